@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/cluster"
+	"github.com/spf13/cobra"
+)
+
+var abuseReportCmd = &cobra.Command{
+	Use:   "abuse-report [filter]",
+	Short: "Summarize the clients and paths of request logs for abuse triage",
+	Long: `Aggregate the entries with an httpRequest, like load balancer logs, matching the
+filter and report:
+  - the clients sending the most requests, with their share of the traffic,
+    error ratio, number of distinct paths and most used user agent
+  - the paths with the most "not found" responses, with numbers and IDs
+    normalized into patterns, which usually reveal scanners probing for
+    known vulnerabilities
+
+The report is a table, or a single JSON object with --format json.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, andFilters(filter, "httpRequest.remoteIp:*"), newAbuseSink)
+	},
+}
+
+// newAbuseSink returns the sink aggregating the requests per client and path and printing the report
+func newAbuseSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	top, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		return nil, nil, err
+	}
+	report := newAbuseReport()
+	process, err := newLinePipeline(cmd, func(entry *loggingpb.LogEntry, _ string) error {
+		report.observe(entry)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format := flagOrConfig(cmd, "format")
+	if format == "" {
+		format = defaultFormat(cmd, false)
+	}
+	return process, func() error {
+		if format == formatJSON {
+			line, err := marshalJSONValue(report.summary(top))
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(stdout, line+"\n")
+			return err
+		}
+		return report.writeText(stdout, top)
+	}, nil
+}
+
+type abuseClient struct {
+	IP       string `json:"ip"`
+	Requests int    `json:"requests"`
+	// Share is the fraction of all the requests sent by the client.
+	Share      float64 `json:"share"`
+	Errors     int     `json:"errors"`
+	ErrorRatio float64 `json:"errorRatio"`
+	Paths      int     `json:"paths"`
+	UserAgent  string  `json:"userAgent"`
+
+	paths      map[string]bool
+	userAgents map[string]int
+}
+
+type abusePath struct {
+	Pattern  string `json:"pattern"`
+	NotFound int    `json:"notFound"`
+	Clients  int    `json:"clients"`
+
+	clients map[string]bool
+}
+
+type abuseSummary struct {
+	Requests int            `json:"requests"`
+	Clients  []*abuseClient `json:"clients"`
+	Paths    []*abusePath   `json:"paths"`
+}
+
+// abuseReport aggregates request logs per client IP and path pattern
+type abuseReport struct {
+	requests int
+	clients  map[string]*abuseClient
+	paths    map[string]*abusePath
+}
+
+func newAbuseReport() *abuseReport {
+	return &abuseReport{clients: map[string]*abuseClient{}, paths: map[string]*abusePath{}}
+}
+
+// requestPath returns the path of a request URL, which may be absolute or not
+func requestPath(requestURL string) string {
+	if u, err := url.Parse(requestURL); err == nil && u.Path != "" {
+		return u.Path
+	}
+	path, _, _ := strings.Cut(requestURL, "?")
+	return path
+}
+
+func (r *abuseReport) observe(entry *loggingpb.LogEntry) {
+	request := entry.HttpRequest
+	if request.GetRemoteIp() == "" {
+		return
+	}
+	r.requests++
+
+	ip := request.RemoteIp
+	if addr, ok := parseRemoteIP(ip); ok {
+		ip = addr.String()
+	}
+	client, ok := r.clients[ip]
+	if !ok {
+		client = &abuseClient{IP: ip, paths: map[string]bool{}, userAgents: map[string]int{}}
+		r.clients[ip] = client
+	}
+	client.Requests++
+	if request.Status >= 400 {
+		client.Errors++
+	}
+	pattern := cluster.Normalize(requestPath(request.RequestUrl))
+	client.paths[pattern] = true
+	client.userAgents[request.UserAgent]++
+
+	if request.Status == 404 {
+		path, ok := r.paths[pattern]
+		if !ok {
+			path = &abusePath{Pattern: pattern, clients: map[string]bool{}}
+			r.paths[pattern] = path
+		}
+		path.NotFound++
+		path.clients[ip] = true
+	}
+}
+
+// summary returns the top clients by requests and the top paths by "not found" responses
+func (r *abuseReport) summary(top int) abuseSummary {
+	s := abuseSummary{Requests: r.requests, Clients: []*abuseClient{}, Paths: []*abusePath{}}
+	for _, c := range r.clients {
+		c.Share = float64(c.Requests) / float64(r.requests)
+		c.ErrorRatio = float64(c.Errors) / float64(c.Requests)
+		c.Paths = len(c.paths)
+		c.UserAgent = ""
+		for userAgent, n := range c.userAgents {
+			if n > c.userAgents[c.UserAgent] || n == c.userAgents[c.UserAgent] && userAgent < c.UserAgent {
+				c.UserAgent = userAgent
+			}
+		}
+		s.Clients = append(s.Clients, c)
+	}
+	slices.SortFunc(s.Clients, func(a, b *abuseClient) int {
+		return cmp.Or(cmp.Compare(b.Requests, a.Requests), strings.Compare(a.IP, b.IP))
+	})
+
+	for _, p := range r.paths {
+		p.Clients = len(p.clients)
+		s.Paths = append(s.Paths, p)
+	}
+	slices.SortFunc(s.Paths, func(a, b *abusePath) int {
+		return cmp.Or(cmp.Compare(b.NotFound, a.NotFound), strings.Compare(a.Pattern, b.Pattern))
+	})
+
+	if top > 0 {
+		s.Clients = s.Clients[:min(top, len(s.Clients))]
+		s.Paths = s.Paths[:min(top, len(s.Paths))]
+	}
+	return s
+}
+
+func (r *abuseReport) writeText(w io.Writer, top int) error {
+	s := r.summary(top)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "CLIENT\tREQUESTS\tSHARE\tERRORS\tPATHS\tUSER AGENT\n")
+	for _, c := range s.Clients {
+		fmt.Fprintf(tw, "%s\t%d\t%.1f%%\t%.1f%%\t%d\t%s\n", c.IP, c.Requests, c.Share*100, c.ErrorRatio*100, c.Paths, c.UserAgent)
+	}
+	if len(s.Paths) > 0 {
+		fmt.Fprintf(tw, "\nNOT FOUND\tCLIENTS\tPATH\n")
+		for _, p := range s.Paths {
+			fmt.Fprintf(tw, "%d\t%d\t%s\n", p.NotFound, p.Clients, p.Pattern)
+		}
+	}
+	fmt.Fprintf(tw, "\n%d requests from %d clients\n", r.requests, len(r.clients))
+	return tw.Flush()
+}
+
+func init() {
+	addFetchFlags(abuseReportCmd)
+	addReportFlags(abuseReportCmd)
+	addGrepFlags(abuseReportCmd)
+	abuseReportCmd.Flags().Int("top", 20, "number of clients and paths to report (0 for all)")
+
+	rootCmd.AddCommand(abuseReportCmd)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+func TestAbuseReport(t *testing.T) {
+	r := newAbuseReport()
+	request := func(ip, url string, status int32, userAgent string) {
+		r.observe(&loggingpb.LogEntry{HttpRequest: &logtypepb.HttpRequest{RemoteIp: ip, RequestUrl: url, Status: status, UserAgent: userAgent}})
+	}
+	for i := range 6 {
+		request("198.51.100.9", "https://example.com/wp-login.php?v="+string(rune('a'+i)), 404, "scanner")
+	}
+	request("198.51.100.9", "/.env", 404, "scanner")
+	request("198.51.100.9", "/", 200, "curl/8")
+	request("203.0.113.5", "/users/42", 200, "Mozilla")
+	request("203.0.113.5:5123", "/users/43", 500, "Mozilla")
+	request("203.0.113.7", "/.env", 404, "")
+	r.observe(&loggingpb.LogEntry{})
+
+	s := r.summary(2)
+	if s.Requests != 11 || len(s.Clients) != 2 || len(s.Paths) != 2 {
+		t.Fatalf("summary(2) = %+v", s)
+	}
+	scanner := s.Clients[0]
+	if scanner.IP != "198.51.100.9" || scanner.Requests != 8 || scanner.Errors != 7 || scanner.Paths != 3 || scanner.UserAgent != "scanner" {
+		t.Errorf("top client = %+v", scanner)
+	}
+	if user := s.Clients[1]; user.IP != "203.0.113.5" || user.Requests != 2 || user.ErrorRatio != 0.5 || user.Paths != 1 {
+		t.Errorf("second client = %+v, want the port stripped and /users/<num> as a single path", user)
+	}
+	if p := s.Paths[0]; p.Pattern != "/wp-login.php" || p.NotFound != 6 || p.Clients != 1 {
+		t.Errorf("top path = %+v", p)
+	}
+	if p := s.Paths[1]; p.Pattern != "/.env" || p.NotFound != 2 || p.Clients != 2 {
+		t.Errorf("second path = %+v", p)
+	}
+
+	var b strings.Builder
+	if err := r.writeText(&b, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "11 requests from 3 clients") {
+		t.Errorf("writeText() = %q", b.String())
+	}
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+)
+
+// newEntrySink returns the function consuming each fetched entry, printing it or, with --stats,
+// aggregating it, and the function to call once all the entries were consumed
+func newEntrySink(cmd *cobra.Command) (process func(*loggingpb.LogEntry) error, flush func() error, err error) {
+	aggregate, err := cmd.Flags().GetBool("stats")
+	if err != nil {
+		return nil, nil, err
+	}
+	var routes []string
+	// Only the queries have --route, local reads files.
+	if cmd.Flag("route") != nil {
+		if routes, err = cmd.Flags().GetStringArray("route"); err != nil {
+			return nil, nil, err
+		}
+	}
+	if isSyslogOutput(cmd) {
+		if aggregate || len(routes) > 0 || entryFormat(cmd) == formatParquet {
+			return nil, nil, errors.New("--output syslog:// cannot be used together with --stats, --route and --format parquet")
+		}
+		return newSyslogSink(cmd)
+	}
+	if entryFormat(cmd) == formatParquet {
+		if aggregate || len(routes) > 0 {
+			return nil, nil, errors.New("--format parquet cannot be used together with --stats and --route")
+		}
+		return newParquetSink(cmd)
+	}
+	if len(routes) > 0 {
+		if aggregate {
+			return nil, nil, errors.New("--route cannot be used together with --stats")
+		}
+		return newRoutedPrinter(cmd, routes)
+	}
+	if !aggregate {
+		return newEntryPrinter(cmd)
+	}
+
+	groupBy, err := cmd.Flags().GetStringSlice("group-by")
+	if err != nil {
+		return nil, nil, err
+	}
+	stats := newEntryStats(groupBy...)
+	process, err = newLinePipeline(cmd, func(entry *loggingpb.LogEntry, _ string) error {
+		return stats.observe(entry)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format := flagOrConfig(cmd, "format")
+	if format == "" {
+		format = defaultFormat(cmd, false)
+	}
+	return process, func() error {
+		if format == formatJSON || format == formatK8s {
+			line, err := marshalJSONValue(stats.report())
+			if err != nil {
+				return err
+			}
+			_, err = stdout.Write([]byte(line + "\n"))
+			return err
+		}
+		return stats.writeText(stdout)
+	}, nil
+}
+
+// reloadableSink reports whether newEntrySink returns a printer, which can be created again when the
+// output settings change, rather than a sink keeping state until it is flushed
+func reloadableSink(cmd *cobra.Command) bool {
+	aggregate, _ := cmd.Flags().GetBool("stats")
+	var routes []string
+	if cmd.Flag("route") != nil {
+		routes, _ = cmd.Flags().GetStringArray("route")
+	}
+	return !aggregate && len(routes) == 0 && !isSyslogOutput(cmd) && entryFormat(cmd) != formatParquet
+}
+
+// entryStats aggregates the entries of a query for --stats
+type entryStats struct {
+	total          int
+	bySeverity     map[string]int
+	byLog          map[string]int
+	byResourceType map[string]int
+	perMinute      map[time.Time]int
+	// groupBy are the fields of --group-by, counted in byField.
+	groupBy []string
+	byField map[string]map[string]int
+}
+
+func newEntryStats(groupBy ...string) *entryStats {
+	s := &entryStats{
+		bySeverity:     map[string]int{},
+		byLog:          map[string]int{},
+		byResourceType: map[string]int{},
+		perMinute:      map[time.Time]int{},
+		groupBy:        groupBy,
+		byField:        map[string]map[string]int{},
+	}
+	for _, field := range groupBy {
+		s.byField[field] = map[string]int{}
+	}
+	return s
+}
+
+func (s *entryStats) observe(entry *loggingpb.LogEntry) error {
+	s.total++
+	s.bySeverity[entry.Severity.String()]++
+	s.byLog[shortLogName(entry.LogName)]++
+	resourceType := entry.Resource.GetType()
+	if resourceType == "" {
+		resourceType = "-"
+	}
+	s.byResourceType[resourceType]++
+	if entry.Timestamp != nil {
+		s.perMinute[entry.Timestamp.AsTime().UTC().Truncate(time.Minute)]++
+	}
+
+	if len(s.groupBy) == 0 {
+		return nil
+	}
+	m, err := entryFields(entry)
+	if err != nil {
+		return err
+	}
+	for _, field := range s.groupBy {
+		value, err := fieldValue(m, strings.Split(field, "."))
+		if err != nil {
+			return err
+		}
+		s.byField[field][value]++
+	}
+	return nil
+}
+
+type statsReport struct {
+	Total          int            `json:"total"`
+	BySeverity     map[string]int `json:"bySeverity"`
+	ByLog          map[string]int `json:"byLog"`
+	ByResourceType map[string]int `json:"byResourceType"`
+	PerMinute      []minuteCount  `json:"perMinute"`
+	// ByField holds the counts of --group-by per field and value.
+	ByField map[string]map[string]int `json:"byField,omitempty"`
+}
+
+type minuteCount struct {
+	Minute  time.Time `json:"minute"`
+	Entries int       `json:"entries"`
+}
+
+// report returns the statistics, with the minutes without entries between the first and last one
+func (s *entryStats) report() statsReport {
+	r := statsReport{
+		Total:          s.total,
+		BySeverity:     s.bySeverity,
+		ByLog:          s.byLog,
+		ByResourceType: s.byResourceType,
+		PerMinute:      []minuteCount{},
+	}
+	if len(s.groupBy) > 0 {
+		r.ByField = s.byField
+	}
+	if len(s.perMinute) == 0 {
+		return r
+	}
+
+	var first, last time.Time
+	for minute := range s.perMinute {
+		if first.IsZero() || minute.Before(first) {
+			first = minute
+		}
+		if minute.After(last) {
+			last = minute
+		}
+	}
+	for minute := first; !minute.After(last); minute = minute.Add(time.Minute) {
+		r.PerMinute = append(r.PerMinute, minuteCount{minute, s.perMinute[minute]})
+	}
+	return r
+}
+
+// writeText prints the statistics for humans, summarizing the entries per minute
+func (s *entryStats) writeText(w io.Writer) error {
+	r := s.report()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Entries: %d\n", r.Total)
+	if len(r.PerMinute) > 0 {
+		peak := slices.MaxFunc(r.PerMinute, func(a, b minuteCount) int { return a.Entries - b.Entries })
+		fmt.Fprintf(&b, "Per minute: %.1f on average, peak of %d at %s\n",
+			float64(r.Total)/float64(len(r.PerMinute)), peak.Entries, peak.Minute.In(outputLocation).Format(minuteLayout))
+	}
+	writeCounts(&b, "By severity", r.BySeverity)
+	writeCounts(&b, "By log", r.ByLog)
+	writeCounts(&b, "By resource type", r.ByResourceType)
+	for _, field := range s.groupBy {
+		writeCounts(&b, "By "+field, r.ByField[field])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeCounts prints a section of counts, largest first
+func writeCounts(b *strings.Builder, title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	width := 0
+	for k := range counts {
+		keys = append(keys, k)
+		width = max(width, len(k))
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if counts[a] != counts[b] {
+			return counts[b] - counts[a]
+		}
+		return strings.Compare(a, b)
+	})
+
+	fmt.Fprintf(b, "\n%s:\n", title)
+	for _, k := range keys {
+		fmt.Fprintf(b, "  %-*s %d\n", width, k, counts[k])
+	}
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestEntryStats(t *testing.T) {
+	start := time.Date(2025, 1, 2, 15, 4, 0, 0, time.UTC)
+	stats := newEntryStats()
+	for i, offset := range []time.Duration{0, 10 * time.Second, 2 * time.Minute} {
+		severity := logtypepb.LogSeverity_INFO
+		if i == 2 {
+			severity = logtypepb.LogSeverity_ERROR
+		}
+		stats.observe(&loggingpb.LogEntry{
+			LogName:   "projects/p/logs/run.googleapis.com%2Fstderr",
+			Resource:  &monitoredres.MonitoredResource{Type: "cloud_run_revision"},
+			Severity:  severity,
+			Timestamp: timestamppb.New(start.Add(offset)),
+		})
+	}
+
+	r := stats.report()
+	if r.Total != 3 || r.BySeverity["INFO"] != 2 || r.ByLog["run.googleapis.com/stderr"] != 3 || r.ByResourceType["cloud_run_revision"] != 3 {
+		t.Errorf("report() = %+v", r)
+	}
+	var perMinute []int
+	for _, m := range r.PerMinute {
+		perMinute = append(perMinute, m.Entries)
+	}
+	if len(perMinute) != 3 || perMinute[0] != 2 || perMinute[1] != 0 || perMinute[2] != 1 {
+		t.Errorf("report() per minute = %v, want [2 0 1]", perMinute)
+	}
+
+	var b strings.Builder
+	if err := stats.writeText(&b); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"Entries: 3\n",
+		"Per minute: 1.0 on average, peak of 2 at 2025-01-02T15:04Z\n",
+		"By severity:\n  INFO  2\n  ERROR 1\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("writeText() = %q, missing %q", b.String(), expected)
+		}
+	}
+}
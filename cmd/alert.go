@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+)
+
+// Alert states, in the state field of the alert payloads.
+const (
+	alertFiring   = "firing"
+	alertResolved = "resolved"
+)
+
+// alertExecTimeout bounds each run of the --exec command
+const alertExecTimeout = time.Minute
+
+var alertCmd = &cobra.Command{
+	Use:   "alert [filter]",
+	Short: "Notify when the matching entries exceed a threshold",
+	Long: `Count the entries matching the filter in the last --window at every
+--interval, and notify when their number goes above --threshold, then when it
+goes back to it or below, until interrupted.
+
+The notifications are JSON objects, POSTed to --webhook (valid Slack messages
+thanks to their text field) and/or written to the standard input of --exec,
+which is run by sh with GRAPPLE_ALERT_STATE (firing or resolved) and
+GRAPPLE_ALERT_COUNT set.
+
+To spare the read quota of the Logging API, the entries are counted by pages
+of 1000 and the count stops at the first page past the threshold.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+
+		userFilter := ""
+		if len(args) > 0 {
+			userFilter = args[0]
+		}
+		for _, name := range []string{"from", "to", "freshness"} {
+			if cmd.Flags().Changed(name) {
+				cobra.CheckErr(fmt.Errorf("--%s cannot be used with alert, see --window", name))
+			}
+		}
+		threshold, err := cmd.Flags().GetInt("threshold")
+		cobra.CheckErr(err)
+		if threshold < 0 {
+			cobra.CheckErr(fmt.Errorf("invalid --threshold %d", threshold))
+		}
+		window, err := parseFreshness(cmd.Flag("window").Value.String())
+		cobra.CheckErr(err)
+		interval, err := parseFreshness(cmd.Flag("interval").Value.String())
+		cobra.CheckErr(err)
+		if window <= 0 || interval <= 0 {
+			cobra.CheckErr(errors.New("--window and --interval must be positive"))
+		}
+
+		var senders []func(alertPayload) error
+		if url := cmd.Flag("webhook").Value.String(); url != "" {
+			senders = append(senders, postWebhook[alertPayload](url))
+		}
+		if command := cmd.Flag("exec").Value.String(); command != "" {
+			senders = append(senders, execAlert(command))
+		}
+		if len(senders) == 0 {
+			cobra.CheckErr(errors.New("at least one of --webhook and --exec is required"))
+		}
+
+		filter, err := composeFilter(cmd, userFilter)
+		cobra.CheckErr(err)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		baseOpts := []logadmin.EntriesOption{logadmin.PageSize(maxPageSize)}
+		views, err := determineViews(ctx, cmd, client)
+		cobra.CheckErr(err)
+		if len(views) > 0 {
+			baseOpts = append(baseOpts, logadmin.ResourceNames(views))
+		}
+
+		a := &alerter{project: projectId, filter: filter, threshold: threshold, window: window}
+		log.Printf("Alerting on more than %d entries in %s, every %s", threshold, window, interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := time.Now(); ; {
+			opts := append(slices.Clone(baseOpts), logadmin.Filter(buildFilter(now.Add(-window), now, filter)))
+			count, _, err := countEntries(ctx, client, opts, threshold)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				// A failed count keeps the previous state, like a missing data point.
+				log.Printf("Error counting the entries: %v", err)
+			} else if payload := a.evaluate(count, now); payload != nil {
+				log.Printf("Alert %s: %s", payload.State, payload.Text)
+				for _, send := range senders {
+					if err := send(*payload); err != nil {
+						log.Printf("Warning: alert notification failed: %v", err)
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case now = <-ticker.C:
+			}
+		}
+	},
+}
+
+// alertPayload is the JSON object sent by the alert command, text makes it a valid Slack message
+type alertPayload struct {
+	Text      string    `json:"text"`
+	State     string    `json:"state"`
+	Count     int       `json:"count"`
+	Threshold int       `json:"threshold"`
+	Window    string    `json:"window"`
+	Project   string    `json:"project"`
+	Filter    string    `json:"filter"`
+	At        time.Time `json:"at"`
+}
+
+// alerter tracks whether the alert is firing, to notify only its changes
+type alerter struct {
+	project   string
+	filter    string
+	threshold int
+	window    time.Duration
+	firing    bool
+}
+
+// evaluate returns the payload to send for the count of the window ending at now, nil when the state is unchanged
+func (a *alerter) evaluate(count int, now time.Time) *alertPayload {
+	firing := count > a.threshold
+	if firing == a.firing {
+		return nil
+	}
+	a.firing = firing
+
+	payload := &alertPayload{
+		State:     alertResolved,
+		Count:     count,
+		Threshold: a.threshold,
+		Window:    a.window.String(),
+		Project:   a.project,
+		Filter:    a.filter,
+		At:        now.UTC(),
+	}
+	if firing {
+		payload.State = alertFiring
+		payload.Text = fmt.Sprintf("More than %d entries in the last %s in %s: %s", a.threshold, a.window, a.project, a.filter)
+	} else {
+		payload.Text = fmt.Sprintf("Resolved: %d entries in the last %s in %s: %s", count, a.window, a.project, a.filter)
+	}
+	return payload
+}
+
+// execAlert returns a sender running the command with sh, the payload on its standard input
+func execAlert(command string) func(alertPayload) error {
+	return func(payload alertPayload) error {
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), alertExecTimeout)
+		defer cancel()
+		c := exec.CommandContext(ctx, "sh", "-c", command)
+		c.Env = append(os.Environ(), "GRAPPLE_ALERT_STATE="+payload.State, "GRAPPLE_ALERT_COUNT="+strconv.Itoa(payload.Count))
+		c.Stdin = bytes.NewReader(append(body, '\n'))
+		c.Stdout = os.Stderr
+		c.Stderr = os.Stderr
+		return c.Run()
+	}
+}
+
+func init() {
+	addFilterFlags(alertCmd)
+	alertCmd.Flags().Int("threshold", 0, "notify when more than this many entries match in --window")
+	alertCmd.Flags().String("window", "5m", "length of the time window whose entries are counted (e.g. 5m, 1h)")
+	alertCmd.Flags().String("interval", "1m", "how often the entries are counted")
+	alertCmd.Flags().String("webhook", "", "POST the notifications as JSON (Slack compatible) to this URL")
+	alertCmd.Flags().String("exec", "", "run this shell command for each notification, with the JSON on its standard input")
+
+	rootCmd.AddCommand(alertCmd)
+}
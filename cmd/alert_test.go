@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAlerterEvaluate(t *testing.T) {
+	a := &alerter{project: "p", filter: "severity>=ERROR", threshold: 5, window: 5 * time.Minute}
+	now := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		count int
+		state string
+	}{
+		{3, ""},
+		{5, ""},
+		{6, alertFiring},
+		{1000, ""},
+		{5, alertResolved},
+		{0, ""},
+		{7, alertFiring},
+	}
+	for i, step := range steps {
+		payload := a.evaluate(step.count, now)
+		switch {
+		case step.state == "" && payload != nil:
+			t.Errorf("step %d: evaluate(%d) = %+v, want no notification", i, step.count, payload)
+		case step.state != "" && (payload == nil || payload.State != step.state || payload.Count != step.count):
+			t.Errorf("step %d: evaluate(%d) = %+v, want a %s notification", i, step.count, payload, step.state)
+		}
+	}
+}
+
+func TestExecAlert(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "alert.json")
+	send := execAlert(`cat > "$OUT" && test "$GRAPPLE_ALERT_STATE" = firing && test "$GRAPPLE_ALERT_COUNT" = 6`)
+	t.Setenv("OUT", out)
+	if err := send(alertPayload{State: alertFiring, Count: 6, Threshold: 5}); err != nil {
+		t.Fatalf("execAlert() unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload alertPayload
+	if err := json.Unmarshal(data, &payload); err != nil || payload.Count != 6 || payload.Threshold != 5 {
+		t.Errorf("execAlert() wrote %s, %v", data, err)
+	}
+
+	if err := execAlert("exit 3")(alertPayload{}); err == nil {
+		t.Error("execAlert() with a failing command expected error, got nil")
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// projectReference matches project references within resource names and filters, e.g. projects/prod/logs/...
+var projectReference = regexp.MustCompile(`\bprojects/([A-Za-z0-9_-]+)`)
+
+// projectAliases returns the aliases defined in the config, keyed by lowercase alias.
+func projectAliases() map[string]string {
+	return viper.GetStringMapString("aliases")
+}
+
+// expandProject resolves a project alias into the actual project ID, leaving other values untouched
+func expandProject(project string, aliases map[string]string) string {
+	if expanded, ok := aliases[strings.ToLower(project)]; ok {
+		return expanded
+	}
+	return project
+}
+
+// expandProjectReferences resolves aliases in the projects/ALIAS references of a filter or resource name.
+// Quoted string literals are searched text, like textPayload:"GET /projects/prod", left untouched
+// unless they are resource names themselves, like logName="projects/prod/logs/app".
+func expandProjectReferences(s string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return s
+	}
+	expand := func(match string) string {
+		return "projects/" + expandProject(strings.TrimPrefix(match, "projects/"), aliases)
+	}
+
+	var b strings.Builder
+	for s != "" {
+		start := strings.IndexByte(s, '"')
+		if start < 0 {
+			b.WriteString(projectReference.ReplaceAllStringFunc(s, expand))
+			break
+		}
+		b.WriteString(projectReference.ReplaceAllStringFunc(s[:start], expand))
+		end := quotedLiteralEnd(s, start)
+		literal := s[start:end]
+		if loc := projectReference.FindStringIndex(literal); loc != nil && loc[0] == 1 {
+			literal = `"` + expand(literal[1:loc[1]]) + literal[loc[1]:]
+		}
+		b.WriteString(literal)
+		s = s[end:]
+	}
+	return b.String()
+}
+
+// quotedLiteralEnd returns the offset following the string literal starting with the quote at start,
+// skipping escaped quotes, or the length of s when the literal is not closed
+func quotedLiteralEnd(s string, start int) int {
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(s)
+}
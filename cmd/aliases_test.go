@@ -0,0 +1,29 @@
+package cmd
+
+import "testing"
+
+func TestExpandProjectReferences(t *testing.T) {
+	aliases := map[string]string{"prod": "my-prod-project-1234", "stg": "my-staging-project"}
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{`logName="projects/prod/logs/app"`, `logName="projects/my-prod-project-1234/logs/app"`},
+		{`logName="projects/PROD/logs/app"`, `logName="projects/my-prod-project-1234/logs/app"`},
+		{`projects/stg/locations/global/buckets/b`, `projects/my-staging-project/locations/global/buckets/b`},
+		{`logName="projects/production/logs/app"`, `logName="projects/production/logs/app"`},
+		{`textPayload:"prod"`, `textPayload:"prod"`},
+		{`subprojects/prod`, `subprojects/prod`},
+		{`textPayload:"GET /projects/prod/settings"`, `textPayload:"GET /projects/prod/settings"`},
+		{`logName="projects/prod/logs/app" "moved to projects/prod"`, `logName="projects/my-prod-project-1234/logs/app" "moved to projects/prod"`},
+		{`textPayload:"say \"projects/prod\"" OR logName:projects/prod`, `textPayload:"say \"projects/prod\"" OR logName:projects/my-prod-project-1234`},
+		{`textPayload:"unterminated projects/prod`, `textPayload:"unterminated projects/prod`},
+	}
+
+	for _, c := range cases {
+		if got := expandProjectReferences(c.input, aliases); got != c.expected {
+			t.Errorf("expandProjectReferences(%q) = %q, want %q", c.input, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	audit "google.golang.org/genproto/googleapis/cloud/audit"
+	"google.golang.org/grpc/codes"
+)
+
+// auditAll selects all the kinds of audit logs, it is the value of a bare --audit
+const auditAll = "all"
+
+// auditLogIDs maps the kinds accepted by --audit to the IDs of the Cloud Audit Logs
+var auditLogIDs = map[string]string{
+	"admin":  "cloudaudit.googleapis.com/activity",
+	"data":   "cloudaudit.googleapis.com/data_access",
+	"system": "cloudaudit.googleapis.com/system_event",
+	"policy": "cloudaudit.googleapis.com/policy",
+}
+
+func auditKinds() []string {
+	var kinds []string
+	for kind := range auditLogIDs {
+		kinds = append(kinds, kind)
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+// auditFilter returns the filter selecting the audit logs of the given kind, or of all kinds
+func auditFilter(kind string) (string, error) {
+	if kind == auditAll {
+		var clauses []string
+		for _, kind := range auditKinds() {
+			clauses = append(clauses, fmt.Sprintf("log_id(%q)", auditLogIDs[kind]))
+		}
+		return strings.Join(clauses, " OR "), nil
+	}
+
+	logID, ok := auditLogIDs[kind]
+	if !ok {
+		return "", fmt.Errorf("invalid --audit %q, valid values: %s, %s", kind, auditAll, strings.Join(auditKinds(), ", "))
+	}
+	return fmt.Sprintf("log_id(%q)", logID), nil
+}
+
+// decodeAuditLog returns the AuditLog carried by the protoPayload of an entry, if any
+func decodeAuditLog(entry *loggingpb.LogEntry) (*audit.AuditLog, bool) {
+	payload, ok := entry.Payload.(*loggingpb.LogEntry_ProtoPayload)
+	if !ok {
+		return nil, false
+	}
+	auditLog := &audit.AuditLog{}
+	if err := payload.ProtoPayload.UnmarshalTo(auditLog); err != nil {
+		return nil, false
+	}
+	return auditLog, true
+}
+
+// renderAudit formats an audit entry as a single line:
+// TIMESTAMP SEVERITY PRINCIPAL METHOD RESOURCE STATUS [CALLER-IP]
+// Entries that aren't audit logs are rendered as in the text format.
+func renderAudit(entry *loggingpb.LogEntry, color bool) string {
+	auditLog, ok := decodeAuditLog(entry)
+	if !ok {
+		return renderText(entry, color)
+	}
+
+	principal := auditLog.AuthenticationInfo.GetPrincipalEmail()
+	if principal == "" {
+		principal = "-"
+	}
+
+	status := codes.OK.String()
+	if s := auditLog.Status; s != nil && s.Code != 0 {
+		status = codes.Code(s.Code).String()
+		if s.Message != "" {
+			status = fmt.Sprintf("%s: %s", status, s.Message)
+		}
+	}
+
+	line := fmt.Sprintf("%s %s %s %s %s", renderPrefix(entry, color), principal, auditLog.MethodName, auditLog.ResourceName, status)
+	if ip := auditLog.RequestMetadata.GetCallerIp(); ip != "" {
+		line += fmt.Sprintf(" [%s]", ip)
+	}
+	return line
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	audit "google.golang.org/genproto/googleapis/cloud/audit"
+	statuspb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+func TestAuditFilter(t *testing.T) {
+	tests := []struct {
+		kind     string
+		expected string
+		wantErr  bool
+	}{
+		{"admin", `log_id("cloudaudit.googleapis.com/activity")`, false},
+		{"all", `log_id("cloudaudit.googleapis.com/activity") OR log_id("cloudaudit.googleapis.com/data_access") OR log_id("cloudaudit.googleapis.com/policy") OR log_id("cloudaudit.googleapis.com/system_event")`, false},
+		{"access", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.kind, func(t *testing.T) {
+			got, err := auditFilter(tt.kind)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("auditFilter(%q) error = %v, wantErr %v", tt.kind, err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("auditFilter(%q) = %q, want %q", tt.kind, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderAudit(t *testing.T) {
+	payload, err := anypb.New(&audit.AuditLog{
+		AuthenticationInfo: &audit.AuthenticationInfo{PrincipalEmail: "alice@example.com"},
+		MethodName:         "storage.buckets.delete",
+		ResourceName:       "projects/_/buckets/b",
+		Status:             &statuspb.Status{Code: 7, Message: "denied"},
+		RequestMetadata:    &audit.RequestMetadata{CallerIp: "10.0.0.1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_ProtoPayload{ProtoPayload: payload}}
+
+	expected := "- DEFAULT   alice@example.com storage.buckets.delete projects/_/buckets/b PermissionDenied: denied [10.0.0.1]"
+	if got := renderAudit(entry, false); got != expected {
+		t.Errorf("renderAudit() = %q, want %q", got, expected)
+	}
+}
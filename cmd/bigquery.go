@@ -0,0 +1,542 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// bigQueryMaxBatchBytes bounds the size of the NDJSON data of a load job, whatever --batch-size
+const bigQueryMaxBatchBytes = 100 << 20
+
+// bigQueryPollInterval is how often the load jobs are checked for completion
+var bigQueryPollInterval = time.Second
+
+var exportBigQueryCmd = &cobra.Command{
+	Use:   "bigquery --dataset DATASET --table TABLE [filter]",
+	Short: "Export entries to a BigQuery table",
+	Long: `Load the entries matching the filter into a BigQuery table, in batches of
+--batch-size entries, with the schema of the tables written by the BigQuery
+sinks of Cloud Logging, so that historical entries can be queried together
+with the exported ones:
+
+  logName, insertId, severity, textPayload, trace, spanId, traceSampled
+  timestamp, receiveTimestamp (TIMESTAMP)
+  resource (type and labels), labels, httpRequest, operation,
+  sourceLocation, split (RECORD)
+  jsonPayload, protopayload_auditlog (RECORD)
+
+Like in the sinks, the fields of the labels and payloads become columns of
+their records, named with the characters other than letters, digits and
+underscores replaced by underscores, and new ones are added to the table as
+they appear. Values not matching the type of their column are dropped.
+
+The table is created if needed, partitioned by day on timestamp. The load
+jobs are free, the storage is billed to the project of the dataset.`,
+	Annotations: map[string]string{annotationWritesOutput: "true"},
+	Args:        cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newBigQuerySink)
+	},
+}
+
+// bigQueryExporter loads the entries into a table, a batch per load job, keeping the schema of the table
+// widened with the fields of the entries
+type bigQueryExporter struct {
+	ctx       context.Context
+	jobs      *bigquery.JobsService
+	project   string
+	location  string
+	table     *bigquery.TableReference
+	fields    []*bigquery.TableFieldSchema
+	batchSize int
+	// create is whether the table did not exist, to be created by the first load job.
+	create bool
+
+	batch   bytes.Buffer
+	rows    int
+	loaded  int
+	dropped int
+	// err is the failure of a load job that stopped the exporter.
+	err error
+}
+
+// newBigQuerySink returns the function adding each entry to the batch to load into the --table, and
+// the function loading the last batch
+func newBigQuerySink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	projectId := requireProject()
+	batchSize, err := cmd.Flags().GetInt("batch-size")
+	if err != nil {
+		return nil, nil, err
+	}
+	if batchSize <= 0 {
+		return nil, nil, fmt.Errorf("invalid --batch-size %d", batchSize)
+	}
+	dataset := datasetReference(projectId, cmd.Flag("dataset").Value.String())
+
+	ctx := cmd.Context()
+	opts, err := clientOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	service, err := bigquery.NewService(ctx, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &bigQueryExporter{
+		ctx:       ctx,
+		jobs:      service.Jobs,
+		project:   projectId,
+		location:  cmd.Flag("job-location").Value.String(),
+		table:     &bigquery.TableReference{ProjectId: dataset.ProjectId, DatasetId: dataset.DatasetId, TableId: cmd.Flag("table").Value.String()},
+		batchSize: batchSize,
+	}
+
+	table, err := service.Tables.Get(e.table.ProjectId, e.table.DatasetId, e.table.TableId).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+		e.create = true
+	case err != nil:
+		return nil, nil, err
+	case table.Schema != nil:
+		e.fields = table.Schema.Fields
+	}
+	for _, field := range bigQueryEntryFields() {
+		if findBigQueryField(e.fields, field.Name) == nil {
+			e.fields = append(e.fields, field)
+		}
+	}
+	return e.process, e.flush, nil
+}
+
+// bigQueryField returns a nullable field of the schema
+func bigQueryField(name, typ string, fields ...*bigquery.TableFieldSchema) *bigquery.TableFieldSchema {
+	return &bigquery.TableFieldSchema{Name: name, Type: typ, Mode: "NULLABLE", Fields: fields}
+}
+
+// bigQueryEntryFields returns the fixed fields of the schema of the BigQuery sinks, the labels and
+// payloads being added as they appear
+func bigQueryEntryFields() []*bigquery.TableFieldSchema {
+	return []*bigquery.TableFieldSchema{
+		bigQueryField("logName", "STRING"),
+		bigQueryField("resource", "RECORD", bigQueryField("type", "STRING")),
+		bigQueryField("textPayload", "STRING"),
+		bigQueryField("timestamp", "TIMESTAMP"),
+		bigQueryField("receiveTimestamp", "TIMESTAMP"),
+		bigQueryField("severity", "STRING"),
+		bigQueryField("insertId", "STRING"),
+		bigQueryField("httpRequest", "RECORD",
+			bigQueryField("requestMethod", "STRING"),
+			bigQueryField("requestUrl", "STRING"),
+			bigQueryField("requestSize", "INTEGER"),
+			bigQueryField("status", "INTEGER"),
+			bigQueryField("responseSize", "INTEGER"),
+			bigQueryField("userAgent", "STRING"),
+			bigQueryField("remoteIp", "STRING"),
+			bigQueryField("serverIp", "STRING"),
+			bigQueryField("referer", "STRING"),
+			bigQueryField("latency", "FLOAT"),
+			bigQueryField("cacheLookup", "BOOLEAN"),
+			bigQueryField("cacheHit", "BOOLEAN"),
+			bigQueryField("cacheValidatedWithOriginServer", "BOOLEAN"),
+			bigQueryField("cacheFillBytes", "INTEGER"),
+			bigQueryField("protocol", "STRING"),
+		),
+		bigQueryField("operation", "RECORD",
+			bigQueryField("id", "STRING"),
+			bigQueryField("producer", "STRING"),
+			bigQueryField("first", "BOOLEAN"),
+			bigQueryField("last", "BOOLEAN"),
+		),
+		bigQueryField("trace", "STRING"),
+		bigQueryField("spanId", "STRING"),
+		bigQueryField("traceSampled", "BOOLEAN"),
+		bigQueryField("sourceLocation", "RECORD",
+			bigQueryField("file", "STRING"),
+			bigQueryField("line", "INTEGER"),
+			bigQueryField("function", "STRING"),
+		),
+		bigQueryField("split", "RECORD",
+			bigQueryField("uid", "STRING"),
+			bigQueryField("index", "INTEGER"),
+			bigQueryField("totalSplits", "INTEGER"),
+		),
+	}
+}
+
+// bigQueryRow returns an entry as a row of the schema of the BigQuery sinks, before bigQueryConform:
+// protoPayload becomes protopayload_TYPE, e.g. protopayload_auditlog, its request, response, metadata
+// and serviceData being JSON text in requestJson, responseJson, metadataJson and serviceDataJson
+func bigQueryRow(entry *loggingpb.LogEntry) (map[string]any, error) {
+	row := map[string]any{
+		"logName":          entry.LogName,
+		"textPayload":      entry.GetTextPayload(),
+		"timestamp":        bigQueryTime(entry.Timestamp),
+		"receiveTimestamp": bigQueryTime(entry.ReceiveTimestamp),
+		"severity":         entry.Severity.String(),
+		"insertId":         entry.InsertId,
+		"trace":            entry.Trace,
+		"spanId":           entry.SpanId,
+		"labels":           stringMap(entry.Labels),
+	}
+	if entry.TraceSampled {
+		row["traceSampled"] = true
+	}
+	if resource := entry.Resource; resource != nil {
+		row["resource"] = map[string]any{"type": resource.Type, "labels": stringMap(resource.Labels)}
+	}
+	if r := entry.HttpRequest; r != nil {
+		request := map[string]any{
+			"requestMethod":                  r.RequestMethod,
+			"requestUrl":                     r.RequestUrl,
+			"requestSize":                    r.RequestSize,
+			"status":                         int64(r.Status),
+			"responseSize":                   r.ResponseSize,
+			"userAgent":                      r.UserAgent,
+			"remoteIp":                       r.RemoteIp,
+			"serverIp":                       r.ServerIp,
+			"referer":                        r.Referer,
+			"cacheLookup":                    r.CacheLookup,
+			"cacheHit":                       r.CacheHit,
+			"cacheValidatedWithOriginServer": r.CacheValidatedWithOriginServer,
+			"cacheFillBytes":                 r.CacheFillBytes,
+			"protocol":                       r.Protocol,
+		}
+		if r.Latency != nil {
+			request["latency"] = r.Latency.AsDuration().Seconds()
+		}
+		row["httpRequest"] = request
+	}
+	if o := entry.Operation; o != nil {
+		row["operation"] = map[string]any{"id": o.Id, "producer": o.Producer, "first": o.First, "last": o.Last}
+	}
+	if l := entry.SourceLocation; l != nil {
+		row["sourceLocation"] = map[string]any{"file": l.File, "line": l.Line, "function": l.Function}
+	}
+	if s := entry.Split; s != nil {
+		row["split"] = map[string]any{"uid": s.Uid, "index": int64(s.Index), "totalSplits": int64(s.TotalSplits)}
+	}
+	if payload := entry.GetJsonPayload(); payload != nil {
+		row["jsonPayload"] = payload.AsMap()
+	}
+	if payload := entry.GetProtoPayload(); payload != nil {
+		data, err := protojson.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		var message map[string]any
+		if err := json.Unmarshal(data, &message); err != nil {
+			return nil, err
+		}
+		delete(message, "@type")
+		for _, name := range []string{"request", "response", "metadata", "serviceData"} {
+			if value, ok := message[name]; ok {
+				if message[name+"Json"], err = marshalJSONValue(value); err != nil {
+					return nil, err
+				}
+				delete(message, name)
+			}
+		}
+		typeName := payload.TypeUrl[strings.LastIndex(payload.TypeUrl, ".")+1:]
+		row["protopayload_"+strings.ToLower(typeName)] = message
+	}
+	return row, nil
+}
+
+// bigQueryTime formats a timestamp for the TIMESTAMP columns, empty when missing
+func bigQueryTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format("2006-01-02T15:04:05.999999Z")
+}
+
+func stringMap(m map[string]string) map[string]any {
+	converted := make(map[string]any, len(m))
+	for k, v := range m {
+		converted[k] = v
+	}
+	return converted
+}
+
+// bigQueryFieldName replaces the characters not allowed in column names with underscores
+func bigQueryFieldName(key string) string {
+	name := []byte(key)
+	for i, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+		return "_" + string(name)
+	}
+	return string(name)
+}
+
+// findBigQueryField returns the field with the name, compared case-insensitively like BigQuery does
+func findBigQueryField(fields []*bigquery.TableFieldSchema, name string) *bigquery.TableFieldSchema {
+	for _, field := range fields {
+		if strings.EqualFold(field.Name, name) {
+			return field
+		}
+	}
+	return nil
+}
+
+// bigQueryConform returns the values of the record matching the fields, adding the fields of the new
+// keys, without the empty values and counting the values of the wrong type in dropped
+func bigQueryConform(fields *[]*bigquery.TableFieldSchema, record map[string]any, dropped *int) map[string]any {
+	conformed := map[string]any{}
+	keys := make([]string, 0, len(record))
+	for key := range record {
+		keys = append(keys, key)
+	}
+	// Sorted, so that the new fields are added in a stable order.
+	slices.Sort(keys)
+	for _, key := range keys {
+		name := bigQueryFieldName(key)
+		field := findBigQueryField(*fields, name)
+		if field == nil {
+			if field = inferBigQueryField(name, record[key]); field == nil {
+				continue
+			}
+			if value, ok := conformBigQueryValue(field, record[key], dropped); ok {
+				*fields = append(*fields, field)
+				conformed[field.Name] = value
+			}
+			continue
+		}
+		if value, ok := conformBigQueryValue(field, record[key], dropped); ok {
+			conformed[field.Name] = value
+		}
+	}
+	return conformed
+}
+
+// inferBigQueryField returns the field holding a value, nil for empty values
+func inferBigQueryField(name string, value any) *bigquery.TableFieldSchema {
+	switch v := value.(type) {
+	case string:
+		if v != "" {
+			return bigQueryField(name, "STRING")
+		}
+	case bool:
+		return bigQueryField(name, "BOOLEAN")
+	case int64:
+		return bigQueryField(name, "INTEGER")
+	case float64:
+		return bigQueryField(name, "FLOAT")
+	case map[string]any:
+		if len(v) > 0 {
+			return bigQueryField(name, "RECORD")
+		}
+	case []any:
+		for _, element := range v {
+			if _, nested := element.([]any); nested {
+				// Arrays of arrays are stored as arrays of JSON text.
+				return &bigquery.TableFieldSchema{Name: name, Type: "STRING", Mode: "REPEATED"}
+			}
+			if field := inferBigQueryField(name, element); field != nil {
+				field.Mode = "REPEATED"
+				return field
+			}
+		}
+	}
+	return nil
+}
+
+// conformBigQueryValue converts a value to the type of the field, false for empty values and for the
+// ones of the wrong type, counted in dropped
+func conformBigQueryValue(field *bigquery.TableFieldSchema, value any, dropped *int) (any, bool) {
+	if field.Mode != "REPEATED" {
+		return conformBigQueryScalar(field, value, dropped)
+	}
+	elements, ok := value.([]any)
+	if !ok {
+		elements = []any{value}
+	}
+	var conformed []any
+	for _, element := range elements {
+		if value, ok := conformBigQueryScalar(field, element, dropped); ok {
+			conformed = append(conformed, value)
+		}
+	}
+	return conformed, len(conformed) > 0
+}
+
+func conformBigQueryScalar(field *bigquery.TableFieldSchema, value any, dropped *int) (any, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		if v == "" {
+			return nil, false
+		}
+	case bool:
+		// The false booleans are kept, as in the JSON payloads.
+	case int64:
+		if v == 0 && field.Type != "FLOAT" {
+			return nil, false
+		}
+	}
+
+	switch field.Type {
+	case "STRING":
+		if s, ok := value.(string); ok {
+			return s, true
+		}
+		text, err := marshalJSONValue(value)
+		return text, err == nil
+	case "FLOAT", "FLOAT64":
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case int64:
+			return float64(v), true
+		}
+	case "INTEGER", "INT64":
+		switch v := value.(type) {
+		case int64:
+			return v, true
+		case float64:
+			if v == float64(int64(v)) {
+				return int64(v), true
+			}
+		}
+	case "BOOLEAN", "BOOL":
+		if b, ok := value.(bool); ok {
+			return b, true
+		}
+	case "TIMESTAMP":
+		if s, ok := value.(string); ok {
+			return s, true
+		}
+	case "RECORD", "STRUCT":
+		if record, ok := value.(map[string]any); ok {
+			conformed := bigQueryConform(&field.Fields, record, dropped)
+			return conformed, len(conformed) > 0
+		}
+	default:
+		// Types grapple does not infer, in tables created otherwise, are left to BigQuery.
+		return value, true
+	}
+	*dropped++
+	return nil, false
+}
+
+func (e *bigQueryExporter) process(entry *loggingpb.LogEntry) error {
+	if e.err != nil {
+		return grapple.ErrStop
+	}
+	row, err := bigQueryRow(entry)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(bigQueryConform(&e.fields, row, &e.dropped))
+	if err != nil {
+		return err
+	}
+	e.batch.Write(append(line, '\n'))
+	e.rows++
+	if e.rows >= e.batchSize || e.batch.Len() >= bigQueryMaxBatchBytes {
+		if e.err = e.load(); e.err != nil {
+			return grapple.ErrStop
+		}
+	}
+	return nil
+}
+
+// flush loads the last batch
+func (e *bigQueryExporter) flush() error {
+	if e.err == nil {
+		e.err = e.load()
+	}
+	if e.dropped > 0 {
+		log.Printf("Warning: dropped %d values not matching the type of their column", e.dropped)
+	}
+	log.Printf("Loaded %d entries into %s.%s.%s", e.loaded, e.table.ProjectId, e.table.DatasetId, e.table.TableId)
+	return e.err
+}
+
+// load runs a load job of the batch and waits for it to complete
+func (e *bigQueryExporter) load() error {
+	if e.rows == 0 {
+		return nil
+	}
+	config := &bigquery.JobConfigurationLoad{
+		DestinationTable:    e.table,
+		SourceFormat:        "NEWLINE_DELIMITED_JSON",
+		Schema:              &bigquery.TableSchema{Fields: e.fields},
+		SchemaUpdateOptions: []string{"ALLOW_FIELD_ADDITION"},
+		CreateDisposition:   "CREATE_IF_NEEDED",
+		WriteDisposition:    "WRITE_APPEND",
+	}
+	if e.create {
+		config.SchemaUpdateOptions = nil
+		config.TimePartitioning = &bigquery.TimePartitioning{Type: "DAY", Field: "timestamp"}
+	}
+	job := &bigquery.Job{
+		JobReference:  &bigquery.JobReference{ProjectId: e.project, Location: e.location},
+		Configuration: &bigquery.JobConfiguration{Load: config},
+	}
+	job, err := e.jobs.Insert(e.project, job).Media(bytes.NewReader(e.batch.Bytes())).Context(e.ctx).Do()
+	if err != nil {
+		return fmt.Errorf("starting the load job: %w", err)
+	}
+	for job.Status == nil || job.Status.State != "DONE" {
+		select {
+		case <-e.ctx.Done():
+			return e.ctx.Err()
+		case <-time.After(bigQueryPollInterval):
+		}
+		if job, err = e.jobs.Get(e.project, job.JobReference.JobId).Location(job.JobReference.Location).Context(e.ctx).Do(); err != nil {
+			return fmt.Errorf("checking the load job: %w", err)
+		}
+	}
+	if status := job.Status; status.ErrorResult != nil {
+		messages := []string{status.ErrorResult.Message}
+		for _, e := range status.Errors {
+			if e.Message != status.ErrorResult.Message {
+				messages = append(messages, e.Message)
+			}
+		}
+		return fmt.Errorf("load job %s failed: %s", job.JobReference.JobId, strings.Join(messages, "; "))
+	}
+	e.loaded += e.rows
+	e.rows = 0
+	e.batch.Reset()
+	e.create = false
+	return nil
+}
+
+func init() {
+	addFetchFlags(exportBigQueryCmd)
+	exportBigQueryCmd.Flags().String("dataset", "", "dataset of the table, as DATASET or PROJECT.DATASET")
+	exportBigQueryCmd.Flags().String("table", "", "table to load the entries into, created if needed")
+	exportBigQueryCmd.Flags().String("job-location", "", "location of the load jobs, that of the dataset (default detected by BigQuery)")
+	exportBigQueryCmd.Flags().Int("batch-size", 50000, "number of entries loaded per load job")
+	exportBigQueryCmd.MarkFlagRequired("dataset")
+	exportBigQueryCmd.MarkFlagRequired("table")
+
+	exportCmd.AddCommand(exportBigQueryCmd)
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	bigquery "google.golang.org/api/bigquery/v2"
+	audit "google.golang.org/genproto/googleapis/cloud/audit"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestBigQueryFieldName(t *testing.T) {
+	tests := map[string]string{
+		"userId":        "userId",
+		"k8s-pod/app":   "k8s_pod_app",
+		"2xx":           "_2xx",
+		"":              "_",
+		"request.bytes": "request_bytes",
+	}
+	for key, expected := range tests {
+		if name := bigQueryFieldName(key); name != expected {
+			t.Errorf("bigQueryFieldName(%q) = %q, want %q", key, name, expected)
+		}
+	}
+}
+
+func TestBigQueryConform(t *testing.T) {
+	fields := []*bigquery.TableFieldSchema{bigQueryField("message", "STRING"), bigQueryField("count", "INTEGER")}
+	dropped := 0
+	row := bigQueryConform(&fields, map[string]any{
+		"message": map[string]any{"text": "structured"},
+		"count":   1.5,
+		"latency": 0.25,
+		"ok":      false,
+		"tags":    []any{"a", nil, "b"},
+		"user":    map[string]any{"ID": "u-42", "empty": map[string]any{}},
+		"none":    nil,
+	}, &dropped)
+
+	expected := `{"latency":0.25,"message":"{\"text\":\"structured\"}","ok":false,"tags":["a","b"],"user":{"ID":"u-42"}}`
+	if data, _ := json.Marshal(row); string(data) != expected {
+		t.Errorf("conformed row %s, want %s", data, expected)
+	}
+	if dropped != 1 {
+		t.Errorf("dropped %d values, want the non-integral count", dropped)
+	}
+
+	var names []string
+	for _, field := range fields {
+		names = append(names, field.Name+":"+field.Type+":"+field.Mode)
+	}
+	if schema := strings.Join(names, " "); schema != "message:STRING:NULLABLE count:INTEGER:NULLABLE latency:FLOAT:NULLABLE ok:BOOLEAN:NULLABLE tags:STRING:REPEATED user:RECORD:NULLABLE" {
+		t.Errorf("unexpected schema %s", schema)
+	}
+
+	// The fields are matched case-insensitively, and keep the case they were added with.
+	row = bigQueryConform(&fields, map[string]any{"User": map[string]any{"id": "u-43"}}, &dropped)
+	if data, _ := json.Marshal(row); string(data) != `{"user":{"ID":"u-43"}}` {
+		t.Errorf("conformed row %s", data)
+	}
+	if user := findBigQueryField(fields, "user"); len(user.Fields) != 1 {
+		t.Errorf("user has %d fields, want 1", len(user.Fields))
+	}
+}
+
+func TestBigQueryRow(t *testing.T) {
+	request, err := structpb.NewStruct(map[string]any{"bucket": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := anypb.New(&audit.AuditLog{MethodName: "storage.objects.get", Request: request})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &loggingpb.LogEntry{
+		LogName:   "projects/p/logs/cloudaudit.googleapis.com%2Fdata_access",
+		InsertId:  "a",
+		Timestamp: timestamppb.New(time.Date(2025, 1, 2, 15, 4, 5, 123456789, time.UTC)),
+		Labels:    map[string]string{"k8s-pod/app": "web"},
+		Payload:   &loggingpb.LogEntry_ProtoPayload{ProtoPayload: payload},
+	}
+	fields := bigQueryEntryFields()
+	dropped := 0
+	row, err := bigQueryRow(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(bigQueryConform(&fields, row, &dropped))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"insertId":"a","labels":{"k8s_pod_app":"web"},"logName":"projects/p/logs/cloudaudit.googleapis.com%2Fdata_access","protopayload_auditlog":{"methodName":"storage.objects.get","requestJson":"{\"bucket\":\"b\"}"},"severity":"DEFAULT","timestamp":"2025-01-02T15:04:05.123456Z"}`
+	// protojson randomizes its spacing.
+	if strings.ReplaceAll(string(data), " ", "") != expected {
+		t.Errorf("row %s, want %s", data, expected)
+	}
+	if findBigQueryField(fields, "protopayload_auditlog") == nil || findBigQueryField(fields, "labels") == nil {
+		t.Error("the payload and labels were not added to the schema")
+	}
+}
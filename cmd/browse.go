@@ -0,0 +1,522 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/dippi/grapple/internal/lql"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var browseCmd = &cobra.Command{
+	Use:   "browse [filter]",
+	Short: "Explore the matching entries in a terminal UI",
+	Long: `Fetch the newest entries matching the filter (up to --limit) and explore them
+in a full-screen terminal UI: a scrollable list, with the full JSON of the
+selected entry below it.
+
+Keys:
+  up/down, k/j      select the previous/next entry
+  pgup/pgdown, g/G  scroll the list by a page, go to the first/last entry
+  K/J               scroll the JSON of the selected entry
+  d, i, w, e        show/hide the debug, info, warning and error entries
+  /                 edit the filter, enter fetches again, esc cancels
+  q, ctrl-c         quit`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		if !stdinIsTerminal() || !writesToTerminal(cmd) {
+			cobra.CheckErr(errors.New("browse needs an interactive terminal"))
+		}
+		limit, err := cmd.Flags().GetInt("limit")
+		cobra.CheckErr(err)
+		if limit <= 0 {
+			cobra.CheckErr(fmt.Errorf("invalid --limit %d", limit))
+		}
+		from, to, err := determineTimeWindow(cmd)
+		cobra.CheckErr(err)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		baseOpts := []logadmin.EntriesOption{logadmin.PageSize(int32(min(limit, maxPageSize))), logadmin.NewestFirst()}
+		views, err := determineViews(ctx, cmd, client)
+		cobra.CheckErr(err)
+		if len(views) > 0 {
+			baseOpts = append(baseOpts, logadmin.ResourceNames(views))
+		}
+
+		fetch := func(filter string) ([]*loggingpb.LogEntry, error) {
+			composed, err := composeFilter(cmd, filter)
+			if err != nil {
+				return nil, err
+			}
+			opts := append(slices.Clone(baseOpts), logadmin.Filter(buildFilter(from, to, composed)))
+			var entries []*loggingpb.LogEntry
+			_, err = fetchAndProcessLogs(ctx, client, opts, func(entry *loggingpb.LogEntry) error {
+				entries = append(entries, entry)
+				if len(entries) == limit {
+					return grapple.ErrStop
+				}
+				return nil
+			})
+			return entries, err
+		}
+
+		log.Printf("Fetching up to %d entries...", limit)
+		entries, err := fetch(filter)
+		cobra.CheckErr(err)
+		cobra.CheckErr(runBrowser(ctx, newBrowser(filter, entries), fetch, useColor(cmd)))
+	},
+}
+
+// browseAction is what the browser asks for after a key
+type browseAction int
+
+const (
+	browseNone browseAction = iota
+	browseQuit
+	browseFetch
+)
+
+// browser is the state of the browse UI, independent of the terminal
+type browser struct {
+	filter  string
+	entries []*loggingpb.LogEntry
+	// visible are the indices of the entries of the severities shown.
+	visible []int
+	hidden  map[string]bool
+	// cursor is the selected line of visible, offset the first one on screen.
+	cursor, offset int
+	// detailOffset is the first line of the JSON of the selected entry on screen.
+	detailOffset int
+	editing      bool
+	input        []rune
+	status       string
+	width        int
+	height       int
+}
+
+// browseSeverities are the groups of severities toggled by their key, the lowest severity of each first
+var browseSeverities = []struct {
+	key, name string
+	from      logtypepb.LogSeverity
+}{
+	{"d", "debug", logtypepb.LogSeverity_DEFAULT},
+	{"i", "info", logtypepb.LogSeverity_INFO},
+	{"w", "warning", logtypepb.LogSeverity_WARNING},
+	{"e", "error", logtypepb.LogSeverity_ERROR},
+}
+
+// severityKey returns the key toggling the severity of the entry
+func severityKey(severity logtypepb.LogSeverity) string {
+	key := browseSeverities[0].key
+	for _, s := range browseSeverities {
+		if severity >= s.from {
+			key = s.key
+		}
+	}
+	return key
+}
+
+func newBrowser(filter string, entries []*loggingpb.LogEntry) *browser {
+	b := &browser{filter: filter, hidden: map[string]bool{}, width: 80, height: 24}
+	b.setEntries(entries)
+	return b
+}
+
+// setEntries replaces the entries, selecting the first one
+func (b *browser) setEntries(entries []*loggingpb.LogEntry) {
+	b.entries, b.visible = entries, nil
+	b.cursor, b.offset = 0, 0
+	b.applyToggles()
+	b.status = fmt.Sprintf("%d entries", len(entries))
+}
+
+// applyToggles recomputes the visible entries, keeping the selected one when still visible
+func (b *browser) applyToggles() {
+	selected := -1
+	if b.cursor < len(b.visible) {
+		selected = b.visible[b.cursor]
+	}
+	b.visible = b.visible[:0]
+	b.cursor = 0
+	for i, entry := range b.entries {
+		if b.hidden[severityKey(entry.Severity)] {
+			continue
+		}
+		if i <= selected {
+			b.cursor = len(b.visible)
+		}
+		b.visible = append(b.visible, i)
+	}
+	b.detailOffset = 0
+	b.scroll()
+}
+
+// listHeight is the number of lines of the list, the top half of the screen below the header
+func (b *browser) listHeight() int {
+	return max((b.height-2)/2, 1)
+}
+
+// scroll keeps the selected line on screen
+func (b *browser) scroll() {
+	b.cursor = max(min(b.cursor, len(b.visible)-1), 0)
+	if b.cursor < b.offset {
+		b.offset = b.cursor
+	}
+	if b.cursor >= b.offset+b.listHeight() {
+		b.offset = b.cursor - b.listHeight() + 1
+	}
+}
+
+func (b *browser) move(delta int) {
+	b.cursor += delta
+	b.detailOffset = 0
+	b.scroll()
+}
+
+// handleKey updates the state after a key, see browseKeys for their names
+func (b *browser) handleKey(key string) browseAction {
+	if b.editing {
+		switch key {
+		case "enter":
+			filter := string(b.input)
+			if _, err := lql.Parse(filter); err != nil {
+				b.status = "Invalid filter at " + err.Error()
+				return browseNone
+			}
+			b.editing = false
+			b.filter = filter
+			return browseFetch
+		case "esc", "ctrl-c":
+			b.editing = false
+			b.status = ""
+		case "backspace":
+			if len(b.input) > 0 {
+				b.input = b.input[:len(b.input)-1]
+			}
+		default:
+			if r := []rune(key); len(r) == 1 {
+				b.input = append(b.input, r[0])
+			}
+		}
+		return browseNone
+	}
+
+	switch key {
+	case "q", "ctrl-c":
+		return browseQuit
+	case "up", "k":
+		b.move(-1)
+	case "down", "j":
+		b.move(1)
+	case "pgup":
+		b.move(-b.listHeight())
+	case "pgdown":
+		b.move(b.listHeight())
+	case "home", "g":
+		b.move(-len(b.visible))
+	case "end", "G":
+		b.move(len(b.visible))
+	case "K":
+		b.detailOffset = max(b.detailOffset-1, 0)
+	case "J":
+		b.detailOffset++
+	case "/":
+		b.editing = true
+		b.input = []rune(b.filter)
+		b.status = "Enter fetches the entries of the filter, esc cancels"
+	default:
+		for _, s := range browseSeverities {
+			if key == s.key {
+				b.hidden[key] = !b.hidden[key]
+				b.applyToggles()
+			}
+		}
+	}
+	return browseNone
+}
+
+// selected returns the selected entry, nil when no entry is visible
+func (b *browser) selected() *loggingpb.LogEntry {
+	if len(b.visible) == 0 {
+		return nil
+	}
+	return b.entries[b.visible[b.cursor]]
+}
+
+// render draws the whole screen: the filter, the list, a status line and the JSON of the selected entry
+func (b *browser) render(w io.Writer, color bool) error {
+	var s strings.Builder
+	s.WriteString("\x1b[H\x1b[2J")
+	line := func(text, sgr string) {
+		text = fitWidth(text, b.width)
+		if sgr != "" && color {
+			text = "\x1b[" + sgr + "m" + text + "\x1b[0m"
+		}
+		s.WriteString(text + "\r\n")
+	}
+
+	if b.editing {
+		line("Filter> "+string(b.input)+"_", "1")
+	} else {
+		line("Filter: "+dryRunValue(b.filter, "none, all the entries"), "1")
+	}
+
+	for i := b.offset; i < b.offset+b.listHeight(); i++ {
+		if i >= len(b.visible) {
+			line("", "")
+			continue
+		}
+		entry := b.entries[b.visible[i]]
+		sgr := severityColors[entry.Severity]
+		if i == b.cursor {
+			sgr = "7"
+			if !color {
+				line("> "+renderText(entry, false), "")
+				continue
+			}
+		}
+		line("  "+renderText(entry, false), sgr)
+	}
+
+	toggles := make([]string, len(browseSeverities))
+	for i, sev := range browseSeverities {
+		state := "on"
+		if b.hidden[sev.key] {
+			state = "off"
+		}
+		toggles[i] = fmt.Sprintf("[%s]%s %s", sev.key, sev.name[1:], state)
+	}
+	position := "0/0"
+	if len(b.visible) > 0 {
+		position = fmt.Sprintf("%d/%d", b.cursor+1, len(b.visible))
+	}
+	line(fmt.Sprintf("-- %s -- %s -- / filter, q quit -- %s", position, strings.Join(toggles, " "), b.status), "7")
+
+	var detail []string
+	if entry := b.selected(); entry != nil {
+		data, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		detail = strings.Split(string(data), "\n")
+	}
+	b.detailOffset = max(min(b.detailOffset, len(detail)-1), 0)
+	for i := range b.height - b.listHeight() - 2 {
+		text := ""
+		if b.detailOffset+i < len(detail) {
+			text = detail[b.detailOffset+i]
+		}
+		if i < b.height-b.listHeight()-3 {
+			line(text, "")
+		} else {
+			// The last line ends without a newline, which would scroll the screen.
+			s.WriteString(fitWidth(text, b.width))
+		}
+	}
+
+	_, err := io.WriteString(w, s.String())
+	return err
+}
+
+// fitWidth cuts a line to the width of the screen, without control characters
+func fitWidth(text string, width int) string {
+	runes := []rune(strings.Map(func(r rune) rune {
+		if r < ' ' {
+			return ' '
+		}
+		return r
+	}, text))
+	if len(runes) > width {
+		runes = runes[:width]
+	}
+	return string(runes)
+}
+
+// browseKeySequences names the escape sequences of the special keys
+var browseKeySequences = map[string]string{
+	"\x1b[A":  "up",
+	"\x1b[B":  "down",
+	"\x1bOA":  "up",
+	"\x1bOB":  "down",
+	"\x1b[5~": "pgup",
+	"\x1b[6~": "pgdown",
+	"\x1b[H":  "home",
+	"\x1b[F":  "end",
+	"\x1b[1~": "home",
+	"\x1b[4~": "end",
+}
+
+// browseKeys decodes a read from the terminal in raw mode into key names: the characters
+// themselves, or up, down, pgup, pgdown, home, end, enter, backspace, esc and ctrl-c
+func browseKeys(buf []byte) []string {
+	var keys []string
+	s := string(buf)
+	for len(s) > 0 {
+		if s[0] == '\x1b' {
+			matched := false
+			for seq, key := range browseKeySequences {
+				if strings.HasPrefix(s, seq) {
+					keys = append(keys, key)
+					s = s[len(seq):]
+					matched = true
+					break
+				}
+			}
+			if matched {
+				continue
+			}
+			if len(s) > 2 && (s[1] == '[' || s[1] == 'O') {
+				// An unknown sequence, skipped up to its final byte.
+				if end := strings.IndexFunc(s[2:], func(r rune) bool { return r >= '@' && r <= '~' }); end >= 0 {
+					s = s[2+end+1:]
+					continue
+				}
+			}
+			keys = append(keys, "esc")
+			s = s[1:]
+			continue
+		}
+		r := []rune(s)[0]
+		switch r {
+		case '\r', '\n':
+			keys = append(keys, "enter")
+		case 0x7f, 0x08:
+			keys = append(keys, "backspace")
+		case 0x03:
+			keys = append(keys, "ctrl-c")
+		default:
+			if r >= ' ' {
+				keys = append(keys, string(r))
+			}
+		}
+		s = s[len(string(r)):]
+	}
+	return keys
+}
+
+// statusWriter keeps the last log message, shown in the status line instead of garbling the screen
+type statusWriter struct {
+	mu   sync.Mutex
+	last string
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.last = strings.TrimSpace(string(p))
+	return len(p), nil
+}
+
+func (w *statusWriter) take() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	last := w.last
+	w.last = ""
+	return last
+}
+
+// runBrowser drives the browser on the terminal until quit
+func runBrowser(ctx context.Context, b *browser, fetch func(filter string) ([]*loggingpb.LogEntry, error), color bool) error {
+	fd := int(os.Stdin.Fd())
+	saved, err := term.MakeRaw(fd)
+	if err != nil {
+		return fmt.Errorf("setting the terminal in raw mode: %w", err)
+	}
+	// Alternate screen, without cursor.
+	fmt.Fprint(os.Stdout, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(os.Stdout, "\x1b[?25h\x1b[?1049l")
+		term.Restore(fd, saved)
+	}()
+
+	logs := &statusWriter{}
+	previous := log.Writer()
+	log.SetOutput(logs)
+	defer log.SetOutput(previous)
+
+	keys := make(chan []byte)
+	go func() {
+		for {
+			buf := make([]byte, 64)
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- buf[:n]
+		}
+	}()
+
+	for {
+		if width, height, err := term.GetSize(fd); err == nil {
+			b.width, b.height = width, height
+			b.scroll()
+		}
+		if err := b.render(os.Stdout, color); err != nil {
+			return err
+		}
+
+		var buf []byte
+		select {
+		case <-ctx.Done():
+			return nil
+		case buf = <-keys:
+		}
+		for _, key := range browseKeys(buf) {
+			switch b.handleKey(key) {
+			case browseQuit:
+				return nil
+			case browseFetch:
+				b.status = "Fetching..."
+				b.render(os.Stdout, color)
+				started := time.Now()
+				entries, err := fetch(b.filter)
+				if err != nil {
+					b.status = "Error: " + err.Error()
+					continue
+				}
+				b.setEntries(entries)
+				b.status += fmt.Sprintf(" in %s", time.Since(started).Round(time.Millisecond))
+			}
+		}
+		if last := logs.take(); last != "" {
+			b.status = last
+		}
+	}
+}
+
+func init() {
+	addFilterFlags(browseCmd)
+	browseCmd.Flags().Bool("no-color", false, "disable colors (also disabled by the NO_COLOR env var)")
+	browseCmd.Flags().Int("limit", 1000, "fetch at most this many entries, the newest")
+
+	rootCmd.AddCommand(browseCmd)
+}
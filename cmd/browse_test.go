@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+func TestBrowseKeys(t *testing.T) {
+	keys := browseKeys([]byte("j\x1b[A\x1b[6~\x1b\r\x7f\x03é\x1b[1;5C/"))
+	expected := []string{"j", "up", "pgdown", "esc", "enter", "backspace", "ctrl-c", "é", "/"}
+	if !slices.Equal(keys, expected) {
+		t.Errorf("browseKeys() = %q, want %q", keys, expected)
+	}
+}
+
+func TestBrowser(t *testing.T) {
+	var entries []*loggingpb.LogEntry
+	for i, severity := range []logtypepb.LogSeverity{
+		logtypepb.LogSeverity_ERROR,
+		logtypepb.LogSeverity_INFO,
+		logtypepb.LogSeverity_DEBUG,
+		logtypepb.LogSeverity_WARNING,
+		logtypepb.LogSeverity_CRITICAL,
+	} {
+		entries = append(entries, &loggingpb.LogEntry{
+			InsertId: string(rune('a' + i)),
+			LogName:  "projects/p/logs/app",
+			Severity: severity,
+			Payload:  &loggingpb.LogEntry_TextPayload{TextPayload: "message " + string(rune('a'+i))},
+		})
+	}
+	b := newBrowser("", entries)
+	b.width, b.height = 60, 12
+
+	for _, key := range []string{"j", "j", "j"} {
+		b.handleKey(key)
+	}
+	if b.selected().InsertId != "d" {
+		t.Errorf("selected() after 3 downs = %s, want d", b.selected().InsertId)
+	}
+
+	// Hiding the info and debug entries keeps the selection.
+	b.handleKey("i")
+	b.handleKey("d")
+	if len(b.visible) != 3 || b.selected().InsertId != "d" {
+		t.Errorf("after hiding info and debug: %d visible, selected %s, want 3 and d", len(b.visible), b.selected().InsertId)
+	}
+	b.handleKey("G")
+	if b.selected().InsertId != "e" {
+		t.Errorf("selected() after G = %s, want e", b.selected().InsertId)
+	}
+
+	var screen strings.Builder
+	if err := b.render(&screen, false); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(screen.String(), "\r\n")
+	if len(lines) != b.height {
+		t.Errorf("render() drew %d lines, want %d", len(lines), b.height)
+	}
+	if !strings.HasPrefix(lines[3], "> ") || !strings.Contains(lines[3], "message e") {
+		t.Errorf("render() selected line = %q, want the last entry", lines[3])
+	}
+	// protojson randomizes the spaces after the colons.
+	if detail := strings.Join(lines[7:], "\n"); !strings.Contains(detail, `"insertId":`) || !strings.Contains(detail, `"e"`) {
+		t.Errorf("render() misses the JSON of the selected entry:\n%s", screen.String())
+	}
+
+	b.handleKey("/")
+	for _, key := range []string{"s", "e", "v", "="} {
+		b.handleKey(key)
+	}
+	if action := b.handleKey("enter"); action != browseNone || !b.editing || !strings.HasPrefix(b.status, "Invalid filter") {
+		t.Errorf("enter with an invalid filter = %v, editing %v, status %q", action, b.editing, b.status)
+	}
+	for _, key := range []string{"backspace", ">", "=", "E", "R", "R", "O", "R"} {
+		b.handleKey(key)
+	}
+	if action := b.handleKey("enter"); action != browseFetch || b.filter != "sev>=ERROR" {
+		t.Errorf("enter with a valid filter = %v, filter %q, want a fetch of sev>=ERROR", action, b.filter)
+	}
+
+	b.setEntries(entries[:1])
+	if b.selected().InsertId != "a" || b.cursor != 0 {
+		t.Errorf("setEntries() selected %s at %d, want a at 0", b.selected().InsertId, b.cursor)
+	}
+	if b.handleKey("q") != browseQuit {
+		t.Error("handleKey(q) did not quit")
+	}
+}
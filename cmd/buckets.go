@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+)
+
+var bucketsCmd = &cobra.Command{
+	Use:   "buckets",
+	Short: "Manage log buckets",
+	Long:  `Manage log buckets`,
+}
+
+var bucketsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List log buckets",
+	Long: `List the log buckets of a location as JSON lines.
+
+By default the buckets of all locations are listed.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		location := cmd.Flag("location").Value.String()
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		cobra.CheckErr(printAll(client.Buckets(ctx, location).Next))
+	},
+}
+
+var bucketsCreateCmd = &cobra.Command{
+	Use:   "create BUCKET_ID",
+	Short: "Create a log bucket",
+	Long:  `Create a log bucket`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		location := cmd.Flag("location").Value.String()
+
+		bucket := &loggingpb.LogBucket{}
+		_, err := bucketFromFlags(cmd, bucket)
+		cobra.CheckErr(err)
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		created, err := client.CreateBucket(ctx, location, args[0], bucket)
+		cobra.CheckErr(err)
+		cobra.CheckErr(printJSON(created))
+	},
+}
+
+var bucketsUpdateCmd = &cobra.Command{
+	Use:   "update BUCKET_ID",
+	Short: "Update a log bucket",
+	Long: `Update a log bucket.
+
+Only the fields whose flags are explicitly given are changed.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		location := cmd.Flag("location").Value.String()
+
+		bucket := &loggingpb.LogBucket{}
+		paths, err := bucketFromFlags(cmd, bucket)
+		cobra.CheckErr(err)
+		if len(paths) == 0 {
+			cobra.CheckErr(fmt.Errorf("nothing to update, specify at least one of --description, --retention-days"))
+		}
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		updated, err := client.UpdateBucket(ctx, location, args[0], bucket, paths)
+		cobra.CheckErr(err)
+		cobra.CheckErr(printJSON(updated))
+	},
+}
+
+var bucketsDeleteCmd = &cobra.Command{
+	Use:   "delete BUCKET_ID",
+	Short: "Delete a log bucket",
+	Long: `Delete a log bucket.
+
+The bucket enters a pending deletion state and is purged after 7 days.
+Unless --yes is given, the deletion must be confirmed interactively.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		location := cmd.Flag("location").Value.String()
+
+		yes, err := cmd.Flags().GetBool("yes")
+		cobra.CheckErr(err)
+
+		if !yes && !confirm(fmt.Sprintf("Delete bucket %s in location %s of project %s?", args[0], location, projectId)) {
+			log.Println("Aborted")
+			return
+		}
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		cobra.CheckErr(client.DeleteBucket(ctx, location, args[0]))
+		log.Printf("Deleted bucket %s", args[0])
+	},
+}
+
+// bucketFromFlags fills the bucket with the explicitly set flags and returns the matching update mask paths
+func bucketFromFlags(cmd *cobra.Command, bucket *loggingpb.LogBucket) ([]string, error) {
+	var paths []string
+
+	if cmd.Flags().Changed("description") {
+		description, err := cmd.Flags().GetString("description")
+		if err != nil {
+			return nil, err
+		}
+		bucket.Description = description
+		paths = append(paths, "description")
+	}
+
+	if cmd.Flags().Changed("retention-days") {
+		days, err := cmd.Flags().GetInt32("retention-days")
+		if err != nil {
+			return nil, err
+		}
+		if days <= 0 {
+			return nil, fmt.Errorf("invalid --retention-days %d", days)
+		}
+		bucket.RetentionDays = days
+		paths = append(paths, "retention_days")
+	}
+
+	return paths, nil
+}
+
+func init() {
+	bucketsListCmd.Flags().String("location", "-", "location of the buckets, \"-\" for all locations")
+
+	for _, c := range []*cobra.Command{bucketsCreateCmd, bucketsUpdateCmd, bucketsDeleteCmd} {
+		c.Flags().String("location", "global", "location of the bucket")
+	}
+	for _, c := range []*cobra.Command{bucketsCreateCmd, bucketsUpdateCmd} {
+		c.Flags().String("description", "", "description of the bucket")
+		c.Flags().Int32("retention-days", 0, "number of days entries are retained")
+	}
+	bucketsDeleteCmd.Flags().Bool("yes", false, "skip the confirmation prompt")
+
+	bucketsCmd.AddCommand(bucketsListCmd, bucketsCreateCmd, bucketsUpdateCmd, bucketsDeleteCmd)
+	rootCmd.AddCommand(bucketsCmd)
+}
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// stateVersion is the version of the JSON blob written by state export
+const stateVersion = 1
+
+// checkpoint is the persistent state of a watch run named with --checkpoint
+type checkpoint struct {
+	// Filter is the filter of the run, a restarted run with another one gets a warning.
+	Filter string `json:"filter"`
+	// WindowField is the --window-field of the run, timestamp when empty.
+	WindowField string `json:"windowField,omitempty"`
+	// Watermark is the timestamp of the newest entry processed, Seen the keys of the entries processed at it.
+	Watermark time.Time `json:"watermark"`
+	Seen      []string  `json:"seen,omitempty"`
+	// Notifications is the state of the fingerprints of --notify.
+	Notifications map[string]*notifyGroup `json:"notifications,omitempty"`
+	LastRun       time.Time               `json:"lastRun"`
+}
+
+// stateExport is the JSON blob of state export and state import
+type stateExport struct {
+	Version     int                    `json:"version"`
+	ExportedAt  time.Time              `json:"exportedAt"`
+	Checkpoints map[string]*checkpoint `json:"checkpoints"`
+}
+
+var stateCmd = &cobra.Command{
+	Use:   "state",
+	Short: "Export and import the checkpoints of watch runs",
+	Long: `Export and import the checkpoints written by --watch --checkpoint NAME, with
+the watermark of the entries already processed and the state of --notify,
+as a single JSON blob. Importing the export of another host lets a forwarding
+daemon move there without processing the same entries again.
+
+The checkpoints are stored in the grapple directory of the user config dir.`,
+}
+
+var stateExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write all the checkpoints as a single JSON blob to stdout",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		dir, err := checkpointsDir()
+		cobra.CheckErr(err)
+		export, err := exportState(dir, time.Now().UTC())
+		cobra.CheckErr(err)
+		data, err := json.MarshalIndent(export, "", "  ")
+		cobra.CheckErr(err)
+		_, err = stdout.Write(append(data, '\n'))
+		cobra.CheckErr(err)
+	},
+}
+
+var stateImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Write the checkpoints of a JSON blob from state export (- for stdin)",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, err := cmd.Flags().GetBool("force")
+		cobra.CheckErr(err)
+
+		var r io.Reader = os.Stdin
+		if args[0] != "-" {
+			f, err := os.Open(args[0])
+			cobra.CheckErr(err)
+			defer f.Close()
+			r = f
+		}
+		var export stateExport
+		cobra.CheckErr(json.NewDecoder(r).Decode(&export))
+
+		dir, err := checkpointsDir()
+		cobra.CheckErr(err)
+		names, err := importState(dir, &export, force)
+		cobra.CheckErr(err)
+		log.Printf("Imported %d checkpoints: %s", len(names), strings.Join(names, ", "))
+	},
+}
+
+// checkpointsDir returns the directory of the checkpoint files
+func checkpointsDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, cliName, "checkpoints"), nil
+}
+
+func checkpointPath(dir, name string) string {
+	return filepath.Join(dir, name+".json")
+}
+
+// loadCheckpoint returns the named checkpoint, nil when there is none
+func loadCheckpoint(dir, name string) (*checkpoint, error) {
+	path := checkpointPath(dir, name)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var c checkpoint
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("corrupted checkpoint %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// saveCheckpoint replaces the named checkpoint atomically, so that a crash never leaves a partial one
+func saveCheckpoint(dir, name string, c *checkpoint) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := checkpointPath(dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// exportState reads all the checkpoints of the directory
+func exportState(dir string, now time.Time) (*stateExport, error) {
+	export := &stateExport{Version: stateVersion, ExportedAt: now, Checkpoints: map[string]*checkpoint{}}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		c, err := loadCheckpoint(dir, name)
+		if err != nil {
+			return nil, err
+		}
+		export.Checkpoints[name] = c
+	}
+	return export, nil
+}
+
+// importState writes the checkpoints of an export to the directory, refusing to replace
+// existing ones unless forced, and returns their sorted names
+func importState(dir string, export *stateExport, force bool) ([]string, error) {
+	if export.Version != stateVersion {
+		return nil, fmt.Errorf("unsupported state version %d, expected %d", export.Version, stateVersion)
+	}
+	var names, existing []string
+	for name := range export.Checkpoints {
+		if !savedQueryNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid checkpoint name %q", name)
+		}
+		names = append(names, name)
+		if _, err := os.Stat(checkpointPath(dir, name)); err == nil {
+			existing = append(existing, name)
+		}
+	}
+	slices.Sort(names)
+	slices.Sort(existing)
+	if len(existing) > 0 && !force {
+		return nil, fmt.Errorf("checkpoints %s already exist, use --force to replace them", strings.Join(existing, ", "))
+	}
+	for _, name := range names {
+		if err := saveCheckpoint(dir, name, export.Checkpoints[name]); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+func init() {
+	stateImportCmd.Flags().Bool("force", false, "replace the checkpoints that already exist")
+
+	stateCmd.AddCommand(stateExportCmd, stateImportCmd)
+	rootCmd.AddCommand(stateCmd)
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStateExportImport(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	source := t.TempDir()
+	prod := &checkpoint{
+		Filter:    "severity>=ERROR",
+		Watermark: now,
+		Seen:      []string{"projects/p/logs/app\x00abc"},
+		Notifications: map[string]*notifyGroup{
+			"0123456789ab": {LogName: "projects/p/logs/app", Severity: "ERROR", Template: "timeout after <num>s", SentAt: now, Pending: 3},
+		},
+		LastRun: now,
+	}
+	if err := saveCheckpoint(source, "prod", prod); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveCheckpoint(source, "staging", &checkpoint{Watermark: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := exportState(source, now)
+	if err != nil {
+		t.Fatalf("exportState() unexpected error: %v", err)
+	}
+	if len(export.Checkpoints) != 2 || !reflect.DeepEqual(export.Checkpoints["prod"], prod) {
+		t.Errorf("exportState() = %+v, want the prod and staging checkpoints", export.Checkpoints)
+	}
+
+	target := t.TempDir()
+	if err := saveCheckpoint(target, "staging", &checkpoint{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := importState(target, export, false); err == nil {
+		t.Errorf("importState() expected error for an existing checkpoint, got nil")
+	}
+	names, err := importState(target, export, true)
+	if err != nil {
+		t.Fatalf("importState() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(names, []string{"prod", "staging"}) {
+		t.Errorf("importState() = %v, want [prod staging]", names)
+	}
+	imported, err := loadCheckpoint(target, "prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(imported, prod) {
+		t.Errorf("imported checkpoint = %+v, want %+v", imported, prod)
+	}
+
+	if missing, err := loadCheckpoint(target, "dev"); missing != nil || err != nil {
+		t.Errorf("loadCheckpoint(dev) = %v, %v, want nil, nil", missing, err)
+	}
+	export.Version = 2
+	if _, err := importState(target, export, true); err == nil {
+		t.Errorf("importState() expected error for an unsupported version, got nil")
+	}
+}
+
+func TestNotifierRestore(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var sent []notification
+	n := newNotifier(5*time.Minute, func(msg notification) error {
+		sent = append(sent, msg)
+		return nil
+	})
+	n.restore(map[string]*notifyGroup{
+		"0123456789ab": {LogName: "projects/p/logs/app", Severity: "ERROR", Template: "disk full", SentAt: now, Pending: 2},
+	})
+	if snapshot := n.snapshot(); snapshot["0123456789ab"].Pending != 2 {
+		t.Errorf("snapshot() = %+v, want the restored state", snapshot)
+	}
+
+	n.flush(now.Add(5*time.Minute), false)
+	if len(sent) != 1 || sent[0].Count != 2 || sent[0].Fingerprint != "0123456789ab" {
+		t.Errorf("notifications = %+v, want the restored count", sent)
+	}
+}
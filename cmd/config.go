@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// currentConfigVersion is the config schema version written by this release
+const currentConfigVersion = 1
+
+const configVersionKey = "configVersion"
+
+// knownConfigKeys lists the top-level keys accepted in the config file
+var knownConfigKeys = []string{
+	configVersionKey,
+	"project",
+	"order",
+	"format",
+	"stats",
+	"timezone",
+	"endpoint",
+	"transport",
+	"aliases",
+	"alwaysFilter",
+	"jsonProtoNames",
+	"jsonEmitDefaults",
+	"jsonEnumNumbers",
+	notifyKey,
+	presetsKey,
+	profileKey,
+	profilesKey,
+}
+
+// configMigrations[v] upgrades a config document from version v to v+1 in place
+var configMigrations = []func(root *yaml.Node) error{
+	// 0 -> 1: the schema is unchanged, the version is simply made explicit.
+	func(root *yaml.Node) error { return nil },
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the config file",
+	Long:  `Manage the config file`,
+}
+
+var configMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the config file to the current schema",
+	Long: fmt.Sprintf(`Upgrade the config file to the current schema (version %d).
+
+The file is rewritten in place, preserving comments, and the original is kept
+alongside it with a .bak extension.`, currentConfigVersion),
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path := viper.ConfigFileUsed()
+		if path == "" {
+			cobra.CheckErr(errors.New("no config file found, nothing to migrate"))
+		}
+
+		original, err := os.ReadFile(path)
+		cobra.CheckErr(err)
+
+		migrated, from, err := migrateConfig(original)
+		cobra.CheckErr(err)
+		if from == currentConfigVersion {
+			log.Printf("Config file is already at version %d", currentConfigVersion)
+			return
+		}
+
+		cobra.CheckErr(os.WriteFile(path+".bak", original, 0o600))
+		cobra.CheckErr(os.WriteFile(path, migrated, 0o600))
+		log.Printf("Migrated %s from version %d to %d (backup in %s.bak)", path, from, currentConfigVersion, path)
+	},
+}
+
+// validateConfig checks the keys and schema version of the loaded config file,
+// printing warnings for recoverable issues
+func validateConfig(path string) error {
+	raw := viper.New()
+	raw.SetConfigFile(path)
+	if err := raw.ReadInConfig(); err != nil {
+		return err
+	}
+	return checkConfig(raw, path)
+}
+
+// checkConfig checks the keys and schema version of the settings read from the config file,
+// printing warnings for recoverable issues
+func checkConfig(raw *viper.Viper, path string) error {
+	version := raw.GetInt(configVersionKey)
+	if version > currentConfigVersion {
+		return fmt.Errorf("config file %s has version %d, but this release only understands up to version %d, please upgrade %v", path, version, currentConfigVersion, cliName)
+	}
+	if version < currentConfigVersion {
+		log.Printf("Warning: config file %s uses schema version %d, run `%v config migrate` to upgrade it to version %d", path, version, cliName, currentConfigVersion)
+	}
+
+	for _, key := range unknownConfigKeys(raw.AllKeys()) {
+		if suggestion := suggestConfigKey(key); suggestion != "" {
+			log.Printf("Warning: unknown config key %q, did you mean %q?", key, suggestion)
+		} else {
+			log.Printf("Warning: unknown config key %q", key)
+		}
+	}
+	return nil
+}
+
+// unknownConfigKeys returns the sorted, deduplicated top-level keys not in knownConfigKeys.
+// Viper flattens nested keys with dots and lowercases them.
+func unknownConfigKeys(keys []string) []string {
+	known := map[string]bool{}
+	for _, k := range knownConfigKeys {
+		known[strings.ToLower(k)] = true
+	}
+
+	seen := map[string]bool{}
+	var unknown []string
+	for _, k := range keys {
+		top, _, _ := strings.Cut(k, ".")
+		if known[top] || seen[top] {
+			continue
+		}
+		seen[top] = true
+		unknown = append(unknown, top)
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// suggestConfigKey returns the known key closest to the given one, or "" when none is close enough
+func suggestConfigKey(key string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range knownConfigKeys {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(candidate)); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
+
+// migrateConfig upgrades a YAML config document to the current schema version,
+// returning the rewritten document and the version it started from
+func migrateConfig(data []byte) ([]byte, int, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, 0, err
+	}
+
+	var root *yaml.Node
+	if len(doc.Content) == 0 {
+		root = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}
+	} else {
+		root = doc.Content[0]
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, 0, errors.New("config file must contain a mapping at the top level")
+	}
+
+	from := 0
+	versionNode := mappingValue(root, configVersionKey)
+	if versionNode != nil {
+		if err := versionNode.Decode(&from); err != nil {
+			return nil, 0, fmt.Errorf("invalid %s: %w", configVersionKey, err)
+		}
+	}
+	if from > currentConfigVersion {
+		return nil, from, fmt.Errorf("config version %d is newer than the supported version %d", from, currentConfigVersion)
+	}
+	if from == currentConfigVersion {
+		return data, from, nil
+	}
+
+	for v := from; v < currentConfigVersion; v++ {
+		if err := configMigrations[v](root); err != nil {
+			return nil, from, fmt.Errorf("migrating from version %d: %w", v, err)
+		}
+	}
+
+	version := fmt.Sprint(currentConfigVersion)
+	if versionNode != nil {
+		versionNode.SetString(version)
+		versionNode.Tag = "!!int"
+	} else {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: configVersionKey}
+		// Keep the leading comment of the file at the top.
+		if len(root.Content) > 0 {
+			keyNode.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+		}
+		root.Content = append([]*yaml.Node{
+			keyNode,
+			{Kind: yaml.ScalarNode, Tag: "!!int", Value: version},
+		}, root.Content...)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, from, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, from, err
+	}
+	return buf.Bytes(), from, nil
+}
+
+// mappingValue returns the value node of a key in a YAML mapping, matching the key case-insensitively like viper does
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if strings.EqualFold(mapping.Content[i].Value, key) {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func init() {
+	configCmd.AddCommand(configMigrateCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSuggestConfigKey(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"projet", "project"},
+		{"ordr", "order"},
+		{"configversion", "configVersion"},
+		{"completely-unrelated", ""},
+	}
+
+	for _, c := range cases {
+		if got := suggestConfigKey(c.input); got != c.expected {
+			t.Errorf("suggestConfigKey(%q) = %q, want %q", c.input, got, c.expected)
+		}
+	}
+}
+
+func TestUnknownConfigKeys(t *testing.T) {
+	got := unknownConfigKeys([]string{"project", "projet", "nested.a", "nested.b", "configversion"})
+	expected := []string{"nested", "projet"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("unknownConfigKeys() = %v, want %v", got, expected)
+	}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	input := "# my config\nproject: foo # inline\norder: asc\n"
+
+	migrated, from, err := migrateConfig([]byte(input))
+	if err != nil {
+		t.Fatalf("migrateConfig() unexpected error: %v", err)
+	}
+	if from != 0 {
+		t.Errorf("migrateConfig() from = %d, want 0", from)
+	}
+	expected := "# my config\nconfigVersion: 1\nproject: foo # inline\norder: asc\n"
+	if string(migrated) != expected {
+		t.Errorf("migrateConfig() = %q, want %q", migrated, expected)
+	}
+
+	again, from, err := migrateConfig(migrated)
+	if err != nil || from != currentConfigVersion || string(again) != string(migrated) {
+		t.Errorf("migrateConfig() on a current config = (%q, %d, %v), want it unchanged", again, from, err)
+	}
+
+	if _, _, err := migrateConfig([]byte("configVersion: 99\n")); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Errorf("migrateConfig() on a future version error = %v, want a newer-version error", err)
+	}
+}
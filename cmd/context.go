@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	profilesKey = "profiles"
+	profileKey  = "profile"
+)
+
+// profileKeys lists the settings a profile can override, the bool ones in profileBoolKeys
+var (
+	profileKeys     = []string{"project", "alwaysFilter", "format", "order", "stats", "timezone", "endpoint", "transport"}
+	profileBoolKeys = []string{"stats"}
+)
+
+var contextCmd = &cobra.Command{
+	Use:   "context",
+	Short: "Manage the named profiles of the config file",
+	Long: fmt.Sprintf(`Manage named profiles, like prod and staging, grouping per-environment
+settings in the config file: %s.
+
+The settings of the active profile override the top-level ones of the config
+file, and are overridden by environment variables and flags. The active
+profile is set with "context use" or, for a single command, with --profile.`, strings.Join(profileKeys, ", ")),
+}
+
+var contextListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the profiles, marking the active one",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		active := viper.GetString(profileKey)
+		for _, name := range profileNames(viper.GetViper()) {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+			project := viper.GetString(profilesKey + "." + name + ".project")
+			if project == "" {
+				project = "-"
+			}
+			fmt.Fprintf(stdout, "%s %s\t%s\n", marker, name, project)
+		}
+	},
+}
+
+var contextUseCmd = &cobra.Command{
+	Use:   "use PROFILE",
+	Short: "Make a profile the active one",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		if !slices.Contains(profileNames(viper.GetViper()), name) {
+			cobra.CheckErr(fmt.Errorf("unknown profile %q, create it with `%v context set %s KEY=VALUE`", name, cliName, name))
+		}
+		path, err := editConfigFile(func(root *yaml.Node) error {
+			return setConfigValue(root, []string{profileKey}, name)
+		})
+		cobra.CheckErr(err)
+		log.Printf("Switched to profile %q in %s", name, path)
+	},
+}
+
+var contextSetCmd = &cobra.Command{
+	Use:   "set PROFILE KEY=VALUE...",
+	Short: "Set settings of a profile, creating it if needed",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		path, err := editConfigFile(func(root *yaml.Node) error {
+			for _, setting := range args[1:] {
+				key, value, ok := strings.Cut(setting, "=")
+				if !ok {
+					return fmt.Errorf("invalid setting %q, expected KEY=VALUE", setting)
+				}
+				i := slices.IndexFunc(profileKeys, func(k string) bool { return strings.EqualFold(k, key) })
+				if i < 0 {
+					return fmt.Errorf("unknown profile setting %q, valid keys: %s", key, strings.Join(profileKeys, ", "))
+				}
+				if err := setConfigValue(root, []string{profilesKey, name, profileKeys[i]}, value); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		cobra.CheckErr(err)
+		log.Printf("Updated profile %q in %s", name, path)
+	},
+}
+
+// profileNames returns the sorted names of the profiles of the config file
+func profileNames(v *viper.Viper) []string {
+	var names []string
+	for name := range v.GetStringMap(profilesKey) {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// applyProfile merges the settings of the active profile, if any, over the top-level ones of the config file
+func applyProfile(v *viper.Viper) error {
+	name := v.GetString(profileKey)
+	if name == "" {
+		return nil
+	}
+	if names := profileNames(v); !slices.Contains(names, name) {
+		return fmt.Errorf("unknown profile %q, valid values: %s", name, strings.Join(names, ", "))
+	}
+	return v.MergeConfigMap(v.GetStringMap(profilesKey + "." + name))
+}
+
+// editConfigFile applies edit to the YAML document of the config file, creating the file in the home
+// directory if there is none, and returns its path
+func editConfigFile(edit func(root *yaml.Node) error) (string, error) {
+	path := viper.ConfigFileUsed()
+	var data []byte
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, fmt.Sprintf(".%v.yaml", cliName))
+		data = []byte(fmt.Sprintf("%s: %d\n", configVersionKey, currentConfigVersion))
+	} else {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return "", err
+		}
+	}
+
+	edited, err := editConfig(data, edit)
+	if err != nil {
+		return "", err
+	}
+	return path, os.WriteFile(path, edited, 0o600)
+}
+
+// editConfig applies edit to the top-level mapping of a YAML config document, preserving comments
+func editConfig(data []byte, edit func(root *yaml.Node) error) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("config file must contain a mapping at the top level")
+	}
+	if err := edit(root); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// setConfigValue sets the value at path in nested YAML mappings, creating the missing ones.
+// The value is a string, or a bool for the keys in profileBoolKeys.
+func setConfigValue(mapping *yaml.Node, path []string, value string) error {
+	for i, key := range path {
+		node := mappingValue(mapping, key)
+		if i == len(path)-1 {
+			var v any = value
+			if slices.Contains(profileBoolKeys, key) {
+				switch strings.ToLower(value) {
+				case "true":
+					v = true
+				case "false":
+					v = false
+				default:
+					return fmt.Errorf("invalid %s %q, expected true or false", key, value)
+				}
+			}
+			if node == nil {
+				node = &yaml.Node{}
+				mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, node)
+			}
+			return node.Encode(v)
+		}
+		if node == nil {
+			node = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, node)
+		}
+		if node.Kind != yaml.MappingNode {
+			return fmt.Errorf("%s is not a mapping", strings.Join(path[:i+1], "."))
+		}
+		mapping = node
+	}
+	return nil
+}
+
+func init() {
+	contextCmd.AddCommand(contextListCmd, contextUseCmd, contextSetCmd)
+	rootCmd.AddCommand(contextCmd)
+}
@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+func TestEditConfigSetsNestedValues(t *testing.T) {
+	input := "# my config\nproject: foo # inline\nprofiles:\n  prod:\n    project: bar\n"
+
+	edited, err := editConfig([]byte(input), func(root *yaml.Node) error {
+		if err := setConfigValue(root, []string{profileKey}, "prod"); err != nil {
+			return err
+		}
+		if err := setConfigValue(root, []string{profilesKey, "prod", "project"}, "baz"); err != nil {
+			return err
+		}
+		return setConfigValue(root, []string{profilesKey, "staging", "stats"}, "true")
+	})
+	if err != nil {
+		t.Fatalf("editConfig() unexpected error: %v", err)
+	}
+
+	expected := "# my config\nproject: foo # inline\nprofiles:\n  prod:\n    project: baz\n  staging:\n    stats: true\nprofile: prod\n"
+	if string(edited) != expected {
+		t.Errorf("editConfig() = %q, want %q", edited, expected)
+	}
+}
+
+func TestSetConfigValueErrors(t *testing.T) {
+	cases := []struct {
+		input string
+		path  []string
+		value string
+	}{
+		{"profiles: prod\n", []string{profilesKey, "prod", "project"}, "foo"},
+		{"{}\n", []string{profilesKey, "prod", "stats"}, "maybe"},
+	}
+
+	for _, c := range cases {
+		_, err := editConfig([]byte(c.input), func(root *yaml.Node) error {
+			return setConfigValue(root, c.path, c.value)
+		})
+		if err == nil {
+			t.Errorf("setConfigValue(%q, %v, %q) expected error, got nil", c.input, c.path, c.value)
+		}
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	config := "project: top\norder: asc\nprofiles:\n  prod:\n    project: prod-project\n    alwaysFilter: severity>=ERROR\n"
+	if err := v.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+
+	v.Set(profileKey, "staging")
+	if err := applyProfile(v); err == nil {
+		t.Errorf("applyProfile(v) expected error for an unknown profile, got nil")
+	}
+
+	v.Set(profileKey, "prod")
+	if err := applyProfile(v); err != nil {
+		t.Fatalf("applyProfile(v) unexpected error: %v", err)
+	}
+	if got := v.GetString("project"); got != "prod-project" {
+		t.Errorf("project = %q, want %q", got, "prod-project")
+	}
+	if got := v.GetString("alwaysFilter"); got != "severity>=ERROR" {
+		t.Errorf("alwaysFilter = %q, want %q", got, "severity>=ERROR")
+	}
+	if got := v.GetString("order"); got != "asc" {
+		t.Errorf("order = %q, want %q", got, "asc")
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+)
+
+var copyCmd = &cobra.Command{
+	Use:   "copy [filter]",
+	Short: "Copy log entries from a log bucket to Cloud Storage",
+	Long: `Copy the entries of a log bucket matching the optional filter to a
+destination such as "storage.googleapis.com/GCS_BUCKET".
+
+The copy runs as a long-running operation on Google's side: grapple polls it
+and reports the progress until it completes. Interrupting grapple (Ctrl+C)
+requests the cancellation of the operation, use --no-wait to start the copy
+and exit immediately instead.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+
+		sourceBucket := cmd.Flag("source-bucket").Value.String()
+		location := cmd.Flag("location").Value.String()
+		destination := cmd.Flag("destination").Value.String()
+		noWait, err := cmd.Flags().GetBool("no-wait")
+		cobra.CheckErr(err)
+		interval, err := cmd.Flags().GetDuration("poll-interval")
+		cobra.CheckErr(err)
+		if interval <= 0 {
+			cobra.CheckErr(errors.New("--poll-interval must be positive"))
+		}
+
+		filter := ""
+		if len(args) > 0 {
+			filter = expandProjectReferences(args[0], projectAliases())
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		source := expandProjectReferences(sourceBucket, projectAliases())
+		if !strings.Contains(source, "/") {
+			source = client.BucketPath(location, sourceBucket)
+		}
+
+		op, err := client.CopyLogEntries(ctx, source, filter, destination)
+		cobra.CheckErr(err)
+		log.Printf("Started copy operation %s", op.Name())
+
+		if noWait {
+			return
+		}
+
+		cobra.CheckErr(waitForCopy(ctx, op, interval))
+	},
+}
+
+// waitForCopy polls the copy operation until completion, reporting the progress
+// and requesting its cancellation when ctx is cancelled
+func waitForCopy(ctx context.Context, op *logadmin.CopyOperation, interval time.Duration) error {
+	// Polling continues after an interrupt to observe the cancellation, so it uses a separate context.
+	pollCtx := context.WithoutCancel(ctx)
+	cancelRequested := false
+	lastProgress := int32(-1)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := op.Poll(pollCtx)
+		if err != nil {
+			if cancelRequested {
+				return errors.New("copy operation cancelled")
+			}
+			return err
+		}
+
+		if meta, err := op.Metadata(); err == nil && meta != nil && meta.Progress != lastProgress {
+			lastProgress = meta.Progress
+			log.Printf("Progress: %d%% (%s)", meta.Progress, strings.TrimPrefix(meta.State.String(), "OPERATION_STATE_"))
+		}
+
+		if op.Done() {
+			if cancelRequested {
+				return errors.New("copy operation cancelled")
+			}
+			log.Printf("Copied %d entries", resp.GetLogEntriesCopiedCount())
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			if !cancelRequested {
+				cancelRequested = true
+				log.Println("Interrupted, cancelling the copy operation...")
+				if err := op.Cancel(pollCtx); err != nil {
+					return err
+				}
+			}
+			<-ticker.C
+		case <-ticker.C:
+		}
+	}
+}
+
+func init() {
+	copyCmd.Flags().String("source-bucket", "", "ID or full resource name of the log bucket to copy from")
+	copyCmd.Flags().String("location", "global", "location of --source-bucket")
+	copyCmd.Flags().String("destination", "", "destination of the copy, e.g. storage.googleapis.com/GCS_BUCKET")
+	copyCmd.Flags().Bool("no-wait", false, "start the copy and exit without waiting for it")
+	copyCmd.Flags().Duration("poll-interval", 5*time.Second, "interval between progress checks")
+
+	copyCmd.MarkFlagRequired("source-bucket")
+	copyCmd.MarkFlagRequired("destination")
+
+	rootCmd.AddCommand(copyCmd)
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+	"google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+const copyOperation = "projects/p/locations/global/operations/copy-1"
+
+// fakeCopy starts a copy operation, recording the request
+type fakeCopy struct {
+	loggingpb.UnimplementedConfigServiceV2Server
+	request *loggingpb.CopyLogEntriesRequest
+}
+
+func (f *fakeCopy) CopyLogEntries(ctx context.Context, req *loggingpb.CopyLogEntriesRequest) (*longrunningpb.Operation, error) {
+	f.request = req
+	return &longrunningpb.Operation{Name: copyOperation}, nil
+}
+
+// fakeOperations completes the copy operation at poll done, or never if done is 0, and stops it when cancelled
+type fakeOperations struct {
+	longrunningpb.UnimplementedOperationsServer
+	done      int
+	polls     int
+	cancelled []string
+}
+
+func (f *fakeOperations) GetOperation(ctx context.Context, req *longrunningpb.GetOperationRequest) (*longrunningpb.Operation, error) {
+	f.polls++
+	op := &longrunningpb.Operation{Name: req.Name}
+	switch {
+	case len(f.cancelled) > 0:
+		op.Done = true
+		op.Result = &longrunningpb.Operation_Error{Error: &status.Status{Code: int32(codes.Canceled), Message: "cancelled"}}
+	case f.polls == f.done:
+		op.Done = true
+		op.Result = &longrunningpb.Operation_Response{Response: mustAny(&loggingpb.CopyLogEntriesResponse{LogEntriesCopiedCount: 7})}
+	default:
+		op.Metadata = mustAny(&loggingpb.CopyLogEntriesMetadata{State: loggingpb.OperationState_OPERATION_STATE_RUNNING, Progress: int32(f.polls * 10)})
+	}
+	return op, nil
+}
+
+func (f *fakeOperations) CancelOperation(ctx context.Context, req *longrunningpb.CancelOperationRequest) (*emptypb.Empty, error) {
+	f.cancelled = append(f.cancelled, req.Name)
+	return &emptypb.Empty{}, nil
+}
+
+func mustAny(m proto.Message) *anypb.Any {
+	a, err := anypb.New(m)
+	if err != nil {
+		panic(err)
+	}
+	return a
+}
+
+func TestWaitForCopy(t *testing.T) {
+	copies := &fakeCopy{}
+	operations := &fakeOperations{done: 3}
+	client := newFakeClient(t, func(s *grpc.Server) {
+		loggingpb.RegisterConfigServiceV2Server(s, copies)
+		longrunningpb.RegisterOperationsServer(s, operations)
+	})
+
+	ctx := context.Background()
+	op, err := client.CopyLogEntries(ctx, client.BucketPath("global", "archive"), "severity>=ERROR", "storage.googleapis.com/my-bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if req := copies.request; req.Name != "projects/p/locations/global/buckets/archive" || req.Filter != "severity>=ERROR" || req.Destination != "storage.googleapis.com/my-bucket" {
+		t.Errorf("copy request %v, want the bucket, the filter and the destination", req)
+	}
+	if op.Name() != copyOperation {
+		t.Errorf("operation %s, want %s", op.Name(), copyOperation)
+	}
+
+	if err := waitForCopy(ctx, op, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if operations.polls != 3 || len(operations.cancelled) != 0 {
+		t.Errorf("waitForCopy() polled %d times and cancelled %v, want 3 polls until done", operations.polls, operations.cancelled)
+	}
+}
+
+func TestWaitForCopyCancel(t *testing.T) {
+	operations := &fakeOperations{}
+	client := newFakeClient(t, func(s *grpc.Server) {
+		loggingpb.RegisterConfigServiceV2Server(s, &fakeCopy{})
+		longrunningpb.RegisterOperationsServer(s, operations)
+	})
+	op, err := client.CopyLogEntries(context.Background(), client.BucketPath("global", "archive"), "", "storage.googleapis.com/my-bucket")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An interrupted wait requests the cancellation once, and keeps polling until the operation stops.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = waitForCopy(ctx, op, time.Millisecond)
+	if err == nil || err.Error() != "copy operation cancelled" {
+		t.Errorf("waitForCopy() = %v, want the cancellation", err)
+	}
+	if len(operations.cancelled) != 1 || operations.cancelled[0] != copyOperation || operations.polls != 2 {
+		t.Errorf("waitForCopy() cancelled %v after %d polls, want %s once, then a poll", operations.cancelled, operations.polls, copyOperation)
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// requestSummary describes the most recent request sent to the Logging API
+type requestSummary struct {
+	Filter    string
+	OrderBy   string
+	PageToken string
+	SentAt    time.Time
+}
+
+var lastRequest requestSummary
+
+// secretMarkers are the substrings identifying config keys whose values must not leak into crash reports
+var secretMarkers = []string{"token", "secret", "password", "credential", "key"}
+
+// recoverCrash turns a panic into a crash report on disk and exits with a non-zero status.
+// It must be invoked directly via defer.
+func recoverCrash() {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	path, err := writeCrashReport(r, debug.Stack())
+	if err != nil {
+		log.Printf("Error: unexpected crash: %v", r)
+		fatalf("Error: unable to write crash report: %v", err)
+	}
+	log.Printf("Error: unexpected crash: %v", r)
+	fatalf("A crash report was written to %s, please attach it when filing a bug", path)
+}
+
+// writeCrashReport dumps the panic value, stack, redacted config and last request into a temp file
+func writeCrashReport(r any, stack []byte) (string, error) {
+	f, err := os.CreateTemp("", fmt.Sprintf("%v-crash-*.txt", cliName))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v crash report (%s)\n\n", cliName, time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "Panic: %v\n\n", r)
+
+	b.WriteString("Effective config:\n")
+	settings := redactSettings(viper.AllSettings())
+	keys := make([]string, 0, len(settings))
+	for k := range settings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "  %s: %v\n", k, settings[k])
+	}
+
+	b.WriteString("\nLast request:\n")
+	if lastRequest.SentAt.IsZero() {
+		b.WriteString("  none\n")
+	} else {
+		fmt.Fprintf(&b, "  sent at: %s\n", lastRequest.SentAt.Format(time.RFC3339Nano))
+		fmt.Fprintf(&b, "  filter: %s\n", lastRequest.Filter)
+		fmt.Fprintf(&b, "  order by: %s\n", lastRequest.OrderBy)
+		fmt.Fprintf(&b, "  page token: %q\n", lastRequest.PageToken)
+	}
+
+	fmt.Fprintf(&b, "\nStack:\n%s", stack)
+
+	if _, err := f.WriteString(b.String()); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// redactSettings returns a copy of the settings with secret-looking values masked, descending into nested maps
+func redactSettings(settings map[string]any) map[string]any {
+	redacted := make(map[string]any, len(settings))
+	for k, v := range settings {
+		if nested, ok := v.(map[string]any); ok {
+			redacted[k] = redactSettings(nested)
+			continue
+		}
+		if isSecretKey(k) && v != "" {
+			redacted[k] = "[REDACTED]"
+			continue
+		}
+		redacted[k] = v
+	}
+	return redacted
+}
+
+func isSecretKey(key string) bool {
+	lower := strings.ToLower(key)
+	for _, marker := range secretMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
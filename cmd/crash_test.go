@@ -0,0 +1,39 @@
+package cmd
+
+import "testing"
+
+func TestRedactSettings(t *testing.T) {
+	settings := map[string]any{
+		"project":      "my-project",
+		"access-token": "ya29.secret",
+		"profiles": map[string]any{
+			"prod": map[string]any{
+				"credentials-file": "/tmp/sa.json",
+				"order":            "asc",
+			},
+		},
+		"api-key": "",
+	}
+
+	redacted := redactSettings(settings)
+
+	if redacted["project"] != "my-project" {
+		t.Errorf("project = %v, want unchanged", redacted["project"])
+	}
+	if redacted["access-token"] != "[REDACTED]" {
+		t.Errorf("access-token = %v, want redacted", redacted["access-token"])
+	}
+	if redacted["api-key"] != "" {
+		t.Errorf("api-key = %v, want empty values left alone", redacted["api-key"])
+	}
+	prod := redacted["profiles"].(map[string]any)["prod"].(map[string]any)
+	if prod["credentials-file"] != "[REDACTED]" {
+		t.Errorf("nested credentials-file = %v, want redacted", prod["credentials-file"])
+	}
+	if prod["order"] != "asc" {
+		t.Errorf("nested order = %v, want unchanged", prod["order"])
+	}
+	if settings["access-token"] != "ya29.secret" {
+		t.Errorf("redactSettings mutated its input")
+	}
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/viper"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// accessTokenEnv is the environment variable read when --access-token is not given
+const accessTokenEnv = "GRAPPLE_ACCESS_TOKEN"
+
+// loggingEmulatorHostEnv is the environment variable pointing the Logging clients to an emulator
+const loggingEmulatorHostEnv = "GRAPPLE_LOGGING_EMULATOR_HOST"
+
+// Transports of --transport.
+const (
+	transportGRPC = "grpc"
+	transportREST = "rest"
+)
+
+var credentialsFile string
+var accessToken string
+var quotaProject string
+var caCertFile string
+var insecureSkipVerify bool
+
+// clientOptions returns the options authenticating the API clients as requested by
+// --credentials-file or --access-token, none for the application default credentials,
+// and billing the requests to --quota-project
+func clientOptions() ([]option.ClientOption, error) {
+	var opts []option.ClientOption
+	token := accessToken
+	if token == "" {
+		token = os.Getenv(accessTokenEnv)
+	}
+	switch {
+	case credentialsFile != "" && token != "":
+		return nil, errors.New("--credentials-file cannot be used together with --access-token or " + accessTokenEnv)
+	case credentialsFile != "":
+		if _, err := os.Stat(credentialsFile); err != nil {
+			return nil, err
+		}
+		opts = append(opts, option.WithCredentialsFile(credentialsFile))
+	case token != "":
+		opts = append(opts, option.WithTokenSource(oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})))
+	}
+	if quotaProject != "" {
+		opts = append(opts, option.WithQuotaProject(expandProject(quotaProject, projectAliases())))
+	}
+	return opts, nil
+}
+
+// loggingTransport returns the transport set by --transport or the transport config
+func loggingTransport() (string, error) {
+	switch name := viper.GetString("transport"); name {
+	case "", transportGRPC:
+		return transportGRPC, nil
+	case transportREST:
+		return transportREST, nil
+	default:
+		return "", fmt.Errorf("invalid --transport %q, valid values: %s, %s", name, transportGRPC, transportREST)
+	}
+}
+
+// loggingClientOptions returns the options of the Logging clients over the transport: the ones of clientOptions
+// with the --endpoint, if any, or, when an emulator is configured, its endpoint without authentication nor TLS
+func loggingClientOptions(ctx context.Context, transport string) ([]option.ClientOption, error) {
+	host := os.Getenv(loggingEmulatorHostEnv)
+	if host != "" && transport == transportREST {
+		return []option.ClientOption{
+			option.WithEndpoint("http://" + host),
+			option.WithoutAuthentication(),
+			option.WithTelemetryDisabled(),
+		}, nil
+	}
+	if host != "" {
+		return []option.ClientOption{
+			option.WithEndpoint(host),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+			option.WithTelemetryDisabled(),
+		}, nil
+	}
+
+	opts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	if endpoint := viper.GetString("endpoint"); endpoint != "" {
+		if transport == transportREST {
+			opts = append(opts, option.WithEndpoint(endpointURL(endpoint)))
+		} else {
+			opts = append(opts, option.WithEndpoint(endpointAddress(endpoint)))
+		}
+	}
+	config, err := tlsConfig()
+	if err != nil || config == nil {
+		return opts, err
+	}
+	if transport == transportGRPC {
+		return append(opts, option.WithGRPCDialOption(grpc.WithTransportCredentials(credentials.NewTLS(config)))), nil
+	}
+	// The HTTP client replaces the default one, authentication included, so it wraps a transport trusting the CA.
+	base := http.DefaultTransport.(*http.Transport).Clone()
+	base.TLSClientConfig = config
+	authenticated, err := htransport.NewTransport(ctx, base, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return append(opts, option.WithHTTPClient(&http.Client{Transport: authenticated})), nil
+}
+
+// tlsConfig returns the TLS configuration of --ca-cert and --insecure-skip-verify, nil for the default one
+func tlsConfig() (*tls.Config, error) {
+	if caCertFile == "" && !insecureSkipVerify {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+	if insecureSkipVerify {
+		log.Println("Warning: the certificate of the Logging API is not verified (--insecure-skip-verify)")
+	}
+	if caCertFile != "" {
+		pem, err := os.ReadFile(caCertFile)
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in --ca-cert %s", caCertFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+// endpointAddress adds the HTTPS port to an endpoint without one, as gRPC requires it
+func endpointAddress(endpoint string) string {
+	if _, _, err := net.SplitHostPort(endpoint); err != nil {
+		return net.JoinHostPort(endpoint, "443")
+	}
+	return endpoint
+}
+
+// endpointURL adds the HTTPS scheme to an endpoint without one, as REST requires it
+func endpointURL(endpoint string) string {
+	if strings.Contains(endpoint, "://") {
+		return endpoint
+	}
+	return "https://" + endpoint
+}
+
+// newLogadminClient creates a Logging client for the parent over the --transport, with the options of loggingClientOptions
+func newLogadminClient(ctx context.Context, parent string) (*logadmin.Client, error) {
+	transport, err := loggingTransport()
+	if err != nil {
+		return nil, err
+	}
+	opts, err := loggingClientOptions(ctx, transport)
+	if err != nil {
+		return nil, err
+	}
+	if transport == transportREST {
+		return logadmin.NewRESTClient(ctx, parent, opts...)
+	}
+	return logadmin.NewClient(ctx, parent, opts...)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+func TestClientOptions(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key.json")
+	if err := os.WriteFile(keyFile, []byte(`{"type":"service_account"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { credentialsFile, accessToken, quotaProject = "", "", "" }()
+
+	cases := []struct {
+		file, token, env string
+		options          int
+		valid            bool
+	}{
+		{"", "", "", 0, true},
+		{keyFile, "", "", 1, true},
+		{"", "ya29.token", "", 1, true},
+		{"", "", "ya29.env", 1, true},
+		{keyFile, "ya29.token", "", 0, false},
+		{keyFile, "", "ya29.env", 0, false},
+		{filepath.Join(t.TempDir(), "missing.json"), "", "", 0, false},
+	}
+	for _, c := range cases {
+		credentialsFile, accessToken = c.file, c.token
+		t.Setenv(accessTokenEnv, c.env)
+		opts, err := clientOptions()
+		if (err == nil) != c.valid {
+			t.Errorf("clientOptions(%q, %q, env %q) error = %v, want valid %v", c.file, c.token, c.env, err, c.valid)
+		} else if len(opts) != c.options {
+			t.Errorf("clientOptions(%q, %q, env %q) returned %d options, want %d", c.file, c.token, c.env, len(opts), c.options)
+		}
+	}
+
+	credentialsFile, accessToken, quotaProject = keyFile, "", "billing-project"
+	t.Setenv(accessTokenEnv, "")
+	if opts, err := clientOptions(); err != nil || len(opts) != 2 {
+		t.Errorf("clientOptions() with --quota-project = %d options, %v, want 2", len(opts), err)
+	}
+}
+
+func TestLoggingClientOptionsEmulator(t *testing.T) {
+	defer func() { accessToken = "" }()
+	accessToken = "ya29.token"
+
+	t.Setenv(loggingEmulatorHostEnv, "")
+	opts, err := loggingClientOptions(context.Background(), transportGRPC)
+	if err != nil || len(opts) != 1 {
+		t.Errorf("loggingClientOptions() = %d options, %v, want the access token", len(opts), err)
+	}
+
+	t.Setenv(loggingEmulatorHostEnv, "localhost:8085")
+	opts, err = loggingClientOptions(context.Background(), transportGRPC)
+	if err != nil || len(opts) != 4 {
+		t.Errorf("loggingClientOptions() = %d options, %v, want the emulator ones", len(opts), err)
+	}
+	opts, err = loggingClientOptions(context.Background(), transportREST)
+	if err != nil || len(opts) != 3 {
+		t.Errorf("loggingClientOptions(rest) = %d options, %v, want the emulator ones without gRPC", len(opts), err)
+	}
+}
+
+func TestLoggingTransport(t *testing.T) {
+	defer viper.Set("transport", nil)
+	for input, expected := range map[string]string{"": transportGRPC, "grpc": transportGRPC, "rest": transportREST} {
+		viper.Set("transport", input)
+		if got, err := loggingTransport(); got != expected || err != nil {
+			t.Errorf("loggingTransport() with %q = %q, %v, want %q", input, got, err, expected)
+		}
+	}
+	viper.Set("transport", "http")
+	if _, err := loggingTransport(); err == nil {
+		t.Error("loggingTransport() with http succeeded, want an error")
+	}
+}
+
+func TestEndpointAddress(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"restricted.googleapis.com", "restricted.googleapis.com:443"},
+		{"logging.europe-west1.rep.googleapis.com:443", "logging.europe-west1.rep.googleapis.com:443"},
+		{"10.0.0.5:8443", "10.0.0.5:8443"},
+	}
+	for _, c := range cases {
+		if got := endpointAddress(c.input); got != c.expected {
+			t.Errorf("endpointAddress(%q) = %q, want %q", c.input, got, c.expected)
+		}
+	}
+
+	if got := endpointURL("restricted.googleapis.com"); got != "https://restricted.googleapis.com" {
+		t.Errorf("endpointURL() = %q, want the HTTPS scheme", got)
+	}
+	if got := endpointURL("http://localhost:8080"); got != "http://localhost:8080" {
+		t.Errorf("endpointURL() = %q, want the given scheme", got)
+	}
+}
+
+func TestTLSConfig(t *testing.T) {
+	defer func() { caCertFile, insecureSkipVerify = "", false }()
+
+	if config, err := tlsConfig(); config != nil || err != nil {
+		t.Errorf("tlsConfig() = %v, %v, want the default one", config, err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Corporate Proxy CA"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	caCertFile = filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caCertFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config, err := tlsConfig()
+	if err != nil || config == nil || config.RootCAs == nil || config.InsecureSkipVerify {
+		t.Errorf("tlsConfig() with --ca-cert = %v, %v, want the CA among the roots", config, err)
+	}
+
+	caCertFile = filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(caCertFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tlsConfig(); err == nil {
+		t.Errorf("tlsConfig() expected error for a file without certificates, got nil")
+	}
+
+	caCertFile, insecureSkipVerify = "", true
+	if config, err := tlsConfig(); err != nil || config == nil || !config.InsecureSkipVerify {
+		t.Errorf("tlsConfig() with --insecure-skip-verify = %v, %v", config, err)
+	}
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+)
+
+// defaultCSVColumns are the columns of --format csv and tsv when --columns is not set
+var defaultCSVColumns = []string{"timestamp", "severity", "logName", "textPayload", "jsonPayload.message"}
+
+// csvColumnPaths returns the paths of the --columns fields, the default ones when empty
+func csvColumnPaths(columns []string) [][]string {
+	if paths := parseFields(columns); len(paths) > 0 {
+		return paths
+	}
+	return parseFields(defaultCSVColumns)
+}
+
+// csvHeader returns the header row of --format csv and tsv, empty for the other formats
+func csvHeader(cmd *cobra.Command) (string, error) {
+	format := entryFormat(cmd)
+	columns, err := cmd.Flags().GetStringSlice("columns")
+	if err != nil {
+		return "", err
+	}
+	if format != formatCSV && format != formatTSV {
+		return "", nil
+	}
+	paths := csvColumnPaths(columns)
+	names := make([]string, len(paths))
+	for i, path := range paths {
+		names[i] = strings.Join(path, ".")
+	}
+	return csvRecord(names, format == formatTSV)
+}
+
+// renderCSV returns the function formatting each entry as a CSV record (RFC 4180) of the columns,
+// or with tsv as tab-separated values, the cells of missing fields left empty
+func renderCSV(paths [][]string, tsv bool) func(*loggingpb.LogEntry) (string, error) {
+	return func(entry *loggingpb.LogEntry) (string, error) {
+		m, err := entryFields(entry)
+		if err != nil {
+			return "", err
+		}
+		cells := make([]string, len(paths))
+		for i, path := range paths {
+			value, ok := lookupPath(m, path)
+			if !ok {
+				continue
+			}
+			if s, ok := value.(string); ok {
+				cells[i] = s
+			} else if cells[i], err = marshalJSONValue(value); err != nil {
+				return "", err
+			}
+		}
+		return csvRecord(cells, tsv)
+	}
+}
+
+// tsvEscaper escapes the characters that cannot appear in the cells of tab-separated values
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// csvRecord formats cells as a single record, without the line ending
+func csvRecord(cells []string, tsv bool) (string, error) {
+	if tsv {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = tsvEscaper.Replace(cell)
+		}
+		return strings.Join(escaped, "\t"), nil
+	}
+	var b strings.Builder
+	w := csv.NewWriter(&b)
+	if err := w.Write(cells); err != nil {
+		return "", err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRenderCSV(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]any{"message": "said \"hi\",\nthen\tleft", "count": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &loggingpb.LogEntry{
+		Timestamp: timestamppb.New(time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)),
+		Severity:  logtypepb.LogSeverity_ERROR,
+		Payload:   &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+	}
+	paths := parseFields([]string{"timestamp", "severity", "jsonPayload.message", "jsonPayload.count", "labels.missing"})
+
+	tests := []struct {
+		tsv      bool
+		expected string
+	}{
+		{false, "2025-01-02T15:04:05Z,ERROR,\"said \"\"hi\"\",\nthen\tleft\",3,"},
+		{true, "2025-01-02T15:04:05Z\tERROR\tsaid \"hi\",\\nthen\\tleft\t3\t"},
+	}
+	for _, test := range tests {
+		line, err := renderCSV(paths, test.tsv)(entry)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != test.expected {
+			t.Errorf("renderCSV(tsv %t) = %q, want %q", test.tsv, line, test.expected)
+		}
+	}
+}
+
+func TestEntryPrinterCSVHeader(t *testing.T) {
+	cmd := &cobra.Command{}
+	addFormatFlags(cmd)
+	cmd.Flags().Set("format", formatCSV)
+	cmd.Flags().Set("columns", "severity,jsonPayload.message")
+
+	var buf bytes.Buffer
+	saved := stdout
+	stdout = &buf
+	defer func() { stdout = saved }()
+
+	process, flush, err := newEntryPrinter(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, severity := range []logtypepb.LogSeverity{logtypepb.LogSeverity_INFO, logtypepb.LogSeverity_WARNING} {
+		if err := process(&loggingpb.LogEntry{Severity: severity}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := flush(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "severity,jsonPayload.message\nINFO,\nWARNING,\n"; buf.String() != expected {
+		t.Errorf("printed %q, want %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	_, flush, err = newEntryPrinter(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := flush(); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "severity,jsonPayload.message\n"; buf.String() != expected {
+		t.Errorf("printed %q without entries, want the header", buf.String())
+	}
+}
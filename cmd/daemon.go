@@ -0,0 +1,365 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// daemonStopTimeout is how long tenants get to drain their output after being signaled
+	daemonStopTimeout = 30 * time.Second
+	// daemonMaxRestartDelay caps the delay between the restarts of a failing tenant
+	daemonMaxRestartDelay = time.Minute
+)
+
+// daemonConfig is the content of the --tenants file
+type daemonConfig struct {
+	Tenants []tenantConfig `yaml:"tenants"`
+}
+
+// tenantConfig describes a tenant: the project and credentials to fetch with, and the flags of its query
+type tenantConfig struct {
+	Name        string            `yaml:"name"`
+	Project     string            `yaml:"project"`
+	Credentials string            `yaml:"credentials"`
+	Filter      string            `yaml:"filter"`
+	Args        []string          `yaml:"args"`
+	Env         map[string]string `yaml:"env"`
+}
+
+// tenantMetrics counts what happened to a tenant, from its exits and diagnostic messages
+type tenantMetrics struct {
+	mu              sync.Mutex
+	up              bool
+	restarts        int
+	errors          int
+	warnings        int
+	rateLimits      int
+	transientErrors int
+}
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Watch the logs of several tenants, each with its own project, credentials and output",
+	Long: `Run a watch query (see --watch) for each tenant of the --tenants file, for
+centralized log collection across projects and organizations:
+
+  tenants:
+    - name: acme
+      project: acme-prod
+      credentials: /secrets/acme.json
+      filter: severity>=WARNING
+      args: [--output, /var/log/acme.ndjson, --append, --format, json]
+
+Each tenant runs in its own grapple process, with its own credentials
+(GOOGLE_APPLICATION_CREDENTIALS), rate limit backoff and output, restarted
+with a backoff when it fails, and resuming from the checkpoint named after it
+unless --checkpoint is among its args, so that files given with --output
+need --append to keep the entries of the previous runs. Entries of tenants
+without --output are printed to stdout, diagnostic messages are prefixed with
+the tenant name. The tenants do not inherit the GRAPPLE_ACCESS_TOKEN,
+GOOGLE_APPLICATION_CREDENTIALS and GRAPPLE_PROFILE of the daemon: those without
+credentials use the application default credentials of the machine, unless
+set in their env. The --tenants file is read once: restart the daemon to apply
+its changes. The tenants reload the filters, the output settings and the
+notify target of the config file, like --watch.
+
+--metrics-addr serves per-tenant metrics in the Prometheus text format.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		config, err := loadDaemonConfig(cmd.Flag("tenants").Value.String())
+		cobra.CheckErr(err)
+		exe, err := os.Executable()
+		cobra.CheckErr(err)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		metrics := map[string]*tenantMetrics{}
+		for _, t := range config.Tenants {
+			metrics[t.Name] = &tenantMetrics{}
+		}
+		if addr := cmd.Flag("metrics-addr").Value.String(); addr != "" {
+			cobra.CheckErr(serveDaemonMetrics(addr, config.Tenants, metrics))
+		}
+
+		var stdoutMu sync.Mutex
+		var wg sync.WaitGroup
+		for _, t := range config.Tenants {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				runTenant(ctx, exe, t, metrics[t.Name], &stdoutMu)
+			}()
+		}
+		sdNotify("READY=1")
+		log.Printf("Started %d tenants", len(config.Tenants))
+		<-ctx.Done()
+		sdNotify("STOPPING=1")
+		log.Printf("Stopping the tenants")
+		wg.Wait()
+	},
+}
+
+// loadDaemonConfig reads and validates the --tenants file
+func loadDaemonConfig(path string) (*daemonConfig, error) {
+	if path == "" {
+		return nil, errors.New("required flag \"tenants\" not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var config daemonConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid tenants file %s: %w", path, err)
+	}
+	if len(config.Tenants) == 0 {
+		return nil, fmt.Errorf("no tenants in %s", path)
+	}
+
+	seen := map[string]bool{}
+	for _, t := range config.Tenants {
+		switch {
+		case !savedQueryNamePattern.MatchString(t.Name):
+			return nil, fmt.Errorf("invalid tenant name %q, use letters, digits, '.', '_' and '-'", t.Name)
+		case seen[t.Name]:
+			return nil, fmt.Errorf("duplicate tenant %q", t.Name)
+		case t.Project == "":
+			return nil, fmt.Errorf("tenant %q has no project", t.Name)
+		}
+		seen[t.Name] = true
+	}
+	return &config, nil
+}
+
+// tenantArgs returns the command line of the grapple process of a tenant
+func tenantArgs(t tenantConfig, configFile string) []string {
+	args := []string{"--project", t.Project, "--no-gcloud"}
+	if configFile != "" {
+		args = append(args, "--config", configFile)
+	}
+	args = append(args, t.Args...)
+	if !hasFlag(t.Args, "watch") {
+		args = append(args, "--watch")
+	}
+	if !hasFlag(t.Args, "checkpoint") {
+		args = append(args, "--checkpoint", t.Name)
+	}
+	if t.Filter != "" {
+		args = append(args, "--", t.Filter)
+	}
+	return args
+}
+
+// hasFlag reports whether a long flag is among the arguments
+func hasFlag(args []string, name string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool {
+		return arg == "--"+name || strings.HasPrefix(arg, "--"+name+"=")
+	})
+}
+
+// daemonOnlyEnv are the variables of the daemon that its tenants do not inherit, as they would read
+// the logs with the credentials or the profile of the daemon rather than with their own
+var daemonOnlyEnv = []string{accessTokenEnv, "GOOGLE_APPLICATION_CREDENTIALS", strings.ToUpper(cliName) + "_PROFILE"}
+
+// tenantEnv returns the environment of the grapple process of a tenant
+func tenantEnv(t tenantConfig) []string {
+	env := slices.DeleteFunc(os.Environ(), func(v string) bool {
+		name, _, _ := strings.Cut(v, "=")
+		return slices.Contains(daemonOnlyEnv, name)
+	})
+	if t.Credentials != "" {
+		env = append(env, "GOOGLE_APPLICATION_CREDENTIALS="+t.Credentials)
+	}
+	for name, value := range t.Env {
+		env = append(env, name+"="+value)
+	}
+	return env
+}
+
+// runTenant runs the grapple process of a tenant until the context is done, restarting it when it exits
+func runTenant(ctx context.Context, exe string, t tenantConfig, metrics *tenantMetrics, stdoutMu *sync.Mutex) {
+	args := tenantArgs(t, cfgFile)
+	for attempt := 0; ; attempt++ {
+		started := time.Now()
+		err := runTenantProcess(ctx, exe, args, tenantEnv(t), t.Name, metrics, stdoutMu)
+		if ctx.Err() != nil {
+			return
+		}
+		if time.Since(started) > daemonMaxRestartDelay {
+			attempt = 0
+		}
+		delay := min(time.Second<<min(attempt, 6), daemonMaxRestartDelay)
+		log.Printf("Error: tenant %s exited (%v), restarting in %s", t.Name, err, delay)
+		metrics.mu.Lock()
+		metrics.restarts++
+		metrics.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+func runTenantProcess(ctx context.Context, exe string, args, env []string, name string, metrics *tenantMetrics, stdoutMu *sync.Mutex) error {
+	c := exec.CommandContext(ctx, exe, args...)
+	c.Env = env
+	// Stopping the daemon lets the tenants drain their output, like an interrupt does.
+	c.Cancel = func() error { return c.Process.Signal(syscall.SIGTERM) }
+	c.WaitDelay = daemonStopTimeout
+	stdoutPipe, err := c.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderrPipe, err := c.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	metrics.setUp(true)
+	defer metrics.setUp(false)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		forEachLine(stdoutPipe, func(line []byte) {
+			stdoutMu.Lock()
+			stdout.Write(append(line, '\n'))
+			stdoutMu.Unlock()
+		})
+	}()
+	go func() {
+		defer wg.Done()
+		forEachLine(stderrPipe, func(line []byte) {
+			metrics.observe(string(line))
+			log.Printf("[%s] %s", name, line)
+		})
+	}()
+	wg.Wait()
+	return c.Wait()
+}
+
+// forEachLine calls fn with each line of r, without its newline, until r fails or ends. Lines of any
+// length are read, so that the pipes of the tenants are drained rather than blocking them.
+func forEachLine(r io.Reader, fn func(line []byte)) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			fn(bytes.TrimSuffix(line, []byte("\n")))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (m *tenantMetrics) setUp(up bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.up = up
+}
+
+// observe counts a diagnostic message of the tenant
+func (m *tenantMetrics) observe(line string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch {
+	case strings.HasPrefix(line, "Error"):
+		m.errors++
+	case strings.HasPrefix(line, "Warning"):
+		m.warnings++
+	case strings.HasPrefix(line, "Rate limit exceeded"):
+		m.rateLimits++
+	case strings.HasPrefix(line, "Transient error"):
+		m.transientErrors++
+	}
+}
+
+// writeDaemonMetrics prints the metrics of the tenants in the Prometheus text format
+func writeDaemonMetrics(w io.Writer, tenants []tenantConfig, metrics map[string]*tenantMetrics) error {
+	families := []struct {
+		name, kind, help string
+		value            func(m *tenantMetrics) int
+	}{
+		{"grapple_tenant_up", "gauge", "Whether the process of the tenant is running.", func(m *tenantMetrics) int {
+			if m.up {
+				return 1
+			}
+			return 0
+		}},
+		{"grapple_tenant_restarts_total", "counter", "Restarts of the process of the tenant.", func(m *tenantMetrics) int { return m.restarts }},
+		{"grapple_tenant_errors_total", "counter", "Error messages of the tenant.", func(m *tenantMetrics) int { return m.errors }},
+		{"grapple_tenant_warnings_total", "counter", "Warning messages of the tenant.", func(m *tenantMetrics) int { return m.warnings }},
+		{"grapple_tenant_rate_limits_total", "counter", "Rate limit errors of the tenant.", func(m *tenantMetrics) int { return m.rateLimits }},
+		{"grapple_tenant_transient_errors_total", "counter", "Transient API errors of the tenant.", func(m *tenantMetrics) int { return m.transientErrors }},
+	}
+
+	var b strings.Builder
+	for _, f := range families {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+		for _, t := range tenants {
+			m := metrics[t.Name]
+			m.mu.Lock()
+			fmt.Fprintf(&b, "%s{tenant=%q} %d\n", f.name, t.Name, f.value(m))
+			m.mu.Unlock()
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// serveDaemonMetrics exposes /metrics on addr in the background
+func serveDaemonMetrics(addr string, tenants []tenantConfig, metrics map[string]*tenantMetrics) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeDaemonMetrics(w, tenants, metrics)
+	})
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Error: metrics server stopped: %v", err)
+		}
+	}()
+	log.Printf("Serving metrics on %s", listener.Addr())
+	return nil
+}
+
+func init() {
+	daemonCmd.Flags().String("tenants", "", "YAML file listing the tenants (required)")
+	daemonCmd.MarkFlagFilename("tenants", "yaml", "yml")
+	daemonCmd.Flags().String("metrics-addr", "", "serve the per-tenant metrics on /metrics at this address (e.g. :9090)")
+
+	rootCmd.AddCommand(daemonCmd)
+}
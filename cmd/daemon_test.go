@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadDaemonConfig(t *testing.T) {
+	cases := []struct {
+		content string
+		valid   bool
+	}{
+		{"tenants:\n  - name: acme\n    project: acme-prod\n    credentials: /secrets/acme.json\n    args: [--format, json]\n", true},
+		{"tenants: []\n", false},
+		{"tenants:\n  - name: acme\n", false},
+		{"tenants:\n  - name: acme\n    project: a\n  - name: acme\n    project: b\n", false},
+		{"tenants:\n  - name: acme corp\n    project: a\n", false},
+		{"tenants:\n  - name: acme\n    projet: a\n", false},
+	}
+	for _, c := range cases {
+		path := filepath.Join(t.TempDir(), "tenants.yaml")
+		if err := os.WriteFile(path, []byte(c.content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		_, err := loadDaemonConfig(path)
+		if (err == nil) != c.valid {
+			t.Errorf("loadDaemonConfig(%q) error = %v, want valid %v", c.content, err, c.valid)
+		}
+	}
+}
+
+func TestTenantArgs(t *testing.T) {
+	cases := []struct {
+		tenant     tenantConfig
+		configFile string
+		expected   []string
+	}{
+		{
+			tenantConfig{Name: "acme", Project: "acme-prod", Filter: "severity>=ERROR", Args: []string{"--format", "json"}},
+			"",
+			[]string{"--project", "acme-prod", "--no-gcloud", "--format", "json", "--watch", "--checkpoint", "acme", "--", "severity>=ERROR"},
+		},
+		{
+			tenantConfig{Name: "beta", Project: "beta-prod", Args: []string{"--watch=1m", "--checkpoint", "shared"}},
+			"/etc/grapple.yaml",
+			[]string{"--project", "beta-prod", "--no-gcloud", "--config", "/etc/grapple.yaml", "--watch=1m", "--checkpoint", "shared"},
+		},
+	}
+	for _, c := range cases {
+		if got := tenantArgs(c.tenant, c.configFile); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("tenantArgs(%s) = %q, want %q", c.tenant.Name, got, c.expected)
+		}
+	}
+}
+
+func TestDaemonMetrics(t *testing.T) {
+	tenants := []tenantConfig{{Name: "acme"}, {Name: "beta"}}
+	metrics := map[string]*tenantMetrics{"acme": {up: true, restarts: 2}, "beta": {}}
+	for _, line := range []string{"Rate limit exceeded (q: 1), sleeping...", "Error processing log entry (x): boom", "Using config file: x", "Warning: y"} {
+		metrics["beta"].observe(line)
+	}
+
+	var b strings.Builder
+	if err := writeDaemonMetrics(&b, tenants, metrics); err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{
+		"# TYPE grapple_tenant_up gauge\n",
+		`grapple_tenant_up{tenant="acme"} 1` + "\n",
+		`grapple_tenant_up{tenant="beta"} 0` + "\n",
+		`grapple_tenant_restarts_total{tenant="acme"} 2` + "\n",
+		`grapple_tenant_errors_total{tenant="beta"} 1` + "\n",
+		`grapple_tenant_warnings_total{tenant="beta"} 1` + "\n",
+		`grapple_tenant_rate_limits_total{tenant="beta"} 1` + "\n",
+		`grapple_tenant_transient_errors_total{tenant="beta"} 0` + "\n",
+	} {
+		if !strings.Contains(b.String(), expected) {
+			t.Errorf("writeDaemonMetrics() = %q, missing %q", b.String(), expected)
+		}
+	}
+}
+
+func TestForEachLine(t *testing.T) {
+	long := strings.Repeat("x", 1<<20)
+	var lines []string
+	forEachLine(strings.NewReader("Error: a\n"+long+"\nlast"), func(line []byte) {
+		lines = append(lines, string(line))
+	})
+	if len(lines) != 3 || lines[0] != "Error: a" || lines[1] != long || lines[2] != "last" {
+		t.Errorf("forEachLine() read %d lines, want the 3 lines whatever their length", len(lines))
+	}
+}
+
+func TestTenantEnv(t *testing.T) {
+	t.Setenv(accessTokenEnv, "daemon-token")
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "/secrets/daemon.json")
+	t.Setenv("GRAPPLE_PROFILE", "daemon")
+	t.Setenv("GRAPPLE_TIMEZONE", "Europe/Rome")
+
+	values := func(env []string, name string) []string {
+		var found []string
+		for _, v := range env {
+			if value, ok := strings.CutPrefix(v, name+"="); ok {
+				found = append(found, value)
+			}
+		}
+		return found
+	}
+	env := tenantEnv(tenantConfig{Name: "acme", Credentials: "/secrets/acme.json", Env: map[string]string{"HTTPS_PROXY": "http://proxy:3128"}})
+	for name, expected := range map[string][]string{
+		accessTokenEnv:                   nil,
+		"GOOGLE_APPLICATION_CREDENTIALS": {"/secrets/acme.json"},
+		"GRAPPLE_PROFILE":                nil,
+		"GRAPPLE_TIMEZONE":               {"Europe/Rome"},
+		"HTTPS_PROXY":                    {"http://proxy:3128"},
+	} {
+		if got := values(env, name); !reflect.DeepEqual(got, expected) {
+			t.Errorf("tenantEnv() %s = %q, want %q", name, got, expected)
+		}
+	}
+
+	// Without credentials of its own, a tenant uses the application default credentials of the machine.
+	if got := values(tenantEnv(tenantConfig{Name: "beta"}), "GOOGLE_APPLICATION_CREDENTIALS"); got != nil {
+		t.Errorf("tenantEnv() without credentials GOOGLE_APPLICATION_CREDENTIALS = %q, want none", got)
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// dedupeKey identifies an entry for --dedupe
+type dedupeKey struct {
+	insertId  string
+	timestamp int64
+}
+
+// deduper drops the entries whose insertId and timestamp were already printed in the run, as
+// watch polls, retries and the views of several buckets can return the same entry more than once
+type deduper struct {
+	next       func(*loggingpb.LogEntry) error
+	seen       map[dedupeKey]bool
+	duplicates int
+}
+
+func newDeduper(next func(*loggingpb.LogEntry) error) *deduper {
+	return &deduper{next: next, seen: map[dedupeKey]bool{}}
+}
+
+func (d *deduper) process(entry *loggingpb.LogEntry) error {
+	if entry.InsertId == "" {
+		return d.next(entry)
+	}
+	key := dedupeKey{insertId: entry.InsertId, timestamp: entry.Timestamp.AsTime().UnixNano()}
+	if d.seen[key] {
+		d.duplicates++
+		return nil
+	}
+	d.seen[key] = true
+	return d.next(entry)
+}
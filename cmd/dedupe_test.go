@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestDeduper(t *testing.T) {
+	start := time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+	entries := []*loggingpb.LogEntry{
+		{InsertId: "a", Timestamp: timestamppb.New(start), Trace: "first"},
+		{InsertId: "b", Timestamp: timestamppb.New(start)},
+		{InsertId: "a", Timestamp: timestamppb.New(start), Trace: "again"},
+		// Same insertId at another time, a different entry.
+		{InsertId: "a", Timestamp: timestamppb.New(start.Add(time.Second)), Trace: "later"},
+		{Timestamp: timestamppb.New(start), Trace: "no insertId"},
+		{Timestamp: timestamppb.New(start), Trace: "no insertId"},
+	}
+
+	var printed []string
+	d := newDeduper(func(entry *loggingpb.LogEntry) error {
+		printed = append(printed, entry.InsertId+" "+entry.Trace)
+		return nil
+	})
+	for _, entry := range entries {
+		if err := d.process(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{"a first", "b ", "a later", " no insertId", " no insertId"}
+	if !reflect.DeepEqual(printed, expected) {
+		t.Errorf("printed %q, want %q", printed, expected)
+	}
+	if d.duplicates != 1 {
+		t.Errorf("duplicates = %d, want 1", d.duplicates)
+	}
+}
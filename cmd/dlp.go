@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	dlp "google.golang.org/api/dlp/v2"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	dlpInspect = "inspect"
+	dlpRedact  = "redact"
+)
+
+// dlpFindingsLabel is the label listing the info types found by --dlp inspect
+const dlpFindingsLabel = "grapple.dlp/findings"
+
+// defaultDLPInfoTypes are the info types looked for when --dlp-info-types is not set
+var defaultDLPInfoTypes = []string{"CREDIT_CARD_NUMBER", "EMAIL_ADDRESS", "GCP_API_KEY", "IP_ADDRESS", "JSON_WEB_TOKEN", "PERSON_NAME", "PHONE_NUMBER"}
+
+func addDLPFlags(c *cobra.Command) {
+	c.Flags().String("dlp", "", fmt.Sprintf("send the payloads to Cloud DLP and either annotate the entries with the findings in the %s label or redact them, valid values: %s, %s", dlpFindingsLabel, dlpInspect, dlpRedact))
+	c.Flags().StringSlice("dlp-info-types", defaultDLPInfoTypes, "info types looked for by --dlp")
+	c.Flags().String("dlp-min-likelihood", "POSSIBLE", "minimum likelihood of the findings of --dlp")
+	c.Flags().String("dlp-location", "global", "location of the DLP API requests")
+	c.Flags().Int("dlp-batch-size", 100, "entries sent to DLP per request")
+}
+
+// dlpLeaf is a string of a payload sent to DLP, with the way to replace it
+type dlpLeaf struct {
+	entry int
+	value string
+	set   func(string)
+}
+
+// dlpProcessor batches entries to inspect or redact their payloads with Cloud DLP before handing them over
+type dlpProcessor struct {
+	ctx       context.Context
+	contents  *dlp.ProjectsLocationsContentService
+	parent    string
+	mode      string
+	config    *dlp.GooglePrivacyDlpV2InspectConfig
+	batchSize int
+	next      func(*loggingpb.LogEntry) error
+
+	batch []*loggingpb.LogEntry
+	// err is the failure that stopped the processor; entries are never handed over unprocessed.
+	err error
+}
+
+// newDLPProcessor returns the processor configured by the --dlp flags, or nil if --dlp is not set
+func newDLPProcessor(ctx context.Context, cmd *cobra.Command, projectId string, next func(*loggingpb.LogEntry) error) (*dlpProcessor, error) {
+	mode := cmd.Flag("dlp").Value.String()
+	if mode == "" {
+		return nil, nil
+	}
+	if mode != dlpInspect && mode != dlpRedact {
+		return nil, fmt.Errorf("invalid --dlp %q, valid values: %s, %s", mode, dlpInspect, dlpRedact)
+	}
+	batchSize, err := cmd.Flags().GetInt("dlp-batch-size")
+	if err != nil {
+		return nil, err
+	}
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("invalid --dlp-batch-size %d", batchSize)
+	}
+	infoTypeNames, err := cmd.Flags().GetStringSlice("dlp-info-types")
+	if err != nil {
+		return nil, err
+	}
+	infoTypes := make([]*dlp.GooglePrivacyDlpV2InfoType, len(infoTypeNames))
+	for i, name := range infoTypeNames {
+		infoTypes[i] = &dlp.GooglePrivacyDlpV2InfoType{Name: name}
+	}
+
+	opts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	service, err := dlp.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &dlpProcessor{
+		ctx:      ctx,
+		contents: service.Projects.Locations.Content,
+		parent:   fmt.Sprintf("projects/%s/locations/%s", projectId, cmd.Flag("dlp-location").Value.String()),
+		mode:     mode,
+		config: &dlp.GooglePrivacyDlpV2InspectConfig{
+			InfoTypes:     infoTypes,
+			MinLikelihood: cmd.Flag("dlp-min-likelihood").Value.String(),
+		},
+		batchSize: batchSize,
+		next:      next,
+	}, nil
+}
+
+// process adds the entry to the batch, sending the batch to DLP when full
+func (p *dlpProcessor) process(entry *loggingpb.LogEntry) error {
+	if p.err != nil {
+		return grapple.ErrStop
+	}
+	p.batch = append(p.batch, entry)
+	if len(p.batch) < p.batchSize {
+		return nil
+	}
+	if err := p.flush(); err != nil {
+		return grapple.ErrStop
+	}
+	return nil
+}
+
+// flush sends the pending entries to DLP and hands them over, returning the error that stopped the processor if any
+func (p *dlpProcessor) flush() error {
+	if p.err != nil || len(p.batch) == 0 {
+		return p.err
+	}
+	batch := p.batch
+	p.batch = nil
+
+	leaves := dlpLeaves(batch)
+	if len(leaves) > 0 {
+		var err error
+		if p.mode == dlpRedact {
+			err = p.redact(leaves)
+		} else {
+			err = p.inspect(batch, leaves)
+		}
+		if err != nil {
+			p.err = fmt.Errorf("dlp %s: %w", p.mode, err)
+			return p.err
+		}
+	}
+	for _, entry := range batch {
+		if err := p.next(entry); errors.Is(err, grapple.ErrStop) {
+			return nil
+		} else if err != nil {
+			counters.skipped++
+			log.Printf("Error processing log entry (%s): %v", entry.InsertId, err)
+		}
+	}
+	return nil
+}
+
+// dlpTable puts the leaves in a single column table, so that findings can be traced back to them by row
+func dlpTable(leaves []dlpLeaf) *dlp.GooglePrivacyDlpV2ContentItem {
+	rows := make([]*dlp.GooglePrivacyDlpV2Row, len(leaves))
+	for i, leaf := range leaves {
+		rows[i] = &dlp.GooglePrivacyDlpV2Row{Values: []*dlp.GooglePrivacyDlpV2Value{{StringValue: leaf.value}}}
+	}
+	return &dlp.GooglePrivacyDlpV2ContentItem{Table: &dlp.GooglePrivacyDlpV2Table{
+		Headers: []*dlp.GooglePrivacyDlpV2FieldId{{Name: "value"}},
+		Rows:    rows,
+	}}
+}
+
+func (p *dlpProcessor) inspect(batch []*loggingpb.LogEntry, leaves []dlpLeaf) error {
+	response, err := p.contents.Inspect(p.parent, &dlp.GooglePrivacyDlpV2InspectContentRequest{
+		InspectConfig: p.config,
+		Item:          dlpTable(leaves),
+	}).Context(p.ctx).Do()
+	if err != nil {
+		return err
+	}
+	if response.Result == nil {
+		return nil
+	}
+
+	found := map[int][]string{}
+	for _, finding := range response.Result.Findings {
+		if finding.InfoType == nil || finding.Location == nil {
+			continue
+		}
+		for _, location := range finding.Location.ContentLocations {
+			if location.RecordLocation == nil || location.RecordLocation.TableLocation == nil {
+				continue
+			}
+			row := int(location.RecordLocation.TableLocation.RowIndex)
+			if row < 0 || row >= len(leaves) {
+				continue
+			}
+			i := leaves[row].entry
+			if !slices.Contains(found[i], finding.InfoType.Name) {
+				found[i] = append(found[i], finding.InfoType.Name)
+			}
+		}
+	}
+	for i, infoTypes := range found {
+		slices.Sort(infoTypes)
+		if batch[i].Labels == nil {
+			batch[i].Labels = map[string]string{}
+		}
+		batch[i].Labels[dlpFindingsLabel] = strings.Join(infoTypes, ",")
+	}
+	return nil
+}
+
+func (p *dlpProcessor) redact(leaves []dlpLeaf) error {
+	response, err := p.contents.Deidentify(p.parent, &dlp.GooglePrivacyDlpV2DeidentifyContentRequest{
+		InspectConfig: p.config,
+		DeidentifyConfig: &dlp.GooglePrivacyDlpV2DeidentifyConfig{
+			InfoTypeTransformations: &dlp.GooglePrivacyDlpV2InfoTypeTransformations{
+				Transformations: []*dlp.GooglePrivacyDlpV2InfoTypeTransformation{{
+					PrimitiveTransformation: &dlp.GooglePrivacyDlpV2PrimitiveTransformation{
+						ReplaceWithInfoTypeConfig: &dlp.GooglePrivacyDlpV2ReplaceWithInfoTypeConfig{},
+					},
+				}},
+			},
+		},
+		Item: dlpTable(leaves),
+	}).Context(p.ctx).Do()
+	if err != nil {
+		return err
+	}
+
+	if response.Item == nil || response.Item.Table == nil || len(response.Item.Table.Rows) != len(leaves) {
+		return errors.New("unexpected shape of the redacted table")
+	}
+	for i, row := range response.Item.Table.Rows {
+		if len(row.Values) != 1 {
+			return errors.New("unexpected shape of the redacted table")
+		}
+		if value := row.Values[0].StringValue; value != leaves[i].value {
+			leaves[i].set(value)
+		}
+	}
+	return nil
+}
+
+// dlpLeaves collects the text payloads and the strings of the JSON payloads of the entries
+func dlpLeaves(batch []*loggingpb.LogEntry) []dlpLeaf {
+	var leaves []dlpLeaf
+	for i, entry := range batch {
+		switch payload := entry.Payload.(type) {
+		case *loggingpb.LogEntry_TextPayload:
+			leaves = append(leaves, dlpLeaf{i, payload.TextPayload, func(s string) { payload.TextPayload = s }})
+		case *loggingpb.LogEntry_JsonPayload:
+			for _, value := range payload.JsonPayload.GetFields() {
+				leaves = appendDLPLeaves(leaves, i, value)
+			}
+		}
+	}
+	return leaves
+}
+
+func appendDLPLeaves(leaves []dlpLeaf, entry int, value *structpb.Value) []dlpLeaf {
+	switch kind := value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		leaves = append(leaves, dlpLeaf{entry, kind.StringValue, func(s string) { kind.StringValue = s }})
+	case *structpb.Value_StructValue:
+		for _, nested := range kind.StructValue.GetFields() {
+			leaves = appendDLPLeaves(leaves, entry, nested)
+		}
+	case *structpb.Value_ListValue:
+		for _, nested := range kind.ListValue.GetValues() {
+			leaves = appendDLPLeaves(leaves, entry, nested)
+		}
+	}
+	return leaves
+}
@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestDLPLeaves(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]any{
+		"user":  map[string]any{"email": "jane@example.com", "age": 42},
+		"notes": []any{"call 555-0100"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := []*loggingpb.LogEntry{
+		{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "hello"}},
+		{Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: payload}},
+		{},
+	}
+
+	leaves := dlpLeaves(batch)
+	var values []string
+	for _, leaf := range leaves {
+		values = append(values, leaf.value)
+		leaf.set("[" + leaf.value + "]")
+	}
+	slices.Sort(values)
+	if expected := []string{"call 555-0100", "hello", "jane@example.com"}; !slices.Equal(values, expected) {
+		t.Fatalf("leaf values = %q, want %q", values, expected)
+	}
+
+	if got := batch[0].GetTextPayload(); got != "[hello]" {
+		t.Errorf("text payload = %q", got)
+	}
+	fields := batch[1].GetJsonPayload().AsMap()
+	if got := fields["user"].(map[string]any)["email"]; got != "[jane@example.com]" {
+		t.Errorf("jsonPayload.user.email = %v", got)
+	}
+	if got := fields["notes"].([]any)[0]; got != "[call 555-0100]" {
+		t.Errorf("jsonPayload.notes[0] = %v", got)
+	}
+}
+
+func TestDLPProcessorStopsAfterFailure(t *testing.T) {
+	var handed []*loggingpb.LogEntry
+	p := &dlpProcessor{batchSize: 2, next: func(entry *loggingpb.LogEntry) error {
+		handed = append(handed, entry)
+		return nil
+	}}
+
+	// Entries without payload don't need a DLP request.
+	for range 3 {
+		if err := p.process(&loggingpb.LogEntry{}); err != nil {
+			t.Fatalf("process() = %v", err)
+		}
+	}
+	if err := p.flush(); err != nil || len(handed) != 3 {
+		t.Fatalf("flush() = %v after handing over %d entries, want 3", err, len(handed))
+	}
+
+	p.err = errors.New("quota exceeded")
+	if err := p.process(&loggingpb.LogEntry{}); !errors.Is(err, grapple.ErrStop) {
+		t.Errorf("process() after a failure = %v, want grapple.ErrStop", err)
+	}
+	if err := p.flush(); err == nil || len(handed) != 3 {
+		t.Errorf("flush() after a failure = %v, %d entries handed over", err, len(handed))
+	}
+}
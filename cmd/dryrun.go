@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// dryRunPlan is what --dry-run prints instead of fetching: the request grapple would send, with where its filter comes from
+type dryRunPlan struct {
+	req *loggingpb.ListLogEntriesRequest
+	// filter is the one given to the client, the request one differs when the default window was added.
+	filter   string
+	from, to time.Time
+	// query is the filter of the command line, alwaysFilter the one of the config file or of the profile.
+	query        string
+	alwaysFilter string
+	profile      string
+	// includeBuckets are the buckets of --include-buckets, whose views a dry run does not list.
+	includeBuckets []string
+}
+
+// dryRunReport is the JSON object printed by --dry-run
+type dryRunReport struct {
+	Request        json.RawMessage `json:"request"`
+	Window         dryRunWindow    `json:"window"`
+	ResourceNames  []string        `json:"resourceNames"`
+	IncludeBuckets []string        `json:"includeBuckets,omitempty"`
+	AlwaysFilter   string          `json:"alwaysFilter,omitempty"`
+	Profile        string          `json:"profile,omitempty"`
+}
+
+// dryRunWindow is the time window of the request, Default when it is the one applied
+// to filters without timestamp clauses
+type dryRunWindow struct {
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Default bool   `json:"default,omitempty"`
+}
+
+// window returns the time window the request covers at now
+func (d *dryRunPlan) window(now time.Time) dryRunWindow {
+	var window dryRunWindow
+	switch {
+	case !d.from.IsZero():
+		window.From = d.from.UTC().Format(time.RFC3339Nano)
+		if !d.to.IsZero() {
+			window.To = d.to.UTC().Format(time.RFC3339Nano)
+		}
+	case d.req.Filter != d.filter:
+		window = dryRunWindow{From: now.Add(-24 * time.Hour).UTC().Format(time.RFC3339), Default: true}
+	}
+	return window
+}
+
+// writeJSON prints the request as a JSON object, with the window it covers and the resources it reads
+func (d *dryRunPlan) writeJSON(w io.Writer, now time.Time) error {
+	request, err := protojson.Marshal(d.req)
+	if err != nil {
+		return err
+	}
+	report := dryRunReport{
+		Request:        request,
+		Window:         d.window(now),
+		ResourceNames:  d.req.ResourceNames,
+		IncludeBuckets: d.includeBuckets,
+		AlwaysFilter:   d.alwaysFilter,
+		Profile:        d.profile,
+	}
+	line, err := marshalJSONValue(report)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, line+"\n")
+	return err
+}
+
+// writeText prints the final filter of the request, then the parts it is made of, the resources, page size and order
+func (d *dryRunPlan) writeText(w io.Writer, now time.Time) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Filter:\t%s\n", dryRunValue(d.req.Filter, "none, all the entries"))
+	fmt.Fprintf(tw, "  query:\t%s\n", dryRunValue(d.query, "none"))
+	if d.alwaysFilter != "" {
+		source := "config file"
+		if d.profile != "" {
+			source = "profile " + d.profile
+		}
+		fmt.Fprintf(tw, "  alwaysFilter:\t%s (%s)\n", d.alwaysFilter, source)
+	}
+	switch window := d.window(now); {
+	case window.Default:
+		fmt.Fprintf(tw, "  window:\t%s >= %q (default of the last 24 hours, as the filter has no timestamp clause)\n", fieldTimestamp, window.From)
+	case window.From != "":
+		fmt.Fprintf(tw, "  window:\t%s from %s to %s\n", windowField, window.From, dryRunValue(window.To, "now"))
+	default:
+		fmt.Fprintf(tw, "  window:\tnone added, the filter mentions timestamp\n")
+	}
+
+	resources := strings.Join(d.req.ResourceNames, ", ")
+	if len(d.includeBuckets) > 0 {
+		resources = fmt.Sprintf("the _AllLogs views of the buckets %s, listed when fetching", strings.Join(d.includeBuckets, ", "))
+	}
+	fmt.Fprintf(tw, "Resource names:\t%s\n", resources)
+	fmt.Fprintf(tw, "Page size:\t%d\n", d.req.PageSize)
+	fmt.Fprintf(tw, "Order:\t%s\n", dryRunValue(d.req.OrderBy, fieldTimestamp+" asc"))
+	return tw.Flush()
+}
+
+// dryRunValue returns s, or the fallback describing its absence when empty
+func dryRunValue(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
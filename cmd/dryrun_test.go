@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+func TestWriteDryRun(t *testing.T) {
+	now := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	from := now.Add(-time.Hour)
+
+	cases := []struct {
+		name     string
+		req      *loggingpb.ListLogEntriesRequest
+		filter   string
+		from, to time.Time
+		expected string
+	}{
+		{
+			"explicit window",
+			&loggingpb.ListLogEntriesRequest{ResourceNames: []string{"projects/p"}, Filter: `severity>=ERROR AND timestamp >= "2025-01-01T23:00:00Z"`, OrderBy: "timestamp desc", PageSize: 1000},
+			`severity>=ERROR AND timestamp >= "2025-01-01T23:00:00Z"`,
+			from, time.Time{},
+			`{"request":{"resourceNames":["projects/p"],"filter":"severity>=ERROR AND timestamp >= \"2025-01-01T23:00:00Z\"","orderBy":"timestamp desc","pageSize":1000},"window":{"from":"2025-01-01T23:00:00Z"},"resourceNames":["projects/p"]}`,
+		},
+		{
+			"default window",
+			&loggingpb.ListLogEntriesRequest{ResourceNames: []string{"projects/p/locations/global/buckets/b/views/_AllLogs"}, Filter: `severity>=ERROR AND timestamp >= "2025-01-01T00:00:00Z"`},
+			`severity>=ERROR`,
+			time.Time{}, time.Time{},
+			`{"request":{"resourceNames":["projects/p/locations/global/buckets/b/views/_AllLogs"],"filter":"severity>=ERROR AND timestamp >= \"2025-01-01T00:00:00Z\""},"window":{"from":"2025-01-01T00:00:00Z","default":true},"resourceNames":["projects/p/locations/global/buckets/b/views/_AllLogs"]}`,
+		},
+		{
+			"window in the filter",
+			&loggingpb.ListLogEntriesRequest{ResourceNames: []string{"projects/p"}, Filter: `timestamp > "2024-01-01"`},
+			`timestamp > "2024-01-01"`,
+			time.Time{}, time.Time{},
+			`{"request":{"resourceNames":["projects/p"],"filter":"timestamp > \"2024-01-01\""},"window":{},"resourceNames":["projects/p"]}`,
+		},
+	}
+	for _, c := range cases {
+		var b strings.Builder
+		run := &dryRunPlan{req: c.req, filter: c.filter, from: c.from, to: c.to}
+		if err := run.writeJSON(&b, now); err != nil {
+			t.Fatalf("%s: writeJSON() unexpected error: %v", c.name, err)
+		}
+		if got := strings.TrimSuffix(b.String(), "\n"); got != c.expected {
+			t.Errorf("%s: writeJSON() = %s, want %s", c.name, got, c.expected)
+		}
+	}
+}
+
+func TestDryRunText(t *testing.T) {
+	now := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	run := &dryRunPlan{
+		req: &loggingpb.ListLogEntriesRequest{
+			ResourceNames: []string{"projects/p"},
+			Filter:        `(severity>=ERROR) AND (-logName:"healthz") AND timestamp >= "2025-01-01T00:00:00Z"`,
+			OrderBy:       "timestamp desc",
+			PageSize:      1000,
+		},
+		filter:       `(severity>=ERROR) AND (-logName:"healthz")`,
+		query:        "severity>=ERROR",
+		alwaysFilter: `-logName:"healthz"`,
+		profile:      "prod",
+	}
+	expected := `Filter:          (severity>=ERROR) AND (-logName:"healthz") AND timestamp >= "2025-01-01T00:00:00Z"
+  query:         severity>=ERROR
+  alwaysFilter:  -logName:"healthz" (profile prod)
+  window:        timestamp >= "2025-01-01T00:00:00Z" (default of the last 24 hours, as the filter has no timestamp clause)
+Resource names:  projects/p
+Page size:       1000
+Order:           timestamp desc
+`
+	var b strings.Builder
+	if err := run.writeText(&b, now); err != nil || b.String() != expected {
+		t.Errorf("writeText() = %s, %v, want %s", b.String(), err, expected)
+	}
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/dippi/grapple/pkg/grapple"
+	"google.golang.org/grpc/metadata"
+)
+
+// countFieldMask limits the entries listed by countEntries to the fields fetchAndProcessLogs needs
+// to resume after an expired page token, with the X-Goog-FieldMask system parameter
+const countFieldMask = "nextPageToken,entries.insertId,entries.logName,entries.timestamp,entries.receiveTimestamp"
+
+// maxCountPages is how many pages --confirm-over lists at most. Beyond them, the number of entries
+// is extrapolated from the part of the time window the pages cover.
+const maxCountPages = 10
+
+// countEntries counts the entries matching opts, stopping as soon as there are more than limit,
+// and returns the time (in windowField) of the last one counted.
+// The API cannot count: the entries are listed like by the query, in pages of --page-size with
+// its retries, each page costing a read request of the quota, but without their payloads.
+func countEntries(ctx context.Context, client *logadmin.Client, opts []logadmin.EntriesOption, limit int) (int, time.Time, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-goog-fieldmask", countFieldMask)
+	count := 0
+	var last time.Time
+	_, err := fetchAndProcessLogs(ctx, client, opts, func(entry *loggingpb.LogEntry) error {
+		if t, ok := windowTime(entry, windowField); ok {
+			last = t
+		}
+		if count++; count > limit {
+			return grapple.ErrStop
+		}
+		return nil
+	})
+	return count, last, err
+}
+
+// extrapolateCount returns how many entries there are between from and to at the rate of the count
+// ones listed from one end of the window, newest first from to or oldest first from from, until last
+func extrapolateCount(count int, last, from, to time.Time, newestFirst bool) int {
+	covered := last.Sub(from)
+	if newestFirst {
+		covered = to.Sub(last)
+	}
+	// Entries all within the same second are a burst, the rate of which says little: assume the worst.
+	covered = max(covered, time.Second)
+	if window := to.Sub(from); window > covered {
+		return int(float64(count) * float64(window) / float64(covered))
+	}
+	return count
+}
+
+// confirmEstimate checks the size of the query of the window from-to against the --confirm-over
+// threshold, asking whether to go on when it is exceeded, or failing when nobody can answer.
+// At most maxCountPages pages are listed: when the threshold is larger, the count of the entries
+// listed is a lower bound, extrapolated to the whole window.
+func confirmEstimate(ctx context.Context, client *logadmin.Client, opts []logadmin.EntriesOption, from, to time.Time, newestFirst bool, threshold int) error {
+	if threshold <= 0 {
+		return nil
+	}
+
+	log.Printf("Estimating the number of matching entries...")
+	limit := min(threshold, maxCountPages*pageSize)
+	count, last, err := countEntries(ctx, client, opts, limit)
+	if err != nil {
+		return fmt.Errorf("estimating the number of entries: %w", err)
+	}
+	if count <= limit || limit == threshold {
+		return checkEstimate(count, threshold, false, stdinIsTerminal(), confirm)
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.IsZero() {
+		// The default window of the Logging API.
+		from = to.Add(-24 * time.Hour)
+	}
+	estimate := extrapolateCount(count, last, from, to, newestFirst)
+	log.Printf("Listed the first %d entries, about %d over the whole time window", count, estimate)
+	return checkEstimate(estimate, threshold, true, stdinIsTerminal(), confirm)
+}
+
+// checkEstimate lets a query matching count entries, about that many when extrapolated, go on when
+// they are at most threshold or when the question asked interactively is confirmed
+func checkEstimate(count, threshold int, extrapolated, interactive bool, ask func(question string) bool) error {
+	if count <= threshold {
+		return nil
+	}
+	matches := fmt.Sprintf("more than %d entries", threshold)
+	if extrapolated {
+		matches = fmt.Sprintf("about %d entries, extrapolated from the first ones, more than %d", count, threshold)
+	}
+	if !interactive {
+		return fmt.Errorf("the query matches %s (--confirm-over), aborting", matches)
+	}
+	if !ask(fmt.Sprintf("The query matches %s, fetch them all?", matches)) {
+		return errors.New("aborted")
+	}
+	return nil
+}
+
+// stdinIsTerminal reports whether questions can be answered interactively
+func stdinIsTerminal() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeLogging serves pages of 2 entries, the last one being pages
+type fakeLogging struct {
+	loggingpb.UnimplementedLoggingServiceV2Server
+	pages     int
+	requests  []*loggingpb.ListLogEntriesRequest
+	fieldMask []string
+}
+
+func (f *fakeLogging) ListLogEntries(ctx context.Context, req *loggingpb.ListLogEntriesRequest) (*loggingpb.ListLogEntriesResponse, error) {
+	f.requests = append(f.requests, req)
+	md, _ := metadata.FromIncomingContext(ctx)
+	f.fieldMask = md.Get("x-goog-fieldmask")
+	page := len(f.requests)
+	resp := &loggingpb.ListLogEntriesResponse{}
+	for i := 0; i < 2; i++ {
+		resp.Entries = append(resp.Entries, &loggingpb.LogEntry{InsertId: fmt.Sprintf("%d-%d", page, i)})
+	}
+	if page < f.pages {
+		resp.NextPageToken = fmt.Sprint(page)
+	}
+	return resp, nil
+}
+
+func newFakeLogadminClient(t *testing.T, server *fakeLogging) *logadmin.Client {
+	return newFakeClient(t, func(s *grpc.Server) { loggingpb.RegisterLoggingServiceV2Server(s, server) })
+}
+
+// newFakeClient returns a client of the services registered by register on a local server
+func newFakeClient(t *testing.T, register func(*grpc.Server)) *logadmin.Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	register(s)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := logadmin.NewClient(context.Background(), "p", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestCountEntries(t *testing.T) {
+	defer func(size int) { pageSize = size }(pageSize)
+	pageSize = 2
+
+	server := &fakeLogging{pages: 5}
+	client := newFakeLogadminClient(t, server)
+	count, _, err := countEntries(context.Background(), client, []logadmin.EntriesOption{logadmin.Filter("severity>=ERROR")}, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Counting stops in the second page, once there are more entries than the threshold.
+	if count != 4 || len(server.requests) != 2 {
+		t.Errorf("countEntries() = %d after %d requests, want 4 after 2", count, len(server.requests))
+	}
+	if req := server.requests[0]; req.PageSize != 2 || !strings.HasPrefix(req.Filter, "severity>=ERROR") {
+		t.Errorf("request %+v, want the --page-size and the filter of the query", req)
+	}
+	if strings.Join(server.fieldMask, ",") != countFieldMask {
+		t.Errorf("field mask %v, want %s", server.fieldMask, countFieldMask)
+	}
+
+	server = &fakeLogging{pages: 2}
+	client = newFakeLogadminClient(t, server)
+	if count, _, err := countEntries(context.Background(), client, nil, 10); err != nil || count != 4 {
+		t.Errorf("countEntries() under the threshold = %d, %v, want all the 4 entries", count, err)
+	}
+}
+
+func TestConfirmEstimateCapped(t *testing.T) {
+	defer func(size int) { pageSize = size }(pageSize)
+	pageSize = 2
+
+	// The entries have no timestamps: as far as the count knows, they cover the whole window.
+	server := &fakeLogging{pages: 100}
+	client := newFakeLogadminClient(t, server)
+	if err := confirmEstimate(context.Background(), client, nil, time.Time{}, time.Time{}, true, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.requests) != maxCountPages+1 {
+		t.Errorf("confirmEstimate() listed %d pages, want %d", len(server.requests), maxCountPages+1)
+	}
+}
+
+func TestExtrapolateCount(t *testing.T) {
+	to := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	from := to.Add(-24 * time.Hour)
+	cases := []struct {
+		last        time.Time
+		newestFirst bool
+		expected    int
+	}{
+		{to.Add(-time.Hour), true, 2400},
+		{from.Add(6 * time.Hour), false, 400},
+		{to, true, 100 * 24 * 3600},
+		{from, true, 100},
+	}
+	for _, c := range cases {
+		if got := extrapolateCount(100, c.last, from, to, c.newestFirst); got != c.expected {
+			t.Errorf("extrapolateCount(100, %s, newestFirst=%v) = %d, want %d", c.last, c.newestFirst, got, c.expected)
+		}
+	}
+}
+
+func TestCheckEstimate(t *testing.T) {
+	var asked []string
+	ask := func(answer bool) func(string) bool {
+		return func(question string) bool {
+			asked = append(asked, question)
+			return answer
+		}
+	}
+
+	if err := checkEstimate(10, 10, false, false, ask(false)); err != nil || len(asked) != 0 {
+		t.Errorf("checkEstimate() at the threshold = %v after asking %v, want nil without asking", err, asked)
+	}
+	if err := checkEstimate(11, 10, false, false, ask(true)); err == nil || !strings.Contains(err.Error(), "--confirm-over") || len(asked) != 0 {
+		t.Errorf("checkEstimate() over the threshold, non-interactive = %v after asking %v, want an error without asking", err, asked)
+	}
+	if err := checkEstimate(11, 10, false, true, ask(true)); err != nil || len(asked) != 1 {
+		t.Errorf("checkEstimate() confirmed = %v, want nil after asking once", err)
+	}
+	if err := checkEstimate(11, 10, false, true, ask(false)); err == nil || err.Error() != "aborted" {
+		t.Errorf("checkEstimate() declined = %v, want aborted", err)
+	}
+	if err := checkEstimate(5000, 10, true, false, ask(true)); err == nil || !strings.Contains(err.Error(), "about 5000 entries, extrapolated") {
+		t.Errorf("checkEstimate() extrapolated = %v, want an error with the estimate", err)
+	}
+}
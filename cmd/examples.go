@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// example is a recipe printed by the examples command and in the help of its command
+type example struct {
+	title   string
+	command *cobra.Command
+	// args are the filter and flags following the name of the command.
+	args []string
+}
+
+// examples are the recipes for common scenarios, grouped by command
+var examples = []example{
+	{"Errors of a GKE namespace in the last hour", rootCmd,
+		[]string{"--gke-cluster", "prod", "--namespace", "checkout", "--freshness", "1h", "severity>=ERROR"}},
+	{"Follow the errors of a Cloud Run service", rootCmd,
+		[]string{"--cloud-run-service", "checkout", "--watch", "severity>=ERROR"}},
+	{"Who deleted a bucket, from the Admin Activity audit logs", rootCmd,
+		[]string{"--audit=admin", "--freshness", "30d", "--format", "audit", `protoPayload.methodName:"delete" AND protoPayload.resourceName:"my-bucket"`}},
+	{"5xx responses of the external load balancers in the last hour", rootCmd,
+		[]string{"--freshness", "1h", "--format", "http", `resource.type="http_load_balancer" AND httpRequest.status>=500`}},
+	{"Fail a CI check when errors appeared in the last 10 minutes", rootCmd,
+		[]string{"--exit-status", "--freshness", "10m", "--format", "json", "severity>=ERROR"}},
+	{"Export a day of logs, oldest first, as gzipped JSON lines", rootCmd,
+		[]string{"--from", "2024-05-01", "--to", "2024-05-02", "--order", "asc", "-o", "logs.ndjson.gz"}},
+	{"All the entries of a request across services, by its trace ID", traceCmd,
+		[]string{"4bf92f3577b34da6a3ce929d0e0e4736"}},
+	{"The most frequent error messages of the last day", topCmd,
+		[]string{"--freshness", "1d", "severity>=ERROR"}},
+	{"Errors per hour of the last day, split by severity", histogramCmd,
+		[]string{"--freshness", "1d", "--interval", "1h", "--by-severity", "severity>=WARNING"}},
+	{"Post to Slack when more than 5 errors are logged in 5 minutes", alertCmd,
+		[]string{"--threshold", "5", "--window", "5m", "--webhook", "https://hooks.slack.com/services/T000/B000/XXXX", "severity>=ERROR"}},
+	{"When an error message first appeared in the last 30 days", firstCmd,
+		[]string{`textPayload:"connection reset by peer"`}},
+}
+
+var examplesCmd = &cobra.Command{
+	Use:   "examples [keyword]",
+	Short: "Print recipes for common scenarios",
+	Long: `Print copy-pasteable command lines for common scenarios, like the errors of a
+GKE namespace or who deleted a resource. With a keyword, print only the ones
+whose title or command line contains it, ignoring case.`,
+	Example: `  grapple examples audit`,
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		keyword := ""
+		if len(args) > 0 {
+			keyword = args[0]
+		}
+		cobra.CheckErr(writeExamples(stdout, examples, keyword))
+	},
+}
+
+// commandLine returns the example as a shell command line
+func (e example) commandLine() string {
+	words := []string{cliName}
+	if e.command != rootCmd {
+		words = append(words, e.command.Name())
+	}
+	for _, arg := range e.args {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+// examplesHelp formats the examples as the Example of a command, indented as cobra expects
+func examplesHelp(examples []example) string {
+	var b strings.Builder
+	for i, e := range examples {
+		if i > 0 {
+			b.WriteString("\n\n")
+		}
+		fmt.Fprintf(&b, "  # %s\n  %s", e.title, e.commandLine())
+	}
+	return b.String()
+}
+
+// writeExamples prints the examples whose title or command line contains the keyword
+func writeExamples(w io.Writer, examples []example, keyword string) error {
+	keyword = strings.ToLower(keyword)
+	var matched []example
+	for _, e := range examples {
+		if strings.Contains(strings.ToLower(e.title+"\n"+e.commandLine()), keyword) {
+			matched = append(matched, e)
+		}
+	}
+	if len(matched) == 0 {
+		return fmt.Errorf("no examples about %q", keyword)
+	}
+	for i, e := range matched {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		if _, err := fmt.Fprintf(w, "# %s\n%s\n", e.title, e.commandLine()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	byCommand := map[*cobra.Command][]example{}
+	for _, e := range examples {
+		byCommand[e.command] = append(byCommand[e.command], e)
+	}
+	for c, examples := range byCommand {
+		c.Example = examplesHelp(examples)
+	}
+
+	rootCmd.AddCommand(examplesCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/dippi/grapple/internal/lql"
+	"github.com/spf13/pflag"
+)
+
+// TestExamples parses the arguments of every example with the flags of its command,
+// and the filter it composes with the Logging query language parser
+func TestExamples(t *testing.T) {
+	for _, e := range examples {
+		t.Run(e.title, func(t *testing.T) {
+			flags := e.command.Flags()
+			flags.AddFlagSet(rootCmd.PersistentFlags())
+			defer resetFlags(flags)
+
+			if err := flags.Parse(e.args); err != nil {
+				t.Fatalf("%s: %v", e.commandLine(), err)
+			}
+			if err := e.command.ValidateArgs(flags.Args()); err != nil {
+				t.Fatalf("%s: %v", e.commandLine(), err)
+			}
+			if !strings.Contains(e.command.Use, "[filter]") {
+				return
+			}
+
+			filter := ""
+			if flags.NArg() > 0 {
+				filter = flags.Arg(0)
+			}
+			filter, err := composeFilter(e.command, filter)
+			if err != nil {
+				t.Fatalf("%s: %v", e.commandLine(), err)
+			}
+			from, to, err := determineTimeWindow(e.command)
+			if err != nil {
+				t.Fatalf("%s: %v", e.commandLine(), err)
+			}
+			if _, err := lql.Parse(buildFilter(from, to, filter)); err != nil {
+				t.Errorf("%s: invalid filter: %v", e.commandLine(), err)
+			}
+		})
+	}
+}
+
+// resetFlags restores the default values of the flags, for the next example of the same command
+func resetFlags(flags *pflag.FlagSet) {
+	flags.VisitAll(func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	})
+}
+
+func TestWriteExamples(t *testing.T) {
+	examples := []example{
+		{"Errors of the day", rootCmd, []string{"--freshness", "1d", "severity>=ERROR"}},
+		{"Audit logs", traceCmd, []string{"abc"}},
+	}
+	var b strings.Builder
+	if err := writeExamples(&b, examples, "FRESHNESS"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "# Errors of the day\ngrapple --freshness 1d 'severity>=ERROR'\n"; b.String() != expected {
+		t.Errorf("writeExamples() = %q, want %q", b.String(), expected)
+	}
+	if err := writeExamples(&b, examples, "nope"); err == nil {
+		t.Error("writeExamples() without matches expected error, got nil")
+	}
+
+	if help, expected := examplesHelp(examples), "  # Errors of the day\n  grapple --freshness 1d 'severity>=ERROR'\n\n  # Audit logs\n  grapple trace abc"; help != expected {
+		t.Errorf("examplesHelp() = %q, want %q", help, expected)
+	}
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// annotationWritesOutput marks the commands writing --output themselves, rather than through stdout, see openOutput
+const annotationWritesOutput = "writesOutput"
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export entries to other storage systems",
+	Long: `Export the entries matching a filter to other storage systems, with the
+same flags selecting and fetching the entries as the queries. The flags printing
+them, like --format or --tail, do not apply.`,
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+}
@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// parseFields splits a --fields value like "timestamp,jsonPayload.message" into paths
+func parseFields(fields []string) [][]string {
+	var paths [][]string
+	for _, field := range fields {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		paths = append(paths, strings.Split(field, "."))
+	}
+	return paths
+}
+
+// entryToMap converts a log entry into its generic JSON representation, as printed by default
+func entryToMap(entry *loggingpb.LogEntry) (map[string]any, error) {
+	jsonBytes, err := protojson.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(jsonBytes))
+	decoder.UseNumber()
+	var m map[string]any
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// entryFields converts a log entry into its generic JSON representation with the virtual fields
+// computed by grapple, @geo, @ua and @ingest_delay, for looking up fields by path
+func entryFields(entry *loggingpb.LogEntry) (map[string]any, error) {
+	m, err := entryToMap(entry)
+	if err != nil {
+		return nil, err
+	}
+	addLabelField(m, "@geo", geoLabelPrefix)
+	addLabelField(m, "@ua", userAgentLabelPrefix)
+	if delay, ok := ingestDelay(entry); ok {
+		m["@ingest_delay"] = json.Number(strconv.FormatFloat(delay.Seconds(), 'f', 3, 64))
+	}
+	return m, nil
+}
+
+// ingestDelay returns how long after its timestamp the entry was received by Cloud Logging,
+// negative for timestamps in the future
+func ingestDelay(entry *loggingpb.LogEntry) (time.Duration, bool) {
+	if entry.Timestamp == nil || entry.ReceiveTimestamp == nil {
+		return 0, false
+	}
+	return entry.ReceiveTimestamp.AsTime().Sub(entry.Timestamp.AsTime()), true
+}
+
+// fieldValue returns the value at path as a string, JSON encoded unless it is a string,
+// and "-" if it is missing
+func fieldValue(m map[string]any, path []string) (string, error) {
+	value, ok := lookupPath(m, path)
+	if !ok {
+		return "-", nil
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return marshalJSONValue(value)
+}
+
+// projectFields returns a copy of obj containing only the given paths, keeping their nesting.
+// Paths missing from obj are skipped.
+func projectFields(obj map[string]any, paths [][]string) map[string]any {
+	projected := map[string]any{}
+	for _, path := range paths {
+		value, ok := lookupPath(obj, path)
+		if !ok {
+			continue
+		}
+		target := projected
+		for _, key := range path[:len(path)-1] {
+			next, ok := target[key].(map[string]any)
+			if !ok {
+				next = map[string]any{}
+				target[key] = next
+			}
+			target = next
+		}
+		target[path[len(path)-1]] = value
+	}
+	return projected
+}
+
+// lookupPath walks nested JSON objects following path
+func lookupPath(obj map[string]any, path []string) (any, bool) {
+	var current any = obj
+	for _, key := range path {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// marshalJSONValue encodes a generic value as a single JSON line, without the trailing newline
+func marshalJSONValue(v any) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProjectFields(t *testing.T) {
+	entry := map[string]any{
+		"timestamp": "2025-01-02T15:04:05Z",
+		"severity":  "ERROR",
+		"jsonPayload": map[string]any{
+			"message": "boom",
+			"extra":   "noise",
+		},
+		"labels": map[string]any{
+			"pod_name": "web-1",
+		},
+		"textPayload": "ignored",
+	}
+
+	got := projectFields(entry, parseFields([]string{"timestamp", " severity", "jsonPayload.message", "labels.pod_name", "resource.type", "severity.nested", ""}))
+	expected := map[string]any{
+		"timestamp": "2025-01-02T15:04:05Z",
+		"severity":  "ERROR",
+		"jsonPayload": map[string]any{
+			"message": "boom",
+		},
+		"labels": map[string]any{
+			"pod_name": "web-1",
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("projectFields() = %v, want %v", got, expected)
+	}
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+func TestAndFilters(t *testing.T) {
+	cases := []struct {
+		input    []string
+		expected string
+	}{
+		{nil, ""},
+		{[]string{"", " "}, ""},
+		{[]string{`severity>=ERROR`, ""}, `severity>=ERROR`},
+		{[]string{"", `NOT logName:"health"`}, `NOT logName:"health"`},
+		{[]string{`a OR b`, `NOT c`}, `(a OR b) AND (NOT c)`},
+	}
+
+	for _, c := range cases {
+		if got := andFilters(c.input...); got != c.expected {
+			t.Errorf("andFilters(%q) = %q, want %q", c.input, got, c.expected)
+		}
+	}
+}
+
+func TestTraceFilter(t *testing.T) {
+	cases := []struct {
+		trace    string
+		expected string
+	}{
+		{"4bf92f3577b34da6a3ce929d0e0e4736", `trace="projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736"`},
+		{"projects/other/traces/abc", `trace="projects/other/traces/abc"`},
+	}
+
+	for _, c := range cases {
+		if got := traceFilter("my-project", c.trace); got != c.expected {
+			t.Errorf("traceFilter(%q) = %q, want %q", c.trace, got, c.expected)
+		}
+	}
+
+	if isTraceReference("1a2b3c4d5e") {
+		t.Error("isTraceReference() should not accept an insertId")
+	}
+}
+
+func TestBuildFilter(t *testing.T) {
+	from := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	labels, err := labelClauses("labels", []string{"env=prod", `k8s-pod/app=web "v2"`}, true)
+	if err != nil {
+		t.Fatalf("labelClauses() unexpected error: %v", err)
+	}
+	resourceLabels, err := labelClauses("resource.labels", []string{"cluster_name=main=1", "k8s.io/zone=a"}, false)
+	if err != nil {
+		t.Fatalf("labelClauses() unexpected error: %v", err)
+	}
+
+	cases := []struct {
+		from, to time.Time
+		filter   string
+		clauses  []string
+		expected string
+	}{
+		{time.Time{}, time.Time{}, "", nil, ""},
+		{time.Time{}, time.Time{}, "severity>=ERROR", nil, "severity>=ERROR"},
+		{from, to, "", nil, `timestamp >= "2025-01-02T00:00:00Z" AND timestamp <= "2025-01-02T01:00:00Z"`},
+		{from, to, "severity>=ERROR", nil, `(severity>=ERROR) AND timestamp >= "2025-01-02T00:00:00Z" AND timestamp <= "2025-01-02T01:00:00Z"`},
+		{
+			time.Time{}, time.Time{}, "severity>=ERROR", append(labels, resourceLabels...),
+			`(severity>=ERROR) AND (labels."env"="prod") AND (labels."k8s-pod/app"="web \"v2\"") AND (resource.labels.cluster_name="main=1") AND (resource.labels."k8s.io/zone"="a")`,
+		},
+	}
+
+	for _, c := range cases {
+		if got := buildFilter(c.from, c.to, c.filter, c.clauses...); got != c.expected {
+			t.Errorf("buildFilter(%q, %q) = %q, want %q", c.filter, c.clauses, got, c.expected)
+		}
+	}
+
+	if _, err := labelClauses("labels", []string{"novalue"}, true); err == nil {
+		t.Error("labelClauses() without = expected error, got nil")
+	}
+}
+
+func TestBucketViews(t *testing.T) {
+	buckets := []*loggingpb.LogBucket{
+		{Name: "projects/p/locations/global/buckets/_Default"},
+		{Name: "projects/p/locations/global/buckets/_Required"},
+		{Name: "projects/p/locations/eu/buckets/analytics"},
+		{Name: "projects/p/locations/us/buckets/analytics"},
+	}
+
+	got, err := bucketViews([]string{"_Default", "analytics"}, buckets)
+	if err != nil {
+		t.Fatalf("bucketViews() unexpected error: %v", err)
+	}
+	expected := []string{
+		"projects/p/locations/global/buckets/_Default/views/_AllLogs",
+		"projects/p/locations/eu/buckets/analytics/views/_AllLogs",
+		"projects/p/locations/us/buckets/analytics/views/_AllLogs",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("bucketViews() = %v, want %v", got, expected)
+	}
+
+	_, err = bucketViews([]string{"audit"}, buckets)
+	if err == nil || err.Error() != `unknown bucket "audit" in --include-buckets, available buckets: _Default, _Required, analytics` {
+		t.Errorf("bucketViews(audit) error = %v, want the available buckets", err)
+	}
+}
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/iterator"
+)
+
+// defaultSearchWindow is how far back first and last search without an explicit time window,
+// the retention of the _Default bucket
+const defaultSearchWindow = 30 * 24 * time.Hour
+
+// searchPrecision is the size of the window the search narrows down to before fetching the entry
+const searchPrecision = time.Minute
+
+var firstCmd = &cobra.Command{
+	Use:   "first [filter]",
+	Short: "Find the oldest entry matching a filter",
+	Long: `Find the oldest entry matching a filter, within the time window (the last
+30 days, the default retention, unless --from/--to or --freshness are given).
+
+Instead of scanning the whole window, it is bisected with cheap existence
+checks, which answers "when did this error start?" much faster.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runEdgeSearch(cmd, args, false)
+	},
+}
+
+var lastCmd = &cobra.Command{
+	Use:   "last [filter]",
+	Short: "Find the newest entry matching a filter",
+	Long: `Find the newest entry matching a filter, within the time window (the last
+30 days, the default retention, unless --from/--to or --freshness are given).
+
+The window is searched backwards with growing steps from its end, then
+bisected, so recent entries are found with a handful of cheap queries.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runEdgeSearch(cmd, args, true)
+	},
+}
+
+// runEdgeSearch prints the oldest, or with newest the newest, entry matching the filter and the flags
+func runEdgeSearch(cmd *cobra.Command, args []string, newest bool) {
+	projectId := requireProject()
+
+	userFilter := ""
+	if len(args) > 0 {
+		userFilter = args[0]
+	}
+	filter, err := composeFilter(cmd, userFilter)
+	cobra.CheckErr(err)
+
+	from, to, err := determineTimeWindow(cmd)
+	cobra.CheckErr(err)
+	if from.IsZero() {
+		to = time.Now()
+		from = to.Add(-defaultSearchWindow)
+	}
+
+	ctx := cmd.Context()
+
+	client, err := newLogadminClient(ctx, projectId)
+	cobra.CheckErr(err)
+	defer client.Close()
+
+	baseOpts := []logadmin.EntriesOption{logadmin.PageSize(1)}
+	views, err := determineViews(ctx, cmd, client)
+	cobra.CheckErr(err)
+	if len(views) > 0 {
+		baseOpts = append(baseOpts, logadmin.ResourceNames(views))
+	}
+
+	// edge returns the oldest, or newest, entry in a window, nil if there is none
+	queries := 0
+	edge := func(from, to time.Time, newest bool) (*loggingpb.LogEntry, error) {
+		queries++
+		opts := append(slices.Clone(baseOpts), logadmin.Filter(andFilters(filter, windowFilter(from, to))))
+		if newest {
+			opts = append(opts, logadmin.NewestFirst())
+		}
+		entry, err := client.Entries(ctx, opts...).Next()
+		if errors.Is(err, iterator.Done) {
+			return nil, nil
+		}
+		return entry, err
+	}
+	exists := func(from, to time.Time) (bool, error) {
+		entry, err := edge(from, to, newest)
+		return entry != nil, err
+	}
+
+	lo, hi, found, err := searchEdge(ctx, exists, from, to, newest, searchPrecision)
+	cobra.CheckErr(err)
+	if !found {
+		cobra.CheckErr(fmt.Errorf("no entries between %s and %s", from.Format(time.RFC3339), to.Format(time.RFC3339)))
+	}
+
+	entry, err := edge(lo, hi, newest)
+	cobra.CheckErr(err)
+	if entry == nil {
+		// The entries of the window expired in the meantime.
+		cobra.CheckErr(errors.New("the entry disappeared during the search, try again"))
+	}
+	log.Printf("Found with %d queries", queries)
+
+	process, flush, err := newEntryPrinter(cmd)
+	cobra.CheckErr(err)
+	cobra.CheckErr(process(entry))
+	cobra.CheckErr(flush())
+}
+
+// windowFilter returns the clause matching the entries between from and to, both included
+func windowFilter(from, to time.Time) string {
+	return fmt.Sprintf("timestamp >= %q AND timestamp <= %q", from.UTC().Format(time.RFC3339Nano), to.UTC().Format(time.RFC3339Nano))
+}
+
+// searchEdge narrows [from, to] down to a window of at most precision containing the oldest,
+// or with newest the newest, time for which exists reports entries
+func searchEdge(ctx context.Context, exists func(from, to time.Time) (bool, error), from, to time.Time, newest bool, precision time.Duration) (lo, hi time.Time, found bool, err error) {
+	lo, hi = from, to
+
+	if newest {
+		// Look back from the end with doubling steps, most searches are about recent entries.
+		step := precision
+		for {
+			start := hi.Add(-step)
+			if start.Before(from) {
+				start = from
+			}
+			if found, err = exists(start, hi); err != nil || found {
+				lo = start
+				break
+			}
+			if !start.After(from) {
+				return lo, hi, false, nil
+			}
+			hi = start
+			step *= 2
+		}
+	} else if found, err = exists(lo, hi); !found {
+		return lo, hi, false, err
+	}
+	if err != nil {
+		return lo, hi, false, err
+	}
+
+	for hi.Sub(lo) > precision {
+		if err := ctx.Err(); err != nil {
+			return lo, hi, false, err
+		}
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if newest {
+			found, err = exists(mid, hi)
+			if found {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		} else {
+			found, err = exists(lo, mid)
+			if found {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		if err != nil {
+			return lo, hi, false, err
+		}
+	}
+	return lo, hi, true, nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{firstCmd, lastCmd} {
+		addFilterFlags(c)
+		addFormatFlags(c)
+		rootCmd.AddCommand(c)
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSearchEdge(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(30 * 24 * time.Hour)
+	entries := []time.Time{from.Add(50 * time.Hour), from.Add(51 * time.Hour), to.Add(-3 * time.Hour)}
+
+	queries := 0
+	exists := func(lo, hi time.Time) (bool, error) {
+		queries++
+		for _, ts := range entries {
+			if !ts.Before(lo) && !ts.After(hi) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	cases := []struct {
+		newest   bool
+		expected time.Time
+	}{
+		{false, entries[0]},
+		{true, entries[2]},
+	}
+	for _, c := range cases {
+		queries = 0
+		lo, hi, found, err := searchEdge(context.Background(), exists, from, to, c.newest, time.Minute)
+		if err != nil || !found {
+			t.Fatalf("searchEdge(newest=%v) = %v, %v", c.newest, found, err)
+		}
+		if c.expected.Before(lo) || c.expected.After(hi) || hi.Sub(lo) > time.Minute {
+			t.Errorf("searchEdge(newest=%v) = [%s, %s], want a minute around %s", c.newest, lo, hi, c.expected)
+		}
+		if queries > 30 {
+			t.Errorf("searchEdge(newest=%v) took %d queries", c.newest, queries)
+		}
+	}
+
+	entries = nil
+	for _, newest := range []bool{false, true} {
+		if _, _, found, err := searchEdge(context.Background(), exists, from, to, newest, time.Minute); found || err != nil {
+			t.Errorf("searchEdge(newest=%v) without entries = %v, %v", newest, found, err)
+		}
+	}
+}
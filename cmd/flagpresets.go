@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+const presetsKey = "presets"
+
+//go:embed flag_presets.yaml
+var flagPresetsData []byte
+
+// flagPreset is a named bundle of a filter and flags, selected with --preset
+type flagPreset struct {
+	Description string            `yaml:"description"`
+	Filter      string            `yaml:"filter"`
+	Flags       map[string]string `yaml:"flags"`
+}
+
+// builtinFlagPresets are the presets shipped with grapple, keyed by name
+var builtinFlagPresets = mustLoadFlagPresets(flagPresetsData)
+
+func mustLoadFlagPresets(data []byte) map[string]flagPreset {
+	presets := map[string]flagPreset{}
+	if err := yaml.Unmarshal(data, &presets); err != nil {
+		panic(fmt.Sprintf("invalid built-in presets: %v", err))
+	}
+	return presets
+}
+
+var presetsCmd = &cobra.Command{
+	Use:   "presets",
+	Short: "List the presets of --preset",
+	Long: `List the presets of --preset, the built-in ones and those of the presets
+key of the config file, which replace the built-in ones with the same name:
+
+  presets:
+    payments:
+      description: Errors of the payments service
+      filter: severity>=ERROR
+      flags:
+        cloud-run-service: payments
+        freshness: 1h`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		presets, err := flagPresets(viper.GetViper())
+		cobra.CheckErr(err)
+		cobra.CheckErr(writeFlagPresets(stdout, presets))
+	},
+}
+
+// flagPresets returns the built-in presets merged with the ones of the config file
+func flagPresets(v *viper.Viper) (map[string]flagPreset, error) {
+	presets := maps.Clone(builtinFlagPresets)
+	if !v.IsSet(presetsKey) {
+		return presets, nil
+	}
+	// The flag values are decoded as strings whatever their YAML type, e.g. true or 2.
+	data, err := yaml.Marshal(v.Get(presetsKey))
+	if err != nil {
+		return nil, err
+	}
+	configured := map[string]flagPreset{}
+	if err := yaml.Unmarshal(data, &configured); err != nil {
+		return nil, fmt.Errorf("invalid %s in the config file: %w", presetsKey, err)
+	}
+	maps.Copy(presets, configured)
+	return presets, nil
+}
+
+// applyFlagPresets sets the flags of the named presets that are not given on the command line,
+// returning their filters ANDed. Two presets setting the same flag to different values conflict.
+func applyFlagPresets(cmd *cobra.Command, presets map[string]flagPreset, names []string) (string, error) {
+	values := map[string]string{}
+	setBy := map[string]string{}
+	var filters []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		preset, ok := presets[name]
+		if !ok {
+			return "", fmt.Errorf("unknown preset %q, valid values: %s", name, strings.Join(slices.Sorted(maps.Keys(presets)), ", "))
+		}
+		for flag, value := range preset.Flags {
+			if cmd.Flags().Lookup(flag) == nil {
+				return "", fmt.Errorf("preset %q sets --%s, which %s does not have", name, flag, cmd.CommandPath())
+			}
+			if previous, ok := values[flag]; ok && previous != value {
+				return "", fmt.Errorf("presets %q and %q conflict: --%s %s and --%s %s", setBy[flag], name, flag, previous, flag, value)
+			}
+			values[flag], setBy[flag] = value, name
+		}
+		filters = append(filters, preset.Filter)
+	}
+
+	for _, flag := range slices.Sorted(maps.Keys(values)) {
+		if cmd.Flags().Changed(flag) {
+			continue
+		}
+		if err := cmd.Flags().Set(flag, values[flag]); err != nil {
+			return "", fmt.Errorf("preset %q: invalid --%s: %w", setBy[flag], flag, err)
+		}
+	}
+	return andFilters(filters...), nil
+}
+
+func writeFlagPresets(w io.Writer, presets map[string]flagPreset) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range slices.Sorted(maps.Keys(presets)) {
+		preset := presets[name]
+		var args []string
+		if preset.Filter != "" {
+			args = append(args, shellQuote(preset.Filter))
+		}
+		for _, flag := range slices.Sorted(maps.Keys(preset.Flags)) {
+			args = append(args, "--"+flag, shellQuote(preset.Flags[flag]))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, preset.Description, strings.Join(args, " "))
+	}
+	return tw.Flush()
+}
+
+func init() {
+	rootCmd.AddCommand(presetsCmd)
+}
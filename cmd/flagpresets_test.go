@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func TestBuiltinFlagPresetsAreValid(t *testing.T) {
+	cmd := &cobra.Command{Use: "grapple"}
+	addQueryFlags(cmd)
+	for name, preset := range builtinFlagPresets {
+		if preset.Description == "" {
+			t.Errorf("preset %q must have a description", name)
+		}
+		for flag := range preset.Flags {
+			if cmd.Flags().Lookup(flag) == nil {
+				t.Errorf("preset %q sets the unknown flag --%s", name, flag)
+			}
+		}
+	}
+}
+
+func TestFlagPresetsFromConfig(t *testing.T) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	config := `
+presets:
+  export:
+    description: Mine
+    flags:
+      limit: 10
+      integrity-report: true
+`
+	if err := v.ReadConfig(strings.NewReader(config)); err != nil {
+		t.Fatal(err)
+	}
+	presets, err := flagPresets(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export := presets["export"]; export.Description != "Mine" || export.Flags["limit"] != "10" || export.Flags["integrity-report"] != "true" {
+		t.Errorf("config preset export = %+v, want it to replace the built-in one", export)
+	}
+	if _, ok := presets["incident"]; !ok {
+		t.Error("built-in preset incident missing")
+	}
+}
+
+func TestApplyFlagPresets(t *testing.T) {
+	presets := map[string]flagPreset{
+		"errors": {Filter: "severity>=ERROR", Flags: map[string]string{"freshness": "2h", "format": "text"}},
+		"run":    {Filter: `resource.type="cloud_run_revision"`, Flags: map[string]string{"format": "text", "limit": "50"}},
+		"json":   {Flags: map[string]string{"format": "json"}},
+	}
+	newCmd := func(args ...string) *cobra.Command {
+		cmd := &cobra.Command{Use: "grapple"}
+		addQueryFlags(cmd)
+		if err := cmd.ParseFlags(args); err != nil {
+			t.Fatal(err)
+		}
+		return cmd
+	}
+
+	cmd := newCmd("--freshness", "10m")
+	filter, err := applyFlagPresets(cmd, presets, []string{"errors", "run"})
+	if err != nil {
+		t.Fatalf("applyFlagPresets() unexpected error: %v", err)
+	}
+	if expected := `(severity>=ERROR) AND (resource.type="cloud_run_revision")`; filter != expected {
+		t.Errorf("applyFlagPresets() = %q, want %q", filter, expected)
+	}
+	for flag, expected := range map[string]string{"freshness": "10m", "format": "text", "limit": "50"} {
+		if value := cmd.Flag(flag).Value.String(); value != expected {
+			t.Errorf("--%s = %q, want %q", flag, value, expected)
+		}
+	}
+
+	if _, err := applyFlagPresets(newCmd(), presets, []string{"errors", "json"}); err == nil || !strings.Contains(err.Error(), "conflict") {
+		t.Errorf("applyFlagPresets() with conflicting presets = %v, want a conflict error", err)
+	}
+	if _, err := applyFlagPresets(newCmd(), presets, []string{"nope"}); err == nil {
+		t.Error("applyFlagPresets() with an unknown preset expected error, got nil")
+	}
+	bad := map[string]flagPreset{"bad": {Flags: map[string]string{"no-such-flag": "1"}}}
+	if _, err := applyFlagPresets(newCmd(), bad, []string{"bad"}); err == nil {
+		t.Error("applyFlagPresets() with an unknown flag expected error, got nil")
+	}
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var fmtCmd = &cobra.Command{
+	Use:   "fmt [FILE...]",
+	Short: "Print exported entries again with other formats",
+	Long: `Read entries exported as JSON, by grapple or by gcloud logging read --format
+json, from stdin or from files, and print them again with --format,
+--fields, --grep or --stats, as the queries would have, so that the entries
+fetched once can be rendered in other ways, e.g.
+
+  grapple fmt --format csv --columns timestamp,severity,textPayload < logs.ndjson
+  grapple fmt --stats --group-by resource.labels.namespace_name logs.ndjson.gz
+
+Files ending in .gz and .zst are decompressed. Unlike grapple local, stdin
+is read by default.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			args = []string{"-"}
+		}
+		printLocalFiles(cmd, args, &replayPacer{})
+	},
+}
+
+func init() {
+	addFormatFlags(fmtCmd)
+	addOutputFlags(fmtCmd)
+
+	rootCmd.AddCommand(fmtCmd)
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+)
+
+// Output formats for log entries.
+const (
+	formatJSON    = "json"
+	formatText    = "text"
+	formatK8s     = "k8s"
+	formatAudit   = "audit"
+	formatHTTP    = "http"
+	formatCSV     = "csv"
+	formatTSV     = "tsv"
+	formatParquet = "parquet"
+)
+
+// addFormatFlags registers the flags selecting how entries are printed, see newEntryPrinter
+func addFormatFlags(c *cobra.Command) {
+	c.Flags().String("format", "", "output format, valid values: text, json, audit, http, k8s, csv, tsv, parquet (default text on a terminal, json otherwise)")
+	c.Flags().Bool("no-color", false, "disable colors in the text format (also disabled by the NO_COLOR env var)")
+	addGrepFlags(c)
+	c.Flags().String("json-output", jsonOutputLines, "how the JSON formats separate the entries, valid values: lines (one JSON document per line), array (a single JSON array), seq (RFC 7464 JSON text sequences)")
+	c.Flags().Bool("json-proto-names", false, "name the fields of the JSON format as in the proto definitions (insert_id) rather than in lowerCamelCase (insertId)")
+	c.Flags().Bool("json-emit-defaults", false, "include the fields with default values in the JSON format")
+	c.Flags().Bool("json-enum-numbers", false, "print the enums of the JSON format, like the severity, as numbers")
+	c.Flags().Bool("stats", false, "print statistics about the entries (counts per severity, log, resource type and minute) instead of the entries")
+	c.Flags().StringSlice("group-by", nil, "with --stats, also count the entries per value of these comma-separated fields (e.g. @geo.country)")
+	c.Flags().StringSlice("columns", nil, fmt.Sprintf("columns of --format csv and tsv, as comma-separated fields (default %s)", strings.Join(defaultCSVColumns, ",")))
+	c.Flags().StringSlice("fields", nil, "only print these comma-separated fields (e.g. timestamp,severity,jsonPayload.message)")
+}
+
+// addGrepFlags registers the flags filtering the entries by their output, see newLinePipeline
+func addGrepFlags(c *cobra.Command) {
+	c.Flags().StringArray("grep", nil, "only print the entries whose output matches this regular expression, highlighting it on a terminal (repeatable)")
+	c.Flags().Bool("ignore-case", false, "match --grep case-insensitively")
+	c.Flags().Bool("invert", false, "only print the entries matching none of the --grep patterns")
+}
+
+// addReportFlags registers the flags of the commands printing a report of the entries rather than
+// the entries: its format and destination
+func addReportFlags(c *cobra.Command) {
+	c.Flags().String("format", "", "format of the report, valid values: text, json (default text on a terminal, json otherwise)")
+	addOutputFlags(c)
+}
+
+// newEntryPrinter returns the function printing each entry in the format selected by the flags,
+// skipping the entries rejected by --grep, and the function ending the output of --json-output array
+func newEntryPrinter(cmd *cobra.Command) (process func(*loggingpb.LogEntry) error, flush func() error, err error) {
+	framer := &outputFramer{}
+	process, err = newLinePipeline(cmd, func(_ *loggingpb.LogEntry, line string) error {
+		return framer.write(stdout, line)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if framer.mode, err = jsonOutputMode(cmd); err != nil {
+		return nil, nil, err
+	}
+	if framer.header, err = csvHeader(cmd); err != nil {
+		return nil, nil, err
+	}
+	return process, func() error { return framer.close(stdout) }, nil
+}
+
+// newLinePipeline returns the function rendering each entry in the format selected by the flags
+// and passing the entries accepted by --grep, with their line, to emit
+func newLinePipeline(cmd *cobra.Command, emit func(entry *loggingpb.LogEntry, line string) error) (func(*loggingpb.LogEntry) error, error) {
+	render, human, err := newEntryRenderer(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := jsonOutputMode(cmd); err != nil {
+		return nil, err
+	}
+	matcher, err := grepMatcherFromFlags(cmd)
+	if err != nil {
+		return nil, err
+	}
+	highlight := matcher != nil && human && useColor(cmd)
+
+	return func(entry *loggingpb.LogEntry) error {
+		line, err := render(entry)
+		if err != nil {
+			return err
+		}
+		if matcher != nil {
+			if !matcher.matches(line) {
+				counters.grepped++
+				return nil
+			}
+			if highlight {
+				line = matcher.highlight(line)
+			}
+		}
+		return emit(entry, line)
+	}, nil
+}
+
+// newGrepFilter returns the function reporting whether --grep accepts an entry, rendered as by
+// newLinePipeline, or nil without --grep
+func newGrepFilter(cmd *cobra.Command) (func(*loggingpb.LogEntry) (bool, error), error) {
+	if cmd.Flag("grep") == nil {
+		return nil, nil
+	}
+	matcher, err := grepMatcherFromFlags(cmd)
+	if err != nil || matcher == nil {
+		return nil, err
+	}
+	render, _, err := newEntryRenderer(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return func(entry *loggingpb.LogEntry) (bool, error) {
+		line, err := render(entry)
+		if err != nil {
+			return false, err
+		}
+		return matcher.matches(line), nil
+	}, nil
+}
+
+// newEntryRenderer returns the function formatting each entry as a single line, and whether
+// the format is meant for humans rather than programs
+func newEntryRenderer(cmd *cobra.Command) (func(*loggingpb.LogEntry) (string, error), bool, error) {
+	// The reports have neither --fields nor --columns, their entries are only rendered for --grep.
+	fields, _ := cmd.Flags().GetStringSlice("fields")
+	paths := parseFields(fields)
+	var err error
+	if outputLocation, err = timezone(); err != nil {
+		return nil, false, err
+	}
+	if jsonMarshalOptions, err = jsonMarshalOptionsFlags(cmd); err != nil {
+		return nil, false, err
+	}
+
+	columns, _ := cmd.Flags().GetStringSlice("columns")
+
+	format := entryFormat(cmd)
+	if len(columns) > 0 && format != formatCSV && format != formatTSV {
+		return nil, false, errors.New("--columns is only supported with --format csv and tsv")
+	}
+
+	switch format {
+	case formatJSON:
+		if len(paths) == 0 {
+			return func(entry *loggingpb.LogEntry) (string, error) {
+				jsonBytes, err := jsonMarshalOptions.Marshal(entry)
+				return string(jsonBytes), err
+			}, false, nil
+		}
+		return func(entry *loggingpb.LogEntry) (string, error) {
+			m, err := entryFields(entry)
+			if err != nil {
+				return "", err
+			}
+			return marshalJSONValue(projectFields(m, paths))
+		}, false, nil
+	case formatText, formatAudit, formatHTTP:
+		if len(paths) > 0 {
+			return nil, false, errors.New("--fields is only supported with --format json")
+		}
+		color := useColor(cmd)
+		render := renderText
+		switch format {
+		case formatAudit:
+			render = renderAudit
+		case formatHTTP:
+			render = renderHTTP
+		}
+		return func(entry *loggingpb.LogEntry) (string, error) {
+			return render(entry, color), nil
+		}, true, nil
+	case formatK8s:
+		if len(paths) > 0 {
+			return nil, false, errors.New("--fields is only supported with --format json")
+		}
+		return func(entry *loggingpb.LogEntry) (string, error) {
+			record, err := k8sRecord(entry)
+			if err != nil {
+				return "", err
+			}
+			return marshalJSONValue(record)
+		}, false, nil
+	case formatCSV, formatTSV:
+		if len(paths) > 0 {
+			return nil, false, fmt.Errorf("--fields is not supported with --format %s, use --columns", format)
+		}
+		return renderCSV(csvColumnPaths(columns), format == formatTSV), false, nil
+	case formatParquet:
+		return nil, false, errors.New("--format parquet only applies to the entries written by query and local")
+	default:
+		return nil, false, fmt.Errorf("invalid --format %q, valid values: %s, %s, %s, %s, %s, %s, %s, %s", format, formatJSON, formatText, formatAudit, formatHTTP, formatK8s, formatCSV, formatTSV, formatParquet)
+	}
+}
+
+// useColor reports whether the human-readable formats should be colored
+func useColor(cmd *cobra.Command) bool {
+	noColor, _ := cmd.Flags().GetBool("no-color")
+	return !noColor && os.Getenv("NO_COLOR") == "" && writesToTerminal(cmd)
+}
+
+// entryFormat returns the format of the entries selected by --format or the config, or the default one
+func entryFormat(cmd *cobra.Command) string {
+	if format := flagOrConfig(cmd, "format"); format != "" {
+		return format
+	}
+	fields, _ := cmd.Flags().GetStringSlice("fields")
+	return defaultFormat(cmd, len(parseFields(fields)) > 0)
+}
+
+// defaultFormat picks text (or audit, when fetching audit logs) for interactive sessions
+// and JSON lines for everything else
+func defaultFormat(cmd *cobra.Command, projected bool) string {
+	if !projected && writesToTerminal(cmd) {
+		if audit := cmd.Flag("audit"); audit != nil && audit.Value.String() != "" {
+			return formatAudit
+		}
+		return formatText
+	}
+	return formatJSON
+}
+
+// writesToTerminal reports whether the entries end up on an interactive terminal
+func writesToTerminal(cmd *cobra.Command) bool {
+	if output := cmd.Flag("output"); output != nil && output.Value.String() != "" && output.Value.String() != "-" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
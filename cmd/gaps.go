@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+)
+
+// gapFactor is how many times longer than the average interval between entries a gap must be to be suspicious
+const gapFactor = 50
+
+// minGap is the shortest gap ever reported, so that bursty low-volume logs don't raise false alarms
+const minGap = time.Minute
+
+// timeRange is a time interval, exclusive of both ends
+type timeRange struct {
+	from, to time.Time
+}
+
+// filter returns the filter clause matching the entries strictly inside the range, in windowField
+func (r timeRange) filter() string {
+	return fmt.Sprintf("%s > %q AND %s < %q", windowField, r.from.Format(time.RFC3339Nano), windowField, r.to.Format(time.RFC3339Nano))
+}
+
+// gapDetector collects the times of the fetched entries, in windowField like the time window of the
+// query, to look for suspicious holes in the stream
+type gapDetector struct {
+	timestamps []time.Time
+}
+
+func (d *gapDetector) observe(entry *loggingpb.LogEntry) {
+	if ts, ok := windowTime(entry, windowField); ok {
+		d.timestamps = append(d.timestamps, ts)
+	}
+}
+
+// gaps returns the intervals between consecutive entries that are much longer than expected
+// from the average rate of the stream, oldest first
+func (d *gapDetector) gaps() []timeRange {
+	if len(d.timestamps) < 3 {
+		return nil
+	}
+
+	timestamps := slices.Clone(d.timestamps)
+	slices.SortFunc(timestamps, func(a, b time.Time) int { return a.Compare(b) })
+
+	span := timestamps[len(timestamps)-1].Sub(timestamps[0])
+	threshold := max(span/time.Duration(len(timestamps)-1)*gapFactor, minGap)
+
+	var gaps []timeRange
+	for i := 1; i < len(timestamps); i++ {
+		if timestamps[i].Sub(timestamps[i-1]) > threshold {
+			gaps = append(gaps, timeRange{timestamps[i-1], timestamps[i]})
+		}
+	}
+	return gaps
+}
+
+// reportGaps logs the gaps found in the fetched stream, optionally fetching them again
+func reportGaps(ctx context.Context, client *logadmin.Client, opts []logadmin.EntriesOption, filter string, gaps []timeRange, refetch bool, process func(*loggingpb.LogEntry) error) (int, error) {
+	count := 0
+	for _, gap := range gaps {
+		log.Printf("Suspicious gap of %s between %s and %s", gap.to.Sub(gap.from).Round(time.Second), gap.from.Format(time.RFC3339), gap.to.Format(time.RFC3339))
+		if !refetch {
+			continue
+		}
+
+		// The last Filter option overrides the previous one.
+		gapOpts := append(slices.Clone(opts), logadmin.Filter(andFilters(filter, gap.filter())))
+		n, err := fetchAndProcessLogs(ctx, client, gapOpts, process)
+		count += n
+		if err != nil {
+			return count, err
+		}
+		log.Printf("Refetched %d entries between %s and %s", n, gap.from.Format(time.RFC3339), gap.to.Format(time.RFC3339))
+	}
+	return count, nil
+}
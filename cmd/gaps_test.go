@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestGapDetector(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	d := &gapDetector{}
+	// One entry per second for ten minutes, newest first, with nothing between 00:04 and 00:08.
+	for i := 600; i >= 0; i-- {
+		ts := start.Add(time.Duration(i) * time.Second)
+		if ts.After(start.Add(4*time.Minute)) && ts.Before(start.Add(8*time.Minute)) {
+			continue
+		}
+		d.observe(&loggingpb.LogEntry{Timestamp: timestamppb.New(ts)})
+	}
+
+	gaps := d.gaps()
+	if len(gaps) != 1 {
+		t.Fatalf("gaps() = %v, want a single gap", gaps)
+	}
+	if !gaps[0].from.Equal(start.Add(4*time.Minute)) || !gaps[0].to.Equal(start.Add(8*time.Minute)) {
+		t.Errorf("gaps() = %v, want 00:04 to 00:08", gaps)
+	}
+
+	expected := `timestamp > "2025-01-01T00:04:00Z" AND timestamp < "2025-01-01T00:08:00Z"`
+	if got := gaps[0].filter(); got != expected {
+		t.Errorf("filter() = %q, want %q", got, expected)
+	}
+}
+
+func TestGapDetectorSparse(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	d := &gapDetector{}
+	for _, offset := range []time.Duration{0, 10 * time.Second, 50 * time.Second, 55 * time.Second} {
+		d.observe(&loggingpb.LogEntry{Timestamp: timestamppb.New(start.Add(offset))})
+	}
+
+	if gaps := d.gaps(); len(gaps) != 0 {
+		t.Errorf("gaps() = %v, want none below the minimum gap", gaps)
+	}
+}
+
+func TestGapDetectorReceiveTimestamp(t *testing.T) {
+	defer func() { windowField = fieldTimestamp }()
+	windowField = fieldReceiveTimestamp
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	d := &gapDetector{}
+	// The producer clock is skewed by an hour, the gap is in the time of ingestion.
+	for i := 600; i >= 0; i-- {
+		received := start.Add(time.Duration(i) * time.Second)
+		if received.After(start.Add(4*time.Minute)) && received.Before(start.Add(8*time.Minute)) {
+			continue
+		}
+		d.observe(&loggingpb.LogEntry{Timestamp: timestamppb.New(received.Add(-time.Hour)), ReceiveTimestamp: timestamppb.New(received)})
+	}
+	gaps := d.gaps()
+	if len(gaps) != 1 {
+		t.Fatalf("gaps() = %v, want a single gap", gaps)
+	}
+	expected := `receiveTimestamp > "2025-01-01T00:04:00Z" AND receiveTimestamp < "2025-01-01T00:08:00Z"`
+	if got := gaps[0].filter(); got != expected {
+		t.Errorf("filter() = %q, want %q", got, expected)
+	}
+}
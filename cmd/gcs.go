@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// gcsScheme prefixes the --output paths of Cloud Storage objects
+const gcsScheme = "gs://"
+
+// gcsChunkSize is the size of the chunks of the resumable uploads, buffered in memory
+const gcsChunkSize = 16 << 20
+
+// parseGCSPath splits a gs://bucket/name path into its bucket and object name
+func parseGCSPath(path string) (bucket, name string, err error) {
+	bucket, name, _ = strings.Cut(strings.TrimPrefix(path, gcsScheme), "/")
+	if bucket == "" || name == "" {
+		return "", "", fmt.Errorf("invalid Cloud Storage path %q, expected gs://BUCKET/OBJECT or gs://BUCKET/PREFIX/", path)
+	}
+	return bucket, name, nil
+}
+
+// gcsUploader streams objects to Cloud Storage with resumable uploads, without local copies
+type gcsUploader struct {
+	ctx     context.Context
+	objects *storage.ObjectsService
+}
+
+func newGCSUploader(ctx context.Context) (*gcsUploader, error) {
+	opts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	service, err := storage.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &gcsUploader{ctx: ctx, objects: service.Objects}, nil
+}
+
+// create starts the upload of the object at path, complete once the returned writer is closed
+func (u *gcsUploader) create(path string) (io.WriteCloser, error) {
+	bucket, name, err := parseGCSPath(path)
+	if err != nil {
+		return nil, err
+	}
+	r, w := io.Pipe()
+	upload := &gcsUpload{PipeWriter: w, path: path, done: make(chan error, 1)}
+	go func() {
+		_, err := u.objects.Insert(bucket, &storage.Object{Name: name}).Media(r, googleapi.ChunkSize(gcsChunkSize)).Context(u.ctx).Do()
+		// Writes after a failure get its error.
+		r.CloseWithError(err)
+		upload.done <- err
+	}()
+	return upload, nil
+}
+
+// gcsUpload is an object being uploaded, through its pipe
+type gcsUpload struct {
+	*io.PipeWriter
+	path string
+	done chan error
+}
+
+func (u *gcsUpload) Close() error {
+	u.PipeWriter.Close()
+	if err := <-u.done; err != nil {
+		return fmt.Errorf("uploading %s: %w", u.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import "testing"
+
+func TestParseGCSPath(t *testing.T) {
+	tests := []struct {
+		path, bucket, name string
+		valid              bool
+	}{
+		{"gs://logs/exports/2025/", "logs", "exports/2025/", true},
+		{"gs://logs/entries.ndjson.gz", "logs", "entries.ndjson.gz", true},
+		{"gs://logs", "", "", false},
+		{"gs://logs/", "", "", false},
+		{"gs:///entries", "", "", false},
+	}
+	for _, test := range tests {
+		bucket, name, err := parseGCSPath(test.path)
+		if (err == nil) != test.valid || bucket != test.bucket || name != test.name {
+			t.Errorf("parseGCSPath(%q) = %q, %q, %v", test.path, bucket, name, err)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// defaultPayloadTemplate is the payload of the generated entries when no --template is given
+const defaultPayloadTemplate = `{"message": "synthetic entry {{.Seq}}"}`
+
+// generatorTick is how often the generator catches up with the requested rate
+const generatorTick = 100 * time.Millisecond
+
+var generateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Write synthetic log entries at a steady rate",
+	Long: `Write synthetic log entries at a steady rate, to load-test sinks, exclusions
+and downstream consumers. Entries are written to Cloud Logging, or to --output
+as JSON lines instead (use - for stdout).
+
+The payload is a JSON object rendered for each entry from a Go template
+(--template), which can use:
+  {{.Seq}}                the sequence number of the entry, from 1
+  {{.Timestamp}}          the timestamp of the entry, RFC3339
+  {{randInt 1 100}}       a random integer in [1, 100]
+  {{pick "GET" "POST"}}   one of the arguments at random
+
+Generated entries carry the label generator=grapple. Generation goes on
+until --count entries were written, --duration elapsed, or an interrupt.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		rate, err := parseRate(cmd.Flag("rate").Value.String())
+		cobra.CheckErr(err)
+		limit, err := cmd.Flags().GetInt("count")
+		cobra.CheckErr(err)
+		duration, err := cmd.Flags().GetDuration("duration")
+		cobra.CheckErr(err)
+		batchSize, err := cmd.Flags().GetInt("batch-size")
+		cobra.CheckErr(err)
+		if batchSize <= 0 {
+			cobra.CheckErr(fmt.Errorf("invalid --batch-size %d", batchSize))
+		}
+		severity, err := parseSeverity(cmd.Flag("severity").Value.String())
+		cobra.CheckErr(err)
+
+		templateText := defaultPayloadTemplate
+		if path := cmd.Flag("template").Value.String(); path != "" {
+			data, err := os.ReadFile(path)
+			cobra.CheckErr(err)
+			templateText = string(data)
+		}
+		tmpl, err := newPayloadTemplate(templateText)
+		cobra.CheckErr(err)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		if duration > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, duration)
+			defer cancel()
+		}
+
+		var write func([]*loggingpb.LogEntry) error
+		closeOutput := func() error { return nil }
+		if cmd.Flag("output").Value.String() != "" {
+			out, err := openOutput(cmd)
+			cobra.CheckErr(err)
+			closeOutput = out.Close
+			write = func(entries []*loggingpb.LogEntry) error {
+				for _, entry := range entries {
+					if err := printJSON(entry); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+		} else {
+			client, err := newLogadminClient(ctx, requireProject())
+			cobra.CheckErr(err)
+			defer client.Close()
+
+			logID := cmd.Flag("log").Value.String()
+			resource := &monitoredres.MonitoredResource{Type: cmd.Flag("resource-type").Value.String()}
+			write = func(entries []*loggingpb.LogEntry) error {
+				// The write must complete even when generation is being stopped.
+				return client.WriteEntries(context.WithoutCancel(ctx), logID, resource, entries)
+			}
+		}
+
+		started := time.Now()
+		written, err := generateEntries(ctx, rate, limit, batchSize, func(seq int, now time.Time) (*loggingpb.LogEntry, error) {
+			payload, err := renderPayload(tmpl, seq, now)
+			if err != nil {
+				return nil, err
+			}
+			return &loggingpb.LogEntry{
+				Timestamp: timestamppb.New(now),
+				Severity:  severity,
+				Labels:    map[string]string{"generator": cliName},
+				Payload:   &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+			}, nil
+		}, write)
+		elapsed := time.Since(started)
+		log.Printf("Wrote %d entries in %s (%.1f/s)", written, elapsed.Round(time.Millisecond), float64(written)/elapsed.Seconds())
+		cobra.CheckErr(errors.Join(err, closeOutput()))
+	},
+}
+
+// generateEntries creates entries at the given rate per second, writing them in batches,
+// until limit entries (when positive) were written or ctx is done
+func generateEntries(ctx context.Context, rate float64, limit, batchSize int, create func(seq int, now time.Time) (*loggingpb.LogEntry, error), write func([]*loggingpb.LogEntry) error) (int, error) {
+	started := time.Now()
+	written := 0
+
+	ticker := time.NewTicker(generatorTick)
+	defer ticker.Stop()
+	for {
+		due := int(time.Since(started).Seconds() * rate)
+		if limit > 0 {
+			due = min(due, limit)
+		}
+
+		for written < due {
+			batch := make([]*loggingpb.LogEntry, 0, min(batchSize, due-written))
+			for len(batch) < cap(batch) {
+				entry, err := create(written+len(batch)+1, time.Now())
+				if err != nil {
+					return written, err
+				}
+				batch = append(batch, entry)
+			}
+			if err := write(batch); err != nil {
+				return written, err
+			}
+			written += len(batch)
+		}
+
+		if limit > 0 && written >= limit {
+			return written, nil
+		}
+		select {
+		case <-ctx.Done():
+			return written, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// parseRate converts strings like "1000/s", "30/m", "10/h" or "5" (per second) into entries per second
+func parseRate(expression string) (float64, error) {
+	count, unit, _ := strings.Cut(expression, "/")
+	n, err := strconv.ParseFloat(count, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid --rate %q, expected e.g. 1000/s", expression)
+	}
+	switch unit {
+	case "", "s":
+		return n, nil
+	case "m":
+		return n / 60, nil
+	case "h":
+		return n / 3600, nil
+	default:
+		return 0, fmt.Errorf("invalid --rate %q, the unit must be s, m or h", expression)
+	}
+}
+
+// payloadData is what the payload templates are rendered with
+type payloadData struct {
+	Seq       int
+	Timestamp string
+}
+
+func newPayloadTemplate(text string) (*template.Template, error) {
+	return template.New("payload").Funcs(template.FuncMap{
+		"randInt": func(low, high int) int { return low + rand.IntN(high-low+1) },
+		"pick":    func(choices ...string) string { return choices[rand.IntN(len(choices))] },
+	}).Parse(text)
+}
+
+// renderPayload renders the payload of the seq-th entry, which must be a JSON object
+func renderPayload(tmpl *template.Template, seq int, now time.Time) (*structpb.Struct, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, payloadData{Seq: seq, Timestamp: now.UTC().Format(time.RFC3339Nano)}); err != nil {
+		return nil, err
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		return nil, fmt.Errorf("the template must render a JSON object: %w", err)
+	}
+	return structpb.NewStruct(fields)
+}
+
+func init() {
+	generateCmd.Flags().String("rate", "10/s", "entries to write per second (/s), minute (/m) or hour (/h)")
+	generateCmd.Flags().String("template", "", "file with the Go template of the JSON payload")
+	generateCmd.Flags().Int("count", 0, "stop after this many entries (0 for no limit)")
+	generateCmd.Flags().Duration("duration", 0, "stop after this long (0 for no limit)")
+	generateCmd.Flags().String("severity", "info", "severity of the entries")
+	generateCmd.Flags().String("log", cliName+"-generated", "ID of the log to write to")
+	generateCmd.Flags().String("resource-type", "global", "monitored resource type of the entries")
+	generateCmd.Flags().Int("batch-size", 500, "maximum number of entries per write request")
+	addOutputFlags(generateCmd)
+	generateCmd.MarkFlagFilename("template")
+
+	rootCmd.AddCommand(generateCmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+func TestParseRate(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected float64
+		wantErr  bool
+	}{
+		{"1000/s", 1000, false},
+		{"5", 5, false},
+		{"30/m", 0.5, false},
+		{"36/h", 0.01, false},
+		{"10/d", 0, true},
+		{"-1/s", 0, true},
+		{"many", 0, true},
+	}
+	for _, c := range cases {
+		got, err := parseRate(c.input)
+		if (err != nil) != c.wantErr || got != c.expected {
+			t.Errorf("parseRate(%q) = %v, %v, want %v (error %v)", c.input, got, err, c.expected, c.wantErr)
+		}
+	}
+}
+
+func TestRenderPayload(t *testing.T) {
+	tmpl, err := newPayloadTemplate(`{"message": "request {{.Seq}}", "method": "{{pick "GET"}}", "status": {{randInt 200 200}}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := renderPayload(tmpl, 7, time.Now())
+	if err != nil {
+		t.Fatalf("renderPayload() unexpected error: %v", err)
+	}
+	fields := payload.AsMap()
+	if fields["message"] != "request 7" || fields["method"] != "GET" || fields["status"] != 200.0 {
+		t.Errorf("renderPayload() = %v", fields)
+	}
+
+	tmpl, _ = newPayloadTemplate(`not json`)
+	if _, err := renderPayload(tmpl, 1, time.Now()); err == nil {
+		t.Error("renderPayload() of a non-JSON template succeeded")
+	}
+}
+
+func TestGenerateEntriesLimit(t *testing.T) {
+	var batches []int
+	written, err := generateEntries(context.Background(), 1000, 250, 100, func(seq int, now time.Time) (*loggingpb.LogEntry, error) {
+		return &loggingpb.LogEntry{}, nil
+	}, func(entries []*loggingpb.LogEntry) error {
+		batches = append(batches, len(entries))
+		return nil
+	})
+	if err != nil || written != 250 {
+		t.Fatalf("generateEntries() = %d, %v, want 250", written, err)
+	}
+	for _, n := range batches {
+		if n > 100 {
+			t.Errorf("generateEntries() wrote a batch of %d entries, over the batch size", n)
+		}
+	}
+}
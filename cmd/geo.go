@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/mmdb"
+	"github.com/spf13/cobra"
+)
+
+// geoLabelPrefix prefixes the labels set by --geoip-db, exposed as the @geo virtual field
+const geoLabelPrefix = "grapple.geo/"
+
+// geoEnricher annotates the entries with the location and network of httpRequest.remoteIp
+type geoEnricher struct {
+	readers []*mmdb.Reader
+}
+
+// newGeoEnricher returns the enricher of the --geoip-db databases, or nil if none is given
+func newGeoEnricher(cmd *cobra.Command) (*geoEnricher, error) {
+	paths, err := cmd.Flags().GetStringArray("geoip-db")
+	if err != nil || len(paths) == 0 {
+		return nil, err
+	}
+	e := &geoEnricher{}
+	for _, path := range paths {
+		r, err := mmdb.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		e.readers = append(e.readers, r)
+	}
+	return e, nil
+}
+
+// parseRemoteIP parses an IP address, possibly followed by a port
+func parseRemoteIP(s string) (netip.Addr, bool) {
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr, true
+	}
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr(), true
+	}
+	return netip.Addr{}, false
+}
+
+// annotate sets the grapple.geo/ labels of the entry: country, city, asn and as_org,
+// as far as the databases know them. The grapple.geo/ labels the entry already had are
+// dropped, so that producers cannot pass theirs off as the enrichment.
+func (e *geoEnricher) annotate(entry *loggingpb.LogEntry) error {
+	dropLabels(entry, geoLabelPrefix)
+	addr, ok := parseRemoteIP(entry.HttpRequest.GetRemoteIp())
+	if !ok {
+		return nil
+	}
+	for _, r := range e.readers {
+		record, err := r.Lookup(addr)
+		if err != nil {
+			return fmt.Errorf("looking up %s: %w", addr, err)
+		}
+		fields, _ := record.(map[string]any)
+		for name, path := range map[string][]string{
+			"country": {"country", "iso_code"},
+			"city":    {"city", "names", "en"},
+			"asn":     {"autonomous_system_number"},
+			"as_org":  {"autonomous_system_organization"},
+		} {
+			var value string
+			switch v, _ := lookupPath(fields, path); v := v.(type) {
+			case string:
+				value = v
+			case uint64:
+				value = strconv.FormatUint(v, 10)
+			default:
+				continue
+			}
+			if entry.Labels == nil {
+				entry.Labels = map[string]string{}
+			}
+			entry.Labels[geoLabelPrefix+name] = value
+		}
+	}
+	return nil
+}
+
+// process returns a process function annotating the entries before handing them to next
+func (e *geoEnricher) process(next func(*loggingpb.LogEntry) error) func(*loggingpb.LogEntry) error {
+	return func(entry *loggingpb.LogEntry) error {
+		if err := e.annotate(entry); err != nil {
+			return err
+		}
+		return next(entry)
+	}
+}
+
+// dropLabels deletes the labels with the prefix of an entry
+func dropLabels(entry *loggingpb.LogEntry, prefix string) {
+	for key := range entry.Labels {
+		if strings.HasPrefix(key, prefix) {
+			delete(entry.Labels, key)
+		}
+	}
+}
+
+// addLabelField copies the labels with the prefix of an entry, converted by entryToMap, to a virtual field
+func addLabelField(m map[string]any, field, prefix string) {
+	labels, _ := m["labels"].(map[string]any)
+	values := map[string]any{}
+	for key, value := range labels {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			values[name] = value
+		}
+	}
+	if len(values) > 0 {
+		m[field] = values
+	}
+}
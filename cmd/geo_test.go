@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+func TestParseRemoteIP(t *testing.T) {
+	cases := map[string]string{
+		"203.0.113.7":        "203.0.113.7",
+		"203.0.113.7:443":    "203.0.113.7",
+		"2001:db8::1":        "2001:db8::1",
+		"[2001:db8::1]:8080": "2001:db8::1",
+	}
+	for input, expected := range cases {
+		if addr, ok := parseRemoteIP(input); !ok || addr.String() != expected {
+			t.Errorf("parseRemoteIP(%q) = %s, %v, want %s", input, addr, ok, expected)
+		}
+	}
+	if _, ok := parseRemoteIP("unknown"); ok {
+		t.Error("parseRemoteIP(unknown) succeeded")
+	}
+}
+
+func TestGeoEnricherDropsLabels(t *testing.T) {
+	entry := &loggingpb.LogEntry{
+		HttpRequest: &logtypepb.HttpRequest{RemoteIp: "unknown"},
+		Labels:      map[string]string{geoLabelPrefix + "country": "XX", "env": "prod"},
+	}
+	if err := (&geoEnricher{}).annotate(entry); err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Labels) != 1 || entry.Labels["env"] != "prod" {
+		t.Errorf("labels %v, want the grapple.geo/ label of the producer dropped", entry.Labels)
+	}
+}
+
+func TestGroupByGeoField(t *testing.T) {
+	stats := newEntryStats("@geo.country", "httpRequest.status")
+	for _, country := range []string{"AU", "AU", "IT", ""} {
+		entry := &loggingpb.LogEntry{HttpRequest: &logtypepb.HttpRequest{Status: 200}}
+		if country != "" {
+			entry.Labels = map[string]string{geoLabelPrefix + "country": country, "env": "prod"}
+		}
+		if err := stats.observe(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byCountry := stats.report().ByField["@geo.country"]
+	if byCountry["AU"] != 2 || byCountry["IT"] != 1 || byCountry["-"] != 1 {
+		t.Errorf("by @geo.country = %v", byCountry)
+	}
+	if byStatus := stats.report().ByField["httpRequest.status"]; byStatus["200"] != 4 {
+		t.Errorf("by httpRequest.status = %v", byStatus)
+	}
+}
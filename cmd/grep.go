@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// ansiEscape matches the SGR sequences used to color the human-readable formats
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// grepMatcher filters the rendered entries client-side, for what the Logging filters can't express
+type grepMatcher struct {
+	patterns []*regexp.Regexp
+	invert   bool
+	// any matches any of the patterns, for highlighting them in a single pass
+	any *regexp.Regexp
+}
+
+// grepMatcherFromFlags compiles the --grep patterns, returning nil when there are none
+func grepMatcherFromFlags(cmd *cobra.Command) (*grepMatcher, error) {
+	patterns, err := cmd.Flags().GetStringArray("grep")
+	if err != nil {
+		return nil, err
+	}
+	ignoreCase, err := cmd.Flags().GetBool("ignore-case")
+	if err != nil {
+		return nil, err
+	}
+	invert, err := cmd.Flags().GetBool("invert")
+	if err != nil {
+		return nil, err
+	}
+	return newGrepMatcher(patterns, ignoreCase, invert)
+}
+
+func newGrepMatcher(patterns []string, ignoreCase, invert bool) (*grepMatcher, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	m := &grepMatcher{invert: invert}
+	var alternatives []string
+	for _, pattern := range patterns {
+		if ignoreCase {
+			pattern = "(?i)" + pattern
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		m.patterns = append(m.patterns, re)
+		alternatives = append(alternatives, "(?:"+pattern+")")
+	}
+	m.any = regexp.MustCompile(strings.Join(alternatives, "|"))
+	return m, nil
+}
+
+// matches reports whether a rendered line should be printed: when any pattern matches it,
+// or when none does with invert. Colors are ignored.
+func (m *grepMatcher) matches(line string) bool {
+	plain := ansiEscape.ReplaceAllString(line, "")
+	for _, re := range m.patterns {
+		if re.MatchString(plain) {
+			return !m.invert
+		}
+	}
+	return m.invert
+}
+
+// highlight shows the matches of the patterns in reverse video, leaving the existing colors untouched
+func (m *grepMatcher) highlight(line string) string {
+	if m.invert {
+		return line
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, loc := range ansiEscape.FindAllStringIndex(line, -1) {
+		b.WriteString(m.highlightPlain(line[last:loc[0]]))
+		b.WriteString(line[loc[0]:loc[1]])
+		last = loc[1]
+	}
+	b.WriteString(m.highlightPlain(line[last:]))
+	return b.String()
+}
+
+// highlightPlain highlights the matches in a piece of text without escape sequences
+func (m *grepMatcher) highlightPlain(s string) string {
+	return m.any.ReplaceAllStringFunc(s, func(match string) string {
+		if match == "" {
+			return match
+		}
+		return "\x1b[7m" + match + "\x1b[27m"
+	})
+}
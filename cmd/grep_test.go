@@ -0,0 +1,39 @@
+package cmd
+
+import "testing"
+
+func TestGrepMatcher(t *testing.T) {
+	m, err := newGrepMatcher([]string{"time(out)?", "refused"}, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	colored := "2025-01-02T15:04:05.000Z \x1b[31mERROR    \x1b[0m app connection Refused after TIMEOUT"
+	if !m.matches(colored) {
+		t.Errorf("matches(%q) = false", colored)
+	}
+	if m.matches("\x1b[31mERROR\x1b[0m all good") {
+		t.Error("matches() matched a line without the patterns")
+	}
+
+	expected := "2025-01-02T15:04:05.000Z \x1b[31mERROR    \x1b[0m app connection \x1b[7mRefused\x1b[27m after \x1b[7mTIMEOUT\x1b[27m"
+	if got := m.highlight(colored); got != expected {
+		t.Errorf("highlight() = %q, want %q", got, expected)
+	}
+
+	// The escape sequences themselves never match.
+	m, err = newGrepMatcher([]string{"31m"}, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.matches("\x1b[31mERROR\x1b[0m") {
+		t.Error("inverted matches() matched an escape sequence")
+	}
+
+	if m, err := newGrepMatcher(nil, false, false); m != nil || err != nil {
+		t.Errorf("newGrepMatcher() without patterns = %v, %v, want nil", m, err)
+	}
+	if _, err := newGrepMatcher([]string{"("}, false, false); err == nil {
+		t.Error("newGrepMatcher() with an invalid pattern succeeded")
+	}
+}
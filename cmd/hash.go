@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldHasher replaces fields of the entries with salted hashes, so that exports can be
+// analyzed by identity without exposing the identities
+type fieldHasher struct {
+	paths [][]string
+	salt  []byte
+}
+
+// newFieldHasher returns the hasher of --hash-fields, or nil if the flag is not set
+func newFieldHasher(cmd *cobra.Command) (*fieldHasher, error) {
+	fields, err := cmd.Flags().GetStringSlice("hash-fields")
+	if err != nil || len(fields) == 0 {
+		return nil, err
+	}
+	saltEnv := cmd.Flag("hash-salt-env").Value.String()
+	if saltEnv == "" {
+		return nil, fmt.Errorf("--hash-fields requires --hash-salt-env")
+	}
+	salt := os.Getenv(saltEnv)
+	if salt == "" {
+		// Unsalted hashes of low-entropy identifiers, like emails, are easily reversed.
+		return nil, fmt.Errorf("environment variable %s of --hash-salt-env is empty", saltEnv)
+	}
+	h := &fieldHasher{salt: []byte(salt)}
+	for _, field := range fields {
+		path := strings.Split(field, ".")
+		if err := checkHashPath(path); err != nil {
+			return nil, fmt.Errorf("invalid --hash-fields %s: %w", field, err)
+		}
+		h.paths = append(h.paths, path)
+	}
+	return h, nil
+}
+
+// checkHashPath checks that the hash of the field at path still fits the entries: a field of
+// jsonPayload, a label or a string field. The hashes of the other fields, like httpRequest.status
+// or timestamp, cannot be converted back to entries.
+func checkHashPath(path []string) error {
+	md := (&loggingpb.LogEntry{}).ProtoReflect().Descriptor()
+	for i, name := range path {
+		fd := md.Fields().ByJSONName(name)
+		if fd == nil {
+			return fmt.Errorf("unknown field %s", strings.Join(path[:i+1], "."))
+		}
+		last := i == len(path)-1
+		switch {
+		case fd.IsMap():
+			// labels and resource.labels map strings to strings.
+			if i != len(path)-2 || fd.MapValue().Kind() != protoreflect.StringKind {
+				return fmt.Errorf("expected a key of %s", strings.Join(path[:i+1], "."))
+			}
+			return nil
+		case fd.IsList():
+			return fmt.Errorf("%s is a list", strings.Join(path[:i+1], "."))
+		case fd.Kind() == protoreflect.MessageKind && fd.Message().FullName() == "google.protobuf.Struct":
+			if last {
+				return fmt.Errorf("expected a field of %s", name)
+			}
+			return nil
+		case last:
+			if fd.Kind() != protoreflect.StringKind {
+				return fmt.Errorf("%s is not a string field", strings.Join(path, "."))
+			}
+			return nil
+		case fd.Kind() != protoreflect.MessageKind || fd.Message().FullName() == "google.protobuf.Any":
+			return fmt.Errorf("the fields of %s cannot be hashed", strings.Join(path[:i+1], "."))
+		}
+		md = fd.Message()
+	}
+	return nil
+}
+
+// hash returns the hex encoded HMAC-SHA256 of the value
+func (h *fieldHasher) hash(value string) string {
+	mac := hmac.New(sha256.New, h.salt)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// apply returns a copy of the entry with the fields replaced by their hashes.
+// Values other than strings are hashed in their JSON form.
+func (h *fieldHasher) apply(entry *loggingpb.LogEntry) (*loggingpb.LogEntry, error) {
+	m, err := entryToMap(entry)
+	if err != nil {
+		return nil, err
+	}
+	changed := false
+	for _, path := range h.paths {
+		parent := m
+		if len(path) > 1 {
+			value, _ := lookupPath(m, path[:len(path)-1])
+			parent, _ = value.(map[string]any)
+		}
+		key := path[len(path)-1]
+		value, ok := parent[key]
+		if !ok {
+			continue
+		}
+		s, isString := value.(string)
+		if !isString {
+			if s, err = marshalJSONValue(value); err != nil {
+				return nil, err
+			}
+		}
+		parent[key] = h.hash(s)
+		changed = true
+	}
+	if !changed {
+		return entry, nil
+	}
+
+	data, err := marshalJSONValue(m)
+	if err != nil {
+		return nil, err
+	}
+	hashed := &loggingpb.LogEntry{}
+	if err := protojson.Unmarshal([]byte(data), hashed); err != nil {
+		return nil, fmt.Errorf("hashing fields: %w", err)
+	}
+	return hashed, nil
+}
+
+// process returns a process function hashing the fields of the entries before handing them to next
+func (h *fieldHasher) process(next func(*loggingpb.LogEntry) error) func(*loggingpb.LogEntry) error {
+	return func(entry *loggingpb.LogEntry) error {
+		hashed, err := h.apply(entry)
+		if err != nil {
+			return err
+		}
+		return next(hashed)
+	}
+}
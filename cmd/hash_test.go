@@ -0,0 +1,79 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestFieldHasher(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]any{"user_id": "u-42", "attempt": 3, "message": "login"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := &loggingpb.LogEntry{
+		InsertId: "1",
+		Labels:   map[string]string{"email": "jane@example.com", "env": "prod"},
+		Payload:  &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+	}
+
+	h := &fieldHasher{salt: []byte("pepper"), paths: [][]string{
+		{"jsonPayload", "user_id"}, {"jsonPayload", "attempt"}, {"labels", "email"}, {"jsonPayload", "missing", "deep"},
+	}}
+	hashed, err := h.apply(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := hashed.GetJsonPayload().GetFields()
+	if got := fields["user_id"].GetStringValue(); got != h.hash("u-42") || len(got) != 64 {
+		t.Errorf("jsonPayload.user_id = %q", got)
+	}
+	if got := fields["attempt"].GetStringValue(); got != h.hash("3") {
+		t.Errorf("jsonPayload.attempt = %q, want the hash of its JSON form", got)
+	}
+	if got := hashed.Labels["email"]; got != h.hash("jane@example.com") {
+		t.Errorf("labels.email = %q", got)
+	}
+	if fields["message"].GetStringValue() != "login" || hashed.Labels["env"] != "prod" || hashed.InsertId != "1" {
+		t.Errorf("other fields changed: %v", hashed)
+	}
+	if entry.Labels["email"] != "jane@example.com" {
+		t.Error("the original entry was modified")
+	}
+
+	other := &fieldHasher{salt: []byte("salt"), paths: h.paths}
+	if other.hash("u-42") == h.hash("u-42") {
+		t.Error("hashes don't depend on the salt")
+	}
+}
+
+func TestCheckHashPath(t *testing.T) {
+	cases := []struct {
+		field string
+		valid bool
+	}{
+		{"jsonPayload.user_id", true},
+		{"jsonPayload.user.email", true},
+		{"labels.email", true},
+		{"resource.labels.pod_name", true},
+		{"httpRequest.remoteIp", true},
+		{"trace", true},
+		{"jsonPayload", false},
+		{"labels", false},
+		{"labels.a.b", false},
+		{"httpRequest.status", false},
+		{"timestamp", false},
+		{"severity", false},
+		{"protoPayload.authenticationInfo.principalEmail", false},
+		{"httpRequest.missing", false},
+	}
+	for _, c := range cases {
+		err := checkHashPath(strings.Split(c.field, "."))
+		if (err == nil) != c.valid {
+			t.Errorf("checkHashPath(%s) = %v, want valid %v", c.field, err, c.valid)
+		}
+	}
+}
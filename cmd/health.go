@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthState tracks what the health endpoints report for long-running modes
+type healthState struct {
+	mu          sync.Mutex
+	started     time.Time
+	lastSuccess time.Time
+	lastError   error
+	// backlog is the number of entries of the current page left to process, progress the last
+	// time one was processed or a page fetched.
+	backlog  int
+	progress time.Time
+	draining bool
+	// interval is the --watch interval, 0 for a single fetch.
+	interval time.Duration
+}
+
+var health = &healthState{started: time.Now()}
+
+// stallWindow is how long the backlog can wait without any entry being processed, e.g. while the
+// output is blocked, before the process is not ready
+const stallWindow = time.Minute
+
+// minReadinessWindow is how recent the last successful API call must at least be to be considered ready
+const minReadinessWindow = 5 * time.Minute
+
+// readinessWindow is how recent the last successful API call must be to be considered ready, long
+// enough for a slow --watch interval to poll twice
+func readinessWindow(interval time.Duration) time.Duration {
+	return max(minReadinessWindow, 2*interval)
+}
+
+func (h *healthState) recordSuccess(backlog int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastSuccess = time.Now()
+	h.lastError = nil
+	h.backlog = backlog
+	h.progress = h.lastSuccess
+}
+
+func (h *healthState) recordError(err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastError = err
+}
+
+func (h *healthState) setBacklog(backlog int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.backlog = backlog
+	h.progress = time.Now()
+}
+
+func (h *healthState) setInterval(interval time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.interval = interval
+}
+
+func (h *healthState) setDraining() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.draining = true
+}
+
+type healthReport struct {
+	Status      string `json:"status"`
+	LastSuccess string `json:"lastSuccess,omitempty"`
+	LastError   string `json:"lastError,omitempty"`
+	Backlog     int    `json:"backlog"`
+}
+
+// report describes the current state. The process is live until it exits, draining included, and
+// ready when not draining, the API was reached recently and the entries fetched are being processed.
+// Draining only fails readiness, so that the orchestrator stops sending work without restarting
+// the process while it flushes the output.
+func (h *healthState) report(now time.Time) (live, ready bool, r healthReport) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	r.Backlog = h.backlog
+	if !h.lastSuccess.IsZero() {
+		r.LastSuccess = h.lastSuccess.Format(time.RFC3339)
+	}
+	if h.lastError != nil {
+		r.LastError = h.lastError.Error()
+	}
+
+	live = true
+	ready = !h.draining &&
+		!h.lastSuccess.IsZero() &&
+		now.Sub(h.lastSuccess) < readinessWindow(h.interval) &&
+		(h.backlog == 0 || now.Sub(h.progress) < stallWindow)
+
+	switch {
+	case h.draining:
+		r.Status = "draining"
+	case !ready:
+		r.Status = "not ready"
+	default:
+		r.Status = "ok"
+	}
+	return live, ready, r
+}
+
+func (h *healthState) handler(readiness bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		live, ready, report := h.report(time.Now())
+		ok := live
+		if readiness {
+			ok = ready
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if !ok {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(report)
+	}
+}
+
+// serveHealth exposes /healthz and /readyz on addr in the background, for a run polling at interval
+func serveHealth(addr string, interval time.Duration) error {
+	health.setInterval(interval)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", health.handler(false))
+	mux.HandleFunc("/readyz", health.handler(true))
+
+	go func() {
+		if err := http.Serve(listener, mux); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Error: health server stopped: %v", err)
+		}
+	}()
+	log.Printf("Serving health checks on %s", listener.Addr())
+	return nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+func TestHealthReport(t *testing.T) {
+	h := &healthState{started: time.Now()}
+	now := time.Now()
+
+	if live, ready, _ := h.report(now); !live || ready {
+		t.Errorf("before the first fetch: live=%v ready=%v, want live and not ready", live, ready)
+	}
+
+	h.recordSuccess(10)
+	if live, ready, r := h.report(now); !live || !ready || r.Status != "ok" {
+		t.Errorf("after a fetch: live=%v ready=%v status=%q, want ok", live, ready, r.Status)
+	}
+
+	h.recordError(errors.New("unavailable"))
+	if _, ready, r := h.report(now.Add(2 * minReadinessWindow)); ready || r.LastError != "unavailable" {
+		t.Errorf("after stale errors: ready=%v lastError=%q, want not ready with the error", ready, r.LastError)
+	}
+
+	h.recordSuccess(10)
+	if _, ready, _ := h.report(time.Now().Add(stallWindow)); ready {
+		t.Error("with a stalled backlog: want not ready")
+	}
+
+	h.setBacklog(0)
+	if _, ready, _ := h.report(time.Now().Add(stallWindow)); !ready {
+		t.Error("with an empty backlog: want ready")
+	}
+
+	h.setDraining()
+	if live, ready, r := h.report(time.Now()); !live || ready || r.Status != "draining" {
+		t.Errorf("while draining: live=%v ready=%v status=%q, want live and not ready", live, ready, r.Status)
+	}
+}
+
+func TestHealthReadinessWindow(t *testing.T) {
+	h := &healthState{started: time.Now()}
+	h.recordSuccess(0)
+	if _, ready, _ := h.report(time.Now().Add(10 * time.Minute)); ready {
+		t.Error("10 minutes after a single fetch: want not ready")
+	}
+
+	// Polling every 10 minutes, the last success is as old as one interval between polls.
+	h.setInterval(10 * time.Minute)
+	if _, ready, _ := h.report(time.Now().Add(10 * time.Minute)); !ready {
+		t.Error("10 minutes after a poll every 10 minutes: want ready")
+	}
+	if _, ready, _ := h.report(time.Now().Add(20 * time.Minute)); ready {
+		t.Error("20 minutes after a poll every 10 minutes: want not ready")
+	}
+
+	if got := readinessWindow(time.Minute); got != minReadinessWindow {
+		t.Errorf("readinessWindow(1m) = %s, want %s", got, minReadinessWindow)
+	}
+}
+
+func TestHealthBacklog(t *testing.T) {
+	defer func(h *healthState) { health = h }(health)
+	health = &healthState{started: time.Now()}
+	defer func(size int) { pageSize = size }(pageSize)
+	pageSize = 2
+
+	// The backlog is reported by the fetches: the entries of the page left after each one.
+	client := newFakeLogadminClient(t, &fakeLogging{pages: 1})
+	var backlogs []int
+	var stalled []bool
+	_, err := fetchAndProcessLogs(context.Background(), client, nil, func(*loggingpb.LogEntry) error {
+		_, ready, r := health.report(time.Now().Add(stallWindow))
+		backlogs = append(backlogs, r.Backlog)
+		stalled = append(stalled, !ready)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backlogs) != 2 || backlogs[0] != 2 || backlogs[1] != 1 || !stalled[0] || !stalled[1] {
+		t.Errorf("backlogs %v stalled %v while processing, want 2 and 1, stalled without progress", backlogs, stalled)
+	}
+	if _, ready, r := health.report(time.Now().Add(stallWindow)); !ready || r.Backlog != 0 {
+		t.Errorf("after the page: ready=%v backlog=%d, want ready with an empty backlog", ready, r.Backlog)
+	}
+}
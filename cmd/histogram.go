@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+// histogramWidth is the length of the longest bar of the histogram
+const histogramWidth = 60
+
+var histogramCmd = &cobra.Command{
+	Use:   "histogram [filter]",
+	Short: "Print a histogram of the matching entries over time",
+	Long: `Count the entries matching the filter in time intervals (--interval, 5
+minutes by default) and print the counts as an ASCII histogram, or as JSON
+lines with --format json. With --by-severity the bars are split by severity.
+
+It is the fastest way to spot when an incident started.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newHistogramSink)
+	},
+}
+
+// newHistogramSink returns the sink counting the entries per interval and printing the histogram
+func newHistogramSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	interval, err := parseFreshness(cmd.Flag("interval").Value.String())
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --interval: %w", err)
+	}
+	if interval <= 0 {
+		return nil, nil, fmt.Errorf("invalid --interval %s", interval)
+	}
+	bySeverity, err := cmd.Flags().GetBool("by-severity")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := &histogram{interval: interval, counts: map[time.Time]map[logtypepb.LogSeverity]int{}}
+	process, err := newLinePipeline(cmd, func(entry *loggingpb.LogEntry, _ string) error {
+		h.observe(entry)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format := flagOrConfig(cmd, "format")
+	if format == "" {
+		format = defaultFormat(cmd, false)
+	}
+	color := useColor(cmd)
+	return process, func() error {
+		if format == formatJSON {
+			return h.writeJSON(stdout, bySeverity)
+		}
+		return h.writeText(stdout, bySeverity, color)
+	}, nil
+}
+
+// histogram counts entries per time interval and severity
+type histogram struct {
+	interval time.Duration
+	counts   map[time.Time]map[logtypepb.LogSeverity]int
+}
+
+func (h *histogram) observe(entry *loggingpb.LogEntry) {
+	if entry.Timestamp == nil {
+		return
+	}
+	start := entry.Timestamp.AsTime().UTC().Truncate(h.interval)
+	if h.counts[start] == nil {
+		h.counts[start] = map[logtypepb.LogSeverity]int{}
+	}
+	h.counts[start][entry.Severity]++
+}
+
+// histogramBin is an interval of the histogram, the empty ones between the first and last are included
+type histogramBin struct {
+	Start      time.Time      `json:"start"`
+	Entries    int            `json:"entries"`
+	BySeverity map[string]int `json:"bySeverity,omitempty"`
+
+	severities map[logtypepb.LogSeverity]int
+}
+
+func (h *histogram) bins() []histogramBin {
+	if len(h.counts) == 0 {
+		return nil
+	}
+	starts := make([]time.Time, 0, len(h.counts))
+	for start := range h.counts {
+		starts = append(starts, start)
+	}
+	slices.SortFunc(starts, func(a, b time.Time) int { return a.Compare(b) })
+
+	var bins []histogramBin
+	for start := starts[0]; !start.After(starts[len(starts)-1]); start = start.Add(h.interval) {
+		bin := histogramBin{Start: start, severities: h.counts[start]}
+		for _, n := range bin.severities {
+			bin.Entries += n
+		}
+		bins = append(bins, bin)
+	}
+	return bins
+}
+
+func (h *histogram) writeJSON(w io.Writer, bySeverity bool) error {
+	for _, bin := range h.bins() {
+		if bySeverity {
+			bin.BySeverity = map[string]int{}
+			for severity, n := range bin.severities {
+				bin.BySeverity[severity.String()] = n
+			}
+		}
+		line, err := marshalJSONValue(bin)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeText prints a line per interval with its count and a bar, split by severity with
+// the initial of each severity (colored on a terminal) when bySeverity is set
+func (h *histogram) writeText(w io.Writer, bySeverity, color bool) error {
+	bins := h.bins()
+	peak, total := 0, 0
+	for _, bin := range bins {
+		peak = max(peak, bin.Entries)
+		total += bin.Entries
+	}
+	countWidth := len(fmt.Sprint(peak))
+
+	var b strings.Builder
+	for _, bin := range bins {
+		fmt.Fprintf(&b, "%s %*d ", bin.Start.In(outputLocation).Format(minuteLayout), countWidth, bin.Entries)
+		if !bySeverity {
+			b.WriteString(strings.Repeat("#", barLength(bin.Entries, peak)))
+		} else {
+			severities := make([]logtypepb.LogSeverity, 0, len(bin.severities))
+			for severity := range bin.severities {
+				severities = append(severities, severity)
+			}
+			// The most severe first, so that errors stand out at the start of the bars.
+			slices.SortFunc(severities, func(a, b logtypepb.LogSeverity) int { return int(b) - int(a) })
+			for _, severity := range severities {
+				segment := strings.Repeat(severity.String()[:1], barLength(bin.severities[severity], peak))
+				if code, ok := severityColors[severity]; ok && color && segment != "" {
+					segment = fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, segment)
+				}
+				b.WriteString(segment)
+			}
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%d entries, %s per line\n", total, h.interval)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// barLength scales a count to the histogram width, showing any non-zero count
+func barLength(n, peak int) int {
+	if n == 0 || peak == 0 {
+		return 0
+	}
+	return max(1, n*histogramWidth/peak)
+}
+
+func init() {
+	addFetchFlags(histogramCmd)
+	addReportFlags(histogramCmd)
+	addGrepFlags(histogramCmd)
+	histogramCmd.Flags().Bool("no-color", false, "disable colors in the text format (also disabled by the NO_COLOR env var)")
+	histogramCmd.Flags().String("interval", "5m", "length of the time intervals (e.g. 1m, 1h)")
+	histogramCmd.Flags().Bool("by-severity", false, "split the bars by severity")
+
+	rootCmd.AddCommand(histogramCmd)
+}
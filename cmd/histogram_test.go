@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestHistogram(t *testing.T) {
+	start := time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+	h := &histogram{interval: 5 * time.Minute, counts: map[time.Time]map[logtypepb.LogSeverity]int{}}
+	for _, e := range []struct {
+		offset   time.Duration
+		severity logtypepb.LogSeverity
+	}{
+		{time.Minute, logtypepb.LogSeverity_INFO},
+		{2 * time.Minute, logtypepb.LogSeverity_ERROR},
+		{12 * time.Minute, logtypepb.LogSeverity_INFO},
+	} {
+		h.observe(&loggingpb.LogEntry{Timestamp: timestamppb.New(start.Add(e.offset)), Severity: e.severity})
+	}
+
+	var b strings.Builder
+	if err := h.writeText(&b, true, false); err != nil {
+		t.Fatal(err)
+	}
+	expected := "2025-01-02T15:00Z 2 " + strings.Repeat("E", 30) + strings.Repeat("I", 30) + "\n" +
+		"2025-01-02T15:05Z 0 \n" +
+		"2025-01-02T15:10Z 1 " + strings.Repeat("I", 30) + "\n" +
+		"3 entries, 5m0s per line\n"
+	if b.String() != expected {
+		t.Errorf("writeText() =\n%s\nwant\n%s", b.String(), expected)
+	}
+
+	b.Reset()
+	if err := h.writeJSON(&b, false); err != nil {
+		t.Fatal(err)
+	}
+	if first := strings.SplitN(b.String(), "\n", 2)[0]; first != `{"start":"2025-01-02T15:00:00Z","entries":2}` {
+		t.Errorf("writeJSON() first line = %s", first)
+	}
+}
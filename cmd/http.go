@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// renderHTTP formats the request of an entry as a single line:
+// TIMESTAMP SEVERITY METHOD URL STATUS LATENCY SIZE "USER-AGENT"
+// Entries without httpRequest are rendered as in the text format.
+func renderHTTP(entry *loggingpb.LogEntry, color bool) string {
+	req := entry.HttpRequest
+	if req == nil {
+		return renderText(entry, color)
+	}
+
+	status := fmt.Sprintf("%d", req.Status)
+	if code := statusColor(req.Status); code != "" && color {
+		status = fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, status)
+	}
+
+	latency := "-"
+	if req.Latency != nil {
+		latency = req.Latency.AsDuration().Round(time.Millisecond).String()
+	}
+
+	userAgent := req.UserAgent
+	if userAgent == "" {
+		userAgent = "-"
+	}
+
+	return fmt.Sprintf("%s %s %s %s %s %s %q", renderPrefix(entry, color), req.RequestMethod, req.RequestUrl, status, latency, formatBytes(req.ResponseSize), userAgent)
+}
+
+// statusColor returns the ANSI SGR parameter for an HTTP status class, empty for successes
+func statusColor(status int32) string {
+	switch {
+	case status >= 500:
+		return "31"
+	case status >= 400:
+		return "33"
+	case status >= 300:
+		return "36"
+	default:
+		return ""
+	}
+}
+
+// formatBytes prints a size in bytes with a decimal unit, e.g. 1.5kB
+func formatBytes(n int64) string {
+	if n < 1000 {
+		return fmt.Sprintf("%dB", n)
+	}
+	value := float64(n)
+	for _, unit := range []string{"kB", "MB", "GB"} {
+		value /= 1000
+		if value < 1000 {
+			return fmt.Sprintf("%.1f%s", value, unit)
+		}
+	}
+	return fmt.Sprintf("%.1fTB", value/1000)
+}
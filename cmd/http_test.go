@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRenderHTTP(t *testing.T) {
+	entry := &loggingpb.LogEntry{
+		Timestamp: timestamppb.New(time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)),
+		Severity:  logtypepb.LogSeverity_WARNING,
+		HttpRequest: &logtypepb.HttpRequest{
+			RequestMethod: "GET",
+			RequestUrl:    "https://example.com/cart",
+			Status:        404,
+			Latency:       durationpb.New(123456 * time.Microsecond),
+			ResponseSize:  1530,
+			UserAgent:     "curl/8.0",
+		},
+	}
+
+	expected := `2025-01-02T15:04:05.000Z WARNING   GET https://example.com/cart 404 123ms 1.5kB "curl/8.0"`
+	if got := renderHTTP(entry, false); got != expected {
+		t.Errorf("renderHTTP() = %q, want %q", got, expected)
+	}
+
+	entry.HttpRequest = nil
+	entry.Payload = &loggingpb.LogEntry_TextPayload{TextPayload: "no request"}
+	if got, want := renderHTTP(entry, false), renderText(entry, false); got != want {
+		t.Errorf("renderHTTP() without httpRequest = %q, want %q", got, want)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[int64]string{0: "0B", 999: "999B", 1000: "1.0kB", 2_500_000: "2.5MB", 3_000_000_000_000: "3.0TB"}
+	for n, expected := range cases {
+		if got := formatBytes(n); got != expected {
+			t.Errorf("formatBytes(%d) = %q, want %q", n, got, expected)
+		}
+	}
+}
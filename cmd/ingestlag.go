@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+)
+
+var ingestLagCmd = &cobra.Command{
+	Use:   "ingest-lag [filter]",
+	Short: "Report how long the matching entries took to reach Cloud Logging",
+	Long: `Report the delay between the timestamp of the entries matching the filter and
+their receiveTimestamp, that is when Cloud Logging received them: the median,
+90th and 99th percentiles and the maximum, in total and per log, the slowest
+first. Large delays explain recent entries seemingly missing.
+
+--by groups by another field instead of the log, given as a path like in
+--fields (e.g. resource.type or resource.labels.cluster_name). The delay of
+each entry is also available as the @ingest_delay field, in seconds.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newIngestLagSink)
+	},
+}
+
+// ingestLags collects the ingestion delays of the entries per group
+type ingestLags struct {
+	all     []time.Duration
+	byGroup map[string][]time.Duration
+}
+
+// ingestLagRow is a line of the report, with the delays in seconds in JSON
+type ingestLagRow struct {
+	Group   string  `json:"group"`
+	Entries int     `json:"entries"`
+	P50     float64 `json:"p50"`
+	P90     float64 `json:"p90"`
+	P99     float64 `json:"p99"`
+	Max     float64 `json:"max"`
+}
+
+// newIngestLagSink returns the sink collecting the ingestion delays and printing their percentiles
+func newIngestLagSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	by := cmd.Flag("by").Value.String()
+	key := func(entry *loggingpb.LogEntry) (string, error) { return shortLogName(entry.LogName), nil }
+	if by != "log" {
+		path := strings.Split(by, ".")
+		key = func(entry *loggingpb.LogEntry) (string, error) {
+			m, err := entryFields(entry)
+			if err != nil {
+				return "", err
+			}
+			return fieldValue(m, path)
+		}
+	}
+
+	lags := &ingestLags{byGroup: map[string][]time.Duration{}}
+	process, err := newLinePipeline(cmd, func(entry *loggingpb.LogEntry, _ string) error {
+		delay, ok := ingestDelay(entry)
+		if !ok {
+			return nil
+		}
+		group, err := key(entry)
+		if err != nil {
+			return err
+		}
+		lags.all = append(lags.all, delay)
+		lags.byGroup[group] = append(lags.byGroup[group], delay)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format := flagOrConfig(cmd, "format")
+	if format == "" {
+		format = defaultFormat(cmd, false)
+	}
+	return process, func() error {
+		if format == formatJSON {
+			return writeIngestLagJSON(stdout, lags.report())
+		}
+		return writeIngestLagText(stdout, lags.report())
+	}, nil
+}
+
+// report returns the total row, then one per group, the largest 99th percentile first
+func (l *ingestLags) report() []ingestLagRow {
+	if len(l.all) == 0 {
+		return nil
+	}
+	rows := []ingestLagRow{ingestLagStats("total", l.all)}
+	var groups []ingestLagRow
+	for group, delays := range l.byGroup {
+		groups = append(groups, ingestLagStats(group, delays))
+	}
+	slices.SortFunc(groups, func(a, b ingestLagRow) int {
+		if a.P99 != b.P99 {
+			if a.P99 > b.P99 {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Group, b.Group)
+	})
+	return append(rows, groups...)
+}
+
+// ingestLagStats computes the percentiles of the delays, with the nearest-rank method
+func ingestLagStats(group string, delays []time.Duration) ingestLagRow {
+	sorted := slices.Clone(delays)
+	slices.Sort(sorted)
+	percentile := func(p int) float64 {
+		rank := (p*len(sorted) + 99) / 100
+		return sorted[max(rank, 1)-1].Seconds()
+	}
+	return ingestLagRow{
+		Group:   group,
+		Entries: len(sorted),
+		P50:     percentile(50),
+		P90:     percentile(90),
+		P99:     percentile(99),
+		Max:     sorted[len(sorted)-1].Seconds(),
+	}
+}
+
+func writeIngestLagText(w io.Writer, rows []ingestLagRow) error {
+	if len(rows) == 0 {
+		_, err := fmt.Fprintln(w, "No entries with both timestamp and receiveTimestamp")
+		return err
+	}
+	seconds := func(s float64) string {
+		return time.Duration(s * float64(time.Second)).Round(time.Millisecond).String()
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ENTRIES\tP50\tP90\tP99\tMAX\t GROUP")
+	for _, row := range rows {
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t %s\n", row.Entries, seconds(row.P50), seconds(row.P90), seconds(row.P99), seconds(row.Max), row.Group)
+	}
+	return tw.Flush()
+}
+
+func writeIngestLagJSON(w io.Writer, rows []ingestLagRow) error {
+	for _, row := range rows {
+		line, err := marshalJSONValue(row)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	addFetchFlags(ingestLagCmd)
+	addReportFlags(ingestLagCmd)
+	addGrepFlags(ingestLagCmd)
+	ingestLagCmd.Flags().String("by", "log", "group by the log or by this field (e.g. resource.type)")
+
+	rootCmd.AddCommand(ingestLagCmd)
+}
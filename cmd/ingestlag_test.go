@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestIngestDelayField(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	entry := &loggingpb.LogEntry{
+		Timestamp:        timestamppb.New(ts),
+		ReceiveTimestamp: timestamppb.New(ts.Add(1500 * time.Millisecond)),
+	}
+	m, err := entryFields(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := fieldValue(m, []string{"@ingest_delay"}); err != nil || value != "1.500" {
+		t.Errorf("@ingest_delay = %q, %v, want 1.500", value, err)
+	}
+
+	m, err = entryFields(&loggingpb.LogEntry{Timestamp: timestamppb.New(ts)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := fieldValue(m, []string{"@ingest_delay"}); value != "-" {
+		t.Errorf("@ingest_delay without receiveTimestamp = %q, want missing", value)
+	}
+}
+
+func TestIngestLagsReport(t *testing.T) {
+	lags := &ingestLags{byGroup: map[string][]time.Duration{}}
+	add := func(group string, delays ...time.Duration) {
+		lags.all = append(lags.all, delays...)
+		lags.byGroup[group] = append(lags.byGroup[group], delays...)
+	}
+	var fast []time.Duration
+	for i := 1; i <= 100; i++ {
+		fast = append(fast, time.Duration(i)*time.Millisecond)
+	}
+	add("stdout", fast...)
+	add("requests", 2*time.Second, 30*time.Second)
+
+	rows := lags.report()
+	if len(rows) != 3 || rows[0].Group != "total" || rows[1].Group != "requests" || rows[2].Group != "stdout" {
+		t.Fatalf("report() = %+v, want the total, then requests and stdout", rows)
+	}
+	if stdout := rows[2]; stdout.Entries != 100 || stdout.P50 != 0.05 || stdout.P90 != 0.09 || stdout.P99 != 0.099 || stdout.Max != 0.1 {
+		t.Errorf("report() stdout = %+v", stdout)
+	}
+	if requests := rows[1]; requests.P50 != 2 || requests.Max != 30 {
+		t.Errorf("report() requests = %+v", requests)
+	}
+
+	var b strings.Builder
+	if err := writeIngestLagText(&b, rows); err != nil {
+		t.Fatal(err)
+	}
+	expected := `  ENTRIES   P50   P90   P99    MAX GROUP
+      102  51ms  92ms    2s    30s total
+        2    2s   30s   30s    30s requests
+      100  50ms  90ms  99ms  100ms stdout
+`
+	if b.String() != expected {
+		t.Errorf("writeIngestLagText() =\n%s\nwant\n%s", b.String(), expected)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"cmp"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// maxIntegrityExamples is how many instances of each anomaly are detailed in the integrity report
+const maxIntegrityExamples = 10
+
+// insertIdKey identifies an entry by its log and insertId, which producers are expected not to reuse
+type insertIdKey struct {
+	logName, insertId string
+}
+
+// timestampRegression is an entry older than the one fetched before it in an ascending scan
+type timestampRegression struct {
+	key                 insertIdKey
+	timestamp, previous time.Time
+}
+
+// integrityChecker looks for duplicate insertIds within a log, which hint at double writes by the
+// producers, and, in ascending scans, for entries out of timestamp order
+type integrityChecker struct {
+	// ascending enables the check of timestamp regressions
+	ascending   bool
+	previous    time.Time
+	seen        map[insertIdKey]int
+	regressions []timestampRegression
+	entries     int
+}
+
+func newIntegrityChecker(ascending bool) *integrityChecker {
+	return &integrityChecker{ascending: ascending, seen: map[insertIdKey]int{}}
+}
+
+func (c *integrityChecker) observe(entry *loggingpb.LogEntry) {
+	c.entries++
+	key := insertIdKey{entry.LogName, entry.InsertId}
+	if key.insertId != "" {
+		c.seen[key]++
+	}
+	if !c.ascending || entry.Timestamp == nil {
+		return
+	}
+	timestamp := entry.Timestamp.AsTime()
+	if timestamp.Before(c.previous) {
+		c.regressions = append(c.regressions, timestampRegression{key, timestamp, c.previous})
+	} else {
+		c.previous = timestamp
+	}
+}
+
+// duplicates returns the insertIds seen more than once, sorted by log and insertId
+func (c *integrityChecker) duplicates() []insertIdKey {
+	var keys []insertIdKey
+	for key, n := range c.seen {
+		if n > 1 {
+			keys = append(keys, key)
+		}
+	}
+	slices.SortFunc(keys, func(a, b insertIdKey) int {
+		return cmp.Or(strings.Compare(a.logName, b.logName), strings.Compare(a.insertId, b.insertId))
+	})
+	return keys
+}
+
+// report logs the anomalies found, separately from the entries written to the output
+func (c *integrityChecker) report() {
+	duplicates := c.duplicates()
+	if len(duplicates) == 0 && len(c.regressions) == 0 {
+		log.Printf("Integrity check of %d entries: no duplicate insertIds or timestamp regressions", c.entries)
+		return
+	}
+
+	log.Printf("Integrity check of %d entries: %d duplicate insertIds, %d timestamp regressions", c.entries, len(duplicates), len(c.regressions))
+	for i, key := range duplicates {
+		if i == maxIntegrityExamples {
+			log.Printf("... and %d more duplicate insertIds", len(duplicates)-i)
+			break
+		}
+		log.Printf("Duplicate insertId %s in %s seen %d times", key.insertId, key.logName, c.seen[key])
+	}
+	for i, r := range c.regressions {
+		if i == maxIntegrityExamples {
+			log.Printf("... and %d more timestamp regressions", len(c.regressions)-i)
+			break
+		}
+		log.Printf("Timestamp regression of %s: %s in %s at %s after %s", r.previous.Sub(r.timestamp), r.key.insertId, r.key.logName, r.timestamp.Format(time.RFC3339Nano), r.previous.Format(time.RFC3339Nano))
+	}
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestIntegrityChecker(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(logName, insertId string, offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{LogName: logName, InsertId: insertId, Timestamp: timestamppb.New(base.Add(offset))}
+	}
+
+	c := newIntegrityChecker(true)
+	for _, e := range []*loggingpb.LogEntry{
+		entry("projects/p/logs/a", "1", 0),
+		entry("projects/p/logs/a", "2", time.Second),
+		entry("projects/p/logs/b", "1", 2*time.Second),
+		entry("projects/p/logs/a", "2", 3*time.Second),
+		entry("projects/p/logs/a", "3", time.Second/2),
+		entry("projects/p/logs/a", "", 4*time.Second),
+		entry("projects/p/logs/a", "", 5*time.Second),
+	} {
+		c.observe(e)
+	}
+
+	if got, want := c.duplicates(), []insertIdKey{{"projects/p/logs/a", "2"}}; !slices.Equal(got, want) {
+		t.Errorf("duplicates() = %v, want %v", got, want)
+	}
+	if len(c.regressions) != 1 || c.regressions[0].key.insertId != "3" || !c.regressions[0].previous.Equal(base.Add(3*time.Second)) {
+		t.Errorf("regressions = %+v, want only insertId 3 after the entry at +3s", c.regressions)
+	}
+
+	descending := newIntegrityChecker(false)
+	descending.observe(entry("projects/p/logs/a", "1", time.Second))
+	descending.observe(entry("projects/p/logs/a", "2", 0))
+	if len(descending.regressions) != 0 {
+		t.Errorf("regressions in a descending scan = %+v, want none", descending.regressions)
+	}
+}
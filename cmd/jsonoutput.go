@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// JSON output modes of --json-output.
+const (
+	jsonOutputLines = "lines"
+	jsonOutputArray = "array"
+	jsonOutputSeq   = "seq"
+)
+
+// jsonOutputMode returns the --json-output mode, checking it against the format: modes other than
+// lines only apply to the JSON formats
+func jsonOutputMode(cmd *cobra.Command) (string, error) {
+	flag := cmd.Flag("json-output")
+	if flag == nil {
+		return jsonOutputLines, nil
+	}
+	switch mode := flag.Value.String(); mode {
+	case jsonOutputLines:
+		return mode, nil
+	case jsonOutputArray, jsonOutputSeq:
+		if format := entryFormat(cmd); format != formatJSON && format != formatK8s {
+			return "", fmt.Errorf("--json-output %s requires a JSON --format", mode)
+		}
+		return mode, nil
+	default:
+		return "", fmt.Errorf("invalid --json-output %q, valid values: %s, %s, %s", mode, jsonOutputLines, jsonOutputArray, jsonOutputSeq)
+	}
+}
+
+// jsonMarshalOptions renders the entries of the JSON format, and the messages of printJSON,
+// set from the --json-* rendering flags by newEntryRenderer
+var jsonMarshalOptions = protojson.MarshalOptions{}
+
+// jsonRenderingKeys are the config keys of the --json-* rendering flags
+var jsonRenderingKeys = map[string]string{
+	"json-proto-names":   "jsonProtoNames",
+	"json-emit-defaults": "jsonEmitDefaults",
+	"json-enum-numbers":  "jsonEnumNumbers",
+}
+
+// jsonMarshalOptionsFlags returns the protojson options selected by the --json-* rendering flags, falling back to the config
+func jsonMarshalOptionsFlags(cmd *cobra.Command) (protojson.MarshalOptions, error) {
+	enabled := map[string]bool{}
+	for name, key := range jsonRenderingKeys {
+		if !cmd.Flags().Changed(name) {
+			enabled[name] = viper.GetBool(key)
+			continue
+		}
+		value, err := cmd.Flags().GetBool(name)
+		if err != nil {
+			return protojson.MarshalOptions{}, err
+		}
+		enabled[name] = value
+	}
+	return protojson.MarshalOptions{
+		UseProtoNames:   enabled["json-proto-names"],
+		EmitUnpopulated: enabled["json-emit-defaults"],
+		UseEnumNumbers:  enabled["json-enum-numbers"],
+	}, nil
+}
+
+// outputFramer writes the rendered entries as lines, after the header row of the CSV formats,
+// or for --json-output as a single JSON array or RFC 7464 JSON text sequences
+type outputFramer struct {
+	mode    string
+	header  string
+	written int
+}
+
+func (f *outputFramer) write(w io.Writer, line string) error {
+	var framed string
+	switch {
+	case f.header != "" && f.written == 0:
+		framed = f.header + "\n" + line + "\n"
+	case f.mode == jsonOutputSeq:
+		framed = "\x1e" + line + "\n"
+	case f.mode == jsonOutputArray && f.written == 0:
+		framed = "[\n" + line
+	case f.mode == jsonOutputArray:
+		framed = ",\n" + line
+	default:
+		framed = line + "\n"
+	}
+	f.written++
+	_, err := io.WriteString(w, framed)
+	return err
+}
+
+// close ends the array, which is empty if no entry was written, and writes the header of empty CSV outputs
+func (f *outputFramer) close(w io.Writer) error {
+	var end string
+	switch {
+	case f.mode == jsonOutputArray && f.written == 0:
+		end = "[]\n"
+	case f.mode == jsonOutputArray:
+		end = "\n]\n"
+	case f.header != "" && f.written == 0:
+		end = f.header + "\n"
+	}
+	_, err := io.WriteString(w, end)
+	return err
+}
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+func TestJSONFramer(t *testing.T) {
+	tests := []struct {
+		mode     string
+		lines    []string
+		expected string
+	}{
+		{jsonOutputLines, []string{`{"a":1}`, `{"b":2}`}, "{\"a\":1}\n{\"b\":2}\n"},
+		{jsonOutputArray, []string{`{"a":1}`, `{"b":2}`}, "[\n{\"a\":1},\n{\"b\":2}\n]\n"},
+		{jsonOutputArray, nil, "[]\n"},
+		{jsonOutputSeq, []string{`{"a":1}`, `{"b":2}`}, "\x1e{\"a\":1}\n\x1e{\"b\":2}\n"},
+	}
+	for _, test := range tests {
+		var b strings.Builder
+		f := &outputFramer{mode: test.mode}
+		for _, line := range test.lines {
+			if err := f.write(&b, line); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := f.close(&b); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != test.expected {
+			t.Errorf("%s of %d lines = %q, want %q", test.mode, len(test.lines), b.String(), test.expected)
+		}
+		if test.mode == jsonOutputArray && !json.Valid([]byte(b.String())) {
+			t.Errorf("%s of %d lines is not a valid JSON document", test.mode, len(test.lines))
+		}
+	}
+}
+
+func TestJSONOutputMode(t *testing.T) {
+	cmd := &cobra.Command{}
+	addFormatFlags(cmd)
+	for _, test := range []struct {
+		value  string
+		format string
+		err    bool
+	}{
+		{"lines", formatText, false},
+		{"array", formatJSON, false},
+		{"seq", formatK8s, false},
+		{"array", formatText, true},
+		{"seq", formatCSV, true},
+		{"xml", formatJSON, true},
+	} {
+		if err := cmd.Flags().Set("json-output", test.value); err != nil {
+			t.Fatal(err)
+		}
+		if err := cmd.Flags().Set("format", test.format); err != nil {
+			t.Fatal(err)
+		}
+		if mode, err := jsonOutputMode(cmd); (err != nil) != test.err || (err == nil && mode != test.value) {
+			t.Errorf("jsonOutputMode(%q) with --format %s = %q, %v", test.value, test.format, mode, err)
+		}
+	}
+}
+
+func TestJSONRenderingFlags(t *testing.T) {
+	cmd := &cobra.Command{}
+	addFormatFlags(cmd)
+	viper.Set("jsonProtoNames", true)
+	viper.Set("jsonEnumNumbers", true)
+	defer viper.Set("jsonProtoNames", nil)
+	defer viper.Set("jsonEnumNumbers", nil)
+	defer func() { jsonMarshalOptions = protojson.MarshalOptions{} }()
+	// The flags override the config.
+	for name, value := range map[string]string{"format": formatJSON, "json-enum-numbers": "false", "json-emit-defaults": "true"} {
+		if err := cmd.Flags().Set(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	render, _, err := newEntryRenderer(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err := render(&loggingpb.LogEntry{InsertId: "a", Severity: logtypepb.LogSeverity_ERROR})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{`"insert_id"`, `"ERROR"`, `"trace"`} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("rendered %s, want %s", line, expected)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// k8sRecord converts an entry into the structured JSON line understood by the logging agents of
+// Kubernetes clusters, so that entries re-emitted by a sidecar keep their severity, time and trace
+func k8sRecord(entry *loggingpb.LogEntry) (map[string]any, error) {
+	record := map[string]any{}
+
+	if payload, ok := entry.Payload.(*loggingpb.LogEntry_JsonPayload); ok {
+		m, err := entryToMap(&loggingpb.LogEntry{Payload: payload})
+		if err != nil {
+			return nil, err
+		}
+		if fields, ok := m["jsonPayload"].(map[string]any); ok {
+			record = fields
+		}
+	}
+
+	record["message"] = payloadSummary(entry)
+	record["severity"] = entry.Severity.String()
+	if entry.Timestamp != nil {
+		record["time"] = entry.Timestamp.AsTime().Format(time.RFC3339Nano)
+	}
+	record["logName"] = entry.LogName
+	if entry.InsertId != "" {
+		record["logging.googleapis.com/insertId"] = entry.InsertId
+	}
+	if len(entry.Labels) > 0 {
+		record["logging.googleapis.com/labels"] = entry.Labels
+	}
+	if entry.Trace != "" {
+		record["logging.googleapis.com/trace"] = entry.Trace
+		record["logging.googleapis.com/trace_sampled"] = entry.TraceSampled
+	}
+	if entry.SpanId != "" {
+		record["logging.googleapis.com/spanId"] = entry.SpanId
+	}
+	if loc := entry.SourceLocation; loc != nil {
+		record["logging.googleapis.com/sourceLocation"] = map[string]any{
+			"file":     loc.File,
+			"line":     loc.Line,
+			"function": loc.Function,
+		}
+	}
+
+	return record, nil
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/kafka"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// kafkaMaxBatchBytes bounds the messages of a produce request, below the 1MB message.max.bytes that
+// brokers accept by default for the batch of a partition
+const kafkaMaxBatchBytes = 900 << 10
+
+// kafkaAcks maps the values of --acks to the acknowledgements of the produce requests
+var kafkaAcks = map[string]int16{"all": kafka.AcksAll, "1": kafka.AcksLeader, "0": kafka.AcksNone}
+
+var exportKafkaCmd = &cobra.Command{
+	Use:   "kafka --brokers HOST:PORT,... --topic TOPIC [filter]",
+	Short: "Produce entries to a Kafka topic",
+	Long: `Produce each entry matching the filter to a Kafka topic, as a message of the
+entry as JSON with the timestamp of the entry, in produce requests of
+--batch-size entries.
+
+The messages are keyed by the insertId or the trace of the entries (--key),
+and partitioned by the hash of their keys like the Java clients do, so that
+the entries of a trace land in the same partition, in the order they were
+fetched: use --order asc to replay them chronologically. The messages
+without a key, e.g. of entries without a trace, are spread across the
+partitions.
+
+--acks selects the acknowledgement awaited for each request: all (every
+in-sync replica wrote the messages), 1 (the leader did) or 0 (none, the
+messages can be lost silently). The connections use TLS with --tls, SASL
+authentication is not supported.`,
+	Annotations: map[string]string{annotationWritesOutput: "true"},
+	Args:        cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newKafkaSink)
+	},
+}
+
+// kafkaExporter produces the entries to a topic in batches
+type kafkaExporter struct {
+	ctx       context.Context
+	producer  *kafka.Producer
+	topic     string
+	key       string
+	batchSize int
+
+	batch    []kafka.Message
+	size     int
+	produced int
+	// err is the failure of a produce request that stopped the exporter.
+	err error
+}
+
+// newKafkaSink returns the function producing each entry to the --topic, and the function producing
+// the last batch
+func newKafkaSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	brokers, err := cmd.Flags().GetStringSlice("brokers")
+	if err != nil {
+		return nil, nil, err
+	}
+	topic := cmd.Flag("topic").Value.String()
+	key := cmd.Flag("key").Value.String()
+	if key != "insertId" && key != "trace" && key != "none" {
+		return nil, nil, fmt.Errorf("invalid --key %q, valid values: insertId, trace, none", key)
+	}
+	acks, ok := kafkaAcks[cmd.Flag("acks").Value.String()]
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid --acks %q, valid values: all, 1, 0", cmd.Flag("acks").Value.String())
+	}
+	batchSize, err := cmd.Flags().GetInt("batch-size")
+	if err != nil {
+		return nil, nil, err
+	}
+	if batchSize <= 0 {
+		return nil, nil, fmt.Errorf("invalid --batch-size %d", batchSize)
+	}
+	config := kafka.Config{Brokers: brokers, Topic: topic, Acks: acks}
+	useTLS, err := cmd.Flags().GetBool("tls")
+	if err != nil {
+		return nil, nil, err
+	}
+	if useTLS {
+		if config.TLS, err = tlsConfig(); err != nil {
+			return nil, nil, err
+		}
+		if config.TLS == nil {
+			// The system roots, without --ca-cert and --insecure-skip-verify.
+			config.TLS = &tls.Config{}
+		}
+	}
+
+	producer, err := kafka.NewProducer(cmd.Context(), config)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &kafkaExporter{ctx: cmd.Context(), producer: producer, topic: topic, key: key, batchSize: batchSize}
+	return e.process, e.flush, nil
+}
+
+// kafkaMessage returns the message of an entry, keyed by the field key of the entry
+func kafkaMessage(entry *loggingpb.LogEntry, key string) (kafka.Message, error) {
+	value, err := protojson.Marshal(entry)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+	message := kafka.Message{Value: value, Time: time.Now()}
+	if entry.Timestamp != nil {
+		message.Time = entry.Timestamp.AsTime()
+	}
+	switch {
+	case key == "insertId" && entry.InsertId != "":
+		message.Key = []byte(entry.InsertId)
+	case key == "trace" && entry.Trace != "":
+		message.Key = []byte(entry.Trace)
+	}
+	return message, nil
+}
+
+func (e *kafkaExporter) process(entry *loggingpb.LogEntry) error {
+	if e.err != nil {
+		return grapple.ErrStop
+	}
+	message, err := kafkaMessage(entry, e.key)
+	if err != nil {
+		return err
+	}
+	size := len(message.Key) + len(message.Value)
+	if len(e.batch) == e.batchSize || len(e.batch) > 0 && e.size+size > kafkaMaxBatchBytes {
+		if e.err = e.send(); e.err != nil {
+			return grapple.ErrStop
+		}
+	}
+	e.batch = append(e.batch, message)
+	e.size += size
+	return nil
+}
+
+// send produces the batch
+func (e *kafkaExporter) send() error {
+	if len(e.batch) == 0 {
+		return nil
+	}
+	if err := e.producer.Produce(e.ctx, e.batch); err != nil {
+		return fmt.Errorf("producing to %s: %w", e.topic, err)
+	}
+	e.produced += len(e.batch)
+	e.batch, e.size = nil, 0
+	return nil
+}
+
+// flush produces the last batch and closes the connections
+func (e *kafkaExporter) flush() error {
+	if e.err == nil {
+		e.err = e.send()
+	}
+	e.producer.Close()
+	log.Printf("Produced %d entries to %s", e.produced, e.topic)
+	return e.err
+}
+
+func init() {
+	addFetchFlags(exportKafkaCmd)
+	exportKafkaCmd.Flags().StringSlice("brokers", nil, "comma-separated HOST:PORT addresses of the bootstrap brokers")
+	exportKafkaCmd.Flags().String("topic", "", "topic to produce the entries to")
+	exportKafkaCmd.Flags().String("key", "insertId", "key of the messages, valid values: insertId, trace, none")
+	exportKafkaCmd.Flags().String("acks", "all", "acknowledgement awaited for each produce request, valid values: all, 1, 0")
+	exportKafkaCmd.Flags().Int("batch-size", 1000, "number of entries produced per request")
+	exportKafkaCmd.Flags().Bool("tls", false, "connect to the brokers with TLS")
+	exportKafkaCmd.MarkFlagRequired("brokers")
+	exportKafkaCmd.MarkFlagRequired("topic")
+
+	exportCmd.AddCommand(exportKafkaCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestKafkaMessage(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	entry := &loggingpb.LogEntry{InsertId: "a", Trace: "projects/p/traces/t1", Timestamp: timestamppb.New(ts)}
+	tests := []struct {
+		key      string
+		expected []byte
+	}{
+		{"insertId", []byte("a")},
+		{"trace", []byte("projects/p/traces/t1")},
+		{"none", nil},
+	}
+	for _, test := range tests {
+		message, err := kafkaMessage(entry, test.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(message.Key) != string(test.expected) || (message.Key == nil) != (test.expected == nil) {
+			t.Errorf("key of --key %s = %q, want %q", test.key, message.Key, test.expected)
+		}
+		if !message.Time.Equal(ts) {
+			t.Errorf("time %v, want the timestamp of the entry", message.Time)
+		}
+		// protojson randomizes its spacing.
+		if !strings.Contains(strings.ReplaceAll(string(message.Value), " ", ""), `"insertId":"a"`) {
+			t.Errorf("value %s", message.Value)
+		}
+	}
+
+	// Entries without a trace are spread across the partitions.
+	if message, _ := kafkaMessage(&loggingpb.LogEntry{InsertId: "b"}, "trace"); message.Key != nil {
+		t.Errorf("key %q, want none", message.Key)
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+)
+
+// narrowingThreshold is the length of the time window above which the newest entries are
+// searched from the recent end of the window rather than by paginating through all of it
+const narrowingThreshold = 6 * time.Hour
+
+// initialNarrowingStep is the length of the first window searched when narrowing
+const initialNarrowingStep = time.Hour
+
+// fetchNarrowing fetches the newest entries between from and to, newest first, querying windows
+// of doubling length back from to, until done reports that process got enough entries. Sparse
+// matches deep in a long window otherwise take many pages. process must stop the fetch with
+// grapple.ErrStop once done.
+func fetchNarrowing(ctx context.Context, client *logadmin.Client, baseOpts []logadmin.EntriesOption, filter string, from, to time.Time, done func() bool, process func(*loggingpb.LogEntry) error) (int, error) {
+	count := 0
+	for _, window := range narrowingWindows(from, to) {
+		opts := append(slices.Clone(baseOpts), logadmin.Filter(andFilters(filter, window)), logadmin.NewestFirst())
+		n, err := fetchAndProcessLogs(ctx, client, opts, process)
+		count += n
+		if err != nil || done() || ctx.Err() != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// narrowingWindows splits [from, to] into contiguous windows of doubling length going back from to,
+// returning their filter clauses on windowField, newest first
+func narrowingWindows(from, to time.Time) []string {
+	var windows []string
+	upper := "<="
+	step := initialNarrowingStep
+	for hi := to; hi.After(from); step *= 2 {
+		lo := hi.Add(-step)
+		if lo.Before(from) {
+			lo = from
+		}
+		windows = append(windows, fmt.Sprintf("%s >= %q AND %s %s %q", windowField, lo.UTC().Format(time.RFC3339Nano), windowField, upper, hi.UTC().Format(time.RFC3339Nano)))
+		// The windows after the first exclude their end, which belongs to the previous one.
+		upper = "<"
+		hi = lo
+	}
+	return windows
+}
+
+// tailBuffer holds the entries of --tail, fetched as the first ones in the opposite order, until the
+// fetch is over. It buffers the entries that went through the processors and are accepted by --grep,
+// the filter of the sink, so that they are the last limit entries printed, then hands them to next.
+type tailBuffer struct {
+	limit   int
+	accept  func(*loggingpb.LogEntry) (bool, error)
+	next    func(*loggingpb.LogEntry) error
+	entries []*loggingpb.LogEntry
+	flushed bool
+}
+
+func newTailBuffer(cmd *cobra.Command, limit int, next func(*loggingpb.LogEntry) error) (*tailBuffer, error) {
+	accept, err := newGrepFilter(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return &tailBuffer{limit: limit, accept: accept, next: next}, nil
+}
+
+// process buffers the entry, or hands it to next once flushed
+func (b *tailBuffer) process(entry *loggingpb.LogEntry) error {
+	if b.flushed {
+		return b.next(entry)
+	}
+	if b.accept != nil {
+		ok, err := b.accept(entry)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			counters.grepped++
+			return nil
+		}
+	}
+	b.entries = append(b.entries, entry)
+	return nil
+}
+
+// full reports whether the buffer holds the last limit entries
+func (b *tailBuffer) full() bool {
+	return len(b.entries) >= b.limit
+}
+
+// flush hands the buffered entries to next in reverse, the order of the query
+func (b *tailBuffer) flush() {
+	b.flushed = true
+	for i := len(b.entries) - 1; i >= 0; i-- {
+		if err := b.next(b.entries[i]); err != nil {
+			counters.skipped++
+			log.Printf("Error processing log entry (%s): %v", b.entries[i].InsertId, err)
+		}
+	}
+	b.entries = nil
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+)
+
+func TestNarrowingWindows(t *testing.T) {
+	to := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	from := to.Add(-5 * time.Hour)
+
+	expected := []string{
+		`timestamp >= "2025-01-02T11:00:00Z" AND timestamp <= "2025-01-02T12:00:00Z"`,
+		`timestamp >= "2025-01-02T09:00:00Z" AND timestamp < "2025-01-02T11:00:00Z"`,
+		`timestamp >= "2025-01-02T07:00:00Z" AND timestamp < "2025-01-02T09:00:00Z"`,
+	}
+	if got := narrowingWindows(from, to); !reflect.DeepEqual(got, expected) {
+		t.Errorf("narrowingWindows() =\n%v\nwant\n%v", got, expected)
+	}
+}
+
+func TestTailBufferGrep(t *testing.T) {
+	defer func(size int) { pageSize = size }(pageSize)
+	pageSize = 2
+
+	cmd := &cobra.Command{}
+	addFormatFlags(cmd)
+	cmd.Flags().Set("format", "json")
+	cmd.Flags().Set("grep", `"insertId":"[0-9]+-1"`)
+	var printed []string
+	tailed, err := newTailBuffer(cmd, 3, func(entry *loggingpb.LogEntry) error {
+		printed = append(printed, entry.InsertId)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// --tail 3 --grep keeps fetching until it has the last 3 matches, not the last 3 entries.
+	server := &fakeLogging{pages: 10}
+	client := newFakeLogadminClient(t, server)
+	_, err = fetchAndProcessLogs(context.Background(), client, nil, func(entry *loggingpb.LogEntry) error {
+		tailed.process(entry)
+		if tailed.full() {
+			return grapple.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tailed.flush()
+	if expected := []string{"3-1", "2-1", "1-1"}; !reflect.DeepEqual(printed, expected) || len(server.requests) != 3 {
+		t.Errorf("printed %v after %d requests, want %v after 3", printed, len(server.requests), expected)
+	}
+
+	// Once flushed, the entries go straight to the output.
+	tailed.process(&loggingpb.LogEntry{InsertId: "watched"})
+	if printed[len(printed)-1] != "watched" {
+		t.Errorf("printed %v, want the entry after the flush", printed)
+	}
+}
+
+func TestNarrowingWindowsReceiveTimestamp(t *testing.T) {
+	defer func() { windowField = fieldTimestamp }()
+	windowField = fieldReceiveTimestamp
+
+	to := time.Date(2025, 1, 2, 12, 0, 0, 0, time.UTC)
+	windows := narrowingWindows(to.Add(-2*time.Hour), to)
+	expected := `receiveTimestamp >= "2025-01-02T11:00:00Z" AND receiveTimestamp <= "2025-01-02T12:00:00Z"`
+	if len(windows) != 2 || windows[0] != expected {
+		t.Errorf("narrowingWindows() = %v, want windows on receiveTimestamp like %s", windows, expected)
+	}
+}
@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/input"
+	"github.com/spf13/cobra"
+)
+
+var localCmd = &cobra.Command{
+	Use:   "local FILE...",
+	Short: "Print entries from exported files",
+	Long: `Print the entries of files of JSON lines, as written by grapple, or of JSON
+arrays, with the same formats as the queries. Files ending in .gz and .zst are decompressed,
+"-" reads stdin. The files are read one after the other.
+
+With --replay-speed the entries are re-emitted spaced according to their
+timestamps, at the original pace (1x) or faster (e.g. 10x), which is useful
+to exercise downstream pipelines with realistic traffic. Replaying expects
+the entries oldest first: going back in time does not wait.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		speed, err := parseReplaySpeed(cmd.Flag("replay-speed").Value.String())
+		checkErr(err)
+		printLocalFiles(cmd, args, &replayPacer{speed: speed})
+	},
+}
+
+// printLocalFiles prints the entries of the files one after the other, with the format and output
+// flags of cmd, until the end of the last file or an interruption
+func printLocalFiles(cmd *cobra.Command, paths []string, pacer *replayPacer) {
+	process, flush, err := newEntrySink(cmd)
+	checkErr(err)
+
+	out, err := openOutput(cmd)
+	checkErr(err)
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	count := 0
+	for _, path := range paths {
+		var n int
+		n, err = printLocalFile(ctx, path, pacer, process)
+		count += n
+		if err != nil || ctx.Err() != nil {
+			break
+		}
+	}
+	if ctx.Err() != nil {
+		log.Printf("Interrupted after %d entries", count)
+	}
+	if err == nil {
+		err = flush()
+	}
+	checkErr(errors.Join(err, out.Close()))
+}
+
+// printLocalFile prints the entries of a file, pacing them, until the end of the file or of ctx
+func printLocalFile(ctx context.Context, path string, pacer *replayPacer, process func(*loggingpb.LogEntry) error) (int, error) {
+	r, err := input.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	entries := input.NewEntryReader(path, r)
+	count := 0
+	for {
+		entry, err := entries.Next()
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return count, nil
+		case <-time.After(pacer.delay(entry)):
+		}
+
+		if err := process(entry); err != nil {
+			log.Printf("Error processing log entry (%s): %v", entry.InsertId, err)
+		}
+		count++
+	}
+}
+
+// parseReplaySpeed converts strings like "1x", "10x" or "0.5" into a speed factor, zero for no pacing
+func parseReplaySpeed(expression string) (float64, error) {
+	if expression == "" {
+		return 0, nil
+	}
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(strings.ToLower(expression), "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid --replay-speed %q, expected a positive factor like 1x or 10x", expression)
+	}
+	return speed, nil
+}
+
+// replayPacer spaces the entries according to their timestamps, divided by the speed
+type replayPacer struct {
+	speed float64
+	last  time.Time
+}
+
+// delay returns how long to wait before emitting the entry
+func (p *replayPacer) delay(entry *loggingpb.LogEntry) time.Duration {
+	if p.speed == 0 || entry.Timestamp == nil {
+		return 0
+	}
+	ts := entry.Timestamp.AsTime()
+	previous := p.last
+	p.last = ts
+	if previous.IsZero() || !ts.After(previous) {
+		return 0
+	}
+	return time.Duration(float64(ts.Sub(previous)) / p.speed)
+}
+
+func init() {
+	localCmd.Flags().String("replay-speed", "", "re-emit the entries at the pace of their timestamps, sped up by this factor (e.g. 1x, 10x)")
+	addFormatFlags(localCmd)
+	addOutputFlags(localCmd)
+
+	rootCmd.AddCommand(localCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestParseReplaySpeed(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected float64
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"1x", 1, false},
+		{"10X", 10, false},
+		{"0.5", 0.5, false},
+		{"0x", 0, true},
+		{"fast", 0, true},
+	}
+	for _, c := range cases {
+		got, err := parseReplaySpeed(c.input)
+		if (err != nil) != c.wantErr || got != c.expected {
+			t.Errorf("parseReplaySpeed(%q) = %v, %v, want %v (error %v)", c.input, got, err, c.expected, c.wantErr)
+		}
+	}
+}
+
+func TestPrintLocalFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.ndjson")
+	data := `{"insertId":"a","severity":"ERROR"}
+{"insertId":"b","jsonPayload":{"message":"ok"}}
+`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var ids string
+	n, err := printLocalFile(context.Background(), path, &replayPacer{}, func(entry *loggingpb.LogEntry) error {
+		ids += entry.InsertId
+		return nil
+	})
+	if err != nil || n != 2 || ids != "ab" {
+		t.Errorf("printLocalFile() = %d, %v with entries %q, want 2 entries a and b", n, err, ids)
+	}
+}
+
+func TestReplayPacer(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{Timestamp: timestamppb.New(start.Add(offset))}
+	}
+
+	p := &replayPacer{speed: 10}
+	for _, c := range []struct {
+		offset, expected time.Duration
+	}{
+		{0, 0},
+		{10 * time.Second, time.Second},
+		{5 * time.Second, 0},
+		{7 * time.Second, 200 * time.Millisecond},
+	} {
+		if got := p.delay(entry(c.offset)); got != c.expected {
+			t.Errorf("delay(+%s) = %s, want %s", c.offset, got, c.expected)
+		}
+	}
+}
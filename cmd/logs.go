@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Manage logs",
+	Long:  `Manage logs`,
+}
+
+var logsDeleteCmd = &cobra.Command{
+	Use:   "delete LOG_ID...",
+	Short: "Delete logs and all their entries",
+	Long: `Delete one or more logs and all their entries.
+
+A deleted log reappears as soon as it receives new entries.
+Unless --yes is given, the deletion must be confirmed interactively.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+
+		yes, err := cmd.Flags().GetBool("yes")
+		cobra.CheckErr(err)
+
+		if !confirmDeletion(projectId, args, yes, confirm) {
+			log.Println("Aborted")
+			return
+		}
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		cobra.CheckErr(deleteLogs(ctx, client, args))
+	},
+}
+
+// confirmDeletion reports whether the logs can be deleted: with --yes, or when the question asked is confirmed
+func confirmDeletion(projectId string, logIDs []string, yes bool, ask func(question string) bool) bool {
+	return yes || ask(fmt.Sprintf("Delete %d log(s) from project %s: %s?", len(logIDs), projectId, strings.Join(logIDs, ", ")))
+}
+
+// deleteLogs deletes each of the logs, going on after a failure, and reports how many failed
+func deleteLogs(ctx context.Context, client *logadmin.Client, logIDs []string) error {
+	failed := 0
+	for _, logID := range logIDs {
+		if err := client.DeleteLog(ctx, logID); err != nil {
+			log.Printf("Error deleting log %s: %v", logID, err)
+			failed++
+			continue
+		}
+		log.Printf("Deleted log %s", logID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to delete %d of %d log(s)", failed, len(logIDs))
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on stderr and reads the answer from stdin
+func confirm(question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	logsDeleteCmd.Flags().Bool("yes", false, "skip the confirmation prompt")
+
+	logsCmd.AddCommand(logsDeleteCmd)
+	rootCmd.AddCommand(logsCmd)
+}
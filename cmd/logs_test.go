@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// fakeDeletions records the deleted logs, failing for missing
+type fakeDeletions struct {
+	loggingpb.UnimplementedLoggingServiceV2Server
+	missing string
+	deleted []string
+}
+
+func (f *fakeDeletions) DeleteLog(ctx context.Context, req *loggingpb.DeleteLogRequest) (*emptypb.Empty, error) {
+	if req.LogName == f.missing {
+		return nil, status.Error(codes.NotFound, "log not found")
+	}
+	f.deleted = append(f.deleted, req.LogName)
+	return &emptypb.Empty{}, nil
+}
+
+func TestConfirmDeletion(t *testing.T) {
+	var asked []string
+	ask := func(answer bool) func(string) bool {
+		return func(question string) bool {
+			asked = append(asked, question)
+			return answer
+		}
+	}
+	if !confirmDeletion("p", []string{"a", "b"}, true, ask(false)) || len(asked) != 0 {
+		t.Errorf("confirmDeletion() with --yes asked %q, want no question", asked)
+	}
+	if confirmDeletion("p", []string{"a", "b"}, false, ask(false)) {
+		t.Error("confirmDeletion() = true after a refusal")
+	}
+	if !confirmDeletion("p", []string{"a", "b"}, false, ask(true)) {
+		t.Error("confirmDeletion() = false after a confirmation")
+	}
+	if len(asked) != 2 || asked[1] != "Delete 2 log(s) from project p: a, b?" {
+		t.Errorf("asked %q, want the logs and the project", asked)
+	}
+}
+
+func TestDeleteLogs(t *testing.T) {
+	server := &fakeDeletions{missing: "projects/p/logs/b"}
+	client := newFakeClient(t, func(s *grpc.Server) { loggingpb.RegisterLoggingServiceV2Server(s, server) })
+
+	err := deleteLogs(context.Background(), client, []string{"a", "b", "cloudaudit.googleapis.com/activity"})
+	if err == nil || err.Error() != "failed to delete 1 of 3 log(s)" {
+		t.Errorf("deleteLogs() = %v, want the failure of b", err)
+	}
+	// The deletion goes on after a failure.
+	if fmt.Sprint(server.deleted) != "[projects/p/logs/a projects/p/logs/cloudaudit.googleapis.com%2Factivity]" {
+		t.Errorf("deleted %v, want a and the escaped activity log", server.deleted)
+	}
+}
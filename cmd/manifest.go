@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// defaultManifestWindow is the default length of the windows counted by a manifest
+const defaultManifestWindow = "1h"
+
+// exportManifest describes what an export contains, so that `grapple verify` can check it is complete
+type exportManifest struct {
+	Project string `json:"project"`
+	// Filter is the filter of the export, without the time window.
+	Filter string `json:"filter"`
+	// Views are the log views read, View the only one in manifests written before --include-buckets.
+	Views []string `json:"views,omitempty"`
+	View  string   `json:"view,omitempty"`
+	// WindowField is the field the windows apply to, timestamp when empty.
+	WindowField string           `json:"windowField,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	Windows     []manifestWindow `json:"windows"`
+}
+
+// manifestWindow counts the entries exported with from <= timestamp (or WindowField) < to,
+// the last window of a manifest also includes its end
+type manifestWindow struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Entries int       `json:"entries"`
+}
+
+// newExportManifest splits the time range of an export into windows of the given size
+func newExportManifest(project, filter string, views []string, from, to time.Time, size time.Duration) *exportManifest {
+	m := &exportManifest{Project: project, Filter: filter, Views: views, CreatedAt: time.Now().UTC()}
+	if windowField != fieldTimestamp {
+		m.WindowField = windowField
+	}
+	for start := from; start.Before(to); start = start.Add(size) {
+		end := start.Add(size)
+		if end.After(to) {
+			end = to
+		}
+		m.Windows = append(m.Windows, manifestWindow{From: start.UTC(), To: end.UTC()})
+	}
+	return m
+}
+
+// observe counts an exported entry in its window
+func (m *exportManifest) observe(entry *loggingpb.LogEntry) {
+	ts, ok := windowTime(entry, m.field())
+	if !ok || len(m.Windows) == 0 {
+		return
+	}
+	for i := range m.Windows {
+		w := &m.Windows[i]
+		if !ts.Before(w.From) && (ts.Before(w.To) || i == len(m.Windows)-1 && ts.Equal(w.To)) {
+			w.Entries++
+			return
+		}
+	}
+}
+
+// windowFilter returns the filter matching the entries of the i-th window
+func (m *exportManifest) windowFilter(i int) string {
+	w := m.Windows[i]
+	upper := "<"
+	if i == len(m.Windows)-1 {
+		upper = "<="
+	}
+	return andFilters(m.Filter, fmt.Sprintf("%[1]s >= %[2]q AND %[1]s %[3]s %[4]q", m.field(), w.From.Format(time.RFC3339Nano), upper, w.To.Format(time.RFC3339Nano)))
+}
+
+// field returns the field the windows apply to
+func (m *exportManifest) field() string {
+	if m.WindowField == "" {
+		return fieldTimestamp
+	}
+	return m.WindowField
+}
+
+func (m *exportManifest) write(path string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+func readExportManifest(path string) (*exportManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m := &exportManifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+	}
+	return m, nil
+}
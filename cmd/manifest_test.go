@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestExportManifest(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(150 * time.Minute)
+
+	m := newExportManifest("p", "severity>=ERROR", nil, from, to, time.Hour)
+	if len(m.Windows) != 3 || !m.Windows[2].To.Equal(to) {
+		t.Fatalf("newExportManifest() windows = %v, want 3 ending at %s", m.Windows, to)
+	}
+
+	for _, offset := range []time.Duration{0, 59 * time.Minute, time.Hour, 150 * time.Minute, 151 * time.Minute} {
+		m.observe(&loggingpb.LogEntry{Timestamp: timestamppb.New(from.Add(offset))})
+	}
+	for i, expected := range []int{2, 1, 1} {
+		if got := m.Windows[i].Entries; got != expected {
+			t.Errorf("window %d has %d entries, want %d", i, got, expected)
+		}
+	}
+
+	cases := map[int]string{
+		0: `(severity>=ERROR) AND (timestamp >= "2025-01-01T00:00:00Z" AND timestamp < "2025-01-01T01:00:00Z")`,
+		2: `(severity>=ERROR) AND (timestamp >= "2025-01-01T02:00:00Z" AND timestamp <= "2025-01-01T02:30:00Z")`,
+	}
+	for i, expected := range cases {
+		if got := m.windowFilter(i); got != expected {
+			t.Errorf("windowFilter(%d) = %q, want %q", i, got, expected)
+		}
+	}
+}
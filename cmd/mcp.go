@@ -0,0 +1,332 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/lql"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/status"
+)
+
+// mcpProtocolVersion is the version of the Model Context Protocol implemented by grapple mcp
+const mcpProtocolVersion = "2024-11-05"
+
+// JSON-RPC error codes of the MCP responses.
+const (
+	mcpParseError     = -32700
+	mcpMethodNotFound = -32601
+	mcpInvalidParams  = -32602
+)
+
+var mcpCmd = &cobra.Command{
+	Use:   "mcp",
+	Short: "Serve read-only log queries as Model Context Protocol tools",
+	Long: `Serve grapple as a Model Context Protocol server over stdio, so that AI
+assistants can be granted controlled, read-only access to the log entries:
+
+  query      fetch the entries matching a filter, as JSON lines
+  aggregate  count the entries matching a filter per severity, log and resource type
+  explain    check the syntax of a filter and show how it is understood
+
+Like serve-grpc, grapple reads the entries with its own credentials, adds the
+filter flags it was started with (the alwaysFilter, --severity, --label,
+--exclude-preset, --freshness, ...) to every filter and reads at most
+--max-entries entries per call. The entries returned by query go through
+--hash-fields and --dlp first, so that identities and secrets can be kept
+away from the assistant.
+
+Register it in the assistant as a stdio server running, e.g.,
+"grapple mcp --project my-project --freshness 1d --dlp redact".`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		hasher, err := newFieldHasher(cmd)
+		cobra.CheckErr(err)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+		s, err := newGrappleServer(ctx, cmd, client)
+		cobra.CheckErr(err)
+
+		redact := func(ctx context.Context, next func(*loggingpb.LogEntry) error) (func(*loggingpb.LogEntry) error, func() error, error) {
+			process := next
+			if hasher != nil {
+				process = hasher.process(process)
+			}
+			dlpProcessor, err := newDLPProcessor(ctx, cmd, projectId, process)
+			if err != nil || dlpProcessor == nil {
+				return process, func() error { return nil }, err
+			}
+			return dlpProcessor.process, dlpProcessor.flush, nil
+		}
+		err = serveMCP(ctx, os.Stdin, os.Stdout, mcpTools(s, redact))
+		if ctx.Err() == nil {
+			cobra.CheckErr(err)
+		}
+	},
+}
+
+// mcpTool is a tool of the MCP server, call returning the text of its result
+type mcpTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"inputSchema"`
+	call        func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+// mcpToolArgs are the arguments of the tools, each using a subset
+type mcpToolArgs struct {
+	Filter string `json:"filter"`
+	Limit  int    `json:"limit"`
+	Order  string `json:"order"`
+}
+
+// mcpFilterSchema is the input schema of the filter argument of the tools
+var mcpFilterSchema = map[string]any{
+	"type":        "string",
+	"description": "Cloud Logging query language filter, e.g. severity>=ERROR AND resource.type=\"cloud_run_revision\"; empty for all the entries",
+}
+
+// mcpTools returns the tools reading the entries through s, the ones returned by query going through redact
+func mcpTools(s *grappleServer, redact func(context.Context, func(*loggingpb.LogEntry) error) (func(*loggingpb.LogEntry) error, func() error, error)) []mcpTool {
+	return []mcpTool{
+		{
+			Name:        "query",
+			Description: fmt.Sprintf("Fetch the log entries matching a filter, as JSON lines, newest first unless order is asc. At most %d entries are returned.", s.maxEntries),
+			InputSchema: map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"filter": mcpFilterSchema,
+					"limit":  map[string]any{"type": "integer", "description": "maximum number of entries to return", "minimum": 1, "maximum": s.maxEntries},
+					"order":  map[string]any{"type": "string", "enum": []string{"desc", "asc"}},
+				},
+			},
+			call: func(ctx context.Context, data json.RawMessage) (string, error) {
+				args, err := parseMCPToolArgs(data)
+				if err != nil {
+					return "", err
+				}
+				req := &loggingpb.ListLogEntriesRequest{Filter: args.Filter, PageSize: int32(args.Limit)}
+				switch args.Order {
+				case "", "desc":
+				case "asc":
+					req.OrderBy = fieldTimestamp + " asc"
+				default:
+					return "", fmt.Errorf("invalid order %q, valid values: asc, desc", args.Order)
+				}
+				filter, newestFirst, limit, err := s.request(req)
+				if err != nil {
+					return "", errors.New(status.Convert(err).Message())
+				}
+
+				var b strings.Builder
+				process, flush, err := redact(ctx, func(entry *loggingpb.LogEntry) error {
+					line, err := jsonMarshalOptions.Marshal(entry)
+					if err != nil {
+						return err
+					}
+					b.Write(line)
+					b.WriteByte('\n')
+					return nil
+				})
+				if err != nil {
+					return "", err
+				}
+				err = s.fetchLimited(ctx, filter, newestFirst, limit, process)
+				if errors.Is(err, grapple.ErrStop) {
+					// Stopped by --dlp, whose flush returns why.
+					err = nil
+				}
+				if err = errors.Join(err, flush()); err != nil {
+					return "", errors.New(status.Convert(err).Message())
+				}
+				if b.Len() == 0 {
+					return "No entries matched the filter.", nil
+				}
+				return b.String(), nil
+			},
+		},
+		{
+			Name:        "aggregate",
+			Description: fmt.Sprintf("Count the log entries matching a filter per severity, log, resource type and minute, as JSON. At most %d entries are counted.", s.maxEntries),
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"filter": mcpFilterSchema},
+			},
+			call: func(ctx context.Context, data json.RawMessage) (string, error) {
+				args, err := parseMCPToolArgs(data)
+				if err != nil {
+					return "", err
+				}
+				report, err := s.aggregate(ctx, &loggingpb.ListLogEntriesRequest{Filter: args.Filter})
+				if err != nil {
+					return "", errors.New(status.Convert(err).Message())
+				}
+				line, err := report.MarshalJSON()
+				return string(line), err
+			},
+		},
+		{
+			Name:        "explain",
+			Description: "Check the syntax of a filter and explain how it is understood, one term per line, as sent with the filters added by the server.",
+			InputSchema: map[string]any{
+				"type":       "object",
+				"properties": map[string]any{"filter": mcpFilterSchema},
+				"required":   []string{"filter"},
+			},
+			call: func(ctx context.Context, data json.RawMessage) (string, error) {
+				args, err := parseMCPToolArgs(data)
+				if err != nil {
+					return "", err
+				}
+				if err := lqlError(args.Filter); err != nil {
+					return "", syntaxErrorContext(args.Filter, err)
+				}
+				filter, err := s.compose(args.Filter)
+				if err != nil {
+					return "", err
+				}
+				node, err := lql.Parse(filter)
+				if err != nil {
+					return "", syntaxErrorContext(filter, err)
+				}
+				var b strings.Builder
+				fmt.Fprintf(&b, "Filter sent: %s\n", dryRunValue(filter, "none, all the entries"))
+				if node != nil {
+					explainFilter(&b, node, "")
+				}
+				return b.String(), nil
+			},
+		},
+	}
+}
+
+func parseMCPToolArgs(data json.RawMessage) (mcpToolArgs, error) {
+	var args mcpToolArgs
+	if len(data) == 0 {
+		return args, nil
+	}
+	if err := json.Unmarshal(data, &args); err != nil {
+		return args, fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Limit < 0 {
+		return args, fmt.Errorf("invalid limit %d", args.Limit)
+	}
+	return args, nil
+}
+
+// mcpMessage is a JSON-RPC 2.0 request, notification (without ID) or response
+type mcpMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  any             `json:"result,omitempty"`
+	Error   *mcpError       `json:"error,omitempty"`
+}
+
+type mcpError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// mcpToolResult is the result of a tools/call request, the errors of the tools being reported in it
+type mcpToolResult struct {
+	Content []mcpContent `json:"content"`
+	IsError bool         `json:"isError,omitempty"`
+}
+
+type mcpContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// serveMCP answers the JSON-RPC messages read from in, one per line, until in ends
+func serveMCP(ctx context.Context, in io.Reader, out io.Writer, tools []mcpTool) error {
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	encoder := json.NewEncoder(out)
+	for scanner.Scan() {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var request mcpMessage
+		if err := json.Unmarshal(scanner.Bytes(), &request); err != nil {
+			if err := encoder.Encode(mcpMessage{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &mcpError{Code: mcpParseError, Message: err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		// Notifications, like notifications/initialized, get no response.
+		if len(request.ID) == 0 {
+			continue
+		}
+		result, rpcErr := handleMCPRequest(ctx, request, tools)
+		if err := encoder.Encode(mcpMessage{JSONRPC: "2.0", ID: request.ID, Result: result, Error: rpcErr}); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// handleMCPRequest returns the result of a request, or its JSON-RPC error
+func handleMCPRequest(ctx context.Context, request mcpMessage, tools []mcpTool) (any, *mcpError) {
+	switch request.Method {
+	case "initialize":
+		return map[string]any{
+			"protocolVersion": mcpProtocolVersion,
+			"capabilities":    map[string]any{"tools": map[string]any{}},
+			"serverInfo":      map[string]any{"name": cliName, "version": version},
+		}, nil
+	case "ping":
+		return map[string]any{}, nil
+	case "tools/list":
+		return map[string]any{"tools": tools}, nil
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, &mcpError{Code: mcpInvalidParams, Message: err.Error()}
+		}
+		for _, tool := range tools {
+			if tool.Name != params.Name {
+				continue
+			}
+			text, err := tool.call(ctx, params.Arguments)
+			if err != nil {
+				return mcpToolResult{Content: []mcpContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+			}
+			return mcpToolResult{Content: []mcpContent{{Type: "text", Text: text}}}, nil
+		}
+		return nil, &mcpError{Code: mcpInvalidParams, Message: fmt.Sprintf("unknown tool %q", params.Name)}
+	default:
+		return nil, &mcpError{Code: mcpMethodNotFound, Message: fmt.Sprintf("method %q not found", request.Method)}
+	}
+}
+
+func init() {
+	addFilterFlags(mcpCmd)
+	addDLPFlags(mcpCmd)
+	mcpCmd.Flags().Int("max-entries", 100, "maximum number of entries read by a query or aggregate call")
+	mcpCmd.Flags().StringSlice("hash-fields", nil, "replace these comma-separated fields of the entries returned by query with salted hashes (e.g. jsonPayload.user_id,labels.email)")
+	mcpCmd.Flags().String("hash-salt-env", "", "environment variable holding the salt of --hash-fields")
+
+	rootCmd.AddCommand(mcpCmd)
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+func TestServeMCP(t *testing.T) {
+	s := &grappleServer{
+		ctx:        context.Background(),
+		maxEntries: 10,
+		compose: func(filter string) (string, error) {
+			return andFilters(filter, `logName:"curated"`), nil
+		},
+		fetch: func(ctx context.Context, filter string, newestFirst bool, process func(*loggingpb.LogEntry) error) (int, error) {
+			for _, entry := range []*loggingpb.LogEntry{
+				{InsertId: "a", Severity: logtypepb.LogSeverity_ERROR, Trace: "secret"},
+				{InsertId: "b", Severity: logtypepb.LogSeverity_INFO},
+			} {
+				if err := process(entry); err != nil {
+					return 1, nil
+				}
+			}
+			return 2, nil
+		},
+	}
+	// Stands in for --hash-fields and --dlp.
+	redact := func(ctx context.Context, next func(*loggingpb.LogEntry) error) (func(*loggingpb.LogEntry) error, func() error, error) {
+		return func(entry *loggingpb.LogEntry) error {
+			entry.Trace = strings.ReplaceAll(entry.Trace, "secret", "[REDACTED]")
+			return next(entry)
+		}, func() error { return nil }, nil
+	}
+
+	requests := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2024-11-05"}}`,
+		`{"jsonrpc":"2.0","method":"notifications/initialized"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"tools/list"}`,
+		`{"jsonrpc":"2.0","id":3,"method":"tools/call","params":{"name":"query","arguments":{"filter":"severity>=ERROR","limit":1}}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"tools/call","params":{"name":"aggregate","arguments":{}}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"tools/call","params":{"name":"explain","arguments":{"filter":"severity>=ERROR OR"}}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"tools/call","params":{"name":"explain","arguments":{"filter":"a=1"}}}`,
+		`{"jsonrpc":"2.0","id":7,"method":"resources/list"}`,
+		`not json`,
+	}, "\n")
+	var out strings.Builder
+	if err := serveMCP(context.Background(), strings.NewReader(requests), &out, mcpTools(s, redact)); err != nil {
+		t.Fatal(err)
+	}
+
+	type response struct {
+		ID     json.RawMessage `json:"id"`
+		Result struct {
+			ProtocolVersion string       `json:"protocolVersion"`
+			Tools           []mcpTool    `json:"tools"`
+			Content         []mcpContent `json:"content"`
+			IsError         bool         `json:"isError"`
+		} `json:"result"`
+		Error *mcpError `json:"error"`
+	}
+	var responses []response
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var response response
+		if err := json.Unmarshal([]byte(line), &response); err != nil {
+			t.Fatalf("invalid response %s: %v", line, err)
+		}
+		responses = append(responses, response)
+	}
+	if len(responses) != 8 {
+		t.Fatalf("got %d responses, want one per request but the notification:\n%s", len(responses), out.String())
+	}
+
+	if responses[0].Result.ProtocolVersion != mcpProtocolVersion {
+		t.Errorf("initialize = %+v", responses[0].Result)
+	}
+	var names []string
+	for _, tool := range responses[1].Result.Tools {
+		names = append(names, tool.Name)
+	}
+	if strings.Join(names, ",") != "query,aggregate,explain" {
+		t.Errorf("tools/list = %v", names)
+	}
+
+	text := func(i int) string {
+		if len(responses[i].Result.Content) != 1 {
+			t.Fatalf("response %s has content %+v", responses[i].ID, responses[i].Result.Content)
+		}
+		return responses[i].Result.Content[0].Text
+	}
+	if query := text(2); strings.Count(query, "\n") != 1 || !strings.Contains(query, "[REDACTED]") || strings.Contains(query, "secret") {
+		t.Errorf("query = %q, want the first entry redacted", query)
+	}
+	if aggregate := text(3); !strings.Contains(aggregate, `"total":2`) {
+		t.Errorf("aggregate = %q", aggregate)
+	}
+	if !responses[4].Result.IsError || !strings.Contains(text(4), "invalid filter") {
+		t.Errorf("explain of an invalid filter = %+v", responses[4].Result)
+	}
+	if explain := text(5); !strings.Contains(explain, `logName:"curated"`) || !strings.Contains(explain, "AND\n") {
+		t.Errorf("explain = %q, want the curated filter", explain)
+	}
+	if responses[6].Error == nil || responses[6].Error.Code != mcpMethodNotFound {
+		t.Errorf("resources/list error = %+v", responses[6].Error)
+	}
+	if responses[7].Error == nil || responses[7].Error.Code != mcpParseError {
+		t.Errorf("invalid JSON error = %+v", responses[7].Error)
+	}
+}
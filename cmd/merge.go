@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/input"
+	"github.com/spf13/cobra"
+)
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge FILE...",
+	Short: "Merge exported files into a single ordered stream",
+	Long: `Merge files of JSON lines entries, as written by grapple, into a single
+stream ordered by timestamp. Files ending in .gz and .zst are decompressed,
+"-" reads stdin.
+
+Each file must already be ordered like the output (--order, newest first by
+default), as the files are merged while being read. With --dedupe, entries
+with the same log name and insertId are only printed once.`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dedupe, err := cmd.Flags().GetBool("dedupe")
+		cobra.CheckErr(err)
+
+		order := flagOrConfig(cmd, "order")
+		if order != "asc" && order != "desc" {
+			cobra.CheckErr(fmt.Errorf("invalid --order %q, valid values: asc, desc", order))
+		}
+
+		var sources []entrySource
+		for _, path := range args {
+			r, err := input.Open(path)
+			cobra.CheckErr(err)
+			defer r.Close()
+			sources = append(sources, input.NewEntryReader(path, r))
+		}
+
+		out, err := openOutput(cmd)
+		cobra.CheckErr(err)
+
+		merged, duplicates, err := mergeEntries(sources, order == "desc", dedupe, func(entry *loggingpb.LogEntry) error {
+			return printJSON(entry)
+		})
+		if dedupe {
+			log.Printf("Merged %d entries, dropped %d duplicates", merged, duplicates)
+		}
+		cobra.CheckErr(errors.Join(err, out.Close()))
+	},
+}
+
+// entrySource is a stream of log entries ending with io.EOF
+type entrySource interface {
+	Next() (*loggingpb.LogEntry, error)
+	Name() string
+}
+
+// mergeEntries interleaves ordered sources into a single ordered stream, optionally dropping the
+// entries whose log name and insertId were already emitted, returning how many were emitted and dropped
+func mergeEntries(sources []entrySource, newestFirst, dedupe bool, emit func(*loggingpb.LogEntry) error) (merged, duplicates int, err error) {
+	h := &entryHeap{newestFirst: newestFirst}
+	for _, source := range sources {
+		if _, err := h.pushNext(source); err != nil {
+			return merged, duplicates, err
+		}
+	}
+
+	// Duplicates share their timestamp, so only the keys at the current one need remembering.
+	var current *loggingpb.LogEntry
+	seen := map[string]bool{}
+
+	for h.Len() > 0 {
+		head := heap.Pop(h).(*mergeHead)
+		entry := head.entry
+
+		if current == nil || !entry.Timestamp.AsTime().Equal(current.Timestamp.AsTime()) {
+			clear(seen)
+		}
+		current = entry
+
+		key := entry.LogName + "\x00" + entry.InsertId
+		if dedupe && entry.InsertId != "" && seen[key] {
+			duplicates++
+		} else {
+			seen[key] = true
+			if err := emit(entry); err != nil {
+				return merged, duplicates, err
+			}
+			merged++
+		}
+
+		next, err := h.pushNext(head.source)
+		if err != nil {
+			return merged, duplicates, err
+		}
+		if next != nil && h.before(next, entry) {
+			return merged, duplicates, fmt.Errorf("%s is not ordered by timestamp (%s), check --order", head.source.Name(), orderName(newestFirst))
+		}
+	}
+	return merged, duplicates, nil
+}
+
+func orderName(newestFirst bool) string {
+	if newestFirst {
+		return "desc"
+	}
+	return "asc"
+}
+
+// mergeHead is the next entry of a source
+type mergeHead struct {
+	entry  *loggingpb.LogEntry
+	source entrySource
+}
+
+// entryHeap orders the heads of the sources by timestamp, implementing heap.Interface
+type entryHeap struct {
+	heads       []*mergeHead
+	newestFirst bool
+}
+
+// pushNext reads the next entry of source onto the heap, returning it unless the source is exhausted
+func (h *entryHeap) pushNext(source entrySource) (*loggingpb.LogEntry, error) {
+	entry, err := source.Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	heap.Push(h, &mergeHead{entry, source})
+	return entry, nil
+}
+
+// before reports whether a comes strictly before b in the output order
+func (h *entryHeap) before(a, b *loggingpb.LogEntry) bool {
+	if h.newestFirst {
+		return a.Timestamp.AsTime().After(b.Timestamp.AsTime())
+	}
+	return a.Timestamp.AsTime().Before(b.Timestamp.AsTime())
+}
+
+func (h *entryHeap) Len() int           { return len(h.heads) }
+func (h *entryHeap) Less(i, j int) bool { return h.before(h.heads[i].entry, h.heads[j].entry) }
+func (h *entryHeap) Swap(i, j int)      { h.heads[i], h.heads[j] = h.heads[j], h.heads[i] }
+func (h *entryHeap) Push(x any)         { h.heads = append(h.heads, x.(*mergeHead)) }
+func (h *entryHeap) Pop() any {
+	head := h.heads[len(h.heads)-1]
+	h.heads = h.heads[:len(h.heads)-1]
+	return head
+}
+
+func init() {
+	mergeCmd.Flags().Bool("dedupe", false, "drop entries whose log name and insertId were already printed")
+	mergeCmd.Flags().String("order", "desc", "ordering of the input files and of the output, valid values: asc, desc")
+	addOutputFlags(mergeCmd)
+
+	rootCmd.AddCommand(mergeCmd)
+}
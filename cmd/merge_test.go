@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/input"
+)
+
+func TestMergeEntries(t *testing.T) {
+	first := `{"insertId":"a","logName":"l","timestamp":"2025-01-01T00:00:03Z"}
+{"insertId":"c","logName":"l","timestamp":"2025-01-01T00:00:01Z"}
+`
+	second := `{"insertId":"b","logName":"l","timestamp":"2025-01-01T00:00:02Z"}
+{"insertId":"c","logName":"l","timestamp":"2025-01-01T00:00:01Z"}
+{"insertId":"d","logName":"l","timestamp":"2025-01-01T00:00:00Z"}
+`
+	sources := func() []entrySource {
+		return []entrySource{
+			input.NewEntryReader("first", strings.NewReader(first)),
+			input.NewEntryReader("second", strings.NewReader(second)),
+		}
+	}
+
+	var ids []string
+	collect := func(entry *loggingpb.LogEntry) error {
+		ids = append(ids, entry.InsertId)
+		return nil
+	}
+
+	merged, duplicates, err := mergeEntries(sources(), true, true, collect)
+	if err != nil {
+		t.Fatalf("mergeEntries() unexpected error: %v", err)
+	}
+	if got := strings.Join(ids, ","); got != "a,b,c,d" || merged != 4 || duplicates != 1 {
+		t.Errorf("mergeEntries() = %s (%d merged, %d duplicates), want a,b,c,d (4 merged, 1 duplicate)", got, merged, duplicates)
+	}
+
+	ids = nil
+	if _, _, err := mergeEntries(sources(), false, false, collect); err == nil || !strings.Contains(err.Error(), "is not ordered") {
+		t.Errorf("mergeEntries() in the wrong order = %v, want an ordering error", err)
+	}
+}
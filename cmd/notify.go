@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/cluster"
+)
+
+// notifyTimeout bounds each webhook request of --notify
+const notifyTimeout = 10 * time.Second
+
+// notifyKey is the setting of the config file with the default --notify target
+const notifyKey = "notify"
+
+// notification is the JSON payload POSTed by --notify, text makes it a valid Slack message
+type notification struct {
+	Text        string    `json:"text"`
+	Fingerprint string    `json:"fingerprint"`
+	Count       int       `json:"count"`
+	Since       time.Time `json:"since"`
+	LogName     string    `json:"logName"`
+	Severity    string    `json:"severity"`
+	Example     string    `json:"example"`
+}
+
+// notifyGroup tracks the entries sharing a fingerprint since the last notification,
+// it is persisted in the checkpoints of --checkpoint
+type notifyGroup struct {
+	LogName  string    `json:"logName"`
+	Severity string    `json:"severity"`
+	Template string    `json:"template"`
+	Example  string    `json:"example,omitempty"`
+	SentAt   time.Time `json:"sentAt"`
+	Pending  int       `json:"pending,omitempty"`
+}
+
+// notifier sends a notification for the first entry of each fingerprint, then at most one per
+// cooldown with the count of the occurrences in between
+type notifier struct {
+	mu       sync.Mutex
+	cooldown time.Duration
+	send     func(notification) error
+	groups   map[string]*notifyGroup
+}
+
+func newNotifier(cooldown time.Duration, send func(notification) error) *notifier {
+	return &notifier{cooldown: cooldown, send: send, groups: map[string]*notifyGroup{}}
+}
+
+// entryFingerprint identifies the entries of the same log and severity with the same message pattern
+func entryFingerprint(entry *loggingpb.LogEntry) (string, string) {
+	template := cluster.Normalize(payloadSummary(entry))
+	sum := sha256.Sum256([]byte(entry.LogName + "\x00" + entry.Severity.String() + "\x00" + template))
+	return hex.EncodeToString(sum[:6]), template
+}
+
+// observe notifies the entry right away if its fingerprint is new or cooled down, otherwise counts it
+func (n *notifier) observe(entry *loggingpb.LogEntry, now time.Time) {
+	fingerprint, template := entryFingerprint(entry)
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.flushLocked(now, false)
+	g, ok := n.groups[fingerprint]
+	if !ok {
+		g = &notifyGroup{LogName: entry.LogName, Severity: entry.Severity.String(), Template: template, SentAt: now}
+		n.groups[fingerprint] = g
+		message := payloadSummary(entry)
+		n.deliver(notification{
+			Text:        fmt.Sprintf("%s %s: %s", g.Severity, shortLogName(g.LogName), message),
+			Fingerprint: fingerprint,
+			Count:       1,
+			Since:       now,
+			LogName:     g.LogName,
+			Severity:    g.Severity,
+			Example:     message,
+		})
+		return
+	}
+	g.Pending++
+	g.Example = payloadSummary(entry)
+}
+
+// flush sends the counts of the fingerprints whose cooldown is over, or of all of them when final
+func (n *notifier) flush(now time.Time, final bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.flushLocked(now, final)
+}
+
+func (n *notifier) flushLocked(now time.Time, final bool) {
+	for fingerprint, g := range n.groups {
+		if !final && now.Sub(g.SentAt) < n.cooldown {
+			continue
+		}
+		if g.Pending == 0 {
+			delete(n.groups, fingerprint)
+			continue
+		}
+		occurrences := "occurrences"
+		if g.Pending == 1 {
+			occurrences = "occurrence"
+		}
+		window := now.Sub(g.SentAt).Round(time.Second)
+		n.deliver(notification{
+			Text:        fmt.Sprintf("%d new %s of %q in the last %s (%s %s)", g.Pending, occurrences, g.Template, window, g.Severity, shortLogName(g.LogName)),
+			Fingerprint: fingerprint,
+			Count:       g.Pending,
+			Since:       g.SentAt,
+			LogName:     g.LogName,
+			Severity:    g.Severity,
+			Example:     g.Example,
+		})
+		g.SentAt = now
+		g.Pending = 0
+	}
+}
+
+// snapshot returns a copy of the state of the fingerprints, for checkpoints
+func (n *notifier) snapshot() map[string]*notifyGroup {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	groups := make(map[string]*notifyGroup, len(n.groups))
+	for fingerprint, g := range n.groups {
+		copied := *g
+		groups[fingerprint] = &copied
+	}
+	return groups
+}
+
+// restore replaces the state of the fingerprints with the one of a checkpoint
+func (n *notifier) restore(groups map[string]*notifyGroup) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.groups = map[string]*notifyGroup{}
+	for fingerprint, g := range groups {
+		copied := *g
+		n.groups[fingerprint] = &copied
+	}
+}
+
+// retarget sends the next notifications with send
+func (n *notifier) retarget(send func(notification) error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.send = send
+}
+
+func (n *notifier) deliver(msg notification) {
+	if err := n.send(msg); err != nil {
+		log.Printf("Warning: notification for %s failed: %v", msg.Fingerprint, err)
+	}
+}
+
+// run flushes the due counts at each interval and all of them when the context is done
+func (n *notifier) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.flush(time.Now(), true)
+			return
+		case now := <-ticker.C:
+			n.flush(now, false)
+		}
+	}
+}
+
+// postNotification returns a sender POSTing the notifications as JSON to a webhook URL
+func postNotification(url string) func(notification) error {
+	return postWebhook[notification](url)
+}
+
+// postWebhook returns a sender POSTing messages as JSON to a webhook URL
+func postWebhook[T any](url string) func(T) error {
+	client := &http.Client{Timeout: notifyTimeout}
+	return func(msg T) error {
+		body, err := json.Marshal(msg)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("webhook responded %s", resp.Status)
+		}
+		return nil
+	}
+}
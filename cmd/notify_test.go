@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+func TestNotifierCooldown(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(message string) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{
+			LogName:  "projects/p/logs/app",
+			Severity: logtypepb.LogSeverity_ERROR,
+			Payload:  &loggingpb.LogEntry_TextPayload{TextPayload: message},
+		}
+	}
+
+	var sent []notification
+	n := newNotifier(5*time.Minute, func(msg notification) error {
+		sent = append(sent, msg)
+		return nil
+	})
+
+	n.observe(entry("timeout after 30s"), start)
+	for i := 0; i < 42; i++ {
+		n.observe(entry("timeout after 31s"), start.Add(time.Minute))
+	}
+	n.observe(entry("disk full"), start.Add(time.Minute))
+	n.flush(start.Add(4*time.Minute), false)
+	if len(sent) != 2 {
+		t.Fatalf("sent %d notifications during the cooldown, want 2: %+v", len(sent), sent)
+	}
+	if sent[0].Count != 1 || sent[0].Text != "ERROR app: timeout after 30s" {
+		t.Errorf("first notification = %+v", sent[0])
+	}
+
+	n.flush(start.Add(5*time.Minute), false)
+	if len(sent) != 3 {
+		t.Fatalf("sent %d notifications after the cooldown, want 3: %+v", len(sent), sent)
+	}
+	expected := `42 new occurrences of "timeout after <num>s" in the last 5m0s (ERROR app)`
+	if sent[2].Count != 42 || sent[2].Text != expected || sent[2].Fingerprint != sent[0].Fingerprint {
+		t.Errorf("aggregated notification = %+v, want count 42 and text %q", sent[2], expected)
+	}
+
+	// The disk full group cooled down without new occurrences and starts over.
+	n.observe(entry("disk full"), start.Add(10*time.Minute))
+	if len(sent) != 4 || sent[3].Count != 1 || sent[3].Text != "ERROR app: disk full" {
+		t.Errorf("notifications = %+v, want a new single one for disk full", sent)
+	}
+
+	n.observe(entry("disk full"), start.Add(11*time.Minute))
+	n.flush(start.Add(11*time.Minute), true)
+	if len(sent) != 5 || sent[4].Count != 1 || sent[4].Example != "disk full" {
+		t.Errorf("final flush = %+v, want the pending disk full occurrence", sent)
+	}
+}
+
+func TestPostNotification(t *testing.T) {
+	var received notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("decoding the payload: %v", err)
+		}
+		if received.Count > 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	send := postNotification(server.URL)
+	if err := send(notification{Text: "hello", Count: 1}); err != nil {
+		t.Fatalf("send() unexpected error: %v", err)
+	}
+	if received.Text != "hello" {
+		t.Errorf("received text = %q, want %q", received.Text, "hello")
+	}
+	if err := send(notification{Text: "hello", Count: 2}); err == nil {
+		t.Errorf("send() expected error on a 502 response, got nil")
+	}
+}
@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/otlp"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var exportOTLPCmd = &cobra.Command{
+	Use:   "otlp --endpoint HOST:PORT [filter]",
+	Short: "Export entries to an OpenTelemetry collector",
+	Long: `Convert the entries matching the filter into OpenTelemetry log records and
+send them to a collector, or any backend receiving OTLP/gRPC, in requests of
+--batch-size records:
+
+  timestamp, receiveTimestamp  time, observed time
+  severity                     severity number and text (ERROR is 17)
+  trace, spanId, traceSampled  trace ID, span ID and flags
+  textPayload, jsonPayload,    body, a string or a map
+  protoPayload
+  labels                       attributes, with gcp.log_name, log.record.uid
+                               (the insertId), the http.*, url.full, code.*
+                               attributes of httpRequest and sourceLocation
+  resource                     resource attributes: cloud.provider,
+                               gcp.resource_type, cloud.account.id,
+                               cloud.region, k8s.*, service.name... and
+                               gcp.resource.labels.* for the other labels
+
+The connection uses TLS, unless --insecure is given for collectors listening
+in plaintext, e.g. on localhost:4317.`,
+	Annotations: map[string]string{annotationWritesOutput: "true"},
+	Args:        cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newOTLPSink)
+	},
+}
+
+// otlpSeverities maps the severities of the entries to the severity numbers of OpenTelemetry
+var otlpSeverities = map[logtypepb.LogSeverity]int32{
+	logtypepb.LogSeverity_DEFAULT:   otlp.SeverityUnspecified,
+	logtypepb.LogSeverity_DEBUG:     otlp.SeverityDebug,
+	logtypepb.LogSeverity_INFO:      otlp.SeverityInfo,
+	logtypepb.LogSeverity_NOTICE:    otlp.SeverityInfo2,
+	logtypepb.LogSeverity_WARNING:   otlp.SeverityWarn,
+	logtypepb.LogSeverity_ERROR:     otlp.SeverityError,
+	logtypepb.LogSeverity_CRITICAL:  otlp.SeverityError2,
+	logtypepb.LogSeverity_ALERT:     otlp.SeverityError3,
+	logtypepb.LogSeverity_EMERGENCY: otlp.SeverityFatal,
+}
+
+// otlpResourceLabels maps the labels of the monitored resources to the resource attributes of the
+// semantic conventions, the others becoming gcp.resource.labels.KEY
+var otlpResourceLabels = map[string]string{
+	"project_id":     "cloud.account.id",
+	"zone":           "cloud.availability_zone",
+	"region":         "cloud.region",
+	"location":       "cloud.region",
+	"instance_id":    "host.id",
+	"cluster_name":   "k8s.cluster.name",
+	"namespace_name": "k8s.namespace.name",
+	"pod_name":       "k8s.pod.name",
+	"container_name": "k8s.container.name",
+	"node_name":      "k8s.node.name",
+	"service_name":   "service.name",
+	"module_id":      "service.name",
+	"function_name":  "faas.name",
+}
+
+// otlpPlatforms maps the types of the monitored resources to the cloud.platform attribute
+var otlpPlatforms = map[string]string{
+	"gce_instance":       "gcp_compute_engine",
+	"k8s_container":      "gcp_kubernetes_engine",
+	"k8s_pod":            "gcp_kubernetes_engine",
+	"k8s_node":           "gcp_kubernetes_engine",
+	"k8s_cluster":        "gcp_kubernetes_engine",
+	"cloud_run_revision": "gcp_cloud_run",
+	"cloud_run_job":      "gcp_cloud_run",
+	"cloud_function":     "gcp_cloud_functions",
+	"gae_app":            "gcp_app_engine",
+}
+
+// otlpExporter sends the entries to a collector in batches, grouped by resource
+type otlpExporter struct {
+	ctx       context.Context
+	client    *otlp.Client
+	endpoint  string
+	batchSize int
+
+	batch     []otlp.ResourceLogs
+	resources map[string]int
+	records   int
+	exported  int
+	rejected  int64
+	// err is the failure of a request that stopped the exporter.
+	err error
+}
+
+// newOTLPSink returns the function adding each entry to the batch to send to the --endpoint, and the
+// function sending the last batch
+func newOTLPSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	endpoint := cmd.Flag("endpoint").Value.String()
+	batchSize, err := cmd.Flags().GetInt("batch-size")
+	if err != nil {
+		return nil, nil, err
+	}
+	if batchSize <= 0 {
+		return nil, nil, fmt.Errorf("invalid --batch-size %d", batchSize)
+	}
+	insecure, err := cmd.Flags().GetBool("insecure")
+	if err != nil {
+		return nil, nil, err
+	}
+	pairs, err := cmd.Flags().GetStringArray("header")
+	if err != nil {
+		return nil, nil, err
+	}
+	headers := map[string]string{}
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, nil, fmt.Errorf("invalid header %q, expected key=value", pair)
+		}
+		headers[key] = value
+	}
+	config, err := tlsConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := otlp.NewClient(endpoint, otlp.Options{
+		Insecure: insecure,
+		TLS:      config,
+		Headers:  headers,
+		Scope:    otlp.Scope{Name: "grapple", Version: version},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &otlpExporter{ctx: cmd.Context(), client: client, endpoint: endpoint, batchSize: batchSize, resources: map[string]int{}}
+	return e.process, e.flush, nil
+}
+
+func (e *otlpExporter) process(entry *loggingpb.LogEntry) error {
+	if e.err != nil {
+		return grapple.ErrStop
+	}
+	record, err := otlpRecord(entry)
+	if err != nil {
+		return err
+	}
+	resource := otlpResource(entry.Resource, entry.LogName)
+	key := fmt.Sprint(resource)
+	i, ok := e.resources[key]
+	if !ok {
+		i = len(e.batch)
+		e.resources[key] = i
+		e.batch = append(e.batch, otlp.ResourceLogs{Attributes: resource})
+	}
+	e.batch[i].Records = append(e.batch[i].Records, record)
+	if e.records++; e.records == e.batchSize {
+		if e.err = e.send(); e.err != nil {
+			return grapple.ErrStop
+		}
+	}
+	return nil
+}
+
+// send sends the batch in one request
+func (e *otlpExporter) send() error {
+	if e.records == 0 {
+		return nil
+	}
+	err := e.client.Export(e.ctx, e.batch)
+	var partial *otlp.PartialSuccessError
+	if errors.As(err, &partial) {
+		log.Printf("Warning: %s rejected %d records: %s", e.endpoint, partial.Rejected, partial.Message)
+		e.rejected += partial.Rejected
+		err = nil
+	}
+	if err != nil {
+		return fmt.Errorf("exporting to %s: %w", e.endpoint, err)
+	}
+	e.exported += e.records
+	e.batch, e.records = nil, 0
+	clear(e.resources)
+	return nil
+}
+
+// flush sends the last batch and closes the connection
+func (e *otlpExporter) flush() error {
+	if e.err == nil {
+		e.err = e.send()
+	}
+	e.client.Close()
+	log.Printf("Exported %d entries to %s", int64(e.exported)-e.rejected, e.endpoint)
+	return e.err
+}
+
+// otlpRecord converts an entry into a log record, its resource aside
+func otlpRecord(entry *loggingpb.LogEntry) (otlp.Record, error) {
+	record := otlp.Record{
+		SeverityNumber: otlpSeverities[entry.Severity],
+		SeverityText:   entry.Severity.String(),
+		TraceID:        otlpID(entry.Trace[strings.LastIndex(entry.Trace, "/")+1:], 16),
+		SpanID:         otlpID(entry.SpanId, 8),
+	}
+	if entry.Timestamp != nil {
+		record.Time = entry.Timestamp.AsTime()
+	}
+	if entry.ReceiveTimestamp != nil {
+		record.ObservedTime = entry.ReceiveTimestamp.AsTime()
+	}
+	if entry.TraceSampled {
+		record.Flags = otlp.FlagSampled
+	}
+
+	switch payload := entry.Payload.(type) {
+	case *loggingpb.LogEntry_TextPayload:
+		record.Body = payload.TextPayload
+	case *loggingpb.LogEntry_JsonPayload:
+		record.Body = payload.JsonPayload.AsMap()
+	case *loggingpb.LogEntry_ProtoPayload:
+		data, err := protojson.Marshal(payload.ProtoPayload)
+		if err != nil {
+			return record, err
+		}
+		var body map[string]any
+		if err := json.Unmarshal(data, &body); err != nil {
+			return record, err
+		}
+		record.Body = body
+	}
+
+	add := func(key string, value any) {
+		if value != "" && value != int64(0) && value != 0.0 && value != false {
+			record.Attributes = append(record.Attributes, otlp.KeyValue{Key: key, Value: value})
+		}
+	}
+	add("gcp.log_name", entry.LogName)
+	add("log.record.uid", entry.InsertId)
+	if r := entry.HttpRequest; r != nil {
+		add("http.request.method", r.RequestMethod)
+		add("url.full", r.RequestUrl)
+		add("http.response.status_code", int64(r.Status))
+		add("http.request.size", r.RequestSize)
+		add("http.response.size", r.ResponseSize)
+		add("user_agent.original", r.UserAgent)
+		add("client.address", r.RemoteIp)
+		add("server.address", r.ServerIp)
+		if r.Referer != "" {
+			add("http.request.header.referer", []any{r.Referer})
+		}
+		add("network.protocol.name", r.Protocol)
+		if r.Latency != nil {
+			add("gcp.http_request.latency", r.Latency.AsDuration().Seconds())
+		}
+	}
+	if l := entry.SourceLocation; l != nil {
+		add("code.file.path", l.File)
+		add("code.line.number", l.Line)
+		add("code.function.name", l.Function)
+	}
+	if o := entry.Operation; o != nil {
+		add("gcp.operation.id", o.Id)
+		add("gcp.operation.producer", o.Producer)
+		add("gcp.operation.first", o.First)
+		add("gcp.operation.last", o.Last)
+	}
+	for _, key := range sortedKeys(entry.Labels) {
+		add(key, entry.Labels[key])
+	}
+	return record, nil
+}
+
+// otlpID decodes a hexadecimal trace or span ID, nil unless it has the size of the IDs of OpenTelemetry
+func otlpID(id string, size int) []byte {
+	decoded, err := hex.DecodeString(id)
+	if err != nil || len(decoded) != size {
+		return nil
+	}
+	return decoded
+}
+
+// otlpResource returns the attributes of the resource of an entry
+func otlpResource(resource *monitoredres.MonitoredResource, logName string) []otlp.KeyValue {
+	attributes := map[string]string{"cloud.provider": "gcp"}
+	if projectId, _, found := strings.Cut(strings.TrimPrefix(logName, "projects/"), "/"); found && strings.HasPrefix(logName, "projects/") {
+		attributes["cloud.account.id"] = projectId
+	}
+	if resource != nil {
+		attributes["gcp.resource_type"] = resource.Type
+		if platform, ok := otlpPlatforms[resource.Type]; ok {
+			attributes["cloud.platform"] = platform
+		}
+		for key, value := range resource.Labels {
+			if name, ok := otlpResourceLabels[key]; ok {
+				attributes[name] = value
+			} else {
+				attributes["gcp.resource.labels."+key] = value
+			}
+		}
+	}
+	keyValues := make([]otlp.KeyValue, 0, len(attributes))
+	for _, key := range sortedKeys(attributes) {
+		keyValues = append(keyValues, otlp.KeyValue{Key: key, Value: attributes[key]})
+	}
+	return keyValues
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func init() {
+	addFetchFlags(exportOTLPCmd)
+	exportOTLPCmd.Flags().String("endpoint", "", "address of the collector, as HOST:PORT (e.g. localhost:4317)")
+	exportOTLPCmd.Flags().Bool("insecure", false, "connect to the collector without TLS")
+	exportOTLPCmd.Flags().StringArray("header", nil, "send this header with the requests, as key=value, e.g. the API key of a backend (repeatable)")
+	exportOTLPCmd.Flags().Int("batch-size", 500, "number of entries sent per request")
+	exportOTLPCmd.MarkFlagRequired("endpoint")
+
+	exportCmd.AddCommand(exportOTLPCmd)
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/otlp"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestOTLPRecord(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]any{"message": "down"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	entry := &loggingpb.LogEntry{
+		LogName:        "projects/p/logs/app",
+		InsertId:       "a",
+		Timestamp:      timestamppb.New(ts),
+		Severity:       logtypepb.LogSeverity_CRITICAL,
+		Trace:          "projects/p/traces/4bf92f3577b34da6a3ce929d0e0e4736",
+		SpanId:         "00f067aa0ba902b7",
+		TraceSampled:   true,
+		Labels:         map[string]string{"team": "core"},
+		HttpRequest:    &logtypepb.HttpRequest{RequestMethod: "GET", Status: 503},
+		SourceLocation: &loggingpb.LogEntrySourceLocation{File: "main.go", Line: 42},
+		Payload:        &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+	}
+	record, err := otlpRecord(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !record.Time.Equal(ts) || !record.ObservedTime.IsZero() {
+		t.Errorf("times %v and %v", record.Time, record.ObservedTime)
+	}
+	if record.SeverityNumber != otlp.SeverityError2 || record.SeverityText != "CRITICAL" {
+		t.Errorf("severity %d %s", record.SeverityNumber, record.SeverityText)
+	}
+	if hex.EncodeToString(record.TraceID) != "4bf92f3577b34da6a3ce929d0e0e4736" || hex.EncodeToString(record.SpanID) != "00f067aa0ba902b7" || record.Flags != otlp.FlagSampled {
+		t.Errorf("trace %x, span %x, flags %d", record.TraceID, record.SpanID, record.Flags)
+	}
+	if body, ok := record.Body.(map[string]any); !ok || body["message"] != "down" {
+		t.Errorf("body %v", record.Body)
+	}
+	expected := "[{gcp.log_name projects/p/logs/app} {log.record.uid a} {http.request.method GET} {http.response.status_code 503} {code.file.path main.go} {code.line.number 42} {team core}]"
+	if attributes := fmt.Sprint(record.Attributes); attributes != expected {
+		t.Errorf("attributes %s, want %s", attributes, expected)
+	}
+
+	// IDs of other formats, e.g. from other tracers, are left out.
+	record, _ = otlpRecord(&loggingpb.LogEntry{Trace: "abc", SpanId: "123", Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "up"}})
+	if record.TraceID != nil || record.SpanID != nil || record.Body != "up" {
+		t.Errorf("record %+v", record)
+	}
+}
+
+func TestOTLPResource(t *testing.T) {
+	resource := &monitoredres.MonitoredResource{
+		Type:   "k8s_container",
+		Labels: map[string]string{"project_id": "p", "cluster_name": "prod", "location": "europe-west1", "pod_name": "web-1", "custom": "x"},
+	}
+	expected := "[{cloud.account.id p} {cloud.platform gcp_kubernetes_engine} {cloud.provider gcp} {cloud.region europe-west1} {gcp.resource.labels.custom x} {gcp.resource_type k8s_container} {k8s.cluster.name prod} {k8s.pod.name web-1}]"
+	if attributes := fmt.Sprint(otlpResource(resource, "projects/p/logs/stdout")); attributes != expected {
+		t.Errorf("resource attributes %s, want %s", attributes, expected)
+	}
+	// Without a resource, the project comes from the log name.
+	if attributes := fmt.Sprint(otlpResource(nil, "projects/q/logs/app")); attributes != "[{cloud.account.id q} {cloud.provider gcp}]" {
+		t.Errorf("resource attributes %s", attributes)
+	}
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dippi/grapple/internal/output"
+	"github.com/spf13/cobra"
+)
+
+// stdout is where the formatted entries are written, see openOutput
+var stdout io.Writer = os.Stdout
+
+// addOutputFlags registers the flags selecting the destination of the entries, see openOutput
+func addOutputFlags(c *cobra.Command) {
+	c.Flags().StringP("output", "o", "", "write entries to this file instead of stdout, or to Cloud Storage: gs://BUCKET/OBJECT, or gs://BUCKET/PREFIX/ for a series of objects, or send them to a syslog server: syslog://HOST[:PORT][?proto=udp|tcp|tls&facility=NAME]")
+	c.Flags().String("compress", "auto", "output compression, valid values: auto (from the file extension), none, gzip, zstd")
+	c.Flags().String("rotate-size", "", "rotate the output file after this much data (e.g. 100MB, 1GiB)")
+	c.Flags().String("rotate-interval", "", "rotate the output file after this long (e.g. 1h, 1d)")
+	c.Flags().Bool("append", false, "append to the --output file instead of overwriting it")
+
+	c.MarkFlagFilename("output")
+}
+
+// openOutput opens the destination selected by the output flags and installs it as stdout.
+// The returned writer must be closed to flush compressed output.
+func openOutput(cmd *cobra.Command) (io.WriteCloser, error) {
+	if cmd.Annotations[annotationWritesOutput] != "" || isSyslogOutput(cmd) {
+		// The command or sink writes --output itself, e.g. export sqlite: stdout stays the terminal.
+		return output.Open(output.Options{Compression: output.CompressNone})
+	}
+	path := cmd.Flag("output").Value.String()
+	compression := cmd.Flag("compress").Value.String()
+
+	rotateSize, err := parseSize(cmd.Flag("rotate-size").Value.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid --rotate-size: %w", err)
+	}
+	var rotateInterval time.Duration
+	if interval := cmd.Flag("rotate-interval").Value.String(); interval != "" {
+		rotateInterval, err = parseFreshness(interval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --rotate-interval: %w", err)
+		}
+	}
+
+	format := ""
+	if cmd.Flag("format") != nil {
+		format = entryFormat(cmd)
+	}
+	if format == formatParquet {
+		// Parquet files compress their pages, see newParquetSink.
+		compression = output.CompressNone
+	}
+
+	appendOutput, err := cmd.Flags().GetBool("append")
+	if err != nil {
+		return nil, err
+	}
+	if appendOutput && (format == formatParquet || strings.HasPrefix(path, gcsScheme)) {
+		return nil, errors.New("--append cannot be used together with --format parquet and gs:// outputs")
+	}
+
+	opts := output.Options{
+		Path:           path,
+		Compression:    compression,
+		RotateSize:     rotateSize,
+		RotateInterval: rotateInterval,
+		Append:         appendOutput,
+	}
+	if strings.HasPrefix(path, gcsScheme) {
+		uploader, err := newGCSUploader(cmd.Context())
+		if err != nil {
+			return nil, err
+		}
+		opts.Create = uploader.create
+		opts.Extension = formatExtension(format)
+	}
+	out, err := output.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	stdout = out
+	return out, nil
+}
+
+// formatExtension returns the extension of the files of a format
+func formatExtension(format string) string {
+	switch format {
+	case formatJSON, formatK8s:
+		return ".ndjson"
+	case formatCSV, formatTSV, formatParquet:
+		return "." + format
+	default:
+		return ".log"
+	}
+}
+
+// parseSize converts strings like "500", "100KB", "1.5GiB" into a number of bytes.
+// Decimal (KB, MB, GB) and binary (KiB, MiB, GiB) units are supported, an empty string means 0.
+func parseSize(expression string) (int64, error) {
+	if expression == "" {
+		return 0, nil
+	}
+
+	re := regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*([KMGT]I?B|B)?$`)
+	match := re.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(expression)))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size %q", expression)
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", expression)
+	}
+
+	multipliers := map[string]float64{
+		"": 1, "B": 1,
+		"KB": 1e3, "MB": 1e6, "GB": 1e9, "TB": 1e12,
+		"KIB": 1 << 10, "MIB": 1 << 20, "GIB": 1 << 30, "TIB": 1 << 40,
+	}
+	return int64(value * multipliers[match[2]]), nil
+}
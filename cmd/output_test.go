@@ -0,0 +1,37 @@
+package cmd
+
+import "testing"
+
+func TestParseSize(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected int64
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"512", 512, false},
+		{"100KB", 100_000, false},
+		{"1.5GiB", 3 << 29, false},
+		{"10 mb", 10_000_000, false},
+		{"10MiB", 10 << 20, false},
+		{"lots", 0, true},
+		{"-1KB", 0, true},
+	}
+
+	for _, c := range cases {
+		size, err := parseSize(c.input)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("parseSize(%q) expected error, got nil", c.input)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseSize(%q) unexpected error: %v", c.input, err)
+			continue
+		}
+		if size != c.expected {
+			t.Errorf("parseSize(%q) = %v, want %v", c.input, size, c.expected)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/output"
+	"github.com/dippi/grapple/internal/parquet"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// parquetColumns is the schema of --format parquet, the same whatever the entries, so that the
+// files of different runs can be queried together
+var parquetColumns = []parquet.Column{
+	{Name: "timestamp", Type: parquet.Timestamp},
+	{Name: "receiveTimestamp", Type: parquet.Timestamp},
+	{Name: "severity", Type: parquet.String},
+	{Name: "logName", Type: parquet.String},
+	{Name: "insertId", Type: parquet.String},
+	{Name: "resourceType", Type: parquet.String},
+	{Name: "resourceLabels", Type: parquet.JSON},
+	{Name: "labels", Type: parquet.JSON},
+	{Name: "trace", Type: parquet.String},
+	{Name: "spanId", Type: parquet.String},
+	{Name: "httpRequest", Type: parquet.JSON},
+	{Name: "operation", Type: parquet.JSON},
+	{Name: "sourceLocation", Type: parquet.JSON},
+	{Name: "textPayload", Type: parquet.String},
+	{Name: "payload", Type: parquet.JSON},
+}
+
+// stdoutProxy writes to the current stdout, which openOutput replaces after the sinks are created
+type stdoutProxy struct{}
+
+func (stdoutProxy) Write(p []byte) (int, error) {
+	return stdout.Write(p)
+}
+
+// newParquetSink returns the function adding each entry to the Parquet file written to --output,
+// and the function writing its footer
+func newParquetSink(cmd *cobra.Command) (process func(*loggingpb.LogEntry) error, flush func() error, err error) {
+	for _, name := range []string{"fields", "columns", "grep"} {
+		if cmd.Flags().Changed(name) {
+			return nil, nil, fmt.Errorf("--%s is not supported with --format parquet", name)
+		}
+	}
+	if writesToTerminal(cmd) {
+		return nil, nil, errors.New("--format parquet requires --output or a redirected stdout")
+	}
+	if path := cmd.Flag("output").Value.String(); strings.HasPrefix(path, gcsScheme) && strings.HasSuffix(path, "/") {
+		return nil, nil, errors.New("--format parquet writes a single object, give its name rather than a prefix")
+	}
+	if cmd.Flag("rotate-size").Value.String() != "" || cmd.Flag("rotate-interval").Value.String() != "" {
+		return nil, nil, errors.New("--format parquet cannot be used together with --rotate-size and --rotate-interval")
+	}
+
+	// The pages of the file are compressed rather than the file, see openOutput.
+	compression := parquet.CompressZstd
+	switch mode := cmd.Flag("compress").Value.String(); mode {
+	case output.CompressAuto, output.CompressZstd:
+	case output.CompressNone:
+		compression = parquet.CompressNone
+	default:
+		return nil, nil, fmt.Errorf("--compress %s is not supported with --format parquet, valid values: %s, %s, %s", mode, output.CompressAuto, output.CompressNone, output.CompressZstd)
+	}
+
+	w, err := parquet.NewWriter(stdoutProxy{}, parquetColumns, parquet.Options{Compression: compression, CreatedBy: cliName + " " + version})
+	if err != nil {
+		return nil, nil, err
+	}
+	return func(entry *loggingpb.LogEntry) error {
+		row, err := parquetRow(entry)
+		if err != nil {
+			return err
+		}
+		return w.Write(row)
+	}, w.Close, nil
+}
+
+// parquetRow returns the values of the parquetColumns of an entry, nil for the missing fields
+func parquetRow(entry *loggingpb.LogEntry) ([]any, error) {
+	row := []any{
+		parquetTime(entry.Timestamp),
+		parquetTime(entry.ReceiveTimestamp),
+		entry.Severity.String(),
+		optionalString(entry.LogName),
+		optionalString(entry.InsertId),
+		optionalString(entry.Resource.GetType()),
+		nil, // resourceLabels
+		nil, // labels
+		optionalString(entry.Trace),
+		optionalString(entry.SpanId),
+		nil, // httpRequest
+		nil, // operation
+		nil, // sourceLocation
+		optionalString(entry.GetTextPayload()),
+		nil, // payload
+	}
+	var err error
+	if row[6], err = labelsJSON(entry.Resource.GetLabels()); err != nil {
+		return nil, err
+	}
+	if row[7], err = labelsJSON(entry.Labels); err != nil {
+		return nil, err
+	}
+	if row[14], err = payloadJSON(entry); err != nil {
+		return nil, err
+	}
+	for i, message := range map[int]proto.Message{10: entry.HttpRequest, 11: entry.Operation, 12: entry.SourceLocation} {
+		if message == nil || !message.ProtoReflect().IsValid() {
+			continue
+		}
+		value, err := protojson.Marshal(message)
+		if err != nil {
+			return nil, err
+		}
+		row[i] = string(value)
+	}
+	return row, nil
+}
+
+// payloadJSON returns the JSON or proto payload of an entry as JSON, nil for the other payloads
+func payloadJSON(entry *loggingpb.LogEntry) (any, error) {
+	var payload proto.Message
+	switch {
+	case entry.GetJsonPayload() != nil:
+		payload = entry.GetJsonPayload()
+	case entry.GetProtoPayload() != nil:
+		payload = entry.GetProtoPayload()
+	default:
+		return nil, nil
+	}
+	value, err := protojson.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return string(value), nil
+}
+
+// parquetTime returns the time of ts, nil when missing
+func parquetTime(ts *timestamppb.Timestamp) any {
+	if ts == nil {
+		return nil
+	}
+	return ts.AsTime()
+}
+
+// labelsJSON returns labels as a JSON object, nil when empty
+func labelsJSON(labels map[string]string) (any, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return marshalJSONValue(labels)
+}
+
+// optionalString returns s, nil when empty
+func optionalString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
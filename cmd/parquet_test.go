@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestParquetRow(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]any{"message": "boom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	entry := &loggingpb.LogEntry{
+		Timestamp: timestamppb.New(ts),
+		Severity:  logtypepb.LogSeverity_ERROR,
+		LogName:   "projects/p/logs/app",
+		Resource:  &monitoredres.MonitoredResource{Type: "k8s_container", Labels: map[string]string{"namespace_name": "prod"}},
+		Payload:   &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+	}
+
+	row, err := parquetRow(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(row) != len(parquetColumns) {
+		t.Fatalf("row of %d values, %d columns", len(row), len(parquetColumns))
+	}
+	// protojson randomizes its spacing.
+	message, ok := row[14].(string)
+	if !ok || strings.ReplaceAll(message, " ", "") != `{"message":"boom"}` {
+		t.Errorf("payload = %v", row[14])
+	}
+	row[14] = nil
+	expected := []any{ts, nil, "ERROR", "projects/p/logs/app", nil, "k8s_container", `{"namespace_name":"prod"}`, nil, nil, nil, nil, nil, nil, nil, nil}
+	if !reflect.DeepEqual(row, expected) {
+		t.Errorf("parquetRow() = %v, want %v", row, expected)
+	}
+}
+
+func TestParquetSink(t *testing.T) {
+	newCmd := func() *cobra.Command {
+		cmd := &cobra.Command{}
+		addFormatFlags(cmd)
+		addOutputFlags(cmd)
+		cmd.Flags().StringArray("route", nil, "")
+		cmd.Flags().Set("format", formatParquet)
+		cmd.Flags().Set("output", "entries.parquet")
+		return cmd
+	}
+
+	var buf bytes.Buffer
+	saved := stdout
+	stdout = &buf
+	defer func() { stdout = saved }()
+
+	process, flush, err := newEntrySink(newCmd())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := process(&loggingpb.LogEntry{Severity: logtypepb.LogSeverity_INFO, Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "hello"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := flush(); err != nil {
+		t.Fatal(err)
+	}
+	if data := buf.Bytes(); !bytes.HasPrefix(data, []byte("PAR1")) || !bytes.HasSuffix(data, []byte("PAR1")) {
+		t.Errorf("wrote %q, want a Parquet file", data)
+	}
+
+	for flag, value := range map[string]string{"grep": "hello", "stats": "true", "rotate-size": "1MB", "compress": "gzip"} {
+		cmd := newCmd()
+		cmd.Flags().Set(flag, value)
+		if _, _, err := newEntrySink(cmd); err == nil {
+			t.Errorf("expected an error with --%s", flag)
+		}
+	}
+}
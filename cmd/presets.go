@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	_ "embed"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed exclude_presets.yaml
+var excludePresetsData []byte
+
+type excludePreset struct {
+	Description string `yaml:"description"`
+	Filter      string `yaml:"filter"`
+}
+
+// excludePresets are the built-in negative filters, keyed by name
+var excludePresets = mustLoadExcludePresets(excludePresetsData)
+
+func mustLoadExcludePresets(data []byte) map[string]excludePreset {
+	presets := map[string]excludePreset{}
+	if err := yaml.Unmarshal(data, &presets); err != nil {
+		panic(fmt.Sprintf("invalid built-in exclude presets: %v", err))
+	}
+	return presets
+}
+
+// excludePresetNames returns the sorted names of the built-in presets
+func excludePresetNames() []string {
+	names := make([]string, 0, len(excludePresets))
+	for name := range excludePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// buildExcludeFilter turns preset names into a filter excluding everything they match
+func buildExcludeFilter(names []string) (string, error) {
+	var clauses []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		preset, ok := excludePresets[name]
+		if !ok {
+			return "", fmt.Errorf("unknown exclude preset %q, valid values: %s", name, strings.Join(excludePresetNames(), ", "))
+		}
+		clauses = append(clauses, fmt.Sprintf("NOT (%s)", preset.Filter))
+	}
+	return strings.Join(clauses, " AND "), nil
+}
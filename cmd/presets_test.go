@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExcludePresetsAreValid(t *testing.T) {
+	if len(excludePresets) == 0 {
+		t.Fatal("no built-in exclude presets")
+	}
+	for name, preset := range excludePresets {
+		if preset.Description == "" || strings.TrimSpace(preset.Filter) == "" {
+			t.Errorf("exclude preset %q must have a description and a filter", name)
+		}
+	}
+}
+
+func TestBuildExcludeFilter(t *testing.T) {
+	filter, err := buildExcludeFilter([]string{"lb-probes", " gke-healthchecks"})
+	if err != nil {
+		t.Fatalf("buildExcludeFilter() unexpected error: %v", err)
+	}
+	expected := "NOT (" + excludePresets["lb-probes"].Filter + ") AND NOT (" + excludePresets["gke-healthchecks"].Filter + ")"
+	if filter != expected {
+		t.Errorf("buildExcludeFilter() = %q, want %q", filter, expected)
+	}
+
+	if filter, err := buildExcludeFilter(nil); err != nil || filter != "" {
+		t.Errorf("buildExcludeFilter(nil) = %q, %v, want empty", filter, err)
+	}
+
+	if _, err := buildExcludeFilter([]string{"nope"}); err == nil {
+		t.Error("buildExcludeFilter() with an unknown preset expected error, got nil")
+	}
+}
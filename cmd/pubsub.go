@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	pubsub "google.golang.org/api/pubsub/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+const (
+	// pubsubMaxMessages and pubsubMaxBytes bound the publish requests, below the limits of Pub/Sub
+	pubsubMaxMessages = 1000
+	pubsubMaxBytes    = 9 << 20
+
+	// pubsubTimestampAttribute holds the timestamp of the entries, like in the messages of the Pub/Sub sinks
+	pubsubTimestampAttribute = "logging.googleapis.com/timestamp"
+)
+
+var exportPubSubCmd = &cobra.Command{
+	Use:   "pubsub --topic TOPIC [filter]",
+	Short: "Publish entries to a Pub/Sub topic",
+	Long: `Publish each entry matching the filter to a Pub/Sub topic, as the JSON
+message published by the Pub/Sub sinks of Cloud Logging, with the timestamp
+of the entry in the logging.googleapis.com/timestamp attribute, so that
+historical entries can be replayed through the existing streaming pipelines.
+
+The messages carry an ordering key, the logName or the trace of the entry
+(--ordering-key), so that the subscriptions with message ordering enabled
+deliver the entries of a log or a trace in the order they were fetched: use
+--order asc to replay them chronologically.`,
+	Annotations: map[string]string{annotationWritesOutput: "true"},
+	Args:        cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newPubSubSink)
+	},
+}
+
+// pubsubPublisher publishes the entries to a topic in batches, one request at a time so that the
+// messages with the same ordering key are published in order
+type pubsubPublisher struct {
+	topic       string
+	orderingKey string
+	publish     func(messages []*pubsub.PubsubMessage) error
+
+	batch     []*pubsub.PubsubMessage
+	size      int
+	published int
+	// err is the failure of a publish request that stopped the publisher.
+	err error
+}
+
+// newPubSubSink returns the function publishing each entry to the --topic, and the function publishing
+// the last batch
+func newPubSubSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	topic := expandProjectReferences(cmd.Flag("topic").Value.String(), projectAliases())
+	if !strings.HasPrefix(topic, "projects/") {
+		topic = "projects/" + requireProject() + "/topics/" + topic
+	}
+	orderingKey := cmd.Flag("ordering-key").Value.String()
+	switch orderingKey {
+	case "logName", "trace", "none":
+	default:
+		return nil, nil, fmt.Errorf("invalid --ordering-key %q, valid values: logName, trace, none", orderingKey)
+	}
+
+	ctx := cmd.Context()
+	opts, err := clientOptions()
+	if err != nil {
+		return nil, nil, err
+	}
+	service, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &pubsubPublisher{topic: topic, orderingKey: orderingKey, publish: topicPublisher(ctx, service.Projects.Topics, topic)}
+	return p.process, p.flush, nil
+}
+
+// topicPublisher returns the function publishing messages to a topic
+func topicPublisher(ctx context.Context, topics *pubsub.ProjectsTopicsService, topic string) func([]*pubsub.PubsubMessage) error {
+	return func(messages []*pubsub.PubsubMessage) error {
+		_, err := topics.Publish(topic, &pubsub.PublishRequest{Messages: messages}).Context(ctx).Do()
+		return err
+	}
+}
+
+// pubsubMessage returns the message of an entry
+func pubsubMessage(entry *loggingpb.LogEntry, orderingKey string) (*pubsub.PubsubMessage, error) {
+	data, err := protojson.Marshal(entry)
+	if err != nil {
+		return nil, err
+	}
+	message := &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString(data)}
+	if entry.Timestamp != nil {
+		message.Attributes = map[string]string{pubsubTimestampAttribute: entry.Timestamp.AsTime().UTC().Format("2006-01-02T15:04:05.999999999Z")}
+	}
+	switch orderingKey {
+	case "logName":
+		message.OrderingKey = entry.LogName
+	case "trace":
+		message.OrderingKey = entry.Trace
+	}
+	return message, nil
+}
+
+func (p *pubsubPublisher) process(entry *loggingpb.LogEntry) error {
+	if p.err != nil {
+		return grapple.ErrStop
+	}
+	message, err := pubsubMessage(entry, p.orderingKey)
+	if err != nil {
+		return err
+	}
+	size := len(message.Data) + len(message.OrderingKey) + 100
+	if len(p.batch) == pubsubMaxMessages || len(p.batch) > 0 && p.size+size > pubsubMaxBytes {
+		if p.err = p.send(); p.err != nil {
+			return grapple.ErrStop
+		}
+	}
+	p.batch = append(p.batch, message)
+	p.size += size
+	return nil
+}
+
+// send publishes the batch
+func (p *pubsubPublisher) send() error {
+	if len(p.batch) == 0 {
+		return nil
+	}
+	if err := p.publish(p.batch); err != nil {
+		return fmt.Errorf("publishing to %s: %w", p.topic, err)
+	}
+	p.published += len(p.batch)
+	p.batch = nil
+	p.size = 0
+	return nil
+}
+
+// flush publishes the last batch
+func (p *pubsubPublisher) flush() error {
+	if p.err == nil {
+		p.err = p.send()
+	}
+	log.Printf("Published %d entries to %s", p.published, p.topic)
+	return p.err
+}
+
+func init() {
+	addFetchFlags(exportPubSubCmd)
+	exportPubSubCmd.Flags().String("topic", "", "topic to publish the entries to, as projects/PROJECT/topics/TOPIC or TOPIC in the current project")
+	exportPubSubCmd.Flags().String("ordering-key", "logName", "ordering key of the messages, valid values: logName, trace, none")
+	exportPubSubCmd.MarkFlagRequired("topic")
+
+	exportCmd.AddCommand(exportPubSubCmd)
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	pubsub "google.golang.org/api/pubsub/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPubSubMessage(t *testing.T) {
+	entry := &loggingpb.LogEntry{
+		LogName:   "projects/p/logs/app",
+		InsertId:  "a",
+		Trace:     "projects/p/traces/t1",
+		Timestamp: timestamppb.New(time.Date(2025, 1, 2, 15, 4, 5, 120000000, time.UTC)),
+	}
+	message, err := pubsubMessage(entry, "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.OrderingKey != "projects/p/traces/t1" {
+		t.Errorf("ordering key %q, want the trace", message.OrderingKey)
+	}
+	if ts := message.Attributes[pubsubTimestampAttribute]; ts != "2025-01-02T15:04:05.12Z" {
+		t.Errorf("timestamp attribute %q", ts)
+	}
+	data, err := base64.StdEncoding.DecodeString(message.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// protojson randomizes its spacing.
+	if !strings.Contains(strings.ReplaceAll(string(data), " ", ""), `"insertId":"a"`) {
+		t.Errorf("message data %s", data)
+	}
+
+	if message, _ := pubsubMessage(entry, "none"); message.OrderingKey != "" {
+		t.Errorf("ordering key %q, want none", message.OrderingKey)
+	}
+}
+
+func TestPubSubPublisher(t *testing.T) {
+	var requests []int
+	p := &pubsubPublisher{topic: "projects/p/topics/t", orderingKey: "logName", publish: func(messages []*pubsub.PubsubMessage) error {
+		requests = append(requests, len(messages))
+		return nil
+	}}
+	for i := 0; i < pubsubMaxMessages+1; i++ {
+		if err := p.process(&loggingpb.LogEntry{LogName: "projects/p/logs/app"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := p.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 2 || requests[0] != pubsubMaxMessages || requests[1] != 1 || p.published != pubsubMaxMessages+1 {
+		t.Errorf("published %d entries in requests of %v", p.published, requests)
+	}
+
+	// A failed request stops the publisher.
+	p = &pubsubPublisher{topic: "projects/p/topics/t", publish: func([]*pubsub.PubsubMessage) error {
+		return errors.New("permission denied")
+	}}
+	p.process(&loggingpb.LogEntry{})
+	if err := p.flush(); err == nil || err.Error() != "publishing to projects/p/topics/t: permission denied" {
+		t.Errorf("flush() = %v", err)
+	}
+	if err := p.process(&loggingpb.LogEntry{}); err != grapple.ErrStop {
+		t.Errorf("process() after a failure = %v, want grapple.ErrStop", err)
+	}
+}
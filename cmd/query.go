@@ -0,0 +1,698 @@
+package cmd
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// addQueryFlags registers the flags of the commands that fetch and print log entries
+func addQueryFlags(c *cobra.Command) {
+	addFetchFlags(c)
+	addFormatFlags(c)
+	addOutputFlags(c)
+	c.Flags().Int("tail", 0, "print only the last this many entries of the result set, not counting the ones dropped by --grep and the processors (0 for all)")
+	c.Flags().StringArray("route", nil, "write the entries matching a severity condition to a destination, like 'severity>=ERROR => file:errors.ndjson' or 'default => stdout' for the entries no other route matched (repeatable)")
+}
+
+// addFetchFlags registers the flags selecting, fetching and processing the entries, shared by the
+// commands that fetch log entries whatever they do with them, see runQueryInto
+func addFetchFlags(c *cobra.Command) {
+	addFilterFlags(c)
+	c.Flags().StringSlice("preset", nil, "apply the filter and flags of these comma-separated presets, which the flags given override, see grapple presets")
+	c.Flags().String("order", "desc", "ordering based on timestamp, valid values: asc, desc")
+	c.Flags().String("window-field", fieldTimestamp, "field that --from, --to, --freshness, --watch, --manifest, --limit and --gap-report apply to, valid values: timestamp, receiveTimestamp (immune to producers with skewed clocks)")
+
+	c.Flags().BoolVar(&exitStatus, "exit-status", false, "exit with 0 when at least one entry matched, 1 when none did and 2 on errors, like grep")
+	c.Flags().Int("limit", 0, "stop after this many entries were printed (0 for no limit)")
+	c.Flags().String("watch", "", fmt.Sprintf("keep polling for new entries at this interval (default %s when given without a value)", defaultWatchInterval))
+	c.Flags().Lookup("watch").NoOptDefVal = defaultWatchInterval
+	c.Flags().String("notify", "", "with --watch, POST a JSON notification (Slack compatible) of new entries to this webhook URL (default the notify setting of the config file)")
+	c.Flags().String("watch-window", windowWatermark, "where each poll of --watch starts: watermark (the newest entry printed), fixed (the previous poll) or sliding (the previous poll, minus --watch-overlap)")
+	c.Flags().Duration("watch-overlap", 0, "how far back before the start of --watch-window each poll reaches again, to catch entries ingested late")
+	c.Flags().String("checkpoint", "", "with --watch, keep the progress in this named checkpoint and resume from it after a restart, see grapple state")
+	c.Flags().Duration("notify-cooldown", 5*time.Minute, "after notifying an entry, count the ones with the same fingerprint (log, severity and message pattern) and notify their number at most this often")
+	c.Flags().String("manifest", "", "write the number of entries fetched per time window to this file, for grapple verify")
+	c.Flags().String("manifest-window", defaultManifestWindow, "length of the time windows counted in --manifest")
+	c.MarkFlagFilename("manifest")
+	c.Flags().String("summary-file", "", "at the end of the run, write its statistics (entries per severity, log and resource type, window covered, retries, skipped entries) to this JSON file")
+	c.MarkFlagFilename("summary-file", "json")
+	c.Flags().Bool("gap-report", false, "after fetching, report the time ranges where entries are suspiciously missing")
+	c.Flags().Bool("refetch-gaps", false, "fetch again the time ranges reported by --gap-report")
+	c.Flags().String("wasm-processor", "", "run each entry through this WebAssembly module, which reads the entries as JSON lines and answers each with the entry to print or an empty line to drop it")
+	c.Flags().Duration("wasm-timeout", 5*time.Second, "how long the --wasm-processor module has to answer each entry before it is stopped")
+	c.Flags().Bool("dedupe", false, "drop the entries whose insertId and timestamp were already printed in the run")
+	c.Flags().Bool("strict-order", false, "print the entries in strict (timestamp, insertId) order, reordering them within --reorder-window and failing when that is not enough")
+	c.Flags().Duration("reorder-window", time.Second, "with --strict-order, how far past the timestamp of an entry the fetch goes before printing it")
+	c.Flags().Bool("integrity-report", false, "after fetching, report duplicate insertIds and, in ascending scans, timestamp regressions")
+	c.Flags().Bool("dry-run", false, "print the final filter, with the parts it is made of, the resources, page size and order of the request instead of fetching, without API calls (the request as JSON with --format json or when not on a terminal)")
+	c.Flags().Int("confirm-over", 0, "count the matching entries first, listing them without payloads up to this many or 10 pages, extrapolated to the time window beyond them, and ask before fetching more (fails when not interactive)")
+	addDLPFlags(c)
+	c.Flags().StringArray("geoip-db", nil, "annotate httpRequest.remoteIp with the country, city and ASN found in this MaxMind database, as the @geo field (repeatable)")
+	c.MarkFlagFilename("geoip-db")
+	c.MarkFlagFilename("wasm-processor", "wasm")
+	c.Flags().Bool("parse-user-agent", false, "annotate the entries with the browser, version, os, device and bot parsed from httpRequest.userAgent, as the @ua field")
+	c.Flags().StringSlice("hash-fields", nil, "replace these comma-separated fields with salted hashes (e.g. jsonPayload.user_id,labels.email)")
+	c.Flags().String("hash-salt-env", "", "environment variable holding the salt of --hash-fields")
+	c.Flags().Int("page-size", maxPageSize, "number of entries requested per page")
+	c.Flags().Duration("rpc-timeout", 0, "timeout of each page request, retries included (0 for the client default of 60s)")
+	c.Flags().Int("max-retries", -1, "retries of each failed page request (-1 for the client default of retrying until the timeout)")
+	c.Flags().Duration("backoff-initial", rateLimitBackoff.Initial, "pause after the first rate limit error, doubling at each following one")
+	c.Flags().Duration("backoff-max", rateLimitBackoff.Max, "longest pause after rate limit errors, unless the API asks for more")
+	c.Flags().String("health-addr", "", "serve /healthz and /readyz on this address (e.g. :8080) while running")
+}
+
+// addFilterFlags registers the flags selecting the entries to fetch, see composeFilter and determineTimeWindow
+func addFilterFlags(c *cobra.Command) {
+	addEntryFilterFlags(c)
+	c.Flags().String("bucket", "", "read entries from this log bucket instead of the whole project")
+	c.Flags().String("view", "", "read entries through this log view of --bucket (default _AllLogs)")
+	c.Flags().String("location", "global", "location of --bucket")
+	c.Flags().StringSlice("include-buckets", nil, "read entries from the _AllLogs view of these comma-separated buckets, in any location (e.g. _Default,my-analytics-bucket)")
+}
+
+// addEntryFilterFlags registers the flags of addFilterFlags restricting the entries themselves,
+// rather than where they are read from
+func addEntryFilterFlags(c *cobra.Command) {
+	c.Flags().String("from", "", "start of time range (e.g. 2024-05-01T14:00:00Z, 2024-05-01, -2h, yesterday 14:00)")
+	c.Flags().String("to", "", "end of time range, like --from (default now)")
+	c.Flags().String("freshness", "", "maximum age of log entries (e.g. 2h, 3d4h)")
+	c.Flags().StringArray("label", nil, "only fetch entries with this label, as key=value (repeatable)")
+	c.Flags().StringArray("resource-label", nil, "only fetch entries whose resource has this label, as key=value (repeatable)")
+	addShorthandFlags(c)
+	c.Flags().String("audit", "", fmt.Sprintf("only fetch Cloud Audit Logs, all of them or of one kind: %s", strings.Join(auditKinds(), ", ")))
+	c.Flags().Lookup("audit").NoOptDefVal = auditAll
+	c.Flags().Bool("no-default-filter", false, "do not apply the alwaysFilter from the config")
+	c.Flags().StringSlice("exclude-preset", nil, fmt.Sprintf("drop common noise with built-in negative filters, valid values: %s", strings.Join(excludePresetNames(), ", ")))
+}
+
+// flagOrConfig returns the value of a flag when explicitly set, falling back to the config
+// (viper keys are bound to the root command flags only)
+func flagOrConfig(cmd *cobra.Command, name string) string {
+	if cmd.Flags().Changed(name) {
+		return cmd.Flag(name).Value.String()
+	}
+	return viper.GetString(name)
+}
+
+// oldestFirst lists entries oldest first unless an order was explicitly requested, so that
+// a trace reads in the order it happened and --watch appends new entries at the bottom like tail -f
+func oldestFirst(cmd *cobra.Command) {
+	if !cmd.Flags().Changed("order") {
+		cmd.Flags().Set("order", "asc")
+	}
+}
+
+// composeFilter combines the user filter with the filters coming from the config and the query flags,
+// everything but the time window
+func composeFilter(cmd *cobra.Command, userFilter string) (string, error) {
+	filter := expandProjectReferences(userFilter, projectAliases())
+	presets, err := cmd.Flags().GetStringSlice("exclude-preset")
+	if err != nil {
+		return "", err
+	}
+	excludeFilter, err := buildExcludeFilter(presets)
+	if err != nil {
+		return "", err
+	}
+	filter = andFilters(filter, defaultFilter(cmd), excludeFilter)
+
+	labels, err := cmd.Flags().GetStringArray("label")
+	if err != nil {
+		return "", err
+	}
+	entryLabels, err := labelClauses("labels", labels, true)
+	if err != nil {
+		return "", err
+	}
+	resourceLabels, err := cmd.Flags().GetStringArray("resource-label")
+	if err != nil {
+		return "", err
+	}
+	resourceLabelClauses, err := labelClauses("resource.labels", resourceLabels, false)
+	if err != nil {
+		return "", err
+	}
+
+	clauses, err := shorthandClauses(cmd)
+	if err != nil {
+		return "", err
+	}
+	if kind := cmd.Flag("audit").Value.String(); kind != "" {
+		auditClause, err := auditFilter(kind)
+		if err != nil {
+			return "", err
+		}
+		clauses = append(clauses, auditClause)
+	}
+
+	clauses = append(append(clauses, entryLabels...), resourceLabelClauses...)
+	return andFilters(append([]string{filter}, clauses...)...), nil
+}
+
+// runQuery fetches the entries matching the user filter and the query flags of cmd and prints them
+func runQuery(cmd *cobra.Command, userFilter string) {
+	runQuerySink(cmd, userFilter, newEntrySink, reloadableSink(cmd))
+}
+
+// runQueryInto fetches the entries matching the user filter and the query flags of cmd
+// and hands them to the sink created by newSink
+func runQueryInto(cmd *cobra.Command, userFilter string, newSink func(*cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error)) {
+	runQuerySink(cmd, userFilter, newSink, false)
+}
+
+// runQuerySink is runQueryInto, where with reloadSink the sink is created again when the output
+// settings of the config file change while watching
+func runQuerySink(cmd *cobra.Command, userFilter string, newSink func(*cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error), reloadSink bool) {
+	defer recoverCrash()
+
+	presetNames, err := cmd.Flags().GetStringSlice("preset")
+	checkErr(err)
+	if len(presetNames) > 0 {
+		presets, err := flagPresets(viper.GetViper())
+		checkErr(err)
+		presetFilter, err := applyFlagPresets(cmd, presets, presetNames)
+		checkErr(err)
+		userFilter = andFilters(userFilter, presetFilter)
+	}
+
+	projectId := requireProject()
+
+	from, to, err := determineTimeWindow(cmd)
+	checkErr(err)
+
+	watchInterval, err := watchIntervalFlag(cmd)
+	checkErr(err)
+	if watchInterval > 0 {
+		if cmd.Flag("to").Value.String() != "" {
+			checkErr(errors.New("--watch cannot be used together with --to"))
+		}
+		oldestFirst(cmd)
+	}
+
+	manifestPath := cmd.Flag("manifest").Value.String()
+	if manifestPath != "" {
+		if watchInterval > 0 {
+			checkErr(errors.New("--manifest cannot be used together with --watch"))
+		}
+		// The manifest needs explicit bounds, same as the default window of logadmin.
+		if from.IsZero() {
+			to = time.Now().Truncate(time.Second)
+			from = to.Add(-24 * time.Hour)
+		}
+	}
+
+	limit, err := cmd.Flags().GetInt("limit")
+	checkErr(err)
+	if limit < 0 {
+		checkErr(fmt.Errorf("invalid --limit %d", limit))
+	}
+	if limit > 0 && (watchInterval > 0 || manifestPath != "") {
+		checkErr(errors.New("--limit cannot be used together with --watch or --manifest"))
+	}
+
+	strategy, err := windowStrategyFlag(cmd)
+	checkErr(err)
+	windowField, err = windowFieldFlag(cmd)
+	checkErr(err)
+
+	checkpointName := cmd.Flag("checkpoint").Value.String()
+	if checkpointName != "" {
+		if watchInterval == 0 {
+			checkErr(errors.New("--checkpoint requires --watch"))
+		}
+		if !savedQueryNamePattern.MatchString(checkpointName) {
+			checkErr(fmt.Errorf("invalid checkpoint name %q, use letters, digits, '.', '_' and '-'", checkpointName))
+		}
+	}
+
+	notifyURL := cmd.Flag("notify").Value.String()
+	if notifyURL != "" && watchInterval == 0 {
+		checkErr(errors.New("--notify requires --watch"))
+	}
+	if watchInterval > 0 {
+		notifyURL = flagOrConfig(cmd, notifyKey)
+	}
+	notifyCooldown, err := cmd.Flags().GetDuration("notify-cooldown")
+	checkErr(err)
+	if notifyCooldown <= 0 {
+		checkErr(errors.New("--notify-cooldown must be positive"))
+	}
+
+	if cmd.Flag("dlp").Value.String() != "" && watchInterval > 0 {
+		checkErr(errors.New("--dlp cannot be used together with --watch"))
+	}
+
+	order := flagOrConfig(cmd, "order")
+	newestFirst := order == "desc"
+
+	// Only the commands printing the entries have --tail, the reports and exports take them all.
+	var tail int
+	if cmd.Flag("tail") != nil {
+		tail, err = cmd.Flags().GetInt("tail")
+		checkErr(err)
+	}
+	if tail < 0 {
+		checkErr(fmt.Errorf("invalid --tail %d", tail))
+	}
+	if tail > 0 {
+		if limit > 0 || manifestPath != "" {
+			checkErr(errors.New("--tail cannot be used together with --limit or --manifest"))
+		}
+		// The last entries are the first ones in the opposite order, printed back reversed.
+		limit = tail
+		newestFirst = !newestFirst
+	}
+
+	narrowing := limit > 0 && newestFirst && (from.IsZero() || to.Sub(from) > narrowingThreshold)
+	if narrowing && from.IsZero() {
+		to = time.Now()
+		from = to.Add(-24 * time.Hour)
+	}
+
+	filter, err := composeFilter(cmd, userFilter)
+	checkErr(err)
+	allFilters := buildFilter(from, to, filter)
+
+	lastRequest = requestSummary{Filter: allFilters, OrderBy: order}
+
+	// Interrupting stops the fetch gracefully, so that the output is flushed.
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client, err := newLogadminClient(ctx, projectId)
+	checkErr(err)
+	defer client.Close()
+
+	callOpts, err := entriesCallOptions(cmd)
+	checkErr(err)
+	client.SetEntriesCallOptions(callOpts...)
+
+	pageSize, err = pageSizeFlag(cmd, limit)
+	checkErr(err)
+	rateLimitBackoff, err = backoffFlags(cmd)
+	checkErr(err)
+	baseOpts := []logadmin.EntriesOption{logadmin.PageSize(int32(pageSize))}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	checkErr(err)
+	included, err := cmd.Flags().GetStringSlice("include-buckets")
+	checkErr(err)
+	// Resolving --include-buckets lists the buckets, a dry run makes no API calls.
+	var views []string
+	if !dryRun || len(included) == 0 {
+		views, err = determineViews(ctx, cmd, client)
+		checkErr(err)
+	}
+	if len(views) > 0 {
+		baseOpts = append(baseOpts, logadmin.ResourceNames(views))
+	}
+
+	opts := append(slices.Clone(baseOpts), logadmin.Filter(allFilters))
+	if newestFirst {
+		opts = append(opts, logadmin.NewestFirst())
+	}
+
+	if dryRun {
+		run := &dryRunPlan{
+			req:          client.EntriesRequest(opts...),
+			filter:       allFilters,
+			from:         from,
+			to:           to,
+			query:        userFilter,
+			alwaysFilter: defaultFilter(cmd),
+		}
+		if run.alwaysFilter != "" {
+			run.profile = viper.GetString(profileKey)
+		}
+		if len(views) == 0 {
+			run.includeBuckets = included
+		}
+		format := flagOrConfig(cmd, "format")
+		if format == "" {
+			format = defaultFormat(cmd, false)
+		}
+		if format == formatJSON {
+			checkErr(run.writeJSON(stdout, time.Now()))
+		} else {
+			checkErr(run.writeText(stdout, time.Now()))
+		}
+		return
+	}
+
+	threshold, err := cmd.Flags().GetInt("confirm-over")
+	checkErr(err)
+	checkErr(confirmEstimate(ctx, client, opts, from, to, newestFirst, threshold))
+
+	process, flush, err := newSink(cmd)
+	checkErr(err)
+
+	// emitted counts the entries reaching the output, processed without errors and not
+	// rejected by --grep, for --limit and --exit-status. --notify observes the same entries, after
+	// --hash-fields and the other processors, once watching.
+	var emitted int
+	var notify *notifier
+	sink := process
+	process = func(entry *loggingpb.LogEntry) error {
+		grepped := counters.grepped
+		err := sink(entry)
+		if err == nil && counters.grepped == grepped {
+			emitted++
+			if notify != nil {
+				notify.observe(entry, time.Now())
+			}
+		}
+		return err
+	}
+
+	// --tail buffers the entries that went through the processors and --grep, to emit them after the fetch.
+	var tailed *tailBuffer
+	if tail > 0 {
+		tailed, err = newTailBuffer(cmd, tail, process)
+		checkErr(err)
+		process = tailed.process
+	}
+
+	hasher, err := newFieldHasher(cmd)
+	checkErr(err)
+	if hasher != nil {
+		process = hasher.process(process)
+	}
+
+	dlpProcessor, err := newDLPProcessor(ctx, cmd, projectId, process)
+	checkErr(err)
+	if dlpProcessor != nil {
+		process = dlpProcessor.process
+	}
+
+	wasm, err := newWASMProcessor(cmd, process)
+	checkErr(err)
+	if wasm != nil {
+		process = wasm.process
+	}
+
+	geo, err := newGeoEnricher(cmd)
+	checkErr(err)
+	if geo != nil {
+		process = geo.process(process)
+	}
+
+	parseUserAgent, err := cmd.Flags().GetBool("parse-user-agent")
+	checkErr(err)
+	if parseUserAgent {
+		printEntry := process
+		process = func(entry *loggingpb.LogEntry) error {
+			annotateUserAgent(entry)
+			return printEntry(entry)
+		}
+	}
+
+	out, err := openOutput(cmd)
+	checkErr(err)
+
+	if addr := cmd.Flag("health-addr").Value.String(); addr != "" {
+		checkErr(serveHealth(addr, watchInterval))
+	}
+
+	sdNotify("READY=1")
+
+	gapReport, err := cmd.Flags().GetBool("gap-report")
+	checkErr(err)
+	refetchGaps, err := cmd.Flags().GetBool("refetch-gaps")
+	checkErr(err)
+	detector := &gapDetector{}
+	if gapReport || refetchGaps {
+		printEntry := process
+		process = func(entry *loggingpb.LogEntry) error {
+			detector.observe(entry)
+			return printEntry(entry)
+		}
+	}
+
+	integrityReport, err := cmd.Flags().GetBool("integrity-report")
+	checkErr(err)
+	var checker *integrityChecker
+	if integrityReport {
+		checker = newIntegrityChecker(order != "desc")
+		printEntry := process
+		process = func(entry *loggingpb.LogEntry) error {
+			checker.observe(entry)
+			return printEntry(entry)
+		}
+	}
+
+	var manifest *exportManifest
+	if manifestPath != "" {
+		window, err := parseFreshness(cmd.Flag("manifest-window").Value.String())
+		checkErr(err)
+		if window <= 0 {
+			checkErr(errors.New("--manifest-window must be positive"))
+		}
+		manifest = newExportManifest(projectId, filter, views, from, to, window)
+		printEntry := process
+		process = func(entry *loggingpb.LogEntry) error {
+			manifest.observe(entry)
+			return printEntry(entry)
+		}
+	}
+
+	mark := newWatermark(time.Now(), strategy.Overlap())
+	var resumed *checkpoint
+	saveState := func() {}
+	if checkpointName != "" {
+		dir, err := checkpointsDir()
+		checkErr(err)
+		resumed, err = loadCheckpoint(dir, checkpointName)
+		checkErr(err)
+		if resumed != nil {
+			if resumed.Filter != filter {
+				log.Printf("Warning: checkpoint %s was written with another filter: %s", checkpointName, resumed.Filter)
+			}
+			if resumedField := cmp.Or(resumed.WindowField, fieldTimestamp); resumedField != windowField {
+				log.Printf("Warning: checkpoint %s was written with --window-field %s", checkpointName, resumedField)
+			}
+			mark = newWatermark(resumed.Watermark, strategy.Overlap())
+			for _, key := range resumed.Seen {
+				mark.seen[key] = resumed.Watermark
+			}
+		}
+		saveState = func() {
+			state := &checkpoint{Filter: filter, WindowField: windowField, Watermark: mark.timestamp, Seen: mark.keys(), LastRun: time.Now().UTC()}
+			if notify != nil {
+				state.Notifications = notify.snapshot()
+			}
+			if err := saveCheckpoint(dir, checkpointName, state); err != nil {
+				log.Printf("Error saving checkpoint %s: %v", checkpointName, err)
+			}
+		}
+	}
+	if watchInterval > 0 {
+		printEntry := process
+		process = func(entry *loggingpb.LogEntry) error {
+			mark.record(entry)
+			return printEntry(entry)
+		}
+	}
+
+	summaryPath := cmd.Flag("summary-file").Value.String()
+	var collector *summaryCollector
+	if summaryPath != "" {
+		collector = newSummaryCollector()
+		printEntry := process
+		process = func(entry *loggingpb.LogEntry) error {
+			collector.observe(entry)
+			return printEntry(entry)
+		}
+	}
+
+	strictOrder, err := cmd.Flags().GetBool("strict-order")
+	checkErr(err)
+	var orderer *strictOrderer
+	if strictOrder {
+		if refetchGaps {
+			checkErr(errors.New("--strict-order cannot be used together with --refetch-gaps"))
+		}
+		reorderWindow, err := cmd.Flags().GetDuration("reorder-window")
+		checkErr(err)
+		if reorderWindow < 0 {
+			checkErr(fmt.Errorf("invalid --reorder-window %s", reorderWindow))
+		}
+		orderer = newStrictOrderer(reorderWindow, order == "desc", process)
+		process = orderer.process
+	}
+
+	dedupe, err := cmd.Flags().GetBool("dedupe")
+	checkErr(err)
+	var dedup *deduper
+	if dedupe {
+		dedup = newDeduper(process)
+		process = dedup.process
+	}
+
+	// --limit stops once enough entries were emitted, the ones dropped by --grep, --dedupe or
+	// the processors not counting. --tail counts the entries it buffers the same way.
+	limitReached := func() bool { return limit > 0 && emitted >= limit }
+	if tailed != nil {
+		limitReached = tailed.full
+	}
+	unlimited := process
+	if limit > 0 {
+		printEntry := process
+		process = func(entry *loggingpb.LogEntry) error {
+			if err := printEntry(entry); err != nil {
+				counters.skipped++
+				log.Printf("Error processing log entry (%s): %v", entry.InsertId, err)
+			}
+			if limitReached() {
+				return grapple.ErrStop
+			}
+			return nil
+		}
+	}
+
+	started := time.Now()
+	var count int
+	if resumed != nil {
+		log.Printf("Resuming checkpoint %s from %s", checkpointName, resumed.Watermark.Format(time.RFC3339Nano))
+	} else if narrowing {
+		count, err = fetchNarrowing(ctx, client, baseOpts, filter, from, to, limitReached, process)
+	} else {
+		count, err = fetchAndProcessLogs(ctx, client, opts, process)
+	}
+	if tailed != nil {
+		// The entries held back by --strict-order are among the last ones.
+		if err == nil && orderer != nil {
+			err = orderer.flush()
+		}
+		tailed.flush()
+		process = unlimited
+	}
+	if checker != nil {
+		// Refetched gaps and watched entries are out of order with the initial scan.
+		checker.ascending = false
+	}
+	if err == nil && ctx.Err() == nil {
+		var refetched int
+		refetched, err = reportGaps(ctx, client, opts, allFilters, detector.gaps(), refetchGaps, process)
+		count += refetched
+	}
+	if err == nil && ctx.Err() == nil && watchInterval > 0 {
+		saveState()
+		stopNotify := func() {}
+		startNotify := func(url string) {
+			notify = newNotifier(notifyCooldown, postNotification(url))
+			notifyCtx, cancel := context.WithCancel(ctx)
+			done := make(chan struct{})
+			go func() {
+				notify.run(notifyCtx, watchInterval)
+				close(done)
+			}()
+			stopNotify = func() {
+				cancel()
+				<-done
+				notify = nil
+				stopNotify = func() {}
+			}
+		}
+		if notifyURL != "" {
+			startNotify(notifyURL)
+			if resumed != nil {
+				notify.restore(resumed.Notifications)
+			}
+		}
+		// The output and the --notify target follow the config file, unless given with flags.
+		reloaded := func(changed []string) {
+			if reloadSink && slices.ContainsFunc(changed, outputSetting) {
+				if !reloadableSink(cmd) {
+					log.Printf("Error: keeping the previous output, --stats, --route, --format parquet and syslog outputs are not reloaded")
+				} else if reloadedProcess, reloadedFlush, err := newSink(cmd); err != nil {
+					log.Printf("Error: keeping the previous output: %v", err)
+				} else {
+					if err := flush(); err != nil {
+						log.Printf("Error flushing the previous output: %v", err)
+					}
+					sink, flush = reloadedProcess, reloadedFlush
+				}
+			}
+			if slices.Contains(changed, notifyKey) && !cmd.Flags().Changed("notify") {
+				url := viper.GetString(notifyKey)
+				switch {
+				case url == "":
+					stopNotify()
+				case notify == nil:
+					startNotify(url)
+				default:
+					notify.retarget(postNotification(url))
+				}
+			}
+		}
+		var config *configFile
+		config, err = watchConfigFile(ctx, cmd.Flag(profileKey))
+		checkErr(err)
+		var watched int
+		watched, err = watchEntries(ctx, client, baseOpts, watchInterval, mark, strategy, config, func() (string, error) {
+			return composeFilter(cmd, userFilter)
+		}, reloaded, process, saveState)
+		stopNotify()
+		saveState()
+		count += watched
+	}
+	if err == nil && orderer != nil {
+		err = orderer.flush()
+	}
+	if dedup != nil && dedup.duplicates > 0 {
+		log.Printf("Dropped %d duplicate entries", dedup.duplicates)
+	}
+	if wasm != nil {
+		err = errors.Join(err, wasm.close())
+		if wasm.dropped > 0 {
+			log.Printf("The WASM processor dropped %d entries", wasm.dropped)
+		}
+	}
+	if err == nil && dlpProcessor != nil {
+		err = dlpProcessor.flush()
+	}
+	if err == nil {
+		err = flush()
+	}
+	if checker != nil {
+		checker.report()
+	}
+	if manifest != nil && err == nil {
+		if ctx.Err() != nil {
+			log.Printf("Not writing the manifest of an interrupted export")
+		} else if err = manifest.write(manifestPath); err == nil {
+			log.Printf("Wrote the manifest to %s", manifestPath)
+		}
+	}
+	if ctx.Err() != nil {
+		health.setDraining()
+		sdNotify("STOPPING=1")
+		log.Printf("Interrupted after %d entries, draining the output", count)
+	}
+	recordUsage(count, time.Since(started))
+	err = errors.Join(err, out.Close())
+	if collector != nil {
+		summary := collector.summary(projectId, allFilters, from, to, started, time.Now(), count, ctx.Err() != nil, err)
+		if err := summary.write(summaryPath); err != nil {
+			log.Printf("Error writing the summary to %s: %v", summaryPath, err)
+		}
+	}
+	checkErr(err)
+	if exitStatus && emitted == 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/input"
+	"github.com/dippi/grapple/internal/lql"
+	"github.com/spf13/cobra"
+	storage "google.golang.org/api/storage/v1"
+)
+
+// globChars are the characters making a path a glob pattern
+const globChars = "*?["
+
+var readCmd = &cobra.Command{
+	Use:   "read PATH... [--filter FILTER]",
+	Short: "Filter and print entries of exported files, local or in Cloud Storage",
+	Long: `Read the entries of exported files and print the ones matching --filter and
+the filter flags, with the same formats and --stats as the queries, so that
+archived logs can be analyzed without ingesting them again.
+
+The paths are local files, "-" for stdin, or Cloud Storage objects:
+gs://BUCKET/OBJECT, gs://BUCKET/PREFIX/ for all the objects under a prefix,
+or glob patterns like gs://BUCKET/stdout/2025/01/*/*.json, where * does not
+match /. The objects are read in the order of their names, which is
+chronological for the hourly files of the Cloud Storage sinks.
+
+The files are JSON lines, as written by grapple and by the Cloud Storage
+sinks of Cloud Logging, or a JSON array, as printed by gcloud logging read
+--format json. Files ending in .gz and .zst are decompressed.
+
+The filter is evaluated locally, approximating the API: the : operator and
+the global restrictions match substrings ignoring case where the API matches
+tokens, and only the log_id, sample and ip_in_net functions are supported.`,
+	Example: `  grapple read 'gs://my-archive/stdout/2025/01/02/*.json' --filter 'severity>=ERROR'
+  grapple read gs://my-archive/cloudaudit.googleapis.com/activity/2025/01/ --stats
+  gcloud logging read 'resource.type="k8s_container"' --format json | grapple read - --filter 'jsonPayload.message:timeout'`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		matcher, err := readMatcher(cmd)
+		cobra.CheckErr(err)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		r := &archiveReader{ctx: ctx}
+		paths, err := r.expand(args)
+		cobra.CheckErr(err)
+
+		process, flush, err := newEntrySink(cmd)
+		cobra.CheckErr(err)
+
+		out, err := openOutput(cmd)
+		cobra.CheckErr(err)
+
+		read, matched := 0, 0
+		for _, p := range paths {
+			var n, m int
+			n, m, err = r.readFile(p, matcher, process)
+			read += n
+			matched += m
+			if err != nil || ctx.Err() != nil {
+				break
+			}
+		}
+		log.Printf("Read %d entries from %d files, %d matched", read, len(paths), matched)
+		if err == nil {
+			err = flush()
+		}
+		cobra.CheckErr(errors.Join(err, out.Close()))
+	},
+}
+
+// readMatcher returns the matcher of --filter combined with the filter flags
+func readMatcher(cmd *cobra.Command) (*lql.Matcher, error) {
+	filter, err := composeFilter(cmd, cmd.Flag("filter").Value.String())
+	if err != nil {
+		return nil, err
+	}
+	from, to, err := determineTimeWindow(cmd)
+	if err != nil {
+		return nil, err
+	}
+	node, err := lql.Parse(buildFilter(from, to, filter))
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+	return lql.NewMatcher(node)
+}
+
+// archiveReader reads entries from local files and Cloud Storage objects
+type archiveReader struct {
+	ctx context.Context
+	// objects is created for the first gs:// path.
+	objects *storage.ObjectsService
+}
+
+func (r *archiveReader) storageObjects() (*storage.ObjectsService, error) {
+	if r.objects == nil {
+		opts, err := clientOptions()
+		if err != nil {
+			return nil, err
+		}
+		service, err := storage.NewService(r.ctx, opts...)
+		if err != nil {
+			return nil, err
+		}
+		r.objects = service.Objects
+	}
+	return r.objects, nil
+}
+
+// expand replaces the glob patterns and the Cloud Storage prefixes of paths with the files and
+// objects they match
+func (r *archiveReader) expand(paths []string) ([]string, error) {
+	var expanded []string
+	for _, p := range paths {
+		switch {
+		case strings.HasPrefix(p, gcsScheme):
+			objects, err := r.storageObjects()
+			if err != nil {
+				return nil, err
+			}
+			matches, err := listGCSObjects(r.ctx, objects, p)
+			if err != nil {
+				return nil, err
+			}
+			expanded = append(expanded, matches...)
+		case strings.ContainsAny(p, globChars):
+			matches, err := filepath.Glob(p)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %s", p)
+			}
+			expanded = append(expanded, matches...)
+		default:
+			expanded = append(expanded, p)
+		}
+	}
+	return expanded, nil
+}
+
+// listGCSObjects returns the paths of the objects of a gs:// path: the object itself, the objects
+// under a prefix ending in / or the objects matching a glob pattern, in the order of their names
+func listGCSObjects(ctx context.Context, objects *storage.ObjectsService, pattern string) ([]string, error) {
+	bucket, name, err := parseGCSPath(pattern)
+	if err != nil {
+		return nil, err
+	}
+	glob := strings.ContainsAny(name, globChars)
+	if !glob && !strings.HasSuffix(name, "/") {
+		return []string{pattern}, nil
+	}
+	prefix := name
+	if glob {
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		prefix = name[:strings.IndexAny(name, globChars)]
+	}
+
+	var paths []string
+	err = objects.List(bucket).Prefix(prefix).Pages(ctx, func(page *storage.Objects) error {
+		for _, object := range page.Items {
+			if matchesGCSPattern(name, object.Name, glob) {
+				paths = append(paths, gcsScheme+bucket+"/"+object.Name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no objects match %s", pattern)
+	}
+	return paths, nil
+}
+
+// matchesGCSPattern reports whether an object listed under the prefix of pattern is one of its
+// files, skipping the placeholders of folders
+func matchesGCSPattern(pattern, name string, glob bool) bool {
+	if strings.HasSuffix(name, "/") {
+		return false
+	}
+	if !glob {
+		return true
+	}
+	ok, _ := path.Match(pattern, name)
+	return ok
+}
+
+// open returns a reader of the decompressed content of a file or a Cloud Storage object
+func (r *archiveReader) open(p string) (io.ReadCloser, error) {
+	if !strings.HasPrefix(p, gcsScheme) {
+		return input.Open(p)
+	}
+	bucket, name, err := parseGCSPath(p)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := r.storageObjects()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := objects.Get(bucket, name).Context(r.ctx).Download()
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", p, err)
+	}
+	return input.Decompress(p, resp.Body)
+}
+
+// readFile hands the entries of a file matching the filter to process, until the end of the file or
+// of the context, and returns the numbers of entries read and matched
+func (r *archiveReader) readFile(p string, matcher *lql.Matcher, process func(*loggingpb.LogEntry) error) (read, matched int, err error) {
+	f, err := r.open(p)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	entries := input.NewEntryReader(p, f)
+	for r.ctx.Err() == nil {
+		entry, err := entries.Next()
+		if err == io.EOF {
+			return read, matched, nil
+		}
+		if err != nil {
+			return read, matched, err
+		}
+		read++
+
+		fields, err := entryToMap(entry)
+		if err != nil {
+			return read, matched, err
+		}
+		if !matcher.Match(fields) {
+			continue
+		}
+		matched++
+		if err := process(entry); err != nil {
+			log.Printf("Error processing log entry (%s): %v", entry.InsertId, err)
+		}
+	}
+	return read, matched, nil
+}
+
+func init() {
+	readCmd.Flags().String("filter", "", "only print the entries matching this filter, in the query language of Cloud Logging")
+	addEntryFilterFlags(readCmd)
+	addFormatFlags(readCmd)
+	addOutputFlags(readCmd)
+
+	rootCmd.AddCommand(readCmd)
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+)
+
+func TestMatchesGCSPattern(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		glob          bool
+		expected      bool
+	}{
+		{"stdout/2025/01/", "stdout/2025/01/02/00:00:00_00:59:59_S0.json", false, true},
+		{"stdout/2025/01/", "stdout/2025/01/02/", false, false},
+		{"stdout/2025/01/*/*.json", "stdout/2025/01/02/00:00:00_00:59:59_S0.json", true, true},
+		{"stdout/2025/01/*.json", "stdout/2025/01/02/00:00:00_00:59:59_S0.json", true, false},
+		{"stdout/2025/01/0[1-3]/*", "stdout/2025/01/04/00:00:00_00:59:59_S0.json", true, false},
+	}
+	for _, c := range cases {
+		if got := matchesGCSPattern(c.pattern, c.name, c.glob); got != c.expected {
+			t.Errorf("matchesGCSPattern(%q, %q) = %v, want %v", c.pattern, c.name, got, c.expected)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.json")
+	data := `[
+  {"insertId": "a", "severity": "ERROR", "timestamp": "2025-01-02T15:04:05Z", "jsonPayload": {"message": "Upstream timed out"}},
+  {"insertId": "b", "severity": "INFO", "timestamp": "2025-01-02T15:04:06Z", "jsonPayload": {"message": "ok"}},
+  {"insertId": "c", "severity": "ERROR", "timestamp": "2025-01-03T00:00:00Z", "labels": {"team": "web"}}
+]`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("filter", "", "")
+	addEntryFilterFlags(cmd)
+	cmd.Flags().Set("filter", "severity>=ERROR")
+	cmd.Flags().Set("from", "2025-01-02T00:00:00Z")
+	cmd.Flags().Set("to", "2025-01-02T23:59:59Z")
+	matcher, err := readMatcher(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	r := &archiveReader{ctx: context.Background()}
+	read, matched, err := r.readFile(path, matcher, func(entry *loggingpb.LogEntry) error {
+		ids = append(ids, entry.InsertId)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read != 3 || matched != 1 || len(ids) != 1 || ids[0] != "a" {
+		t.Errorf("readFile() read %d and matched %d entries %v, want 3 and a", read, matched, ids)
+	}
+
+	cmd.Flags().Set("filter", `source("a")`)
+	if _, err := readMatcher(cmd); err == nil {
+		t.Error("readMatcher() succeeded with an unsupported function, want an error")
+	}
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configFile is the config file of a long-running mode, which applies its changes with reload on its
+// own goroutine, so that the settings are never read while they are replaced
+type configFile struct {
+	v    *viper.Viper
+	path string
+	// profile is the --profile flag, which takes precedence over the profile of the file.
+	profile  *pflag.Flag
+	previous map[string]any
+	changes  chan struct{}
+}
+
+func newConfigFile(v *viper.Viper, path string, profile *pflag.Flag) *configFile {
+	return &configFile{v: v, path: filepath.Clean(path), profile: profile, previous: flattenSettings(v.AllSettings()), changes: make(chan struct{}, 1)}
+}
+
+// watchConfigFile watches the config file in use until ctx is done, or returns nil when there is none
+func watchConfigFile(ctx context.Context, profile *pflag.Flag) (*configFile, error) {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		return nil, nil
+	}
+	c := newConfigFile(viper.GetViper(), path, profile)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	// The directory is watched, as editors and Kubernetes replace the file rather than writing it.
+	if err := watcher.Add(filepath.Dir(c.path)); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	go c.watch(ctx, watcher)
+	return c, nil
+}
+
+// watch signals changed when the file is written, or when the target of a symbolic link to it changes
+func (c *configFile) watch(ctx context.Context, watcher *fsnotify.Watcher) {
+	defer watcher.Close()
+	target, _ := filepath.EvalSymlinks(c.path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			current, _ := filepath.EvalSymlinks(c.path)
+			written := filepath.Clean(event.Name) == c.path && event.Has(fsnotify.Write|fsnotify.Create)
+			if !written && (current == "" || current == target) {
+				continue
+			}
+			target = current
+			select {
+			case c.changes <- struct{}{}:
+			default:
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			log.Printf("Error watching the config file: %v", err)
+		}
+	}
+}
+
+// changed receives after the config file changed, never when c is nil
+func (c *configFile) changed() <-chan struct{} {
+	if c == nil {
+		return nil
+	}
+	return c.changes
+}
+
+// reload reads the config file again and replaces the settings, with the ones of the active profile
+// merged over them, unless they are invalid. It logs a diff of the settings, where the keys for which
+// reloaded is false are marked as applying after a restart, and returns the changed keys.
+func (c *configFile) reload(reloaded func(key string) bool) ([]string, error) {
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return nil, err
+	}
+	// The new settings are checked apart, so that the previous ones are kept when they are invalid.
+	check := viper.New()
+	check.SetConfigFile(c.path)
+	check.SetEnvPrefix(cliName)
+	check.AutomaticEnv()
+	if c.profile != nil {
+		check.BindPFlag(profileKey, c.profile)
+	}
+	if err := check.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(check, c.path); err != nil {
+		return nil, err
+	}
+	if err := applyProfile(check); err != nil {
+		return nil, err
+	}
+
+	if err := c.v.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	if err := applyProfile(c.v); err != nil {
+		return nil, err
+	}
+	current := flattenSettings(c.v.AllSettings())
+	changes, keys := diffSettings(c.previous, current)
+	c.previous = current
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	log.Printf("Config file %s changed:", c.path)
+	for i, change := range changes {
+		if !reloaded(keys[i]) {
+			change += " (not reloaded, applies after a restart)"
+		}
+		log.Printf("  %s", change)
+	}
+	return keys, nil
+}
+
+// flattenSettings turns nested settings into dotted keys, e.g. aliases.prod
+func flattenSettings(settings map[string]any) map[string]any {
+	flat := map[string]any{}
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			if nested, ok := v.(map[string]any); ok {
+				walk(prefix+k+".", nested)
+				continue
+			}
+			flat[prefix+k] = v
+		}
+	}
+	walk("", settings)
+	return flat
+}
+
+// diffSettings describes the differences between two flattened settings,
+// with secrets redacted, and returns the sorted changed keys
+func diffSettings(previous, current map[string]any) ([]string, []string) {
+	var keys []string
+	for k, v := range current {
+		if old, ok := previous[k]; !ok || !reflect.DeepEqual(old, v) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range previous {
+		if _, ok := current[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	display := func(key string, v any) string {
+		if isSecretKey(key) {
+			return "[REDACTED]"
+		}
+		return fmt.Sprintf("%v", v)
+	}
+
+	changes := make([]string, 0, len(keys))
+	for _, k := range keys {
+		old, hadOld := previous[k]
+		v, hasNew := current[k]
+		switch {
+		case !hadOld:
+			changes = append(changes, fmt.Sprintf("+ %s: %s", k, display(k, v)))
+		case !hasNew:
+			changes = append(changes, fmt.Sprintf("- %s: %s", k, display(k, old)))
+		default:
+			changes = append(changes, fmt.Sprintf("~ %s: %s -> %s", k, display(k, old), display(k, v)))
+		}
+	}
+	return changes, keys
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestDiffSettings(t *testing.T) {
+	previous := flattenSettings(map[string]any{
+		"order":        "desc",
+		"project":      "my-project",
+		"access-token": "old-secret",
+		"aliases":      map[string]any{"prod": "my-prod-1234", "stg": "my-stg"},
+	})
+	current := flattenSettings(map[string]any{
+		"order":        "asc",
+		"project":      "my-project",
+		"access-token": "new-secret",
+		"aliases":      map[string]any{"prod": "my-prod-1234", "dev": "my-dev"},
+	})
+
+	changes, keys := diffSettings(previous, current)
+
+	expectedKeys := []string{"access-token", "aliases.dev", "aliases.stg", "order"}
+	if !reflect.DeepEqual(keys, expectedKeys) {
+		t.Errorf("diffSettings() keys = %v, want %v", keys, expectedKeys)
+	}
+	expectedChanges := []string{
+		"~ access-token: [REDACTED] -> [REDACTED]",
+		"+ aliases.dev: my-dev",
+		"- aliases.stg: my-stg",
+		"~ order: desc -> asc",
+	}
+	if !reflect.DeepEqual(changes, expectedChanges) {
+		t.Errorf("diffSettings() changes = %v, want %v", changes, expectedChanges)
+	}
+}
+
+func TestFilterSetting(t *testing.T) {
+	for key, expected := range map[string]bool{
+		"alwaysfilter":          true,
+		"aliases.prod":          true,
+		"profile":               false,
+		"profiles.prod.project": false,
+		"format":                false,
+		"project":               false,
+		"notify":                false,
+		"aliasesx":              false,
+	} {
+		if got := filterSetting(key); got != expected {
+			t.Errorf("filterSetting(%q) = %v, want %v", key, got, expected)
+		}
+	}
+}
+
+func TestReloadedSetting(t *testing.T) {
+	for key, expected := range map[string]bool{
+		"alwaysfilter":          true,
+		"aliases.prod":          true,
+		"format":                true,
+		"timezone":              true,
+		"jsonprotonames":        true,
+		"notify":                true,
+		"profile":               true,
+		"profiles.prod.project": true,
+		"project":               false,
+		"transport":             false,
+		"profilesx":             false,
+	} {
+		if got := reloadedSetting(key); got != expected {
+			t.Errorf("reloadedSetting(%q) = %v, want %v", key, got, expected)
+		}
+	}
+}
+
+func TestConfigFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(`configVersion: 1
+profile: prod
+alwaysFilter: severity>=INFO
+profiles:
+  prod:
+    alwaysFilter: severity>=ERROR
+`)
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyProfile(v); err != nil {
+		t.Fatal(err)
+	}
+	c := newConfigFile(v, path, nil)
+
+	// The settings of the active profile keep applying over the reloaded ones.
+	write(`configVersion: 1
+profile: prod
+alwaysFilter: severity>=INFO
+format: json
+profiles:
+  prod:
+    alwaysFilter: severity>=WARNING
+`)
+	changed, err := c.reload(reloadedSetting)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(changed, []string{"alwaysfilter", "format", "profiles.prod.alwaysfilter"}) {
+		t.Errorf("reload() changed %v, want the filter of the profile and the format", changed)
+	}
+	if got := v.GetString("alwaysFilter"); got != "severity>=WARNING" {
+		t.Errorf("alwaysFilter = %q after the reload, want the one of the profile", got)
+	}
+
+	// Invalid settings are not applied.
+	for _, invalid := range []string{
+		"configVersion: 99\nalwaysFilter: severity>=DEBUG\n",
+		"configVersion: 1\nprofile: staging\nalwaysFilter: severity>=DEBUG\n",
+		"configVersion: 1\nalwaysFilter: [\n",
+	} {
+		write(invalid)
+		if _, err := c.reload(reloadedSetting); err == nil {
+			t.Errorf("reload() of %q succeeded, want an error", invalid)
+		}
+		if got := v.GetString("alwaysFilter"); got != "severity>=WARNING" || v.GetString("format") != "json" {
+			t.Errorf("alwaysFilter = %q after reloading %q, want the previous settings", got, invalid)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var resourcesCmd = &cobra.Command{
+	Use:   "resources",
+	Short: "Inspect monitored resource types",
+	Long:  `Inspect monitored resource types`,
+}
+
+var resourcesListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List monitored resource descriptors",
+	Long: `List the monitored resource descriptors known to Cloud Logging.
+
+Each descriptor is printed as a JSON line and includes the resource type
+(usable as resource.type in filters) together with its label schema.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx := cmd.Context()
+
+		// Descriptors are global, so the project is only used for the client setup.
+		client, err := newLogadminClient(ctx, expandProject(configuredProject(), projectAliases()))
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		cobra.CheckErr(printAll(client.ResourceDescriptors(ctx).Next))
+	},
+}
+
+func init() {
+	resourcesCmd.AddCommand(resourcesListCmd)
+	rootCmd.AddCommand(resourcesCmd)
+}
@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/api/label"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/grpc"
+)
+
+type fakeResources struct {
+	loggingpb.UnimplementedLoggingServiceV2Server
+}
+
+func (f *fakeResources) ListMonitoredResourceDescriptors(ctx context.Context, req *loggingpb.ListMonitoredResourceDescriptorsRequest) (*loggingpb.ListMonitoredResourceDescriptorsResponse, error) {
+	return &loggingpb.ListMonitoredResourceDescriptorsResponse{ResourceDescriptors: []*monitoredres.MonitoredResourceDescriptor{
+		{Type: "gce_instance", Labels: []*label.LabelDescriptor{{Key: "instance_id"}, {Key: "zone"}}},
+		{Type: "k8s_container"},
+	}}, nil
+}
+
+func TestResourcesList(t *testing.T) {
+	client := newFakeClient(t, func(s *grpc.Server) { loggingpb.RegisterLoggingServiceV2Server(s, &fakeResources{}) })
+
+	var buf bytes.Buffer
+	saved := stdout
+	stdout = &buf
+	defer func() { stdout = saved }()
+
+	if err := printAll(client.ResourceDescriptors(context.Background()).Next); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"type":"gce_instance"`) || !strings.Contains(lines[0], `"key":"zone"`) || !strings.Contains(lines[1], `"type":"k8s_container"`) {
+		t.Errorf("printed %q, want a JSON line per descriptor with its labels", lines)
+	}
+}
@@ -6,23 +6,35 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path"
 	"regexp"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/gcloud"
 	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/dippi/grapple/pkg/grapple"
 	"github.com/googleapis/gax-go/v2/apierror"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"google.golang.org/api/iterator"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
 )
 
 var cliName = "grapple"
+
+// identifierPattern matches the field names that need no quoting in filters
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 var cfgFile string
+var noGcloud bool
+
+// exitStatus is set by --exit-status, under which errors exit with 2 as 1 means that no entries matched
+var exitStatus bool
 
 var rootCmd = &cobra.Command{
 	Use:   cliName,
@@ -30,46 +42,27 @@ var rootCmd = &cobra.Command{
 	Long:  `Fetch logs from Google Cloud Logging`,
 	Args:  cobra.MatchAll(cobra.MaximumNArgs(1), cobra.OnlyValidArgs),
 	Run: func(cmd *cobra.Command, args []string) {
-		projectId := viper.GetString("project")
-		if projectId == "" {
-			log.Fatal("Error: required flag \"project\" not set")
-		}
-
-		from, to, err := determineTimeWindow(cmd)
-		cobra.CheckErr(err)
-
 		filter := ""
 		if len(args) > 0 {
 			filter = args[0]
 		}
-		allFilters := buildFilter(from, to, filter)
-
-		newestFirst := viper.GetString("order") == "desc"
-
-		ctx := cmd.Context()
-
-		client, err := logadmin.NewClient(ctx, projectId)
-		cobra.CheckErr(err)
-		defer client.Close()
-
-		opts := []logadmin.EntriesOption{
-			logadmin.PageSize(1000),
-			logadmin.Filter(allFilters),
-		}
 
-		if newestFirst {
-			opts = append(opts, logadmin.NewestFirst())
+		if trace := cmd.Flag("trace").Value.String(); trace != "" {
+			filter = andFilters(filter, traceFilter(requireProject(), trace))
+			oldestFirst(cmd)
 		}
 
-		err = fetchAndProcessLogs(ctx, client, opts)
-		cobra.CheckErr(err)
+		runQuery(cmd, filter)
 	},
 }
 
 func Execute() {
 	log.SetFlags(0)
-	err := rootCmd.Execute()
-	cobra.CheckErr(err)
+	args, err := expandSavedQuery(os.Args[1:])
+	checkErr(err)
+	rootCmd.SetArgs(args)
+	err = rootCmd.Execute()
+	checkErr(err)
 }
 
 func init() {
@@ -77,25 +70,43 @@ func init() {
 
 	configDescription := fmt.Sprintf("config file (default is .%v.yaml in the working directory or in the home directory)", cliName)
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", configDescription)
-
-	rootCmd.Flags().String("project", "", "Google Cloud Platform project ID")
-	rootCmd.Flags().String("from", "", "start of time range")
-	rootCmd.Flags().String("to", "", "end of time range")
-	rootCmd.Flags().String("freshness", "", "maximum age of log entries (e.g. 2h, 3d4h)")
-	rootCmd.Flags().String("order", "desc", "ordering based on timestamp, valid values: asc, desc")
+	rootCmd.PersistentFlags().StringVar(&logTo, "log-to", logToStderr, "destination of diagnostic messages, valid values: stderr, journal (systemd)")
+
+	rootCmd.PersistentFlags().String("project", "", "Google Cloud Platform project ID")
+	rootCmd.PersistentFlags().StringVar(&credentialsFile, "credentials-file", "", "authenticate with this service account key or credential configuration instead of the application default credentials")
+	rootCmd.PersistentFlags().StringVar(&accessToken, "access-token", "", "authenticate with this OAuth2 access token, e.g. from gcloud auth print-access-token (default $"+accessTokenEnv+")")
+	rootCmd.PersistentFlags().StringVar(&caCertFile, "ca-cert", "", "also trust the root CAs of this PEM file for the Logging API, e.g. of a TLS-intercepting proxy")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerify, "insecure-skip-verify", false, "do not verify the certificate of the Logging API, for test environments only")
+	rootCmd.PersistentFlags().String("endpoint", "", "address of the Logging API, e.g. restricted.googleapis.com or logging.europe-west1.rep.googleapis.com (default logging.googleapis.com:443)")
+	rootCmd.PersistentFlags().String("transport", transportGRPC, "transport of the Logging API, valid values: grpc, rest (e.g. when gRPC is blocked by a firewall)")
+	rootCmd.PersistentFlags().StringVar(&quotaProject, "quota-project", "", "bill the API requests to this project instead of the queried one, which needs serviceusage.services.use on it (default $GOOGLE_CLOUD_QUOTA_PROJECT)")
+	rootCmd.PersistentFlags().BoolVar(&noGcloud, "no-gcloud", false, "do not fall back to the project of the active gcloud configuration when --project is not set")
+	rootCmd.PersistentFlags().String(profileKey, "", "use the settings of this profile of the config file, see grapple context")
+	rootCmd.PersistentFlags().String("timezone", "", "zone of the --from/--to values without one and of the timestamps printed as text, e.g. Europe/Rome or Local (default UTC)")
+	addQueryFlags(rootCmd)
+	rootCmd.Flags().String("trace", "", "only fetch the entries of this trace, oldest first (e.g. 4bf92f3577b34da6a3ce929d0e0e4736)")
 
 	rootCmd.MarkFlagFilename("config")
-
-	viper.BindPFlag("project", rootCmd.Flags().Lookup("project"))
+	rootCmd.MarkPersistentFlagFilename("credentials-file", "json")
+	rootCmd.MarkPersistentFlagFilename("ca-cert", "pem", "crt")
+
+	viper.BindPFlag("project", rootCmd.PersistentFlags().Lookup("project"))
+	viper.BindPFlag("timezone", rootCmd.PersistentFlags().Lookup("timezone"))
+	viper.BindPFlag("endpoint", rootCmd.PersistentFlags().Lookup("endpoint"))
+	viper.BindPFlag("transport", rootCmd.PersistentFlags().Lookup("transport"))
+	viper.BindPFlag(profileKey, rootCmd.PersistentFlags().Lookup(profileKey))
 	viper.BindPFlag("order", rootCmd.Flags().Lookup("order"))
+	viper.BindPFlag("format", rootCmd.Flags().Lookup("format"))
 }
 
 func initConfig() {
+	checkErr(setupLogging(logTo))
+
 	if cfgFile != "" {
 		viper.SetConfigFile(cfgFile)
 	} else {
 		home, err := os.UserHomeDir()
-		cobra.CheckErr(err)
+		checkErr(err)
 
 		viper.AddConfigPath(home)
 		viper.AddConfigPath(".")
@@ -108,9 +119,152 @@ func initConfig() {
 
 	if err := viper.ReadInConfig(); err == nil {
 		log.Println("Using config file:", viper.ConfigFileUsed())
+		if err := validateConfig(viper.ConfigFileUsed()); err != nil {
+			fatalf("Error: %v", err)
+		}
 	} else if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
-		log.Fatalf("Error: %v", err)
+		fatalf("Error: %v", err)
+	}
+	if err := applyProfile(viper.GetViper()); err != nil {
+		fatalf("Error: %v", err)
+	}
+}
+
+// errorExitCode returns the exit status of errors
+func errorExitCode() int {
+	if exitStatus {
+		return 2
+	}
+	return 1
+}
+
+// checkErr is cobra.CheckErr, exiting with errorExitCode
+func checkErr(err error) {
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(errorExitCode())
+	}
+}
+
+// fatalf is log.Fatalf, exiting with errorExitCode
+func fatalf(format string, v ...any) {
+	log.Printf(format, v...)
+	os.Exit(errorExitCode())
+}
+
+// requireProject returns the configured project ID with aliases expanded, exiting when it is missing
+func requireProject() string {
+	projectId := configuredProject()
+	if projectId == "" {
+		fatalf("Error: required flag \"project\" not set")
+	}
+	return expandProject(projectId, projectAliases())
+}
+
+// configuredProject returns the project ID from flags, env and config, falling back to
+// the core/project property of the active gcloud configuration unless --no-gcloud is given
+func configuredProject() string {
+	if projectId := viper.GetString("project"); projectId != "" || noGcloud {
+		return projectId
+	}
+	projectId, err := gcloud.Project()
+	if err != nil {
+		log.Printf("Warning: reading the gcloud configuration: %v", err)
+		return ""
+	}
+	if projectId != "" {
+		log.Printf("Using project %s from the gcloud configuration", projectId)
+	}
+	return projectId
+}
+
+// printJSON writes a protobuf message to the output as a single JSON line
+func printJSON(m proto.Message) error {
+	jsonBytes, err := jsonMarshalOptions.Marshal(m)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(append(jsonBytes, '\n'))
+	return err
+}
+
+// printAll drains an API iterator, printing each item as a JSON line
+func printAll[T proto.Message](next func() (T, error)) error {
+	for {
+		item, err := next()
+		if errors.Is(err, iterator.Done) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := printJSON(item); err != nil {
+			return err
+		}
+	}
+}
+
+// determineViews returns the resource names of the log views selected via --bucket and --view,
+// or via --include-buckets, if any
+func determineViews(ctx context.Context, cmd *cobra.Command, client *logadmin.Client) ([]string, error) {
+	bucket := cmd.Flag("bucket").Value.String()
+	view := cmd.Flag("view").Value.String()
+	location := cmd.Flag("location").Value.String()
+	included, err := cmd.Flags().GetStringSlice("include-buckets")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(included) > 0 {
+		if bucket != "" || view != "" {
+			return nil, errors.New("--include-buckets cannot be used together with --bucket or --view")
+		}
+		var buckets []*loggingpb.LogBucket
+		it := client.Buckets(ctx, "-")
+		for {
+			b, err := it.Next()
+			if errors.Is(err, iterator.Done) {
+				break
+			} else if err != nil {
+				return nil, fmt.Errorf("listing the buckets of --include-buckets: %w", err)
+			}
+			buckets = append(buckets, b)
+		}
+		return bucketViews(included, buckets)
+	}
+	if bucket == "" {
+		if view != "" {
+			return nil, errors.New("--view requires --bucket")
+		}
+		return nil, nil
+	}
+	if view == "" {
+		view = "_AllLogs"
+	}
+	return []string{client.ViewPath(location, bucket, view)}, nil
+}
+
+// bucketViews returns the _AllLogs views of the buckets with the given IDs, in any location
+func bucketViews(ids []string, buckets []*loggingpb.LogBucket) ([]string, error) {
+	var views []string
+	for _, id := range ids {
+		found := false
+		for _, b := range buckets {
+			if path.Base(b.Name) == id {
+				views = append(views, b.Name+"/views/_AllLogs")
+				found = true
+			}
+		}
+		if !found {
+			available := make([]string, len(buckets))
+			for i, b := range buckets {
+				available[i] = path.Base(b.Name)
+			}
+			slices.Sort(available)
+			return nil, fmt.Errorf("unknown bucket %q in --include-buckets, available buckets: %s", id, strings.Join(slices.Compact(available), ", "))
+		}
 	}
+	return views, nil
 }
 
 // determineTimeWindow parses time-related flags and returns the appropriate time range
@@ -134,22 +288,33 @@ func determineTimeWindow(cmd *cobra.Command) (from, to time.Time, err error) {
 		return from, to, nil
 	}
 
-	if fromFlag != "" && toFlag != "" {
-		from, err = time.Parse(time.RFC3339, fromFlag)
-		if err != nil {
-			return from, to, fmt.Errorf("invalid --from: %w", err)
-		}
-		to, err = time.Parse(time.RFC3339, toFlag)
+	if fromFlag == "" && toFlag == "" {
+		// No explicit time window, logadmin will apply its default.
+		return from, to, nil
+	} else if fromFlag == "" {
+		return from, to, errors.New("--to requires --from")
+	}
+
+	loc, err := timezone()
+	if err != nil {
+		return from, to, err
+	}
+	now := time.Now().In(loc)
+	from, err = parseTimeExpression(fromFlag, now)
+	if err != nil {
+		return from, to, fmt.Errorf("invalid --from: %w", err)
+	}
+	to = now
+	if toFlag != "" {
+		to, err = parseTimeExpression(toFlag, now)
 		if err != nil {
 			return from, to, fmt.Errorf("invalid --to: %w", err)
 		}
-		return from, to, nil
-	} else if fromFlag == "" && toFlag == "" {
-		// No explicit time window, logadmin will apply its default.
-		return from, to, nil
-	} else {
-		return from, to, errors.New("either specify both --from and --to, or neither")
 	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("--from %s is after --to %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	return from, to, nil
 }
 
 // parseFreshness converts strings like "1d", "2h", "30m" into a time.Duration.
@@ -183,12 +348,57 @@ func parseFreshness(expression string) (time.Duration, error) {
 	return total, nil
 }
 
-// buildFilter combines time filter and user filter into a single filter string
-func buildFilter(from, to time.Time, userFilter string) string {
+// defaultFilter returns the alwaysFilter from the config, unless suppressed with --no-default-filter
+func defaultFilter(cmd *cobra.Command) string {
+	if skip, _ := cmd.Flags().GetBool("no-default-filter"); skip {
+		return ""
+	}
+	return expandProjectReferences(viper.GetString("alwaysFilter"), projectAliases())
+}
+
+// andFilters joins the non-empty filters with AND, parenthesizing them when there is more than one
+func andFilters(filters ...string) string {
+	var nonEmpty []string
+	for _, f := range filters {
+		if strings.TrimSpace(f) != "" {
+			nonEmpty = append(nonEmpty, f)
+		}
+	}
+	if len(nonEmpty) == 1 {
+		return nonEmpty[0]
+	}
+	for i, f := range nonEmpty {
+		nonEmpty[i] = "(" + f + ")"
+	}
+	return strings.Join(nonEmpty, " AND ")
+}
+
+// labelClauses compiles key=value pairs into equality clauses on the given labels field,
+// e.g. labels."key"="value". Keys are quoted when quoteKeys is set or when they aren't plain identifiers.
+func labelClauses(field string, pairs []string, quoteKeys bool) ([]string, error) {
+	var clauses []string
+	for _, pair := range pairs {
+		key, value, found := strings.Cut(pair, "=")
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid label %q, expected key=value", pair)
+		}
+		if quoteKeys || !identifierPattern.MatchString(key) {
+			key = strconv.Quote(key)
+		}
+		clauses = append(clauses, fmt.Sprintf("%s.%s=%q", field, key, value))
+	}
+	return clauses, nil
+}
+
+// buildFilter combines time filter on windowField, user filter and additional clauses into a single filter string
+func buildFilter(from, to time.Time, userFilter string, clauses ...string) string {
+	userFilter = andFilters(append([]string{userFilter}, clauses...)...)
+
 	var timeFilter string
 	if !from.IsZero() && !to.IsZero() {
 		timeFilter = fmt.Sprintf(
-			`timestamp >= %q AND timestamp <= %q`,
+			`%[1]s >= %[2]q AND %[1]s <= %[3]q`,
+			windowField,
 			from.Format(time.RFC3339),
 			to.Format(time.RFC3339),
 		)
@@ -202,79 +412,75 @@ func buildFilter(from, to time.Time, userFilter string) string {
 	return fmt.Sprintf("(%s) AND %s", userFilter, timeFilter)
 }
 
-// handleRateLimitError processes rate limit errors and returns whether the operation was rate limited
-func handleRateLimitError(err error, rateLimited bool) bool {
-	// This could handled with status.FromError and err.Code() like the one below
-	// with code ResourceExhausted, but it wouldn't give us easy access to the metadata.
-	// Another way around would be to use status.FromError, then get the .Details()
-	// cast "any" to "google.golang.org/genproto/googleapis/rpc/errdetails.ErrorInfo"
-	// and get the metadata from there.
-	if apiErr, ok := err.(*apierror.APIError); ok && apiErr.Reason() == "RATE_LIMIT_EXCEEDED" {
-		if !rateLimited {
-			metadata := apiErr.Metadata()
-			quotaLimit := metadata["quota_limit"]
-			quotaLimitValue := metadata["quota_limit_value"]
-			if quotaLimit != "" && quotaLimitValue != "" {
-				log.Printf("Rate limit exceeded (%s: %s), sleeping...", quotaLimit, quotaLimitValue)
-			} else {
-				log.Println("Rate limit exceeded, sleeping...")
-				log.Println(apiErr)
-			}
-		} else {
-			log.Println(".")
-		}
-		time.Sleep(1 * time.Second)
-		return true
+// logRateLimit reports the rate limit errors of the fetches, the quota limit at the first one
+// and a dot at each following one
+func logRateLimit(apiErr *apierror.APIError, attempt int, delay time.Duration) {
+	counters.rateLimited++
+	if attempt > 0 {
+		log.Println(".")
+		return
+	}
+	metadata := apiErr.Metadata()
+	quotaLimit := metadata["quota_limit"]
+	quotaLimitValue := metadata["quota_limit_value"]
+	if quotaLimit != "" && quotaLimitValue != "" {
+		log.Printf("Rate limit exceeded (%s: %s), sleeping...", quotaLimit, quotaLimitValue)
+	} else {
+		log.Println("Rate limit exceeded, sleeping...")
+		log.Println(apiErr)
 	}
-	return false
 }
 
-// fetchAndProcessLogs fetches logs from the API and processes them
-func fetchAndProcessLogs(ctx context.Context, client *logadmin.Client, opts []logadmin.EntriesOption) error {
-	rateLimited := false
-	currentToken := ""
-
-outer:
-	for {
-		it := client.Entries(ctx, opts...)
-
-		pager := iterator.NewPager(it, 1000, currentToken)
-		for {
-			var entries []*loggingpb.LogEntry
-			nextToken, err := pager.NextPage(&entries)
-			if err != nil {
-				if errors.Is(err, context.Canceled) || err.Error() == "no more items in iterator" {
-					break outer
-				}
-				if rateLimited = handleRateLimitError(err, rateLimited); rateLimited {
-					break
-				}
-				if err, ok := status.FromError(err); ok && err.Code() == codes.Unauthenticated {
-					return errors.New("unauthenticated, please run `gcloud auth application-default login` and try again")
-				}
-				return err
-			}
-
-			if rateLimited {
-				log.Println("Rate limit expired")
-				rateLimited = false
-			}
-
-			for _, entry := range entries {
-				jsonBytes, err := protojson.MarshalOptions{Multiline: false}.Marshal(entry)
-				if err != nil {
-					log.Printf("Error marshaling log entry (%s): %v", entry.InsertId, err)
-					continue
-				}
-				fmt.Println(string(jsonBytes))
-			}
-
-			if nextToken == "" {
-				break outer
-			}
+// fetchTrace reports the progress of the fetches to the logs, the health checks, the crash
+// reports and the run summary
+var fetchTrace = &grapple.Trace{
+	PageRequest: func(pageToken string) {
+		lastRequest.PageToken = pageToken
+		lastRequest.SentAt = time.Now()
+	},
+	PageError:        func(err error) { health.recordError(err) },
+	Page:             func(entries int) { health.recordSuccess(entries) },
+	Backlog:          func(entries int) { health.setBacklog(entries) },
+	RateLimited:      logRateLimit,
+	RateLimitExpired: func() { log.Println("Rate limit expired") },
+	TransientError: func(s *status.Status, delay time.Duration) {
+		counters.transient++
+		log.Printf("Transient error (%s), resuming in %s: %s", s.Code(), delay.Round(time.Millisecond), s.Message())
+	},
+	PageTokenExpired: func(count int, from time.Time) {
+		counters.expiredPageTokens++
+		log.Printf("Page token expired after %d entries, restarting the query from %s", count, from.Format(time.RFC3339Nano))
+	},
+}
 
-			currentToken = nextToken
+// fetchAndProcessLogs fetches logs from the API and hands them to process, returning the number of entries fetched.
+// The entries that process fails on are logged and skipped.
+func fetchAndProcessLogs(ctx context.Context, client *logadmin.Client, opts []logadmin.EntriesOption, process func(*loggingpb.LogEntry) error) (int, error) {
+	req := client.EntriesRequest(opts...)
+	query := grapple.Query{
+		ResourceNames: req.ResourceNames,
+		Filter:        req.Filter,
+		NewestFirst:   req.OrderBy == "timestamp desc",
+		PageSize:      pageSize,
+	}
+	fetcher := &grapple.Fetcher{Client: client, Backoff: rateLimitBackoff, Trace: fetchTrace}
+
+	count := 0
+	err := fetcher.Fetch(ctx, query, func(entry *loggingpb.LogEntry) error {
+		count++
+		err := process(entry)
+		if err != nil && !errors.Is(err, grapple.ErrStop) {
+			counters.skipped++
+			log.Printf("Error processing log entry (%s): %v", entry.InsertId, err)
+			return nil
 		}
+		return err
+	})
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return count, nil
+	}
+	if s, ok := grapple.Status(err); ok && s.Code() == codes.Unauthenticated {
+		return count, errors.New("unauthenticated, please run `gcloud auth application-default login` and try again")
 	}
-	return nil
+	return count, err
 }
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// executeArgsEnv makes the test binary run Execute with these space-separated arguments, for the tests of exit statuses
+const executeArgsEnv = "GRAPPLE_TEST_EXECUTE_ARGS"
+
+// failingLogging fails every list of entries
+type failingLogging struct {
+	loggingpb.UnimplementedLoggingServiceV2Server
+}
+
+func (*failingLogging) ListLogEntries(context.Context, *loggingpb.ListLogEntriesRequest) (*loggingpb.ListLogEntriesResponse, error) {
+	return nil, status.Error(codes.PermissionDenied, "logging.logEntries.list denied")
+}
+
+func TestExitStatusAPIError(t *testing.T) {
+	if args := os.Getenv(executeArgsEnv); args != "" {
+		os.Args = append([]string{cliName}, strings.Fields(args)...)
+		Execute()
+		return
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	loggingpb.RegisterLoggingServiceV2Server(s, &failingLogging{})
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	for _, test := range []struct {
+		args string
+		want int
+	}{
+		{"--project p", 1},
+		{"--project p --exit-status", 2},
+		{"top --project p --exit-status", 2},
+		{"export sqlite --project p --exit-status --output " + t.TempDir() + "/logs.db", 2},
+	} {
+		run := exec.Command(os.Args[0], "-test.run=^TestExitStatusAPIError$")
+		run.Dir = t.TempDir()
+		run.Env = append(os.Environ(), executeArgsEnv+"="+test.args, loggingEmulatorHostEnv+"="+listener.Addr().String(),
+			"HOME="+run.Dir, "CLOUDSDK_CONFIG="+run.Dir)
+		var exit *exec.ExitError
+		if err := run.Run(); !errors.As(err, &exit) || exit.ExitCode() != test.want {
+			t.Errorf("grapple %s: %v, want exit status %d", test.args, err, test.want)
+		}
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	cases := []struct {
+		path    string
+		has     []string
+		hasNone []string
+	}{
+		{"", []string{"from", "watch", "format", "json-output", "tail", "route", "output"}, nil},
+		{"export pubsub", []string{"from", "watch", "limit", "exit-status"}, []string{"format", "json-output", "tail", "no-color", "grep", "output"}},
+		{"export sqlite", []string{"from", "output"}, []string{"format", "tail", "compress"}},
+		{"top", []string{"from", "format", "no-color", "grep", "output"}, []string{"json-output", "tail", "route", "stats", "fields"}},
+		{"ingest-lag", []string{"format", "grep"}, []string{"json-output", "tail", "no-color"}},
+		{"infer-schema", []string{"format", "output"}, []string{"grep", "tail"}},
+	}
+	for _, c := range cases {
+		cmd, _, err := rootCmd.Find(strings.Fields(c.path))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range c.has {
+			if cmd.Flags().Lookup(name) == nil {
+				t.Errorf("grapple %s has no --%s", c.path, name)
+			}
+		}
+		for _, name := range c.hasNone {
+			if cmd.Flags().Lookup(name) != nil {
+				t.Errorf("grapple %s has --%s, which it ignores", c.path, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/output"
+	"github.com/spf13/cobra"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+// route sends the entries matching its severity condition to a destination, see --route
+type route struct {
+	// fallback routes get the entries no other route matched, the others the ones whose severity compares
+	// with op to severity.
+	fallback    bool
+	op          string
+	severity    logtypepb.LogSeverity
+	destination string
+}
+
+var routeConditionRegexp = regexp.MustCompile(`^severity\s*(>=|<=|!=|=|<|>)\s*(\w+)$`)
+
+// parseRoute parses a --route rule like "severity>=ERROR => file:errors.ndjson"
+func parseRoute(rule string) (route, error) {
+	condition, destination, ok := strings.Cut(rule, "=>")
+	if !ok {
+		return route{}, fmt.Errorf("invalid --route %q, expected CONDITION => DESTINATION", rule)
+	}
+	condition, destination = strings.TrimSpace(condition), strings.TrimSpace(destination)
+
+	r := route{destination: destination}
+	switch path, ok := strings.CutPrefix(destination, "file:"); {
+	case destination == "stdout" || destination == "stderr":
+	case ok && path != "":
+	default:
+		return route{}, fmt.Errorf("invalid --route destination %q, valid values: stdout, stderr, file:PATH", destination)
+	}
+
+	if condition == "default" {
+		r.fallback = true
+		return r, nil
+	}
+	match := routeConditionRegexp.FindStringSubmatch(condition)
+	if match == nil {
+		return route{}, fmt.Errorf("invalid --route condition %q, expected default or a severity comparison like severity>=ERROR", condition)
+	}
+	severity, err := parseSeverity(match[2])
+	if err != nil {
+		return route{}, fmt.Errorf("invalid --route condition %q: %w", condition, err)
+	}
+	r.op, r.severity = match[1], severity
+	return r, nil
+}
+
+func (r route) matches(entry *loggingpb.LogEntry) bool {
+	switch s := entry.Severity; r.op {
+	case "=":
+		return s == r.severity
+	case "!=":
+		return s != r.severity
+	case "<":
+		return s < r.severity
+	case "<=":
+		return s <= r.severity
+	case ">":
+		return s > r.severity
+	default:
+		return s >= r.severity
+	}
+}
+
+// router writes each rendered entry to the destinations of the routes matching it, or of the
+// default routes when none does
+type router struct {
+	routes []route
+	// destinations are the distinct ones of the routes, each with its own --json-output framing.
+	destinations []string
+	framers      map[string]*outputFramer
+	// writers are the files by destination, opened once even when several routes share them.
+	writers map[string]io.Writer
+	files   []io.Closer
+}
+
+// newRouter parses the --route rules and opens the files they write to, appending with --append, mode
+// being the one of --json-output and header the one of the CSV formats
+func newRouter(rules []string, mode, header string, appendFiles bool) (*router, error) {
+	r := &router{framers: map[string]*outputFramer{}, writers: map[string]io.Writer{}}
+	for _, rule := range rules {
+		rt, err := parseRoute(rule)
+		if err != nil {
+			return nil, errors.Join(err, r.closeFiles())
+		}
+		r.routes = append(r.routes, rt)
+		if r.framers[rt.destination] != nil {
+			continue
+		}
+		r.destinations = append(r.destinations, rt.destination)
+		r.framers[rt.destination] = &outputFramer{mode: mode, header: header}
+		path, ok := strings.CutPrefix(rt.destination, "file:")
+		if !ok {
+			continue
+		}
+		f, err := output.Open(output.Options{Path: path, Compression: output.CompressAuto, Append: appendFiles})
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("opening --route file: %w", err), r.closeFiles())
+		}
+		r.writers[rt.destination] = f
+		r.files = append(r.files, f)
+	}
+	return r, nil
+}
+
+// writer returns the destination of a route, stdout being resolved when writing as --output replaces it
+func (r *router) writer(destination string) io.Writer {
+	switch destination {
+	case "stdout":
+		return stdout
+	case "stderr":
+		return os.Stderr
+	default:
+		return r.writers[destination]
+	}
+}
+
+func (r *router) emit(entry *loggingpb.LogEntry, line string) error {
+	written := map[string]bool{}
+	write := func(rt route) error {
+		if written[rt.destination] {
+			return nil
+		}
+		written[rt.destination] = true
+		return r.framers[rt.destination].write(r.writer(rt.destination), line)
+	}
+
+	for _, rt := range r.routes {
+		if !rt.fallback && rt.matches(entry) {
+			if err := write(rt); err != nil {
+				return err
+			}
+		}
+	}
+	if len(written) > 0 {
+		return nil
+	}
+	for _, rt := range r.routes {
+		if rt.fallback {
+			if err := write(rt); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// close ends the output of the destinations and closes the files of the routes
+func (r *router) close() error {
+	var errs []error
+	for _, destination := range r.destinations {
+		errs = append(errs, r.framers[destination].close(r.writer(destination)))
+	}
+	return errors.Join(append(errs, r.closeFiles())...)
+}
+
+func (r *router) closeFiles() error {
+	var errs []error
+	for _, f := range r.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// newRoutedPrinter returns the sink of --route, rendering each entry once for all its destinations
+func newRoutedPrinter(cmd *cobra.Command, rules []string) (process func(*loggingpb.LogEntry) error, flush func() error, err error) {
+	mode, err := jsonOutputMode(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	header, err := csvHeader(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	appendFiles, err := cmd.Flags().GetBool("append")
+	if err != nil {
+		return nil, nil, err
+	}
+	r, err := newRouter(rules, mode, header, appendFiles)
+	if err != nil {
+		return nil, nil, err
+	}
+	process, err = newLinePipeline(cmd, r.emit)
+	if err != nil {
+		return nil, nil, errors.Join(err, r.closeFiles())
+	}
+	return process, r.close, nil
+}
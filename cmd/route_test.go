@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+func TestParseRoute(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected route
+		err      bool
+	}{
+		{"severity>=ERROR => file:errors.ndjson", route{op: ">=", severity: logtypepb.LogSeverity_ERROR, destination: "file:errors.ndjson"}, false},
+		{"severity = warning=>stderr", route{op: "=", severity: logtypepb.LogSeverity_WARNING, destination: "stderr"}, false},
+		{"default => stdout", route{fallback: true, destination: "stdout"}, false},
+		{"severity>=ERROR", route{}, true},
+		{"severity>=LOUD => stdout", route{}, true},
+		{"logName=x => stdout", route{}, true},
+		{"default => file:", route{}, true},
+		{"default => kafka:topic", route{}, true},
+	}
+	for _, test := range tests {
+		got, err := parseRoute(test.rule)
+		if (err != nil) != test.err {
+			t.Errorf("parseRoute(%q) error = %v, want error %t", test.rule, err, test.err)
+			continue
+		}
+		if got != test.expected {
+			t.Errorf("parseRoute(%q) = %+v, want %+v", test.rule, got, test.expected)
+		}
+	}
+}
+
+func TestRouter(t *testing.T) {
+	dir := t.TempDir()
+	errorsPath := filepath.Join(dir, "errors.ndjson")
+	allPath := filepath.Join(dir, "all.ndjson")
+	r, err := newRouter([]string{
+		"severity>=ERROR => file:" + errorsPath,
+		"severity>=INFO => file:" + allPath,
+		// Written once even if the entry matches both.
+		"severity>=CRITICAL => file:" + allPath,
+		"default => stdout",
+	}, jsonOutputLines, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	saved := stdout
+	stdout = &buf
+	defer func() { stdout = saved }()
+
+	for _, entry := range []*loggingpb.LogEntry{
+		{InsertId: "info", Severity: logtypepb.LogSeverity_INFO},
+		{InsertId: "critical", Severity: logtypepb.LogSeverity_CRITICAL},
+		{InsertId: "unset"},
+	} {
+		if err := r.emit(entry, entry.InsertId); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]string{errorsPath: "critical\n", allPath: "info\ncritical\n"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), data, expected)
+		}
+	}
+	if got := buf.String(); got != "unset\n" {
+		t.Errorf("stdout = %q, want %q", got, "unset\n")
+	}
+}
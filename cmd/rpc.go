@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dippi/grapple/pkg/grapple"
+	gax "github.com/googleapis/gax-go/v2"
+	"github.com/spf13/cobra"
+)
+
+// maxPageSize is the largest page size accepted by the API
+const maxPageSize = grapple.MaxPageSize
+
+// pageSize is the number of entries requested per page by fetchAndProcessLogs
+var pageSize = maxPageSize
+
+// rateLimitBackoff is the policy of the pauses of fetchAndProcessLogs when rate limited
+var rateLimitBackoff = grapple.DefaultBackoff
+
+// countedRetryer stops retrying after a number of attempts
+type countedRetryer struct {
+	gax.Retryer
+	remaining int
+}
+
+func (r *countedRetryer) Retry(err error) (time.Duration, bool) {
+	pause, retry := r.Retryer.Retry(err)
+	if !retry || r.remaining <= 0 {
+		return 0, false
+	}
+	r.remaining--
+	return pause, true
+}
+
+// entriesCallOptions returns the call options set by --rpc-timeout and --max-retries
+// on top of the defaults of the logging client
+func entriesCallOptions(cmd *cobra.Command) ([]gax.CallOption, error) {
+	var opts []gax.CallOption
+
+	timeout, err := cmd.Flags().GetDuration("rpc-timeout")
+	if err != nil {
+		return nil, err
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("invalid --rpc-timeout %s", timeout)
+	}
+	if timeout > 0 {
+		opts = append(opts, gax.WithTimeout(timeout))
+	}
+
+	retries, err := cmd.Flags().GetInt("max-retries")
+	if err != nil {
+		return nil, err
+	}
+	if retries >= 0 {
+		opts = append(opts, gax.WithRetry(func() gax.Retryer {
+			return &countedRetryer{
+				Retryer: gax.OnErrorFunc(gax.Backoff{
+					Initial:    100 * time.Millisecond,
+					Max:        time.Minute,
+					Multiplier: 1.3,
+				}, grapple.Retryable),
+				remaining: retries,
+			}
+		}))
+	}
+	return opts, nil
+}
+
+// backoffFlags returns the rate limit backoff policy set by --backoff-initial and --backoff-max
+func backoffFlags(cmd *cobra.Command) (grapple.Backoff, error) {
+	policy := rateLimitBackoff
+	var err error
+	if policy.Initial, err = cmd.Flags().GetDuration("backoff-initial"); err != nil {
+		return policy, err
+	}
+	if policy.Max, err = cmd.Flags().GetDuration("backoff-max"); err != nil {
+		return policy, err
+	}
+	if policy.Initial <= 0 || policy.Max < policy.Initial {
+		return policy, fmt.Errorf("invalid backoff between --backoff-initial %s and --backoff-max %s", policy.Initial, policy.Max)
+	}
+	return policy, nil
+}
+
+// pageSizeFlag returns the page size set by --page-size, capped by the limit of entries if any
+func pageSizeFlag(cmd *cobra.Command, limit int) (int, error) {
+	size, err := cmd.Flags().GetInt("page-size")
+	if err != nil {
+		return 0, err
+	}
+	if size <= 0 || size > maxPageSize {
+		return 0, fmt.Errorf("invalid --page-size %d, expected between 1 and %d", size, maxPageSize)
+	}
+	if limit > 0 {
+		size = min(size, limit)
+	}
+	return size, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/dippi/grapple/pkg/grapple"
+	gax "github.com/googleapis/gax-go/v2"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestPageSizeFlag(t *testing.T) {
+	cases := []struct {
+		flag     string
+		limit    int
+		expected int
+		wantErr  bool
+	}{
+		{"1000", 0, 1000, false},
+		{"200", 0, 200, false},
+		{"200", 50, 50, false},
+		{"200", 500, 200, false},
+		{"0", 0, 0, true},
+		{"1001", 0, 0, true},
+	}
+	for _, c := range cases {
+		cmd := &cobra.Command{}
+		addQueryFlags(cmd)
+		cmd.Flags().Set("page-size", c.flag)
+		got, err := pageSizeFlag(cmd, c.limit)
+		if (err != nil) != c.wantErr || got != c.expected {
+			t.Errorf("pageSizeFlag(%s, limit %d) = %d, %v", c.flag, c.limit, got, err)
+		}
+	}
+}
+
+func TestEntriesCallOptionsRetries(t *testing.T) {
+	cmd := &cobra.Command{}
+	addQueryFlags(cmd)
+	if opts, err := entriesCallOptions(cmd); err != nil || len(opts) != 0 {
+		t.Fatalf("entriesCallOptions() with defaults = %v, %v, want none", opts, err)
+	}
+
+	cmd.Flags().Set("rpc-timeout", "5s")
+	cmd.Flags().Set("max-retries", "2")
+	opts, err := entriesCallOptions(cmd)
+	if err != nil || len(opts) != 2 {
+		t.Fatalf("entriesCallOptions() = %v, %v, want timeout and retry", opts, err)
+	}
+
+}
+
+func TestCountedRetryer(t *testing.T) {
+	r := &countedRetryer{Retryer: gax.OnErrorFunc(gax.Backoff{}, grapple.Retryable), remaining: 2}
+	if _, retry := r.Retry(status.Error(codes.PermissionDenied, "denied")); retry {
+		t.Error("Retry(PermissionDenied) = true")
+	}
+	unavailable := status.Error(codes.Unavailable, "unavailable")
+	for i := range 2 {
+		if _, retry := r.Retry(unavailable); !retry {
+			t.Errorf("Retry(Unavailable) #%d = false", i+1)
+		}
+	}
+	if _, retry := r.Retry(unavailable); retry {
+		t.Error("Retry(Unavailable) after the last attempt = true")
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// savedQueryNamePattern matches the valid names of saved queries
+var savedQueryNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+
+// savedQueries is the content of the saved queries file
+type savedQueries struct {
+	Queries map[string]*savedQuery `json:"queries"`
+}
+
+// savedQuery is a filter with the flags to run it with, as given on the command line
+type savedQuery struct {
+	Args    []string  `json:"args"`
+	SavedAt time.Time `json:"savedAt"`
+}
+
+var queryCmd = &cobra.Command{
+	Use:   "query",
+	Short: "Manage saved queries",
+	Long: `Save a filter with its flags under a name, to run it again later with
+"grapple query run NAME", e.g. from a runbook.
+
+The queries are stored in the grapple directory of the user config dir.`,
+}
+
+var querySaveCmd = &cobra.Command{
+	Use:   "save NAME [filter] [flags]",
+	Short: "Save a filter and its flags under a name, replacing any previous one",
+	Example: `  grapple query save 5xx 'httpRequest.status>=500' --freshness 1h --format http
+  grapple query run 5xx --project staging`,
+	Args:               cobra.MinimumNArgs(1),
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		name, queryArgs := args[0], args[1:]
+		if name == "-h" || name == "--help" {
+			cobra.CheckErr(cmd.Help())
+			return
+		}
+		cobra.CheckErr(validateSavedQuery(name, queryArgs))
+
+		path, err := savedQueriesPath()
+		cobra.CheckErr(err)
+		queries, err := loadSavedQueries(path)
+		cobra.CheckErr(err)
+		queries.Queries[name] = &savedQuery{Args: queryArgs, SavedAt: time.Now().UTC()}
+		cobra.CheckErr(saveSavedQueries(path, queries))
+		log.Printf("Saved query %q, run it with `%v query run %s`", name, cliName, name)
+	},
+}
+
+var queryRunCmd = &cobra.Command{
+	Use:   "run NAME [flags]",
+	Short: "Run a saved query, the flags given after the name override the saved ones",
+	Args:  cobra.MinimumNArgs(1),
+	// The saved arguments are expanded by Execute before parsing the command line,
+	// so this only runs when the expansion did not apply.
+	DisableFlagParsing: true,
+	Run: func(cmd *cobra.Command, args []string) {
+		cobra.CheckErr(fmt.Errorf("the flags of %v must follow `%v query run NAME`", cliName, cliName))
+	},
+}
+
+var queryListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the saved queries",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := savedQueriesPath()
+		cobra.CheckErr(err)
+		queries, err := loadSavedQueries(path)
+		cobra.CheckErr(err)
+		cobra.CheckErr(writeSavedQueries(stdout, queries))
+	},
+}
+
+var queryDeleteCmd = &cobra.Command{
+	Use:   "delete NAME",
+	Short: "Delete a saved query",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path, err := savedQueriesPath()
+		cobra.CheckErr(err)
+		queries, err := loadSavedQueries(path)
+		cobra.CheckErr(err)
+		if _, ok := queries.Queries[args[0]]; !ok {
+			cobra.CheckErr(fmt.Errorf("unknown saved query %q", args[0]))
+		}
+		delete(queries.Queries, args[0])
+		cobra.CheckErr(saveSavedQueries(path, queries))
+	},
+}
+
+// validateSavedQuery checks the name and that the arguments are a valid filter and flags of the root command
+func validateSavedQuery(name string, args []string) error {
+	if !savedQueryNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid query name %q, use letters, digits, '.', '_' and '-'", name)
+	}
+	flags := rootCmd.Flags()
+	flags.AddFlagSet(rootCmd.PersistentFlags())
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return fmt.Errorf("expected at most one filter, got %d arguments: %s", flags.NArg(), strings.Join(flags.Args(), " "))
+	}
+	return nil
+}
+
+// expandSavedQuery replaces "query run NAME" at the start of the arguments with the saved ones,
+// keeping the arguments after the name so that they override the saved flags
+func expandSavedQuery(args []string) ([]string, error) {
+	if len(args) < 3 || args[0] != queryCmd.Name() || args[1] != queryRunCmd.Name() || strings.HasPrefix(args[2], "-") {
+		return args, nil
+	}
+	path, err := savedQueriesPath()
+	if err != nil {
+		return nil, err
+	}
+	queries, err := loadSavedQueries(path)
+	if err != nil {
+		return nil, err
+	}
+	query, ok := queries.Queries[args[2]]
+	if !ok {
+		return nil, fmt.Errorf("unknown saved query %q, see `%v query list`", args[2], cliName)
+	}
+	return append(slices.Clone(query.Args), args[3:]...), nil
+}
+
+func writeSavedQueries(w io.Writer, queries *savedQueries) error {
+	names := make([]string, 0, len(queries.Queries))
+	for name := range queries.Queries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, name := range names {
+		quoted := make([]string, len(queries.Queries[name].Args))
+		for i, arg := range queries.Queries[name].Args {
+			quoted[i] = shellQuote(arg)
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, strings.Join(quoted, " "))
+	}
+	return tw.Flush()
+}
+
+// shellQuote single-quotes an argument unless it only contains characters safe in a shell
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-.,:=/@%+") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// savedQueriesPath returns the location of the saved queries file
+func savedQueriesPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, cliName, "queries.json"), nil
+}
+
+func loadSavedQueries(path string) (*savedQueries, error) {
+	queries := &savedQueries{Queries: map[string]*savedQuery{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return queries, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, queries); err != nil {
+		return nil, fmt.Errorf("corrupted saved queries file %s: %w", path, err)
+	}
+	if queries.Queries == nil {
+		queries.Queries = map[string]*savedQuery{}
+	}
+	return queries, nil
+}
+
+func saveSavedQueries(path string, queries *savedQueries) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(queries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+func init() {
+	queryCmd.AddCommand(querySaveCmd, queryRunCmd, queryListCmd, queryDeleteCmd)
+	rootCmd.AddCommand(queryCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandSavedQuery(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	path, err := savedQueriesPath()
+	if err != nil {
+		t.Fatal(err)
+	}
+	queries := &savedQueries{Queries: map[string]*savedQuery{
+		"5xx": {Args: []string{"httpRequest.status>=500", "--freshness", "1h"}},
+	}}
+	if err := saveSavedQueries(path, queries); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"query", "run", "5xx", "--freshness", "2h"}, []string{"httpRequest.status>=500", "--freshness", "1h", "--freshness", "2h"}},
+		{[]string{"query", "list"}, []string{"query", "list"}},
+		{[]string{"query", "run", "--help"}, []string{"query", "run", "--help"}},
+		{[]string{"severity>=ERROR"}, []string{"severity>=ERROR"}},
+	}
+	for _, c := range cases {
+		got, err := expandSavedQuery(c.args)
+		if err != nil {
+			t.Errorf("expandSavedQuery(%q) unexpected error: %v", c.args, err)
+		} else if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("expandSavedQuery(%q) = %q, want %q", c.args, got, c.expected)
+		}
+	}
+
+	if _, err := expandSavedQuery([]string{"query", "run", "4xx"}); err == nil {
+		t.Errorf("expandSavedQuery() expected error for an unknown query, got nil")
+	}
+}
+
+func TestValidateSavedQuery(t *testing.T) {
+	cases := []struct {
+		name  string
+		args  []string
+		valid bool
+	}{
+		{"5xx", []string{"httpRequest.status>=500", "--freshness", "1h", "--project", "prod"}, true},
+		{"errors.v2", nil, true},
+		{"-x", nil, false},
+		{"5xx", []string{"--no-such-flag"}, false},
+		{"5xx", []string{"a", "b"}, false},
+	}
+	for _, c := range cases {
+		err := validateSavedQuery(c.name, c.args)
+		if (err == nil) != c.valid {
+			t.Errorf("validateSavedQuery(%q, %q) error = %v, want valid %v", c.name, c.args, err, c.valid)
+		}
+	}
+}
+
+func TestWriteSavedQueries(t *testing.T) {
+	queries := &savedQueries{Queries: map[string]*savedQuery{
+		"slow": {Args: []string{"--latency", ">1s"}},
+		"5xx":  {Args: []string{"httpRequest.status>=500", "--freshness", "1h", "--format", "it's"}},
+	}}
+	var b strings.Builder
+	if err := writeSavedQueries(&b, queries); err != nil {
+		t.Fatal(err)
+	}
+	expected := "5xx   'httpRequest.status>=500' --freshness 1h --format 'it'\\''s'\nslow  --latency '>1s'\n"
+	if b.String() != expected {
+		t.Errorf("writeSavedQueries() = %q, want %q", b.String(), expected)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/pii"
+	"github.com/spf13/cobra"
+)
+
+var scanPIICmd = &cobra.Command{
+	Use:   "scan-pii [filter]",
+	Short: "Report the logs and fields leaking sensitive data",
+	Long: fmt.Sprintf(`Run detectors of sensitive data over every string field of the entries matching
+the filter and report, per log and field, what was found, how many times and a
+masked example. The entries themselves are not printed.
+
+Builtin detectors: %s.
+--detector adds custom ones as NAME=REGEX.`, strings.Join(detectorNames(pii.Builtin()), ", ")),
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newPIISink)
+	},
+}
+
+func detectorNames(detectors []pii.Detector) []string {
+	names := make([]string, len(detectors))
+	for i, d := range detectors {
+		names[i] = d.Name
+	}
+	return names
+}
+
+// newPIISink returns the sink scanning the entries and printing the findings
+func newPIISink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	names, err := cmd.Flags().GetStringSlice("detectors")
+	if err != nil {
+		return nil, nil, err
+	}
+	detectors, err := pii.Select(names)
+	if err != nil {
+		return nil, nil, err
+	}
+	custom, err := cmd.Flags().GetStringArray("detector")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, definition := range custom {
+		d, err := pii.Custom(definition)
+		if err != nil {
+			return nil, nil, err
+		}
+		detectors = append(detectors, d)
+	}
+
+	scanner := newPIIScanner(detectors)
+	format := flagOrConfig(cmd, "format")
+	if format == "" {
+		format = defaultFormat(cmd, false)
+	}
+	return scanner.observe, func() error {
+		if format == formatJSON {
+			for _, f := range scanner.findings() {
+				line, err := marshalJSONValue(f)
+				if err != nil {
+					return err
+				}
+				if _, err := io.WriteString(stdout, line+"\n"); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return scanner.writeText(stdout)
+	}, nil
+}
+
+type piiFinding struct {
+	Log      string `json:"log"`
+	Field    string `json:"field"`
+	Detector string `json:"detector"`
+	Count    int    `json:"count"`
+	// Example is the first match, masked.
+	Example string `json:"example"`
+}
+
+// piiScanner collects the findings of the detectors per log, field and detector
+type piiScanner struct {
+	detectors []pii.Detector
+	seen      map[piiFinding]*piiFinding
+	entries   int
+	flagged   int
+}
+
+func newPIIScanner(detectors []pii.Detector) *piiScanner {
+	return &piiScanner{detectors: detectors, seen: map[piiFinding]*piiFinding{}}
+}
+
+func (s *piiScanner) observe(entry *loggingpb.LogEntry) error {
+	m, err := entryToMap(entry)
+	if err != nil {
+		return err
+	}
+	s.entries++
+	found := false
+	s.walk("", m, func(path, value string) {
+		for _, d := range s.detectors {
+			matches := d.Find(value)
+			if len(matches) == 0 {
+				continue
+			}
+			found = true
+			key := piiFinding{Log: shortLogName(entry.LogName), Field: path, Detector: d.Name}
+			f, ok := s.seen[key]
+			if !ok {
+				f = &key
+				f.Example = pii.Mask(matches[0])
+				s.seen[key] = f
+			}
+			f.Count += len(matches)
+		}
+	})
+	if found {
+		s.flagged++
+	}
+	return nil
+}
+
+// walk calls visit with every string in value and its path, arrays are marked with []
+func (s *piiScanner) walk(path string, value any, visit func(path, value string)) {
+	switch v := value.(type) {
+	case string:
+		visit(path, v)
+	case map[string]any:
+		for key, nested := range v {
+			if path != "" {
+				key = path + "." + key
+			}
+			s.walk(key, nested, visit)
+		}
+	case []any:
+		for _, nested := range v {
+			s.walk(path+"[]", nested, visit)
+		}
+	}
+}
+
+// findings returns the findings sorted by log, field and detector
+func (s *piiScanner) findings() []*piiFinding {
+	findings := make([]*piiFinding, 0, len(s.seen))
+	for _, f := range s.seen {
+		findings = append(findings, f)
+	}
+	slices.SortFunc(findings, func(a, b *piiFinding) int {
+		return cmp.Or(strings.Compare(a.Log, b.Log), strings.Compare(a.Field, b.Field), strings.Compare(a.Detector, b.Detector))
+	})
+	return findings
+}
+
+func (s *piiScanner) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "LOG\tFIELD\tDETECTOR\tCOUNT\tEXAMPLE\n")
+	for _, f := range s.findings() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\n", f.Log, f.Field, f.Detector, f.Count, f.Example)
+	}
+	fmt.Fprintf(tw, "\n%d of %d entries flagged\n", s.flagged, s.entries)
+	return tw.Flush()
+}
+
+func init() {
+	addFetchFlags(scanPIICmd)
+	addReportFlags(scanPIICmd)
+	scanPIICmd.Flags().StringSlice("detectors", nil, "only run these comma-separated builtin detectors (default all)")
+	scanPIICmd.Flags().StringArray("detector", nil, "add a custom detector as NAME=REGEX (repeatable)")
+
+	rootCmd.AddCommand(scanPIICmd)
+}
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/pii"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPIIScanner(t *testing.T) {
+	payload, err := structpb.NewStruct(map[string]any{
+		"user":  map[string]any{"email": "jane@example.com"},
+		"cards": []any{"4111111111111111", "none"},
+		"count": 4111111111111111,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newPIIScanner(pii.Builtin())
+	for _, entry := range []*loggingpb.LogEntry{
+		{LogName: "projects/p/logs/app", Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: payload}},
+		{LogName: "projects/p/logs/app", Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "mail bob@example.com or ann@example.com"}},
+		{LogName: "projects/p/logs/app", Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "nothing here"}},
+	} {
+		if err := s.observe(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []piiFinding{
+		{Log: "app", Field: "jsonPayload.cards[]", Detector: "credit-card", Count: 1, Example: "4111********1111"},
+		{Log: "app", Field: "jsonPayload.user.email", Detector: "email", Count: 1, Example: "jane********.com"},
+		{Log: "app", Field: "textPayload", Detector: "email", Count: 2, Example: "bob@*******.com"},
+	}
+	findings := s.findings()
+	if len(findings) != len(expected) {
+		t.Fatalf("findings() = %+v", findings)
+	}
+	for i, f := range findings {
+		if *f != expected[i] {
+			t.Errorf("findings()[%d] = %+v, want %+v", i, *f, expected[i])
+		}
+	}
+	if s.entries != 3 || s.flagged != 2 {
+		t.Errorf("%d of %d entries flagged, want 2 of 3", s.flagged, s.entries)
+	}
+}
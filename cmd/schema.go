@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxExampleLength bounds the length of the example values of the schema
+const maxExampleLength = 40
+
+var inferSchemaCmd = &cobra.Command{
+	Use:   "infer-schema [filter]",
+	Short: "Report the fields of the JSON payloads of matching entries",
+	Long: `Sample the entries matching the filter, newest first, and report the fields
+observed in their jsonPayload: path, types, how often they occur and an example
+value. The paths can be used as they are with --fields.
+
+Nested objects are walked, arrays are reported as a whole.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newSchemaSink)
+	},
+}
+
+// newSchemaSink returns the sink collecting the payload fields of --sample entries and printing the schema
+func newSchemaSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	sample, err := cmd.Flags().GetInt("sample")
+	if err != nil {
+		return nil, nil, err
+	}
+	if sample <= 0 {
+		return nil, nil, fmt.Errorf("invalid --sample %d", sample)
+	}
+
+	schema := newPayloadSchema()
+	process := func(entry *loggingpb.LogEntry) error {
+		schema.observe(entry)
+		if schema.entries >= sample {
+			return grapple.ErrStop
+		}
+		return nil
+	}
+
+	format := flagOrConfig(cmd, "format")
+	if format == "" {
+		format = defaultFormat(cmd, false)
+	}
+	flush := func() error {
+		if format == formatJSON {
+			for _, field := range schema.fields() {
+				line, err := marshalJSONValue(field)
+				if err != nil {
+					return err
+				}
+				if _, err := stdout.Write([]byte(line + "\n")); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return schema.writeText(stdout)
+	}
+	return process, flush, nil
+}
+
+// payloadSchema accumulates the fields seen in the JSON payloads of a sample of entries
+type payloadSchema struct {
+	entries int
+	seen    map[string]*schemaField
+}
+
+type schemaField struct {
+	Path string `json:"path"`
+	// Types counts the occurrences of each JSON type.
+	Types       map[string]int `json:"types"`
+	Occurrences int            `json:"occurrences"`
+	// Rate is the fraction of the sampled entries having the field.
+	Rate    float64 `json:"rate"`
+	Example string  `json:"example"`
+}
+
+func newPayloadSchema() *payloadSchema {
+	return &payloadSchema{seen: map[string]*schemaField{}}
+}
+
+func (s *payloadSchema) observe(entry *loggingpb.LogEntry) {
+	s.entries++
+	if payload := entry.GetJsonPayload(); payload != nil {
+		s.walk("jsonPayload", payload)
+	}
+}
+
+func (s *payloadSchema) walk(prefix string, obj *structpb.Struct) {
+	for key, value := range obj.GetFields() {
+		path := prefix + "." + key
+		field, ok := s.seen[path]
+		if !ok {
+			field = &schemaField{Path: path, Types: map[string]int{}}
+			s.seen[path] = field
+		}
+		field.Occurrences++
+		field.Types[jsonType(value)]++
+		if nested := value.GetStructValue(); nested != nil {
+			s.walk(path, nested)
+		} else if field.Example == "" {
+			field.Example = exampleValue(value)
+		}
+	}
+}
+
+// fields returns the observed fields sorted by path
+func (s *payloadSchema) fields() []*schemaField {
+	fields := make([]*schemaField, 0, len(s.seen))
+	for _, field := range s.seen {
+		field.Rate = float64(field.Occurrences) / float64(s.entries)
+		fields = append(fields, field)
+	}
+	slices.SortFunc(fields, func(a, b *schemaField) int { return strings.Compare(a.Path, b.Path) })
+	return fields
+}
+
+func (s *payloadSchema) writeText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "FIELD\tTYPES\tRATE\tEXAMPLE\n")
+	for _, field := range s.fields() {
+		fmt.Fprintf(tw, "%s\t%s\t%.1f%%\t%s\n", field.Path, typeSummary(field.Types), field.Rate*100, field.Example)
+	}
+	fmt.Fprintf(tw, "\n%d entries sampled\n", s.entries)
+	return tw.Flush()
+}
+
+// typeSummary lists the types of a field, the most common first
+func typeSummary(types map[string]int) string {
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int {
+		if types[a] != types[b] {
+			return types[b] - types[a]
+		}
+		return strings.Compare(a, b)
+	})
+	return strings.Join(names, "|")
+}
+
+func jsonType(v *structpb.Value) string {
+	switch v.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return "string"
+	case *structpb.Value_NumberValue:
+		return "number"
+	case *structpb.Value_BoolValue:
+		return "bool"
+	case *structpb.Value_StructValue:
+		return "object"
+	case *structpb.Value_ListValue:
+		return "array"
+	default:
+		return "null"
+	}
+}
+
+// exampleValue returns a short JSON rendering of a value
+func exampleValue(v *structpb.Value) string {
+	data, err := json.Marshal(v.AsInterface())
+	if err != nil {
+		return ""
+	}
+	example := string(data)
+	if len(example) > maxExampleLength {
+		example = example[:maxExampleLength-3] + "..."
+	}
+	return example
+}
+
+func init() {
+	addFetchFlags(inferSchemaCmd)
+	addReportFlags(inferSchemaCmd)
+	inferSchemaCmd.Flags().Int("sample", 1000, "number of entries to sample")
+
+	rootCmd.AddCommand(inferSchemaCmd)
+}
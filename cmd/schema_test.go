@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestPayloadSchema(t *testing.T) {
+	schema := newPayloadSchema()
+	for _, fields := range []map[string]any{
+		{"message": "hello", "status": 200, "http": map[string]any{"method": "GET"}},
+		{"message": "world", "status": "ok"},
+		{"tags": []any{"a"}},
+	} {
+		payload, err := structpb.NewStruct(fields)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schema.observe(&loggingpb.LogEntry{Payload: &loggingpb.LogEntry_JsonPayload{JsonPayload: payload}})
+	}
+	schema.observe(&loggingpb.LogEntry{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "plain"}})
+
+	var got []string
+	for _, f := range schema.fields() {
+		got = append(got, f.Path+" "+typeSummary(f.Types)+" "+f.Example)
+	}
+	expected := []string{
+		"jsonPayload.http object ",
+		`jsonPayload.http.method string "GET"`,
+		`jsonPayload.message string "hello"`,
+		"jsonPayload.status number|string 200",
+		`jsonPayload.tags array ["a"]`,
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("fields() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+	if rate := schema.fields()[2].Rate; rate != 0.5 {
+		t.Errorf("rate of jsonPayload.message = %v, want 0.5", rate)
+	}
+}
@@ -0,0 +1,346 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/dippi/grapple/internal/lql"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var serveGRPCCmd = &cobra.Command{
+	Use:   "serve-grpc",
+	Short: "Serve curated access to the log entries over gRPC",
+	Long: `Serve the grapple.v1.Grapple gRPC service (see proto/grapple/v1/grapple.proto),
+so that internal tools can read log entries through grapple instead of being
+granted Logging roles on the project:
+
+  Query      streams the entries matching a filter, newest first by default
+  Tail       streams the new entries matching a filter until cancelled
+  Aggregate  returns the statistics of the entries matching a filter
+
+grapple authenticates to Google Cloud with its own credentials and adds the
+filter flags it was started with (the alwaysFilter, --severity, --label,
+--exclude-preset, --from, --freshness, ...) to the filter of every request,
+which can only narrow them down. Query and Aggregate read at most
+--max-entries entries per call.
+
+The calls share the quota of the project, so their fetches run one at a time.
+Serve with --tls-cert and --tls-key outside of trusted networks: the service
+does not authenticate its clients itself.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		tailInterval, err := cmd.Flags().GetDuration("tail-interval")
+		cobra.CheckErr(err)
+		if tailInterval <= 0 {
+			cobra.CheckErr(fmt.Errorf("invalid --tail-interval %s", tailInterval))
+		}
+
+		var opts []grpc.ServerOption
+		certFile, keyFile := cmd.Flag("tls-cert").Value.String(), cmd.Flag("tls-key").Value.String()
+		if (certFile == "") != (keyFile == "") {
+			cobra.CheckErr(errors.New("--tls-cert and --tls-key must be given together"))
+		}
+		if certFile != "" {
+			creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+			cobra.CheckErr(err)
+			opts = append(opts, grpc.Creds(creds))
+		}
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+		s, err := newGrappleServer(ctx, cmd, client)
+		cobra.CheckErr(err)
+		s.tailInterval = tailInterval
+
+		listener, err := net.Listen("tcp", cmd.Flag("addr").Value.String())
+		cobra.CheckErr(err)
+		server := grpc.NewServer(opts...)
+		server.RegisterService(&grappleServiceDesc, s)
+		go func() {
+			<-ctx.Done()
+			log.Printf("Stopping the gRPC server")
+			server.GracefulStop()
+		}()
+		log.Printf("Serving grapple.v1.Grapple for project %s on %s", projectId, listener.Addr())
+		sdNotify("READY=1")
+		cobra.CheckErr(server.Serve(listener))
+	},
+}
+
+// newGrappleServer returns the server fetching through client, curated by the filter flags and
+// --max-entries of cmd
+func newGrappleServer(ctx context.Context, cmd *cobra.Command, client *logadmin.Client) (*grappleServer, error) {
+	maxEntries, err := cmd.Flags().GetInt("max-entries")
+	if err != nil {
+		return nil, err
+	}
+	if maxEntries <= 0 {
+		return nil, fmt.Errorf("invalid --max-entries %d", maxEntries)
+	}
+	// The curated filter is checked once, requests are only checked for their own part.
+	curated, err := composeFilter(cmd, "")
+	if err != nil {
+		return nil, err
+	}
+	if err := syntaxErrorContext(curated, lqlError(curated)); err != nil {
+		return nil, err
+	}
+	if _, _, err := determineTimeWindow(cmd); err != nil {
+		return nil, err
+	}
+	views, err := determineViews(ctx, cmd, client)
+	if err != nil {
+		return nil, err
+	}
+	baseOpts := []logadmin.EntriesOption{logadmin.PageSize(int32(pageSize))}
+	if len(views) > 0 {
+		baseOpts = append(baseOpts, logadmin.ResourceNames(views))
+	}
+
+	return &grappleServer{
+		ctx:        ctx,
+		maxEntries: maxEntries,
+		compose: func(filter string) (string, error) {
+			filter, err := composeFilter(cmd, filter)
+			if err != nil {
+				return "", err
+			}
+			from, to, err := determineTimeWindow(cmd)
+			if err != nil {
+				return "", err
+			}
+			return buildFilter(from, to, filter), nil
+		},
+		fetch: func(ctx context.Context, filter string, newestFirst bool, process func(*loggingpb.LogEntry) error) (int, error) {
+			opts := append(slices.Clone(baseOpts), logadmin.Filter(filter))
+			if newestFirst {
+				opts = append(opts, logadmin.NewestFirst())
+			}
+			return fetchAndProcessLogs(ctx, client, opts, process)
+		},
+	}, nil
+}
+
+// lqlError returns the syntax error of a filter, if any
+func lqlError(filter string) error {
+	_, err := lql.Parse(filter)
+	return err
+}
+
+// grappleServer implements the grapple.v1.Grapple service on top of the fetch pipeline
+type grappleServer struct {
+	// ctx ends the Tail calls when the server stops.
+	ctx          context.Context
+	maxEntries   int
+	tailInterval time.Duration
+	// compose adds the curated filter and time window of the server to the filter of a request.
+	compose func(filter string) (string, error)
+	fetch   func(ctx context.Context, filter string, newestFirst bool, process func(*loggingpb.LogEntry) error) (int, error)
+
+	// fetchMu runs one fetch at a time, as the retries and counters of fetchAndProcessLogs are shared.
+	fetchMu sync.Mutex
+}
+
+// request checks a request, returning the filter to fetch, whether newest first, and the maximum number of entries
+func (s *grappleServer) request(req *loggingpb.ListLogEntriesRequest) (filter string, newestFirst bool, limit int, err error) {
+	if len(req.ResourceNames) > 0 {
+		return "", false, 0, status.Error(codes.InvalidArgument, "resource_names is set by the server")
+	}
+	if req.PageToken != "" {
+		return "", false, 0, status.Error(codes.InvalidArgument, "page_token is not supported, the entries are streamed")
+	}
+	switch req.OrderBy {
+	case "", fieldTimestamp + " desc":
+		newestFirst = true
+	case fieldTimestamp + " asc":
+	default:
+		return "", false, 0, status.Errorf(codes.InvalidArgument, "invalid order_by %q, valid values: timestamp asc, timestamp desc", req.OrderBy)
+	}
+	if err := lqlError(req.Filter); err != nil {
+		return "", false, 0, status.Error(codes.InvalidArgument, syntaxErrorContext(req.Filter, err).Error())
+	}
+	filter, err = s.compose(req.Filter)
+	if err != nil {
+		return "", false, 0, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	limit = s.maxEntries
+	if req.PageSize > 0 {
+		limit = min(limit, int(req.PageSize))
+	}
+	return filter, newestFirst, limit, nil
+}
+
+// fetchLimited hands at most limit entries, all of them when 0, to process, returning the error that stopped it, if any
+func (s *grappleServer) fetchLimited(ctx context.Context, filter string, newestFirst bool, limit int, process func(*loggingpb.LogEntry) error) error {
+	s.fetchMu.Lock()
+	defer s.fetchMu.Unlock()
+
+	var processErr error
+	remaining := limit
+	_, err := s.fetch(ctx, filter, newestFirst, func(entry *loggingpb.LogEntry) error {
+		if processErr = process(entry); processErr != nil {
+			return grapple.ErrStop
+		}
+		if remaining--; remaining == 0 {
+			return grapple.ErrStop
+		}
+		return nil
+	})
+	if processErr != nil {
+		return processErr
+	}
+	if err != nil {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return ctx.Err()
+}
+
+func (s *grappleServer) query(req *loggingpb.ListLogEntriesRequest, stream grpc.ServerStream) error {
+	filter, newestFirst, limit, err := s.request(req)
+	if err != nil {
+		return err
+	}
+	return s.fetchLimited(stream.Context(), filter, newestFirst, limit, func(entry *loggingpb.LogEntry) error {
+		return stream.SendMsg(entry)
+	})
+}
+
+func (s *grappleServer) aggregate(ctx context.Context, req *loggingpb.ListLogEntriesRequest) (*structpb.Struct, error) {
+	filter, newestFirst, limit, err := s.request(req)
+	if err != nil {
+		return nil, err
+	}
+	stats := newEntryStats()
+	if err := s.fetchLimited(ctx, filter, newestFirst, limit, stats.observe); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(stats.report())
+	if err != nil {
+		return nil, err
+	}
+	report := &structpb.Struct{}
+	return report, report.UnmarshalJSON(data)
+}
+
+// tail polls for the entries newer than the last one sent, like --watch, until the call or the server ends
+func (s *grappleServer) tail(req *loggingpb.ListLogEntriesRequest, stream grpc.ServerStream) error {
+	filter, _, _, err := s.request(&loggingpb.ListLogEntriesRequest{Filter: req.Filter})
+	if err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	mark := newWatermark(time.Now(), 0)
+	strategy := grapple.WatermarkWindow{}
+	ticker := time.NewTicker(s.tailInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.ctx.Done():
+			return status.Error(codes.Unavailable, "the server is stopping")
+		case <-ticker.C:
+		}
+		started := time.Now()
+		poll := andFilters(filter, windowClause(mark.from(strategy)))
+		err := s.fetchLimited(ctx, poll, false, 0, func(entry *loggingpb.LogEntry) error {
+			if mark.covers(entry) {
+				return nil
+			}
+			mark.record(entry)
+			return stream.SendMsg(entry)
+		})
+		if err != nil {
+			return err
+		}
+		mark.polled = started
+	}
+}
+
+// grappleServiceDesc describes the grapple.v1.Grapple service of proto/grapple/v1/grapple.proto,
+// written by hand as its messages are the ones of the Logging API
+var grappleServiceDesc = grpc.ServiceDesc{
+	ServiceName: "grapple.v1.Grapple",
+	HandlerType: (*any)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Aggregate",
+			Handler: func(srv any, ctx context.Context, dec func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+				req := &loggingpb.ListLogEntriesRequest{}
+				if err := dec(req); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req any) (any, error) {
+					return srv.(*grappleServer).aggregate(ctx, req.(*loggingpb.ListLogEntriesRequest))
+				}
+				if interceptor == nil {
+					return handler(ctx, req)
+				}
+				return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: "/grapple.v1.Grapple/Aggregate"}, handler)
+			},
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "Query",
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				req := &loggingpb.ListLogEntriesRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*grappleServer).query(req, stream)
+			},
+			ServerStreams: true,
+		},
+		{
+			StreamName: "Tail",
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				req := &loggingpb.ListLogEntriesRequest{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				return srv.(*grappleServer).tail(req, stream)
+			},
+			ServerStreams: true,
+		},
+	},
+	Metadata: "proto/grapple/v1/grapple.proto",
+}
+
+func init() {
+	addFilterFlags(serveGRPCCmd)
+	serveGRPCCmd.Flags().String("addr", "localhost:50051", "address to listen on")
+	serveGRPCCmd.Flags().Int("max-entries", 10_000, "maximum number of entries read by a Query or Aggregate call")
+	serveGRPCCmd.Flags().Duration("tail-interval", 5*time.Second, "how often Tail calls poll for new entries")
+	serveGRPCCmd.Flags().String("tls-cert", "", "serve over TLS with this certificate file")
+	serveGRPCCmd.Flags().String("tls-key", "", "private key file of --tls-cert")
+
+	serveGRPCCmd.MarkFlagFilename("tls-cert")
+	serveGRPCCmd.MarkFlagFilename("tls-key")
+
+	rootCmd.AddCommand(serveGRPCCmd)
+}
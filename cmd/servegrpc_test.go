@@ -0,0 +1,134 @@
+package cmd
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// startTestGRPCServer serves s in memory, returning a connection to it
+func startTestGRPCServer(t *testing.T, s *grappleServer) *grpc.ClientConn {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	server.RegisterService(&grappleServiceDesc, s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// streamEntries calls a streaming method, returning the entries received and the final error
+func streamEntries(ctx context.Context, conn *grpc.ClientConn, method string, req *loggingpb.ListLogEntriesRequest) ([]string, error) {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, "/grapple.v1.Grapple/"+method)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.SendMsg(req); err != nil {
+		return nil, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+	var ids []string
+	for {
+		entry := &loggingpb.LogEntry{}
+		if err := stream.RecvMsg(entry); err == io.EOF {
+			return ids, nil
+		} else if err != nil {
+			return ids, err
+		}
+		ids = append(ids, entry.InsertId)
+	}
+}
+
+func TestGrappleServer(t *testing.T) {
+	// In the future, so that Tail sends them.
+	now := time.Now().Add(time.Minute)
+	entries := []*loggingpb.LogEntry{
+		{InsertId: "a", Timestamp: timestamppb.New(now), Severity: logtypepb.LogSeverity_ERROR},
+		{InsertId: "b", Timestamp: timestamppb.New(now), Severity: logtypepb.LogSeverity_INFO},
+		{InsertId: "c", Timestamp: timestamppb.New(now), Severity: logtypepb.LogSeverity_ERROR},
+	}
+	var fetched []string
+	s := &grappleServer{
+		ctx:          context.Background(),
+		maxEntries:   2,
+		tailInterval: time.Millisecond,
+		compose: func(filter string) (string, error) {
+			return andFilters(filter, `logName:"curated"`), nil
+		},
+		fetch: func(ctx context.Context, filter string, newestFirst bool, process func(*loggingpb.LogEntry) error) (int, error) {
+			fetched = append(fetched, filter)
+			for i, entry := range entries {
+				if err := process(entry); err != nil {
+					return i + 1, nil
+				}
+			}
+			return len(entries), nil
+		},
+	}
+	conn := startTestGRPCServer(t, s)
+	ctx := context.Background()
+
+	ids, err := streamEntries(ctx, conn, "Query", &loggingpb.ListLogEntriesRequest{Filter: "severity>=ERROR"})
+	if err != nil {
+		t.Fatalf("Query error = %v", err)
+	}
+	if strings.Join(ids, ",") != "a,b" {
+		t.Errorf("Query = %v, want the first --max-entries entries", ids)
+	}
+	if fetched[0] != `(severity>=ERROR) AND (logName:"curated")` {
+		t.Errorf("Query fetched %q, want the curated filter added", fetched[0])
+	}
+
+	ids, err = streamEntries(ctx, conn, "Query", &loggingpb.ListLogEntriesRequest{PageSize: 1})
+	if err != nil || len(ids) != 1 {
+		t.Errorf("Query with page_size 1 = %v, %v", ids, err)
+	}
+
+	for _, req := range []*loggingpb.ListLogEntriesRequest{
+		{Filter: "severity>="},
+		{ResourceNames: []string{"projects/other"}},
+		{OrderBy: "severity"},
+	} {
+		if _, err := streamEntries(ctx, conn, "Query", req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Query(%v) error = %v, want InvalidArgument", req, err)
+		}
+	}
+
+	report := &structpb.Struct{}
+	if err := conn.Invoke(ctx, "/grapple.v1.Grapple/Aggregate", &loggingpb.ListLogEntriesRequest{}, report); err != nil {
+		t.Fatalf("Aggregate error = %v", err)
+	}
+	if total := report.Fields["total"].GetNumberValue(); total != 2 {
+		t.Errorf("Aggregate total = %v, want 2", total)
+	}
+
+	tailCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	ids, _ = streamEntries(tailCtx, conn, "Tail", &loggingpb.ListLogEntriesRequest{})
+	// The entries are fetched by every poll, but sent once.
+	if strings.Join(ids, ",") != "a,b,c" {
+		t.Errorf("Tail = %v, want each entry once", ids)
+	}
+}
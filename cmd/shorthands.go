@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// resourceShorthand is a group of flags selecting entries of a monitored resource type by its labels
+type resourceShorthand struct {
+	resourceType string
+	labels       []shorthandLabel
+	// logFlag optionally selects one of the logs written for the resource type, by name
+	logFlag string
+	logIDs  map[string]string
+}
+
+// shorthandLabel maps a flag to the resource label it filters on
+type shorthandLabel struct {
+	flag, label, usage string
+}
+
+var resourceShorthands = []resourceShorthand{
+	{
+		resourceType: "k8s_container",
+		labels: []shorthandLabel{
+			{"gke-cluster", "cluster_name", "only fetch entries of this GKE cluster"},
+			{"namespace", "namespace_name", "only fetch entries of this Kubernetes namespace"},
+			{"pod", "pod_name", "only fetch entries of this Kubernetes pod"},
+			{"container", "container_name", "only fetch entries of this Kubernetes container"},
+		},
+	},
+	{
+		resourceType: "cloud_run_revision",
+		labels: []shorthandLabel{
+			{"cloud-run-service", "service_name", "only fetch entries of this Cloud Run service"},
+			{"revision", "revision_name", "only fetch entries of this Cloud Run revision"},
+		},
+		logFlag: "cloud-run-log",
+		logIDs: map[string]string{
+			"requests": "run.googleapis.com/requests",
+			"stdout":   "run.googleapis.com/stdout",
+			"stderr":   "run.googleapis.com/stderr",
+		},
+	},
+	{
+		resourceType: "cloud_function",
+		labels: []shorthandLabel{
+			{"function", "function_name", "only fetch entries of this Cloud Function"},
+		},
+	},
+}
+
+func addShorthandFlags(c *cobra.Command) {
+	for _, shorthand := range resourceShorthands {
+		for _, l := range shorthand.labels {
+			c.Flags().String(l.flag, "", l.usage)
+		}
+		if shorthand.logFlag != "" {
+			c.Flags().String(shorthand.logFlag, "", fmt.Sprintf("only fetch this log of %s resources, valid values: %s", shorthand.resourceType, strings.Join(shorthand.logNames(), ", ")))
+		}
+	}
+}
+
+func (s resourceShorthand) logNames() []string {
+	var names []string
+	for name := range s.logIDs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// shorthandClauses expands the shorthand flags into resource type and label clauses
+func shorthandClauses(cmd *cobra.Command) ([]string, error) {
+	var clauses []string
+	var resourceType string
+
+	for _, shorthand := range resourceShorthands {
+		var labelClauses []string
+		for _, l := range shorthand.labels {
+			if value := cmd.Flag(l.flag).Value.String(); value != "" {
+				labelClauses = append(labelClauses, fmt.Sprintf("resource.labels.%s=%q", l.label, value))
+			}
+		}
+		if shorthand.logFlag != "" {
+			if name := cmd.Flag(shorthand.logFlag).Value.String(); name != "" {
+				logID, ok := shorthand.logIDs[name]
+				if !ok {
+					return nil, fmt.Errorf("invalid --%s %q, valid values: %s", shorthand.logFlag, name, strings.Join(shorthand.logNames(), ", "))
+				}
+				labelClauses = append(labelClauses, fmt.Sprintf("log_id(%q)", logID))
+			}
+		}
+		if len(labelClauses) == 0 {
+			continue
+		}
+		if resourceType != "" {
+			return nil, fmt.Errorf("the shorthand flags for %s and %s cannot be combined", resourceType, shorthand.resourceType)
+		}
+		resourceType = shorthand.resourceType
+		clauses = append(clauses, fmt.Sprintf("resource.type=%q", shorthand.resourceType))
+		clauses = append(clauses, labelClauses...)
+	}
+
+	return clauses, nil
+}
@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestShorthandClauses(t *testing.T) {
+	c := &cobra.Command{}
+	addShorthandFlags(c)
+
+	if clauses, err := shorthandClauses(c); err != nil || len(clauses) != 0 {
+		t.Errorf("shorthandClauses() without flags = %v, %v, want none", clauses, err)
+	}
+
+	c.Flags().Set("namespace", "payments")
+	c.Flags().Set("container", "api")
+
+	clauses, err := shorthandClauses(c)
+	if err != nil {
+		t.Fatalf("shorthandClauses() unexpected error: %v", err)
+	}
+	expected := []string{
+		`resource.type="k8s_container"`,
+		`resource.labels.namespace_name="payments"`,
+		`resource.labels.container_name="api"`,
+	}
+	if !reflect.DeepEqual(clauses, expected) {
+		t.Errorf("shorthandClauses() = %v, want %v", clauses, expected)
+	}
+}
+
+func TestShorthandClausesCloudRun(t *testing.T) {
+	c := &cobra.Command{}
+	addShorthandFlags(c)
+
+	c.Flags().Set("cloud-run-service", "checkout")
+	c.Flags().Set("cloud-run-log", "requests")
+
+	clauses, err := shorthandClauses(c)
+	if err != nil {
+		t.Fatalf("shorthandClauses() unexpected error: %v", err)
+	}
+	expected := []string{
+		`resource.type="cloud_run_revision"`,
+		`resource.labels.service_name="checkout"`,
+		`log_id("run.googleapis.com/requests")`,
+	}
+	if !reflect.DeepEqual(clauses, expected) {
+		t.Errorf("shorthandClauses() = %v, want %v", clauses, expected)
+	}
+
+	c.Flags().Set("cloud-run-log", "access")
+	if _, err := shorthandClauses(c); err == nil {
+		t.Error("shorthandClauses() with an invalid log succeeded")
+	}
+
+	c.Flags().Set("cloud-run-log", "stdout")
+	c.Flags().Set("pod", "web-0")
+	if _, err := shorthandClauses(c); err == nil {
+		t.Error("shorthandClauses() mixing resource types succeeded")
+	}
+}
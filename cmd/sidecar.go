@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// sidecarConfigDirEnv names the directory of a mounted config map, holding one file per setting
+const sidecarConfigDirEnv = "GRAPPLE_CONFIG_DIR"
+
+var sidecarCmd = &cobra.Command{
+	Use:   "sidecar",
+	Short: "Re-emit Cloud Logging entries as the logs of a Kubernetes pod",
+	Long: `Fetch log entries configured only through the environment and print them
+to stdout as structured JSON lines, in the format picked up by the logging
+agents of Kubernetes clusters.
+
+Every query flag is read from an environment variable named after it, e.g.
+GRAPPLE_FRESHNESS for --freshness or GRAPPLE_RESOURCE_LABEL for
+--resource-label, while the filter is read from GRAPPLE_FILTER.
+The settings can also be mounted from a config map as a directory of files
+named after the flags (freshness, filter, ...) given by GRAPPLE_CONFIG_DIR.
+Environment variables take precedence over the files.
+
+The sidecar keeps polling for new entries (see --watch), GRAPPLE_WATCH=0
+makes it exit after the first fetch.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		settings, err := sidecarSettings(cmd, os.Getenv(sidecarConfigDirEnv), os.LookupEnv)
+		checkErr(err)
+
+		checkErr(applySidecarSettings(cmd, settings))
+		if !cmd.Flags().Changed("format") {
+			cmd.Flags().Set("format", formatK8s)
+		}
+		if !cmd.Flags().Changed("watch") {
+			cmd.Flags().Set("watch", defaultWatchInterval)
+		}
+
+		runQuery(cmd, settings["filter"])
+	},
+}
+
+// sidecarEnvName returns the environment variable configuring a setting (e.g. resource-label)
+func sidecarEnvName(setting string) string {
+	return "GRAPPLE_" + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+// sidecarSettings collects the sidecar settings from the config map directory, if any, and the environment
+func sidecarSettings(cmd *cobra.Command, dir string, lookupEnv func(string) (string, bool)) (map[string]string, error) {
+	settings := map[string]string{}
+
+	if dir != "" {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", sidecarConfigDirEnv, err)
+		}
+		for _, file := range files {
+			// Config map volumes keep their data in hidden directories linked from the top level.
+			if strings.HasPrefix(file.Name(), ".") {
+				continue
+			}
+			data, err := os.ReadFile(filepath.Join(dir, file.Name()))
+			if err != nil {
+				return nil, err
+			}
+			settings[file.Name()] = strings.TrimSpace(string(data))
+		}
+	}
+
+	// The other persistent flags are only read before the command runs.
+	names := []string{"filter", "project"}
+	cmd.LocalFlags().VisitAll(func(f *pflag.Flag) {
+		// GRAPPLE_STATS already enables the usage statistics, see the stats config key.
+		if f.Name != "stats" {
+			names = append(names, f.Name)
+		}
+	})
+	for _, name := range names {
+		if value, ok := lookupEnv(sidecarEnvName(name)); ok {
+			settings[name] = value
+		}
+	}
+
+	return settings, nil
+}
+
+// applySidecarSettings sets the flags of cmd from the settings, rejecting unknown ones
+func applySidecarSettings(cmd *cobra.Command, settings map[string]string) error {
+	for name, value := range settings {
+		if name == "filter" {
+			continue
+		}
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil {
+			return fmt.Errorf("unknown sidecar setting %q", name)
+		}
+		if flag.Value.Type() == "stringArray" || flag.Value.Type() == "stringSlice" {
+			// Repeatable flags take one value per line.
+			for _, item := range strings.Split(value, "\n") {
+				if item = strings.TrimSpace(item); item != "" {
+					if err := cmd.Flags().Set(name, item); err != nil {
+						return fmt.Errorf("invalid %s: %w", sidecarEnvName(name), err)
+					}
+				}
+			}
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("invalid %s: %w", sidecarEnvName(name), err)
+		}
+	}
+	return nil
+}
+
+func init() {
+	addQueryFlags(sidecarCmd)
+	sidecarCmd.Flags().VisitAll(func(f *pflag.Flag) { f.Hidden = true })
+
+	rootCmd.AddCommand(sidecarCmd)
+}
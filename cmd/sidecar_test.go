@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestSidecarSettings(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "freshness"), []byte("1h\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "filter"), []byte("severity>=ERROR"), 0o644)
+	os.Mkdir(filepath.Join(dir, "..data"), 0o755)
+
+	c := &cobra.Command{}
+	addQueryFlags(c)
+
+	env := map[string]string{
+		"GRAPPLE_FRESHNESS":      "10m",
+		"GRAPPLE_RESOURCE_LABEL": "zone=europe-west1-b\nenv=prod",
+	}
+	lookupEnv := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	settings, err := sidecarSettings(c, dir, lookupEnv)
+	if err != nil {
+		t.Fatalf("sidecarSettings() unexpected error: %v", err)
+	}
+	expected := map[string]string{
+		"freshness":      "10m",
+		"filter":         "severity>=ERROR",
+		"resource-label": "zone=europe-west1-b\nenv=prod",
+	}
+	if !reflect.DeepEqual(settings, expected) {
+		t.Fatalf("sidecarSettings() = %v, want %v", settings, expected)
+	}
+
+	if err := applySidecarSettings(c, settings); err != nil {
+		t.Fatalf("applySidecarSettings() unexpected error: %v", err)
+	}
+	if got := c.Flag("freshness").Value.String(); got != "10m" {
+		t.Errorf("freshness = %q, want %q", got, "10m")
+	}
+	labels, _ := c.Flags().GetStringArray("resource-label")
+	if !reflect.DeepEqual(labels, []string{"zone=europe-west1-b", "env=prod"}) {
+		t.Errorf("resource-label = %v", labels)
+	}
+
+	if err := applySidecarSettings(c, map[string]string{"freshnes": "1h"}); err == nil {
+		t.Error("applySidecarSettings() with an unknown setting succeeded")
+	}
+}
+
+func TestK8sRecord(t *testing.T) {
+	payload, _ := structpb.NewStruct(map[string]any{"msg": "boom", "code": 3})
+	entry := &loggingpb.LogEntry{
+		LogName:  "projects/p/logs/app",
+		Severity: logtypepb.LogSeverity_ERROR,
+		Trace:    "projects/p/traces/abc",
+		Payload:  &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+	}
+
+	record, err := k8sRecord(entry)
+	if err != nil {
+		t.Fatalf("k8sRecord() unexpected error: %v", err)
+	}
+	for key, want := range map[string]any{
+		"message":                      "boom",
+		"severity":                     "ERROR",
+		"msg":                          "boom",
+		"logging.googleapis.com/trace": "projects/p/traces/abc",
+	} {
+		if record[key] != want {
+			t.Errorf("record[%q] = %v, want %v", key, record[key], want)
+		}
+	}
+	if _, ok := record["logging.googleapis.com/insertId"]; ok {
+		t.Error("record has an empty insertId")
+	}
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	bigquery "google.golang.org/api/bigquery/v2"
+)
+
+// formatTable is the output format of the sql command printing an aligned table, besides CSV and JSON lines
+const formatTable = "table"
+
+// sqlPageSize is the number of rows requested per page of results
+const sqlPageSize = 1000
+
+var sqlCmd = &cobra.Command{
+	Use:   "sql QUERY",
+	Short: "Run a SQL query on Log Analytics",
+	Long: `Run a GoogleSQL query on the logs of buckets upgraded to Log Analytics,
+through the BigQuery datasets linked to them, and print the result as a table,
+CSV or JSON lines (default table on a terminal, json otherwise).
+
+The views of a bucket are the tables of its linked dataset, e.g. _AllLogs,
+which can be named without the dataset when --dataset is set:
+
+  grapple sql --dataset my_logs 'SELECT severity, COUNT(*) AS n FROM _AllLogs
+    WHERE timestamp > TIMESTAMP_SUB(CURRENT_TIMESTAMP(), INTERVAL 1 HOUR)
+    GROUP BY severity ORDER BY n DESC'
+
+The query runs, and is billed, in the project. Link a dataset to a bucket
+with gcloud logging links create.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+
+		format := cmd.Flag("format").Value.String()
+		if format == "" {
+			format = formatJSON
+			if writesToTerminal(cmd) {
+				format = formatTable
+			}
+		}
+		if format != formatTable && format != formatCSV && format != formatJSON {
+			cobra.CheckErr(fmt.Errorf("invalid --format %q, valid values: %s, %s, %s", format, formatTable, formatCSV, formatJSON))
+		}
+		maxRows, err := cmd.Flags().GetInt("max-rows")
+		cobra.CheckErr(err)
+		if maxRows < 0 {
+			cobra.CheckErr(fmt.Errorf("invalid --max-rows %d", maxRows))
+		}
+
+		req := &bigquery.QueryRequest{
+			Query:         args[0],
+			UseLegacySql:  new(bool),
+			Location:      cmd.Flag("location").Value.String(),
+			MaxResults:    sqlPageSize,
+			FormatOptions: &bigquery.DataFormatOptions{UseInt64Timestamp: true},
+		}
+		if dataset := cmd.Flag("dataset").Value.String(); dataset != "" {
+			req.DefaultDataset = datasetReference(projectId, dataset)
+		}
+
+		ctx := cmd.Context()
+		opts, err := clientOptions()
+		cobra.CheckErr(err)
+		service, err := bigquery.NewService(ctx, opts...)
+		cobra.CheckErr(err)
+
+		result, err := runSQL(ctx, service.Jobs, projectId, req, maxRows)
+		cobra.CheckErr(err)
+		if result.truncated {
+			log.Printf("Warning: printing the first %d of %d rows, see --max-rows", len(result.rows), result.totalRows)
+		}
+
+		switch format {
+		case formatCSV:
+			err = writeSQLCSV(stdout, result)
+		case formatJSON:
+			err = writeSQLJSON(stdout, result)
+		default:
+			err = writeSQLTable(stdout, result)
+		}
+		cobra.CheckErr(err)
+	},
+}
+
+// datasetReference parses a dataset given as DATASET, PROJECT.DATASET or PROJECT:DATASET, PROJECT
+// possibly being an alias
+func datasetReference(projectId, dataset string) *bigquery.DatasetReference {
+	if i := strings.LastIndexAny(dataset, ".:"); i >= 0 {
+		return &bigquery.DatasetReference{ProjectId: expandProject(dataset[:i], projectAliases()), DatasetId: dataset[i+1:]}
+	}
+	return &bigquery.DatasetReference{ProjectId: projectId, DatasetId: dataset}
+}
+
+// sqlResult is the result of a query: the columns, the rows with their cells decoded by
+// sqlValue, and whether rows beyond --max-rows were left out
+type sqlResult struct {
+	columns   []*bigquery.TableFieldSchema
+	rows      [][]any
+	totalRows uint64
+	truncated bool
+}
+
+// runSQL runs the query and pages through its results, waiting for it to complete, up to maxRows rows (0 for all)
+func runSQL(ctx context.Context, jobs *bigquery.JobsService, projectId string, req *bigquery.QueryRequest, maxRows int) (*sqlResult, error) {
+	resp, err := jobs.Query(projectId, req).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	page := &bigquery.GetQueryResultsResponse{
+		JobComplete:  resp.JobComplete,
+		JobReference: resp.JobReference,
+		Schema:       resp.Schema,
+		Rows:         resp.Rows,
+		PageToken:    resp.PageToken,
+		TotalRows:    resp.TotalRows,
+	}
+
+	result := &sqlResult{}
+	for {
+		if page.JobComplete {
+			if page.Schema != nil {
+				result.columns = page.Schema.Fields
+			}
+			result.totalRows = page.TotalRows
+			for _, row := range page.Rows {
+				if maxRows > 0 && len(result.rows) == maxRows {
+					result.truncated = true
+					return result, nil
+				}
+				result.rows = append(result.rows, sqlRow(result.columns, row))
+			}
+			if page.PageToken == "" {
+				return result, nil
+			}
+		}
+		// DDL and DML statements have no job nor rows to page through.
+		if page.JobReference == nil {
+			return result, nil
+		}
+		call := jobs.GetQueryResults(projectId, page.JobReference.JobId).
+			Location(page.JobReference.Location).
+			MaxResults(sqlPageSize).
+			FormatOptionsUseInt64Timestamp(true).
+			Context(ctx)
+		if page.JobComplete {
+			call = call.PageToken(page.PageToken)
+		}
+		reference := page.JobReference
+		if page, err = call.Do(); err != nil {
+			return nil, err
+		}
+		if page.JobReference == nil {
+			page.JobReference = reference
+		}
+	}
+}
+
+// sqlRow decodes the cells of a row
+func sqlRow(columns []*bigquery.TableFieldSchema, row *bigquery.TableRow) []any {
+	values := make([]any, len(columns))
+	for i, cell := range row.F {
+		if i < len(columns) {
+			values[i] = sqlValue(columns[i], cell.V)
+		}
+	}
+	return values
+}
+
+// sqlValue decodes a cell of the REST API, where scalars are strings, into a JSON value:
+// numbers for numeric types, RFC 3339 strings for timestamps, objects for records, arrays for repeated fields
+func sqlValue(field *bigquery.TableFieldSchema, v any) any {
+	if v == nil {
+		return nil
+	}
+	if field.Mode == "REPEATED" {
+		items, _ := v.([]any)
+		values := make([]any, len(items))
+		element := *field
+		element.Mode = ""
+		for i, item := range items {
+			if cell, ok := item.(map[string]any); ok {
+				values[i] = sqlValue(&element, cell["v"])
+			}
+		}
+		return values
+	}
+	switch field.Type {
+	case "RECORD", "STRUCT":
+		record := map[string]any{}
+		object, _ := v.(map[string]any)
+		cells, _ := object["f"].([]any)
+		for i, item := range cells {
+			if cell, ok := item.(map[string]any); ok && i < len(field.Fields) {
+				record[field.Fields[i].Name] = sqlValue(field.Fields[i], cell["v"])
+			}
+		}
+		return record
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return v
+	}
+	switch field.Type {
+	case "INTEGER", "INT64", "NUMERIC", "BIGNUMERIC":
+		return json.Number(s)
+	case "FLOAT", "FLOAT64":
+		// NaN and infinities have no JSON number.
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return json.Number(s)
+		}
+	case "BOOLEAN", "BOOL":
+		return s == "true"
+	case "TIMESTAMP":
+		if micros, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return time.UnixMicro(micros).UTC().Format(time.RFC3339Nano)
+		}
+	case "JSON":
+		if json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+	}
+	return s
+}
+
+// sqlCell formats a decoded value for the table and CSV formats, with records and arrays as JSON
+func sqlCell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	s, err := marshalJSONValue(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return s
+}
+
+func writeSQLTable(w io.Writer, result *sqlResult) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	names := make([]string, len(result.columns))
+	for i, column := range result.columns {
+		names[i] = strings.ToUpper(column.Name)
+	}
+	fmt.Fprintln(tw, strings.Join(names, "\t"))
+	for _, row := range result.rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			// Tabs and newlines would break the alignment.
+			cells[i] = strings.NewReplacer("\t", " ", "\n", " ").Replace(sqlCell(v))
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d rows\n", len(result.rows))
+	return err
+}
+
+func writeSQLCSV(w io.Writer, result *sqlResult) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(result.columns))
+	for i, column := range result.columns {
+		header[i] = column.Name
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range result.rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = sqlCell(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeSQLJSON prints each row as a JSON object line, with the keys in the order of the columns
+func writeSQLJSON(w io.Writer, result *sqlResult) error {
+	for _, row := range result.rows {
+		var line strings.Builder
+		line.WriteByte('{')
+		for i, v := range row {
+			if i > 0 {
+				line.WriteByte(',')
+			}
+			key, err := marshalJSONValue(result.columns[i].Name)
+			if err != nil {
+				return err
+			}
+			value, err := marshalJSONValue(v)
+			if err != nil {
+				return err
+			}
+			line.WriteString(key + ":" + value)
+		}
+		line.WriteString("}\n")
+		if _, err := io.WriteString(w, line.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	sqlCmd.Flags().String("dataset", "", "default dataset of the query, the BigQuery dataset linked to a Log Analytics bucket (DATASET or PROJECT.DATASET)")
+	sqlCmd.Flags().String("location", "", "location of the query, that of the linked dataset (default detected by BigQuery)")
+	sqlCmd.Flags().String("format", "", "output format, valid values: table, csv, json (default table on a terminal, json otherwise)")
+	sqlCmd.Flags().Int("max-rows", 1000, "print at most this many rows (0 for all)")
+
+	rootCmd.AddCommand(sqlCmd)
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	bigquery "google.golang.org/api/bigquery/v2"
+)
+
+func TestSQLValue(t *testing.T) {
+	cases := []struct {
+		field    *bigquery.TableFieldSchema
+		input    any
+		expected string
+	}{
+		{&bigquery.TableFieldSchema{Type: "STRING"}, "a,b", "a,b"},
+		{&bigquery.TableFieldSchema{Type: "INTEGER"}, "42", "42"},
+		{&bigquery.TableFieldSchema{Type: "FLOAT"}, "NaN", "NaN"},
+		{&bigquery.TableFieldSchema{Type: "BOOLEAN"}, "true", "true"},
+		{&bigquery.TableFieldSchema{Type: "TIMESTAMP"}, "1735689600123456", "2025-01-01T00:00:00.123456Z"},
+		{&bigquery.TableFieldSchema{Type: "STRING"}, nil, ""},
+		{&bigquery.TableFieldSchema{Type: "STRING", Mode: "REPEATED"}, []any{map[string]any{"v": "x"}, map[string]any{"v": "y"}}, `["x","y"]`},
+		{
+			&bigquery.TableFieldSchema{Type: "RECORD", Fields: []*bigquery.TableFieldSchema{{Name: "status", Type: "INTEGER"}}},
+			map[string]any{"f": []any{map[string]any{"v": "500"}}},
+			`{"status":500}`,
+		},
+	}
+	for _, c := range cases {
+		if got := sqlCell(sqlValue(c.field, c.input)); got != c.expected {
+			t.Errorf("sqlValue(%s, %v) = %q, want %q", c.field.Type, c.input, got, c.expected)
+		}
+	}
+}
+
+func TestWriteSQL(t *testing.T) {
+	columns := []*bigquery.TableFieldSchema{{Name: "severity", Type: "STRING"}, {Name: "n", Type: "INTEGER"}}
+	result := &sqlResult{columns: columns}
+	for _, row := range [][2]string{{"ERROR", "12"}, {"INFO", "3"}} {
+		result.rows = append(result.rows, sqlRow(columns, &bigquery.TableRow{F: []*bigquery.TableCell{{V: row[0]}, {V: row[1]}}}))
+	}
+
+	cases := map[string]func(*bytes.Buffer) error{
+		"SEVERITY  N\nERROR     12\nINFO      3\n\n2 rows\n":                      func(b *bytes.Buffer) error { return writeSQLTable(b, result) },
+		"severity,n\nERROR,12\nINFO,3\n":                                          func(b *bytes.Buffer) error { return writeSQLCSV(b, result) },
+		`{"severity":"ERROR","n":12}` + "\n" + `{"severity":"INFO","n":3}` + "\n": func(b *bytes.Buffer) error { return writeSQLJSON(b, result) },
+	}
+	for expected, write := range cases {
+		var b bytes.Buffer
+		if err := write(&b); err != nil || b.String() != expected {
+			t.Errorf("got %q, %v, want %q", b.String(), err, expected)
+		}
+	}
+}
+
+func TestDatasetReference(t *testing.T) {
+	cases := map[string]*bigquery.DatasetReference{
+		"my_logs":            {ProjectId: "p", DatasetId: "my_logs"},
+		"other.my_logs":      {ProjectId: "other", DatasetId: "my_logs"},
+		"example.com:x:logs": {ProjectId: "example.com:x", DatasetId: "logs"},
+		"prod.my_logs":       {ProjectId: "my-prod-project-1234", DatasetId: "my_logs"},
+	}
+	viper.Set("aliases", map[string]string{"prod": "my-prod-project-1234"})
+	defer viper.Set("aliases", nil)
+	for input, expected := range cases {
+		if got := datasetReference("p", input); !reflect.DeepEqual(got, expected) {
+			t.Errorf("datasetReference(%q) = %+v, want %+v", input, got, expected)
+		}
+	}
+}
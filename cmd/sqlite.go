@@ -0,0 +1,349 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/input"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// sqliteBatchSize is the number of entries inserted per transaction by export sqlite
+const sqliteBatchSize = 5000
+
+// sqliteSchema creates the table of export sqlite and its indexes. The timestamps are stored as
+// RFC 3339 text, with nanoseconds, which sorts chronologically and works with the date functions.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS entries (
+  insertId TEXT,
+  timestamp TEXT,
+  receiveTimestamp TEXT,
+  severity TEXT,
+  severityNumber INTEGER,
+  logName TEXT,
+  resourceType TEXT,
+  resourceLabels TEXT,
+  labels TEXT,
+  trace TEXT,
+  spanId TEXT,
+  textPayload TEXT,
+  payload TEXT,
+  entry TEXT NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS entries_insertId ON entries (insertId, timestamp);
+CREATE INDEX IF NOT EXISTS entries_timestamp ON entries (timestamp);
+CREATE INDEX IF NOT EXISTS entries_severity ON entries (severityNumber, timestamp);
+CREATE INDEX IF NOT EXISTS entries_logName ON entries (logName, timestamp);
+CREATE INDEX IF NOT EXISTS entries_trace ON entries (trace);
+`
+
+var exportSQLiteCmd = &cobra.Command{
+	Use:   "sqlite --output FILE [filter]",
+	Short: "Export entries to an SQLite database",
+	Long: `Write the entries matching the filter to the entries table of an SQLite
+database, created if needed, to query them offline with grapple local query
+or any SQLite client. Exporting again to the same database adds the new
+entries: the ones with an insertId and timestamp already there are skipped.
+
+The columns are insertId, timestamp and receiveTimestamp (RFC 3339 text),
+severity, severityNumber (e.g. 500 for ERROR), logName, resourceType,
+trace, spanId and textPayload, resourceLabels, labels and payload (the
+JSON or proto payload) as JSON, and the whole entry as JSON in entry. The
+timestamp, severityNumber, logName and trace columns are indexed.
+
+The database is written by the sqlite3 command line shell, which must be
+installed (see --sqlite3).`,
+	Annotations: map[string]string{annotationWritesOutput: "true"},
+	Args:        cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newSQLiteSink)
+	},
+}
+
+var localQueryCmd = &cobra.Command{
+	Use:   "query DATABASE [expression]",
+	Short: "Print entries from a database written by export sqlite",
+	Long: `Print the entries of a database written by grapple export sqlite, with the
+same formats as the queries. The expression is an SQL condition over the
+columns of the entries table, e.g.
+
+  grapple local query logs.db "severityNumber >= 500 AND logName LIKE '%nginx%'"
+  grapple local query logs.db "json_extract(payload, '$.userId') = 'u-42'"
+
+The database is opened read-only by the sqlite3 command line shell, which
+must be installed (see --sqlite3).`,
+	Args: cobra.RangeArgs(1, 2),
+	Run: func(cmd *cobra.Command, args []string) {
+		expression := ""
+		if len(args) > 1 {
+			expression = args[1]
+		}
+		order := cmd.Flag("order").Value.String()
+		if order != "asc" && order != "desc" {
+			checkErr(fmt.Errorf("invalid --order %q, valid values: asc, desc", order))
+		}
+		limit, err := cmd.Flags().GetInt("limit")
+		checkErr(err)
+		_, err = os.Stat(args[0])
+		checkErr(err)
+		command, err := sqlite3Command(cmd)
+		checkErr(err)
+
+		process, flush, err := newEntrySink(cmd)
+		checkErr(err)
+		out, err := openOutput(cmd)
+		checkErr(err)
+
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		query := sqliteSelect(expression, order, limit)
+		shell := exec.CommandContext(ctx, command[0], append(command[1:], "-batch", "-bail", "-readonly", args[0], query)...)
+		var stderr bytes.Buffer
+		shell.Stderr = &stderr
+		stdoutPipe, err := shell.StdoutPipe()
+		checkErr(err)
+		checkErr(shell.Start())
+
+		count := 0
+		entries := input.NewEntryReader(args[0], stdoutPipe)
+		for {
+			var entry *loggingpb.LogEntry
+			if entry, err = entries.Next(); err != nil {
+				break
+			}
+			if err := process(entry); err != nil {
+				log.Printf("Error processing log entry (%s): %v", entry.InsertId, err)
+			}
+			count++
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		io.Copy(io.Discard, stdoutPipe)
+		if waitErr := shell.Wait(); ctx.Err() != nil {
+			log.Printf("Interrupted after %d entries", count)
+		} else if waitErr != nil {
+			err = errors.Join(err, sqliteError(waitErr, &stderr))
+		}
+		if err == nil {
+			err = flush()
+		}
+		checkErr(errors.Join(err, out.Close()))
+	},
+}
+
+// sqliteSelect returns the query of the entries matching the SQL expression for local query
+func sqliteSelect(expression, order string, limit int) string {
+	query := "SELECT entry FROM entries"
+	if strings.TrimSpace(expression) != "" {
+		query += " WHERE (" + expression + ")"
+	}
+	query += " ORDER BY timestamp " + order
+	if limit > 0 {
+		query += " LIMIT " + strconv.Itoa(limit)
+	}
+	return query + ";"
+}
+
+// sqlite3Command returns the --sqlite3 command
+func sqlite3Command(cmd *cobra.Command) ([]string, error) {
+	command := strings.Fields(cmd.Flag("sqlite3").Value.String())
+	if len(command) == 0 {
+		return nil, errors.New("--sqlite3 cannot be empty")
+	}
+	return command, nil
+}
+
+// sqliteError adds the messages of the sqlite3 shell to the error it exited with
+func sqliteError(err error, stderr *bytes.Buffer) error {
+	if message := strings.TrimPrefix(strings.TrimSpace(stderr.String()), "Error: "); message != "" {
+		return fmt.Errorf("sqlite3: %s", message)
+	}
+	return fmt.Errorf("sqlite3: %w", err)
+}
+
+// sqliteWriter inserts the entries into a database through the statements it writes to a sqlite3
+// shell, committing them every sqliteBatchSize entries
+type sqliteWriter struct {
+	path    string
+	shell   *exec.Cmd
+	pipe    io.WriteCloser
+	stdin   *bufio.Writer
+	stderr  bytes.Buffer
+	pending int
+	count   int
+	// err is the failure of the shell that stopped the writer.
+	err error
+}
+
+// newSQLiteSink returns the function exporting each entry to the --output database, and the
+// function committing the last ones
+func newSQLiteSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	path := cmd.Flag("output").Value.String()
+	if path == "" || path == "-" {
+		return nil, nil, errors.New("--output, the path of the database, is required")
+	}
+	command, err := sqlite3Command(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := startSQLiteWriter(path, command)
+	if err != nil {
+		return nil, nil, err
+	}
+	return w.process, w.close, nil
+}
+
+// startSQLiteWriter starts the shell writing the database at path, creating its schema
+func startSQLiteWriter(path string, command []string) (*sqliteWriter, error) {
+	w := &sqliteWriter{path: path, shell: exec.Command(command[0], append(command[1:], "-batch", "-bail", path)...)}
+	w.shell.Stdout = os.Stderr
+	w.shell.Stderr = &w.stderr
+	var err error
+	if w.pipe, err = w.shell.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if err := w.shell.Start(); err != nil {
+		return nil, fmt.Errorf("starting sqlite3: %w", err)
+	}
+	w.stdin = bufio.NewWriter(w.pipe)
+	w.write(sqliteSchema + "BEGIN;\n")
+	return w, nil
+}
+
+func (w *sqliteWriter) write(statements string) {
+	if w.err != nil {
+		return
+	}
+	if _, err := w.stdin.WriteString(statements); err != nil {
+		w.err = sqliteError(errors.Join(err, w.shell.Wait()), &w.stderr)
+	}
+}
+
+func (w *sqliteWriter) process(entry *loggingpb.LogEntry) error {
+	if w.err != nil {
+		return grapple.ErrStop
+	}
+	statement, err := sqliteInsert(entry)
+	if err != nil {
+		return err
+	}
+	w.write(statement)
+	if w.pending++; w.pending == sqliteBatchSize {
+		w.write("COMMIT;\nBEGIN;\n")
+		w.pending = 0
+	}
+	if w.err != nil {
+		return grapple.ErrStop
+	}
+	w.count++
+	return nil
+}
+
+// close commits the last entries and waits for the shell to exit
+func (w *sqliteWriter) close() error {
+	w.write("COMMIT;\n")
+	if w.err != nil {
+		return w.err
+	}
+	err := w.stdin.Flush()
+	err = errors.Join(err, w.pipe.Close())
+	if err := w.shell.Wait(); err != nil {
+		return sqliteError(err, &w.stderr)
+	}
+	if err != nil {
+		return err
+	}
+	log.Printf("Exported %d entries to %s", w.count, w.path)
+	return nil
+}
+
+// sqliteInsert returns the statement inserting an entry into the entries table
+func sqliteInsert(entry *loggingpb.LogEntry) (string, error) {
+	resourceLabels, err := labelsJSON(entry.Resource.GetLabels())
+	if err != nil {
+		return "", err
+	}
+	labels, err := labelsJSON(entry.Labels)
+	if err != nil {
+		return "", err
+	}
+	payload, err := payloadJSON(entry)
+	if err != nil {
+		return "", err
+	}
+	full, err := protojson.Marshal(entry)
+	if err != nil {
+		return "", err
+	}
+	values := []string{
+		sqliteValue(entry.InsertId),
+		sqliteValue(sqliteTime(entry.Timestamp)),
+		sqliteValue(sqliteTime(entry.ReceiveTimestamp)),
+		sqliteValue(entry.Severity.String()),
+		strconv.Itoa(int(entry.Severity)),
+		sqliteValue(entry.LogName),
+		sqliteValue(entry.Resource.GetType()),
+		sqliteValue(resourceLabels),
+		sqliteValue(labels),
+		sqliteValue(entry.Trace),
+		sqliteValue(entry.SpanId),
+		sqliteValue(entry.GetTextPayload()),
+		sqliteValue(payload),
+		sqliteValue(string(full)),
+	}
+	return "INSERT OR IGNORE INTO entries VALUES (" + strings.Join(values, ", ") + ");\n", nil
+}
+
+// sqliteTime formats a timestamp as RFC 3339 text with nanoseconds, empty when missing
+func sqliteTime(ts *timestamppb.Timestamp) string {
+	if ts == nil {
+		return ""
+	}
+	return ts.AsTime().UTC().Format("2006-01-02T15:04:05.000000000Z")
+}
+
+// sqliteValue returns the SQL literal of a string, NULL when empty or nil
+func sqliteValue(v any) string {
+	s, _ := v.(string)
+	if s == "" {
+		return "NULL"
+	}
+	// The shell reads statements as C strings.
+	s = strings.ReplaceAll(s, "\x00", "")
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+func init() {
+	addFetchFlags(exportSQLiteCmd)
+	exportSQLiteCmd.Flags().StringP("output", "o", "", "database file to write the entries to, created if needed")
+	exportSQLiteCmd.MarkFlagFilename("output", "db", "sqlite")
+	localQueryCmd.Flags().String("order", "desc", "ordering based on timestamp, valid values: asc, desc")
+	localQueryCmd.Flags().Int("limit", 0, "print at most this many entries (0 for no limit)")
+	addFormatFlags(localQueryCmd)
+	addOutputFlags(localQueryCmd)
+	for _, c := range []*cobra.Command{exportSQLiteCmd, localQueryCmd} {
+		c.Flags().String("sqlite3", "sqlite3", "command of the SQLite shell reading and writing the database")
+	}
+
+	exportCmd.AddCommand(exportSQLiteCmd)
+	localCmd.AddCommand(localQueryCmd)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSQLiteValue(t *testing.T) {
+	tests := []struct {
+		value    any
+		expected string
+	}{
+		{nil, "NULL"},
+		{"", "NULL"},
+		{"it's", "'it''s'"},
+		{"a\x00b", "'ab'"},
+	}
+	for _, test := range tests {
+		if literal := sqliteValue(test.value); literal != test.expected {
+			t.Errorf("sqliteValue(%q) = %s, want %s", test.value, literal, test.expected)
+		}
+	}
+}
+
+func TestSQLiteSelect(t *testing.T) {
+	if query := sqliteSelect("", "desc", 0); query != "SELECT entry FROM entries ORDER BY timestamp desc;" {
+		t.Errorf("unexpected query %q", query)
+	}
+	if query := sqliteSelect("severityNumber >= 500 OR trace IS NULL", "asc", 10); query != "SELECT entry FROM entries WHERE (severityNumber >= 500 OR trace IS NULL) ORDER BY timestamp asc LIMIT 10;" {
+		t.Errorf("unexpected query %q", query)
+	}
+}
+
+func TestSQLiteWriter(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	path := filepath.Join(t.TempDir(), "logs.db")
+	ts := time.Date(2025, 1, 2, 15, 4, 5, 120000000, time.UTC)
+	entries := []*loggingpb.LogEntry{
+		{InsertId: "a", Timestamp: timestamppb.New(ts), Severity: logtypepb.LogSeverity_ERROR, Payload: &loggingpb.LogEntry_TextPayload{TextPayload: "it's down"}},
+		{InsertId: "b", Timestamp: timestamppb.New(ts.Add(time.Second)), Severity: logtypepb.LogSeverity_INFO},
+	}
+
+	// The second export adds b and skips a, already there.
+	for _, batch := range [][]*loggingpb.LogEntry{entries[:1], entries} {
+		w, err := startSQLiteWriter(path, []string{"sqlite3"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range batch {
+			if err := w.process(entry); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, err := exec.Command("sqlite3", path, "SELECT insertId, timestamp, severityNumber, textPayload FROM entries ORDER BY timestamp;").CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	expected := "a|2025-01-02T15:04:05.120000000Z|500|it's down\nb|2025-01-02T15:04:06.120000000Z|200|\n"
+	if string(out) != expected {
+		t.Errorf("database holds %q, want %q", out, expected)
+	}
+
+	out, err = exec.Command("sqlite3", "-readonly", path, sqliteSelect("severityNumber >= 500", "desc", 0)).CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v: %s", err, out)
+	}
+	// protojson randomizes its spacing.
+	if lines := strings.ReplaceAll(string(out), " ", ""); !strings.Contains(lines, `"insertId":"a"`) || strings.Count(lines, "\n") != 1 {
+		t.Errorf("query returned %q", out)
+	}
+}
+
+func TestSQLiteWriterFailure(t *testing.T) {
+	if _, err := exec.LookPath("sqlite3"); err != nil {
+		t.Skip("sqlite3 not installed")
+	}
+	w, err := startSQLiteWriter(filepath.Join(t.TempDir(), "missing", "logs.db"), []string{"sqlite3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.process(&loggingpb.LogEntry{InsertId: "a"})
+	if err := w.close(); err == nil || !strings.HasPrefix(err.Error(), "sqlite3: ") {
+		t.Errorf("close() = %v, want the error of sqlite3", err)
+	}
+}
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultProfile = "default"
+
+// usageStats is the content of the local stats file. It never leaves the machine.
+type usageStats struct {
+	Profiles map[string]*profileUsage `json:"profiles"`
+}
+
+type profileUsage struct {
+	Queries      int64     `json:"queries"`
+	Entries      int64     `json:"entries"`
+	FetchSeconds float64   `json:"fetchSeconds"`
+	FirstUsed    time.Time `json:"firstUsed"`
+	LastUsed     time.Time `json:"lastUsed"`
+}
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show statistics",
+	Long:  `Show statistics`,
+}
+
+var statsSelfCmd = &cobra.Command{
+	Use:   "self",
+	Short: "Show local usage statistics",
+	Long: `Show the usage statistics accumulated on this machine.
+
+Collection is opt-in: set "stats: true" in the config file (or GRAPPLE_STATS=true)
+to enable it. The statistics are only stored locally and never sent anywhere.
+They count the queries run, the entries fetched and the time spent fetching;
+there is no time saved by caching, as the entries are not cached.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, err := cmd.Flags().GetString("format")
+		cobra.CheckErr(err)
+
+		path, err := statsPath()
+		cobra.CheckErr(err)
+		stats, err := loadUsageStats(path)
+		cobra.CheckErr(err)
+
+		switch format {
+		case "text":
+			if !viper.GetBool("stats") {
+				log.Println("Usage statistics are disabled, set \"stats: true\" in the config file to enable them")
+			}
+			if len(stats.Profiles) == 0 {
+				fmt.Println("No usage recorded yet")
+				return
+			}
+			names := make([]string, 0, len(stats.Profiles))
+			for name := range stats.Profiles {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				usage := stats.Profiles[name]
+				fmt.Printf("%s:\n", name)
+				fmt.Printf("  queries run:     %d\n", usage.Queries)
+				fmt.Printf("  entries fetched: %d\n", usage.Entries)
+				fmt.Printf("  time fetching:   %s\n", time.Duration(usage.FetchSeconds*float64(time.Second)).Round(time.Second))
+				fmt.Printf("  first used:      %s\n", usage.FirstUsed.Format(time.RFC3339))
+				fmt.Printf("  last used:       %s\n", usage.LastUsed.Format(time.RFC3339))
+			}
+		case "json":
+			jsonBytes, err := json.Marshal(stats)
+			cobra.CheckErr(err)
+			fmt.Println(string(jsonBytes))
+		default:
+			cobra.CheckErr(fmt.Errorf("invalid --format %q, valid values: text, json", format))
+		}
+	},
+}
+
+// statsPath returns the location of the local stats file
+func statsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, cliName, "stats.json"), nil
+}
+
+func loadUsageStats(path string) (*usageStats, error) {
+	stats := &usageStats{Profiles: map[string]*profileUsage{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return stats, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, fmt.Errorf("corrupted stats file %s: %w", path, err)
+	}
+	if stats.Profiles == nil {
+		stats.Profiles = map[string]*profileUsage{}
+	}
+	return stats, nil
+}
+
+func saveUsageStats(path string, stats *usageStats) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o600)
+}
+
+// add accumulates a single query into the stats of the given profile
+func (s *usageStats) add(profile string, entries int, elapsed time.Duration, now time.Time) {
+	usage, ok := s.Profiles[profile]
+	if !ok {
+		usage = &profileUsage{FirstUsed: now}
+		s.Profiles[profile] = usage
+	}
+	usage.Queries++
+	usage.Entries += int64(entries)
+	usage.FetchSeconds += elapsed.Seconds()
+	usage.LastUsed = now
+}
+
+// recordUsage updates the local stats file when collection is enabled.
+// Failures are only reported, usage stats must never break a query.
+func recordUsage(entries int, elapsed time.Duration) {
+	if !viper.GetBool("stats") {
+		return
+	}
+
+	profile := viper.GetString("profile")
+	if profile == "" {
+		profile = defaultProfile
+	}
+
+	path, err := statsPath()
+	if err == nil {
+		var stats *usageStats
+		stats, err = loadUsageStats(path)
+		if err == nil {
+			stats.add(profile, entries, elapsed, time.Now())
+			err = saveUsageStats(path, stats)
+		}
+	}
+	if err != nil {
+		log.Printf("Error updating usage stats: %v", err)
+	}
+}
+
+func init() {
+	statsSelfCmd.Flags().String("format", "text", "output format, valid values: text, json")
+
+	statsCmd.AddCommand(statsSelfCmd)
+	rootCmd.AddCommand(statsCmd)
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestUsageStatsAdd(t *testing.T) {
+	first := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	last := first.Add(time.Hour)
+	stats := &usageStats{Profiles: map[string]*profileUsage{}}
+	stats.add(defaultProfile, 10, 2*time.Second, first)
+	stats.add(defaultProfile, 5, 500*time.Millisecond, last)
+	stats.add("prod", 1, time.Second, last)
+
+	expected := &profileUsage{Queries: 2, Entries: 15, FetchSeconds: 2.5, FirstUsed: first, LastUsed: last}
+	if got := stats.Profiles[defaultProfile]; !reflect.DeepEqual(got, expected) {
+		t.Errorf("default profile usage = %+v, want %+v", got, expected)
+	}
+	if got := stats.Profiles["prod"]; got.Queries != 1 || !got.FirstUsed.Equal(last) {
+		t.Errorf("prod profile usage = %+v, want a single query", got)
+	}
+}
+
+func TestUsageStatsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), cliName, "stats.json")
+
+	stats, err := loadUsageStats(path)
+	if err != nil || len(stats.Profiles) != 0 {
+		t.Fatalf("loadUsageStats() of a missing file = %v, %v, want empty stats", stats, err)
+	}
+
+	now := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	stats.add(defaultProfile, 10, time.Second, now)
+	if err := saveUsageStats(path, stats); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("stats file mode = %v, want 0600", info.Mode().Perm())
+	}
+	loaded, err := loadUsageStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, stats) {
+		t.Errorf("loadUsageStats() = %+v, want the saved %+v", loaded.Profiles[defaultProfile], stats.Profiles[defaultProfile])
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadUsageStats(path); err == nil {
+		t.Error("loadUsageStats() of a corrupted file succeeded")
+	}
+	if err := os.WriteFile(path, []byte("{}"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err := loadUsageStats(path); err != nil || stats.Profiles == nil {
+		t.Errorf("loadUsageStats() without profiles = %v, %v, want usable stats", stats, err)
+	}
+}
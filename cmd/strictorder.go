@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+)
+
+// strictOrderer hands the entries over in (timestamp, insertId) order for --strict-order, holding
+// them until the fetch is a reorder window past their timestamp, and fails when an entry arrives
+// after one that should follow it was already handed over
+type strictOrderer struct {
+	next       func(*loggingpb.LogEntry) error
+	window     time.Duration
+	descending bool
+	// buffer holds the entries not handed over yet, in order.
+	buffer []*loggingpb.LogEntry
+	// frontier is the furthest timestamp fetched, last the entry handed over last.
+	frontier time.Time
+	last     *loggingpb.LogEntry
+	err      error
+}
+
+func newStrictOrderer(window time.Duration, descending bool, next func(*loggingpb.LogEntry) error) *strictOrderer {
+	return &strictOrderer{next: next, window: window, descending: descending}
+}
+
+// compare orders two entries as they must be handed over
+func (o *strictOrderer) compare(a, b *loggingpb.LogEntry) int {
+	c := cmp.Or(a.Timestamp.AsTime().Compare(b.Timestamp.AsTime()), strings.Compare(a.InsertId, b.InsertId))
+	if o.descending {
+		return -c
+	}
+	return c
+}
+
+// behind returns how far the frontier is past a timestamp, in the order of the entries
+func (o *strictOrderer) behind(ts time.Time) time.Duration {
+	if o.descending {
+		return ts.Sub(o.frontier)
+	}
+	return o.frontier.Sub(ts)
+}
+
+func (o *strictOrderer) process(entry *loggingpb.LogEntry) error {
+	if o.err != nil {
+		return grapple.ErrStop
+	}
+	if o.last != nil && o.compare(entry, o.last) < 0 {
+		o.err = fmt.Errorf("--strict-order violated: entry %s at %s arrived after entry %s at %s was printed, beyond the --reorder-window of %s",
+			entry.InsertId, entry.Timestamp.AsTime().Format(time.RFC3339Nano), o.last.InsertId, o.last.Timestamp.AsTime().Format(time.RFC3339Nano), o.window)
+		return grapple.ErrStop
+	}
+
+	i, _ := slices.BinarySearchFunc(o.buffer, entry, o.compare)
+	o.buffer = slices.Insert(o.buffer, i, entry)
+	if ts := entry.Timestamp.AsTime(); o.frontier.IsZero() || o.behind(ts) < 0 {
+		o.frontier = ts
+	}
+	for len(o.buffer) > 0 && o.behind(o.buffer[0].Timestamp.AsTime()) > o.window {
+		if err := o.handOver(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handOver passes the first entry of the buffer to next, failing only when next stops the fetch
+func (o *strictOrderer) handOver() error {
+	entry := o.buffer[0]
+	o.buffer = o.buffer[1:]
+	o.last = entry
+	if err := o.next(entry); errors.Is(err, grapple.ErrStop) {
+		return err
+	} else if err != nil {
+		counters.skipped++
+		log.Printf("Error processing log entry (%s): %v", entry.InsertId, err)
+	}
+	return nil
+}
+
+// flush hands over the entries left in the buffer, returning the violation of the order, if any
+func (o *strictOrderer) flush() error {
+	if o.err != nil {
+		return o.err
+	}
+	for len(o.buffer) > 0 {
+		if err := o.handOver(); err != nil {
+			// The processing stopped, the rest of the entries are dropped as by the fetch.
+			return nil
+		}
+	}
+	return nil
+}
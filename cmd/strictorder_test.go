@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestStrictOrderer(t *testing.T) {
+	start := time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+	entry := func(id string, offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{InsertId: id, Timestamp: timestamppb.New(start.Add(offset))}
+	}
+
+	tests := []struct {
+		name       string
+		descending bool
+		entries    []*loggingpb.LogEntry
+		// released are the entries handed over before the flush, printed all of them after it.
+		released []string
+		printed  []string
+		err      string
+	}{
+		{
+			name:    "ties ordered by insertId",
+			entries: []*loggingpb.LogEntry{entry("b", 0), entry("a", 0), entry("c", 500*time.Millisecond)},
+			printed: []string{"a", "b", "c"},
+		},
+		{
+			name:     "reordered within the window",
+			entries:  []*loggingpb.LogEntry{entry("a", 0), entry("c", 800*time.Millisecond), entry("b", 400*time.Millisecond), entry("d", 2*time.Second)},
+			released: []string{"a", "b", "c"},
+			printed:  []string{"a", "b", "c", "d"},
+		},
+		{
+			name:       "descending",
+			descending: true,
+			entries:    []*loggingpb.LogEntry{entry("c", 0), entry("a", -500*time.Millisecond), entry("b", -500*time.Millisecond), entry("d", -3*time.Second)},
+			released:   []string{"c", "b", "a"},
+			printed:    []string{"c", "b", "a", "d"},
+		},
+		{
+			name:     "beyond the window",
+			entries:  []*loggingpb.LogEntry{entry("a", time.Second), entry("c", 3*time.Second), entry("b", 0)},
+			released: []string{"a"},
+			printed:  []string{"a"},
+			err:      "entry b at 2025-01-02T15:00:00Z arrived after entry a at 2025-01-02T15:00:01Z",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var printed []string
+			o := newStrictOrderer(time.Second, test.descending, func(entry *loggingpb.LogEntry) error {
+				printed = append(printed, entry.InsertId)
+				return nil
+			})
+			var stopped bool
+			for _, entry := range test.entries {
+				if err := o.process(entry); errors.Is(err, grapple.ErrStop) {
+					stopped = true
+					break
+				} else if err != nil {
+					t.Fatalf("process() error = %v", err)
+				}
+			}
+			if !reflect.DeepEqual(printed, test.released) {
+				t.Errorf("released before the flush %v, want %v", printed, test.released)
+			}
+			err := o.flush()
+			if test.err == "" {
+				if err != nil || stopped {
+					t.Fatalf("flush() error = %v, stopped %t", err, stopped)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("flush() error = %v, want %q", err, test.err)
+			}
+			if !reflect.DeepEqual(printed, test.printed) {
+				t.Errorf("printed %v, want %v", printed, test.printed)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+)
+
+// runCounters counts what the entries of a run don't tell, for --summary-file
+type runCounters struct {
+	rateLimited       int
+	transient         int
+	expiredPageTokens int
+	// skipped are the entries whose processing failed.
+	skipped int
+	// grepped are the entries rejected by --grep.
+	grepped int
+}
+
+var counters = &runCounters{}
+
+// runSummary is the end-of-run statistics written by --summary-file
+type runSummary struct {
+	Project    string    `json:"project"`
+	Filter     string    `json:"filter"`
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	// Window is the time range requested, and the one of the entries fetched.
+	Window summaryWindow `json:"window"`
+	// Fetched counts the entries returned by the API, Skipped the ones whose processing failed.
+	Fetched int `json:"fetched"`
+	Skipped int `json:"skipped"`
+	// Retries counts the pauses after rate limits and transient errors, and the queries restarted after their page token expired.
+	Retries        summaryRetries `json:"retries"`
+	Interrupted    bool           `json:"interrupted,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	BySeverity     map[string]int `json:"bySeverity"`
+	ByLog          map[string]int `json:"byLog"`
+	ByResourceType map[string]int `json:"byResourceType"`
+}
+
+type summaryWindow struct {
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	Oldest string `json:"oldest,omitempty"`
+	Newest string `json:"newest,omitempty"`
+}
+
+type summaryRetries struct {
+	RateLimited       int `json:"rateLimited"`
+	Transient         int `json:"transient"`
+	ExpiredPageTokens int `json:"expiredPageTokens"`
+}
+
+// summaryCollector breaks the entries of a run down for --summary-file
+type summaryCollector struct {
+	stats          *entryStats
+	oldest, newest time.Time
+}
+
+func newSummaryCollector() *summaryCollector {
+	return &summaryCollector{stats: newEntryStats()}
+}
+
+func (c *summaryCollector) observe(entry *loggingpb.LogEntry) {
+	c.stats.observe(entry)
+	if entry.Timestamp == nil {
+		return
+	}
+	ts := entry.Timestamp.AsTime()
+	if c.oldest.IsZero() || ts.Before(c.oldest) {
+		c.oldest = ts
+	}
+	if ts.After(c.newest) {
+		c.newest = ts
+	}
+}
+
+// summary returns the statistics of the run, err being the error it ended with if any
+func (c *summaryCollector) summary(project, filter string, from, to, started, finished time.Time, fetched int, interrupted bool, err error) *runSummary {
+	s := &runSummary{
+		Project:        project,
+		Filter:         filter,
+		StartedAt:      started.UTC(),
+		FinishedAt:     finished.UTC(),
+		Window:         summaryWindow{From: summaryTime(from), To: summaryTime(to), Oldest: summaryTime(c.oldest), Newest: summaryTime(c.newest)},
+		Fetched:        fetched,
+		Skipped:        counters.skipped,
+		Retries:        summaryRetries{RateLimited: counters.rateLimited, Transient: counters.transient, ExpiredPageTokens: counters.expiredPageTokens},
+		Interrupted:    interrupted,
+		BySeverity:     c.stats.bySeverity,
+		ByLog:          c.stats.byLog,
+		ByResourceType: c.stats.byResourceType,
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	return s
+}
+
+// summaryTime formats a time of the summary, empty when zero
+func summaryTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func (s *runSummary) write(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRunSummary(t *testing.T) {
+	defer func() { counters = &runCounters{} }()
+	counters = &runCounters{rateLimited: 2, skipped: 1}
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	collector := newSummaryCollector()
+	for i, severity := range []logtypepb.LogSeverity{logtypepb.LogSeverity_ERROR, logtypepb.LogSeverity_INFO, logtypepb.LogSeverity_ERROR} {
+		collector.observe(&loggingpb.LogEntry{
+			LogName:   "projects/p/logs/app",
+			Severity:  severity,
+			Timestamp: timestamppb.New(start.Add(-time.Duration(i) * time.Minute)),
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	summary := collector.summary("p", "severity>=INFO", time.Time{}, time.Time{}, start, start.Add(time.Second), 3, false, errors.New("boom"))
+	if err := summary.write(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := &runSummary{}
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedWindow := summaryWindow{Oldest: "2024-12-31T23:58:00Z", Newest: "2025-01-01T00:00:00Z"}
+	if got.Window != expectedWindow {
+		t.Errorf("window = %+v, want %+v", got.Window, expectedWindow)
+	}
+	if got.Fetched != 3 || got.Skipped != 1 || got.Retries != (summaryRetries{RateLimited: 2}) || got.Error != "boom" {
+		t.Errorf("summary = %+v, want 3 fetched, 1 skipped, 2 rate limits and the error", got)
+	}
+	if expected := map[string]int{"ERROR": 2, "INFO": 1}; !reflect.DeepEqual(got.BySeverity, expected) {
+		t.Errorf("bySeverity = %v, want %v", got.BySeverity, expected)
+	}
+	if expected := map[string]int{"app": 3}; !reflect.DeepEqual(got.ByLog, expected) {
+		t.Errorf("byLog = %v, want %v", got.ByLog, expected)
+	}
+}
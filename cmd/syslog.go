@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+// syslogScheme prefixes the --output URLs of syslog servers
+const syslogScheme = "syslog://"
+
+// syslogEnterpriseNumber qualifies the IDs of the structured data elements, as RFC 5424 requires for
+// the ones not registered with IANA. It is the number reserved for documentation by RFC 5612.
+const syslogEnterpriseNumber = "32473"
+
+// syslogSeverities maps the severities of the entries to the syslog severities
+var syslogSeverities = map[logtypepb.LogSeverity]int{
+	logtypepb.LogSeverity_EMERGENCY: 0,
+	logtypepb.LogSeverity_ALERT:     1,
+	logtypepb.LogSeverity_CRITICAL:  2,
+	logtypepb.LogSeverity_ERROR:     3,
+	logtypepb.LogSeverity_WARNING:   4,
+	logtypepb.LogSeverity_NOTICE:    5,
+	logtypepb.LogSeverity_INFO:      6,
+	logtypepb.LogSeverity_DEBUG:     7,
+	logtypepb.LogSeverity_DEFAULT:   6,
+}
+
+// syslogFacilities are the facilities accepted by the facility parameter of the syslog URLs
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news", "uucp", "cron", "authpriv", "ftp",
+	"ntp", "audit", "alert", "clock", "local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+// syslogHostLabels are the labels of the monitored resources naming the host of the messages, by preference
+var syslogHostLabels = []string{"pod_name", "instance_id", "node_name", "service_name", "function_name", "module_id", "job_name"}
+
+// syslogConfig is a parsed syslog://HOST[:PORT][?proto=udp|tcp|tls&facility=NAME] URL
+type syslogConfig struct {
+	proto    string
+	address  string
+	facility int
+}
+
+// parseSyslogURL parses the syslog URL of --output, the port defaulting to 514, 6514 with TLS
+func parseSyslogURL(rawURL string) (syslogConfig, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return syslogConfig{}, err
+	}
+	if u.Hostname() == "" {
+		return syslogConfig{}, fmt.Errorf("invalid syslog URL %q, expected syslog://HOST[:PORT][?proto=udp|tcp|tls]", rawURL)
+	}
+	config := syslogConfig{proto: "udp", facility: slices.Index(syslogFacilities, "user")}
+	for key, values := range u.Query() {
+		value := values[len(values)-1]
+		switch key {
+		case "proto":
+			if value != "udp" && value != "tcp" && value != "tls" {
+				return syslogConfig{}, fmt.Errorf("invalid proto %q in %s, valid values: udp, tcp, tls", value, rawURL)
+			}
+			config.proto = value
+		case "facility":
+			if config.facility = slices.Index(syslogFacilities, value); config.facility < 0 {
+				return syslogConfig{}, fmt.Errorf("invalid facility %q in %s, valid values: %s", value, rawURL, strings.Join(syslogFacilities, ", "))
+			}
+		default:
+			return syslogConfig{}, fmt.Errorf("unknown parameter %q in %s, valid parameters: proto, facility", key, rawURL)
+		}
+	}
+	port := u.Port()
+	if port == "" {
+		port = "514"
+		if config.proto == "tls" {
+			port = "6514"
+		}
+	}
+	config.address = net.JoinHostPort(u.Hostname(), port)
+	return config, nil
+}
+
+// isSyslogOutput reports whether --output is a syslog server
+func isSyslogOutput(cmd *cobra.Command) bool {
+	output := cmd.Flag("output")
+	return output != nil && strings.HasPrefix(output.Value.String(), syslogScheme)
+}
+
+// syslogWriter sends the messages to a syslog server: a datagram per message over UDP, framed with
+// their length (RFC 6587 octet counting) over TCP and TLS
+type syslogWriter struct {
+	config syslogConfig
+	tls    *tls.Config
+	conn   net.Conn
+	count  int
+	// err is the failure of the connection that stopped the writer.
+	err error
+}
+
+// newSyslogSink returns the function sending each entry to the syslog server of --output, as an
+// RFC 5424 message of the line rendered by --format
+func newSyslogSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	config, err := parseSyslogURL(cmd.Flag("output").Value.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	w := &syslogWriter{config: config}
+	if config.proto == "tls" {
+		if w.tls, err = tlsConfig(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := w.connect(); err != nil {
+		return nil, nil, err
+	}
+	process, err := newLinePipeline(cmd, func(entry *loggingpb.LogEntry, line string) error {
+		if w.err != nil {
+			return grapple.ErrStop
+		}
+		if w.err = w.send(syslogMessage(entry, config.facility, line)); w.err != nil {
+			return grapple.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		w.conn.Close()
+		return nil, nil, err
+	}
+	return process, w.close, nil
+}
+
+func (w *syslogWriter) connect() error {
+	var err error
+	switch w.config.proto {
+	case "tls":
+		w.conn, err = tls.Dial("tcp", w.config.address, w.tls)
+	default:
+		w.conn, err = net.Dial(w.config.proto, w.config.address)
+	}
+	if err != nil {
+		return fmt.Errorf("connecting to the syslog server: %w", err)
+	}
+	return nil
+}
+
+// send writes a message, connecting again once if the server closed the stream
+func (w *syslogWriter) send(message string) error {
+	if w.config.proto != "udp" {
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	_, err := w.conn.Write([]byte(message))
+	if err != nil && w.config.proto != "udp" {
+		w.conn.Close()
+		if err = w.connect(); err == nil {
+			_, err = w.conn.Write([]byte(message))
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("sending to the syslog server: %w", err)
+	}
+	w.count++
+	return nil
+}
+
+func (w *syslogWriter) close() error {
+	err := errors.Join(w.err, w.conn.Close())
+	log.Printf("Sent %d entries to %s", w.count, w.config.address)
+	return err
+}
+
+// syslogMessage returns the RFC 5424 message of an entry, with its labels and the ones of its resource
+// as structured data
+func syslogMessage(entry *loggingpb.LogEntry, facility int, msg string) string {
+	timestamp := "-"
+	if entry.Timestamp != nil {
+		timestamp = entry.Timestamp.AsTime().UTC().Format("2006-01-02T15:04:05.999999Z07:00")
+	}
+	hostname := "-"
+	for _, label := range syslogHostLabels {
+		if value := entry.Resource.GetLabels()[label]; value != "" {
+			hostname = value
+			break
+		}
+	}
+	var data strings.Builder
+	for _, element := range []struct {
+		id     string
+		params map[string]string
+	}{
+		{"labels", entry.Labels},
+		{"resource", entry.Resource.GetLabels()},
+	} {
+		if len(element.params) == 0 {
+			continue
+		}
+		data.WriteString("[" + element.id + "@" + syslogEnterpriseNumber)
+		if element.id == "resource" {
+			data.WriteString(` type="` + syslogParamValue(entry.Resource.Type) + `"`)
+		}
+		for _, key := range sortedKeys(element.params) {
+			data.WriteString(" " + syslogName(key, 32) + `="` + syslogParamValue(element.params[key]) + `"`)
+		}
+		data.WriteString("]")
+	}
+	if data.Len() == 0 {
+		data.WriteString("-")
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s - - %s %s", facility*8+syslogSeverities[entry.Severity], timestamp,
+		syslogName(hostname, 255), syslogName(shortLogName(entry.LogName), 48), data.String(), msg)
+}
+
+// syslogName returns a header field or parameter name: printable ASCII characters other than space,
+// =, ] and ", "-" when empty
+func syslogName(s string, maxLength int) string {
+	name := []byte(s)
+	for i, c := range name {
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' {
+			name[i] = '_'
+		}
+	}
+	if len(name) == 0 {
+		return "-"
+	}
+	return string(name[:min(len(name), maxLength)])
+}
+
+// syslogParamValue escapes ", \ and ] in a parameter value
+func syslogParamValue(s string) string {
+	return strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`).Replace(s)
+}
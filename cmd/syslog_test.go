@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bufio"
+	"net"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestParseSyslogURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		expected syslogConfig
+	}{
+		{"syslog://logs.internal", syslogConfig{proto: "udp", address: "logs.internal:514", facility: 1}},
+		{"syslog://logs.internal:1514?proto=tcp&facility=local0", syslogConfig{proto: "tcp", address: "logs.internal:1514", facility: 16}},
+		{"syslog://[::1]?proto=tls", syslogConfig{proto: "tls", address: "[::1]:6514", facility: 1}},
+	}
+	for _, test := range tests {
+		config, err := parseSyslogURL(test.url)
+		if err != nil || config != test.expected {
+			t.Errorf("parseSyslogURL(%q) = %+v, %v, want %+v", test.url, config, err, test.expected)
+		}
+	}
+	for _, url := range []string{"syslog://", "syslog://h?proto=sctp", "syslog://h?facility=local8", "syslog://h?framing=lf"} {
+		if _, err := parseSyslogURL(url); err == nil {
+			t.Errorf("parseSyslogURL(%q) succeeded, want an error", url)
+		}
+	}
+}
+
+func TestSyslogMessage(t *testing.T) {
+	entry := &loggingpb.LogEntry{
+		LogName:   "projects/p/logs/nginx%2Faccess",
+		Timestamp: timestamppb.New(time.Date(2025, 1, 2, 15, 4, 5, 123456789, time.UTC)),
+		Severity:  logtypepb.LogSeverity_ERROR,
+		Labels:    map[string]string{"team": `a "b"`, "k8s-pod/app": "web]"},
+		Resource:  &monitoredres.MonitoredResource{Type: "k8s_container", Labels: map[string]string{"pod_name": "web-1"}},
+	}
+	expected := `<11>1 2025-01-02T15:04:05.123456Z web-1 nginx/access - - [labels@32473 k8s-pod/app="web\]" team="a \"b\""][resource@32473 type="k8s_container" pod_name="web-1"] upstream timed out`
+	if message := syslogMessage(entry, 1, "upstream timed out"); message != expected {
+		t.Errorf("syslogMessage() = %s\nwant %s", message, expected)
+	}
+
+	expected = `<134>1 - - - - - - hello`
+	if message := syslogMessage(&loggingpb.LogEntry{}, 16, "hello"); message != expected {
+		t.Errorf("syslogMessage() = %s, want %s", message, expected)
+	}
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		received <- line
+	}()
+
+	w := &syslogWriter{config: syslogConfig{proto: "tcp", address: listener.Addr().String()}}
+	if err := w.connect(); err != nil {
+		t.Fatal(err)
+	}
+	// Octet counting frames the messages: a newline is part of the second one.
+	if err := w.send("<14>1 - - - - - - one"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.send("two\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(); err != nil {
+		t.Fatal(err)
+	}
+	if data := <-received; data != "21 <14>1 - - - - - - one4 two\n" {
+		t.Errorf("server received %q", data)
+	}
+}
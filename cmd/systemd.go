@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Log destinations for --log-to.
+const (
+	logToStderr  = "stderr"
+	logToJournal = "journal"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// syslog priorities, as understood by journald
+const (
+	priorityErr     = 3
+	priorityWarning = 4
+	priorityInfo    = 6
+)
+
+var logTo string
+
+// setupLogging routes the diagnostic messages to the destination selected with --log-to.
+// With "journal" messages go to the systemd journal, falling back to stderr with
+// sd-daemon priority prefixes (e.g. "<3>") that journald understands as well.
+func setupLogging(destination string) error {
+	switch destination {
+	case "", logToStderr:
+		log.SetOutput(os.Stderr)
+	case logToJournal:
+		conn, err := net.Dial("unixgram", journalSocket)
+		if err != nil {
+			log.SetOutput(&priorityWriter{w: os.Stderr, format: formatPrefixed})
+			return nil
+		}
+		log.SetOutput(&priorityWriter{w: conn, format: formatJournal})
+	default:
+		return fmt.Errorf("invalid --log-to %q, valid values: %s, %s", destination, logToStderr, logToJournal)
+	}
+	return nil
+}
+
+// priorityWriter tags each log message with a priority inferred from its prefix
+type priorityWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	format func(priority int, message string) []byte
+}
+
+func (p *priorityWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	message := strings.TrimRight(string(b), "\n")
+	if _, err := p.w.Write(p.format(messagePriority(message), message)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// messagePriority maps the conventional "Error:" and "Warning:" prefixes to syslog priorities
+func messagePriority(message string) int {
+	switch {
+	case strings.HasPrefix(message, "Error"):
+		return priorityErr
+	case strings.HasPrefix(message, "Warning"):
+		return priorityWarning
+	default:
+		return priorityInfo
+	}
+}
+
+// formatPrefixed renders a message for stderr, prefixing every line with its priority
+func formatPrefixed(priority int, message string) []byte {
+	var buf bytes.Buffer
+	for _, line := range strings.Split(message, "\n") {
+		fmt.Fprintf(&buf, "<%d>%s\n", priority, line)
+	}
+	return buf.Bytes()
+}
+
+// formatJournal renders a message in the journal native protocol
+func formatJournal(priority int, message string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "PRIORITY=%d\nSYSLOG_IDENTIFIER=%s\n", priority, cliName)
+	if strings.Contains(message, "\n") {
+		// Multi-line values are sent as the field name followed by a little-endian 64-bit size.
+		buf.WriteString("MESSAGE\n")
+		size := uint64(len(message))
+		for i := 0; i < 8; i++ {
+			buf.WriteByte(byte(size >> (8 * i)))
+		}
+		buf.WriteString(message)
+		buf.WriteString("\n")
+	} else {
+		fmt.Fprintf(&buf, "MESSAGE=%s\n", message)
+	}
+	return buf.Bytes()
+}
+
+// sdNotify sends a state update (e.g. "READY=1") to the service manager.
+// It does nothing when not running under systemd with Type=notify.
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if strings.HasPrefix(socket, "@") {
+		// Abstract namespace socket.
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		log.Printf("Warning: unable to notify systemd: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		log.Printf("Warning: unable to notify systemd: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"bytes"
+	"log"
+	"testing"
+)
+
+func TestPriorityWriter(t *testing.T) {
+	var buf bytes.Buffer
+	logger := log.New(&priorityWriter{w: &buf, format: formatPrefixed}, "", 0)
+
+	logger.Println("Using config file: .grapple.yaml")
+	logger.Printf("Warning: unknown config key %q", "projet")
+	logger.Printf("Error: boom\nsecond line")
+
+	expected := "<6>Using config file: .grapple.yaml\n" +
+		"<4>Warning: unknown config key \"projet\"\n" +
+		"<3>Error: boom\n<3>second line\n"
+	if buf.String() != expected {
+		t.Errorf("priorityWriter output = %q, want %q", buf.String(), expected)
+	}
+}
+
+func TestFormatJournal(t *testing.T) {
+	got := string(formatJournal(priorityErr, "Error: boom"))
+	expected := "PRIORITY=3\nSYSLOG_IDENTIFIER=grapple\nMESSAGE=Error: boom\n"
+	if got != expected {
+		t.Errorf("formatJournal() = %q, want %q", got, expected)
+	}
+
+	got = string(formatJournal(priorityInfo, "a\nb"))
+	expected = "PRIORITY=6\nSYSLOG_IDENTIFIER=grapple\nMESSAGE\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n"
+	if got != expected {
+		t.Errorf("formatJournal() multi-line = %q, want %q", got, expected)
+	}
+}
@@ -0,0 +1,5 @@
+;; Exits with 3 without reading any entry.
+(module
+  (import "wasi_snapshot_preview1" "proc_exit" (func $exit (param i32)))
+  (func (export "_start")
+    (call $exit (i32.const 3))))
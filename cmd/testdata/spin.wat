@@ -0,0 +1,4 @@
+;; Never answers.
+(module
+  (func (export "_start")
+    (loop $forever (br $forever))))
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const textTimestampLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// severityColors maps severities to ANSI SGR parameters, severities missing here are not colored
+var severityColors = map[logtypepb.LogSeverity]string{
+	logtypepb.LogSeverity_DEBUG:     "90",
+	logtypepb.LogSeverity_INFO:      "32",
+	logtypepb.LogSeverity_NOTICE:    "36",
+	logtypepb.LogSeverity_WARNING:   "33",
+	logtypepb.LogSeverity_ERROR:     "31",
+	logtypepb.LogSeverity_CRITICAL:  "1;31",
+	logtypepb.LogSeverity_ALERT:     "1;31",
+	logtypepb.LogSeverity_EMERGENCY: "1;41",
+}
+
+// renderText formats an entry as a single human-readable line:
+// TIMESTAMP SEVERITY LOGNAME payload-summary
+func renderText(entry *loggingpb.LogEntry, color bool) string {
+	return fmt.Sprintf("%s %s %s", renderPrefix(entry, color), shortLogName(entry.LogName), payloadSummary(entry))
+}
+
+// renderPrefix formats the timestamp and the (colored) severity starting the human-readable lines,
+// windowing on receiveTimestamp the timestamp is followed by the delay of the receipt, e.g. +1.25s
+func renderPrefix(entry *loggingpb.LogEntry, color bool) string {
+	timestamp := "-"
+	if entry.Timestamp != nil {
+		timestamp = entry.Timestamp.AsTime().In(outputLocation).Format(textTimestampLayout)
+		if windowField == fieldReceiveTimestamp && entry.ReceiveTimestamp != nil {
+			timestamp += " " + receiveDelay(entry)
+		}
+	}
+
+	severity := fmt.Sprintf("%-9s", entry.Severity.String())
+	if code, ok := severityColors[entry.Severity]; ok && color {
+		severity = fmt.Sprintf("\x1b[%sm%s\x1b[0m", code, severity)
+	}
+
+	return fmt.Sprintf("%s %s", timestamp, severity)
+}
+
+// receiveDelay formats how long after its timestamp an entry was received, negative when the clock
+// of the producer is ahead
+func receiveDelay(entry *loggingpb.LogEntry) string {
+	delay := entry.ReceiveTimestamp.AsTime().Sub(entry.Timestamp.AsTime()).Round(time.Millisecond)
+	if delay < 0 {
+		return delay.String()
+	}
+	return "+" + delay.String()
+}
+
+// shortLogName strips the parent from a log name and decodes the log ID,
+// e.g. projects/p/logs/cloudaudit.googleapis.com%2Factivity becomes cloudaudit.googleapis.com/activity
+func shortLogName(logName string) string {
+	_, logID, found := strings.Cut(logName, "/logs/")
+	if !found {
+		return logName
+	}
+	if decoded, err := url.PathUnescape(logID); err == nil {
+		return decoded
+	}
+	return logID
+}
+
+// payloadSummary returns a single-line description of the entry content
+func payloadSummary(entry *loggingpb.LogEntry) string {
+	var parts []string
+
+	if req := entry.HttpRequest; req != nil {
+		parts = append(parts, fmt.Sprintf("%s %s %d", req.RequestMethod, req.RequestUrl, req.Status))
+		if req.Latency != nil {
+			parts = append(parts, req.Latency.AsDuration().Round(time.Millisecond).String())
+		}
+	}
+
+	switch payload := entry.Payload.(type) {
+	case *loggingpb.LogEntry_TextPayload:
+		parts = append(parts, payload.TextPayload)
+	case *loggingpb.LogEntry_JsonPayload:
+		parts = append(parts, structSummary(payload.JsonPayload))
+	case *loggingpb.LogEntry_ProtoPayload:
+		if auditLog, ok := decodeAuditLog(entry); ok {
+			parts = append(parts, fmt.Sprintf("%s %s %s", auditLog.AuthenticationInfo.GetPrincipalEmail(), auditLog.MethodName, auditLog.ResourceName))
+		} else {
+			parts = append(parts, payload.ProtoPayload.TypeUrl)
+		}
+	}
+
+	return strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+}
+
+// structSummary prefers the conventional message fields of a JSON payload, falling back to the whole payload
+func structSummary(s *structpb.Struct) string {
+	for _, key := range []string{"message", "msg"} {
+		if v, ok := s.GetFields()[key]; ok {
+			if str, ok := v.GetKind().(*structpb.Value_StringValue); ok {
+				return str.StringValue
+			}
+		}
+	}
+	jsonBytes, err := protojson.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	return string(jsonBytes)
+}
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestRenderText(t *testing.T) {
+	timestamp := timestamppb.New(time.Date(2025, 1, 2, 15, 4, 5, 123_000_000, time.UTC))
+	payload, err := structpb.NewStruct(map[string]any{"message": "request\nfailed", "code": 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry := &loggingpb.LogEntry{
+		LogName:   "projects/p/logs/run.googleapis.com%2Fstderr",
+		Timestamp: timestamp,
+		Severity:  logtypepb.LogSeverity_ERROR,
+		Payload:   &loggingpb.LogEntry_JsonPayload{JsonPayload: payload},
+	}
+
+	expected := "2025-01-02T15:04:05.123Z ERROR     run.googleapis.com/stderr request failed"
+	if got := renderText(entry, false); got != expected {
+		t.Errorf("renderText() = %q, want %q", got, expected)
+	}
+
+	expectedColor := "2025-01-02T15:04:05.123Z \x1b[31mERROR    \x1b[0m run.googleapis.com/stderr request failed"
+	if got := renderText(entry, true); got != expectedColor {
+		t.Errorf("renderText() with color = %q, want %q", got, expectedColor)
+	}
+}
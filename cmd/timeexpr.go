@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clockLayouts are the accepted layouts of the time of day following a date or "today"/"yesterday"
+var clockLayouts = []string{"15:04", "15:04:05"}
+
+// dateTimeLayouts are the accepted layouts of absolute times without a zone
+var dateTimeLayouts = []string{
+	time.DateOnly,
+	"2006-01-02 15:04",
+	time.DateTime,
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+}
+
+// parseTimeExpression parses the value of --from or --to relative to now. It accepts:
+//   - RFC3339 timestamps, e.g. 2024-05-01T14:00:00Z
+//   - dates and times without a zone, e.g. 2024-05-01 or 2024-05-01 14:00
+//   - offsets from now, e.g. -2h or -1d12h, and "now"
+//   - "today" or "yesterday", optionally followed by a time of day, e.g. yesterday 14:00
+//
+// Values without a zone are in the location of now.
+func parseTimeExpression(expression string, now time.Time) (time.Time, error) {
+	expression = strings.TrimSpace(expression)
+	if t, err := time.Parse(time.RFC3339, expression); err == nil {
+		return t, nil
+	}
+	for _, layout := range dateTimeLayouts {
+		if t, err := time.ParseInLocation(layout, expression, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+
+	switch {
+	case expression == "now":
+		return now, nil
+	case strings.HasPrefix(expression, "-"), strings.HasPrefix(expression, "+"):
+		offset, err := parseFreshness(expression[1:])
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", expression)
+		}
+		if expression[0] == '-' {
+			offset = -offset
+		}
+		return now.Add(offset), nil
+	}
+
+	day, clock, _ := strings.Cut(expression, " ")
+	y, m, d := now.Date()
+	midnight := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch day {
+	case "today":
+	case "yesterday":
+		midnight = midnight.AddDate(0, 0, -1)
+	default:
+		return time.Time{}, fmt.Errorf("invalid time %q, expected e.g. 2024-05-01T14:00:00Z, 2024-05-01, -2h, now or yesterday 14:00", expression)
+	}
+	if clock = strings.TrimSpace(clock); clock == "" {
+		return midnight, nil
+	}
+	for _, layout := range clockLayouts {
+		if t, err := time.Parse(layout, clock); err == nil {
+			return midnight.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time of day %q", clock)
+}
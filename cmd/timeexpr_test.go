@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeExpression(t *testing.T) {
+	now := time.Date(2024, 5, 10, 9, 30, 0, 0, time.UTC)
+	cases := []struct {
+		input    string
+		expected time.Time
+		wantErr  bool
+	}{
+		{"2024-05-01T14:00:00Z", time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC), false},
+		{"2024-05-01T14:00:00+02:00", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), false},
+		{"2024-05-01", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2024-05-01 14:00", time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC), false},
+		{"2024-05-01T14:00:05", time.Date(2024, 5, 1, 14, 0, 5, 0, time.UTC), false},
+		{"now", now, false},
+		{"-2h", now.Add(-2 * time.Hour), false},
+		{"-1d12h", now.Add(-36 * time.Hour), false},
+		{"+30m", now.Add(30 * time.Minute), false},
+		{"today", time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC), false},
+		{"yesterday 14:00", time.Date(2024, 5, 9, 14, 0, 0, 0, time.UTC), false},
+		{" yesterday  14:00:30 ", time.Date(2024, 5, 9, 14, 0, 30, 0, time.UTC), false},
+		{"", time.Time{}, true},
+		{"-", time.Time{}, true},
+		{"tomorrow", time.Time{}, true},
+		{"yesterday 25:00", time.Time{}, true},
+		{"2024-13-01", time.Time{}, true},
+	}
+	for _, c := range cases {
+		got, err := parseTimeExpression(c.input, now)
+		if (err != nil) != c.wantErr {
+			t.Errorf("parseTimeExpression(%q) error = %v, wantErr %v", c.input, err, c.wantErr)
+			continue
+		}
+		if !got.Equal(c.expected) {
+			t.Errorf("parseTimeExpression(%q) = %s, want %s", c.input, got, c.expected)
+		}
+	}
+}
+
+func TestParseTimeExpressionLocation(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skip(err)
+	}
+	now := time.Date(2024, 5, 10, 0, 30, 0, 0, rome)
+	got, err := parseTimeExpression("yesterday 14:00", now)
+	if err != nil || !got.Equal(time.Date(2024, 5, 9, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("parseTimeExpression(yesterday 14:00) in Rome = %s, %v", got, err)
+	}
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// minuteLayout is the layout of the minutes printed by the text summaries, like --stats and histogram
+const minuteLayout = "2006-01-02T15:04Z07:00"
+
+// outputLocation is the zone of the timestamps printed as text, set from --timezone by newEntryRenderer
+var outputLocation = time.UTC
+
+// timezone returns the location set by --timezone or the timezone config, UTC by default
+func timezone() (*time.Location, error) {
+	name := viper.GetString("timezone")
+	switch strings.ToLower(name) {
+	case "", "utc":
+		return time.UTC, nil
+	case "local":
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/viper"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestTimezone(t *testing.T) {
+	defer viper.Set("timezone", "")
+
+	for name, expected := range map[string]*time.Location{"": time.UTC, "UTC": time.UTC, "local": time.Local} {
+		viper.Set("timezone", name)
+		if loc, err := timezone(); err != nil || loc != expected {
+			t.Errorf("timezone() with %q = %v, %v", name, loc, err)
+		}
+	}
+
+	viper.Set("timezone", "Mars/Olympus_Mons")
+	if _, err := timezone(); err == nil {
+		t.Error("timezone() with an unknown zone succeeded")
+	}
+}
+
+func TestRenderTextTimezone(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer func() { outputLocation = time.UTC }()
+	outputLocation = rome
+
+	entry := &loggingpb.LogEntry{
+		Timestamp: timestamppb.New(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)),
+		Payload:   &loggingpb.LogEntry_TextPayload{TextPayload: "hello"},
+	}
+	if got, want := renderText(entry, false), "2024-05-01T14:00:00.000+02:00"; got[:len(want)] != want {
+		t.Errorf("renderText() = %q, want the timestamp %s", got, want)
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/cluster"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top [filter]",
+	Short: "Print the most frequent message patterns",
+	Long: `Group the entries matching the filter by the pattern of their message, where
+numbers, UUIDs and hex IDs are replaced with placeholders, and print the most
+frequent patterns with their count and an example entry.
+
+--by groups by another field instead of the message, given as a path like
+in --fields (e.g. jsonPayload.error or httpRequest.requestUrl).`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := ""
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		runQueryInto(cmd, filter, newTopSink)
+	},
+}
+
+// newTopSink returns the sink clustering the entries and printing the top patterns
+func newTopSink(cmd *cobra.Command) (func(*loggingpb.LogEntry) error, func() error, error) {
+	patterns, err := cmd.Flags().GetInt("patterns")
+	if err != nil {
+		return nil, nil, err
+	}
+	by := cmd.Flag("by").Value.String()
+	key := func(entry *loggingpb.LogEntry) (string, error) { return payloadSummary(entry), nil }
+	if by != "message" {
+		path := strings.Split(by, ".")
+		key = func(entry *loggingpb.LogEntry) (string, error) {
+			m, err := entryFields(entry)
+			if err != nil {
+				return "", err
+			}
+			return fieldValue(m, path)
+		}
+	}
+
+	counter := cluster.NewCounter[*loggingpb.LogEntry]()
+	process, err := newLinePipeline(cmd, func(entry *loggingpb.LogEntry, _ string) error {
+		message, err := key(entry)
+		if err != nil {
+			return err
+		}
+		counter.Add(message, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	format := flagOrConfig(cmd, "format")
+	if format == "" {
+		format = defaultFormat(cmd, false)
+	}
+	color := useColor(cmd)
+	return process, func() error {
+		if format == formatJSON {
+			return writeTopJSON(stdout, counter, patterns)
+		}
+		return writeTopText(stdout, counter, patterns, color)
+	}, nil
+}
+
+func writeTopText(w io.Writer, counter *cluster.Counter[*loggingpb.LogEntry], patterns int, color bool) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "COUNT\tSHARE\tPATTERN\n")
+	for _, c := range counter.Top(patterns) {
+		fmt.Fprintf(tw, "%d\t%.1f%%\t%s\n", c.Count, 100*float64(c.Count)/float64(counter.Total()), c.Template)
+		fmt.Fprintf(tw, "\t\te.g. %s\n", renderText(c.Example, color))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d entries\n", counter.Total())
+	return err
+}
+
+func writeTopJSON(w io.Writer, counter *cluster.Counter[*loggingpb.LogEntry], patterns int) error {
+	for _, c := range counter.Top(patterns) {
+		example, err := protojson.Marshal(c.Example)
+		if err != nil {
+			return err
+		}
+		line, err := marshalJSONValue(map[string]any{
+			"pattern": c.Template,
+			"count":   c.Count,
+			"example": json.RawMessage(example),
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, line+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	addFetchFlags(topCmd)
+	addReportFlags(topCmd)
+	addGrepFlags(topCmd)
+	topCmd.Flags().Bool("no-color", false, "disable colors in the text format (also disabled by the NO_COLOR env var)")
+	topCmd.Flags().String("by", "message", "group by the message or by this field (e.g. jsonPayload.error)")
+	topCmd.Flags().Int("patterns", 20, "number of patterns to print (0 for all)")
+
+	rootCmd.AddCommand(topCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/cluster"
+)
+
+func TestWriteTopText(t *testing.T) {
+	counter := cluster.NewCounter[*loggingpb.LogEntry]()
+	for _, message := range []string{"retry 1 of 3", "retry 2 of 3", "done"} {
+		entry := &loggingpb.LogEntry{Payload: &loggingpb.LogEntry_TextPayload{TextPayload: message}}
+		counter.Add(payloadSummary(entry), entry)
+	}
+
+	var buf bytes.Buffer
+	if err := writeTopText(&buf, counter, 1, false); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{"2      66.7%  retry <num> of <num>", "e.g.", "retry 1 of 3", "3 entries"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "done") {
+		t.Errorf("output includes the pattern beyond the limit:\n%s", out)
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+	"google.golang.org/api/iterator"
+)
+
+// traceIDPattern matches the trace IDs used by Cloud Trace (32 hex chars)
+var traceIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace TRACE_ID|INSERT_ID",
+	Short: "Follow a single request across services",
+	Long: `Fetch all the entries of a trace, across all logs, oldest first.
+
+The argument is either a trace ID, a full trace name (projects/P/traces/ID)
+or the insertId of a log entry, whose trace is then looked up. The lookup
+uses the same time window as the query, 24 hours by default.
+An additional filter can be given with --filter.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+
+		trace := args[0]
+		if !isTraceReference(trace) {
+			var err error
+			trace, err = lookupTrace(cmd, projectId, trace)
+			checkErr(err)
+			log.Printf("Following trace %s", trace)
+		}
+
+		oldestFirst(cmd)
+		runQuery(cmd, andFilters(cmd.Flag("filter").Value.String(), traceFilter(projectId, trace)))
+	},
+}
+
+// isTraceReference tells trace IDs and names apart from insertIds
+func isTraceReference(s string) bool {
+	return traceIDPattern.MatchString(s) || strings.HasPrefix(s, "projects/")
+}
+
+// traceFilter returns the filter matching the entries of a trace, given its ID or full name
+func traceFilter(projectId, trace string) string {
+	if !strings.HasPrefix(trace, "projects/") {
+		trace = fmt.Sprintf("projects/%s/traces/%s", projectId, trace)
+	}
+	return fmt.Sprintf("trace=%q", trace)
+}
+
+// lookupTrace returns the trace of the entry with the given insertId
+func lookupTrace(cmd *cobra.Command, projectId, insertID string) (string, error) {
+	from, to, err := determineTimeWindow(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	ctx := cmd.Context()
+
+	client, err := newLogadminClient(ctx, projectId)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+
+	it := client.Entries(ctx, logadmin.Filter(buildFilter(from, to, fmt.Sprintf("insertId=%q", insertID))))
+	entry, err := it.Next()
+	if errors.Is(err, iterator.Done) {
+		return "", fmt.Errorf("no entry found with insertId %q", insertID)
+	} else if err != nil {
+		return "", err
+	}
+	if entry.Trace == "" {
+		return "", fmt.Errorf("entry %q has no trace", insertID)
+	}
+	return entry.Trace, nil
+}
+
+func init() {
+	addQueryFlags(traceCmd)
+	traceCmd.Flags().String("filter", "", "additional filter for the entries of the trace")
+
+	rootCmd.AddCommand(traceCmd)
+}
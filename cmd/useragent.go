@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"strconv"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/useragent"
+)
+
+// userAgentLabelPrefix prefixes the labels set by --parse-user-agent, exposed as the @ua virtual field
+const userAgentLabelPrefix = "grapple.ua/"
+
+// annotateUserAgent sets the grapple.ua/ labels of the entry from httpRequest.userAgent:
+// browser, version, os, device and bot. The grapple.ua/ labels the entry already had are dropped,
+// like the grapple.geo/ ones.
+func annotateUserAgent(entry *loggingpb.LogEntry) {
+	dropLabels(entry, userAgentLabelPrefix)
+	header := entry.HttpRequest.GetUserAgent()
+	if header == "" {
+		return
+	}
+	agent := useragent.Parse(header)
+	if entry.Labels == nil {
+		entry.Labels = map[string]string{}
+	}
+	for name, value := range map[string]string{
+		"browser": agent.Browser,
+		"version": agent.Version,
+		"os":      agent.OS,
+		"device":  agent.Device,
+	} {
+		if value != "" {
+			entry.Labels[userAgentLabelPrefix+name] = value
+		}
+	}
+	entry.Labels[userAgentLabelPrefix+"bot"] = strconv.FormatBool(agent.Bot)
+}
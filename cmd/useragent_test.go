@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+)
+
+func TestAnnotateUserAgent(t *testing.T) {
+	entry := &loggingpb.LogEntry{HttpRequest: &logtypepb.HttpRequest{UserAgent: "curl/8.5.0"}}
+	annotateUserAgent(entry)
+
+	m, err := entryFields(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{
+		"@ua.browser": "curl",
+		"@ua.version": "8",
+		"@ua.bot":     "false",
+		"@ua.os":      "-",
+	} {
+		if got, err := fieldValue(m, parseFields([]string{path})[0]); err != nil || got != expected {
+			t.Errorf("%s = %q, %v, want %q", path, got, err, expected)
+		}
+	}
+
+	spoofed := &loggingpb.LogEntry{
+		HttpRequest: &logtypepb.HttpRequest{UserAgent: "curl/8.5.0"},
+		Labels:      map[string]string{userAgentLabelPrefix + "os": "Windows", userAgentLabelPrefix + "bot": "true"},
+	}
+	annotateUserAgent(spoofed)
+	if _, ok := spoofed.Labels[userAgentLabelPrefix+"os"]; ok || spoofed.Labels[userAgentLabelPrefix+"bot"] != "false" {
+		t.Errorf("labels %v, want the grapple.ua/ labels of the producer replaced", spoofed.Labels)
+	}
+
+	without := &loggingpb.LogEntry{Labels: map[string]string{userAgentLabelPrefix + "bot": "true"}}
+	annotateUserAgent(without)
+	if len(without.Labels) != 0 {
+		t.Errorf("labels of an entry without user agent = %v", without.Labels)
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dippi/grapple/internal/lql"
+	"github.com/spf13/cobra"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate [filter]",
+	Short: "Check the syntax of a filter and explain it",
+	Long: `Check the syntax of a filter of the Cloud Logging query language without
+calling the API, and print how it is understood: one term per line, nested
+under the AND, OR and NOT joining them. The filter is read from stdin when
+it is not given or is "-".
+
+Syntax errors are reported with their line and column. Note that OR binds
+tighter than AND: "a OR b AND c" means "(a OR b) AND c".
+
+With --composed, the filter checked is the one grapple would send, with the
+alwaysFilter, the exclude presets, the shorthand flags and the time window of
+--from, --to and --freshness.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		filter := "-"
+		if len(args) > 0 {
+			filter = args[0]
+		}
+		if filter == "-" {
+			data, err := io.ReadAll(os.Stdin)
+			cobra.CheckErr(err)
+			filter = string(data)
+		}
+		if composed, _ := cmd.Flags().GetBool("composed"); composed {
+			var err error
+			filter, err = composeFilter(cmd, filter)
+			cobra.CheckErr(err)
+			from, to, err := determineTimeWindow(cmd)
+			cobra.CheckErr(err)
+			filter = buildFilter(from, to, filter)
+		}
+
+		node, err := lql.Parse(filter)
+		cobra.CheckErr(syntaxErrorContext(filter, err))
+		if node == nil {
+			fmt.Fprintln(stdout, "Empty filter, matching all the entries")
+			return
+		}
+		explainFilter(stdout, node, "")
+	},
+}
+
+// syntaxErrorContext adds the line of a syntax error, with a caret under its column, to the error
+func syntaxErrorContext(filter string, err error) error {
+	var syntaxErr *lql.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return err
+	}
+	lines := strings.Split(filter, "\n")
+	line := strings.ReplaceAll(lines[syntaxErr.Line-1], "\t", " ")
+	return fmt.Errorf("invalid filter at %w\n  %s\n  %s^", err, line, strings.Repeat(" ", syntaxErr.Column-1))
+}
+
+// explainFilter prints a parsed filter as a tree, one term per line
+func explainFilter(w io.Writer, node lql.Node, indent string) {
+	switch n := node.(type) {
+	case *lql.And:
+		fmt.Fprintf(w, "%sAND\n", indent)
+		for _, term := range n.Terms {
+			explainFilter(w, term, indent+"  ")
+		}
+	case *lql.Or:
+		fmt.Fprintf(w, "%sOR\n", indent)
+		for _, term := range n.Terms {
+			explainFilter(w, term, indent+"  ")
+		}
+	case *lql.Not:
+		fmt.Fprintf(w, "%sNOT\n", indent)
+		explainFilter(w, n.Term, indent+"  ")
+	case *lql.Comparison:
+		fmt.Fprintf(w, "%s%s %s %s\n", indent, n.Path, n.Op, n.Value)
+	case *lql.Search:
+		fmt.Fprintf(w, "%s%s anywhere in the entry\n", indent, n.Value)
+	default:
+		fmt.Fprintf(w, "%s%s\n", indent, n)
+	}
+}
+
+func init() {
+	addFilterFlags(validateCmd)
+	validateCmd.Flags().Bool("composed", false, "check the filter grapple would send, with the alwaysFilter, exclude presets, shorthand flags and time window")
+
+	rootCmd.AddCommand(validateCmd)
+}
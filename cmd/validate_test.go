@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/dippi/grapple/internal/lql"
+)
+
+func TestExplainFilter(t *testing.T) {
+	node, err := lql.Parse(`severity>=ERROR (resource.type="gce_instance" OR -logName:healthz) "disk full"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `AND
+  severity >= ERROR
+  OR
+    resource.type = "gce_instance"
+    NOT
+      logName : healthz
+  "disk full" anywhere in the entry
+`
+	var b strings.Builder
+	explainFilter(&b, node, "")
+	if b.String() != expected {
+		t.Errorf("explainFilter() = %s, want %s", b.String(), expected)
+	}
+}
+
+func TestSyntaxErrorContext(t *testing.T) {
+	filter := "severity>=ERROR\n\tAND jsonPayload.status="
+	_, err := lql.Parse(filter)
+	expected := `invalid filter at line 2, column 25: expected a value after "=", found end of filter
+   AND jsonPayload.status=
+                          ^`
+	if got := syntaxErrorContext(filter, err); got == nil || got.Error() != expected {
+		t.Errorf("syntaxErrorContext() = %v, want %s", got, expected)
+	}
+
+	other := errors.New("other")
+	if got := syntaxErrorContext(filter, other); got != other {
+		t.Errorf("syntaxErrorContext() = %v, want the error unchanged", got)
+	}
+}
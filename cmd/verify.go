@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+	bigquery "google.golang.org/api/bigquery/v2"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify MANIFEST",
+	Short: "Check that an export is complete",
+	Long: `Count again, window by window, the entries matching an export and compare
+the counts with the manifest written by --manifest.
+
+Mismatching windows are reported, and make the command fail. Entries are
+only counted, not printed, but they still have to be listed through the API.
+Counts may legitimately grow when entries arrive late or, for recent windows,
+shrink when entries expire from their bucket.
+
+When the entries are also routed to BigQuery, --against-bq counts them in the
+tables of the sink dataset too, in parallel, and reports the windows where the
+sink differs from the manifest. The tables are expected to hold the entries of
+the export only, i.e. the sink has the same filter.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		manifest, err := readExportManifest(args[0])
+		cobra.CheckErr(err)
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, manifest.Project)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		baseOpts := []logadmin.EntriesOption{logadmin.PageSize(1000)}
+		views := manifest.Views
+		if manifest.View != "" {
+			views = append(views, manifest.View)
+		}
+		if len(views) > 0 {
+			baseOpts = append(baseOpts, logadmin.ResourceNames(views))
+		}
+
+		dataset := cmd.Flag("against-bq").Value.String()
+		var sinkCounts []int
+		var sinkErr error
+		sinkDone := make(chan struct{})
+		if dataset != "" {
+			go func() {
+				defer close(sinkDone)
+				sinkCounts, sinkErr = countSinkWindows(ctx, manifest, dataset, cmd.Flag("bq-location").Value.String())
+			}()
+		} else {
+			close(sinkDone)
+		}
+
+		mismatches := 0
+		for i, w := range manifest.Windows {
+			opts := append(slices.Clone(baseOpts), logadmin.Filter(manifest.windowFilter(i)))
+			// Counting stops a little past the exported count, which is enough to tell a mismatch.
+			count, _, err := countEntries(ctx, client, opts, w.Entries)
+			cobra.CheckErr(err)
+			if count != w.Entries {
+				mismatches++
+				more := ""
+				if count > w.Entries {
+					more = " or more"
+				}
+				log.Printf("Mismatch between %s and %s: exported %d, found %d%s", w.From.Format(time.RFC3339), w.To.Format(time.RFC3339), w.Entries, count, more)
+			}
+		}
+
+		<-sinkDone
+		if sinkErr != nil {
+			cobra.CheckErr(fmt.Errorf("counting the entries of BigQuery dataset %s: %w", dataset, sinkErr))
+		}
+		for i, w := range manifest.Windows {
+			if sinkCounts != nil && sinkCounts[i] != w.Entries {
+				mismatches++
+				log.Printf("BigQuery mismatch between %s and %s: exported %d, sink has %d", w.From.Format(time.RFC3339), w.To.Format(time.RFC3339), w.Entries, sinkCounts[i])
+			}
+		}
+
+		if mismatches > 0 {
+			cobra.CheckErr(fmt.Errorf("%d of %d windows do not match the manifest", mismatches, len(manifest.Windows)))
+		}
+		log.Printf("All %d windows match the manifest", len(manifest.Windows))
+	},
+}
+
+// countSinkWindows counts the entries of each window of the manifest in the tables of a BigQuery sink dataset
+func countSinkWindows(ctx context.Context, manifest *exportManifest, dataset, location string) ([]int, error) {
+	opts, err := clientOptions()
+	if err != nil {
+		return nil, err
+	}
+	service, err := bigquery.NewService(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	req := &bigquery.QueryRequest{
+		Query:        sinkWindowsQuery(manifest, datasetReference(manifest.Project, dataset)),
+		UseLegacySql: new(bool),
+		Location:     location,
+	}
+	result, err := runSQL(ctx, service.Jobs, manifest.Project, req, 0)
+	if err != nil {
+		return nil, err
+	}
+	return sinkWindowCounts(result, len(manifest.Windows))
+}
+
+// sinkWindowsQuery returns the query counting the entries of the dataset per window of the manifest.
+// The windows have the same size but the last one, which can be shorter and includes its end.
+func sinkWindowsQuery(manifest *exportManifest, dataset *bigquery.DatasetReference) string {
+	if len(manifest.Windows) == 0 {
+		return "SELECT 0 AS w, 0 AS n LIMIT 0"
+	}
+	first, last := manifest.Windows[0], manifest.Windows[len(manifest.Windows)-1]
+	return fmt.Sprintf(
+		"SELECT LEAST(DIV(UNIX_MICROS(%[1]s) - %[2]d, %[3]d), %[4]d) AS w, COUNT(*) AS n\n"+
+			"FROM `%[5]s.%[6]s.*`\n"+
+			"WHERE %[1]s >= TIMESTAMP_MICROS(%[2]d) AND %[1]s <= TIMESTAMP_MICROS(%[7]d)\n"+
+			"GROUP BY w",
+		manifest.field(),
+		first.From.UnixMicro(),
+		first.To.Sub(first.From).Microseconds(),
+		len(manifest.Windows)-1,
+		dataset.ProjectId,
+		dataset.DatasetId,
+		last.To.UnixMicro(),
+	)
+}
+
+// sinkWindowCounts returns the counts per window of the result of sinkWindowsQuery
+func sinkWindowCounts(result *sqlResult, windows int) ([]int, error) {
+	counts := make([]int, windows)
+	for _, row := range result.rows {
+		if len(row) != 2 {
+			return nil, errors.New("unexpected columns in the window counts")
+		}
+		i, err := strconv.Atoi(sqlCell(row[0]))
+		if err != nil || i < 0 || i >= windows {
+			return nil, fmt.Errorf("unexpected window %v in the window counts", row[0])
+		}
+		if counts[i], err = strconv.Atoi(sqlCell(row[1])); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
+}
+
+func init() {
+	verifyCmd.Flags().String("against-bq", "", "also count the entries of each window in the tables of this BigQuery sink dataset (DATASET or PROJECT.DATASET)")
+	verifyCmd.Flags().String("bq-location", "", "location of the dataset of --against-bq (default detected by BigQuery)")
+
+	rootCmd.AddCommand(verifyCmd)
+}
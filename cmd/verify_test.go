@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSinkWindowsQuery(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := newExportManifest("p", "", nil, from, from.Add(150*time.Minute), time.Hour)
+
+	expected := "SELECT LEAST(DIV(UNIX_MICROS(timestamp) - 1735689600000000, 3600000000), 2) AS w, COUNT(*) AS n\n" +
+		"FROM `p.sink.*`\n" +
+		"WHERE timestamp >= TIMESTAMP_MICROS(1735689600000000) AND timestamp <= TIMESTAMP_MICROS(1735698600000000)\n" +
+		"GROUP BY w"
+	if got := sinkWindowsQuery(m, datasetReference("p", "sink")); got != expected {
+		t.Errorf("sinkWindowsQuery() = %s, want %s", got, expected)
+	}
+
+	result := &sqlResult{rows: [][]any{{json.Number("2"), json.Number("7")}, {json.Number("0"), json.Number("12")}}}
+	counts, err := sinkWindowCounts(result, len(m.Windows))
+	if err != nil || !reflect.DeepEqual(counts, []int{12, 0, 7}) {
+		t.Errorf("sinkWindowCounts() = %v, %v, want [12 0 7]", counts, err)
+	}
+	result.rows = append(result.rows, []any{json.Number("3"), json.Number("1")})
+	if _, err := sinkWindowCounts(result, len(m.Windows)); err == nil {
+		t.Error("sinkWindowCounts() with a window out of range succeeded, want an error")
+	}
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, injected at build time with e.g.
+// -ldflags "-X github.com/dippi/grapple/cmd.version=v1.2.3 -X github.com/dippi/grapple/cmd.commit=abc123 -X github.com/dippi/grapple/cmd.buildDate=2025-01-01T00:00:00Z"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+const loggingModulePath = "cloud.google.com/go/logging"
+
+type versionInfo struct {
+	Version        string `json:"version"`
+	Commit         string `json:"commit"`
+	BuildDate      string `json:"buildDate"`
+	GoVersion      string `json:"goVersion"`
+	LoggingVersion string `json:"loggingClientVersion"`
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print version and build information",
+	Long:  `Print version and build information`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		format, err := cmd.Flags().GetString("format")
+		cobra.CheckErr(err)
+
+		cobra.CheckErr(printVersion(os.Stdout, currentVersionInfo(), format))
+	},
+}
+
+// printVersion writes info to w in the --format
+func printVersion(w io.Writer, info versionInfo, format string) error {
+	switch format {
+	case "text":
+		fmt.Fprintf(w, "%s %s\n", cliName, info.Version)
+		fmt.Fprintf(w, "  commit:         %s\n", info.Commit)
+		fmt.Fprintf(w, "  build date:     %s\n", info.BuildDate)
+		fmt.Fprintf(w, "  go version:     %s\n", info.GoVersion)
+		fmt.Fprintf(w, "  logging client: %s\n", info.LoggingVersion)
+	case "json":
+		jsonBytes, err := json.Marshal(info)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(w, string(jsonBytes))
+	default:
+		return fmt.Errorf("invalid --format %q, valid values: text, json", format)
+	}
+	return nil
+}
+
+// currentVersionInfo merges the ldflags metadata with what the Go toolchain embedded in the binary
+func currentVersionInfo() versionInfo {
+	info := versionInfo{
+		Version:        version,
+		Commit:         commit,
+		BuildDate:      buildDate,
+		GoVersion:      runtime.Version(),
+		LoggingVersion: logadmin.Version,
+	}
+
+	buildInfo, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	// Binaries built with `go install module@version` carry the version but no ldflags.
+	if info.Version == "dev" && buildInfo.Main.Version != "" && buildInfo.Main.Version != "(devel)" {
+		info.Version = buildInfo.Main.Version
+	}
+	for _, setting := range buildInfo.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "unknown":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.BuildDate == "unknown":
+			info.BuildDate = setting.Value
+		}
+	}
+	for _, dep := range buildInfo.Deps {
+		if dep.Path == loggingModulePath {
+			info.LoggingVersion = dep.Version
+		}
+	}
+
+	return info
+}
+
+func init() {
+	versionCmd.Flags().String("format", "text", "output format, valid values: text, json")
+
+	rootCmd.AddCommand(versionCmd)
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPrintVersion(t *testing.T) {
+	info := versionInfo{Version: "v1.2.3", Commit: "abc123", BuildDate: "2025-01-01T00:00:00Z", GoVersion: "go1.23.0", LoggingVersion: "v1.13.0"}
+
+	var buf bytes.Buffer
+	if err := printVersion(&buf, info, "json"); err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &fields); err != nil {
+		t.Fatalf("printVersion() printed invalid JSON %q: %v", buf.String(), err)
+	}
+	expected := map[string]string{"version": "v1.2.3", "commit": "abc123", "buildDate": "2025-01-01T00:00:00Z", "goVersion": "go1.23.0", "loggingClientVersion": "v1.13.0"}
+	for key, value := range expected {
+		if fields[key] != value {
+			t.Errorf("printVersion() %s = %q, want %q", key, fields[key], value)
+		}
+	}
+	if len(fields) != len(expected) || !strings.HasSuffix(buf.String(), "}\n") {
+		t.Errorf("printVersion() = %q, want a JSON line of %d fields", buf.String(), len(expected))
+	}
+
+	buf.Reset()
+	if err := printVersion(&buf, info, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), cliName+" v1.2.3\n") || !strings.Contains(buf.String(), "commit:         abc123\n") {
+		t.Errorf("printVersion() = %q", buf.String())
+	}
+
+	if err := printVersion(&buf, info, "yaml"); err == nil {
+		t.Error("printVersion() succeeded with --format yaml, want an error")
+	}
+}
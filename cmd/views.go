@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+)
+
+var viewsCmd = &cobra.Command{
+	Use:   "views",
+	Short: "Manage log views",
+	Long:  `Manage the log views of a bucket`,
+}
+
+var viewsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the log views of a bucket",
+	Long:  `List the log views of a bucket as JSON lines`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		location := cmd.Flag("location").Value.String()
+		bucket := cmd.Flag("bucket").Value.String()
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		cobra.CheckErr(printAll(client.Views(ctx, location, bucket).Next))
+	},
+}
+
+var viewsCreateCmd = &cobra.Command{
+	Use:   "create VIEW_ID",
+	Short: "Create a log view",
+	Long:  `Create a log view restricting a bucket to the entries matching --filter`,
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		location := cmd.Flag("location").Value.String()
+		bucket := cmd.Flag("bucket").Value.String()
+
+		view := &loggingpb.LogView{
+			Description: cmd.Flag("description").Value.String(),
+			Filter:      expandProjectReferences(cmd.Flag("filter").Value.String(), projectAliases()),
+		}
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		created, err := client.CreateView(ctx, location, bucket, args[0], view)
+		cobra.CheckErr(err)
+		cobra.CheckErr(printJSON(created))
+	},
+}
+
+var viewsDeleteCmd = &cobra.Command{
+	Use:   "delete VIEW_ID",
+	Short: "Delete a log view",
+	Long: `Delete a log view.
+
+Unless --yes is given, the deletion must be confirmed interactively.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+		location := cmd.Flag("location").Value.String()
+		bucket := cmd.Flag("bucket").Value.String()
+
+		yes, err := cmd.Flags().GetBool("yes")
+		cobra.CheckErr(err)
+
+		if !yes && !confirm(fmt.Sprintf("Delete view %s of bucket %s in project %s?", args[0], bucket, projectId)) {
+			log.Println("Aborted")
+			return
+		}
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		cobra.CheckErr(client.DeleteView(ctx, location, bucket, args[0]))
+		log.Printf("Deleted view %s", args[0])
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{viewsListCmd, viewsCreateCmd, viewsDeleteCmd} {
+		c.Flags().String("location", "global", "location of the bucket")
+		c.Flags().String("bucket", "", "ID of the bucket owning the views")
+		c.MarkFlagRequired("bucket")
+	}
+	viewsCreateCmd.Flags().String("description", "", "description of the view")
+	viewsCreateCmd.Flags().String("filter", "", "filter restricting the entries visible through the view")
+	viewsDeleteCmd.Flags().Bool("yes", false, "skip the confirmation prompt")
+
+	viewsCmd.AddCommand(viewsListCmd, viewsCreateCmd, viewsDeleteCmd)
+	rootCmd.AddCommand(viewsCmd)
+}
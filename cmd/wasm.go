@@ -0,0 +1,199 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// errModuleExited fails the writes to a module that exited
+var errModuleExited = errors.New("the module exited")
+
+// wasmProcessor runs a WebAssembly module, with the WASI runtime embedded in grapple, as a processor of
+// the entries: the module reads each entry as a JSON line on its standard input and answers with a line
+// on its standard output, the entry to hand over (as is or transformed) or an empty line to drop it. The
+// module gets no access to files, network, environment or the real clock, and must answer each entry
+// within timeout.
+type wasmProcessor struct {
+	module  string
+	timeout time.Duration
+	runtime wazero.Runtime
+	// cancel stops the module, see terminate.
+	cancel     context.CancelFunc
+	terminated bool
+	stdin      *io.PipeWriter
+	// answers are the lines of the standard output of the module, closed when it ends.
+	answers chan string
+	// exited is closed once the module returned, with its failure in exitErr.
+	exited  chan struct{}
+	exitErr error
+	next    func(*loggingpb.LogEntry) error
+	dropped int
+	// err is the failure of the module that stopped the processor.
+	err error
+}
+
+// newWASMProcessor returns the processor of --wasm-processor, or nil if it is not set
+func newWASMProcessor(cmd *cobra.Command, next func(*loggingpb.LogEntry) error) (*wasmProcessor, error) {
+	module := cmd.Flag("wasm-processor").Value.String()
+	if module == "" {
+		return nil, nil
+	}
+	timeout, err := cmd.Flags().GetDuration("wasm-timeout")
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("invalid --wasm-timeout %s", timeout)
+	}
+	return startWASMProcessor(module, timeout, next)
+}
+
+// startWASMProcessor compiles the module and starts it
+func startWASMProcessor(module string, timeout time.Duration, next func(*loggingpb.LogEntry) error) (*wasmProcessor, error) {
+	binary, err := os.ReadFile(module)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --wasm-processor: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+	compiled, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		cancel()
+		runtime.Close(context.Background())
+		return nil, fmt.Errorf("invalid --wasm-processor %s: %w", module, err)
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+	p := &wasmProcessor{
+		module:  module,
+		timeout: timeout,
+		runtime: runtime,
+		cancel:  cancel,
+		stdin:   stdinWriter,
+		answers: make(chan string),
+		exited:  make(chan struct{}),
+		next:    next,
+	}
+	config := wazero.NewModuleConfig().WithName(module).WithStdin(stdinReader).WithStdout(stdoutWriter).WithStderr(os.Stderr)
+	go func() {
+		// Instantiating runs the _start function of the module, until it returns.
+		_, p.exitErr = runtime.InstantiateModule(ctx, compiled, config)
+		stdinReader.CloseWithError(errModuleExited)
+		stdoutWriter.Close()
+		close(p.exited)
+	}()
+	go func() {
+		defer close(p.answers)
+		lines := bufio.NewReader(stdoutReader)
+		for {
+			line, err := lines.ReadString('\n')
+			if err != nil {
+				return
+			}
+			p.answers <- line
+		}
+	}()
+	return p, nil
+}
+
+func (p *wasmProcessor) process(entry *loggingpb.LogEntry) error {
+	if p.err != nil {
+		return grapple.ErrStop
+	}
+	line, err := protojson.MarshalOptions{Multiline: false}.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	deadline := time.NewTimer(p.timeout)
+	defer deadline.Stop()
+	written := make(chan error, 1)
+	go func() {
+		_, err := p.stdin.Write(append(line, '\n'))
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			p.err = fmt.Errorf("WASM processor %s stopped reading: %w", p.module, err)
+			return grapple.ErrStop
+		}
+	case <-deadline.C:
+		return p.stop(fmt.Errorf("WASM processor %s did not read entry %s within %s", p.module, entry.InsertId, p.timeout))
+	}
+	var answer string
+	select {
+	case a, ok := <-p.answers:
+		if !ok {
+			p.err = fmt.Errorf("WASM processor %s did not answer for entry %s: %w", p.module, entry.InsertId, io.ErrUnexpectedEOF)
+			return grapple.ErrStop
+		}
+		answer = a
+	case <-deadline.C:
+		return p.stop(fmt.Errorf("WASM processor %s did not answer for entry %s within %s", p.module, entry.InsertId, p.timeout))
+	}
+
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		p.dropped++
+		return nil
+	}
+	transformed := &loggingpb.LogEntry{}
+	if err := protojson.Unmarshal([]byte(answer), transformed); err != nil {
+		return fmt.Errorf("invalid entry from the WASM processor: %w", err)
+	}
+	return p.next(transformed)
+}
+
+// stop terminates the module after err, stopping the fetch
+func (p *wasmProcessor) stop(err error) error {
+	p.err = err
+	p.terminate()
+	return grapple.ErrStop
+}
+
+// terminate stops the module, e.g. when it does not answer in time
+func (p *wasmProcessor) terminate() {
+	p.terminated = true
+	p.cancel()
+}
+
+// close ends the input of the module and waits for it to exit, terminating it after the timeout,
+// and returns the failure that stopped it, if any
+func (p *wasmProcessor) close() error {
+	p.stdin.Close()
+	select {
+	case <-p.exited:
+	case <-time.After(p.timeout):
+		p.terminate()
+		<-p.exited
+		if p.err == nil {
+			p.err = fmt.Errorf("WASM processor %s did not exit within %s", p.module, p.timeout)
+		}
+	}
+	for range p.answers {
+		// The answers left unread end with the output of the module.
+	}
+	p.cancel()
+	var err error
+	// The failure of a terminated module is the termination, p.err reports why.
+	if p.exitErr != nil && !p.terminated {
+		err = fmt.Errorf("WASM processor %s: %w", p.module, p.exitErr)
+	}
+	return errors.Join(p.err, err, p.runtime.Close(context.Background()))
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+)
+
+// The modules of testdata are assembled from the .wat files next to them.
+
+func TestWASMProcessor(t *testing.T) {
+	var printed []string
+	p, err := startWASMProcessor("testdata/echo.wasm", time.Second, func(entry *loggingpb.LogEntry) error {
+		printed = append(printed, entry.InsertId)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := p.process(&loggingpb.LogEntry{InsertId: id}); err != nil {
+			t.Fatalf("process(%s) error = %v", id, err)
+		}
+	}
+	if err := p.close(); err != nil {
+		t.Fatalf("close() error = %v", err)
+	}
+	if expected := []string{"a", "b"}; !reflect.DeepEqual(printed, expected) {
+		t.Errorf("printed %v, want %v", printed, expected)
+	}
+
+	p, err = startWASMProcessor("testdata/drop.wasm", time.Second, func(entry *loggingpb.LogEntry) error {
+		t.Errorf("entry %s handed over after an empty answer", entry.InsertId)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b"} {
+		if err := p.process(&loggingpb.LogEntry{InsertId: id}); err != nil {
+			t.Fatalf("process(%s) error = %v", id, err)
+		}
+	}
+	if err := p.close(); err != nil || p.dropped != 2 {
+		t.Errorf("close() = %v with %d dropped, want 2 dropped", err, p.dropped)
+	}
+}
+
+func TestWASMProcessorExited(t *testing.T) {
+	p, err := startWASMProcessor("testdata/exit.wasm", time.Second, func(*loggingpb.LogEntry) error {
+		t.Error("entry handed over by a module that exited")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.process(&loggingpb.LogEntry{InsertId: "a"}); !errors.Is(err, grapple.ErrStop) {
+		t.Fatalf("process() error = %v, want grapple.ErrStop", err)
+	}
+	if err := p.close(); err == nil || !strings.Contains(err.Error(), "exit_code(3)") {
+		t.Errorf("close() error = %v, want the exit code of the module", err)
+	}
+}
+
+func TestWASMProcessorTimeout(t *testing.T) {
+	p, err := startWASMProcessor("testdata/spin.wasm", 50*time.Millisecond, func(*loggingpb.LogEntry) error {
+		t.Error("entry handed over by a module that never answers")
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.process(&loggingpb.LogEntry{InsertId: "a"}); !errors.Is(err, grapple.ErrStop) {
+		t.Fatalf("process() error = %v, want grapple.ErrStop", err)
+	}
+	if err := p.close(); err == nil || !strings.Contains(err.Error(), "within 50ms") {
+		t.Errorf("close() error = %v, want the timeout", err)
+	}
+}
+
+func TestWASMProcessorInvalid(t *testing.T) {
+	if _, err := startWASMProcessor("testdata/echo.wat", time.Second, nil); err == nil {
+		t.Error("startWASMProcessor() of a text file succeeded")
+	}
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+)
+
+// defaultWatchInterval is the polling interval of a bare --watch
+const defaultWatchInterval = "10s"
+
+// watchIntervalFlag returns the --watch interval, zero when not watching
+func watchIntervalFlag(cmd *cobra.Command) (time.Duration, error) {
+	value := cmd.Flag("watch").Value.String()
+	if value == "" {
+		return 0, nil
+	}
+	interval, err := parseFreshness(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid --watch: %w", err)
+	}
+	return interval, nil
+}
+
+// minSeenPrune is the number of remembered entries below which a watermark does not bother pruning
+const minSeenPrune = 1024
+
+// watermark remembers the newest timestamp (in windowField) printed so far, when the last poll started, and the entries
+// printed within retain of the newest timestamp, as polls can reach back that far (see grapple.WindowStrategy)
+type watermark struct {
+	timestamp time.Time
+	polled    time.Time
+	retain    time.Duration
+	seen      map[string]time.Time
+	pruneAt   int
+}
+
+func newWatermark(now time.Time, retain time.Duration) *watermark {
+	return &watermark{timestamp: now, polled: now, retain: retain, seen: map[string]time.Time{}, pruneAt: minSeenPrune}
+}
+
+func entryKey(entry *loggingpb.LogEntry) string {
+	return entry.LogName + "\x00" + entry.InsertId
+}
+
+// horizon is the oldest timestamp of the remembered entries, older ones are never polled again
+func (w *watermark) horizon() time.Time {
+	return w.timestamp.Add(-w.retain)
+}
+
+// record moves the watermark forward to the entry, if newer, and remembers it
+func (w *watermark) record(entry *loggingpb.LogEntry) {
+	ts, ok := windowTime(entry, windowField)
+	if !ok {
+		return
+	}
+	if ts.After(w.timestamp) {
+		w.timestamp = ts
+	}
+	if ts.Before(w.horizon()) {
+		return
+	}
+	w.seen[entryKey(entry)] = ts
+	if len(w.seen) >= w.pruneAt {
+		horizon := w.horizon()
+		for key, seen := range w.seen {
+			if seen.Before(horizon) {
+				delete(w.seen, key)
+			}
+		}
+		w.pruneAt = max(2*len(w.seen), minSeenPrune)
+	}
+}
+
+// from returns where the next poll starts according to the strategy
+func (w *watermark) from(strategy grapple.WindowStrategy) time.Time {
+	return strategy.From(w.polled, w.timestamp)
+}
+
+// covers reports whether the entry is older than the horizon or was already printed
+func (w *watermark) covers(entry *loggingpb.LogEntry) bool {
+	ts, ok := windowTime(entry, windowField)
+	if !ok {
+		return false
+	}
+	if ts.Before(w.horizon()) {
+		return true
+	}
+	_, ok = w.seen[entryKey(entry)]
+	return ok
+}
+
+// keys returns the sorted keys of the entries printed since the horizon, for checkpoints
+func (w *watermark) keys() []string {
+	horizon := w.horizon()
+	keys := make([]string, 0, len(w.seen))
+	for key, seen := range w.seen {
+		if !seen.Before(horizon) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// windowClause returns the clause matching the entries from the given time on, in windowField
+func windowClause(from time.Time) string {
+	return fmt.Sprintf("%s >= %q", windowField, from.Format(time.RFC3339Nano))
+}
+
+// filterSetting reports whether a setting of the config file is one of the filters composed again by
+// watchEntries after the config file changes: alwaysFilter and the aliases
+func filterSetting(key string) bool {
+	return key == "alwaysfilter" || strings.HasPrefix(key, "aliases.")
+}
+
+// outputSetting reports whether a setting of the config file changes how the entries are printed
+func outputSetting(key string) bool {
+	switch key {
+	case "format", "timezone", "jsonprotonames", "jsonemitdefaults", "jsonenumnumbers":
+		return true
+	}
+	return false
+}
+
+// reloadedSetting reports whether a setting of the config file applies to --watch as soon as the file
+// changes: the filters, the output settings, the --notify target and the profiles, whose settings are
+// merged over the others. The other settings, like the project, apply after a restart.
+func reloadedSetting(key string) bool {
+	return filterSetting(key) || outputSetting(key) || key == notifyKey || key == profileKey || strings.HasPrefix(key, profilesKey+".")
+}
+
+// watchEntries polls for new entries every interval, oldest first, until ctx is done, starting each poll
+// where the strategy says and skipping the entries the watermark covers.
+// After the config file changes, the filter is composed again, so that e.g. a new alwaysFilter applies
+// right away, and reloaded is called with the changed settings, between two polls. config is nil when
+// there is no config file.
+func watchEntries(ctx context.Context, client *logadmin.Client, baseOpts []logadmin.EntriesOption, interval time.Duration, mark *watermark, strategy grapple.WindowStrategy, config *configFile, compose func() (string, error), reloaded func(changed []string), process func(*loggingpb.LogEntry) error, polled func()) (int, error) {
+	filter, err := compose()
+	if err != nil {
+		return 0, err
+	}
+
+	newEntries := func(entry *loggingpb.LogEntry) error {
+		if mark.covers(entry) {
+			return nil
+		}
+		return process(entry)
+	}
+
+	count := 0
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return count, nil
+		case <-config.changed():
+			changed, err := config.reload(reloadedSetting)
+			if err != nil {
+				log.Printf("Error: ignoring config change: %v", err)
+				continue
+			}
+			if slices.ContainsFunc(changed, filterSetting) {
+				if updated, err := compose(); err != nil {
+					log.Printf("Error: keeping the previous filter: %v", err)
+				} else {
+					filter = updated
+				}
+			}
+			if len(changed) > 0 {
+				reloaded(changed)
+			}
+			continue
+		case <-ticker.C:
+		}
+
+		started := time.Now()
+		opts := append(slices.Clone(baseOpts), logadmin.Filter(andFilters(filter, windowClause(mark.from(strategy)))))
+		n, err := fetchAndProcessLogs(ctx, client, opts, newEntries)
+		count += n
+		if err != nil {
+			return count, err
+		}
+		mark.polled = started
+		polled()
+	}
+}
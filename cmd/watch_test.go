@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWatermark(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(id string, offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{LogName: "l", InsertId: id, Timestamp: timestamppb.New(start.Add(offset))}
+	}
+
+	mark := newWatermark(start, 0)
+	mark.record(entry("a", time.Second))
+	mark.record(entry("b", 2*time.Second))
+	mark.record(entry("c", 2*time.Second))
+	mark.record(entry("old", -time.Second))
+
+	cases := []struct {
+		entry    *loggingpb.LogEntry
+		expected bool
+	}{
+		{entry("a", time.Second), true},
+		{entry("b", 2*time.Second), true},
+		{entry("d", 2*time.Second), false},
+		{entry("e", 3*time.Second), false},
+	}
+	for _, c := range cases {
+		if got := mark.covers(c.entry); got != c.expected {
+			t.Errorf("covers(%s) = %v, want %v", c.entry.InsertId, got, c.expected)
+		}
+	}
+
+	expected := `timestamp >= "2025-01-01T00:00:02Z"`
+	if got := windowClause(mark.timestamp); got != expected {
+		t.Errorf("windowClause() = %q, want %q", got, expected)
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"github.com/spf13/cobra"
+)
+
+// Window strategies of --watch-window.
+const (
+	windowWatermark = "watermark"
+	windowFixed     = "fixed"
+	windowSliding   = "sliding"
+)
+
+// windowStrategyFlag returns the strategy deciding where each poll of --watch starts, selected by
+// --watch-window and --watch-overlap
+func windowStrategyFlag(cmd *cobra.Command) (grapple.WindowStrategy, error) {
+	overlap, err := cmd.Flags().GetDuration("watch-overlap")
+	if err != nil {
+		return nil, err
+	}
+	if overlap < 0 {
+		return nil, errors.New("--watch-overlap cannot be negative")
+	}
+	switch name := cmd.Flag("watch-window").Value.String(); name {
+	case windowWatermark:
+		return grapple.WatermarkWindow{Slack: overlap}, nil
+	case windowSliding:
+		if overlap == 0 {
+			return nil, errors.New("--watch-window sliding requires a positive --watch-overlap")
+		}
+		return grapple.SlidingWindow{Lookback: overlap}, nil
+	case windowFixed:
+		if overlap > 0 {
+			return nil, errors.New("--watch-overlap cannot be used with --watch-window fixed")
+		}
+		return grapple.FixedWindow{}, nil
+	default:
+		return nil, fmt.Errorf("invalid --watch-window %q, valid values: %s, %s, %s", name, windowWatermark, windowFixed, windowSliding)
+	}
+}
+
+// Fields of --window-field.
+const (
+	fieldTimestamp        = "timestamp"
+	fieldReceiveTimestamp = "receiveTimestamp"
+)
+
+// windowField is the entry field the time windows apply to, set from --window-field by runQueryInto
+var windowField = fieldTimestamp
+
+// windowFieldFlag returns the field selected by --window-field
+func windowFieldFlag(cmd *cobra.Command) (string, error) {
+	switch name := cmd.Flag("window-field").Value.String(); name {
+	case fieldTimestamp, fieldReceiveTimestamp:
+		return name, nil
+	default:
+		return "", fmt.Errorf("invalid --window-field %q, valid values: %s, %s", name, fieldTimestamp, fieldReceiveTimestamp)
+	}
+}
+
+// windowTime returns the time of the entry in field, false when missing
+func windowTime(entry *loggingpb.LogEntry, field string) (time.Time, bool) {
+	ts := entry.Timestamp
+	if field == fieldReceiveTimestamp {
+		ts = entry.ReceiveTimestamp
+	}
+	if ts == nil {
+		return time.Time{}, false
+	}
+	return ts.AsTime(), true
+}
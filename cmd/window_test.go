@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/pkg/grapple"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestWatermarkRetain(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	entry := func(id string, offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{LogName: "l", InsertId: id, Timestamp: timestamppb.New(start.Add(offset))}
+	}
+
+	mark := newWatermark(start, time.Minute)
+	mark.record(entry("a", 10*time.Second))
+	mark.record(entry("b", 2*time.Minute))
+	mark.record(entry("late", 90*time.Second))
+
+	cases := []struct {
+		entry    *loggingpb.LogEntry
+		expected bool
+	}{
+		{entry("a", 10*time.Second), true},
+		{entry("old", 30*time.Second), true},
+		{entry("late", 90*time.Second), true},
+		{entry("later", 80*time.Second), false},
+		{entry("b", 2*time.Minute), true},
+	}
+	for _, c := range cases {
+		if got := mark.covers(c.entry); got != c.expected {
+			t.Errorf("covers(%s) = %v, want %v", c.entry.InsertId, got, c.expected)
+		}
+	}
+
+	if got, expected := mark.keys(), []string{"l\x00b", "l\x00late"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("keys() = %q, want %q", got, expected)
+	}
+}
+
+func TestWindowStrategies(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	mark := newWatermark(start, 0)
+	mark.timestamp = start.Add(time.Minute)
+	mark.polled = start.Add(2 * time.Minute)
+
+	cases := []struct {
+		strategy grapple.WindowStrategy
+		from     time.Time
+	}{
+		{grapple.WatermarkWindow{}, start.Add(time.Minute)},
+		{grapple.WatermarkWindow{Slack: 30 * time.Second}, start.Add(30 * time.Second)},
+		{grapple.FixedWindow{}, start.Add(2 * time.Minute)},
+		{grapple.SlidingWindow{Lookback: 5 * time.Minute}, start.Add(-3 * time.Minute)},
+	}
+	for _, c := range cases {
+		if got := mark.from(c.strategy); !got.Equal(c.from) {
+			t.Errorf("from(%T) = %s, want %s", c.strategy, got, c.from)
+		}
+	}
+}
+
+func TestWindowFieldReceiveTimestamp(t *testing.T) {
+	defer func() { windowField = fieldTimestamp }()
+	windowField = fieldReceiveTimestamp
+
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	expected := `(severity>=ERROR) AND receiveTimestamp >= "2025-01-01T00:00:00Z" AND receiveTimestamp <= "2025-01-01T01:00:00Z"`
+	if got := buildFilter(from, to, "severity>=ERROR"); got != expected {
+		t.Errorf("buildFilter() = %q, want %q", got, expected)
+	}
+	if got, expected := windowClause(from), `receiveTimestamp >= "2025-01-01T00:00:00Z"`; got != expected {
+		t.Errorf("windowClause() = %q, want %q", got, expected)
+	}
+
+	// The producer clock is an hour behind: the entry is windowed on its receipt.
+	skewed := &loggingpb.LogEntry{
+		LogName:          "l",
+		InsertId:         "a",
+		Timestamp:        timestamppb.New(from.Add(-time.Hour)),
+		ReceiveTimestamp: timestamppb.New(from.Add(10 * time.Minute)),
+	}
+	mark := newWatermark(from, time.Minute)
+	mark.record(skewed)
+	if !mark.timestamp.Equal(from.Add(10 * time.Minute)) {
+		t.Errorf("watermark = %s, want the receiveTimestamp", mark.timestamp)
+	}
+	if !mark.covers(skewed) {
+		t.Error("covers() = false for a recorded entry")
+	}
+
+	m := newExportManifest("p", "", nil, from, to, time.Hour)
+	m.observe(skewed)
+	if m.WindowField != fieldReceiveTimestamp || m.Windows[0].Entries != 1 {
+		t.Errorf("manifest windowField = %q with %d entries, want receiveTimestamp with 1", m.WindowField, m.Windows[0].Entries)
+	}
+
+	if got, expected := renderPrefix(skewed, false), "2024-12-31T23:00:00.000Z +1h10m0s DEFAULT  "; got != expected {
+		t.Errorf("renderPrefix() = %q, want %q", got, expected)
+	}
+}
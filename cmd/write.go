@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/dippi/grapple/internal/logadmin"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+var writeCmd = &cobra.Command{
+	Use:   "write",
+	Short: "Write log entries",
+	Long: `Write log entries to Google Cloud Logging.
+
+Without --message, entries are read from stdin as JSON lines in the same
+format printed by grapple, so the output of a query can be written back.
+The logName and receiveTimestamp of the input entries are ignored: every
+entry goes to the log selected with --log.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		projectId := requireProject()
+
+		logID, err := cmd.Flags().GetString("log")
+		cobra.CheckErr(err)
+		resourceType, err := cmd.Flags().GetString("resource-type")
+		cobra.CheckErr(err)
+		batchSize, err := cmd.Flags().GetInt("batch-size")
+		cobra.CheckErr(err)
+		if batchSize <= 0 {
+			cobra.CheckErr(fmt.Errorf("invalid --batch-size %d", batchSize))
+		}
+
+		entries, err := writeInput(cmd, os.Stdin)
+		cobra.CheckErr(err)
+
+		if len(entries) == 0 {
+			log.Println("Nothing to write")
+			return
+		}
+
+		ctx := cmd.Context()
+
+		client, err := newLogadminClient(ctx, projectId)
+		cobra.CheckErr(err)
+		defer client.Close()
+
+		resource := &monitoredres.MonitoredResource{Type: resourceType}
+		cobra.CheckErr(writeBatches(ctx, client, logID, resource, entries, batchSize))
+		log.Printf("Wrote %d entries to log %s", len(entries), logID)
+	},
+}
+
+// writeInput returns the entries to write: the entry of --message and --severity, or the entries read from stdin
+func writeInput(cmd *cobra.Command, stdin io.Reader) ([]*loggingpb.LogEntry, error) {
+	if !cmd.Flags().Changed("message") {
+		return readEntries(stdin)
+	}
+	message, err := cmd.Flags().GetString("message")
+	if err != nil {
+		return nil, err
+	}
+	severityFlag, err := cmd.Flags().GetString("severity")
+	if err != nil {
+		return nil, err
+	}
+	severity, err := parseSeverity(severityFlag)
+	if err != nil {
+		return nil, err
+	}
+	return []*loggingpb.LogEntry{{
+		Payload:  &loggingpb.LogEntry_TextPayload{TextPayload: message},
+		Severity: severity,
+	}}, nil
+}
+
+// writeBatches writes the entries to the log in requests of at most batchSize entries
+func writeBatches(ctx context.Context, client *logadmin.Client, logID string, resource *monitoredres.MonitoredResource, entries []*loggingpb.LogEntry, batchSize int) error {
+	for start := 0; start < len(entries); start += batchSize {
+		end := min(start+batchSize, len(entries))
+		if err := client.WriteEntries(ctx, logID, resource, entries[start:end]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readEntries parses JSON lines into log entries, skipping blank lines
+func readEntries(r io.Reader) ([]*loggingpb.LogEntry, error) {
+	var entries []*loggingpb.LogEntry
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		entry := &loggingpb.LogEntry{}
+		if err := protojson.Unmarshal([]byte(text), entry); err != nil {
+			return nil, fmt.Errorf("invalid entry on line %d: %w", line, err)
+		}
+		entry.LogName = ""
+		entry.ReceiveTimestamp = nil
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// parseSeverity converts a severity name like "warning" into its LogSeverity value
+func parseSeverity(name string) (logtypepb.LogSeverity, error) {
+	value, ok := logtypepb.LogSeverity_value[strings.ToUpper(name)]
+	if !ok {
+		return 0, fmt.Errorf("invalid severity %q", name)
+	}
+	return logtypepb.LogSeverity(value), nil
+}
+
+func init() {
+	writeCmd.Flags().String("log", cliName, "ID of the log to write to")
+	writeCmd.Flags().String("resource-type", "global", "monitored resource type for entries without a resource")
+	writeCmd.Flags().String("message", "", "write a single text entry instead of reading stdin")
+	writeCmd.Flags().String("severity", "default", "severity of the --message entry (e.g. info, warning, error)")
+	writeCmd.Flags().Int("batch-size", 500, "maximum number of entries per write request")
+
+	rootCmd.AddCommand(writeCmd)
+}
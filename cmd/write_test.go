@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/api/monitoredres"
+	logtypepb "google.golang.org/genproto/googleapis/logging/type"
+	"google.golang.org/grpc"
+)
+
+// fakeWrites records the write requests
+type fakeWrites struct {
+	loggingpb.UnimplementedLoggingServiceV2Server
+	requests []*loggingpb.WriteLogEntriesRequest
+}
+
+func (f *fakeWrites) WriteLogEntries(ctx context.Context, req *loggingpb.WriteLogEntriesRequest) (*loggingpb.WriteLogEntriesResponse, error) {
+	f.requests = append(f.requests, req)
+	return &loggingpb.WriteLogEntriesResponse{}, nil
+}
+
+func TestReadEntries(t *testing.T) {
+	input := `{"insertId": "a", "logName": "projects/other/logs/stdout", "receiveTimestamp": "2025-01-02T15:04:06Z", "severity": "ERROR", "textPayload": "boom"}
+
+  {"insertId": "b", "jsonPayload": {"message": "ok"}, "labels": {"team": "web"}}
+`
+	entries, err := readEntries(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].InsertId != "a" || entries[1].InsertId != "b" {
+		t.Fatalf("readEntries() = %v, want a and b", entries)
+	}
+	if entries[0].LogName != "" || entries[0].ReceiveTimestamp != nil {
+		t.Errorf("readEntries() kept logName %q and receiveTimestamp %v, want them ignored", entries[0].LogName, entries[0].ReceiveTimestamp)
+	}
+	if entries[0].Severity != logtypepb.LogSeverity_ERROR || entries[0].GetTextPayload() != "boom" || entries[1].Labels["team"] != "web" {
+		t.Errorf("readEntries() = %v", entries)
+	}
+
+	if _, err := readEntries(strings.NewReader("{\"insertId\": \"a\"}\n\n{nope}\n")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("readEntries() = %v, want an error on line 3", err)
+	}
+}
+
+func TestWriteInput(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().String("message", "", "")
+	cmd.Flags().String("severity", "default", "")
+
+	entries, err := writeInput(cmd, strings.NewReader(`{"insertId": "a"}`))
+	if err != nil || len(entries) != 1 || entries[0].InsertId != "a" {
+		t.Errorf("writeInput() without --message = %v, %v, want the entry of stdin", entries, err)
+	}
+
+	cmd.Flags().Set("message", "hello")
+	cmd.Flags().Set("severity", "warning")
+	entries, err = writeInput(cmd, strings.NewReader("not read"))
+	if err != nil || len(entries) != 1 || entries[0].GetTextPayload() != "hello" || entries[0].Severity != logtypepb.LogSeverity_WARNING {
+		t.Errorf("writeInput() with --message = %v, %v, want a warning with the text", entries, err)
+	}
+
+	cmd.Flags().Set("severity", "loud")
+	if _, err := writeInput(cmd, nil); err == nil {
+		t.Error("writeInput() succeeded with --severity loud, want an error")
+	}
+}
+
+func TestWriteBatches(t *testing.T) {
+	server := &fakeWrites{}
+	client := newFakeClient(t, func(s *grpc.Server) { loggingpb.RegisterLoggingServiceV2Server(s, server) })
+
+	var entries []*loggingpb.LogEntry
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		entries = append(entries, &loggingpb.LogEntry{InsertId: id})
+	}
+	resource := &monitoredres.MonitoredResource{Type: "global"}
+	if err := writeBatches(context.Background(), client, "app/events", resource, entries, 2); err != nil {
+		t.Fatal(err)
+	}
+	if len(server.requests) != 3 {
+		t.Fatalf("writeBatches() sent %d requests, want 3 of at most 2 entries", len(server.requests))
+	}
+	for i, size := range []int{2, 2, 1} {
+		req := server.requests[i]
+		if len(req.Entries) != size || req.LogName != "projects/p/logs/app%2Fevents" || req.Resource.GetType() != "global" {
+			t.Errorf("request %d = %v, want %d entries of the escaped log and the resource", i, req, size)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package cluster groups log messages by template, replacing the parts that vary between
+// occurrences of the same message, like numbers and identifiers, with placeholders.
+package cluster
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Placeholders of the variable parts of a message.
+const (
+	PlaceholderUUID   = "<uuid>"
+	PlaceholderHex    = "<hex>"
+	PlaceholderNumber = "<num>"
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+	hexPattern    = regexp.MustCompile(`\b(?:0x)?[0-9a-fA-F]{8,}\b`)
+	numberPattern = regexp.MustCompile(`\d+(?:\.\d+)?`)
+	spacePattern  = regexp.MustCompile(`\s+`)
+)
+
+// Normalize returns the template of a message: UUIDs, hex IDs and numbers are replaced
+// with placeholders and whitespace is collapsed.
+func Normalize(message string) string {
+	message = uuidPattern.ReplaceAllString(message, PlaceholderUUID)
+	message = hexPattern.ReplaceAllStringFunc(message, func(s string) string {
+		// Words made of hex letters only, like "deadbeef" or "accepted", are left alone.
+		if strings.ContainsAny(s, "0123456789") {
+			return PlaceholderHex
+		}
+		return s
+	})
+	message = numberPattern.ReplaceAllString(message, PlaceholderNumber)
+	return strings.TrimSpace(spacePattern.ReplaceAllString(message, " "))
+}
+
+// Cluster is a group of messages sharing a template.
+type Cluster[T any] struct {
+	Template string
+	Count    int
+	// Example is the first item added to the cluster.
+	Example T
+}
+
+// Counter groups items by the template of their message.
+type Counter[T any] struct {
+	clusters map[string]*Cluster[T]
+	total    int
+}
+
+// NewCounter returns an empty counter.
+func NewCounter[T any]() *Counter[T] {
+	return &Counter[T]{clusters: map[string]*Cluster[T]{}}
+}
+
+// Add counts an item in the cluster of its message.
+func (c *Counter[T]) Add(message string, item T) {
+	c.total++
+	template := Normalize(message)
+	cluster, ok := c.clusters[template]
+	if !ok {
+		cluster = &Cluster[T]{Template: template, Example: item}
+		c.clusters[template] = cluster
+	}
+	cluster.Count++
+}
+
+// Total returns the number of items added.
+func (c *Counter[T]) Total() int {
+	return c.total
+}
+
+// Top returns the n largest clusters, all of them when n is not positive.
+// Ties are broken by template.
+func (c *Counter[T]) Top(n int) []*Cluster[T] {
+	clusters := make([]*Cluster[T], 0, len(c.clusters))
+	for _, cluster := range c.clusters {
+		clusters = append(clusters, cluster)
+	}
+	slices.SortFunc(clusters, func(a, b *Cluster[T]) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return strings.Compare(a.Template, b.Template)
+	})
+	if n > 0 && len(clusters) > n {
+		clusters = clusters[:n]
+	}
+	return clusters
+}
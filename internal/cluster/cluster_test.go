@@ -0,0 +1,39 @@
+package cluster
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"user 1234 logged in after 1.5s", "user <num> logged in after <num>s"},
+		{"request 3f2a9c1e-7b4d-4c8e-9a1f-2b3c4d5e6f70 failed", "request <uuid> failed"},
+		{"commit 9fceb02d0ae598e95dc970b74767f19372d61af8 pushed", "commit <hex> pushed"},
+		{"pointer 0x7ffd5e8c3a10", "pointer <hex>"},
+		{"deadbeef accepted  by\tpeer", "deadbeef accepted by peer"},
+	}
+	for _, c := range cases {
+		if got := Normalize(c.input); got != c.expected {
+			t.Errorf("Normalize(%q) = %q, want %q", c.input, got, c.expected)
+		}
+	}
+}
+
+func TestCounter(t *testing.T) {
+	c := NewCounter[int]()
+	for i, message := range []string{"timeout after 5s", "timeout after 30s", "disk full", "timeout after 1s", "auth failed"} {
+		c.Add(message, i)
+	}
+
+	top := c.Top(2)
+	if len(top) != 2 || top[0].Template != "timeout after <num>s" || top[0].Count != 3 || top[0].Example != 0 {
+		t.Fatalf("Top(2)[0] = %+v", top[0])
+	}
+	if top[1].Template != "auth failed" {
+		t.Errorf("Top(2)[1] = %+v, want the tie broken by template", top[1])
+	}
+	if c.Total() != 5 || len(c.Top(0)) != 3 {
+		t.Errorf("Total() = %d, len(Top(0)) = %d", c.Total(), len(c.Top(0)))
+	}
+}
@@ -0,0 +1,102 @@
+// Package gcloud reads the properties of the active gcloud configuration from its files,
+// without running the gcloud CLI.
+package gcloud
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// ConfigDir returns the gcloud configuration directory, honoring CLOUDSDK_CONFIG.
+func ConfigDir() (string, error) {
+	if dir := os.Getenv("CLOUDSDK_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	if runtime.GOOS == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "gcloud"), nil
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "gcloud"), nil
+}
+
+// ActiveConfig returns the name of the active configuration of a gcloud configuration directory,
+// honoring CLOUDSDK_ACTIVE_CONFIG_NAME.
+func ActiveConfig(dir string) (string, error) {
+	if name := os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME"); name != "" {
+		return name, nil
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "active_config"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "default", nil
+	} else if err != nil {
+		return "", err
+	}
+	if name := strings.TrimSpace(string(data)); name != "" {
+		return name, nil
+	}
+	return "default", nil
+}
+
+// Property returns a property of the active configuration, e.g. ("core", "project"),
+// honoring the CLOUDSDK_SECTION_NAME environment variables like gcloud does.
+// It returns an empty string when the property is not set.
+func Property(section, name string) (string, error) {
+	if value := os.Getenv("CLOUDSDK_" + strings.ToUpper(section) + "_" + strings.ToUpper(name)); value != "" {
+		return value, nil
+	}
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	config, err := ActiveConfig(dir)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Join(dir, "configurations", "config_"+config))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readProperty(f, section, name)
+}
+
+// Project returns the core/project property of the active configuration.
+func Project() (string, error) {
+	return Property("core", "project")
+}
+
+// readProperty looks up a property in a configuration file, in INI format.
+func readProperty(r io.Reader, section, name string) (string, error) {
+	current := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = strings.TrimSpace(line[1 : len(line)-1])
+		case current == section:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				key, value, ok = strings.Cut(line, ":")
+			}
+			if ok && strings.TrimSpace(key) == name {
+				return strings.TrimSpace(value), nil
+			}
+		}
+	}
+	return "", scanner.Err()
+}
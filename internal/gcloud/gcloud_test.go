@@ -0,0 +1,75 @@
+package gcloud
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadProperty(t *testing.T) {
+	config := `# gcloud config
+[compute]
+region = europe-west1
+project = not-this-one
+
+[core]
+account = me@example.com
+project = my-project
+`
+	cases := []struct {
+		section, name, expected string
+	}{
+		{"core", "project", "my-project"},
+		{"compute", "region", "europe-west1"},
+		{"core", "region", ""},
+		{"auth", "project", ""},
+	}
+	for _, c := range cases {
+		got, err := readProperty(strings.NewReader(config), c.section, c.name)
+		if err != nil {
+			t.Fatalf("readProperty(%s/%s) unexpected error: %v", c.section, c.name, err)
+		}
+		if got != c.expected {
+			t.Errorf("readProperty(%s/%s) = %q, want %q", c.section, c.name, got, c.expected)
+		}
+	}
+}
+
+func TestProject(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("CLOUDSDK_CONFIG", dir)
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "")
+	t.Setenv("CLOUDSDK_CORE_PROJECT", "")
+
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	check := func(expected string) {
+		t.Helper()
+		got, err := Project()
+		if err != nil {
+			t.Fatalf("Project() unexpected error: %v", err)
+		}
+		if got != expected {
+			t.Errorf("Project() = %q, want %q", got, expected)
+		}
+	}
+
+	check("")
+	write("configurations/config_default", "[core]\nproject = default-project\n")
+	check("default-project")
+	write("configurations/config_staging", "[core]\nproject = staging-project\n")
+	write("active_config", "staging\n")
+	check("staging-project")
+	t.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", "default")
+	check("default-project")
+	t.Setenv("CLOUDSDK_CORE_PROJECT", "env-project")
+	check("env-project")
+}
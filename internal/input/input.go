@@ -0,0 +1,165 @@
+// Package input reads back log entries written as JSON lines, such as the
+// exports produced by grapple and by the Cloud Storage sinks of Cloud Logging,
+// or as a JSON array, such as the output of gcloud logging read --format json,
+// from plain or compressed files.
+package input
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// maxLineSize bounds the length of a single line, well above the 256KB limit of a log entry
+const maxLineSize = 16 << 20
+
+// Open returns a reader for the file at path, "-" for stdin. Files ending in
+// .gz and .zst are decompressed. The reader must be closed.
+func Open(path string) (io.ReadCloser, error) {
+	var f io.ReadCloser = io.NopCloser(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		f = file
+	}
+	return Decompress(path, f)
+}
+
+// Decompress returns a reader decompressing f when name ends in .gz or .zst,
+// f itself otherwise. Closing the reader closes f.
+func Decompress(name string, f io.ReadCloser) (io.ReadCloser, error) {
+	switch {
+	case strings.HasSuffix(name, ".gz"):
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &stackedReader{r, f}, nil
+	case strings.HasSuffix(name, ".zst"):
+		r, err := zstd.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return &stackedReader{r.IOReadCloser(), f}, nil
+	default:
+		return f, nil
+	}
+}
+
+// EntryReader decodes log entries from JSON lines, skipping blank lines, or
+// from a JSON array when the input starts with [.
+type EntryReader struct {
+	name    string
+	reader  *bufio.Reader
+	scanner *bufio.Scanner
+	// array decodes the elements of a JSON array, then counted by line.
+	array *json.Decoder
+	line  int
+}
+
+// NewEntryReader returns a reader decoding the entries of r, name is used in error messages.
+func NewEntryReader(name string, r io.Reader) *EntryReader {
+	return &EntryReader{name: name, reader: bufio.NewReader(r)}
+}
+
+// start detects the format of the input, from its first significant byte
+func (r *EntryReader) start() error {
+	for {
+		c, err := r.reader.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", r.name, err)
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		r.reader.UnreadByte()
+		if c == '[' {
+			r.array = json.NewDecoder(r.reader)
+			if _, err := r.array.Token(); err != nil {
+				return fmt.Errorf("%s: %w", r.name, err)
+			}
+			return nil
+		}
+		break
+	}
+	r.scanner = bufio.NewScanner(r.reader)
+	r.scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return nil
+}
+
+// Next returns the next entry, or io.EOF when there are no more.
+func (r *EntryReader) Next() (*loggingpb.LogEntry, error) {
+	if r.scanner == nil && r.array == nil {
+		if err := r.start(); err != nil {
+			return nil, err
+		}
+	}
+	if r.array != nil {
+		return r.nextElement()
+	}
+	for r.scanner.Scan() {
+		r.line++
+		line := r.scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		entry := &loggingpb.LogEntry{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(line, entry); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", r.name, r.line, err)
+		}
+		return entry, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s:%d: %w", r.name, r.line+1, err)
+	}
+	return nil, io.EOF
+}
+
+// nextElement returns the next element of the JSON array, or io.EOF after the last one
+func (r *EntryReader) nextElement() (*loggingpb.LogEntry, error) {
+	if !r.array.More() {
+		return nil, io.EOF
+	}
+	r.line++
+	var element json.RawMessage
+	if err := r.array.Decode(&element); err != nil {
+		return nil, fmt.Errorf("%s: entry %d: %w", r.name, r.line, err)
+	}
+	entry := &loggingpb.LogEntry{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(element, entry); err != nil {
+		return nil, fmt.Errorf("%s: entry %d: %w", r.name, r.line, err)
+	}
+	return entry, nil
+}
+
+// Name returns the name the reader was created with.
+func (r *EntryReader) Name() string {
+	return r.name
+}
+
+// stackedReader closes a decoder and then the reader underneath it
+type stackedReader struct {
+	io.ReadCloser
+	under io.Closer
+}
+
+func (s *stackedReader) Close() error {
+	return errors.Join(s.ReadCloser.Close(), s.under.Close())
+}
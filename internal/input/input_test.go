@@ -0,0 +1,86 @@
+package input
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEntryReader(t *testing.T) {
+	data := `{"insertId":"a","timestamp":"2025-01-02T15:04:05Z"}
+
+{"insertId":"b","unknownField":1}
+not json
+`
+	r := NewEntryReader("test.ndjson", strings.NewReader(data))
+
+	for _, want := range []string{"a", "b"} {
+		entry, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() unexpected error: %v", err)
+		}
+		if entry.InsertId != want {
+			t.Errorf("Next() insertId = %q, want %q", entry.InsertId, want)
+		}
+	}
+
+	if _, err := r.Next(); err == nil || !strings.HasPrefix(err.Error(), "test.ndjson:4:") {
+		t.Errorf("Next() on an invalid line = %v, want an error at line 4", err)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() at the end = %v, want io.EOF", err)
+	}
+}
+
+func TestEntryReaderArray(t *testing.T) {
+	data := `
+[
+  {"insertId": "a", "severity": "ERROR"},
+  {"insertId": "b", "unknownField": [1]}
+]`
+	r := NewEntryReader("gcloud.json", strings.NewReader(data))
+	for _, want := range []string{"a", "b"} {
+		entry, err := r.Next()
+		if err != nil {
+			t.Fatalf("Next() unexpected error: %v", err)
+		}
+		if entry.InsertId != want {
+			t.Errorf("Next() insertId = %q, want %q", entry.InsertId, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Next() at the end = %v, want io.EOF", err)
+	}
+
+	r = NewEntryReader("gcloud.json", strings.NewReader(`[{"insertId": "a"}, {"severity": 1.5}]`))
+	r.Next()
+	if _, err := r.Next(); err == nil || !strings.HasPrefix(err.Error(), "gcloud.json: entry 2:") {
+		t.Errorf("Next() on an invalid entry = %v, want an error at entry 2", err)
+	}
+}
+
+func TestOpenGzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entries.ndjson.gz")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := gzip.NewWriter(f)
+	w.Write([]byte(`{"insertId":"a"}` + "\n"))
+	w.Close()
+	f.Close()
+
+	r, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+	defer r.Close()
+
+	entry, err := NewEntryReader(path, r).Next()
+	if err != nil || entry.InsertId != "a" {
+		t.Errorf("Next() = %v, %v, want the entry a", entry, err)
+	}
+}
@@ -0,0 +1,569 @@
+// Package kafka produces messages to a Kafka topic with the subset of the protocol described in
+// https://kafka.apache.org/protocol it needs: Metadata v1 to find the leaders of the partitions, and
+// Produce v3 sending uncompressed record batches (magic 2). Keyed messages are partitioned with the
+// murmur2 hash of the Java clients, so that they land in the same partitions as with other producers.
+// The connections are plain or TLS, SASL authentication is not supported.
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// API keys and versions of the requests.
+const (
+	apiProduce        = 0
+	apiMetadata       = 3
+	versionProduce    = 3
+	versionMetadata   = 1
+	defaultTimeout    = 30 * time.Second
+	defaultClientID   = "grapple"
+	maxResponseLength = 64 << 20
+)
+
+// Acknowledgements required by the produce requests.
+const (
+	// AcksNone does not wait for the brokers, the messages can be lost silently.
+	AcksNone = 0
+	// AcksLeader waits for the leaders of the partitions to write the messages.
+	AcksLeader = 1
+	// AcksAll waits for all the in-sync replicas to write the messages.
+	AcksAll = -1
+)
+
+var crc32c = crc32.MakeTable(crc32.Castagnoli)
+
+// Error is an error code of the protocol.
+type Error int16
+
+var errorNames = map[Error]string{
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	17: "INVALID_TOPIC_EXCEPTION",
+	18: "RECORD_LIST_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return fmt.Sprintf("kafka error %d (%s)", int16(e), name)
+	}
+	return fmt.Sprintf("kafka error %d", int16(e))
+}
+
+// retriable reports whether the error goes away once the metadata is refreshed.
+func (e Error) retriable() bool {
+	return e == 5 || e == 6 || e == 7 || e == 19 || e == 20
+}
+
+// Config configures a Producer.
+type Config struct {
+	// Brokers are the host:port addresses of the bootstrap brokers.
+	Brokers []string
+	Topic   string
+	// Acks is one of AcksNone, AcksLeader and AcksAll.
+	Acks int16
+	// TLS enables TLS connections when not nil.
+	TLS *tls.Config
+	// ClientID identifies the producer in the logs of the brokers, "grapple" when empty.
+	ClientID string
+	// Timeout bounds the connections, the requests and the replication of the messages, 30s when zero.
+	Timeout time.Duration
+}
+
+// Message is a message to produce. Messages without a key are spread across the partitions.
+type Message struct {
+	Key   []byte
+	Value []byte
+	Time  time.Time
+}
+
+// Producer produces messages to a topic.
+type Producer struct {
+	config Config
+	// partitions are the IDs of the partitions of the topic, leaders the broker leading each of them.
+	partitions []int32
+	leaders    map[int32]int32
+	addresses  map[int32]string
+	conns      map[int32]*conn
+	// next is the partition of the messages without key, changed after each Produce.
+	next int
+}
+
+// NewProducer returns a producer of messages to the topic, after fetching its partitions.
+func NewProducer(ctx context.Context, config Config) (*Producer, error) {
+	if len(config.Brokers) == 0 {
+		return nil, errors.New("no brokers")
+	}
+	if config.ClientID == "" {
+		config.ClientID = defaultClientID
+	}
+	if config.Timeout == 0 {
+		config.Timeout = defaultTimeout
+	}
+	p := &Producer{config: config, conns: map[int32]*conn{}}
+	if err := p.refreshMetadata(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// refreshMetadata fetches the partitions of the topic and their leaders from the first broker that answers.
+func (p *Producer) refreshMetadata(ctx context.Context) error {
+	var errs []error
+	for _, address := range p.config.Brokers {
+		c, err := p.dial(ctx, address)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		response, err := c.roundTrip(ctx, apiMetadata, versionMetadata, metadataRequest(p.config.Topic), true)
+		c.Close()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+			continue
+		}
+		return p.parseMetadata(response)
+	}
+	return fmt.Errorf("fetching the metadata of %s: %w", p.config.Topic, errors.Join(errs...))
+}
+
+func metadataRequest(topic string) []byte {
+	var e encoder
+	e.int32(1)
+	e.string(topic)
+	return e.b
+}
+
+func (p *Producer) parseMetadata(response []byte) error {
+	d := decoder{b: response}
+	addresses := map[int32]string{}
+	for n := d.arrayLength(); n > 0 && d.err == nil; n-- {
+		id := d.int32()
+		host := d.string()
+		port := d.int32()
+		d.string() // rack
+		addresses[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	d.int32() // controller_id
+	var partitions []int32
+	leaders := map[int32]int32{}
+	for n := d.arrayLength(); n > 0 && d.err == nil; n-- {
+		code := Error(d.int16())
+		name := d.string()
+		d.int8() // is_internal
+		for n := d.arrayLength(); n > 0 && d.err == nil; n-- {
+			d.int16() // error_code, the partitions without leader fail at produce time
+			partition := d.int32()
+			leader := d.int32()
+			d.int32Array() // replica_nodes
+			d.int32Array() // isr_nodes
+			if name == p.config.Topic {
+				leaders[partition] = leader
+				partitions = append(partitions, partition)
+			}
+		}
+		if d.err == nil && name == p.config.Topic && code != 0 {
+			return fmt.Errorf("topic %s: %w", name, code)
+		}
+	}
+	if d.err != nil {
+		return fmt.Errorf("invalid metadata response: %w", d.err)
+	}
+	if len(partitions) == 0 {
+		return fmt.Errorf("topic %s has no partitions", p.config.Topic)
+	}
+	// The brokers list the partitions in any order, the hashes of the keys index them by ID.
+	slices.Sort(partitions)
+	p.partitions, p.leaders, p.addresses = partitions, leaders, addresses
+	return nil
+}
+
+// Produce sends the messages, a request per broker leading their partitions, and waits for the
+// acknowledgements selected by Config.Acks. The partitions that failed with a retriable error, e.g.
+// after a change of leader, are sent again once.
+func (p *Producer) Produce(ctx context.Context, messages []Message) error {
+	byPartition := map[int32][]Message{}
+	for _, message := range messages {
+		partition := p.partitions[p.next]
+		if message.Key != nil {
+			partition = p.partitions[int(murmur2(message.Key)&0x7fffffff)%len(p.partitions)]
+		}
+		byPartition[partition] = append(byPartition[partition], message)
+	}
+	p.next = (p.next + 1) % len(p.partitions)
+
+	err := p.produce(ctx, byPartition)
+	var retry retryError
+	if errors.As(err, &retry) {
+		for _, c := range p.conns {
+			c.Close()
+		}
+		clear(p.conns)
+		if err := p.refreshMetadata(ctx); err != nil {
+			return err
+		}
+		err = p.produce(ctx, retry.partitions(byPartition))
+	}
+	return err
+}
+
+// retryError lists the partitions that failed with a retriable error.
+type retryError map[int32]error
+
+func (e retryError) Error() string {
+	for _, err := range e {
+		return err.Error()
+	}
+	return ""
+}
+
+func (e retryError) partitions(byPartition map[int32][]Message) map[int32][]Message {
+	retry := map[int32][]Message{}
+	for partition := range e {
+		retry[partition] = byPartition[partition]
+	}
+	return retry
+}
+
+func (p *Producer) produce(ctx context.Context, byPartition map[int32][]Message) error {
+	byLeader := map[int32]map[int32][]Message{}
+	for partition, messages := range byPartition {
+		leader := p.leaders[partition]
+		if byLeader[leader] == nil {
+			byLeader[leader] = map[int32][]Message{}
+		}
+		byLeader[leader][partition] = messages
+	}
+
+	retry := retryError{}
+	for leader, partitions := range byLeader {
+		address, ok := p.addresses[leader]
+		if !ok {
+			for partition := range partitions {
+				retry[partition] = fmt.Errorf("partition %d: %w", partition, Error(5))
+			}
+			continue
+		}
+		c, err := p.leaderConn(ctx, leader, address)
+		if err != nil {
+			return err
+		}
+		request := p.produceRequest(partitions)
+		response, err := c.roundTrip(ctx, apiProduce, versionProduce, request, p.config.Acks != AcksNone)
+		if err != nil {
+			c.Close()
+			delete(p.conns, leader)
+			return fmt.Errorf("%s: %w", address, err)
+		}
+		if p.config.Acks == AcksNone {
+			continue
+		}
+		errs, err := parseProduceResponse(response)
+		if err != nil {
+			return fmt.Errorf("%s: %w", address, err)
+		}
+		for partition, code := range errs {
+			err := fmt.Errorf("partition %d: %w", partition, code)
+			if !code.retriable() {
+				return err
+			}
+			retry[partition] = err
+		}
+	}
+	if len(retry) > 0 {
+		return retry
+	}
+	return nil
+}
+
+func (p *Producer) leaderConn(ctx context.Context, leader int32, address string) (*conn, error) {
+	if c, ok := p.conns[leader]; ok {
+		return c, nil
+	}
+	c, err := p.dial(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[leader] = c
+	return c, nil
+}
+
+func (p *Producer) produceRequest(partitions map[int32][]Message) []byte {
+	var e encoder
+	e.int16(-1) // transactional_id
+	e.int16(p.config.Acks)
+	e.int32(int32(p.config.Timeout / time.Millisecond))
+	e.int32(1)
+	e.string(p.config.Topic)
+	e.int32(int32(len(partitions)))
+	for partition, messages := range partitions {
+		e.int32(partition)
+		batch := recordBatch(messages)
+		e.int32(int32(len(batch)))
+		e.b = append(e.b, batch...)
+	}
+	return e.b
+}
+
+// parseProduceResponse returns the errors of the partitions that failed.
+func parseProduceResponse(response []byte) (map[int32]Error, error) {
+	d := decoder{b: response}
+	errs := map[int32]Error{}
+	for n := d.arrayLength(); n > 0 && d.err == nil; n-- {
+		d.string() // name
+		for n := d.arrayLength(); n > 0 && d.err == nil; n-- {
+			partition := d.int32()
+			if code := Error(d.int16()); code != 0 {
+				errs[partition] = code
+			}
+			d.int64() // base_offset
+			d.int64() // log_append_time_ms
+		}
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("invalid produce response: %w", d.err)
+	}
+	return errs, nil
+}
+
+// recordBatch encodes the messages as a record batch of magic 2, their times as CreateTime.
+func recordBatch(messages []Message) []byte {
+	baseTime := messages[0].Time.UnixMilli()
+	maxTime := baseTime
+	var records []byte
+	for i, message := range messages {
+		ms := message.Time.UnixMilli()
+		maxTime = max(maxTime, ms)
+		var r []byte
+		r = append(r, 0) // attributes
+		r = binary.AppendVarint(r, ms-baseTime)
+		r = binary.AppendVarint(r, int64(i))
+		if message.Key == nil {
+			r = binary.AppendVarint(r, -1)
+		} else {
+			r = binary.AppendVarint(r, int64(len(message.Key)))
+			r = append(r, message.Key...)
+		}
+		r = binary.AppendVarint(r, int64(len(message.Value)))
+		r = append(r, message.Value...)
+		r = binary.AppendVarint(r, 0) // headers
+		records = binary.AppendVarint(records, int64(len(r)))
+		records = append(records, r...)
+	}
+
+	// The fields after the CRC, which covers them.
+	var e encoder
+	e.int16(0) // attributes: no compression, CreateTime
+	e.int32(int32(len(messages) - 1))
+	e.int64(baseTime)
+	e.int64(maxTime)
+	e.int64(-1) // producer_id
+	e.int16(-1) // producer_epoch
+	e.int32(-1) // base_sequence
+	e.int32(int32(len(messages)))
+	e.b = append(e.b, records...)
+	checked := e.b
+
+	var batch encoder
+	batch.int64(0)                               // base_offset
+	batch.int32(int32(4 + 1 + 4 + len(checked))) // batch_length, from partition_leader_epoch
+	batch.int32(-1)                              // partition_leader_epoch
+	batch.int8(2)                                // magic
+	batch.int32(int32(crc32.Checksum(checked, crc32c)))
+	return append(batch.b, checked...)
+}
+
+// murmur2 is the hash of the keys of the default partitioner of the Java clients.
+func murmur2(data []byte) int32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	length := len(data)
+	h := uint32(seed) ^ uint32(length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// Close closes the connections to the brokers.
+func (p *Producer) Close() error {
+	var errs []error
+	for _, c := range p.conns {
+		errs = append(errs, c.Close())
+	}
+	clear(p.conns)
+	return errors.Join(errs...)
+}
+
+// conn is a connection to a broker, sending one request at a time.
+type conn struct {
+	net.Conn
+	r             *bufio.Reader
+	clientID      string
+	timeout       time.Duration
+	correlationID int32
+}
+
+func (p *Producer) dial(ctx context.Context, address string) (*conn, error) {
+	dialer := &net.Dialer{Timeout: p.config.Timeout}
+	var c net.Conn
+	var err error
+	if p.config.TLS != nil {
+		c, err = (&tls.Dialer{NetDialer: dialer, Config: p.config.TLS}).DialContext(ctx, "tcp", address)
+	} else {
+		c, err = dialer.DialContext(ctx, "tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &conn{Conn: c, r: bufio.NewReader(c), clientID: p.config.ClientID, timeout: p.config.Timeout}, nil
+}
+
+// roundTrip sends a request and returns the body of its response, if the broker sends one.
+func (c *conn) roundTrip(ctx context.Context, apiKey, version int16, body []byte, response bool) ([]byte, error) {
+	deadline := time.Now().Add(2 * c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { c.SetDeadline(time.Now()) })
+	defer stop()
+
+	c.correlationID++
+	var e encoder
+	e.int32(0) // length, set below
+	e.int16(apiKey)
+	e.int16(version)
+	e.int32(c.correlationID)
+	e.string(c.clientID)
+	e.b = append(e.b, body...)
+	binary.BigEndian.PutUint32(e.b, uint32(len(e.b)-4))
+	if _, err := c.Write(e.b); err != nil {
+		return nil, errors.Join(err, ctx.Err())
+	}
+	if !response {
+		return nil, nil
+	}
+
+	var header [8]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return nil, errors.Join(err, ctx.Err())
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 4 || length > maxResponseLength {
+		return nil, fmt.Errorf("invalid response length %d", length)
+	}
+	if id := int32(binary.BigEndian.Uint32(header[4:])); id != c.correlationID {
+		return nil, fmt.Errorf("response to request %d, want %d", id, c.correlationID)
+	}
+	data := make([]byte, length-4)
+	if _, err := io.ReadFull(c.r, data); err != nil {
+		return nil, errors.Join(err, ctx.Err())
+	}
+	return data, nil
+}
+
+// encoder appends the big-endian fields of the requests.
+type encoder struct {
+	b []byte
+}
+
+func (e *encoder) int8(v int8)   { e.b = append(e.b, byte(v)) }
+func (e *encoder) int16(v int16) { e.b = binary.BigEndian.AppendUint16(e.b, uint16(v)) }
+func (e *encoder) int32(v int32) { e.b = binary.BigEndian.AppendUint32(e.b, uint32(v)) }
+func (e *encoder) int64(v int64) { e.b = binary.BigEndian.AppendUint64(e.b, uint64(v)) }
+
+func (e *encoder) string(s string) {
+	e.int16(int16(len(s)))
+	e.b = append(e.b, s...)
+}
+
+// decoder reads the fields of the responses, keeping the first error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) next(n int) []byte {
+	if d.err != nil {
+		return make([]byte, n)
+	}
+	if len(d.b) < n {
+		d.err = io.ErrUnexpectedEOF
+		return make([]byte, n)
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) int8() int8   { return int8(d.next(1)[0]) }
+func (d *decoder) int16() int16 { return int16(binary.BigEndian.Uint16(d.next(2))) }
+func (d *decoder) int32() int32 { return int32(binary.BigEndian.Uint32(d.next(4))) }
+func (d *decoder) int64() int64 { return int64(binary.BigEndian.Uint64(d.next(8))) }
+
+// string reads a string, empty when null.
+func (d *decoder) string() string {
+	n := d.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(d.next(int(n)))
+}
+
+// arrayLength reads the length of an array, 0 when null.
+func (d *decoder) arrayLength() int {
+	n := int(d.int32())
+	if n > len(d.b) {
+		d.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	return max(n, 0)
+}
+
+func (d *decoder) int32Array() {
+	for n := d.arrayLength(); n > 0; n-- {
+		d.int32()
+	}
+}
@@ -0,0 +1,234 @@
+package kafka
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMurmur2(t *testing.T) {
+	// The hashes computed by org.apache.kafka.common.utils.Utils.murmur2.
+	tests := map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	}
+	for key, expected := range tests {
+		if hash := murmur2([]byte(key)); hash != expected {
+			t.Errorf("murmur2(%q) = %d, want %d", key, hash, expected)
+		}
+	}
+}
+
+// record is a record decoded by the fake broker
+type record struct {
+	partition int32
+	key       string
+	value     string
+	time      time.Time
+}
+
+// fakeBroker serves the metadata of a topic of 2 partitions led by itself, and records the
+// messages produced, answering with the error code of produceError
+type fakeBroker struct {
+	listener     net.Listener
+	mu           sync.Mutex
+	records      []record
+	produceError int16
+}
+
+func newFakeBroker(t *testing.T) *fakeBroker {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &fakeBroker{listener: listener}
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(t, c)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return b
+}
+
+func (b *fakeBroker) serve(t *testing.T, c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var length [4]byte
+		if _, err := io.ReadFull(r, length[:]); err != nil {
+			return
+		}
+		request := make([]byte, binary.BigEndian.Uint32(length[:]))
+		if _, err := io.ReadFull(r, request); err != nil {
+			return
+		}
+		d := decoder{b: request}
+		apiKey, version, correlationID := d.int16(), d.int16(), d.int32()
+		if clientID := d.string(); clientID != defaultClientID {
+			t.Errorf("client ID %q", clientID)
+		}
+
+		var e encoder
+		e.int32(0)
+		e.int32(correlationID)
+		switch {
+		case apiKey == apiMetadata && version == versionMetadata:
+			host, port, _ := net.SplitHostPort(b.listener.Addr().String())
+			portNumber, _ := strconv.Atoi(port)
+			e.int32(1)
+			e.int32(7)
+			e.string(host)
+			e.int32(int32(portNumber))
+			e.int16(-1)
+			e.int32(7)
+			e.int32(1)
+			e.int16(0)
+			e.string("logs")
+			e.int8(0)
+			e.int32(2)
+			// The partitions are listed out of order, like brokers may.
+			for _, partition := range []int32{1, 0} {
+				e.int16(0)
+				e.int32(partition)
+				e.int32(7)
+				e.int32(1)
+				e.int32(7)
+				e.int32(1)
+				e.int32(7)
+			}
+		case apiKey == apiProduce && version == versionProduce:
+			d.int16() // transactional_id
+			acks := d.int16()
+			d.int32()
+			d.int32()
+			topic := d.string()
+			var partitions []int32
+			for n := d.arrayLength(); n > 0; n-- {
+				partition := d.int32()
+				batch := d.next(int(d.int32()))
+				if err := b.decodeBatch(partition, batch); err != nil {
+					t.Errorf("invalid record batch: %v", err)
+				}
+				partitions = append(partitions, partition)
+			}
+			if acks == AcksNone {
+				continue
+			}
+			e.int32(1)
+			e.string(topic)
+			e.int32(int32(len(partitions)))
+			for _, partition := range partitions {
+				e.int32(partition)
+				e.int16(b.produceError)
+				e.int64(0)
+				e.int64(-1)
+			}
+			e.int32(0)
+		default:
+			t.Errorf("unexpected request %d v%d", apiKey, version)
+			return
+		}
+		binary.BigEndian.PutUint32(e.b, uint32(len(e.b)-4))
+		c.Write(e.b)
+	}
+}
+
+func (b *fakeBroker) decodeBatch(partition int32, batch []byte) error {
+	d := decoder{b: batch}
+	d.int64()
+	if length := d.int32(); int(length) != len(d.b) {
+		return errors.New("wrong batch length")
+	}
+	d.int32()
+	if magic := d.int8(); magic != 2 {
+		return errors.New("wrong magic")
+	}
+	if crc := uint32(d.int32()); crc != crc32.Checksum(d.b, crc32c) {
+		return errors.New("wrong CRC")
+	}
+	d.int16()
+	d.int32()
+	baseTime := d.int64()
+	d.next(8 + 8 + 2 + 4)
+	count := d.int32()
+	for i := int32(0); i < count; i++ {
+		length, n := binary.Varint(d.b)
+		r := d.b[n : n+int(length)]
+		d.b = d.b[n+int(length):]
+		r = r[1:]
+		delta, n := binary.Varint(r)
+		r = r[n:]
+		_, n = binary.Varint(r)
+		r = r[n:]
+		keyLength, n := binary.Varint(r)
+		r = r[n:]
+		key := ""
+		if keyLength >= 0 {
+			key, r = string(r[:keyLength]), r[keyLength:]
+		}
+		valueLength, n := binary.Varint(r)
+		r = r[n:]
+		b.mu.Lock()
+		b.records = append(b.records, record{partition, key, string(r[:valueLength]), time.UnixMilli(baseTime + delta).UTC()})
+		b.mu.Unlock()
+	}
+	return d.err
+}
+
+func TestProducer(t *testing.T) {
+	broker := newFakeBroker(t)
+	ctx := context.Background()
+	p, err := NewProducer(ctx, Config{Brokers: []string{"127.0.0.1:1", broker.listener.Addr().String()}, Topic: "logs", Acks: AcksAll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+
+	ts := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	messages := []Message{
+		{Key: []byte("21"), Value: []byte("a"), Time: ts},
+		{Key: []byte("abc"), Value: []byte("b"), Time: ts.Add(time.Second)},
+		{Key: []byte("21"), Value: []byte("c"), Time: ts.Add(2 * time.Second)},
+	}
+	if err := p.Produce(ctx, messages); err != nil {
+		t.Fatal(err)
+	}
+	// 21 hashes to partition 0, abc to partition 1, in the order they were produced.
+	partitions := map[string]int32{}
+	var values string
+	for _, r := range broker.records {
+		partitions[r.key] = r.partition
+		if r.key == "21" {
+			values += r.value
+		}
+		if r.value == "b" && !r.time.Equal(ts.Add(time.Second)) {
+			t.Errorf("time %v", r.time)
+		}
+	}
+	if len(broker.records) != 3 || partitions["21"] != 0 || partitions["abc"] != 1 || values != "ac" {
+		t.Errorf("broker received %+v", broker.records)
+	}
+
+	broker.produceError = 10
+	err = p.Produce(ctx, messages[:1])
+	if code := Error(0); !errors.As(err, &code) || code != 10 {
+		t.Errorf("Produce() = %v, want MESSAGE_TOO_LARGE", err)
+	}
+}
@@ -0,0 +1,157 @@
+package logadmin
+
+import (
+	"context"
+	"fmt"
+
+	vkit "cloud.google.com/go/logging/apiv2"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// LocationPath returns the resource name of a location within the client's parent.
+// The special location "-" stands for all locations when listing buckets.
+func (c *Client) LocationPath(location string) string {
+	return fmt.Sprintf("%s/locations/%s", c.parent, location)
+}
+
+// BucketPath returns the resource name of a log bucket within the client's parent.
+func (c *Client) BucketPath(location, bucketID string) string {
+	return fmt.Sprintf("%s/buckets/%s", c.LocationPath(location), bucketID)
+}
+
+// ViewPath returns the resource name of a log view within the client's parent.
+// It can be passed to ResourceNames to read entries through the view.
+func (c *Client) ViewPath(location, bucketID, viewID string) string {
+	return fmt.Sprintf("%s/views/%s", c.BucketPath(location, bucketID), viewID)
+}
+
+// Buckets returns a BucketIterator for iterating over the log buckets of a location.
+func (c *Client) Buckets(ctx context.Context, location string) *BucketIterator {
+	it := &BucketIterator{
+		it: c.cClient.ListBuckets(ctx, &logpb.ListBucketsRequest{Parent: c.LocationPath(location)}),
+	}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.items) },
+		func() interface{} { b := it.items; it.items = nil; return b })
+	return it
+}
+
+// CreateBucket creates a log bucket with the given ID and returns it as stored by the service.
+func (c *Client) CreateBucket(ctx context.Context, location, bucketID string, bucket *logpb.LogBucket) (*logpb.LogBucket, error) {
+	return c.cClient.CreateBucket(ctx, &logpb.CreateBucketRequest{
+		Parent:   c.LocationPath(location),
+		BucketId: bucketID,
+		Bucket:   bucket,
+	})
+}
+
+// UpdateBucket updates the given fields of a log bucket, e.g. "retention_days" or "description".
+func (c *Client) UpdateBucket(ctx context.Context, location, bucketID string, bucket *logpb.LogBucket, paths []string) (*logpb.LogBucket, error) {
+	return c.cClient.UpdateBucket(ctx, &logpb.UpdateBucketRequest{
+		Name:       c.BucketPath(location, bucketID),
+		Bucket:     bucket,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: paths},
+	})
+}
+
+// DeleteBucket deletes a log bucket. The bucket stays in a pending deletion state for 7 days.
+func (c *Client) DeleteBucket(ctx context.Context, location, bucketID string) error {
+	return c.cClient.DeleteBucket(ctx, &logpb.DeleteBucketRequest{Name: c.BucketPath(location, bucketID)})
+}
+
+// A BucketIterator iterates over log buckets.
+type BucketIterator struct {
+	it       *vkit.LogBucketIterator
+	pageInfo *iterator.PageInfo
+	nextFunc func() error
+	items    []*logpb.LogBucket
+}
+
+// PageInfo supports pagination. See https://godoc.org/google.golang.org/api/iterator package for details.
+func (it *BucketIterator) PageInfo() *iterator.PageInfo { return it.pageInfo }
+
+// Next returns the next result. Its second return value is iterator.Done
+// (https://godoc.org/google.golang.org/api/iterator) if there are no more
+// results. Once Next returns Done, all subsequent calls will return Done.
+func (it *BucketIterator) Next() (*logpb.LogBucket, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return item, nil
+}
+
+func (it *BucketIterator) fetch(pageSize int, pageToken string) (string, error) {
+	return iterFetch(pageSize, pageToken, it.it.PageInfo(), func() error {
+		item, err := it.it.Next()
+		if err != nil {
+			return err
+		}
+		it.items = append(it.items, item)
+		return nil
+	})
+}
+
+// Views returns a ViewIterator for iterating over the log views of a bucket.
+func (c *Client) Views(ctx context.Context, location, bucketID string) *ViewIterator {
+	it := &ViewIterator{
+		it: c.cClient.ListViews(ctx, &logpb.ListViewsRequest{Parent: c.BucketPath(location, bucketID)}),
+	}
+	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
+		it.fetch,
+		func() int { return len(it.items) },
+		func() interface{} { b := it.items; it.items = nil; return b })
+	return it
+}
+
+// CreateView creates a log view with the given ID and returns it as stored by the service.
+func (c *Client) CreateView(ctx context.Context, location, bucketID, viewID string, view *logpb.LogView) (*logpb.LogView, error) {
+	return c.cClient.CreateView(ctx, &logpb.CreateViewRequest{
+		Parent: c.BucketPath(location, bucketID),
+		ViewId: viewID,
+		View:   view,
+	})
+}
+
+// DeleteView deletes a log view.
+func (c *Client) DeleteView(ctx context.Context, location, bucketID, viewID string) error {
+	return c.cClient.DeleteView(ctx, &logpb.DeleteViewRequest{Name: c.ViewPath(location, bucketID, viewID)})
+}
+
+// A ViewIterator iterates over log views.
+type ViewIterator struct {
+	it       *vkit.LogViewIterator
+	pageInfo *iterator.PageInfo
+	nextFunc func() error
+	items    []*logpb.LogView
+}
+
+// PageInfo supports pagination. See https://godoc.org/google.golang.org/api/iterator package for details.
+func (it *ViewIterator) PageInfo() *iterator.PageInfo { return it.pageInfo }
+
+// Next returns the next result. Its second return value is iterator.Done
+// (https://godoc.org/google.golang.org/api/iterator) if there are no more
+// results. Once Next returns Done, all subsequent calls will return Done.
+func (it *ViewIterator) Next() (*logpb.LogView, error) {
+	if err := it.nextFunc(); err != nil {
+		return nil, err
+	}
+	item := it.items[0]
+	it.items = it.items[1:]
+	return item, nil
+}
+
+func (it *ViewIterator) fetch(pageSize int, pageToken string) (string, error) {
+	return iterFetch(pageSize, pageToken, it.it.PageInfo(), func() error {
+		item, err := it.it.Next()
+		if err != nil {
+			return err
+		}
+		it.items = append(it.items, item)
+		return nil
+	})
+}
@@ -0,0 +1,53 @@
+package logadmin
+
+import (
+	"context"
+
+	vkit "cloud.google.com/go/logging/apiv2"
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"cloud.google.com/go/longrunning/autogen/longrunningpb"
+)
+
+// A CopyOperation tracks a long-running copy of log entries started by CopyLogEntries.
+type CopyOperation struct {
+	c  *Client
+	op *vkit.CopyLogEntriesOperation
+}
+
+// CopyLogEntries starts copying the entries of the source log bucket matching
+// filter to destination, e.g. "storage.googleapis.com/GCS_BUCKET". source is
+// the full resource name of the bucket, see BucketPath. Requires AdminScope.
+func (c *Client) CopyLogEntries(ctx context.Context, source, filter, destination string) (*CopyOperation, error) {
+	op, err := c.cClient.CopyLogEntries(ctx, &logpb.CopyLogEntriesRequest{
+		Name:        source,
+		Filter:      filter,
+		Destination: destination,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &CopyOperation{c: c, op: op}, nil
+}
+
+// Name returns the server-assigned name of the operation.
+func (o *CopyOperation) Name() string { return o.op.Name() }
+
+// Done reports whether the operation has completed.
+func (o *CopyOperation) Done() bool { return o.op.Done() }
+
+// Poll fetches the latest state of the operation. It returns a nil response
+// and error while the operation is still running, see Metadata for progress.
+func (o *CopyOperation) Poll(ctx context.Context) (*logpb.CopyLogEntriesResponse, error) {
+	return o.op.Poll(ctx)
+}
+
+// Metadata returns the progress information fetched by the latest Poll, or nil if not available yet.
+func (o *CopyOperation) Metadata() (*logpb.CopyLogEntriesMetadata, error) {
+	return o.op.Metadata()
+}
+
+// Cancel asks the service to stop the operation. Cancellation is asynchronous,
+// keep polling to find out when the operation actually stops.
+func (o *CopyOperation) Cancel(ctx context.Context) error {
+	return o.c.cClient.CancelOperation(ctx, &longrunningpb.CancelOperationRequest{Name: o.op.Name()})
+}
@@ -12,9 +12,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// These features are missing now, but will likely be added:
-// - There is no way to specify CallOptions.
-
 // Package logadmin contains a Cloud Logging client that can be used
 // for reading logs and working with sinks, metrics and monitored resources.
 // For a client that can write logs, see package cloud.google.com/go/logging.
@@ -27,6 +24,7 @@ package logadmin // import "cloud.google.com/go/logging/logadmin"
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -34,6 +32,7 @@ import (
 	"cloud.google.com/go/logging"
 	vkit "cloud.google.com/go/logging/apiv2"
 	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	gax "github.com/googleapis/gax-go/v2"
 	"google.golang.org/api/iterator"
 	"google.golang.org/api/option"
 	_ "google.golang.org/genproto/googleapis/appengine/logging/v1" // Import the following so EntryIterator can unmarshal log protos.
@@ -45,9 +44,14 @@ const Version = "1.13.0"
 
 // Client is a Logging client. A Client is associated with a single Cloud project.
 type Client struct {
-	lClient *vkit.Client        // logging client
+	lClient *vkit.Client       // logging client
+	cClient *vkit.ConfigClient // config client
 	parent  string
 	closed  bool
+	// sharedConn is set when the config client uses the connection of the logging client.
+	sharedConn bool
+
+	entriesCallOptions []gax.CallOption
 }
 
 // NewClient returns a new logging client associated with the provided project ID.
@@ -65,12 +69,45 @@ func NewClient(ctx context.Context, parent string, opts ...option.ClientOption)
 	if err != nil {
 		return nil, err
 	}
+	cc, err := vkit.NewConfigClient(ctx, option.WithGRPCConn(lc.Connection()))
+	if err != nil {
+		lc.Close()
+		return nil, err
+	}
+	c := newClient(parent, lc, cc)
+	c.sharedConn = true
+	return c, nil
+}
+
+// NewRESTClient is like NewClient, but the client uses the REST transport
+// of the Logging API instead of gRPC, e.g. where gRPC is blocked.
+func NewRESTClient(ctx context.Context, parent string, opts ...option.ClientOption) (*Client, error) {
+	if !strings.ContainsRune(parent, '/') {
+		parent = "projects/" + parent
+	}
+	opts = append([]option.ClientOption{
+		option.WithScopes(logging.AdminScope),
+	}, opts...)
+	lc, err := vkit.NewRESTClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := vkit.NewConfigRESTClient(ctx, opts...)
+	if err != nil {
+		lc.Close()
+		return nil, err
+	}
+	return newClient(parent, lc, cc), nil
+}
+
+func newClient(parent string, lc *vkit.Client, cc *vkit.ConfigClient) *Client {
 	lc.SetGoogleClientInfo("gccl", Version)
-	client := &Client{
+	cc.SetGoogleClientInfo("gccl", Version)
+	return &Client{
 		lClient: lc,
+		cClient: cc,
 		parent:  parent,
 	}
-	return client, nil
 }
 
 // Close closes the client.
@@ -78,13 +115,30 @@ func (c *Client) Close() error {
 	if c.closed {
 		return nil
 	}
-	// Return only the first error. Since all clients share an underlying connection,
-	// Closes after the first always report a "connection is closing" error.
+	// When the clients share the connection, the Close after the first always reports a
+	// "connection is closing" error, only the first one is returned.
 	err := c.lClient.Close()
+	if cerr := c.cClient.Close(); !c.sharedConn {
+		err = errors.Join(err, cerr)
+	}
 	c.closed = true
 	return err
 }
 
+// DeleteLog deletes a log and all its log entries. The log will reappear if it receives new entries.
+// logID identifies the log within the project. An example log ID is "syslog". Requires AdminScope.
+func (c *Client) DeleteLog(ctx context.Context, logID string) error {
+	return c.lClient.DeleteLog(ctx, &logpb.DeleteLogRequest{
+		LogName: logPath(c.parent, logID),
+	})
+}
+
+// logPath creates a formatted path from a parent and a logID.
+func logPath(parent, logID string) string {
+	logID = strings.Replace(logID, "/", "%2F", -1)
+	return fmt.Sprintf("%s/logs/%s", parent, logID)
+}
+
 // An EntriesOption is an option for listing log entries.
 type EntriesOption interface {
 	set(*logpb.ListLogEntriesRequest)
@@ -146,12 +200,18 @@ type pageSize int32
 
 func (p pageSize) set(r *logpb.ListLogEntriesRequest) { r.PageSize = int32(p) }
 
+// SetEntriesCallOptions sets call options, like timeouts and retries, for the requests made by Entries.
+// They are applied after the defaults of the underlying client, overriding them.
+func (c *Client) SetEntriesCallOptions(opts ...gax.CallOption) {
+	c.entriesCallOptions = append([]gax.CallOption(nil), opts...)
+}
+
 // Entries returns an EntryIterator for iterating over log entries. By default,
 // the log entries will be restricted to those from the project passed to
 // NewClient. This may be overridden by passing a ProjectIDs option. Requires ReadScope or AdminScope.
 func (c *Client) Entries(ctx context.Context, opts ...EntriesOption) *EntryIterator {
 	it := &EntryIterator{
-		it: c.lClient.ListLogEntries(ctx, listLogEntriesRequest(c.parent, opts)),
+		it: c.lClient.ListLogEntries(ctx, listLogEntriesRequest(c.parent, opts), c.entriesCallOptions...),
 	}
 	it.pageInfo, it.nextFunc = iterator.NewPageInfo(
 		it.fetch,
@@ -160,6 +220,18 @@ func (c *Client) Entries(ctx context.Context, opts ...EntriesOption) *EntryItera
 	return it
 }
 
+// EntriesRequest returns the request that Entries sends for the given options,
+// including the default timestamp filter, without sending it.
+func (c *Client) EntriesRequest(opts ...EntriesOption) *logpb.ListLogEntriesRequest {
+	return listLogEntriesRequest(c.parent, opts)
+}
+
+// ListLogEntries sends a request, e.g. one returned by EntriesRequest, with the call options
+// set by SetEntriesCallOptions after opts, and returns the iterator of the underlying client.
+func (c *Client) ListLogEntries(ctx context.Context, req *logpb.ListLogEntriesRequest, opts ...gax.CallOption) *vkit.LogEntryIterator {
+	return c.lClient.ListLogEntries(ctx, req, append(append([]gax.CallOption(nil), opts...), c.entriesCallOptions...)...)
+}
+
 func listLogEntriesRequest(parent string, opts []EntriesOption) *logpb.ListLogEntriesRequest {
 	req := &logpb.ListLogEntriesRequest{
 		ResourceNames: []string{parent},
@@ -0,0 +1,77 @@
+package logadmin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// fakeDescriptors serves pages of 2 descriptors, the last one being pages
+type fakeDescriptors struct {
+	logpb.UnimplementedLoggingServiceV2Server
+	pages    int
+	requests []*logpb.ListMonitoredResourceDescriptorsRequest
+}
+
+func (f *fakeDescriptors) ListMonitoredResourceDescriptors(ctx context.Context, req *logpb.ListMonitoredResourceDescriptorsRequest) (*logpb.ListMonitoredResourceDescriptorsResponse, error) {
+	f.requests = append(f.requests, req)
+	page := len(f.requests)
+	resp := &logpb.ListMonitoredResourceDescriptorsResponse{}
+	for i := 0; i < 2; i++ {
+		resp.ResourceDescriptors = append(resp.ResourceDescriptors, &mrpb.MonitoredResourceDescriptor{Type: fmt.Sprintf("type-%d-%d", page, i)})
+	}
+	if page < f.pages {
+		resp.NextPageToken = fmt.Sprint(page)
+	}
+	return resp, nil
+}
+
+func TestResourceDescriptors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeDescriptors{pages: 2}
+	s := grpc.NewServer()
+	logpb.RegisterLoggingServiceV2Server(s, server)
+	go s.Serve(listener)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	client, err := NewClient(ctx, "p", option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	var types []string
+	it := client.ResourceDescriptors(ctx)
+	for {
+		d, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, d.Type)
+	}
+	if fmt.Sprint(types) != "[type-1-0 type-1-1 type-2-0 type-2-1]" {
+		t.Errorf("ResourceDescriptors() = %v, want the descriptors of both pages", types)
+	}
+	if len(server.requests) != 2 || server.requests[0].PageToken != "" || server.requests[1].PageToken != "1" {
+		t.Errorf("requests %v, want the second one to continue from the first page", server.requests)
+	}
+}
@@ -0,0 +1,20 @@
+package logadmin
+
+import (
+	"context"
+
+	logpb "cloud.google.com/go/logging/apiv2/loggingpb"
+	mrpb "google.golang.org/genproto/googleapis/api/monitoredres"
+)
+
+// WriteEntries writes log entries to the log identified by logID. Entries
+// without their own resource are attributed to the given monitored resource.
+// Requires WriteScope or AdminScope.
+func (c *Client) WriteEntries(ctx context.Context, logID string, resource *mrpb.MonitoredResource, entries []*logpb.LogEntry) error {
+	_, err := c.lClient.WriteLogEntries(ctx, &logpb.WriteLogEntriesRequest{
+		LogName:  logPath(c.parent, logID),
+		Resource: resource,
+		Entries:  entries,
+	})
+	return err
+}
@@ -0,0 +1,521 @@
+// Package lql parses the Cloud Logging query language, to report syntax errors with their position
+// before a filter is sent to the API and to explain how a filter is understood, and evaluates filters
+// against entries read from files.
+//
+// The precedence of the operators follows the API: NOT binds tightest, then OR, then AND,
+// so "a OR b AND c" is "(a OR b) AND c". Juxtaposed terms are joined by an implicit AND.
+// Comments start with -- and run to the end of the line.
+package lql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Node is a node of a parsed filter.
+type Node interface {
+	// String returns the node in the query language, fully parenthesized.
+	String() string
+}
+
+// And is a conjunction, explicit or implicit.
+type And struct{ Terms []Node }
+
+// Or is a disjunction.
+type Or struct{ Terms []Node }
+
+// Not is a negation, with NOT or -.
+type Not struct{ Term Node }
+
+// Comparison is a restriction of a field, e.g. severity>=ERROR.
+type Comparison struct {
+	Path string
+	Op   string
+	// Value is a Value, a Call or an And, Or or Not of values, e.g. severity=(ERROR OR WARNING).
+	Value Node
+}
+
+// Search is a global restriction, matching the value anywhere in the entry.
+type Search struct{ Value Node }
+
+// Call is a function call, e.g. sample(insertId, 0.25) or log_id("stdout").
+type Call struct {
+	Name string
+	Args []Node
+}
+
+// Value is a literal, quoted or not.
+type Value struct {
+	Text   string
+	Quoted bool
+}
+
+func (n *And) String() string { return joinNodes(n.Terms, " AND ") }
+
+func (n *Or) String() string { return joinNodes(n.Terms, " OR ") }
+
+func (n *Not) String() string { return "NOT " + n.Term.String() }
+
+func (n *Comparison) String() string { return n.Path + n.Op + n.Value.String() }
+
+func (n *Search) String() string { return n.Value.String() }
+
+func (n *Call) String() string {
+	args := make([]string, len(n.Args))
+	for i, arg := range n.Args {
+		args[i] = arg.String()
+	}
+	return n.Name + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (n *Value) String() string {
+	if n.Quoted {
+		return fmt.Sprintf("%q", n.Text)
+	}
+	return n.Text
+}
+
+func joinNodes(nodes []Node, sep string) string {
+	parts := make([]string, len(nodes))
+	for i, n := range nodes {
+		parts[i] = n.String()
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// SyntaxError is an error in a filter, at a byte Offset, Line and Column counting from 1.
+type SyntaxError struct {
+	Offset int
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Parse parses a filter, returning nil for an empty one. Errors are *SyntaxError.
+func Parse(filter string) (Node, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{filter: filter, tokens: tokens}
+	if p.peek().kind == tokEOF {
+		return nil, nil
+	}
+	node, err := p.parseAnd(p.parseTerm)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokLParen
+	tokRParen
+	tokComma
+	tokOp
+	tokString
+	tokWord
+)
+
+// token is a lexeme of a filter, text being the unquoted content of strings.
+// adjacent reports that no whitespace precedes it.
+type token struct {
+	kind     tokenKind
+	text     string
+	offset   int
+	adjacent bool
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of filter"
+	case tokString:
+		return fmt.Sprintf("string %q", t.text)
+	default:
+		return fmt.Sprintf("%q", t.text)
+	}
+}
+
+// comparisonOps are the comparison operators, the two-character ones first
+var comparisonOps = []string{">=", "<=", "!=", "=~", "!~", "=", "<", ">", ":"}
+
+// wordBreaks are the characters ending an unquoted word, besides whitespace
+const wordBreaks = `()",=<>!:`
+
+func lex(filter string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(filter); {
+		start := i
+		for i < len(filter) {
+			r, size := utf8.DecodeRuneInString(filter[i:])
+			if !unicode.IsSpace(r) {
+				break
+			}
+			i += size
+		}
+		if i == len(filter) {
+			break
+		}
+		if strings.HasPrefix(filter[i:], "--") {
+			// Comments run to the end of the line.
+			if end := strings.IndexByte(filter[i:], '\n'); end >= 0 {
+				i += end
+				continue
+			}
+			break
+		}
+		t := token{offset: i, adjacent: i == start && len(tokens) > 0}
+		afterOp := len(tokens) > 0 && tokens[len(tokens)-1].kind == tokOp
+
+		switch c := filter[i]; {
+		case c == '(':
+			t.kind, t.text = tokLParen, "("
+			i++
+		case c == ')':
+			t.kind, t.text = tokRParen, ")"
+			i++
+		case c == ',':
+			t.kind, t.text = tokComma, ","
+			i++
+		case c == '"':
+			text, n, ok := unquote(filter[i:])
+			if !ok {
+				return nil, newSyntaxError(filter, i, "unterminated string")
+			}
+			t.kind, t.text = tokString, text
+			i += n
+		case strings.IndexByte("=<>!:", c) >= 0:
+			for _, op := range comparisonOps {
+				if strings.HasPrefix(filter[i:], op) {
+					t.kind, t.text = tokOp, op
+					break
+				}
+			}
+			if t.kind != tokOp {
+				return nil, newSyntaxError(filter, i, fmt.Sprintf("unexpected %q, comparison operators are =, !=, <, <=, >, >=, :, =~ and !~", c))
+			}
+			i += len(t.text)
+		default:
+			// Values can contain colons, e.g. unquoted timestamps and URLs.
+			breaks := wordBreaks
+			if afterOp {
+				breaks = `()",`
+			}
+			for i < len(filter) {
+				r, size := utf8.DecodeRuneInString(filter[i:])
+				if unicode.IsSpace(r) || strings.ContainsRune(breaks, r) {
+					break
+				}
+				i += size
+			}
+			t.kind, t.text = tokWord, filter[t.offset:i]
+		}
+		tokens = append(tokens, t)
+	}
+	return append(tokens, token{kind: tokEOF, offset: len(filter)}), nil
+}
+
+// unquote returns the content of the string literal s starts with and its length
+func unquote(s string) (string, int, bool) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return b.String(), i + 1, true
+		case '\\':
+			if i+1 == len(s) {
+				return "", 0, false
+			}
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", 0, false
+}
+
+func newSyntaxError(filter string, offset int, msg string) *SyntaxError {
+	before := filter[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return &SyntaxError{Offset: offset, Line: line, Column: column, Msg: msg}
+}
+
+type parser struct {
+	filter string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token { return p.tokens[p.pos] }
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) errorf(t token, format string, args ...any) error {
+	return newSyntaxError(p.filter, t.offset, fmt.Sprintf(format, args...))
+}
+
+func isKeyword(t token, keyword string) bool { return t.kind == tokWord && t.text == keyword }
+
+// startsTerm reports whether t can start a term joined by an implicit AND
+func startsTerm(t token) bool {
+	switch t.kind {
+	case tokLParen, tokString:
+		return true
+	case tokWord:
+		return t.text != "AND" && t.text != "OR"
+	}
+	return false
+}
+
+// parseAnd parses terms joined by AND or juxtaposed, each term parsed by primary
+func (p *parser) parseAnd(primary func() (Node, error)) (Node, error) {
+	first, err := p.parseOr(primary)
+	if err != nil {
+		return nil, err
+	}
+	terms := []Node{first}
+	for {
+		t := p.peek()
+		if isKeyword(t, "AND") {
+			p.next()
+			if !startsTerm(p.peek()) {
+				return nil, p.errorf(p.peek(), "expected a term after AND, found %s", p.peek())
+			}
+		} else if !startsTerm(t) {
+			break
+		}
+		term, err := p.parseOr(primary)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &And{Terms: terms}, nil
+}
+
+func (p *parser) parseOr(primary func() (Node, error)) (Node, error) {
+	first, err := p.parseNot(primary)
+	if err != nil {
+		return nil, err
+	}
+	terms := []Node{first}
+	for isKeyword(p.peek(), "OR") {
+		p.next()
+		if !startsTerm(p.peek()) {
+			return nil, p.errorf(p.peek(), "expected a term after OR, found %s", p.peek())
+		}
+		term, err := p.parseNot(primary)
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+	}
+	if len(terms) == 1 {
+		return first, nil
+	}
+	return &Or{Terms: terms}, nil
+}
+
+func (p *parser) parseNot(primary func() (Node, error)) (Node, error) {
+	t := p.peek()
+	switch {
+	case isKeyword(t, "NOT"):
+		p.next()
+		if !startsTerm(p.peek()) {
+			return nil, p.errorf(p.peek(), "expected a term after NOT, found %s", p.peek())
+		}
+	case t.kind == tokWord && t.text == "-":
+		p.next()
+		if next := p.peek(); !next.adjacent || !startsTerm(next) {
+			return nil, p.errorf(t, "expected a term right after -")
+		}
+	case t.kind == tokWord && strings.HasPrefix(t.text, "-") && len(t.text) > 1:
+		// The negated term is the rest of the word.
+		p.tokens[p.pos].text = t.text[1:]
+		p.tokens[p.pos].offset++
+	default:
+		return primary()
+	}
+	term, err := p.parseNot(primary)
+	if err != nil {
+		return nil, err
+	}
+	return &Not{Term: term}, nil
+}
+
+// parseTerm parses a parenthesized expression, a comparison, a function call or a global restriction
+func (p *parser) parseTerm() (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokLParen:
+		return p.parseGroup(p.parseTerm)
+	case tokString, tokWord:
+		if t.kind == tokWord && p.tokens[p.pos+1].kind == tokLParen && p.tokens[p.pos+1].adjacent {
+			return p.parseCall()
+		}
+		path := p.parsePath()
+		op := p.peek()
+		if op.kind != tokOp {
+			return &Search{Value: path}, nil
+		}
+		p.next()
+		value, err := p.parseComparisonValue(op)
+		if err != nil {
+			return nil, err
+		}
+		name := path.Text
+		if path.Quoted {
+			name = path.String()
+		}
+		return &Comparison{Path: name, Op: op.text, Value: value}, nil
+	case tokOp:
+		return nil, p.errorf(t, "expected a field before %q", t.text)
+	default:
+		return nil, p.errorf(t, "unexpected %s", t)
+	}
+}
+
+// parseGroup parses an expression between parentheses
+func (p *parser) parseGroup(primary func() (Node, error)) (Node, error) {
+	open := p.next()
+	if p.peek().kind == tokRParen {
+		return nil, p.errorf(p.peek(), "empty parentheses")
+	}
+	node, err := p.parseAnd(primary)
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokRParen {
+		line := newSyntaxError(p.filter, open.offset, "")
+		return nil, p.errorf(t, `expected ")" to close the "(" at line %d, column %d, found %s`, line.Line, line.Column, t)
+	}
+	p.next()
+	return node, nil
+}
+
+// parsePath parses a field path, whose segments can be quoted, e.g. labels."k8s-pod/app"
+func (p *parser) parsePath() *Value {
+	first := p.next()
+	if first.kind == tokString {
+		return &Value{Text: first.text, Quoted: true}
+	}
+	var b strings.Builder
+	b.WriteString(first.text)
+	for {
+		t := p.peek()
+		if !t.adjacent || !strings.HasSuffix(b.String(), ".") && !(t.kind == tokWord && strings.HasPrefix(t.text, ".")) {
+			break
+		}
+		switch t.kind {
+		case tokString:
+			fmt.Fprintf(&b, "%q", t.text)
+		case tokWord:
+			b.WriteString(t.text)
+		default:
+			return &Value{Text: b.String()}
+		}
+		p.next()
+	}
+	return &Value{Text: b.String()}
+}
+
+// parseComparisonValue parses the value after a comparison operator
+func (p *parser) parseComparisonValue(op token) (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokLParen:
+		return p.parseGroup(p.parseValue)
+	case tokString, tokWord:
+		if isKeyword(t, "AND") || isKeyword(t, "OR") || isKeyword(t, "NOT") {
+			return nil, p.errorf(t, "expected a value after %q, found %s", op.text, t)
+		}
+		return p.parseValue()
+	default:
+		return nil, p.errorf(t, "expected a value after %q, found %s", op.text, t)
+	}
+}
+
+// parseValue parses a literal or a function call, in values and arguments
+func (p *parser) parseValue() (Node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tokString:
+		p.next()
+		return &Value{Text: t.text, Quoted: true}, nil
+	case tokWord:
+		if p.tokens[p.pos+1].kind == tokLParen && p.tokens[p.pos+1].adjacent {
+			return p.parseCall()
+		}
+		p.next()
+		return &Value{Text: t.text}, nil
+	case tokLParen:
+		return p.parseGroup(p.parseValue)
+	default:
+		return nil, p.errorf(t, "expected a value, found %s", t)
+	}
+}
+
+// parseCall parses a function call, e.g. sample(insertId, 0.25)
+func (p *parser) parseCall() (Node, error) {
+	name := p.next()
+	p.next()
+	call := &Call{Name: name.text}
+	if p.peek().kind == tokRParen {
+		p.next()
+		return call, nil
+	}
+	for {
+		t := p.peek()
+		if t.kind != tokString && t.kind != tokWord {
+			return nil, p.errorf(t, "expected an argument of %s, found %s", name.text, t)
+		}
+		var arg Node
+		if t.kind == tokWord && p.tokens[p.pos+1].kind == tokLParen && p.tokens[p.pos+1].adjacent {
+			var err error
+			if arg, err = p.parseCall(); err != nil {
+				return nil, err
+			}
+		} else {
+			arg = p.parsePath()
+		}
+		call.Args = append(call.Args, arg)
+		switch t := p.next(); t.kind {
+		case tokComma:
+		case tokRParen:
+			return call, nil
+		default:
+			return nil, p.errorf(t, `expected "," or ")" in the arguments of %s, found %s`, name.text, t)
+		}
+	}
+}
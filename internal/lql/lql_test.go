@@ -0,0 +1,71 @@
+package lql
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"", "<nil>"},
+		{"severity>=ERROR", "severity>=ERROR"},
+		{`resource.type="gce_instance" severity>=WARNING`, `(resource.type="gce_instance" AND severity>=WARNING)`},
+		{"a OR b AND c", "((a OR b) AND c)"},
+		{"NOT a OR -b", "(NOT a OR NOT b)"},
+		{`-logName:"healthz"`, `NOT logName:"healthz"`},
+		{`-(a AND b)`, `NOT (a AND b)`},
+		{"severity=(ERROR OR WARNING)", "severity=(ERROR OR WARNING)"},
+		{`labels."k8s-pod/app"="web"`, `labels."k8s-pod/app"="web"`},
+		{`jsonPayload.message=~"time.?out" "disk full"`, `(jsonPayload.message=~"time.?out" AND "disk full")`},
+		{`sample(insertId, 0.25) log_id("stdout")`, `(sample(insertId, 0.25) AND log_id("stdout"))`},
+		{"timestamp>=2025-01-01T00:00:00Z", "timestamp>=2025-01-01T00:00:00Z"},
+		{"(a OR b)\nc", "((a OR b) AND c)"},
+		{"-- errors only\nseverity>=ERROR -- and above", "severity>=ERROR"},
+	}
+	for _, c := range cases {
+		node, err := Parse(c.input)
+		if err != nil {
+			t.Errorf("Parse(%q) unexpected error: %v", c.input, err)
+			continue
+		}
+		got := "<nil>"
+		if node != nil {
+			got = node.String()
+		}
+		if got != c.expected {
+			t.Errorf("Parse(%q) = %s, want %s", c.input, got, c.expected)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		input        string
+		line, column int
+		msg          string
+	}{
+		{"severity=", 1, 10, `expected a value after "=", found end of filter`},
+		{`textPayload:"oops`, 1, 13, "unterminated string"},
+		{"(a OR b", 1, 8, `expected ")" to close the "(" at line 1, column 1, found end of filter`},
+		{"a AND", 1, 6, "expected a term after AND, found end of filter"},
+		{"a)", 1, 2, `unexpected ")"`},
+		{"severity>=ERROR AND\n(=x)", 2, 2, `expected a field before "="`},
+		{"severity ! ERROR", 1, 10, `unexpected '!', comparison operators are =, !=, <, <=, >, >=, :, =~ and !~`},
+		{"()", 1, 2, "empty parentheses"},
+		{"f(a b)", 1, 5, `expected "," or ")" in the arguments of f, found "b"`},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Errorf("Parse(%q) = %v, want a syntax error", c.input, err)
+			continue
+		}
+		if syntaxErr.Line != c.line || syntaxErr.Column != c.column || syntaxErr.Msg != c.msg {
+			t.Errorf("Parse(%q) error = %d:%d %s, want %d:%d %s", c.input, syntaxErr.Line, syntaxErr.Column, syntaxErr.Msg, c.line, c.column, c.msg)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package lql
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Matcher evaluates a filter against entries in their JSON representation, for the entries read
+// from files instead of the API. It approximates the API: global restrictions and the : operator
+// match substrings, ignoring case, where the API matches tokens.
+type Matcher struct {
+	root    Node
+	regexps map[string]*regexp.Regexp
+}
+
+// severities ranks the severity names, for ordered comparisons
+var severities = map[string]int{
+	"DEFAULT": 0, "DEBUG": 100, "INFO": 200, "NOTICE": 300, "WARNING": 400,
+	"ERROR": 500, "CRITICAL": 600, "ALERT": 700, "EMERGENCY": 800,
+}
+
+// timeLayouts are the layouts of the timestamps compared as times
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// NewMatcher returns a matcher of the filter parsed into node, nil matching every entry. Errors
+// report the regular expressions that do not compile and the functions that cannot be evaluated.
+func NewMatcher(node Node) (*Matcher, error) {
+	m := &Matcher{root: node, regexps: map[string]*regexp.Regexp{}}
+	if node == nil {
+		return m, nil
+	}
+	if err := m.compile(node); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// compile checks the terms of node, compiling the regular expressions
+func (m *Matcher) compile(node Node) error {
+	switch n := node.(type) {
+	case *And:
+		return m.compileAll(n.Terms)
+	case *Or:
+		return m.compileAll(n.Terms)
+	case *Not:
+		return m.compile(n.Term)
+	case *Search:
+		return m.compileValue(n.Value, "")
+	case *Call:
+		return checkCall(n)
+	case *Comparison:
+		return m.compileValue(n.Value, n.Op)
+	}
+	return nil
+}
+
+func (m *Matcher) compileAll(nodes []Node) error {
+	for _, n := range nodes {
+		if err := m.compile(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// compileValue checks the values of a comparison with op
+func (m *Matcher) compileValue(node Node, op string) error {
+	switch n := node.(type) {
+	case *And:
+		for _, term := range n.Terms {
+			if err := m.compileValue(term, op); err != nil {
+				return err
+			}
+		}
+	case *Or:
+		for _, term := range n.Terms {
+			if err := m.compileValue(term, op); err != nil {
+				return err
+			}
+		}
+	case *Not:
+		return m.compileValue(n.Term, op)
+	case *Call:
+		return fmt.Errorf("function %s cannot be used as a value", n.Name)
+	case *Value:
+		if op == "=~" || op == "!~" {
+			re, err := regexp.Compile(n.Text)
+			if err != nil {
+				return fmt.Errorf("invalid regular expression %q: %w", n.Text, err)
+			}
+			m.regexps[n.Text] = re
+		}
+	}
+	return nil
+}
+
+// checkCall checks that a function can be evaluated, with valid arguments
+func checkCall(call *Call) error {
+	switch call.Name {
+	case "log_id":
+		if len(call.Args) != 1 {
+			return fmt.Errorf("log_id expects 1 argument, got %d", len(call.Args))
+		}
+	case "sample":
+		if len(call.Args) != 2 {
+			return fmt.Errorf("sample expects 2 arguments, got %d", len(call.Args))
+		}
+		if fraction, err := strconv.ParseFloat(argText(call.Args[1]), 64); err != nil || fraction < 0 || fraction > 1 {
+			return fmt.Errorf("invalid fraction %s of sample, expected a number between 0 and 1", call.Args[1])
+		}
+	case "ip_in_net":
+		if len(call.Args) != 2 {
+			return fmt.Errorf("ip_in_net expects 2 arguments, got %d", len(call.Args))
+		}
+		if _, err := netip.ParsePrefix(argText(call.Args[1])); err != nil {
+			return fmt.Errorf("invalid network %s of ip_in_net: %w", call.Args[1], err)
+		}
+	default:
+		return fmt.Errorf("function %s cannot be evaluated locally", call.Name)
+	}
+	return nil
+}
+
+// argText returns the text of a literal argument
+func argText(arg Node) string {
+	if v, ok := arg.(*Value); ok {
+		return v.Text
+	}
+	return arg.String()
+}
+
+// Match reports whether the entry, decoded from its JSON representation, matches the filter.
+func (m *Matcher) Match(entry map[string]any) bool {
+	if m.root == nil {
+		return true
+	}
+	return m.eval(m.root, entry)
+}
+
+func (m *Matcher) eval(node Node, entry map[string]any) bool {
+	switch n := node.(type) {
+	case *And:
+		for _, term := range n.Terms {
+			if !m.eval(term, entry) {
+				return false
+			}
+		}
+		return true
+	case *Or:
+		for _, term := range n.Terms {
+			if m.eval(term, entry) {
+				return true
+			}
+		}
+		return false
+	case *Not:
+		return !m.eval(n.Term, entry)
+	case *Search:
+		return evalValue(n.Value, func(v *Value) bool { return containsText(entry, strings.ToLower(v.Text)) })
+	case *Call:
+		return evalCall(n, entry)
+	case *Comparison:
+		fields := lookup(entry, SplitPath(n.Path))
+		severity := n.Path == "severity"
+		return evalValue(n.Value, func(v *Value) bool {
+			for _, field := range fields {
+				if m.compare(field, n.Op, v.Text, severity) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	return false
+}
+
+// evalValue evaluates the values of a comparison, combined with AND, OR and NOT, with match
+func evalValue(node Node, match func(*Value) bool) bool {
+	switch n := node.(type) {
+	case *And:
+		for _, term := range n.Terms {
+			if !evalValue(term, match) {
+				return false
+			}
+		}
+		return true
+	case *Or:
+		for _, term := range n.Terms {
+			if evalValue(term, match) {
+				return true
+			}
+		}
+		return false
+	case *Not:
+		return !evalValue(n.Term, match)
+	case *Value:
+		return match(n)
+	}
+	return false
+}
+
+// compare compares a field of an entry with a value of the filter
+func (m *Matcher) compare(field any, op, value string, severity bool) bool {
+	text := stringify(field)
+	switch op {
+	case ":":
+		return value == "*" || strings.Contains(strings.ToLower(text), strings.ToLower(value))
+	case "=~":
+		return m.regexps[value].MatchString(text)
+	case "!~":
+		return !m.regexps[value].MatchString(text)
+	}
+
+	var c int
+	if severity {
+		c = compareSeverities(text, value)
+	} else {
+		c = compareValues(text, value)
+	}
+	switch op {
+	case "=":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// compareSeverities compares severities by their rank, given by name or number
+func compareSeverities(a, b string) int {
+	return compareNumbers(severityRank(a), severityRank(b))
+}
+
+func severityRank(s string) float64 {
+	if rank, ok := severities[strings.ToUpper(s)]; ok {
+		return float64(rank)
+	}
+	rank, _ := strconv.ParseFloat(s, 64)
+	return rank
+}
+
+// compareValues compares two values as times, numbers or strings, whichever both parse as
+func compareValues(a, b string) int {
+	if ta, ok := parseTime(a); ok {
+		if tb, ok := parseTime(b); ok {
+			return ta.Compare(tb)
+		}
+	}
+	if na, err := strconv.ParseFloat(a, 64); err == nil {
+		if nb, err := strconv.ParseFloat(b, 64); err == nil {
+			return compareNumbers(na, nb)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func parseTime(s string) (time.Time, bool) {
+	// Shortcut the strings that cannot be dates.
+	if len(s) < 10 || s[4] != '-' {
+		return time.Time{}, false
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// evalCall evaluates the functions accepted by checkCall
+func evalCall(call *Call, entry map[string]any) bool {
+	switch call.Name {
+	case "log_id":
+		// The log IDs are URL-encoded in the log names, e.g. cloudaudit.googleapis.com%2Factivity.
+		logName, _ := entry["logName"].(string)
+		return strings.HasSuffix(logName, "/logs/"+url.PathEscape(argText(call.Args[0])))
+	case "sample":
+		fields := lookup(entry, SplitPath(argText(call.Args[0])))
+		if len(fields) == 0 {
+			return false
+		}
+		fraction, _ := strconv.ParseFloat(argText(call.Args[1]), 64)
+		h := fnv.New64a()
+		h.Write([]byte(stringify(fields[0])))
+		return float64(h.Sum64()) < fraction*math.MaxUint64
+	case "ip_in_net":
+		network, _ := netip.ParsePrefix(argText(call.Args[1]))
+		for _, field := range lookup(entry, SplitPath(argText(call.Args[0]))) {
+			if addr, err := netip.ParseAddr(stringify(field)); err == nil && network.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SplitPath splits a field path into its segments, unquoting the quoted ones, e.g.
+// labels."k8s-pod/app" into labels and k8s-pod/app.
+func SplitPath(path string) []string {
+	var segments []string
+	for path != "" {
+		if path[0] == '"' {
+			if text, n, ok := unquote(path); ok {
+				segments = append(segments, text)
+				path = strings.TrimPrefix(path[n:], ".")
+				continue
+			}
+		}
+		segment, rest, _ := strings.Cut(path, ".")
+		segments = append(segments, segment)
+		path = rest
+	}
+	return segments
+}
+
+// lookup returns the values at the path in the entry, several through arrays, none when the field
+// is missing
+func lookup(value any, path []string) []any {
+	if len(path) == 0 {
+		if values, ok := value.([]any); ok {
+			return values
+		}
+		return []any{value}
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		field, ok := v[path[0]]
+		if !ok {
+			// The API accepts the names of the proto fields too, e.g. http_request.
+			field, ok = v[camelCase(path[0])]
+		}
+		if !ok {
+			return nil
+		}
+		return lookup(field, path[1:])
+	case []any:
+		var values []any
+		for _, item := range v {
+			values = append(values, lookup(item, path)...)
+		}
+		return values
+	}
+	return nil
+}
+
+// camelCase converts a snake_case name to camelCase
+func camelCase(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// stringify returns the text of a JSON value, as compared to the values of the filter
+func stringify(value any) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return ""
+	}
+	b, _ := json.Marshal(value)
+	return string(b)
+}
+
+// containsText reports whether any string or number in value contains the lowercase text
+func containsText(value any, text string) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		for _, field := range v {
+			if containsText(field, text) {
+				return true
+			}
+		}
+		return false
+	case []any:
+		for _, item := range v {
+			if containsText(item, text) {
+				return true
+			}
+		}
+		return false
+	}
+	return strings.Contains(strings.ToLower(stringify(value)), text)
+}
@@ -0,0 +1,92 @@
+package lql
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	var entry map[string]any
+	decoder := json.NewDecoder(strings.NewReader(`{
+		"logName": "projects/p/logs/cloudaudit.googleapis.com%2Factivity",
+		"severity": "WARNING",
+		"timestamp": "2025-01-02T15:04:05.123Z",
+		"labels": {"k8s-pod/app": "web"},
+		"httpRequest": {"status": 503, "remoteIp": "10.1.2.3", "requestSize": "2048"},
+		"jsonPayload": {"message": "Upstream timed out", "tags": ["a", "b"]}
+	}`))
+	decoder.UseNumber()
+	if err := decoder.Decode(&entry); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		filter   string
+		expected bool
+	}{
+		{"", true},
+		{"severity>=WARNING", true},
+		{"severity>ERROR", false},
+		{"severity=(ERROR OR WARNING)", true},
+		{"severity>=400", true},
+		{`labels."k8s-pod/app"="web"`, true},
+		{"httpRequest.status>=500 httpRequest.status<600", true},
+		{"http_request.status=503", true},
+		{"httpRequest.requestSize>1000", true},
+		{`timestamp>="2025-01-02T15:00:00Z" timestamp<"2025-01-02T16:00:00+01:00"`, false},
+		{"timestamp>=2025-01-02", true},
+		{`jsonPayload.message:"timed OUT"`, true},
+		{`jsonPayload.message="timed out"`, false},
+		{`jsonPayload.message=~"^Up.*out$"`, true},
+		{`jsonPayload.message!~"^Up"`, false},
+		{"jsonPayload.tags=b", true},
+		{"jsonPayload.missing:*", false},
+		{"NOT jsonPayload.missing:*", true},
+		{"jsonPayload.missing!=x", false},
+		{`"upstream"`, true},
+		{"upstream OR nothing", true},
+		{"upstream nothing", false},
+		{"-upstream", false},
+		{`log_id("cloudaudit.googleapis.com/activity")`, true},
+		{`log_id("stdout")`, false},
+		{`ip_in_net(httpRequest.remoteIp, "10.0.0.0/8")`, true},
+		{`ip_in_net(httpRequest.remoteIp, "192.168.0.0/16")`, false},
+		{"sample(insertId, 1)", false},
+		{"sample(logName, 1)", true},
+		{"sample(logName, 0)", false},
+	}
+	for _, c := range cases {
+		node, err := Parse(c.filter)
+		if err != nil {
+			t.Fatalf("Parse(%q) unexpected error: %v", c.filter, err)
+		}
+		m, err := NewMatcher(node)
+		if err != nil {
+			t.Errorf("NewMatcher(%q) unexpected error: %v", c.filter, err)
+			continue
+		}
+		if got := m.Match(entry); got != c.expected {
+			t.Errorf("Match(%q) = %v, want %v", c.filter, got, c.expected)
+		}
+	}
+}
+
+func TestNewMatcherErrors(t *testing.T) {
+	for _, filter := range []string{`textPayload=~"("`, `source("a")`, "sample(insertId, 2)", `ip_in_net(a, "x")`, "severity=f(x)"} {
+		node, err := Parse(filter)
+		if err != nil {
+			t.Fatalf("Parse(%q) unexpected error: %v", filter, err)
+		}
+		if _, err := NewMatcher(node); err == nil {
+			t.Errorf("NewMatcher(%q) succeeded, want an error", filter)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	got := strings.Join(SplitPath(`labels."k8s-pod/app".x`), "|")
+	if got != "labels|k8s-pod/app|x" {
+		t.Errorf("SplitPath() = %s", got)
+	}
+}
@@ -0,0 +1,327 @@
+// Package mmdb reads databases in the MaxMind DB format, like the GeoLite2 country, city and ASN
+// databases, as described in https://maxmind.github.io/MaxMind-DB/.
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
+	"os"
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between the search tree and the data section
+const dataSectionSeparator = 16
+
+// Metadata describes a database.
+type Metadata struct {
+	DatabaseType string
+	IPVersion    int
+	NodeCount    int
+	RecordSize   int
+}
+
+// Reader looks up IP addresses in a database loaded in memory.
+type Reader struct {
+	Metadata Metadata
+	buf      []byte
+	data     []byte
+	// ipv4Start is the node where the IPv4 addresses start in IPv6 databases.
+	ipv4Start int
+}
+
+// Open loads the database at path.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	r, err := New(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
+
+// New reads a database from its content.
+func New(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, errors.New("not a MaxMind DB: metadata not found")
+	}
+	metadataSection := buf[i+len(metadataMarker):]
+	value, _, err := decoder{metadataSection}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid metadata: %w", err)
+	}
+	fields, ok := value.(map[string]any)
+	if !ok {
+		return nil, errors.New("invalid metadata: not a map")
+	}
+
+	r := &Reader{buf: buf}
+	r.Metadata.DatabaseType, _ = fields["database_type"].(string)
+	r.Metadata.IPVersion = int(asUint(fields["ip_version"]))
+	r.Metadata.NodeCount = int(asUint(fields["node_count"]))
+	r.Metadata.RecordSize = int(asUint(fields["record_size"]))
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("unsupported record size %d", r.Metadata.RecordSize)
+	}
+	if r.Metadata.IPVersion != 4 && r.Metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("unsupported IP version %d", r.Metadata.IPVersion)
+	}
+
+	treeSize := r.Metadata.RecordSize * 2 / 8 * r.Metadata.NodeCount
+	if treeSize+dataSectionSeparator > i {
+		return nil, errors.New("search tree larger than the database")
+	}
+	r.data = buf[treeSize+dataSectionSeparator : i]
+
+	if r.Metadata.IPVersion == 6 {
+		for bit := 0; bit < 96 && r.ipv4Start < r.Metadata.NodeCount; bit++ {
+			r.ipv4Start = r.record(r.ipv4Start, 0)
+		}
+	}
+	return r, nil
+}
+
+func asUint(v any) uint64 {
+	switch v := v.(type) {
+	case uint64:
+		return v
+	case int64:
+		return uint64(v)
+	}
+	return 0
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a node of the search tree
+func (r *Reader) record(node, bit int) int {
+	size := r.Metadata.RecordSize
+	b := r.buf[node*size/4:]
+	switch size {
+	case 24:
+		b = b[bit*3:]
+		return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+	case 28:
+		if bit == 0 {
+			return int(b[3]&0xf0)<<20 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+		}
+		return int(b[3]&0x0f)<<24 | int(b[4])<<16 | int(b[5])<<8 | int(b[6])
+	default:
+		return int(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// Lookup returns the record of the network containing the address, or nil if there is none.
+func (r *Reader) Lookup(addr netip.Addr) (any, error) {
+	addr = addr.Unmap()
+	node := 0
+	var ip []byte
+	switch {
+	case addr.Is4() && r.Metadata.IPVersion == 6:
+		node = r.ipv4Start
+		ip = addr.AsSlice()
+	case addr.Is4():
+		ip = addr.AsSlice()
+	case r.Metadata.IPVersion == 4:
+		return nil, fmt.Errorf("IPv6 address %s in an IPv4 database", addr)
+	default:
+		ip = addr.AsSlice()
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := 0; i < len(ip)*8 && node < nodeCount; i++ {
+		bit := int(ip[i/8]>>(7-i%8)) & 1
+		node = r.record(node, bit)
+	}
+	switch {
+	case node == nodeCount:
+		return nil, nil
+	case node < nodeCount:
+		return nil, errors.New("invalid search tree: address longer than the tree")
+	}
+	offset := node - nodeCount - dataSectionSeparator
+	if offset < 0 || offset >= len(r.data) {
+		return nil, errors.New("invalid search tree: record outside of the data section")
+	}
+	value, _, err := decoder{r.data}.decode(offset, 0)
+	return value, err
+}
+
+// Data types of the data section.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes the values of a data section
+type decoder struct {
+	buf []byte
+}
+
+var errTruncated = errors.New("invalid data section: truncated value")
+
+// maxDepth is how deeply maps, arrays and pointers can nest, far more than the databases need,
+// so that pointer cycles of corrupted or malicious databases do not recurse without end
+const maxDepth = 32
+
+// decode returns the value at offset, nested depth levels deep, and the offset following it
+func (d decoder) decode(offset, depth int) (any, int, error) {
+	if depth > maxDepth {
+		return nil, 0, fmt.Errorf("invalid data section: values nested more than %d levels deep", maxDepth)
+	}
+	if offset >= len(d.buf) {
+		return nil, 0, errTruncated
+	}
+	ctrl := d.buf[offset]
+	offset++
+	kind := int(ctrl >> 5)
+	if kind == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(pointer, depth+1)
+		return value, next, err
+	}
+	if kind == typeExtended {
+		if offset >= len(d.buf) {
+			return nil, 0, errTruncated
+		}
+		kind = 7 + int(d.buf[offset])
+		offset++
+	}
+
+	size := int(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > len(d.buf) {
+			return nil, 0, errTruncated
+		}
+		extra := 0
+		for _, b := range d.buf[offset : offset+n] {
+			extra = extra<<8 | int(b)
+		}
+		offset += n
+		switch size {
+		case 29:
+			size = 29 + extra
+		case 30:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	switch kind {
+	case typeMap:
+		m := make(map[string]any, size)
+		for range size {
+			key, next, err := d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("invalid data section: map key is not a string")
+			}
+			if m[k], offset, err = d.decode(next, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, size)
+		for i := range a {
+			var err error
+			if a[i], offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	if offset+size > len(d.buf) {
+		return nil, 0, errTruncated
+	}
+	payload := d.buf[offset : offset+size]
+	offset += size
+	switch kind {
+	case typeString:
+		return string(payload), offset, nil
+	case typeBytes:
+		return bytes.Clone(payload), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid data section: double of the wrong size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(payload)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid data section: float of the wrong size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(payload))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		var u uint64
+		for _, b := range payload {
+			u = u<<8 | uint64(b)
+		}
+		return u, offset, nil
+	case typeInt32:
+		var u uint32
+		for _, b := range payload {
+			u = u<<8 | uint32(b)
+		}
+		return int64(int32(u)), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(payload), offset, nil
+	default:
+		return nil, 0, fmt.Errorf("invalid data section: unsupported type %d", kind)
+	}
+}
+
+// pointer returns the offset a pointer points to and the offset following the pointer
+func (d decoder) pointer(ctrl byte, offset int) (int, int, error) {
+	n := int(ctrl>>3&0x3) + 1
+	if offset+n > len(d.buf) {
+		return 0, 0, errTruncated
+	}
+	b := d.buf[offset : offset+n]
+	vvv := int(ctrl & 0x7)
+	var pointer int
+	switch n {
+	case 1:
+		pointer = vvv<<8 | int(b[0])
+	case 2:
+		pointer = (vvv<<16 | int(b[0])<<8 | int(b[1])) + 2048
+	case 3:
+		pointer = (vvv<<24 | int(b[0])<<16 | int(b[1])<<8 | int(b[2])) + 526336
+	default:
+		pointer = int(binary.BigEndian.Uint32(b))
+	}
+	return pointer, offset + n, nil
+}
@@ -0,0 +1,175 @@
+package mmdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net/netip"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// encode serializes maps, strings and unsigned integers in the data section format
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		slices.Sort(keys)
+		b := []byte{typeMap<<5 | byte(len(v))}
+		for _, k := range keys {
+			b = append(b, encode(k)...)
+			b = append(b, encode(v[k])...)
+		}
+		return b
+	case string:
+		if len(v) >= 29 {
+			return append([]byte{typeString<<5 | 29, byte(len(v) - 29)}, v...)
+		}
+		return append([]byte{typeString<<5 | byte(len(v))}, v...)
+	case uint32:
+		return binary.BigEndian.AppendUint32([]byte{typeUint32<<5 | 4}, v)
+	case []byte:
+		// Raw bytes, e.g. a pointer.
+		return v
+	}
+	panic("unsupported type")
+}
+
+// build returns a database mapping the prefixes to the records at the data offsets
+func build(ipVersion, recordSize int, prefixes map[netip.Prefix]int, data []byte) []byte {
+	const empty = -1
+	nodes := [][2]int{{empty, empty}}
+	const dataFlag = 1 << 30
+	for prefix, offset := range prefixes {
+		ip := prefix.Addr().AsSlice()
+		if ipVersion == 6 && prefix.Addr().Is4() {
+			ip = append(make([]byte, 12), ip...)
+		}
+		bits := prefix.Bits()
+		if ipVersion == 6 && prefix.Addr().Is4() {
+			bits += 96
+		}
+		node := 0
+		for i := range bits {
+			bit := int(ip[i/8]>>(7-i%8)) & 1
+			if i == bits-1 {
+				nodes[node][bit] = dataFlag | offset
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	var tree []byte
+	for _, n := range nodes {
+		var records [2]int
+		for i, r := range n {
+			switch {
+			case r == empty:
+				records[i] = nodeCount
+			case r&dataFlag != 0:
+				records[i] = nodeCount + dataSectionSeparator + r&^dataFlag
+			default:
+				records[i] = r
+			}
+		}
+		switch recordSize {
+		case 24:
+			for _, r := range records {
+				tree = append(tree, byte(r>>16), byte(r>>8), byte(r))
+			}
+		case 28:
+			tree = append(tree, byte(records[0]>>16), byte(records[0]>>8), byte(records[0]),
+				byte(records[0]>>20&0xf0|records[1]>>24&0x0f),
+				byte(records[1]>>16), byte(records[1]>>8), byte(records[1]))
+		case 32:
+			tree = binary.BigEndian.AppendUint32(tree, uint32(records[0]))
+			tree = binary.BigEndian.AppendUint32(tree, uint32(records[1]))
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(tree)
+	buf.Write(make([]byte, dataSectionSeparator))
+	buf.Write(data)
+	buf.Write(metadataMarker)
+	buf.Write(encode(map[string]any{
+		"database_type": "Test",
+		"ip_version":    uint32(ipVersion),
+		"node_count":    uint32(nodeCount),
+		"record_size":   uint32(recordSize),
+	}))
+	return buf.Bytes()
+}
+
+func TestLookup(t *testing.T) {
+	australia := encode(map[string]any{"country": map[string]any{"iso_code": "AU"}})
+	// The second record points to the string "AU" of the first one, after the map, key and nested map headers.
+	pointer := len(encode(map[string]any{"country": map[string]any{}})) - 1 + len(encode("iso_code"))
+	google := encode(map[string]any{
+		"autonomous_system_number":       uint32(15169),
+		"autonomous_system_organization": "GOOGLE",
+		"registered":                     []byte{typePointer << 5, byte(pointer + 1)},
+	})
+	data := append(slices.Clone(australia), google...)
+	prefixes := map[netip.Prefix]int{
+		netip.MustParsePrefix("1.0.0.0/8"):  0,
+		netip.MustParsePrefix("8.8.8.0/24"): len(australia),
+	}
+
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			r, err := New(build(ipVersion, recordSize, prefixes, data))
+			if err != nil {
+				t.Fatalf("New(IPv%d, %d bits) = %v", ipVersion, recordSize, err)
+			}
+			if r.Metadata.DatabaseType != "Test" || r.Metadata.IPVersion != ipVersion {
+				t.Errorf("Metadata = %+v", r.Metadata)
+			}
+
+			cases := map[string]any{
+				"1.2.3.4":        map[string]any{"country": map[string]any{"iso_code": "AU"}},
+				"::ffff:1.2.3.4": map[string]any{"country": map[string]any{"iso_code": "AU"}},
+				"8.8.8.8": map[string]any{
+					"autonomous_system_number":       uint64(15169),
+					"autonomous_system_organization": "GOOGLE",
+					"registered":                     "AU",
+				},
+				"8.8.4.4": nil,
+				"2.0.0.1": nil,
+			}
+			for ip, expected := range cases {
+				got, err := r.Lookup(netip.MustParseAddr(ip))
+				if err != nil || !reflect.DeepEqual(got, expected) {
+					t.Errorf("IPv%d, %d bits: Lookup(%s) = %v, %v, want %v", ipVersion, recordSize, ip, got, err, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New([]byte("not a database")); err == nil {
+		t.Error("New() of garbage succeeded")
+	}
+}
+
+func TestLookupPointerCycle(t *testing.T) {
+	// The record contains a pointer to itself.
+	data := encode(map[string]any{"loop": []byte{typePointer << 5, 0}})
+	r, err := New(build(4, 24, map[netip.Prefix]int{netip.MustParsePrefix("1.0.0.0/8"): 0}, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := r.Lookup(netip.MustParseAddr("1.2.3.4")); err == nil {
+		t.Errorf("Lookup() of a pointer cycle = %v, want an error", got)
+	}
+}
@@ -0,0 +1,301 @@
+// Package otlp exports log records to OpenTelemetry collectors over OTLP/gRPC, as described in
+// https://opentelemetry.io/docs/specs/otlp/. The messages of opentelemetry/proto/collector/logs/v1
+// are encoded by hand with protowire, the subset written here not being worth generated code.
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// exportMethod is the method of the logs service of the collectors.
+const exportMethod = "/opentelemetry.proto.collector.logs.v1.LogsService/Export"
+
+// Severity numbers of the log data model, see
+// https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber.
+const (
+	SeverityUnspecified = 0
+	SeverityDebug       = 5
+	SeverityInfo        = 9
+	SeverityInfo2       = 10
+	SeverityWarn        = 13
+	SeverityError       = 17
+	SeverityError2      = 18
+	SeverityError3      = 19
+	SeverityFatal       = 21
+)
+
+// FlagSampled is the W3C sampled flag of Record.Flags.
+const FlagSampled = 1
+
+// KeyValue is an attribute. Its value, like the bodies of the records, is nil, a string, bool, int64,
+// float64 or []byte, or a []any or map[string]any of those, written as an array or a key-value list.
+type KeyValue struct {
+	Key   string
+	Value any
+}
+
+// Record is a log record.
+type Record struct {
+	Time           time.Time
+	ObservedTime   time.Time
+	SeverityNumber int32
+	SeverityText   string
+	Body           any
+	Attributes     []KeyValue
+	// TraceID and SpanID are the 16 and 8 bytes of the IDs, or empty.
+	TraceID []byte
+	SpanID  []byte
+	Flags   uint32
+}
+
+// ResourceLogs are the records of a resource.
+type ResourceLogs struct {
+	Attributes []KeyValue
+	Records    []Record
+}
+
+// Scope identifies the instrumentation scope, the application exporting the records.
+type Scope struct {
+	Name    string
+	Version string
+}
+
+// MarshalRequest returns the ExportLogsServiceRequest of logs, all in the same scope.
+func MarshalRequest(scope Scope, logs []ResourceLogs) []byte {
+	var request []byte
+	for _, resourceLogs := range logs {
+		var resource []byte
+		for _, attribute := range resourceLogs.Attributes {
+			resource = appendMessage(resource, 1, appendKeyValue(nil, attribute))
+		}
+
+		var scopeLogs []byte
+		scopeLogs = appendMessage(scopeLogs, 1, appendString(appendString(nil, 1, scope.Name), 2, scope.Version))
+		for _, record := range resourceLogs.Records {
+			scopeLogs = appendMessage(scopeLogs, 2, appendRecord(nil, record))
+		}
+
+		var message []byte
+		message = appendMessage(message, 1, resource)
+		message = appendMessage(message, 2, scopeLogs)
+		request = appendMessage(request, 1, message)
+	}
+	return request
+}
+
+func appendRecord(b []byte, r Record) []byte {
+	b = appendTime(b, 1, r.Time)
+	if r.SeverityNumber != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.SeverityNumber))
+	}
+	b = appendString(b, 3, r.SeverityText)
+	if r.Body != nil {
+		b = appendMessage(b, 5, appendValue(nil, r.Body))
+	}
+	for _, attribute := range r.Attributes {
+		b = appendMessage(b, 6, appendKeyValue(nil, attribute))
+	}
+	if r.Flags != 0 {
+		b = protowire.AppendTag(b, 8, protowire.Fixed32Type)
+		b = protowire.AppendFixed32(b, r.Flags)
+	}
+	if len(r.TraceID) > 0 {
+		b = appendMessage(b, 9, r.TraceID)
+	}
+	if len(r.SpanID) > 0 {
+		b = appendMessage(b, 10, r.SpanID)
+	}
+	return appendTime(b, 11, r.ObservedTime)
+}
+
+func appendKeyValue(b []byte, kv KeyValue) []byte {
+	b = appendString(b, 1, kv.Key)
+	return appendMessage(b, 2, appendValue(nil, kv.Value))
+}
+
+// appendValue appends the fields of the AnyValue of v, none for nil and unsupported types.
+func appendValue(b []byte, v any) []byte {
+	switch v := v.(type) {
+	case string:
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendString(b, v)
+	case bool:
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(v))
+	case int64:
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(v))
+	case float64:
+		b = protowire.AppendTag(b, 4, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(v))
+	case []any:
+		var array []byte
+		for _, element := range v {
+			array = appendMessage(array, 1, appendValue(nil, element))
+		}
+		b = appendMessage(b, 5, array)
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		var list []byte
+		for _, key := range keys {
+			list = appendMessage(list, 1, appendKeyValue(nil, KeyValue{key, v[key]}))
+		}
+		b = appendMessage(b, 6, list)
+	case []byte:
+		b = appendMessage(b, 7, v)
+	}
+	return b
+}
+
+// appendMessage appends an embedded message, or bytes, even when empty: an empty AnyValue is a null.
+func appendMessage(b []byte, num protowire.Number, message []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, message)
+}
+
+// appendString appends a string field, omitted when empty like the proto3 defaults.
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendTime appends a fixed64 field of nanoseconds since the epoch, omitted for the zero time.
+func appendTime(b []byte, num protowire.Number, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, uint64(t.UnixNano()))
+}
+
+// PartialSuccessError reports the records a collector rejected.
+type PartialSuccessError struct {
+	Rejected int64
+	Message  string
+}
+
+func (e *PartialSuccessError) Error() string {
+	return fmt.Sprintf("%d records rejected: %s", e.Rejected, e.Message)
+}
+
+// Options configures a Client.
+type Options struct {
+	// Insecure disables TLS, for collectors listening in plaintext.
+	Insecure bool
+	// TLS configures the TLS connections, the system roots being trusted when nil.
+	TLS *tls.Config
+	// Headers are sent with every request, e.g. the API key of a backend.
+	Headers map[string]string
+	Scope   Scope
+}
+
+// Client exports records to a collector.
+type Client struct {
+	conn    *grpc.ClientConn
+	headers metadata.MD
+	scope   Scope
+}
+
+// NewClient returns a client of the collector at endpoint, a host:port address. It connects on the
+// first export.
+func NewClient(endpoint string, opts Options) (*Client, error) {
+	creds := credentials.NewTLS(opts.TLS)
+	if opts.Insecure {
+		creds = insecure.NewCredentials()
+	}
+	conn, err := grpc.NewClient(endpoint, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	return &Client{conn: conn, headers: metadata.New(opts.Headers), scope: opts.Scope}, nil
+}
+
+// Export sends logs in one request. It returns a *PartialSuccessError when the collector accepted
+// the request but rejected some of the records.
+func (c *Client) Export(ctx context.Context, logs []ResourceLogs) error {
+	request := MarshalRequest(c.scope, logs)
+	var response []byte
+	ctx = metadata.NewOutgoingContext(ctx, c.headers)
+	if err := c.conn.Invoke(ctx, exportMethod, &request, &response, grpc.ForceCodec(rawCodec{})); err != nil {
+		return err
+	}
+	return parseResponse(response)
+}
+
+// parseResponse returns the partial success of an ExportLogsServiceResponse, if any.
+func parseResponse(response []byte) error {
+	partial, _ := field(response, 1)
+	rejected, _ := field(partial, 1)
+	message, _ := field(partial, 2)
+	count, _ := protowire.ConsumeVarint(rejected)
+	if count == 0 && len(message) == 0 {
+		return nil
+	}
+	return &PartialSuccessError{Rejected: int64(count), Message: string(message)}
+}
+
+// field returns the last value of the field num of a message: the bytes of the length-delimited
+// fields, or the encoded varint.
+func field(message []byte, num protowire.Number) ([]byte, bool) {
+	var value []byte
+	found := false
+	for len(message) > 0 {
+		n, typ, length := protowire.ConsumeTag(message)
+		if length < 0 {
+			return nil, false
+		}
+		message = message[length:]
+		valueLength := protowire.ConsumeFieldValue(n, typ, message)
+		if valueLength < 0 {
+			return nil, false
+		}
+		if n == num {
+			value, found = message[:valueLength], true
+			if typ == protowire.BytesType {
+				value, _ = protowire.ConsumeBytes(value)
+			}
+		}
+		message = message[valueLength:]
+	}
+	return value, found
+}
+
+// Close closes the connection to the collector.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// rawCodec sends and receives messages already encoded, as *[]byte.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
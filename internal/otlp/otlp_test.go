@@ -0,0 +1,157 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// path returns the value at a path of field numbers, following their first occurrences
+func path(t *testing.T, message []byte, nums ...protowire.Number) []byte {
+	t.Helper()
+	for _, num := range nums {
+		values := all(message, num)
+		if len(values) == 0 {
+			t.Fatalf("field %v not found", nums)
+		}
+		message = values[0]
+	}
+	return message
+}
+
+// all returns the values of the field num of a message
+func all(message []byte, num protowire.Number) [][]byte {
+	var values [][]byte
+	for len(message) > 0 {
+		n, typ, length := protowire.ConsumeTag(message)
+		message = message[length:]
+		valueLength := protowire.ConsumeFieldValue(n, typ, message)
+		if n == num {
+			value := message[:valueLength]
+			if typ == protowire.BytesType {
+				value, _ = protowire.ConsumeBytes(value)
+			}
+			values = append(values, value)
+		}
+		message = message[valueLength:]
+	}
+	return values
+}
+
+func TestMarshalRequest(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	request := MarshalRequest(Scope{Name: "grapple", Version: "v1"}, []ResourceLogs{{
+		Attributes: []KeyValue{{"cloud.provider", "gcp"}},
+		Records: []Record{{
+			Time:           ts,
+			SeverityNumber: SeverityError,
+			SeverityText:   "ERROR",
+			Body:           map[string]any{"message": "down", "retries": []any{int64(1), 2.5, true, nil}},
+			TraceID:        make([]byte, 16),
+			Flags:          FlagSampled,
+		}},
+	}})
+
+	if value := path(t, request, 1, 1, 1, 2, 1); string(value) != "gcp" {
+		t.Errorf("resource attribute %q", value)
+	}
+	if name := path(t, request, 1, 2, 1, 1); string(name) != "grapple" {
+		t.Errorf("scope name %q", name)
+	}
+	record := path(t, request, 1, 2, 2)
+	if nanos, _ := protowire.ConsumeFixed64(path(t, record, 1)); nanos != uint64(ts.UnixNano()) {
+		t.Errorf("time %d", nanos)
+	}
+	if severity, _ := protowire.ConsumeVarint(path(t, record, 2)); severity != SeverityError {
+		t.Errorf("severity number %d", severity)
+	}
+	if len(all(record, 11)) > 0 {
+		t.Error("the zero observed time was written")
+	}
+	if traceID := path(t, record, 9); len(traceID) != 16 {
+		t.Errorf("trace ID of %d bytes", len(traceID))
+	}
+
+	// The keys of the body are sorted: message, then retries.
+	body := all(path(t, record, 5, 6), 1)
+	if len(body) != 2 {
+		t.Fatalf("%d keys in the body, want 2", len(body))
+	}
+	if value := path(t, body[0], 2, 1); string(value) != "down" {
+		t.Errorf("message %q", value)
+	}
+	elements := all(path(t, body[1], 2, 5), 1)
+	if len(elements) != 4 {
+		t.Fatalf("%d elements, want 4", len(elements))
+	}
+	if i, _ := protowire.ConsumeVarint(path(t, elements[0], 3)); i != 1 {
+		t.Errorf("int element %d", i)
+	}
+	if f, _ := protowire.ConsumeFixed64(path(t, elements[1], 4)); math.Float64frombits(f) != 2.5 {
+		t.Errorf("double element %v", math.Float64frombits(f))
+	}
+	if b, _ := protowire.ConsumeVarint(path(t, elements[2], 2)); b != 1 {
+		t.Errorf("bool element %d", b)
+	}
+	if len(elements[3]) != 0 {
+		t.Errorf("null element %v, want an empty value", elements[3])
+	}
+}
+
+func TestExport(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	received := make(chan []byte, 1)
+	var headers metadata.MD
+	server := grpc.NewServer(grpc.ForceServerCodec(rawCodec{}), grpc.UnknownServiceHandler(func(srv any, stream grpc.ServerStream) error {
+		method, _ := grpc.MethodFromServerStream(stream)
+		if method != exportMethod {
+			return errors.New("unexpected method " + method)
+		}
+		headers, _ = metadata.FromIncomingContext(stream.Context())
+		var request []byte
+		if err := stream.RecvMsg(&request); err != nil {
+			return err
+		}
+		received <- request
+		// A partial success rejecting 1 record.
+		partial := protowire.AppendVarint(protowire.AppendTag(nil, 1, protowire.VarintType), 1)
+		partial = protowire.AppendString(protowire.AppendTag(partial, 2, protowire.BytesType), "too old")
+		response := protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), partial)
+		return stream.SendMsg(&response)
+	}))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	client, err := NewClient(listener.Addr().String(), Options{Insecure: true, Headers: map[string]string{"api-key": "secret"}, Scope: Scope{Name: "grapple"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	logs := []ResourceLogs{{Records: []Record{{Body: "hello"}}}}
+	err = client.Export(context.Background(), logs)
+	var partial *PartialSuccessError
+	if !errors.As(err, &partial) || partial.Rejected != 1 || partial.Message != "too old" {
+		t.Errorf("Export() = %v, want the partial success", err)
+	}
+	if request := <-received; string(request) != string(MarshalRequest(Scope{Name: "grapple"}, logs)) {
+		t.Errorf("the collector received %x", request)
+	}
+	if key := headers.Get("api-key"); len(key) != 1 || key[0] != "secret" {
+		t.Errorf("api-key header %v", key)
+	}
+
+	if err := parseResponse(nil); err != nil {
+		t.Errorf("parseResponse(empty) = %v, want success", err)
+	}
+}
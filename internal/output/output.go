@@ -0,0 +1,253 @@
+// Package output implements the destinations the formatted log entries are written to:
+// stdout or a file, optionally compressed and rotated.
+package output
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported compression formats.
+const (
+	CompressAuto = "auto"
+	CompressNone = "none"
+	CompressGzip = "gzip"
+	CompressZstd = "zstd"
+)
+
+// Options configures an output destination.
+type Options struct {
+	// Path of the output file, empty or "-" for stdout.
+	Path string
+	// Compression is one of the Compress* constants. CompressAuto (or "")
+	// picks it from the file extension: .gz for gzip, .zst for zstd.
+	Compression string
+	// RotateSize rotates the file once this many (uncompressed) bytes were written to it, 0 disables it.
+	RotateSize int64
+	// RotateInterval rotates the file once it has been open this long, 0 disables it.
+	RotateInterval time.Duration
+	// Create, when set, opens objects of an object store, like Cloud Storage, instead of files. Objects
+	// cannot be renamed: a Path ending in "/" is a prefix, the objects being named after the time they
+	// are opened and rotated every DefaultObjectSize bytes unless set otherwise, and other paths name a
+	// single object, which cannot be rotated.
+	Create func(name string) (io.WriteCloser, error)
+	// Extension is appended to the names of the objects of a prefix, before the compression one.
+	Extension string
+	// Append writes after the content of an existing file instead of truncating it. Concatenated
+	// gzip members and zstd frames are valid streams. It is ignored for objects.
+	Append bool
+}
+
+// DefaultObjectSize is the size of the objects written to a prefix without rotation options.
+const DefaultObjectSize = 1 << 30
+
+// Open returns a writer for the destination described by opts. Writes are
+// expected to contain whole records, rotation never splits a single Write.
+// The writer must be closed to flush the compressor.
+func Open(opts Options) (io.WriteCloser, error) {
+	stdout := opts.Path == "" || opts.Path == "-"
+
+	compression, err := resolveCompression(opts.Compression, opts.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if stdout {
+		if opts.RotateSize > 0 || opts.RotateInterval > 0 {
+			return nil, errors.New("rotation requires an output file")
+		}
+		return compress(nopCloser{os.Stdout}, compression)
+	}
+
+	if opts.Create != nil {
+		prefix := strings.HasSuffix(opts.Path, "/")
+		rotated := opts.RotateSize > 0 || opts.RotateInterval > 0
+		switch {
+		case !prefix && rotated:
+			return nil, errors.New("rotating objects requires a path ending in /, the prefix of their names")
+		case prefix && !rotated:
+			opts.RotateSize = DefaultObjectSize
+		}
+		if prefix && (opts.Compression == "" || opts.Compression == CompressAuto) {
+			compression = CompressGzip
+		}
+	}
+
+	w := &fileWriter{opts: opts, compression: compression, now: time.Now}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func resolveCompression(compression, path string) (string, error) {
+	switch compression {
+	case "", CompressAuto:
+		switch {
+		case strings.HasSuffix(path, ".gz"):
+			return CompressGzip, nil
+		case strings.HasSuffix(path, ".zst"):
+			return CompressZstd, nil
+		default:
+			return CompressNone, nil
+		}
+	case CompressNone, CompressGzip, CompressZstd:
+		return compression, nil
+	default:
+		return "", fmt.Errorf("invalid compression %q, valid values: auto, none, gzip, zstd", compression)
+	}
+}
+
+// compress wraps w with the given compression, closing the result closes w too
+func compress(w io.WriteCloser, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressGzip:
+		return &stackedWriter{gzip.NewWriter(w), w}, nil
+	case CompressZstd:
+		enc, err := zstd.NewWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		return &stackedWriter{enc, w}, nil
+	default:
+		return w, nil
+	}
+}
+
+// fileWriter writes to a file, rotating it by size or age. The active file
+// always lives at the configured path, rotated files get the time they were
+// opened inserted before the extension, e.g. logs-20250102T150405Z.ndjson.gz.
+// With Options.Create, it writes objects instead, see objectName.
+type fileWriter struct {
+	opts        Options
+	compression string
+	now         func() time.Time
+
+	current io.WriteCloser
+	written int64
+	opened  time.Time
+	// objects counts the objects opened.
+	objects int
+}
+
+func (w *fileWriter) Write(p []byte) (int, error) {
+	if w.shouldRotate(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.current.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *fileWriter) Close() error {
+	return w.current.Close()
+}
+
+func (w *fileWriter) shouldRotate(next int) bool {
+	if w.written == 0 {
+		return false
+	}
+	if w.opts.RotateSize > 0 && w.written+int64(next) > w.opts.RotateSize {
+		return true
+	}
+	return w.opts.RotateInterval > 0 && w.now().Sub(w.opened) >= w.opts.RotateInterval
+}
+
+func (w *fileWriter) open() error {
+	w.opened = w.now()
+	var f io.WriteCloser
+	var err error
+	if w.opts.Create != nil {
+		f, err = w.opts.Create(w.objectName())
+	} else {
+		flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if w.opts.Append {
+			flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+		}
+		f, err = os.OpenFile(w.opts.Path, flag, 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	current, err := compress(f, w.compression)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.current = current
+	w.written = 0
+	return nil
+}
+
+// objectName returns the name of the next object: the path itself or, for a prefix, the prefix
+// followed by the time the object is opened, a sequence number and the extensions,
+// e.g. gs://bucket/logs/20250102T150405Z-0001.ndjson.gz
+func (w *fileWriter) objectName() string {
+	if !strings.HasSuffix(w.opts.Path, "/") {
+		return w.opts.Path
+	}
+	w.objects++
+	ext := w.opts.Extension
+	switch w.compression {
+	case CompressGzip:
+		ext += ".gz"
+	case CompressZstd:
+		ext += ".zst"
+	}
+	return fmt.Sprintf("%s%s-%04d%s", w.opts.Path, w.opened.UTC().Format("20060102T150405Z"), w.objects, ext)
+}
+
+func (w *fileWriter) rotate() error {
+	if err := w.current.Close(); err != nil {
+		return err
+	}
+	if w.opts.Create == nil {
+		if err := os.Rename(w.opts.Path, rotatedPath(w.opts.Path, w.opened)); err != nil {
+			return err
+		}
+	}
+	return w.open()
+}
+
+// rotatedPath returns a free path for a rotated file, based on the time it was opened
+func rotatedPath(path string, opened time.Time) string {
+	dir, base := filepath.Split(path)
+	stem, ext := base, ""
+	if i := strings.Index(base[1:], "."); i >= 0 {
+		stem, ext = base[:i+1], base[i+1:]
+	}
+	stamp := opened.UTC().Format("20060102T150405Z")
+
+	candidate := filepath.Join(dir, fmt.Sprintf("%s-%s%s", stem, stamp, ext))
+	for i := 1; ; i++ {
+		if _, err := os.Stat(candidate); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+		candidate = filepath.Join(dir, fmt.Sprintf("%s-%s-%d%s", stem, stamp, i, ext))
+	}
+}
+
+// stackedWriter closes an encoder and then the writer underneath it
+type stackedWriter struct {
+	io.WriteCloser
+	under io.Closer
+}
+
+func (s *stackedWriter) Close() error {
+	return errors.Join(s.WriteCloser.Close(), s.under.Close())
+}
+
+// nopCloser keeps stdout open when the output is closed
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
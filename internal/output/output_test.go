@@ -0,0 +1,222 @@
+package output
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatedPath(t *testing.T) {
+	opened := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	dir := t.TempDir()
+
+	cases := []struct {
+		input    string
+		expected string
+	}{
+		{"logs.ndjson.gz", "logs-20250102T150405Z.ndjson.gz"},
+		{"logs", "logs-20250102T150405Z"},
+		{".hidden.gz", ".hidden-20250102T150405Z.gz"},
+	}
+
+	for _, c := range cases {
+		got := rotatedPath(filepath.Join(dir, c.input), opened)
+		if got != filepath.Join(dir, c.expected) {
+			t.Errorf("rotatedPath(%q) = %q, want %q", c.input, got, c.expected)
+		}
+	}
+
+	taken := filepath.Join(dir, "logs-20250102T150405Z.gz")
+	if err := os.WriteFile(taken, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := rotatedPath(filepath.Join(dir, "logs.gz"), opened); got != filepath.Join(dir, "logs-20250102T150405Z-1.gz") {
+		t.Errorf("rotatedPath() with a taken name = %q", got)
+	}
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "out.ndjson.gz")
+
+	w, err := Open(Options{Path: path, RotateSize: 10})
+	if err != nil {
+		t.Fatalf("Open() unexpected error: %v", err)
+	}
+
+	clock := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	fw := w.(*fileWriter)
+	fw.now = func() time.Time { clock = clock.Add(time.Second); return clock }
+
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n"} {
+		if _, err := io.WriteString(w, line); err != nil {
+			t.Fatalf("Write() unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() unexpected error: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+	if len(files) != 3 {
+		t.Fatalf("got files %v, want 3", files)
+	}
+
+	var contents []string
+	for _, f := range files {
+		contents = append(contents, readGzip(t, f))
+	}
+	got := strings.Join(contents, "")
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n"} {
+		if strings.Count(got, line) != 1 {
+			t.Errorf("line %q found %d times across rotated files", line, strings.Count(got, line))
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.ndjson")
+	write := func(opts Options, line string) {
+		w, err := Open(opts)
+		if err != nil {
+			t.Fatalf("Open() unexpected error: %v", err)
+		}
+		if _, err := io.WriteString(w, line); err != nil {
+			t.Fatalf("Write() unexpected error: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close() unexpected error: %v", err)
+		}
+	}
+
+	write(Options{Path: path}, "a\n")
+	write(Options{Path: path}, "b\n")
+	write(Options{Path: path, Append: true}, "c\n")
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "b\nc\n" {
+		t.Errorf("file content = %q, want the second write followed by the appended one", got)
+	}
+}
+
+func TestResolveCompression(t *testing.T) {
+	cases := []struct {
+		compression, path, expected string
+		wantErr                     bool
+	}{
+		{"", "out.ndjson", CompressNone, false},
+		{"auto", "out.ndjson.gz", CompressGzip, false},
+		{"", "out.zst", CompressZstd, false},
+		{"gzip", "-", CompressGzip, false},
+		{"none", "out.gz", CompressNone, false},
+		{"brotli", "out", "", true},
+	}
+
+	for _, c := range cases {
+		got, err := resolveCompression(c.compression, c.path)
+		if c.wantErr {
+			if err == nil {
+				t.Errorf("resolveCompression(%q, %q) expected error, got nil", c.compression, c.path)
+			}
+			continue
+		}
+		if err != nil || got != c.expected {
+			t.Errorf("resolveCompression(%q, %q) = %q, %v, want %q", c.compression, c.path, got, err, c.expected)
+		}
+	}
+}
+
+// memoryObject is an object of a fake object store
+type memoryObject struct {
+	strings.Builder
+	closed bool
+}
+
+func (o *memoryObject) Close() error {
+	o.closed = true
+	return nil
+}
+
+func TestObjects(t *testing.T) {
+	objects := map[string]*memoryObject{}
+	create := func(name string) (io.WriteCloser, error) {
+		objects[name] = &memoryObject{}
+		return objects[name], nil
+	}
+
+	w, err := Open(Options{Path: "gs://bucket/logs/", Compression: CompressNone, RotateSize: 10, Create: create, Extension: ".ndjson"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	w.(*fileWriter).now = func() time.Time { return clock }
+	for _, line := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n"} {
+		if _, err := io.WriteString(w, line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(objects) != 3 {
+		t.Errorf("wrote %d objects, want 3", len(objects))
+	}
+	// The first object was opened before the clock was set.
+	expected := map[string]string{
+		"-0001.ndjson": "aaaaaa\n",
+		"gs://bucket/logs/20250102T150405Z-0002.ndjson": "bbbbbb\n",
+		"gs://bucket/logs/20250102T150405Z-0003.ndjson": "cccccc\n",
+	}
+	for name, object := range objects {
+		found := false
+		for suffix, content := range expected {
+			if strings.HasSuffix(name, suffix) {
+				found = object.String() == content && object.closed
+			}
+		}
+		if !found {
+			t.Errorf("unexpected object %s holding %q (closed: %t)", name, object.String(), object.closed)
+		}
+	}
+
+	if _, err := Open(Options{Path: "gs://bucket/logs.ndjson", RotateSize: 10, Create: create}); err == nil {
+		t.Error("expected an error rotating a single object")
+	}
+	w, err = Open(Options{Path: "gs://bucket/logs/", Create: create})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fw := w.(*fileWriter); fw.compression != CompressGzip || fw.opts.RotateSize != DefaultObjectSize {
+		t.Errorf("prefix written with compression %s and objects of %d bytes, want gzip and the default size", fw.compression, fw.opts.RotateSize)
+	}
+}
+
+func readGzip(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
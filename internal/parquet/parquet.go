@@ -0,0 +1,470 @@
+// Package parquet writes flat Parquet files of optional string, JSON and timestamp columns, as
+// described in https://parquet.apache.org/docs/file-format/: one data page per column chunk, with
+// PLAIN encoded values, and the metadata serialized with the Thrift compact protocol.
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var magic = []byte("PAR1")
+
+// Type is the type of the values of a column.
+type Type int
+
+const (
+	// String columns hold UTF-8 strings.
+	String Type = iota
+	// JSON columns hold JSON documents, as UTF-8 strings.
+	JSON
+	// Timestamp columns hold UTC times, with microsecond precision.
+	Timestamp
+)
+
+// Column describes a column of the file. All the columns are optional: their values can be null.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Supported compression codecs.
+const (
+	CompressNone = "none"
+	CompressZstd = "zstd"
+)
+
+// Options configures a Writer.
+type Options struct {
+	// Compression is one of the Compress* constants, CompressNone when empty.
+	Compression string
+	// RowGroupSize is the number of rows of each row group, 10000 when zero. The rows of a row
+	// group are kept in memory until it is written.
+	RowGroupSize int
+	// CreatedBy identifies the application writing the file.
+	CreatedBy string
+}
+
+// Physical types, converted types, encodings and codecs of the format.
+const (
+	typeInt64     = 2
+	typeByteArray = 6
+
+	repetitionOptional = 1
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+	convertedJSON            = 19
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	codecUncompressed = 0
+	codecZstd         = 6
+
+	pageTypeData = 0
+)
+
+// Writer writes rows to a Parquet file. The file is only valid after Close.
+type Writer struct {
+	w        io.Writer
+	columns  []Column
+	codec    int32
+	encoder  *zstd.Encoder
+	groupMax int
+	created  string
+	offset   int64
+	rows     [][]any
+	groups   []rowGroup
+	numRows  int64
+	err      error
+}
+
+type rowGroup struct {
+	chunks    []columnChunk
+	numRows   int64
+	totalSize int64
+}
+
+type columnChunk struct {
+	offset           int64
+	numValues        int64
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+// NewWriter returns a Writer of rows of the columns to w.
+func NewWriter(w io.Writer, columns []Column, opts Options) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("no columns")
+	}
+	pw := &Writer{w: w, columns: columns, groupMax: opts.RowGroupSize, created: opts.CreatedBy}
+	if pw.groupMax <= 0 {
+		pw.groupMax = 10000
+	}
+	switch opts.Compression {
+	case "", CompressNone:
+		pw.codec = codecUncompressed
+	case CompressZstd:
+		encoder, err := zstd.NewWriter(nil)
+		if err != nil {
+			return nil, err
+		}
+		pw.codec, pw.encoder = codecZstd, encoder
+	default:
+		return nil, fmt.Errorf("unknown compression %q, valid values: %s, %s", opts.Compression, CompressNone, CompressZstd)
+	}
+	return pw, nil
+}
+
+// Write adds a row, with a value per column: nil for null, a string for the String and JSON
+// columns and a time.Time, the zero time being null, for the Timestamp ones.
+func (w *Writer) Write(row []any) error {
+	if w.err != nil {
+		return w.err
+	}
+	if len(row) != len(w.columns) {
+		return fmt.Errorf("row of %d values, %d columns", len(row), len(w.columns))
+	}
+	for i, v := range row {
+		if err := w.columns[i].check(v); err != nil {
+			return err
+		}
+	}
+	if w.offset == 0 {
+		w.write(magic)
+	}
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= w.groupMax {
+		w.flushRowGroup()
+	}
+	return w.err
+}
+
+func (c Column) check(v any) error {
+	switch v := v.(type) {
+	case nil:
+		return nil
+	case string:
+		if c.Type != Timestamp {
+			return nil
+		}
+	case time.Time:
+		if c.Type == Timestamp {
+			return nil
+		}
+	default:
+		return fmt.Errorf("column %s: unsupported value of type %T", c.Name, v)
+	}
+	return fmt.Errorf("column %s: unexpected value of type %T", c.Name, v)
+}
+
+// Close writes the buffered rows and the footer of the file, without closing the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.offset == 0 {
+		w.write(magic)
+	}
+	w.flushRowGroup()
+	footer := w.fileMetadata()
+	w.write(footer)
+	w.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	w.write(magic)
+	if w.encoder != nil {
+		w.encoder.Close()
+	}
+	if w.err == nil {
+		w.err = errors.New("parquet writer closed")
+		return nil
+	}
+	return w.err
+}
+
+func (w *Writer) write(data []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(data)
+	w.offset += int64(n)
+	w.err = err
+}
+
+// flushRowGroup writes the buffered rows as a row group, a column chunk of a single page per column
+func (w *Writer) flushRowGroup() {
+	if len(w.rows) == 0 || w.err != nil {
+		return
+	}
+	group := rowGroup{numRows: int64(len(w.rows))}
+	for i := range w.columns {
+		page := w.encodePage(i)
+		compressed := page
+		if w.encoder != nil {
+			compressed = w.encoder.EncodeAll(page, nil)
+		}
+		header := pageHeader(len(w.rows), len(page), len(compressed))
+		chunk := columnChunk{
+			offset:           w.offset,
+			numValues:        int64(len(w.rows)),
+			uncompressedSize: int64(len(header) + len(page)),
+			compressedSize:   int64(len(header) + len(compressed)),
+		}
+		w.write(header)
+		w.write(compressed)
+		group.chunks = append(group.chunks, chunk)
+		group.totalSize += chunk.uncompressedSize
+	}
+	w.groups = append(w.groups, group)
+	w.numRows += group.numRows
+	w.rows = w.rows[:0]
+}
+
+// encodePage returns the definition levels and the PLAIN encoded non-null values of column i
+func (w *Writer) encodePage(i int) []byte {
+	levels := make([]bool, len(w.rows))
+	var values []byte
+	for r, row := range w.rows {
+		switch v := row[i].(type) {
+		case string:
+			levels[r] = true
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(v)))
+			values = append(values, v...)
+		case time.Time:
+			if v.IsZero() {
+				continue
+			}
+			levels[r] = true
+			values = binary.LittleEndian.AppendUint64(values, uint64(v.UnixMicro()))
+		}
+	}
+	encoded := encodeLevels(levels)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(encoded)))
+	page = append(page, encoded...)
+	return append(page, values...)
+}
+
+// encodeLevels encodes definition levels of bit width 1 as a single bit-packed run of the
+// RLE/bit-packing hybrid encoding
+func encodeLevels(levels []bool) []byte {
+	groups := (len(levels) + 7) / 8
+	out := binary.AppendUvarint(nil, uint64(groups)<<1|1)
+	packed := make([]byte, groups)
+	for i, defined := range levels {
+		if defined {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return append(out, packed...)
+}
+
+func pageHeader(numValues, uncompressed, compressed int) []byte {
+	var t thriftWriter
+	t.i32(1, pageTypeData)
+	t.i32(2, int32(uncompressed))
+	t.i32(3, int32(compressed))
+	t.structBegin(5)
+	t.i32(1, int32(numValues))
+	t.i32(2, encodingPlain)
+	t.i32(3, encodingRLE)
+	t.i32(4, encodingRLE)
+	t.structEnd()
+	t.stop()
+	return t.buf.Bytes()
+}
+
+func (w *Writer) fileMetadata() []byte {
+	var t thriftWriter
+	t.i32(1, 1)
+	t.listBegin(2, thriftStruct, len(w.columns)+1)
+	t.elemBegin()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(w.columns)))
+	t.elemEnd()
+	for _, column := range w.columns {
+		t.elemBegin()
+		t.i32(1, column.physicalType())
+		t.i32(3, repetitionOptional)
+		t.binary(4, column.Name)
+		t.i32(6, column.convertedType())
+		t.structBegin(10)
+		switch column.Type {
+		case String:
+			t.emptyStruct(1)
+		case JSON:
+			t.emptyStruct(12)
+		case Timestamp:
+			t.structBegin(8)
+			t.bool(1, true)
+			t.structBegin(2)
+			t.emptyStruct(2)
+			t.structEnd()
+			t.structEnd()
+		}
+		t.structEnd()
+		t.elemEnd()
+	}
+	t.i64(3, w.numRows)
+	t.listBegin(4, thriftStruct, len(w.groups))
+	for _, group := range w.groups {
+		t.elemBegin()
+		t.listBegin(1, thriftStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			t.elemBegin()
+			t.i64(2, chunk.offset)
+			t.structBegin(3)
+			t.i32(1, w.columns[i].physicalType())
+			t.listBegin(2, thriftI32, 2)
+			t.zigzag(encodingPlain)
+			t.zigzag(encodingRLE)
+			t.binaryList(3, w.columns[i].Name)
+			t.i32(4, w.codec)
+			t.i64(5, chunk.numValues)
+			t.i64(6, chunk.uncompressedSize)
+			t.i64(7, chunk.compressedSize)
+			t.i64(9, chunk.offset)
+			t.structEnd()
+			t.elemEnd()
+		}
+		t.i64(2, group.totalSize)
+		t.i64(3, group.numRows)
+		t.elemEnd()
+	}
+	if w.created != "" {
+		t.binary(6, w.created)
+	}
+	t.stop()
+	return t.buf.Bytes()
+}
+
+func (c Column) physicalType() int32 {
+	if c.Type == Timestamp {
+		return typeInt64
+	}
+	return typeByteArray
+}
+
+func (c Column) convertedType() int32 {
+	switch c.Type {
+	case JSON:
+		return convertedJSON
+	case Timestamp:
+		return convertedTimestampMicros
+	default:
+		return convertedUTF8
+	}
+}
+
+// Types of the Thrift compact protocol.
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter serializes structs with the Thrift compact protocol, field IDs being delta encoded
+// against the last field of the enclosing struct
+type thriftWriter struct {
+	buf  bytes.Buffer
+	last []int16
+	id   int16
+}
+
+func (t *thriftWriter) field(id int16, typ byte) {
+	if delta := id - t.id; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.zigzag(int64(id))
+	}
+	t.id = id
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	t.buf.Write(binary.AppendUvarint(nil, v))
+}
+
+func (t *thriftWriter) zigzag(v int64) {
+	t.varint(uint64(v<<1 ^ v>>63))
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.zigzag(int64(v))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.zigzag(v)
+}
+
+func (t *thriftWriter) bool(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thriftWriter) binary(id int16, v string) {
+	t.field(id, thriftBinary)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+// binaryList writes a list of a single string
+func (t *thriftWriter) binaryList(id int16, v string) {
+	t.listBegin(id, thriftBinary, 1)
+	t.varint(uint64(len(v)))
+	t.buf.WriteString(v)
+}
+
+func (t *thriftWriter) listBegin(id int16, elem byte, size int) {
+	t.field(id, thriftList)
+	if size < 15 {
+		t.buf.WriteByte(byte(size)<<4 | elem)
+	} else {
+		t.buf.WriteByte(0xf0 | elem)
+		t.varint(uint64(size))
+	}
+}
+
+func (t *thriftWriter) structBegin(id int16) {
+	t.field(id, thriftStruct)
+	t.elemBegin()
+}
+
+func (t *thriftWriter) structEnd() {
+	t.elemEnd()
+}
+
+func (t *thriftWriter) emptyStruct(id int16) {
+	t.structBegin(id)
+	t.structEnd()
+}
+
+// elemBegin starts a struct element of a list, with no field header
+func (t *thriftWriter) elemBegin() {
+	t.last = append(t.last, t.id)
+	t.id = 0
+}
+
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.id = t.last[len(t.last)-1]
+	t.last = t.last[:len(t.last)-1]
+}
+
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
@@ -0,0 +1,249 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// thriftReader decodes structs of the Thrift compact protocol as maps from field IDs to values:
+// int64, bool, string, []any and map[int16]any
+type thriftReader struct {
+	t    *testing.T
+	data []byte
+}
+
+func (r *thriftReader) byte() byte {
+	if len(r.data) == 0 {
+		r.t.Fatal("unexpected end of thrift data")
+	}
+	b := r.data[0]
+	r.data = r.data[1:]
+	return b
+}
+
+func (r *thriftReader) uvarint() uint64 {
+	v, n := binary.Uvarint(r.data)
+	if n <= 0 {
+		r.t.Fatal("invalid varint")
+	}
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag() int64 {
+	v := r.uvarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct() map[int16]any {
+	fields := map[int16]any{}
+	var id int16
+	for {
+		header := r.byte()
+		if header == 0 {
+			return fields
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			id = int16(r.zigzag())
+		}
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(typ byte) any {
+	switch typ {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n := r.uvarint()
+		v := string(r.data[:n])
+		r.data = r.data[n:]
+		return v
+	case thriftList:
+		header := r.byte()
+		size := uint64(header >> 4)
+		if size == 15 {
+			size = r.uvarint()
+		}
+		list := []any{}
+		for range size {
+			// Booleans in lists are single bytes.
+			if elem := header & 0x0f; elem == thriftTrue || elem == thriftFalse {
+				list = append(list, r.byte() == thriftTrue)
+			} else {
+				list = append(list, r.value(elem))
+			}
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct()
+	}
+	r.t.Fatalf("unsupported thrift type %d", typ)
+	return nil
+}
+
+// readFile decodes a file written by Writer, returning its metadata and its rows
+func readFile(t *testing.T, data []byte) (map[int16]any, [][]any) {
+	t.Helper()
+	if !bytes.HasPrefix(data, magic) || !bytes.HasSuffix(data, magic) {
+		t.Fatalf("missing magic in %q", data)
+	}
+	size := binary.LittleEndian.Uint32(data[len(data)-8:])
+	footer := &thriftReader{t: t, data: data[len(data)-8-int(size) : len(data)-8]}
+	metadata := footer.readStruct()
+	if len(footer.data) != 0 {
+		t.Fatalf("%d trailing bytes in the footer", len(footer.data))
+	}
+
+	schema := metadata[2].([]any)[1:]
+	var rows [][]any
+	for _, group := range metadata[4].([]any) {
+		numRows := int(group.(map[int16]any)[3].(int64))
+		groupRows := make([][]any, numRows)
+		for i := range groupRows {
+			groupRows[i] = make([]any, len(schema))
+		}
+		for c, chunk := range group.(map[int16]any)[1].([]any) {
+			meta := chunk.(map[int16]any)[3].(map[int16]any)
+			page := &thriftReader{t: t, data: data[meta[9].(int64):]}
+			header := page.readStruct()
+			content := page.data[:header[3].(int64)]
+			if meta[4].(int64) == codecZstd {
+				decoder, _ := zstd.NewReader(nil)
+				var err error
+				if content, err = decoder.DecodeAll(content, nil); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if int64(len(content)) != header[2].(int64) {
+				t.Fatalf("page of %d bytes, header says %d", len(content), header[2])
+			}
+			levelsSize := binary.LittleEndian.Uint32(content)
+			levels := &thriftReader{t: t, data: content[4 : 4+levelsSize]}
+			if run := levels.uvarint(); run&1 != 1 || int(run>>1) != (numRows+7)/8 {
+				t.Fatalf("unexpected levels run header %d", run)
+			}
+			values := content[4+levelsSize:]
+			for r := range numRows {
+				if levels.data[r/8]&(1<<(r%8)) == 0 {
+					continue
+				}
+				if meta[1].(int64) == typeInt64 {
+					groupRows[r][c] = time.UnixMicro(int64(binary.LittleEndian.Uint64(values))).UTC()
+					values = values[8:]
+				} else {
+					n := binary.LittleEndian.Uint32(values)
+					groupRows[r][c] = string(values[4 : 4+n])
+					values = values[4+n:]
+				}
+			}
+			if len(values) != 0 {
+				t.Fatalf("%d trailing bytes in the page of column %d", len(values), c)
+			}
+		}
+		rows = append(rows, groupRows...)
+	}
+	return metadata, rows
+}
+
+func TestWriter(t *testing.T) {
+	columns := []Column{{Name: "timestamp", Type: Timestamp}, {Name: "severity", Type: String}, {Name: "payload", Type: JSON}}
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 123456000, time.UTC)
+	rows := [][]any{
+		{ts, "ERROR", `{"message":"boom"}`},
+		{time.Time{}, nil, ""},
+		{ts.Add(time.Second), "INFO", nil},
+	}
+
+	for _, compression := range []string{CompressNone, CompressZstd} {
+		t.Run(compression, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, columns, Options{Compression: compression, RowGroupSize: 2, CreatedBy: "grapple test"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, row := range rows {
+				if err := w.Write(row); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			metadata, got := readFile(t, buf.Bytes())
+			want := [][]any{
+				{ts, "ERROR", `{"message":"boom"}`},
+				{nil, nil, ""},
+				{ts.Add(time.Second), "INFO", nil},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("rows = %v, want %v", got, want)
+			}
+			if metadata[3] != int64(3) || len(metadata[4].([]any)) != 2 || metadata[6] != "grapple test" {
+				t.Errorf("unexpected metadata %v", metadata)
+			}
+			schema := metadata[2].([]any)
+			if root := schema[0].(map[int16]any); root[4] != "schema" || root[5] != int64(3) {
+				t.Errorf("unexpected schema root %v", root)
+			}
+			for i, want := range []struct {
+				name      string
+				typ       int64
+				converted int64
+				logical   int16
+			}{{"timestamp", typeInt64, convertedTimestampMicros, 8}, {"severity", typeByteArray, convertedUTF8, 1}, {"payload", typeByteArray, convertedJSON, 12}} {
+				element := schema[i+1].(map[int16]any)
+				logical := element[10].(map[int16]any)
+				if _, ok := logical[want.logical]; element[4] != want.name || element[1] != want.typ || element[3] != int64(repetitionOptional) || element[6] != want.converted || !ok {
+					t.Errorf("unexpected schema element %v", element)
+				}
+			}
+		})
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, []Column{{Name: "message", Type: String}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	metadata, rows := readFile(t, buf.Bytes())
+	if metadata[3] != int64(0) || len(rows) != 0 {
+		t.Errorf("metadata = %v, rows = %v", metadata, rows)
+	}
+}
+
+func TestWriterInvalid(t *testing.T) {
+	if _, err := NewWriter(nil, []Column{{Name: "message"}}, Options{Compression: "snappy"}); err == nil {
+		t.Error("expected an error for an unknown compression")
+	}
+	w, err := NewWriter(&bytes.Buffer{}, []Column{{Name: "timestamp", Type: Timestamp}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write([]any{"2024-05-01"}); err == nil {
+		t.Error("expected an error for a string in a timestamp column")
+	}
+	if err := w.Write([]any{1}); err == nil {
+		t.Error("expected an error for an int")
+	}
+	if err := w.Write(nil); err == nil {
+		t.Error("expected an error for a row of the wrong length")
+	}
+}
@@ -0,0 +1,118 @@
+// Package pii detects sensitive data, like credit card numbers or credentials, in strings.
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Detector finds one kind of sensitive data.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Valid, if set, filters out the matches of Pattern that are not actual findings.
+	Valid func(match string) bool
+}
+
+// Find returns the matches of the detector in s.
+func (d Detector) Find(s string) []string {
+	var found []string
+	for _, match := range d.Pattern.FindAllString(s, -1) {
+		if d.Valid == nil || d.Valid(match) {
+			found = append(found, match)
+		}
+	}
+	return found
+}
+
+// Builtin returns the detectors available by default, sorted by name.
+func Builtin() []Detector {
+	return []Detector{
+		{
+			Name:    "credit-card",
+			Pattern: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+			Valid:   luhn,
+		},
+		{
+			Name:    "email",
+			Pattern: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`),
+		},
+		{
+			Name:    "gcp-api-key",
+			Pattern: regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`),
+		},
+		{
+			Name:    "jwt",
+			Pattern: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+		},
+		{
+			Name:    "private-key",
+			Pattern: regexp.MustCompile(`-----BEGIN (?:RSA |EC |OPENSSH )?PRIVATE KEY-----`),
+		},
+	}
+}
+
+// Select returns the builtin detectors with the given names, all of them when names is empty.
+func Select(names []string) ([]Detector, error) {
+	builtin := Builtin()
+	if len(names) == 0 {
+		return builtin, nil
+	}
+	var detectors []Detector
+	for _, name := range names {
+		i := slices.IndexFunc(builtin, func(d Detector) bool { return d.Name == name })
+		if i < 0 {
+			valid := make([]string, len(builtin))
+			for j, d := range builtin {
+				valid[j] = d.Name
+			}
+			return nil, fmt.Errorf("unknown detector %q, valid values: %s", name, strings.Join(valid, ", "))
+		}
+		detectors = append(detectors, builtin[i])
+	}
+	return detectors, nil
+}
+
+// Custom parses a detector given as NAME=REGEX.
+func Custom(definition string) (Detector, error) {
+	name, expression, ok := strings.Cut(definition, "=")
+	if !ok || name == "" {
+		return Detector{}, fmt.Errorf("invalid detector %q, expected NAME=REGEX", definition)
+	}
+	pattern, err := regexp.Compile(expression)
+	if err != nil {
+		return Detector{}, fmt.Errorf("invalid detector %q: %w", name, err)
+	}
+	return Detector{Name: name, Pattern: pattern}, nil
+}
+
+// Mask hides most of a finding, so that reports don't leak what they flag.
+func Mask(s string) string {
+	runes := []rune(s)
+	if len(runes) <= 8 {
+		return strings.Repeat("*", len(runes))
+	}
+	return string(runes[:4]) + strings.Repeat("*", len(runes)-8) + string(runes[len(runes)-4:])
+}
+
+// luhn reports whether the digits of s pass the Luhn checksum of card numbers.
+func luhn(s string) bool {
+	sum, digits := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if digits%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		digits++
+	}
+	return digits >= 13 && sum%10 == 0
+}
@@ -0,0 +1,154 @@
+// Package useragent extracts the browser, operating system and device from User-Agent headers,
+// and tells bots and command line tools apart from browsers.
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Devices.
+const (
+	Desktop = "desktop"
+	Mobile  = "mobile"
+	Tablet  = "tablet"
+)
+
+// Agent is what a User-Agent header says about the client.
+type Agent struct {
+	// Browser is the browser, bot or tool name, e.g. Chrome, Googlebot or curl.
+	Browser string
+	// Version is the major version of Browser.
+	Version string
+	OS      string
+	// Device is Desktop, Mobile or Tablet for browsers, empty otherwise.
+	Device string
+	Bot    bool
+}
+
+// product matches a name/version pair of the header
+type product struct {
+	name  string
+	token *regexp.Regexp
+}
+
+func newProduct(name, token string) product {
+	return product{name, regexp.MustCompile(token + `/(\d+)`)}
+}
+
+var (
+	knownBots = []product{
+		newProduct("Googlebot", `Googlebot`),
+		newProduct("bingbot", `bingbot`),
+		newProduct("YandexBot", `YandexBot`),
+		newProduct("DuckDuckBot", `DuckDuckBot`),
+		newProduct("Baiduspider", `Baiduspider`),
+		newProduct("AhrefsBot", `AhrefsBot`),
+		newProduct("SemrushBot", `SemrushBot`),
+		newProduct("facebookexternalhit", `facebookexternalhit`),
+		newProduct("GoogleHC", `GoogleHC`),
+		newProduct("kube-probe", `kube-probe`),
+	}
+	tools = []product{
+		newProduct("curl", `curl`),
+		newProduct("Wget", `Wget`),
+		newProduct("python-requests", `python-requests`),
+		newProduct("Go-http-client", `Go-http-client`),
+		newProduct("okhttp", `okhttp`),
+		newProduct("axios", `axios`),
+		newProduct("PostmanRuntime", `PostmanRuntime`),
+		newProduct("Java", `Java`),
+	}
+	// browsers are checked in order, as most of them also claim to be the ones following.
+	browsers = []product{
+		newProduct("Edge", `Edg(?:e|A|iOS)?`),
+		newProduct("Opera", `OPR`),
+		newProduct("Samsung Internet", `SamsungBrowser`),
+		newProduct("Chrome", `(?:Chrome|CriOS)`),
+		newProduct("Firefox", `(?:Firefox|FxiOS)`),
+		newProduct("Safari", `Version`),
+		newProduct("Internet Explorer", `(?:MSIE |Trident/.*rv:)`),
+	}
+
+	genericBot  = regexp.MustCompile(`(?i)([\w.-]*(?:bot|crawler|spider|slurp)[\w.-]*)(?:/(\d+))?`)
+	safariToken = regexp.MustCompile(`Safari/`)
+	msieVersion = regexp.MustCompile(`(?:MSIE |rv:)(\d+)`)
+)
+
+// Parse parses a User-Agent header. Unknown clients have an empty Browser.
+func Parse(header string) Agent {
+	var agent Agent
+	for _, p := range knownBots {
+		if strings.Contains(header, p.name) {
+			agent.Browser, agent.Version, agent.Bot = p.name, version(p.token, header), true
+			return agent
+		}
+	}
+	if match := genericBot.FindStringSubmatch(header); match != nil {
+		agent.Browser, agent.Version, agent.Bot = match[1], match[2], true
+		return agent
+	}
+	for _, p := range tools {
+		if strings.HasPrefix(header, p.name+"/") {
+			agent.Browser, agent.Version = p.name, version(p.token, header)
+			return agent
+		}
+	}
+
+	agent.OS = operatingSystem(header)
+	for _, p := range browsers {
+		switch {
+		case p.name == "Safari" && !safariToken.MatchString(header):
+			continue
+		case p.name == "Internet Explorer":
+			if match := msieVersion.FindStringSubmatch(header); match != nil && strings.Contains(header, "Windows") {
+				agent.Browser, agent.Version = p.name, match[1]
+			}
+		case p.token.MatchString(header):
+			agent.Browser, agent.Version = p.name, version(p.token, header)
+		}
+		if agent.Browser != "" {
+			break
+		}
+	}
+	if agent.Browser != "" {
+		agent.Device = device(header)
+	}
+	return agent
+}
+
+func version(token *regexp.Regexp, header string) string {
+	if match := token.FindStringSubmatch(header); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func operatingSystem(header string) string {
+	switch {
+	case strings.Contains(header, "Android"):
+		return "Android"
+	case strings.Contains(header, "iPhone"), strings.Contains(header, "iPad"), strings.Contains(header, "iPod"):
+		return "iOS"
+	case strings.Contains(header, "Windows"):
+		return "Windows"
+	case strings.Contains(header, "Mac OS X"), strings.Contains(header, "Macintosh"):
+		return "macOS"
+	case strings.Contains(header, "CrOS"):
+		return "ChromeOS"
+	case strings.Contains(header, "Linux"):
+		return "Linux"
+	}
+	return ""
+}
+
+func device(header string) string {
+	switch {
+	case strings.Contains(header, "iPad"), strings.Contains(header, "Tablet"),
+		strings.Contains(header, "Android") && !strings.Contains(header, "Mobile"):
+		return Tablet
+	case strings.Contains(header, "Mobile"), strings.Contains(header, "iPhone"):
+		return Mobile
+	}
+	return Desktop
+}
@@ -0,0 +1,53 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected Agent
+	}{
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.6367.91 Safari/537.36",
+			Agent{Browser: "Chrome", Version: "124", OS: "Windows", Device: Desktop},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.67",
+			Agent{Browser: "Edge", Version: "124", OS: "Windows", Device: Desktop},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1",
+			Agent{Browser: "Safari", Version: "17", OS: "iOS", Device: Mobile},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			Agent{Browser: "Chrome", Version: "124", OS: "Android", Device: Tablet},
+		},
+		{
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			Agent{Browser: "Firefox", Version: "125", OS: "Linux", Device: Desktop},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 6.1; Trident/7.0; rv:11.0) like Gecko",
+			Agent{Browser: "Internet Explorer", Version: "11", OS: "Windows", Device: Desktop},
+		},
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Agent{Browser: "Googlebot", Version: "2", Bot: true},
+		},
+		{"GoogleHC/1.0", Agent{Browser: "GoogleHC", Version: "1", Bot: true}},
+		{
+			"Mozilla/5.0 (compatible; MJ12bot/v1.4.8; http://mj12bot.com/)",
+			Agent{Browser: "MJ12bot", Bot: true},
+		},
+		{"curl/8.5.0", Agent{Browser: "curl", Version: "8"}},
+		{"python-requests/2.31.0", Agent{Browser: "python-requests", Version: "2"}},
+		{"", Agent{}},
+		{"something custom", Agent{}},
+	}
+	for _, c := range cases {
+		if got := Parse(c.header); got != c.expected {
+			t.Errorf("Parse(%q) = %+v, want %+v", c.header, got, c.expected)
+		}
+	}
+}
@@ -0,0 +1,413 @@
+// Package grapple reads log entries from Cloud Logging the way the grapple command does: it pages
+// through the results, pauses when rate limited, resumes after transient errors and restarts the
+// queries whose page tokens expire during long runs, handing each entry to a function.
+//
+// The simplest use reads with the Application Default Credentials:
+//
+//	err := grapple.Fetch(ctx, grapple.Query{Project: "my-project", Filter: "severity>=ERROR"}, func(entry *grapple.LogEntry) error {
+//		fmt.Println(entry.Timestamp.AsTime(), entry.GetTextPayload())
+//		return nil
+//	})
+//
+// A Fetcher reads through a client configured otherwise, e.g. with other credentials or with the REST
+// transport, and reports the progress of its fetches to a Trace. A WindowStrategy decides where each
+// poll of an incremental collection starts, trading latency for completeness with late entries.
+package grapple
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	logging "cloud.google.com/go/logging/apiv2"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	gax "github.com/googleapis/gax-go/v2"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// LogEntry is an entry of Cloud Logging.
+type LogEntry = loggingpb.LogEntry
+
+// MaxPageSize is the largest page size accepted by the API.
+const MaxPageSize = 1000
+
+// maxTransientErrors is how many transient errors in a row a fetch resumes from
+const maxTransientErrors = 10
+
+// ErrStop is returned by the function handed the entries to stop fetching after the current one,
+// the fetch then succeeds.
+var ErrStop = errors.New("stop fetching")
+
+// Query selects the entries to fetch.
+type Query struct {
+	// Project is the ID of the project to read the entries of, when ResourceNames is empty.
+	Project string
+	// ResourceNames are the parents to read the entries of: projects, folders, organizations,
+	// billing accounts or log views, e.g. projects/my-project/locations/global/buckets/_Default/views/_AllLogs.
+	ResourceNames []string
+	// Filter selects the entries in the Logging query language, all of them when empty.
+	Filter string
+	// NewestFirst orders the entries by descending timestamp instead of ascending.
+	NewestFirst bool
+	// PageSize is the number of entries requested per page, MaxPageSize when 0.
+	PageSize int
+}
+
+// request returns the first request of the query and its page size
+func (q Query) request() (*loggingpb.ListLogEntriesRequest, int, error) {
+	req := &loggingpb.ListLogEntriesRequest{ResourceNames: q.ResourceNames, Filter: q.Filter}
+	if len(req.ResourceNames) == 0 {
+		if q.Project == "" {
+			return nil, 0, errors.New("grapple: query without Project nor ResourceNames")
+		}
+		req.ResourceNames = []string{"projects/" + q.Project}
+	}
+	if q.NewestFirst {
+		req.OrderBy = "timestamp desc"
+	}
+	pageSize := q.PageSize
+	if pageSize == 0 {
+		pageSize = MaxPageSize
+	}
+	if pageSize < 0 || pageSize > MaxPageSize {
+		return nil, 0, fmt.Errorf("grapple: invalid page size %d, expected between 1 and %d", q.PageSize, MaxPageSize)
+	}
+	return req, pageSize, nil
+}
+
+// Lister sends the requests listing entries, like the Client of cloud.google.com/go/logging/apiv2.
+type Lister interface {
+	ListLogEntries(ctx context.Context, req *loggingpb.ListLogEntriesRequest, opts ...gax.CallOption) *logging.LogEntryIterator
+}
+
+// Backoff grows the pauses between retries exponentially, with jitter so that clients limited at
+// the same time don't retry in lockstep.
+type Backoff struct {
+	Initial, Max time.Duration
+	Multiplier   float64
+}
+
+// DefaultBackoff is the backoff of the Fetchers without one.
+var DefaultBackoff = Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2}
+
+// Delay returns the pause before retrying after attempt failures in a row, the first being 0.
+// The pause is between half and the whole of the exponential delay, and never shorter than
+// the delay requested by the server, if any.
+func (b Backoff) Delay(attempt int, requested time.Duration) time.Duration {
+	base := float64(b.Initial) * math.Pow(b.Multiplier, float64(attempt))
+	if base > float64(b.Max) || math.IsInf(base, 0) {
+		base = float64(b.Max)
+	}
+	d := time.Duration(base / 2)
+	d += rand.N(d + 1)
+	return max(d, requested)
+}
+
+// Trace is notified of the progress of the fetches of a Fetcher. Any of its functions can be nil.
+type Trace struct {
+	// PageRequest is called before each page request, with its page token, empty for the first page.
+	PageRequest func(pageToken string)
+	// PageError is called when a page request fails, before handling the error.
+	PageError func(err error)
+	// Page is called with the number of entries of each page received.
+	Page func(entries int)
+	// RateLimited is called when a page request is rate limited, before pausing for delay and
+	// retrying it. attempt is the number of rate limit errors in a row before this one.
+	RateLimited func(err *apierror.APIError, attempt int, delay time.Duration)
+	// RateLimitExpired is called when a page request succeeds after rate limit errors.
+	RateLimitExpired func()
+	// TransientError is called when a page request fails with an error likely to go away, like
+	// a network blip, before pausing for delay and resuming from that page.
+	TransientError func(s *status.Status, delay time.Duration)
+	// PageTokenExpired is called when the page token expired after count entries, before the query
+	// restarts from the timestamp of the last entry.
+	PageTokenExpired func(count int, from time.Time)
+	// Backlog is called after each entry with the number of entries of its page left to handle.
+	Backlog func(entries int)
+}
+
+// Fetcher fetches entries through a client, retrying and resuming after errors.
+type Fetcher struct {
+	// Client sends the requests.
+	Client Lister
+	// Backoff paces the retries after rate limit and transient errors, DefaultBackoff when zero.
+	Backoff Backoff
+	// Trace is notified of the progress of the fetches, if not nil.
+	Trace *Trace
+}
+
+// Fetch fetches the entries of the query with a new client of the Application Default Credentials,
+// see Fetcher.Fetch.
+func Fetch(ctx context.Context, q Query, fn func(*LogEntry) error) error {
+	client, err := logging.NewClient(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return (&Fetcher{Client: client}).Fetch(ctx, q, fn)
+}
+
+// Fetch hands the entries of the query to fn in order, until the last one or until fn returns an
+// error. It returns nil when fn returns ErrStop, and the error of the context when it is done.
+func (f *Fetcher) Fetch(ctx context.Context, q Query, fn func(*LogEntry) error) error {
+	req, pageSize, err := q.request()
+	if err != nil {
+		return err
+	}
+	backoff := f.Backoff
+	if backoff == (Backoff{}) {
+		backoff = DefaultBackoff
+	}
+	t := f.Trace
+
+	count := 0
+	rateLimits := 0
+	transientErrors := 0
+	currentToken := ""
+	resume := &fetchResume{}
+	for {
+		pager := iterator.NewPager(f.Client.ListLogEntries(ctx, req), pageSize, currentToken)
+		for {
+			var entries []*LogEntry
+			t.pageRequest(currentToken)
+			nextToken, err := pager.NextPage(&entries)
+			if err != nil {
+				if ctx.Err() != nil {
+					return ctx.Err()
+				}
+				if errors.Is(err, context.Canceled) {
+					return err
+				}
+				t.pageError(err)
+				// Breaking out recreates the iterator, resuming from the page that failed.
+				if apiErr, delay, ok := rateLimitDelay(err, backoff, rateLimits); ok {
+					t.rateLimited(apiErr, rateLimits, delay)
+					sleepContext(ctx, delay)
+					rateLimits++
+					break
+				}
+				if s, ok := transient(err, transientErrors); ok {
+					delay := backoff.Delay(transientErrors, 0)
+					t.transientError(s, delay)
+					sleepContext(ctx, delay)
+					transientErrors++
+					break
+				}
+				// Page tokens expire on very long runs, the query restarts from the last entry instead.
+				if currentToken != "" && pageTokenExpired(err) && !resume.timestamp.IsZero() {
+					t.pageTokenExpired(count, resume.timestamp)
+					req = proto.Clone(req).(*loggingpb.ListLogEntriesRequest)
+					req.Filter = resume.filter(req)
+					resume.restarted = true
+					currentToken = ""
+					break
+				}
+				return err
+			}
+
+			if rateLimits > 0 {
+				t.rateLimitExpired()
+				rateLimits = 0
+			}
+			transientErrors = 0
+
+			t.page(len(entries))
+			for i, entry := range entries {
+				if resume.covers(entry) {
+					continue
+				}
+				resume.record(entry)
+				count++
+				if err := fn(entry); errors.Is(err, ErrStop) {
+					return nil
+				} else if err != nil {
+					return err
+				}
+				t.backlog(len(entries) - i - 1)
+			}
+
+			if nextToken == "" {
+				return nil
+			}
+			currentToken = nextToken
+		}
+	}
+}
+
+func (t *Trace) pageRequest(pageToken string) {
+	if t != nil && t.PageRequest != nil {
+		t.PageRequest(pageToken)
+	}
+}
+
+func (t *Trace) pageError(err error) {
+	if t != nil && t.PageError != nil {
+		t.PageError(err)
+	}
+}
+
+func (t *Trace) page(entries int) {
+	if t != nil && t.Page != nil {
+		t.Page(entries)
+	}
+}
+
+func (t *Trace) rateLimited(err *apierror.APIError, attempt int, delay time.Duration) {
+	if t != nil && t.RateLimited != nil {
+		t.RateLimited(err, attempt, delay)
+	}
+}
+
+func (t *Trace) rateLimitExpired() {
+	if t != nil && t.RateLimitExpired != nil {
+		t.RateLimitExpired()
+	}
+}
+
+func (t *Trace) transientError(s *status.Status, delay time.Duration) {
+	if t != nil && t.TransientError != nil {
+		t.TransientError(s, delay)
+	}
+}
+
+func (t *Trace) pageTokenExpired(count int, from time.Time) {
+	if t != nil && t.PageTokenExpired != nil {
+		t.PageTokenExpired(count, from)
+	}
+}
+
+func (t *Trace) backlog(entries int) {
+	if t != nil && t.Backlog != nil {
+		t.Backlog(entries)
+	}
+}
+
+// rateLimitDelay returns the pause before retrying a rate limited request, false for other errors.
+// attempt is the number of rate limit errors in a row before this one.
+func rateLimitDelay(err error, backoff Backoff, attempt int) (*apierror.APIError, time.Duration, bool) {
+	// The reason is in the ErrorInfo of the details, only apierror gives easy access to it.
+	apiErr, ok := apierror.FromError(err)
+	if !ok || apiErr.Reason() != "RATE_LIMIT_EXCEEDED" {
+		return nil, 0, false
+	}
+	var retryDelay time.Duration
+	if retryInfo := apiErr.Details().RetryInfo; retryInfo != nil {
+		retryDelay = retryInfo.GetRetryDelay().AsDuration()
+	}
+	return apiErr, backoff.Delay(attempt, retryDelay), true
+}
+
+// transient returns the status of errors that are likely to go away, like network blips, while
+// attempt, the number of transient errors in a row before this one, is below maxTransientErrors
+func transient(err error, attempt int) (*status.Status, bool) {
+	s, ok := Status(err)
+	if !ok || !Retryable(err) || attempt >= maxTransientErrors {
+		return nil, false
+	}
+	return s, true
+}
+
+// sleepContext pauses for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
+
+// retryCodes are the codes the logging client retries by default
+var retryCodes = []codes.Code{codes.DeadlineExceeded, codes.Internal, codes.Unavailable}
+
+// httpCodes maps the HTTP statuses of the REST transport to the gRPC codes they stand for
+var httpCodes = map[int]codes.Code{
+	http.StatusBadRequest:          codes.InvalidArgument,
+	http.StatusUnauthorized:        codes.Unauthenticated,
+	http.StatusForbidden:           codes.PermissionDenied,
+	http.StatusNotFound:            codes.NotFound,
+	http.StatusConflict:            codes.Aborted,
+	http.StatusTooManyRequests:     codes.ResourceExhausted,
+	499:                            codes.Canceled,
+	http.StatusInternalServerError: codes.Internal,
+	http.StatusNotImplemented:      codes.Unimplemented,
+	http.StatusServiceUnavailable:  codes.Unavailable,
+	http.StatusGatewayTimeout:      codes.DeadlineExceeded,
+}
+
+// Status returns the status of an API error of either transport, false for other errors.
+func Status(err error) (*status.Status, bool) {
+	if s, ok := status.FromError(err); ok {
+		return s, true
+	}
+	var httpErr *googleapi.Error
+	if errors.As(err, &httpErr) {
+		code, ok := httpCodes[httpErr.Code]
+		if !ok {
+			code = codes.Unknown
+		}
+		return status.New(code, httpErr.Message), true
+	}
+	return nil, false
+}
+
+// Retryable reports whether err has one of the codes the logging client retries by default.
+func Retryable(err error) bool {
+	s, ok := Status(err)
+	return ok && slices.Contains(retryCodes, s.Code())
+}
+
+// pageTokenExpired reports whether a page request failed because its page token expired
+func pageTokenExpired(err error) bool {
+	s, ok := Status(err)
+	return ok && s.Code() == codes.InvalidArgument && strings.Contains(strings.ToLower(s.Message()), "token")
+}
+
+// fetchResume is where a fetch restarts a query whose page token expired: the timestamp of
+// the last entry handled, and the entries with that timestamp, which the restarted query
+// returns again
+type fetchResume struct {
+	timestamp time.Time
+	seen      map[string]bool
+	restarted bool
+}
+
+// entryKey identifies an entry, insertIds being unique within a log
+func entryKey(entry *LogEntry) string {
+	return entry.LogName + "\x00" + entry.InsertId
+}
+
+func (r *fetchResume) record(entry *LogEntry) {
+	if ts := entry.Timestamp.AsTime(); !ts.Equal(r.timestamp) {
+		r.timestamp = ts
+		r.seen = map[string]bool{}
+	}
+	r.seen[entryKey(entry)] = true
+}
+
+// covers reports whether the restarted query returned an entry handled before the restart
+func (r *fetchResume) covers(entry *LogEntry) bool {
+	return r.restarted && entry.Timestamp.AsTime().Equal(r.timestamp) && r.seen[entryKey(entry)]
+}
+
+// filter returns the filter of the request restarted from the timestamp of the last entry, in its order
+func (r *fetchResume) filter(req *loggingpb.ListLogEntriesRequest) string {
+	op := ">="
+	if strings.HasSuffix(req.OrderBy, " desc") {
+		op = "<="
+	}
+	clause := fmt.Sprintf("timestamp %s %q", op, r.timestamp.UTC().Format(time.RFC3339Nano))
+	if strings.TrimSpace(req.Filter) == "" {
+		return clause
+	}
+	return fmt.Sprintf("(%s) AND (%s)", req.Filter, clause)
+}
@@ -0,0 +1,229 @@
+package grapple
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	logging "cloud.google.com/go/logging/apiv2"
+	"cloud.google.com/go/logging/apiv2/loggingpb"
+	"github.com/googleapis/gax-go/v2/apierror"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 10 * time.Second, Multiplier: 2}
+	cases := []struct {
+		attempt   int
+		requested time.Duration
+		min, max  time.Duration
+	}{
+		{0, 0, time.Second / 2, time.Second},
+		{2, 0, 2 * time.Second, 4 * time.Second},
+		{10, 0, 5 * time.Second, 10 * time.Second},
+		{5000, 0, 5 * time.Second, 10 * time.Second},
+		{0, 30 * time.Second, 30 * time.Second, 30 * time.Second},
+	}
+	for _, c := range cases {
+		for range 100 {
+			if d := b.Delay(c.attempt, c.requested); d < c.min || d > c.max {
+				t.Fatalf("Delay(%d, %s) = %s, want between %s and %s", c.attempt, c.requested, d, c.min, c.max)
+			}
+		}
+	}
+}
+
+func TestTransient(t *testing.T) {
+	cases := []struct {
+		err      error
+		attempt  int
+		expected bool
+	}{
+		{status.Error(codes.Unavailable, "connection reset"), 0, true},
+		{status.Error(codes.DeadlineExceeded, "timeout"), maxTransientErrors - 1, true},
+		{status.Error(codes.Internal, "internal"), maxTransientErrors, false},
+		{status.Error(codes.InvalidArgument, "bad filter"), 0, false},
+		{errors.New("not a status"), 0, false},
+		{&googleapi.Error{Code: http.StatusServiceUnavailable, Message: "unavailable"}, 0, true},
+		{fmt.Errorf("wrapped: %w", &googleapi.Error{Code: http.StatusBadRequest}), 0, false},
+	}
+	for _, c := range cases {
+		if _, got := transient(c.err, c.attempt); got != c.expected {
+			t.Errorf("transient(%v, %d) = %v, want %v", c.err, c.attempt, got, c.expected)
+		}
+	}
+}
+
+func TestPageTokenExpired(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected bool
+	}{
+		{status.Error(codes.InvalidArgument, "page_token has expired"), true},
+		{&googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid page token"}, true},
+		{status.Error(codes.InvalidArgument, "invalid filter"), false},
+		{status.Error(codes.Unavailable, "page token"), false},
+		{errors.New("token expired"), false},
+	}
+	for _, c := range cases {
+		if got := pageTokenExpired(c.err); got != c.expected {
+			t.Errorf("pageTokenExpired(%v) = %v, want %v", c.err, got, c.expected)
+		}
+	}
+}
+
+func TestFetchResume(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)
+	entry := func(id string, offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{InsertId: id, LogName: "projects/p/logs/app", Timestamp: timestamppb.New(ts.Add(offset))}
+	}
+	resume := &fetchResume{}
+	resume.record(entry("a", -time.Second))
+	resume.record(entry("b", 0))
+	resume.record(entry("c", 0))
+
+	if resume.covers(entry("b", 0)) {
+		t.Error("covers() before a restart = true, want false")
+	}
+	resume.restarted = true
+	for _, c := range []struct {
+		entry    *loggingpb.LogEntry
+		expected bool
+	}{
+		{entry("b", 0), true},
+		{entry("c", 0), true},
+		{entry("d", 0), false},
+		{entry("a", -time.Second), false},
+	} {
+		if got := resume.covers(c.entry); got != c.expected {
+			t.Errorf("covers(%s) = %v, want %v", c.entry.InsertId, got, c.expected)
+		}
+	}
+
+	asc := &loggingpb.ListLogEntriesRequest{Filter: "severity>=ERROR"}
+	if got, expected := resume.filter(asc), `(severity>=ERROR) AND (timestamp >= "2024-05-01T14:00:00Z")`; got != expected {
+		t.Errorf("filter() = %s, want %s", got, expected)
+	}
+	desc := &loggingpb.ListLogEntriesRequest{Filter: "severity>=ERROR", OrderBy: "timestamp desc"}
+	if got, expected := resume.filter(desc), `(severity>=ERROR) AND (timestamp <= "2024-05-01T14:00:00Z")`; got != expected {
+		t.Errorf("filter() = %s, want %s", got, expected)
+	}
+}
+
+// fakeLogging serves the pages of entries keyed by page token, failing the requests with the
+// errors queued in fail first
+type fakeLogging struct {
+	loggingpb.UnimplementedLoggingServiceV2Server
+	pages    map[string]*loggingpb.ListLogEntriesResponse
+	fail     []error
+	requests []*loggingpb.ListLogEntriesRequest
+}
+
+func (f *fakeLogging) ListLogEntries(ctx context.Context, req *loggingpb.ListLogEntriesRequest) (*loggingpb.ListLogEntriesResponse, error) {
+	f.requests = append(f.requests, req)
+	if len(f.fail) > 0 {
+		err := f.fail[0]
+		f.fail = f.fail[1:]
+		return nil, err
+	}
+	if page, ok := f.pages[req.PageToken]; ok {
+		return page, nil
+	}
+	return nil, status.Error(codes.InvalidArgument, "unknown page token")
+}
+
+func newFakeClient(t *testing.T, server *fakeLogging) *logging.Client {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer()
+	loggingpb.RegisterLoggingServiceV2Server(s, server)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := logging.NewClient(context.Background(), option.WithGRPCConn(conn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestFetch(t *testing.T) {
+	ts := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC)
+	entry := func(id string, offset time.Duration) *loggingpb.LogEntry {
+		return &loggingpb.LogEntry{InsertId: id, LogName: "projects/p/logs/app", Timestamp: timestamppb.New(ts.Add(offset))}
+	}
+	rateLimited, err := status.New(codes.ResourceExhausted, "quota exceeded").WithDetails(&errdetails.ErrorInfo{Reason: "RATE_LIMIT_EXCEEDED"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &fakeLogging{
+		pages: map[string]*loggingpb.ListLogEntriesResponse{
+			"":   {Entries: []*loggingpb.LogEntry{entry("a", 0), entry("b", time.Second)}, NextPageToken: "p2"},
+			"p2": {Entries: []*loggingpb.LogEntry{entry("c", 2*time.Second)}},
+		},
+		fail: []error{rateLimited.Err()},
+	}
+	var events []string
+	fetcher := &Fetcher{
+		Client:  newFakeClient(t, server),
+		Backoff: Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 2},
+		Trace: &Trace{
+			RateLimited: func(err *apierror.APIError, attempt int, delay time.Duration) {
+				events = append(events, "rate limited")
+			},
+			RateLimitExpired: func() { events = append(events, "rate limit expired") },
+			PageTokenExpired: func(count int, from time.Time) { events = append(events, fmt.Sprintf("restart after %d", count)) },
+		},
+	}
+
+	var ids string
+	fn := func(e *LogEntry) error {
+		ids += e.InsertId
+		if e.InsertId == "b" {
+			// The page token expires before the next page, the query restarts from b.
+			server.pages[""] = &loggingpb.ListLogEntriesResponse{Entries: []*loggingpb.LogEntry{entry("b", time.Second), entry("c", 2*time.Second)}}
+			delete(server.pages, "p2")
+		}
+		return nil
+	}
+	if err := fetcher.Fetch(context.Background(), Query{Project: "p", Filter: "severity>=ERROR", PageSize: 2}, fn); err != nil {
+		t.Fatal(err)
+	}
+	if ids != "abc" {
+		t.Errorf("entries %s, want abc", ids)
+	}
+	if got := fmt.Sprint(events); got != "[rate limited rate limit expired restart after 2]" {
+		t.Errorf("events %s", got)
+	}
+	last := server.requests[len(server.requests)-1]
+	if expected := `(severity>=ERROR) AND (timestamp >= "2025-01-02T15:04:06Z")`; last.Filter != expected || last.ResourceNames[0] != "projects/p" || last.PageSize != 2 {
+		t.Errorf("restarted request %v, want the filter %s", last, expected)
+	}
+
+	ids = ""
+	err = fetcher.Fetch(context.Background(), Query{Project: "p"}, func(e *LogEntry) error {
+		ids += e.InsertId
+		return ErrStop
+	})
+	if err != nil || ids != "b" {
+		t.Errorf("Fetch() stopped = %v with entries %s, want b", err, ids)
+	}
+}
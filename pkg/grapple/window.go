@@ -0,0 +1,50 @@
+package grapple
+
+import "time"
+
+// A WindowStrategy decides where each poll of an incremental collection starts, trading latency
+// and repeated reads for completeness when entries are ingested late. A collection polls the
+// entries from the time returned by From on, skipping the ones it already handled, which it
+// remembers for Overlap behind the newest one.
+type WindowStrategy interface {
+	// From returns the oldest time of the next poll, given when the previous poll started and
+	// the time of the newest entry collected so far.
+	From(polled, newest time.Time) time.Time
+	// Overlap is how far behind the newest entry a poll can reach, and so how long the
+	// collected entries must be remembered to skip them.
+	Overlap() time.Duration
+}
+
+// FixedWindow polls the entries since the previous poll started: the cheapest, but the entries
+// ingested after a poll with an older time are missed.
+type FixedWindow struct{}
+
+// From returns polled.
+func (FixedWindow) From(polled, newest time.Time) time.Time { return polled }
+
+// Overlap returns 0, the polls do not overlap.
+func (FixedWindow) Overlap() time.Duration { return 0 }
+
+// SlidingWindow polls again Lookback before the previous poll started, catching the entries
+// ingested that late.
+type SlidingWindow struct {
+	Lookback time.Duration
+}
+
+// From returns Lookback before polled.
+func (s SlidingWindow) From(polled, newest time.Time) time.Time { return polled.Add(-s.Lookback) }
+
+// Overlap returns Lookback.
+func (s SlidingWindow) Overlap() time.Duration { return s.Lookback }
+
+// WatermarkWindow polls the entries since the newest one collected, minus Slack for late
+// arrivals, so that quiet streams are not affected by the clock of the collecting machine.
+type WatermarkWindow struct {
+	Slack time.Duration
+}
+
+// From returns Slack before newest.
+func (w WatermarkWindow) From(polled, newest time.Time) time.Time { return newest.Add(-w.Slack) }
+
+// Overlap returns Slack.
+func (w WatermarkWindow) Overlap() time.Duration { return w.Slack }
@@ -0,0 +1,30 @@
+package grapple
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowStrategies(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newest, polled := start.Add(time.Minute), start.Add(2*time.Minute)
+
+	cases := []struct {
+		strategy WindowStrategy
+		from     time.Time
+		overlap  time.Duration
+	}{
+		{WatermarkWindow{}, start.Add(time.Minute), 0},
+		{WatermarkWindow{Slack: 30 * time.Second}, start.Add(30 * time.Second), 30 * time.Second},
+		{FixedWindow{}, start.Add(2 * time.Minute), 0},
+		{SlidingWindow{Lookback: 5 * time.Minute}, start.Add(-3 * time.Minute), 5 * time.Minute},
+	}
+	for _, c := range cases {
+		if got := c.strategy.From(polled, newest); !got.Equal(c.from) {
+			t.Errorf("%+v.From() = %s, want %s", c.strategy, got, c.from)
+		}
+		if got := c.strategy.Overlap(); got != c.overlap {
+			t.Errorf("%+v.Overlap() = %s, want %s", c.strategy, got, c.overlap)
+		}
+	}
+}